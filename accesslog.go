@@ -0,0 +1,241 @@
+package gorigumi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// AccessLogFormat selects the line format of an AccessLogger.
+type AccessLogFormat int
+
+const (
+	// AccessLogCombined is the Apache combined log format
+	AccessLogCombined AccessLogFormat = iota
+	// AccessLogCommon is the Apache common log format
+	AccessLogCommon
+	// AccessLogJSON writes one JSON object per line
+	AccessLogJSON
+)
+
+// AccessLogOptions configures an AccessLogger. Either Output or Path must be set.
+type AccessLogOptions struct {
+	// Format is the line format. Default to AccessLogCombined
+	Format AccessLogFormat
+	// Output is the writer log lines are written to. If set, Path and the
+	// rotation options are ignored
+	Output io.Writer
+	// Path is the log file. It is created if it does not exist
+	Path string
+	// MaxSize is the size in bytes after which the log file is rotated.
+	// Zero disables size based rotation
+	MaxSize int64
+	// RotateEvery is the age after which the log file is rotated.
+	// Zero disables time based rotation
+	RotateEvery time.Duration
+	// MaxAge is the age after which rotated files are removed.
+	// Zero keeps them forever
+	MaxAge time.Duration
+	// MaxBackups is the number of rotated files kept. Zero keeps them all
+	MaxBackups int
+	// Compress enables the gzip compression of rotated files
+	Compress bool
+}
+
+// AccessLogger writes one line per served request in a standard format, so
+// services can feed common log pipelines. Use NewAccessLogger to create one
+// and wire it with AccessLogMiddleware.
+type AccessLogger struct {
+	format AccessLogFormat
+	mu     sync.Mutex
+	out    io.Writer
+	file   *RotatingWriter
+}
+
+// AccessLogEntry holds the fields of a single access log line. It is the
+// object written by the AccessLogJSON format.
+type AccessLogEntry struct {
+	Time       time.Time `json:"time"`
+	RemoteAddr string    `json:"remote_addr"`
+	User       string    `json:"user,omitempty"`
+	Method     string    `json:"method"`
+	URI        string    `json:"uri"`
+	Proto      string    `json:"proto"`
+	Status     int       `json:"status"`
+	Bytes      int64     `json:"bytes"`
+	DurationMS float64   `json:"duration_ms"`
+	Referer    string    `json:"referer,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+}
+
+// NewAccessLogger returns a new AccessLogger writing to opts.Output, or to the
+// file at opts.Path through a RotatingWriter with size and time based rotation.
+// To also print the lines on the console, set Output to a NewTeeWriter of a
+// RotatingWriter and os.Stdout.
+func NewAccessLogger(opts AccessLogOptions) (*AccessLogger, error) {
+	l := &AccessLogger{format: opts.Format, out: opts.Output}
+	if l.out != nil {
+		return l, nil
+	}
+
+	if opts.Path == "" {
+		return nil, errors.New("access log output or path is required")
+	}
+
+	file, err := NewRotatingWriter(opts.Path, opts.MaxSize, opts.MaxAge, opts.MaxBackups, opts.Compress)
+	if err != nil {
+		return nil, err
+	}
+	file.interval = opts.RotateEvery
+	l.out, l.file = file, file
+
+	return l, nil
+}
+
+// Log writes a single entry.
+func (l *AccessLogger) Log(e AccessLogEntry) error {
+	var line []byte
+	switch l.format {
+	case AccessLogJSON:
+		out, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		line = append(out, '\n')
+	default:
+		line = []byte(l.formatApache(e))
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, err := l.out.Write(line)
+	return err
+}
+
+// Reopen reopens the log file, so external rotation tools can move it away.
+// It does nothing if the logger writes to a custom Output.
+func (l *AccessLogger) Reopen() error {
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Reopen()
+}
+
+// ReopenOnSignal reopens the log file whenever the process receives one of the
+// given signals, SIGHUP by default. The returned function stops listening.
+func (l *AccessLogger) ReopenOnSignal(sig ...os.Signal) (stop func()) {
+	if len(sig) == 0 {
+		sig = []os.Signal{syscall.SIGHUP}
+	}
+
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sig...)
+
+	go func() {
+		for {
+			select {
+			case <-ch:
+				_ = l.Reopen()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
+
+// Close closes the log file. It does nothing if the logger writes to a
+// custom Output.
+func (l *AccessLogger) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// AccessLogMiddleware returns a middleware writing a line to l for every
+// request served by next. Requests whose client went away are logged with the
+// status StatusClientClosedRequest.
+func (t *Tools) AccessLogMiddleware(next http.Handler, l *AccessLogger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+
+		next.ServeHTTP(sw, r)
+
+		remote := r.RemoteAddr
+		if host, _, err := net.SplitHostPort(remote); err == nil {
+			remote = host
+		}
+
+		user, _, _ := r.BasicAuth()
+		if user == "" && r.URL.User != nil {
+			user = r.URL.User.Username()
+		}
+
+		_ = l.Log(AccessLogEntry{
+			Time:       start,
+			RemoteAddr: remote,
+			User:       user,
+			Method:     r.Method,
+			URI:        r.RequestURI,
+			Proto:      r.Proto,
+			Status:     responseStatus(sw, r),
+			Bytes:      sw.bytes,
+			DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+			Referer:    r.Referer(),
+			UserAgent:  r.UserAgent(),
+		})
+	})
+}
+
+// formatApache formats e in the Apache common or combined log format.
+func (l *AccessLogger) formatApache(e AccessLogEntry) string {
+	uri := e.URI
+	if uri == "" {
+		uri = "/"
+	}
+
+	size := "-"
+	if e.Bytes > 0 {
+		size = strconv.FormatInt(e.Bytes, 10)
+	}
+
+	line := fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %s",
+		dashIfEmpty(e.RemoteAddr), dashIfEmpty(e.User),
+		e.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		e.Method, uri, e.Proto, e.Status, size,
+	)
+
+	if l.format == AccessLogCombined {
+		line += fmt.Sprintf(" %q %q", dashIfEmpty(e.Referer), dashIfEmpty(e.UserAgent))
+	}
+
+	return line + "\n"
+}
+
+// dashIfEmpty returns "-" for empty strings, as the Apache formats do.
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
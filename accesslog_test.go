@@ -0,0 +1,110 @@
+package gorigumi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// accessLogTests is a slice of structs that hold the name of the test, the log format,
+// and a regular expression the written line is expected to match
+var accessLogTests = []struct {
+	name     string
+	format   AccessLogFormat
+	expected string
+}{
+	{"common", AccessLogCommon, `^192\.0\.2\.1 - foo \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "GET /items\?page=2 HTTP/1\.1" 201 5\n$`},
+	{"combined", AccessLogCombined, `^192\.0\.2\.1 - foo \[.+\] "GET /items\?page=2 HTTP/1\.1" 201 5 "http://example\.com/" "gorigumi-test"\n$`},
+}
+
+// TestTools_AccessLogMiddleware tests the AccessLogMiddleware method by serving a request
+// and checking the written line in the Apache and JSON formats.
+func TestTools_AccessLogMiddleware(t *testing.T) {
+	testTools := New()
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	})
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest("GET", "/items?page=2", nil)
+		req.SetBasicAuth("foo", "bar")
+		req.Header.Set("Referer", "http://example.com/")
+		req.Header.Set("User-Agent", "gorigumi-test")
+		return req
+	}
+
+	for _, at := range accessLogTests {
+		var buf bytes.Buffer
+		logger, err := NewAccessLogger(AccessLogOptions{Format: at.format, Output: &buf})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		testTools.AccessLogMiddleware(next, logger).ServeHTTP(httptest.NewRecorder(), newRequest())
+
+		if !regexp.MustCompile(at.expected).MatchString(buf.String()) {
+			t.Errorf("%s: unexpected line %q", at.name, buf.String())
+		}
+	}
+
+	var buf bytes.Buffer
+	logger, _ := NewAccessLogger(AccessLogOptions{Format: AccessLogJSON, Output: &buf})
+	testTools.AccessLogMiddleware(next, logger).ServeHTTP(httptest.NewRecorder(), newRequest())
+
+	var entry AccessLogEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("failed to decode JSON line: %v", err)
+	}
+	if entry.Status != http.StatusCreated || entry.Bytes != 5 || entry.URI != "/items?page=2" || entry.User != "foo" {
+		t.Errorf("unexpected JSON entry %+v", entry)
+	}
+}
+
+// TestAccessLogger_Reopen tests that a log file moved away by an external tool is
+// recreated by Reopen, and that size based rotation keeps the previous file.
+func TestAccessLogger_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+
+	logger, err := NewAccessLogger(AccessLogOptions{Format: AccessLogJSON, Path: path, MaxSize: 150})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	entry := AccessLogEntry{Method: "GET", URI: "/", Status: 200}
+	if err := logger.Log(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Rename(path, path+".old"); err != nil {
+		t.Fatal(err)
+	}
+	if err := logger.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	logger.Log(entry)
+	logger.Log(entry)
+
+	files, _ := filepath.Glob(filepath.Join(dir, "access.log*"))
+	if len(files) != 3 {
+		t.Errorf("expected the moved, the rotated and the current file, got %v", files)
+	}
+
+	data, _ := os.ReadFile(path)
+	if strings.Count(string(data), "\n") != 1 {
+		t.Errorf("expected a single line in the current file, got %q", data)
+	}
+
+	if _, err := NewAccessLogger(AccessLogOptions{}); err == nil {
+		t.Error("expected error for a logger without output")
+	}
+}
@@ -0,0 +1,142 @@
+package gorigumi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// APIClient calls the JSON endpoints of an API, e.g. an internal service, with
+// settings shared by all its calls, through the GetJSON, PostJSON, PutJSON,
+// PatchJSON and DeleteJSON functions. Calls are made with JSONCall, so the
+// RemoteTimeout and PushRetry of the Tools struct apply, and responses with a
+// status other than 2xx return a *RemoteError. Use NewAPIClient to create one.
+type APIClient struct {
+	// BaseURL is the URL the paths of the calls are appended to, e.g.
+	// "https://billing.internal/v1". Paths may be absolute URLs, e.g. the
+	// next page links of a response, only on the scheme and host of BaseURL,
+	// so the headers and credentials of the client are not sent elsewhere
+	BaseURL string
+	// Header holds the headers sent with every call
+	Header http.Header
+	// BearerToken is sent in the Authorization header of every call if set
+	BearerToken string
+	// Timeout caps the duration of every call, retries included. Default to
+	// no limit other than RemoteTimeout
+	Timeout time.Duration
+	// Client is used to make the requests. Default to a new http.Client
+	Client *http.Client
+
+	tools *Tools
+}
+
+// NewAPIClient returns an APIClient calling the API at baseURL with the settings
+// of the Tools struct.
+func (t *Tools) NewAPIClient(baseURL string) *APIClient {
+	return &APIClient{BaseURL: baseURL, Header: make(http.Header), tools: t}
+}
+
+// GetJSON calls the endpoint at path of c with the GET method and returns its
+// response decoded into a T. The optional req adds headers, query parameters or
+// credentials to the call.
+func GetJSON[T any](ctx context.Context, c *APIClient, path string, req ...RemoteRequest) (T, error) {
+	return callJSON[T](ctx, c, http.MethodGet, path, nil, req)
+}
+
+// PostJSON calls the endpoint at path of c with the POST method and data as body,
+// and returns its response decoded into a T.
+func PostJSON[T any](ctx context.Context, c *APIClient, path string, data any, req ...RemoteRequest) (T, error) {
+	return callJSON[T](ctx, c, http.MethodPost, path, data, req)
+}
+
+// PutJSON calls the endpoint at path of c with the PUT method and data as body,
+// and returns its response decoded into a T.
+func PutJSON[T any](ctx context.Context, c *APIClient, path string, data any, req ...RemoteRequest) (T, error) {
+	return callJSON[T](ctx, c, http.MethodPut, path, data, req)
+}
+
+// PatchJSON calls the endpoint at path of c with the PATCH method and data as
+// body, and returns its response decoded into a T.
+func PatchJSON[T any](ctx context.Context, c *APIClient, path string, data any, req ...RemoteRequest) (T, error) {
+	return callJSON[T](ctx, c, http.MethodPatch, path, data, req)
+}
+
+// DeleteJSON calls the endpoint at path of c with the DELETE method and returns
+// its response decoded into a T, e.g. struct{} for endpoints answering 204 No
+// Content.
+func DeleteJSON[T any](ctx context.Context, c *APIClient, path string, req ...RemoteRequest) (T, error) {
+	return callJSON[T](ctx, c, http.MethodDelete, path, nil, req)
+}
+
+// callJSON calls the endpoint at path of c with method and data, with the
+// options of c and of the optional req, and decodes its response into a T.
+func callJSON[T any](ctx context.Context, c *APIClient, method, path string, data any, req []RemoteRequest) (T, error) {
+	var result T
+
+	var options RemoteRequest
+	if len(req) > 0 {
+		options = req[0]
+	}
+	options.Method = method
+
+	header := c.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	for key, values := range options.Header {
+		header[key] = values
+	}
+	options.Header = header
+	if options.BearerToken == "" && options.Username == "" {
+		options.BearerToken = c.BearerToken
+	}
+	if options.Client == nil {
+		options.Client = c.Client
+	}
+
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	rawURL, err := c.url(path)
+	if err != nil {
+		return result, err
+	}
+
+	tools := c.tools
+	if tools == nil {
+		tools = New()
+	}
+	_, err = tools.JSONCall(ctx, rawURL, data, &result, options)
+	return result, err
+}
+
+// url returns the URL of path, appended to BaseURL unless it is an absolute URL.
+// Absolute URLs, protocol-relative ones included, must have the scheme and host
+// of BaseURL.
+func (c *APIClient) url(path string) (string, error) {
+	ref, err := url.Parse(path)
+	if err != nil {
+		return "", err
+	}
+	if c.BaseURL == "" {
+		return path, nil
+	}
+	if !ref.IsAbs() && ref.Host == "" {
+		return strings.TrimSuffix(c.BaseURL, "/") + "/" + strings.TrimPrefix(path, "/"), nil
+	}
+
+	base, err := url.Parse(c.BaseURL)
+	if err != nil {
+		return "", err
+	}
+	if !strings.EqualFold(ref.Scheme, base.Scheme) || !strings.EqualFold(ref.Host, base.Host) {
+		return "", fmt.Errorf("URL %q is not on the host of the base URL %q", path, c.BaseURL)
+	}
+	return path, nil
+}
@@ -0,0 +1,124 @@
+package gorigumi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// apiItem is the resource of the APIClient tests.
+type apiItem struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// newItemsAPI returns a server of items under /v1/items, recording the method, the path
+// and the headers of the last request in last.
+func newItemsAPI(t *testing.T, last *http.Request) *httptest.Server {
+	t.Helper()
+
+	testTools := New()
+	mux := http.NewServeMux()
+	record := func(r *http.Request) { *last = *r.Clone(context.Background()) }
+
+	mux.HandleFunc("GET /v1/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+		if r.PathValue("id") != "1" {
+			_ = testTools.JSONError(w, WithErrorCode(errors.New("no such item"), CodeNotFound), http.StatusNotFound)
+			return
+		}
+		_ = testTools.JSONWrite(w, http.StatusOK, apiItem{ID: 1, Name: "pen"})
+	})
+	mux.HandleFunc("/v1/items", func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+		var item apiItem
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &item)
+		item.ID = 2
+		_ = testTools.JSONWrite(w, http.StatusCreated, item)
+	})
+	mux.HandleFunc("DELETE /v1/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// TestAPIClient tests the typed calls of an APIClient against an API, with the shared
+// base URL, headers and credentials.
+func TestAPIClient(t *testing.T) {
+	var last http.Request
+	srv := newItemsAPI(t, &last)
+	ctx := context.Background()
+
+	client := New().NewAPIClient(srv.URL + "/v1/")
+	client.Header.Set("X-Tenant", "acme")
+	client.BearerToken = "tok"
+
+	item, err := GetJSON[apiItem](ctx, client, "/items/1")
+	if err != nil || item != (apiItem{ID: 1, Name: "pen"}) {
+		t.Errorf("expected the pen, got %+v (%v)", item, err)
+	}
+	if last.Header.Get("X-Tenant") != "acme" || last.Header.Get("Authorization") != "Bearer tok" {
+		t.Errorf("expected the headers of the client, got %v", last.Header)
+	}
+
+	_, err = GetJSON[apiItem](ctx, client, "items/9", RemoteRequest{Header: http.Header{"X-Tenant": {"globex"}}})
+	var remoteErr *RemoteError
+	if !errors.As(err, &remoteErr) || remoteErr.StatusCode != http.StatusNotFound || remoteErr.Code != CodeNotFound || remoteErr.Message != "no such item" {
+		t.Errorf("expected a not found RemoteError, got %v", err)
+	}
+	if last.Header.Get("X-Tenant") != "globex" {
+		t.Errorf("expected the header of the call to win, got %q", last.Header.Get("X-Tenant"))
+	}
+
+	for method, call := range map[string]func() (apiItem, error){
+		http.MethodPost:  func() (apiItem, error) { return PostJSON[apiItem](ctx, client, "items", apiItem{Name: "ink"}) },
+		http.MethodPut:   func() (apiItem, error) { return PutJSON[apiItem](ctx, client, "items", apiItem{Name: "ink"}) },
+		http.MethodPatch: func() (apiItem, error) { return PatchJSON[apiItem](ctx, client, "items", apiItem{Name: "ink"}) },
+	} {
+		item, err := call()
+		if err != nil || item != (apiItem{ID: 2, Name: "ink"}) || last.Method != method {
+			t.Errorf("%s: expected the created ink, got %+v with %s (%v)", method, item, last.Method, err)
+		}
+	}
+
+	if _, err := DeleteJSON[struct{}](ctx, client, "items/1"); err != nil || last.Method != http.MethodDelete || last.URL.Path != "/v1/items/1" {
+		t.Errorf("expected the item to be deleted, got %s %s (%v)", last.Method, last.URL.Path, err)
+	}
+
+	if _, err := GetJSON[apiItem](ctx, client, srv.URL+"/v1/items/1"); err != nil {
+		t.Errorf("expected absolute URLs on the API host to be called, got %v", err)
+	}
+	last = http.Request{}
+	for _, foreign := range []string{"https://evil.example/steal", "//evil.example/steal", strings.Replace(srv.URL, "http:", "https:", 1) + "/v1/items/1"} {
+		if _, err := GetJSON[apiItem](ctx, client, foreign); err == nil || last.Method != "" {
+			t.Errorf("%s: expected the URL to be refused, got %v", foreign, err)
+		}
+	}
+}
+
+// TestAPIClient_Timeout tests that calls are canceled after the Timeout of the client.
+func TestAPIClient_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	client := New().NewAPIClient(srv.URL)
+	client.Timeout = 50 * time.Millisecond
+	if _, err := GetJSON[apiItem](context.Background(), client, "items/1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a deadline error, got %v", err)
+	}
+}
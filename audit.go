@@ -0,0 +1,61 @@
+package gorigumi
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// AuditEvent is a security-relevant action, such as the quarantine of an
+// uploaded file, recorded by an Auditor.
+type AuditEvent struct {
+	// Action names the action, e.g. "upload.quarantined"
+	Action string
+	// Actor is the user who performed the action, e.g. the administrator
+	// releasing a file. Empty for the actions of the toolkit itself
+	Actor string
+	// Resource identifies what the action applies to, e.g. a quarantine ID
+	Resource string
+	// Time is the time of the action
+	Time time.Time
+	// Attrs hold the details of the action, e.g. the verdict of a scanner
+	Attrs map[string]string
+}
+
+// Auditor records audit events, e.g. in an append-only log or a SIEM. Audit
+// must be safe for concurrent use and should not block for long, as it runs on
+// the path of the response.
+type Auditor interface {
+	Audit(ctx context.Context, event AuditEvent)
+}
+
+// AuditorFunc is a function implementing Auditor.
+type AuditorFunc func(ctx context.Context, event AuditEvent)
+
+// Audit calls f(ctx, event).
+func (f AuditorFunc) Audit(ctx context.Context, event AuditEvent) {
+	f(ctx, event)
+}
+
+// audit sends event to the Auditor of the Tools struct, or logs it with the
+// Logger if none is set. The time of the event defaults to now.
+func (t *Tools) audit(ctx context.Context, event AuditEvent) {
+	if event.Time.IsZero() {
+		event.Time = t.clock().Now().UTC()
+	}
+	if t.Auditor != nil {
+		t.Auditor.Audit(ctx, event)
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("action", event.Action),
+		slog.String("actor", event.Actor),
+		slog.String("resource", event.Resource),
+		slog.Time("time", event.Time),
+	}
+	for key, value := range event.Attrs {
+		attrs = append(attrs, slog.String(key, value))
+	}
+	t.logger().LogAttrs(ctx, slog.LevelInfo, "audit", attrs...)
+}
@@ -0,0 +1,29 @@
+package gorigumi
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// TestTools_audit tests that audit events are logged when no Auditor is set.
+func TestTools_audit(t *testing.T) {
+	var buf bytes.Buffer
+	testTools := New()
+	testTools.Logger = slog.New(slog.NewTextHandler(&buf, nil))
+
+	testTools.audit(context.Background(), AuditEvent{
+		Action:   AuditUploadReleased,
+		Actor:    "ana",
+		Resource: "abc",
+		Attrs:    map[string]string{"verdict": "Eicar-Test-Signature"},
+	})
+
+	for _, want := range []string{"msg=audit", "action=upload.released", "actor=ana", "resource=abc", "verdict=Eicar-Test-Signature", "time="} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected the log to contain %q, got %q", want, buf.String())
+		}
+	}
+}
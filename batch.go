@@ -0,0 +1,192 @@
+package gorigumi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// defaultMaxBatchOperations is the default maximum number of operations
+// accepted in a single batch request. It is used by the JSONBatch method.
+const defaultMaxBatchOperations int = 50
+
+// batchOperationContextKey is the context key marking the requests of batch
+// operations, so they can't be batches themselves.
+type batchOperationContextKey struct{}
+
+// BatchOperation represents a single operation inside a batch request.
+// Method and Path are required, Headers are optional and override the
+// headers of the outer request, and Body is passed to the handler as is.
+type BatchOperation struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// BatchResult represents the response of a single batch operation.
+// If the handler responded with JSON, Body contains it verbatim, otherwise
+// the response body is encoded as a JSON string.
+type BatchResult struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// JSONBatch reads a JSON array of BatchOperation values from the request body,
+// dispatches each of them in order against the given handler and writes a JSON
+// array of BatchResult values back to the client with status 200.
+//
+// Every operation inherits the headers and the context of the outer request, so
+// authentication applies to the batched operations as well. Operations targeting
+// the batch endpoint itself are rejected to prevent recursion, and so are batch
+// requests made by an operation, whatever the path they were routed from.
+//
+// The number of operations is limited by the MaxBatchOperations field of the
+// Tools struct, which defaults to 50. The request body is read with JSONRead,
+// so MaxJSONSize and AllowUnknownFields apply to the whole batch.
+func (t *Tools) JSONBatch(w http.ResponseWriter, r *http.Request, handler http.Handler) error {
+	maxOps := defaultMaxBatchOperations
+	if t.MaxBatchOperations != 0 {
+		maxOps = t.MaxBatchOperations
+	}
+
+	if r.Context().Value(batchOperationContextKey{}) != nil {
+		return errors.New("nested batches are not allowed")
+	}
+
+	var ops []BatchOperation
+	if err := t.JSONRead(w, r, &ops); err != nil {
+		return err
+	}
+
+	if len(ops) == 0 {
+		return errors.New("batch must contain at least one operation")
+	}
+	if len(ops) > maxOps {
+		return fmt.Errorf("batch must not contain more than %d operations", maxOps)
+	}
+
+	for i, op := range ops {
+		if op.Method == "" {
+			return fmt.Errorf("operation %d: method is required", i)
+		}
+		if !strings.HasPrefix(op.Path, "/") || strings.HasPrefix(op.Path, "//") {
+			return fmt.Errorf("operation %d: path must be an absolute path", i)
+		}
+		// the path is compared decoded, so "/%62atch" matches "/batch"
+		u, err := url.Parse(op.Path)
+		if err != nil {
+			return fmt.Errorf("operation %d: invalid path: %w", i, err)
+		}
+		if path.Clean(u.Path) == path.Clean(r.URL.Path) {
+			return fmt.Errorf("operation %d: nested batches are not allowed", i)
+		}
+	}
+
+	results := make([]BatchResult, 0, len(ops))
+	for _, op := range ops {
+		results = append(results, t.dispatchBatchOperation(r, handler, op))
+	}
+
+	return t.JSONWrite(w, http.StatusOK, results)
+}
+
+// BatchHandler returns an http.Handler that serves batch requests against the
+// given handler using JSONBatch. Errors are reported with JSONError and status 400.
+func (t *Tools) BatchHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := t.JSONBatch(w, r, handler); err != nil {
+			_ = t.JSONError(w, err, http.StatusBadRequest)
+		}
+	})
+}
+
+// dispatchBatchOperation builds an internal request for op, serves it with
+// handler and converts the recorded response into a BatchResult.
+func (t *Tools) dispatchBatchOperation(r *http.Request, handler http.Handler, op BatchOperation) BatchResult {
+	ctx := context.WithValue(r.Context(), batchOperationContextKey{}, true)
+	req, err := http.NewRequestWithContext(
+		ctx, strings.ToUpper(op.Method), op.Path, bytes.NewReader(op.Body),
+	)
+	if err != nil {
+		return batchErrorResult(http.StatusBadRequest, err)
+	}
+
+	req.Header = r.Header.Clone()
+	req.Header.Del("Content-Length")
+	if len(op.Body) > 0 {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range op.Headers {
+		req.Header.Set(key, value)
+	}
+	req.RemoteAddr = r.RemoteAddr
+	req.Host = r.Host
+
+	rec := &batchRecorder{header: make(http.Header)}
+	handler.ServeHTTP(rec, req)
+
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+
+	result := BatchResult{Status: rec.status}
+	if len(rec.header) > 0 {
+		result.Headers = make(map[string]string, len(rec.header))
+		for key := range rec.header {
+			result.Headers[key] = rec.header.Get(key)
+		}
+	}
+
+	body := bytes.TrimSpace(rec.body.Bytes())
+	switch {
+	case len(body) == 0:
+	case json.Valid(body):
+		result.Body = body
+	default:
+		result.Body, _ = json.Marshal(string(body))
+	}
+
+	return result
+}
+
+// batchErrorResult returns a BatchResult describing err with the given status.
+func batchErrorResult(status int, err error) BatchResult {
+	body, _ := json.Marshal(JSONResponse{Error: true, Message: err.Error()})
+	return BatchResult{Status: status, Body: body}
+}
+
+// batchRecorder is a minimal http.ResponseWriter used to capture the
+// response of a single batch operation.
+type batchRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+// Header returns the response headers of the operation.
+func (b *batchRecorder) Header() http.Header {
+	return b.header
+}
+
+// WriteHeader records the status code of the operation.
+func (b *batchRecorder) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+// Write records the response body of the operation.
+func (b *batchRecorder) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(p)
+}
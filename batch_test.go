@@ -0,0 +1,108 @@
+package gorigumi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// batchTests is a slice of structs that hold the name of the test, the batch request body,
+// the expected status code of the batch endpoint and the expected per-item status codes
+var batchTests = []struct {
+	name           string
+	body           string
+	expectedStatus int
+	itemStatuses   []int
+}{
+	{"single get", `[{"method": "GET", "path": "/ping"}]`, http.StatusOK, []int{200}},
+	{"mixed operations", `[{"method": "GET", "path": "/ping"}, {"method": "POST", "path": "/echo", "body": {"str": "foo"}}, {"method": "GET", "path": "/missing"}]`, http.StatusOK, []int{200, 201, 404}},
+	{"empty batch", `[]`, http.StatusBadRequest, nil},
+	{"missing method", `[{"path": "/ping"}]`, http.StatusBadRequest, nil},
+	{"absolute url", `[{"method": "GET", "path": "http://example.com/ping"}]`, http.StatusBadRequest, nil},
+	{"nested batch", `[{"method": "POST", "path": "/batch"}]`, http.StatusBadRequest, nil},
+	{"too many operations", `[{"method": "GET", "path": "/ping"}, {"method": "GET", "path": "/ping"}, {"method": "GET", "path": "/ping"}, {"method": "GET", "path": "/ping"}]`, http.StatusBadRequest, nil},
+}
+
+// TestTools_JSONBatch tests the BatchHandler method by dispatching batches of operations
+// against a small mux and checking the status of the batch and of every operation.
+func TestTools_JSONBatch(t *testing.T) {
+	testTools := New()
+	testTools.MaxBatchOperations = 3
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("pong"))
+	})
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Str string `json:"str"`
+		}
+		if err := testTools.JSONRead(w, r, &payload); err != nil {
+			testTools.JSONError(w, err, http.StatusBadRequest)
+			return
+		}
+		testTools.JSONWrite(w, http.StatusCreated, payload)
+	})
+
+	for _, bt := range batchTests {
+		req := httptest.NewRequest("POST", "/batch", strings.NewReader(bt.body))
+		responseRecorder := httptest.NewRecorder()
+
+		testTools.BatchHandler(mux).ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != bt.expectedStatus {
+			t.Errorf("%s: expected status code %d, got %d", bt.name, bt.expectedStatus, responseRecorder.Code)
+			continue
+		}
+
+		if bt.expectedStatus != http.StatusOK {
+			continue
+		}
+
+		var results []BatchResult
+		if err := json.NewDecoder(responseRecorder.Body).Decode(&results); err != nil {
+			t.Errorf("%s: failed to decode JSON: %v", bt.name, err)
+			continue
+		}
+
+		if len(results) != len(bt.itemStatuses) {
+			t.Errorf("%s: expected %d results, got %d", bt.name, len(bt.itemStatuses), len(results))
+			continue
+		}
+
+		for i, res := range results {
+			if res.Status != bt.itemStatuses[i] {
+				t.Errorf("%s: operation %d: expected status %d, got %d", bt.name, i, bt.itemStatuses[i], res.Status)
+			}
+		}
+	}
+}
+
+// TestTools_JSONBatch_nested tests that batches can't be nested, neither through
+// another spelling of the path of the batch endpoint nor through another route
+// to it.
+func TestTools_JSONBatch_nested(t *testing.T) {
+	testTools := New()
+	mux := http.NewServeMux()
+	mux.Handle("/batch", testTools.BatchHandler(mux))
+	mux.Handle("/alias", testTools.BatchHandler(mux))
+
+	for _, path := range []string{"/%62atch", "/ping/../batch"} {
+		req := httptest.NewRequest("POST", "/batch", strings.NewReader(`[{"method": "POST", "path": "`+path+`"}]`))
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", path, rr.Code)
+		}
+	}
+
+	body := `[{"method": "POST", "path": "/alias", "body": [{"method": "POST", "path": "/alias"}]}]`
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("POST", "/batch", strings.NewReader(body)))
+	var results []BatchResult
+	if err := json.NewDecoder(rr.Body).Decode(&results); err != nil || len(results) != 1 || results[0].Status != http.StatusBadRequest {
+		t.Errorf("expected the nested batch to be refused, got %d %+v (%v)", rr.Code, results, err)
+	}
+}
@@ -0,0 +1,251 @@
+package gorigumi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultBreakerThreshold is the default number of consecutive failures opening a breaker
+	// it is inlcuded in the NewCircuitBreaker function
+	defaultBreakerThreshold int = 5
+
+	// defaultBreakerCoolDown is the default time a breaker stays open
+	// it is inlcuded in the NewCircuitBreaker function
+	defaultBreakerCoolDown time.Duration = 30 * time.Second
+
+	// defaultBreakerProbes is the default number of probes of a half-open breaker
+	// it is inlcuded in the NewCircuitBreaker function
+	defaultBreakerProbes int = 1
+)
+
+// ErrCircuitOpen is returned for calls rejected by an open CircuitBreaker.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerState is the state of a CircuitBreaker for a key.
+type BreakerState int
+
+const (
+	// BreakerClosed lets every call through, counting consecutive failures
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects every call with ErrCircuitOpen until the cool-down
+	// has elapsed
+	BreakerOpen
+	// BreakerHalfOpen lets a limited number of probes through, closing the
+	// breaker once they all succeed and opening it again on a failure
+	BreakerHalfOpen
+)
+
+// String returns the name of the state, e.g. "half-open".
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler, so states are written by name
+// in health responses.
+func (s BreakerState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// CircuitBreakerOptions configures NewCircuitBreaker.
+type CircuitBreakerOptions struct {
+	// FailureThreshold is the number of consecutive failures opening the
+	// breaker. Default to 5
+	FailureThreshold int
+	// CoolDown is the time the breaker stays open before probes are let
+	// through. Default to 30 seconds
+	CoolDown time.Duration
+	// HalfOpenProbes is the number of concurrent probes let through once the
+	// breaker is half-open, which must all succeed to close it. Default to 1
+	HalfOpenProbes int
+	// OnStateChange is called when the breaker of a key changes state, e.g.
+	// to log it or to update a metric. It is called with the breaker locked,
+	// so it must not call the breaker
+	OnStateChange func(key string, from, to BreakerState)
+	// Clock is the source of time of the cool-down. Default to SystemClock
+	Clock Clock
+}
+
+// CircuitBreaker stops calling a downstream that keeps failing, so a dead remote
+// doesn't pile up requests waiting for it. It tracks each key separately, e.g.
+// the host of a remote. Once FailureThreshold consecutive calls of a key fail,
+// its breaker opens and calls are rejected with ErrCircuitOpen for CoolDown,
+// then HalfOpenProbes calls are let through to decide whether to close it again.
+// Set the RemoteBreaker field of the Tools struct to guard the calls to remotes.
+type CircuitBreaker struct {
+	options CircuitBreakerOptions
+
+	mu    sync.Mutex
+	hosts map[string]*breakerHost
+}
+
+// breakerHost is the state of a CircuitBreaker for a key.
+type breakerHost struct {
+	state     BreakerState
+	failures  int
+	openedAt  time.Time
+	probes    int
+	successes int
+	// generation changes with every state change, so late outcomes of calls
+	// allowed before it are ignored
+	generation int
+}
+
+// NewCircuitBreaker returns a new CircuitBreaker with the given options.
+func NewCircuitBreaker(opts ...CircuitBreakerOptions) *CircuitBreaker {
+	var options CircuitBreakerOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	if options.FailureThreshold <= 0 {
+		options.FailureThreshold = defaultBreakerThreshold
+	}
+	if options.CoolDown <= 0 {
+		options.CoolDown = defaultBreakerCoolDown
+	}
+	if options.HalfOpenProbes <= 0 {
+		options.HalfOpenProbes = defaultBreakerProbes
+	}
+	options.Clock = clockOrSystem(options.Clock)
+	return &CircuitBreaker{options: options, hosts: make(map[string]*breakerHost)}
+}
+
+// Allow reports whether a call for key may be made. If it may, done must be called
+// with the outcome of the call: nil for a success, an error for a failure. Calls
+// canceled with context.Canceled are not counted. Otherwise it returns an error
+// wrapping ErrCircuitOpen.
+func (b *CircuitBreaker) Allow(key string) (done func(err error), err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	h, ok := b.hosts[key]
+	if !ok {
+		h = &breakerHost{}
+		b.hosts[key] = h
+	}
+
+	if h.state == BreakerOpen && b.options.Clock.Now().Sub(h.openedAt) >= b.options.CoolDown {
+		b.setState(key, h, BreakerHalfOpen)
+	}
+	switch {
+	case h.state == BreakerOpen:
+		return nil, fmt.Errorf("%w: %s", ErrCircuitOpen, key)
+	case h.state == BreakerHalfOpen && h.probes >= b.options.HalfOpenProbes:
+		return nil, fmt.Errorf("%w: %s is being probed", ErrCircuitOpen, key)
+	case h.state == BreakerHalfOpen:
+		h.probes++
+	}
+
+	generation := h.generation
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() { b.record(key, h, generation, err) })
+	}, nil
+}
+
+// record counts the outcome err of a call for key allowed in generation.
+func (b *CircuitBreaker) record(key string, h *breakerHost, generation int, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if h.generation != generation {
+		return
+	}
+	if h.state == BreakerHalfOpen {
+		h.probes--
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+	case err != nil && h.state == BreakerHalfOpen:
+		b.setState(key, h, BreakerOpen)
+	case err != nil:
+		h.failures++
+		if h.failures >= b.options.FailureThreshold {
+			b.setState(key, h, BreakerOpen)
+		}
+	case h.state == BreakerHalfOpen:
+		h.successes++
+		if h.successes >= b.options.HalfOpenProbes {
+			b.setState(key, h, BreakerClosed)
+		}
+	default:
+		h.failures = 0
+	}
+}
+
+// setState moves the breaker of key to state, with b.mu held.
+func (b *CircuitBreaker) setState(key string, h *breakerHost, state BreakerState) {
+	from := h.state
+	*h = breakerHost{state: state, generation: h.generation + 1}
+	if state == BreakerOpen {
+		h.openedAt = b.options.Clock.Now()
+	}
+	if b.options.OnStateChange != nil {
+		b.options.OnStateChange(key, from, state)
+	}
+}
+
+// State returns the state of the breaker of key. Open breakers whose cool-down
+// has elapsed are reported half-open.
+func (b *CircuitBreaker) State(key string) BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	h, ok := b.hosts[key]
+	if !ok {
+		return BreakerClosed
+	}
+	return b.state(h)
+}
+
+// States returns the state of the breaker of every key called so far, e.g. to be
+// written by a health endpoint with JSONWrite.
+func (b *CircuitBreaker) States() map[string]BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	states := make(map[string]BreakerState, len(b.hosts))
+	for key, h := range b.hosts {
+		states[key] = b.state(h)
+	}
+	return states
+}
+
+// state returns the current state of h, with b.mu held.
+func (b *CircuitBreaker) state(h *breakerHost) BreakerState {
+	if h.state == BreakerOpen && b.options.Clock.Now().Sub(h.openedAt) >= b.options.CoolDown {
+		return BreakerHalfOpen
+	}
+	return h.state
+}
+
+// doRemote sends req with httpClient through the RemoteBreaker of the Tools struct
+// if set, keyed by the host of req. Network errors and 5xx responses count as
+// failures of the host.
+func (t *Tools) doRemote(httpClient *http.Client, req *http.Request) (*http.Response, error) {
+	if t.RemoteBreaker == nil {
+		return httpClient.Do(req)
+	}
+	done, err := t.RemoteBreaker.Allow(req.URL.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := httpClient.Do(req)
+	if err == nil && res.StatusCode >= 500 {
+		done(fmt.Errorf("remote responded with status %d", res.StatusCode))
+	} else {
+		done(err)
+	}
+	return res, err
+}
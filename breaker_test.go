@@ -0,0 +1,131 @@
+package gorigumi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/drunkleen/gorigumi/toolkittest"
+)
+
+// TestCircuitBreaker tests the transitions of a breaker between its closed, open and
+// half-open states.
+func TestCircuitBreaker(t *testing.T) {
+	clock := toolkittest.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	var changes []string
+	b := NewCircuitBreaker(CircuitBreakerOptions{
+		FailureThreshold: 3,
+		CoolDown:         time.Minute,
+		Clock:            clock,
+		OnStateChange: func(key string, from, to BreakerState) {
+			changes = append(changes, key+": "+from.String()+" -> "+to.String())
+		},
+	})
+	failure := errors.New("connection refused")
+
+	call := func(err error) error {
+		done, allowErr := b.Allow("billing")
+		if allowErr != nil {
+			return allowErr
+		}
+		done(err)
+		return nil
+	}
+
+	// failures are only counted when consecutive
+	call(failure)
+	call(failure)
+	call(nil)
+	call(failure)
+	call(context.Canceled)
+	call(failure)
+	if state := b.State("billing"); state != BreakerClosed {
+		t.Fatalf("expected the breaker to be closed, got %s", state)
+	}
+	call(failure)
+	if err := call(nil); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected the open breaker to reject calls, got %v", err)
+	}
+	if state := b.State("orders"); state != BreakerClosed {
+		t.Errorf("expected other keys to be closed, got %s", state)
+	}
+
+	// a single probe is let through once the cool-down has elapsed
+	clock.Advance(time.Minute)
+	if state := b.State("billing"); state != BreakerHalfOpen {
+		t.Errorf("expected the breaker to be half-open, got %s", state)
+	}
+	probe, err := b.Allow("billing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Allow("billing"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected a second probe to be rejected, got %v", err)
+	}
+	probe(failure)
+	if err := call(nil); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected a failed probe to open the breaker, got %v", err)
+	}
+
+	clock.Advance(time.Minute)
+	if err := call(nil); err != nil {
+		t.Errorf("expected the probe to be let through, got %v", err)
+	}
+	if state := b.State("billing"); state != BreakerClosed {
+		t.Errorf("expected a successful probe to close the breaker, got %s", state)
+	}
+
+	expected := []string{
+		"billing: closed -> open", "billing: open -> half-open", "billing: half-open -> open",
+		"billing: open -> half-open", "billing: half-open -> closed",
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("expected the changes %v, got %v", expected, changes)
+	}
+	for i := range expected {
+		if changes[i] != expected[i] {
+			t.Errorf("expected the changes %v, got %v", expected, changes)
+			break
+		}
+	}
+
+	states, _ := json.Marshal(b.States())
+	if string(states) != `{"billing":"closed"}` {
+		t.Errorf("unexpected states %s", states)
+	}
+}
+
+// TestTools_RemoteBreaker tests that pushes to a failing remote are rejected without
+// reaching it once its breaker is open.
+func TestTools_RemoteBreaker(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	testTools := New()
+	testTools.RemoteBreaker = NewCircuitBreaker(CircuitBreakerOptions{FailureThreshold: 2})
+	testTools.PushRetry = &RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Millisecond}
+
+	_, status, err := testTools.JSONPushToRemote(srv.URL, map[string]int{"id": 1})
+	if !errors.Is(err, ErrCircuitOpen) || status != 0 || calls.Load() != 2 {
+		t.Errorf("expected retries to stop once the breaker opened, got %d calls, status %d (%v)", calls.Load(), status, err)
+	}
+
+	if _, _, err := testTools.JSONSendToRemote(context.Background(), srv.URL, nil, RemoteRequest{}); !errors.Is(err, ErrCircuitOpen) || calls.Load() != 2 {
+		t.Errorf("expected the request to be rejected, got %d calls (%v)", calls.Load(), err)
+	}
+
+	host, _ := url.Parse(srv.URL)
+	if state := testTools.RemoteBreaker.State(host.Host); state != BreakerOpen {
+		t.Errorf("expected the breaker of %s to be open, got %s", host.Host, state)
+	}
+}
@@ -0,0 +1,83 @@
+package gorigumi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+)
+
+const (
+	// defaultBroadcastConcurrency is the default maximum number of concurrent pushes
+	// it is inlcuded in the JSONBroadcast method
+	defaultBroadcastConcurrency int = 10
+)
+
+// BroadcastOptions configures JSONBroadcast.
+type BroadcastOptions struct {
+	// Concurrency is the maximum number of endpoints pushed to at once.
+	// Default to 10
+	Concurrency int
+	// Request holds the method, headers, query parameters, credentials and
+	// client of the requests, as for JSONSendToRemote. Default to POST
+	Request RemoteRequest
+}
+
+// BroadcastResult is the outcome of the push of JSONBroadcast to an endpoint.
+type BroadcastResult struct {
+	// URL is the endpoint pushed to
+	URL string
+	// StatusCode is the status code of the response, 0 if none was received
+	StatusCode int
+	// Err is the error of the push, including responses with a status other
+	// than 2xx. It is nil if the endpoint received the payload
+	Err error
+}
+
+// JSONBroadcast pushes data as JSON to every endpoint of urls concurrently, e.g. to
+// fan an event out to its subscribers, with at most Concurrency pushes at once.
+// Each push is made like JSONSendToRemote, so RemoteTimeout, PushRetry and
+// RemoteBreaker apply to every endpoint separately. A failing endpoint does not
+// stop the others: it returns a result for every endpoint, in the order of urls.
+// The error is only set if data can't be marshaled or a push panicked, in which
+// case the *PanicError is also the Err of the result of its endpoint.
+func (t *Tools) JSONBroadcast(ctx context.Context, urls []string, data any, opts ...BroadcastOptions) ([]BroadcastResult, error) {
+	var options BroadcastOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	if options.Concurrency <= 0 {
+		options.Concurrency = defaultBroadcastConcurrency
+	}
+
+	// the payload is marshaled once for all the endpoints
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]BroadcastResult, len(urls))
+	for i, url := range urls {
+		results[i].URL = url
+	}
+
+	g, _ := NewGroup(ctx, options.Concurrency)
+	for i, url := range urls {
+		g.Go(func(context.Context) (err error) {
+			defer func() {
+				if v := recover(); v != nil {
+					err = &PanicError{Value: v, Stack: debug.Stack()}
+					results[i].Err = err
+				}
+			}()
+
+			_, status, pushErr := t.sendToRemote(ctx, url, json.RawMessage(jsonData), options.Request, nil)
+			if pushErr == nil && (status < 200 || status > 299) {
+				pushErr = fmt.Errorf("remote responded with status %d", status)
+			}
+			results[i].StatusCode, results[i].Err = status, pushErr
+			return nil
+		})
+	}
+	return results, g.Wait()
+}
@@ -0,0 +1,110 @@
+package gorigumi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestTools_JSONBroadcast tests that the payload is pushed to every endpoint with
+// bounded concurrency, and that failing endpoints get their own result.
+func TestTools_JSONBroadcast(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	subscriber := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			peak := maxInFlight.Load()
+			if n <= peak || maxInFlight.CompareAndSwap(peak, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"event":"order.created"}` || r.Header.Get("X-Topic") != "orders" {
+			t.Errorf("unexpected push %s with topic %q", body, r.Header.Get("X-Topic"))
+		}
+		if r.URL.Query().Get("fail") != "" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer subscriber.Close()
+
+	gone := httptest.NewServer(http.NotFoundHandler())
+	gone.Close()
+
+	urls := []string{subscriber.URL + "/a", subscriber.URL + "/b?fail=1", gone.URL, subscriber.URL + "/c", subscriber.URL + "/d"}
+	results, err := New().JSONBroadcast(context.Background(), urls, map[string]string{"event": "order.created"}, BroadcastOptions{
+		Concurrency: 2,
+		Request:     RemoteRequest{Header: http.Header{"X-Topic": {"orders"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []int{http.StatusAccepted, http.StatusInternalServerError, 0, http.StatusAccepted, http.StatusAccepted}
+	for i, res := range results {
+		if res.URL != urls[i] || res.StatusCode != expected[i] || (res.Err == nil) != (expected[i] == http.StatusAccepted) {
+			t.Errorf("%s: expected status %d, got %d (%v)", urls[i], expected[i], res.StatusCode, res.Err)
+		}
+	}
+	if peak := maxInFlight.Load(); peak > 2 {
+		t.Errorf("expected at most 2 pushes at once, got %d", peak)
+	}
+
+	if _, err := New().JSONBroadcast(context.Background(), urls, make(chan int)); err == nil {
+		t.Error("expected an error for a payload that can't be marshaled")
+	}
+}
+
+// panickingTransport is an http.RoundTripper panicking for the requests to its
+// path, and sending the others with http.DefaultTransport.
+type panickingTransport struct {
+	path string
+}
+
+// RoundTrip panics for requests to the path of the transport.
+func (p panickingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Path == p.path {
+		panic("transport failed")
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// TestTools_JSONBroadcast_panic tests that a panicking push is reported in the
+// result of its endpoint, while the others are pushed.
+func TestTools_JSONBroadcast_panic(t *testing.T) {
+	subscriber := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer subscriber.Close()
+
+	urls := []string{subscriber.URL + "/a", subscriber.URL + "/panic", subscriber.URL + "/b"}
+	results, err := New().JSONBroadcast(context.Background(), urls, 1, BroadcastOptions{
+		Request: RemoteRequest{Client: &http.Client{Transport: panickingTransport{path: "/panic"}}},
+	})
+
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("expected a *PanicError, got %v", err)
+	}
+	for i, res := range results {
+		if res.URL != urls[i] {
+			t.Errorf("expected the result of %s, got %s", urls[i], res.URL)
+		}
+	}
+	if !errors.As(results[1].Err, &panicErr) || results[1].StatusCode != 0 {
+		t.Errorf("expected the panic in the result of %s, got %+v", urls[1], results[1])
+	}
+	if results[0].StatusCode != http.StatusAccepted || results[2].StatusCode != http.StatusAccepted {
+		t.Errorf("expected the other endpoints to be pushed, got %+v", results)
+	}
+}
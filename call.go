@@ -0,0 +1,120 @@
+package gorigumi
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/drunkleen/gorigumi/jsonx"
+)
+
+// RemoteError is the error of JSONCall for responses of the remote with a status
+// other than 2xx. It holds the body of the response, and the code and the message
+// of JSON error bodies, such as the ones written by JSONError or StandardEnvelope.
+type RemoteError struct {
+	// StatusCode is the status code of the response
+	StatusCode int
+	// Header holds the headers of the response
+	Header http.Header
+	// Body is the body of the response, up to MaxJSONSize
+	Body []byte
+	// Code is the error code of the body, if any, e.g. "not_found"
+	Code string
+	// Message is the error message of the body, if any
+	Message string
+}
+
+// Error returns the status code and the message of the response.
+func (e *RemoteError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("remote responded with status %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("remote responded with status %d", e.StatusCode)
+}
+
+// JSONCall sends data to rawURL like JSONSendToRemote and decodes the JSON body of
+// the response into dst, e.g. to call a third-party API, unless dst is nil or the
+// response has no body. It returns the status code of the response. Responses with
+// a status other than 2xx return a *RemoteError holding their body, and bodies
+// larger than MaxJSONSize, or 1MB if it is not set, return an error with the code
+// CodePayloadTooLarge. The request accepts application/json unless req sets an
+// Accept header.
+func (t *Tools) JSONCall(ctx context.Context, rawURL string, data, dst any, req RemoteRequest) (int, error) {
+	maxBytes := t.MaxJSONSize
+	if maxBytes == 0 {
+		maxBytes = jsonx.DefaultMaxBytes
+	}
+
+	if req.Header.Get("Accept") == "" {
+		req.Header = req.Header.Clone()
+		if req.Header == nil {
+			req.Header = make(http.Header)
+		}
+		req.Header.Set("Accept", "application/json")
+	}
+
+	var body []byte
+	res, status, err := t.sendToRemote(ctx, rawURL, data, req, func(r io.Reader) error {
+		var err error
+		body, err = io.ReadAll(io.LimitReader(r, int64(maxBytes)+1))
+		return err
+	})
+	if err != nil {
+		return status, err
+	}
+	if len(body) > maxBytes {
+		return status, WithErrorCode(fmt.Errorf("remote response must not be larger than %d bytes", maxBytes), CodePayloadTooLarge)
+	}
+
+	if status < 200 || status > 299 {
+		return status, newRemoteError(res, body)
+	}
+	if dst == nil || len(bytes.TrimSpace(body)) == 0 {
+		return status, nil
+	}
+	if err := json.Unmarshal(body, dst); err != nil {
+		return status, fmt.Errorf("decoding remote response: %w", err)
+	}
+	return status, nil
+}
+
+// newRemoteError returns the error of the response res with the given body,
+// with the code and the message of JSON error bodies.
+func newRemoteError(res *http.Response, body []byte) *RemoteError {
+	e := &RemoteError{StatusCode: res.StatusCode, Header: res.Header, Body: body}
+
+	mediaType, _, _ := strings.Cut(res.Header.Get("Content-Type"), ";")
+	if !strings.HasSuffix(strings.TrimSpace(mediaType), "json") {
+		return e
+	}
+
+	// JSONResponse and EnvelopeBody errors, or bodies with a message or an
+	// error string
+	var errBody struct {
+		Code    string          `json:"code"`
+		Message string          `json:"message"`
+		Error   json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(body, &errBody) != nil {
+		return e
+	}
+	e.Code, e.Message = errBody.Code, errBody.Message
+
+	var nested struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	var message string
+	switch {
+	case json.Unmarshal(errBody.Error, &nested) == nil:
+		e.Code, e.Message = cmp.Or(nested.Code, e.Code), cmp.Or(nested.Message, e.Message)
+	case json.Unmarshal(errBody.Error, &message) == nil:
+		e.Message = cmp.Or(e.Message, message)
+	}
+	return e
+}
@@ -0,0 +1,93 @@
+package gorigumi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// jsonCallTests is a slice of structs that hold the name of the test, the status, the
+// content type and the body of the remote response, and the expected name, error code
+// and error message
+var jsonCallTests = []struct {
+	name        string
+	status      int
+	contentType string
+	body        string
+	userName    string
+	code        string
+	message     string
+}{
+	{"decoded", http.StatusOK, "application/json", `{"name":"ana"}`, "ana", "", ""},
+	{"no content", http.StatusNoContent, "", "", "", "", ""},
+	{"json error", http.StatusNotFound, "application/json", `{"error":true,"message":"no such user","code":"not_found"}`, "", "not_found", "no such user"},
+	{"envelope error", http.StatusConflict, "application/json; charset=utf-8", `{"error":{"code":"conflict","message":"taken"},"meta":{}}`, "", "conflict", "taken"},
+	{"error string", http.StatusBadRequest, "application/problem+json", `{"error":"invalid name"}`, "", "", "invalid name"},
+	{"text error", http.StatusBadGateway, "text/html", `<h1>Bad Gateway</h1>`, "", "", ""},
+}
+
+// TestTools_JSONCall tests that responses are decoded into the destination, and that
+// other statuses return a RemoteError with the body, code and message.
+func TestTools_JSONCall(t *testing.T) {
+	for _, ct := range jsonCallTests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Accept") != "application/json" {
+				t.Errorf("%s: expected JSON to be accepted, got %q", ct.name, r.Header.Get("Accept"))
+			}
+			if ct.contentType != "" {
+				w.Header().Set("Content-Type", ct.contentType)
+			}
+			w.WriteHeader(ct.status)
+			w.Write([]byte(ct.body))
+		}))
+
+		var user struct {
+			Name string `json:"name"`
+		}
+		status, err := New().JSONCall(context.Background(), srv.URL, map[string]int{"id": 1}, &user, RemoteRequest{})
+		srv.Close()
+
+		if status != ct.status || user.Name != ct.userName {
+			t.Errorf("%s: expected status %d and name %q, got %d and %q", ct.name, ct.status, ct.userName, status, user.Name)
+		}
+		if ct.status < 300 {
+			if err != nil {
+				t.Errorf("%s: %v", ct.name, err)
+			}
+			continue
+		}
+
+		var remoteErr *RemoteError
+		if !errors.As(err, &remoteErr) {
+			t.Errorf("%s: expected a RemoteError, got %v", ct.name, err)
+			continue
+		}
+		if remoteErr.StatusCode != ct.status || string(remoteErr.Body) != ct.body || remoteErr.Code != ct.code || remoteErr.Message != ct.message {
+			t.Errorf("%s: unexpected error %+v", ct.name, remoteErr)
+		}
+	}
+}
+
+// TestTools_JSONCall_limits tests that large and malformed responses return errors.
+func TestTools_JSONCall_limits(t *testing.T) {
+	body := `{"name":"` + strings.Repeat("a", 100) + `"}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	testTools := New()
+	testTools.MaxJSONSize = 50
+	var user struct{ Name string }
+	if _, err := testTools.JSONCall(context.Background(), srv.URL, nil, &user, RemoteRequest{Method: http.MethodGet}); ErrorCode(err) != CodePayloadTooLarge {
+		t.Errorf("expected code %s, got %v", CodePayloadTooLarge, err)
+	}
+
+	body = `{"name":`
+	if _, err := New().JSONCall(context.Background(), srv.URL, nil, &user, RemoteRequest{Method: http.MethodGet}); err == nil {
+		t.Error("expected an error for a malformed response")
+	}
+}
@@ -0,0 +1,105 @@
+package gorigumi
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"time"
+)
+
+// ChaosEnvVar is the environment variable that enables ChaosMiddleware.
+// The middleware is a no-op unless it is set to "1" or "true".
+const ChaosEnvVar = "GORIGUMI_CHAOS"
+
+// ChaosOptions configures the faults injected by ChaosMiddleware. Every
+// percentage is the share of requests (0-100) affected by the fault.
+type ChaosOptions struct {
+	// LatencyPercent is the share of requests delayed by Latency
+	LatencyPercent float64
+	// Latency is the delay added to affected requests
+	Latency time.Duration
+	// ErrorPercent is the share of requests answered with ErrorStatus
+	ErrorPercent float64
+	// ErrorStatus is the status code of injected errors. Default to 500
+	ErrorStatus int
+	// DropPercent is the share of requests whose connection is dropped
+	// before a response is written
+	DropPercent float64
+	// TruncatePercent is the share of requests whose response body is cut
+	// in half before the connection is dropped
+	TruncatePercent float64
+}
+
+// ChaosMiddleware returns a middleware that injects latency, errors, dropped
+// connections and truncated bodies into a percentage of the requests served by
+// next, so clients can be tested against failing upload and JSON endpoints.
+//
+// The middleware is guarded by the GORIGUMI_CHAOS environment variable, read
+// when the middleware is created: unless it is set to "1" or "true", next is
+// returned unchanged, so it is safe to leave it wired in production builds.
+func (t *Tools) ChaosMiddleware(next http.Handler, opts ChaosOptions) http.Handler {
+	if env := os.Getenv(ChaosEnvVar); env != "1" && env != "true" {
+		return next
+	}
+
+	if opts.ErrorStatus == 0 {
+		opts.ErrorStatus = http.StatusInternalServerError
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if chaosHit(opts.LatencyPercent) {
+			select {
+			case <-time.After(opts.Latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		if chaosHit(opts.DropPercent) {
+			// aborts the handler and closes the connection without a response
+			panic(http.ErrAbortHandler)
+		}
+
+		if chaosHit(opts.ErrorPercent) {
+			_ = t.JSONError(w, errChaos, opts.ErrorStatus)
+			return
+		}
+
+		if chaosHit(opts.TruncatePercent) {
+			next.ServeHTTP(&truncatingWriter{ResponseWriter: w}, r)
+			panic(http.ErrAbortHandler)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// errChaos is the error reported by injected error responses.
+var errChaos = chaosError("injected fault")
+
+// chaosError is the type of errors produced by ChaosMiddleware.
+type chaosError string
+
+// Error returns the error message.
+func (e chaosError) Error() string {
+	return string(e)
+}
+
+// chaosHit reports whether a fault with the given percentage should fire.
+func chaosHit(percent float64) bool {
+	return percent > 0 && rand.Float64()*100 < percent
+}
+
+// truncatingWriter forwards only the first half of every write to the
+// underlying http.ResponseWriter.
+type truncatingWriter struct {
+	http.ResponseWriter
+}
+
+// Write forwards half of p and reports it as fully written.
+func (tw *truncatingWriter) Write(p []byte) (int, error) {
+	if _, err := tw.ResponseWriter.Write(p[:len(p)/2]); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
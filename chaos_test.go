@@ -0,0 +1,69 @@
+package gorigumi
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// chaosTests is a slice of structs that hold the name of the test, the value of the chaos
+// environment variable, the injected faults, the expected status code and a boolean that
+// indicates if the request is expected to fail at the transport level
+var chaosTests = []struct {
+	name           string
+	env            string
+	opts           ChaosOptions
+	expectedStatus int
+	transportError bool
+}{
+	{"disabled", "", ChaosOptions{ErrorPercent: 100}, http.StatusOK, false},
+	{"no faults", "1", ChaosOptions{}, http.StatusOK, false},
+	{"latency", "1", ChaosOptions{LatencyPercent: 100, Latency: 10 * time.Millisecond}, http.StatusOK, false},
+	{"error", "true", ChaosOptions{ErrorPercent: 100, ErrorStatus: http.StatusServiceUnavailable}, http.StatusServiceUnavailable, false},
+	{"drop", "1", ChaosOptions{DropPercent: 100}, 0, true},
+	{"truncate", "1", ChaosOptions{TruncatePercent: 100}, http.StatusOK, true},
+}
+
+// TestTools_ChaosMiddleware tests the ChaosMiddleware method by serving requests through a
+// real test server with each fault enabled for every request, and checking that the middleware
+// does nothing unless the environment variable is set.
+func TestTools_ChaosMiddleware(t *testing.T) {
+	testTools := New()
+
+	body := strings.Repeat("gorigumi", 512)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "4096")
+		io.WriteString(w, body)
+	})
+
+	for _, ct := range chaosTests {
+		t.Setenv(ChaosEnvVar, ct.env)
+
+		server := httptest.NewServer(testTools.ChaosMiddleware(next, ct.opts))
+
+		start := time.Now()
+		res, err := http.Get(server.URL)
+		if err == nil {
+			_, err = io.ReadAll(res.Body)
+			res.Body.Close()
+		}
+
+		switch {
+		case ct.transportError && err == nil:
+			t.Errorf("%s: expected transport error but got none", ct.name)
+		case !ct.transportError && err != nil:
+			t.Errorf("%s: %s", ct.name, err)
+		case res != nil && res.StatusCode != ct.expectedStatus:
+			t.Errorf("%s: expected status code %d, got %d", ct.name, ct.expectedStatus, res.StatusCode)
+		}
+
+		if elapsed := time.Since(start); elapsed < ct.opts.Latency {
+			t.Errorf("%s: expected latency of at least %s, got %s", ct.name, ct.opts.Latency, elapsed)
+		}
+
+		server.Close()
+	}
+}
@@ -0,0 +1,53 @@
+package gorigumi
+
+import "time"
+
+// Clock is the source of time of the time-dependent features of this module,
+// such as route budgets, throttling, debouncing and retries. It only uses
+// standard types, so test clocks such as toolkittest.Clock implement it
+// without importing this package.
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+	// After waits for d to elapse and then sends the current time on the
+	// returned channel
+	After(d time.Duration) <-chan time.Time
+	// AfterFunc calls f in its own goroutine once d elapsed. The returned
+	// function cancels the call and reports whether it was still pending
+	AfterFunc(d time.Duration, f func()) (stop func() bool)
+}
+
+// SystemClock is the Clock backed by the time package. It is used when no
+// Clock is configured.
+var SystemClock Clock = systemClock{}
+
+// systemClock is the type of SystemClock.
+type systemClock struct{}
+
+// Now returns time.Now().
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// After returns time.After(d).
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// AfterFunc wraps time.AfterFunc.
+func (systemClock) AfterFunc(d time.Duration, f func()) func() bool {
+	return time.AfterFunc(d, f).Stop
+}
+
+// clockOrSystem returns c, or SystemClock if c is nil.
+func clockOrSystem(c Clock) Clock {
+	if c == nil {
+		return SystemClock
+	}
+	return c
+}
+
+// clock returns the Clock of the Tools struct, or SystemClock if not set.
+func (t *Tools) clock() Clock {
+	return clockOrSystem(t.Clock)
+}
@@ -0,0 +1,75 @@
+package gorigumi
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/drunkleen/gorigumi/toolkittest"
+)
+
+// compile-time check that the test clock implements Clock
+var _ Clock = (*toolkittest.Clock)(nil)
+
+// TestClock_Throttler tests the Throttler with a controllable clock.
+func TestClock_Throttler(t *testing.T) {
+	clock := toolkittest.NewClock(time.Now())
+	th := &Throttler{Interval: time.Minute, Clock: clock}
+
+	runs := 0
+	th.Do("a", func() { runs++ })
+	th.Do("a", func() { runs++ })
+	clock.Advance(time.Minute)
+	th.Do("a", func() { runs++ })
+
+	if runs != 2 {
+		t.Errorf("expected 2 runs, got %d", runs)
+	}
+}
+
+// TestClock_Retry tests that Retry waits on its clock between attempts.
+func TestClock_Retry(t *testing.T) {
+	clock := toolkittest.NewClock(time.Now())
+	policy := RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Hour, Clock: clock}
+
+	done := make(chan error)
+	go func() {
+		_, err := Retry(context.Background(), policy, func(ctx context.Context) (int, error) {
+			return 0, errors.New("failed")
+		})
+		done <- err
+	}()
+
+	// wait for Retry to sleep on the clock
+	for clock.Pending() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	select {
+	case <-done:
+		t.Fatal("expected Retry to wait for the clock")
+	default:
+	}
+
+	clock.Advance(time.Hour)
+	if err := <-done; err == nil {
+		t.Error("expected error but got none")
+	}
+}
+
+// TestClock_Metrics tests that budget windows follow the clock of Metrics.
+func TestClock_Metrics(t *testing.T) {
+	clock := toolkittest.NewClock(time.Now())
+	m := NewMetrics()
+	m.Clock = clock
+	m.DefaultBudget = &RouteBudget{Window: time.Minute}
+
+	m.Observe("GET /", time.Millisecond, 500)
+	clock.Advance(2 * time.Minute)
+	m.Observe("GET /", time.Millisecond, 200)
+
+	if stats := m.Snapshot()["GET /"]; stats.Requests != 1 || stats.Errors != 0 {
+		t.Errorf("expected the first request to leave the window, got %+v", stats)
+	}
+}
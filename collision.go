@@ -0,0 +1,117 @@
+package gorigumi
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// maxCollisionSuffix is the highest suffix tried by CollisionSuffix before
+	// giving up
+	maxCollisionSuffix int = 1000
+)
+
+// CollisionPolicy decides what happens when an uploaded file is stored under a
+// name that already exists in the upload directory, which can only happen when
+// files are not renamed.
+type CollisionPolicy int
+
+const (
+	// CollisionOverwrite replaces the existing file.
+	CollisionOverwrite CollisionPolicy = iota
+	// CollisionError rejects the upload with an error wrapping os.ErrExist.
+	CollisionError
+	// CollisionSuffix stores the file under the first free name with a numeric
+	// suffix, e.g. "photo-1.png".
+	CollisionSuffix
+)
+
+// placeFile moves the complete file at tmpPath to name in uploadDir according to
+// the CollisionPolicy of the Tools struct, and returns the name it was stored
+// under. Existing files are never replaced unless the policy is CollisionOverwrite.
+func (t *Tools) placeFile(tmpPath, uploadDir, name string) (string, error) {
+	var taken map[string]bool
+	if t.CaseInsensitiveNames && !caseInsensitiveFS && t.CollisionPolicy != CollisionOverwrite {
+		var err error
+		if taken, err = foldedNames(uploadDir); err != nil {
+			return "", err
+		}
+	}
+
+	switch t.CollisionPolicy {
+	case CollisionError:
+		if err := linkNewFold(tmpPath, uploadDir, name, taken); err != nil {
+			return "", fmt.Errorf("file %q: %w", name, err)
+		}
+		return name, nil
+	case CollisionSuffix:
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		candidate := name
+		for i := 1; i <= maxCollisionSuffix; i++ {
+			err := linkNewFold(tmpPath, uploadDir, candidate, taken)
+			if !errors.Is(err, os.ErrExist) {
+				return candidate, err
+			}
+			candidate = fmt.Sprintf("%s-%d%s", base, i, ext)
+		}
+		return "", fmt.Errorf("file %q: no free name found: %w", name, os.ErrExist)
+	default:
+		return name, os.Rename(tmpPath, filepath.Join(uploadDir, name))
+	}
+}
+
+// foldedNames returns the lowercased names of the entries of dir, to detect
+// names differing only in case on case-sensitive file systems.
+func foldedNames(dir string) (map[string]bool, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		names[strings.ToLower(e.Name())] = true
+	}
+	return names, nil
+}
+
+// linkNewFold is like linkNew for the file name in dir, but fails with
+// os.ErrExist if taken, the lowercased names of dir if not nil, holds name
+// in any case.
+func linkNewFold(oldPath, dir, name string, taken map[string]bool) error {
+	if taken[strings.ToLower(name)] {
+		return os.ErrExist
+	}
+	return linkNew(oldPath, filepath.Join(dir, name))
+}
+
+// linkNew links the file at oldPath to newPath, failing if newPath exists, then
+// removes oldPath. Unlike a check followed by a rename, this cannot replace a
+// file created concurrently. On file systems without hard links, such as FAT
+// volumes, newPath is reserved by creating it exclusively, then replaced by
+// the file at oldPath.
+func linkNew(oldPath, newPath string) error {
+	err := os.Link(oldPath, newPath)
+	if errors.Is(err, os.ErrExist) {
+		return os.ErrExist
+	}
+	if err != nil {
+		placeholder, cerr := os.OpenFile(newPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(cerr, os.ErrExist) {
+			return os.ErrExist
+		}
+		if cerr != nil {
+			return err
+		}
+		placeholder.Close()
+		if err := os.Rename(oldPath, newPath); err != nil {
+			os.Remove(newPath)
+			return err
+		}
+		return nil
+	}
+	return os.Remove(oldPath)
+}
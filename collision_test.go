@@ -0,0 +1,87 @@
+package gorigumi
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// collisionTests is a slice of structs that hold the name of the test, the collision
+// policy, the expected name of the second upload, the expected content of the first
+// name, and a boolean that indicates if an error wrapping os.ErrExist is expected
+var collisionTests = []struct {
+	name        string
+	policy      CollisionPolicy
+	storedAs    string
+	content     string
+	errExpected bool
+}{
+	{"overwrite", CollisionOverwrite, "a.txt", "second", false},
+	{"error", CollisionError, "", "first", true},
+	{"suffix", CollisionSuffix, "a-2.txt", "first", false},
+}
+
+// TestTools_CollisionPolicy tests that files uploaded without renaming are stored
+// according to the collision policy when their name is taken.
+func TestTools_CollisionPolicy(t *testing.T) {
+	for _, ct := range collisionTests {
+		for _, stream := range []bool{false, true} {
+			uploadDir := t.TempDir()
+			os.WriteFile(filepath.Join(uploadDir, "a.txt"), []byte("first"), 0644)
+			os.WriteFile(filepath.Join(uploadDir, "a-1.txt"), []byte("taken"), 0644)
+
+			testTools := New()
+			testTools.AllowedFileTypes = []string{"*"}
+			testTools.StreamUploads = stream
+			testTools.CollisionPolicy = ct.policy
+
+			req := newMultipartRequest(t, map[string][]byte{"a.txt": []byte("second")})
+			files, err := testTools.UploadFiles(req, uploadDir, false)
+
+			if ct.errExpected != errors.Is(err, os.ErrExist) {
+				t.Errorf("%s (stream %t): expected os.ErrExist %t, got %v", ct.name, stream, ct.errExpected, err)
+			}
+			if !ct.errExpected && (len(files) != 1 || files[0].NewFileName != ct.storedAs) {
+				t.Errorf("%s (stream %t): expected file stored as %s, got %v", ct.name, stream, ct.storedAs, files)
+			}
+			if data, _ := os.ReadFile(filepath.Join(uploadDir, "a.txt")); string(data) != ct.content {
+				t.Errorf("%s (stream %t): expected a.txt to hold %q, got %q", ct.name, stream, ct.content, data)
+			}
+
+			entries, _ := os.ReadDir(uploadDir)
+			for _, e := range entries {
+				if filepath.Ext(e.Name()) != ".txt" {
+					t.Errorf("%s (stream %t): unexpected file %s left behind", ct.name, stream, e.Name())
+				}
+			}
+		}
+	}
+}
+
+// TestTools_CaseInsensitiveNames tests that names differing only in case collide
+// when CaseInsensitiveNames is set.
+func TestTools_CaseInsensitiveNames(t *testing.T) {
+	uploadDir := t.TempDir()
+	os.WriteFile(filepath.Join(uploadDir, "Photo.txt"), []byte("first"), 0644)
+
+	testTools := New()
+	testTools.AllowedFileTypes = []string{"*"}
+	testTools.CollisionPolicy = CollisionSuffix
+	testTools.CaseInsensitiveNames = true
+
+	req := newMultipartRequest(t, map[string][]byte{"photo.txt": []byte("second")})
+	files, err := testTools.UploadFiles(req, uploadDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].NewFileName != "photo-1.txt" {
+		t.Errorf("expected the file to be stored as photo-1.txt, got %v", files)
+	}
+
+	testTools.CollisionPolicy = CollisionError
+	req = newMultipartRequest(t, map[string][]byte{"PHOTO.TXT": []byte("third")})
+	if _, err := testTools.UploadFiles(req, uploadDir, false); !errors.Is(err, os.ErrExist) {
+		t.Errorf("expected os.ErrExist, got %v", err)
+	}
+}
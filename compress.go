@@ -0,0 +1,306 @@
+package gorigumi
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// defaultCompressMinSize is the default size from which responses are compressed
+	// it is inlcuded in the CompressMiddleware method
+	defaultCompressMinSize int = 1024
+)
+
+// defaultIncompressibleTypes are the media types never compressed by default,
+// as they are already compressed. Entries ending with '/' match a whole type.
+var defaultIncompressibleTypes = []string{
+	"image/", "video/", "audio/", "font/woff", "font/woff2",
+	"application/zip", "application/gzip", "application/x-gzip", "application/x-bzip2",
+	"application/x-7z-compressed", "application/x-rar-compressed", "application/x-xz",
+	"application/zstd", "application/pdf", "application/octet-stream",
+}
+
+// CompressEncoder is a content coding CompressMiddleware may use.
+type CompressEncoder struct {
+	// Name is the token of the coding in the Accept-Encoding and
+	// Content-Encoding headers, e.g. "gzip" or "br"
+	Name string
+	// NewWriter returns a writer compressing to w. Calling Close on it must
+	// flush the compressed data, and if it has a Flush() error method, it is
+	// called when the handler flushes the response
+	NewWriter func(w io.Writer) io.WriteCloser
+}
+
+// CompressOptions configures CompressMiddleware.
+type CompressOptions struct {
+	// MinSize is the size in bytes from which responses are compressed.
+	// Smaller responses are sent as they are. Default to 1KB
+	MinSize int
+	// Level is the gzip compression level of the default encoder.
+	// Default to gzip.DefaultCompression
+	Level int
+	// Encoders is the list of codings offered, by order of preference when the
+	// client accepts several with the same quality. Brotli can be offered by
+	// adding an encoder backed by a brotli package. Default to gzip
+	Encoders []CompressEncoder
+	// SkipTypes is the list of media types sent uncompressed. Entries
+	// ending with '/' match a whole type, e.g. "image/". Default to images,
+	// videos, audio, fonts and archives
+	SkipTypes []string
+}
+
+// CompressMiddleware returns a middleware compressing responses for clients
+// accepting it, e.g. the output of JSONWrite and DownloadFile. The coding is
+// negotiated from the Accept-Encoding header, and responses smaller than
+// MinSize, already encoded, partial (206) or of a skipped media type are sent
+// as they are. Compressed responses carry a Content-Encoding header, lose
+// their Content-Length, and their ETag is made weak, as the bytes differ from
+// the uncompressed ones. Every response carries Vary: Accept-Encoding, so
+// caches keep both versions apart.
+func (t *Tools) CompressMiddleware(next http.Handler, opts ...CompressOptions) http.Handler {
+	var options CompressOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	if options.MinSize <= 0 {
+		options.MinSize = defaultCompressMinSize
+	}
+	if options.Level == 0 {
+		options.Level = gzip.DefaultCompression
+	}
+	if options.Encoders == nil {
+		level := options.Level
+		options.Encoders = []CompressEncoder{{
+			Name: "gzip",
+			NewWriter: func(w io.Writer) io.WriteCloser {
+				gz, err := gzip.NewWriterLevel(w, level)
+				if err != nil {
+					gz = gzip.NewWriter(w)
+				}
+				return gz
+			},
+		}}
+	}
+	if options.SkipTypes == nil {
+		options.SkipTypes = defaultIncompressibleTypes
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoder, ok := negotiateEncoding(r.Header.Get("Accept-Encoding"), options.Encoders)
+		if !ok || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, options: &options, encoder: encoder, status: http.StatusOK}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// negotiateEncoding returns the encoder of encoders with the highest quality
+// in the Accept-Encoding header, the first one on ties. ok is false if the
+// client accepts none of them.
+func negotiateEncoding(accept string, encoders []CompressEncoder) (encoder CompressEncoder, ok bool) {
+	best := 0.0
+	for _, e := range encoders {
+		if q := acceptQuality(accept, e.Name); q > best {
+			encoder, best, ok = e, q, true
+		}
+	}
+	return encoder, ok
+}
+
+// acceptQuality returns the quality given to the coding name by the
+// Accept-Encoding header, falling back to the quality of "*". Codings not
+// listed have a quality of 0.
+func acceptQuality(accept, name string) float64 {
+	q, wildcard := -1.0, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.TrimSpace(coding)
+
+		quality := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				quality = f
+			}
+		}
+
+		switch {
+		case strings.EqualFold(coding, name):
+			q = quality
+		case coding == "*":
+			wildcard = quality
+		}
+	}
+	if q < 0 {
+		return wildcard
+	}
+	return q
+}
+
+// compressWriter is a http.ResponseWriter buffering the body until MinSize
+// bytes are written, then compressing it if the response allows it.
+type compressWriter struct {
+	http.ResponseWriter
+	options     *CompressOptions
+	encoder     CompressEncoder
+	status      int
+	wroteHeader bool
+	started     bool
+	buf         []byte
+	enc         io.WriteCloser
+}
+
+// WriteHeader records the status code sent once the coding is decided.
+// Informational statuses are forwarded right away.
+func (c *compressWriter) WriteHeader(status int) {
+	if c.started || c.wroteHeader {
+		return
+	}
+	if status < 200 {
+		c.ResponseWriter.WriteHeader(status)
+		return
+	}
+	c.status, c.wroteHeader = status, true
+}
+
+// Write buffers p until MinSize bytes are written, then sends the headers and
+// the body, compressed or not.
+func (c *compressWriter) Write(p []byte) (int, error) {
+	c.WriteHeader(http.StatusOK)
+
+	if !c.started {
+		if !c.compressible() {
+			if err := c.start(false); err != nil {
+				return 0, err
+			}
+		} else {
+			c.buf = append(c.buf, p...)
+			if len(c.buf) < c.options.MinSize {
+				return len(p), nil
+			}
+			if err := c.start(true); err != nil {
+				return 0, err
+			}
+			return len(p), nil
+		}
+	}
+
+	if c.enc != nil {
+		return c.enc.Write(p)
+	}
+	return c.ResponseWriter.Write(p)
+}
+
+// compressible reports whether the status and headers set so far allow the
+// response to be compressed.
+func (c *compressWriter) compressible() bool {
+	header := c.Header()
+	if !bodyAllowedForStatus(c.status) || c.status == http.StatusPartialContent {
+		return false
+	}
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" {
+		return false
+	}
+	if n, err := strconv.Atoi(header.Get("Content-Length")); err == nil && n < c.options.MinSize {
+		return false
+	}
+	if ct := header.Get("Content-Type"); ct != "" && c.skipType(ct) {
+		return false
+	}
+	return true
+}
+
+// skipType reports whether the media type of contentType is in SkipTypes.
+func (c *compressWriter) skipType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range c.options.SkipTypes {
+		if (strings.HasSuffix(t, "/") && strings.HasPrefix(mediaType, t)) || mediaType == t {
+			return true
+		}
+	}
+	return false
+}
+
+// start sends the headers, compressing the body if compress is set and the
+// sniffed content type allows it, and writes the buffered body.
+func (c *compressWriter) start(compress bool) error {
+	c.started = true
+
+	header := c.Header()
+	if compress && len(c.buf) > 0 && header.Get("Content-Type") == "" {
+		header.Set("Content-Type", http.DetectContentType(c.buf))
+	}
+	if compress && c.skipType(header.Get("Content-Type")) {
+		compress = false
+	}
+
+	if compress {
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", c.encoder.Name)
+		if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set("ETag", "W/"+etag)
+		}
+		c.enc = c.encoder.NewWriter(c.ResponseWriter)
+	}
+	c.ResponseWriter.WriteHeader(c.status)
+
+	buf := c.buf
+	c.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if c.enc != nil {
+		_, err = c.enc.Write(buf)
+	} else {
+		_, err = c.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// Flush sends the buffered body, compressed if the response allows it, and
+// flushes the encoder and the underlying http.ResponseWriter.
+func (c *compressWriter) Flush() {
+	if !c.started && c.wroteHeader {
+		c.start(c.compressible())
+	}
+	if f, ok := c.enc.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	http.NewResponseController(c.ResponseWriter).Flush()
+}
+
+// close sends a response still buffered, uncompressed as it is smaller than
+// MinSize, and finishes the compressed stream.
+func (c *compressWriter) close() {
+	if !c.started && c.wroteHeader {
+		c.start(false)
+	}
+	if c.enc != nil {
+		c.enc.Close()
+	}
+}
+
+// Unwrap returns the underlying http.ResponseWriter, so http.ResponseController
+// can reach its optional interfaces.
+func (c *compressWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// bodyAllowedForStatus reports whether a response with the given status may
+// have a body, and so a Content-Length.
+func bodyAllowedForStatus(status int) bool {
+	return status >= 200 && status != http.StatusNoContent && status != http.StatusNotModified
+}
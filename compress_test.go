@@ -0,0 +1,174 @@
+package gorigumi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// compressTests is a slice of structs that hold the name of the test, the path and
+// Accept-Encoding header of the request, and the expected Content-Encoding
+var compressTests = []struct {
+	name     string
+	path     string
+	accept   string
+	encoding string
+}{
+	{"json gzipped", "/json", "gzip, deflate, br", "gzip"},
+	{"no accept encoding", "/json", "", ""},
+	{"gzip refused", "/json", "gzip;q=0, br", ""},
+	{"wildcard", "/json", "*", "gzip"},
+	{"small json", "/small", "gzip", ""},
+	{"text download gzipped", "/download", "gzip", "gzip"},
+	{"image skipped", "/image", "gzip", ""},
+	{"already encoded", "/encoded", "gzip", "br"},
+}
+
+// TestTools_CompressMiddleware tests the negotiation and the headers of compressed
+// responses, and that their bodies decompress to the original ones.
+func TestTools_CompressMiddleware(t *testing.T) {
+	testTools := New()
+
+	dir := t.TempDir()
+	text := []byte(strings.Repeat("a line of text\n", 500))
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), text, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	items := make([]string, 200)
+	for i := range items {
+		items[i] = "item"
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/json", func(w http.ResponseWriter, r *http.Request) {
+		testTools.JSONWrite(w, http.StatusOK, items)
+	})
+	mux.HandleFunc("/small", func(w http.ResponseWriter, r *http.Request) {
+		testTools.JSONWrite(w, http.StatusOK, map[string]string{"message": "hi"})
+	})
+	mux.HandleFunc("/download", func(w http.ResponseWriter, r *http.Request) {
+		testTools.DownloadFile(w, r, dir, "notes.txt", "notes.txt")
+	})
+	mux.HandleFunc("/image", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(text)
+	})
+	mux.HandleFunc("/encoded", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "br")
+		w.Write(text)
+	})
+	handler := testTools.CompressMiddleware(mux)
+
+	for _, ct := range compressTests {
+		req := httptest.NewRequest("GET", ct.path, nil)
+		if ct.accept != "" {
+			req.Header.Set("Accept-Encoding", ct.accept)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d", ct.name, rr.Code)
+			continue
+		}
+		if enc := rr.Header().Get("Content-Encoding"); enc != ct.encoding {
+			t.Errorf("%s: expected Content-Encoding %q, got %q", ct.name, ct.encoding, enc)
+		}
+		if rr.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("%s: expected Vary: Accept-Encoding, got %q", ct.name, rr.Header().Get("Vary"))
+		}
+		if ct.encoding != "gzip" {
+			continue
+		}
+
+		if cl := rr.Header().Get("Content-Length"); cl != "" {
+			t.Errorf("%s: expected no Content-Length, got %s", ct.name, cl)
+		}
+		gz, err := gzip.NewReader(rr.Body)
+		if err != nil {
+			t.Errorf("%s: invalid gzip body: %v", ct.name, err)
+			continue
+		}
+		body, err := io.ReadAll(gz)
+		if err != nil {
+			t.Errorf("%s: invalid gzip body: %v", ct.name, err)
+		}
+		if ct.path == "/download" && !bytes.Equal(body, text) {
+			t.Errorf("%s: decompressed body differs from the file", ct.name)
+		}
+		if ct.path == "/json" && !bytes.HasPrefix(body, []byte(`["item",`)) {
+			t.Errorf("%s: unexpected decompressed body %.20q", ct.name, body)
+		}
+	}
+}
+
+// TestTools_CompressMiddlewareRange tests that partial responses are not compressed and
+// that the ETag of compressed downloads is weak but still answers conditional requests.
+func TestTools_CompressMiddlewareRange(t *testing.T) {
+	testTools := New()
+
+	dir := t.TempDir()
+	text := []byte(strings.Repeat("0123456789", 300))
+	if err := os.WriteFile(filepath.Join(dir, "digits.txt"), text, 0644); err != nil {
+		t.Fatal(err)
+	}
+	handler := testTools.CompressMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		testTools.DownloadFile(w, r, dir, "digits.txt", "digits.txt")
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("Range", "bytes=0-9")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusPartialContent || rr.Header().Get("Content-Encoding") != "" || rr.Body.String() != "0123456789" {
+		t.Errorf("expected an uncompressed 206, got %d %q %q", rr.Code, rr.Header().Get("Content-Encoding"), rr.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	etag := rr.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("expected a weak ETag, got %q", etag)
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("expected status 304, got %d", rr.Code)
+	}
+}
+
+// TestAcceptQuality tests the parsing of Accept-Encoding headers.
+func TestAcceptQuality(t *testing.T) {
+	var qualityTests = []struct {
+		accept   string
+		name     string
+		expected float64
+	}{
+		{"gzip", "gzip", 1},
+		{"GZIP;q=0.5", "gzip", 0.5},
+		{"br, *;q=0.2", "gzip", 0.2},
+		{"br", "gzip", 0},
+		{"*, gzip;q=0", "gzip", 0},
+		{"", "gzip", 0},
+	}
+
+	for _, qt := range qualityTests {
+		if q := acceptQuality(qt.accept, qt.name); q != qt.expected {
+			t.Errorf("%q: expected quality %v for %s, got %v", qt.accept, qt.expected, qt.name, q)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package gorigumi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/drunkleen/gorigumi/download"
+)
+
+const (
+	// defaultRequestTimeout is the default deadline of requests
+	// it is inlcuded in the ContextMiddleware method
+	defaultRequestTimeout time.Duration = 30 * time.Second
+)
+
+// UploadFilesCtx is like UploadFiles, but stops reading the request and storing
+// files once ctx is done, e.g. when the client disconnected or the server shuts
+// down, and returns ctx.Err(). The file being stored is removed; the files
+// stored before are returned with the error.
+func (t *Tools) UploadFilesCtx(ctx context.Context, r *http.Request, uploadDir string, rename ...bool) ([]*UploadedFile, error) {
+	renameFile := true
+	if len(rename) > 0 {
+		renameFile = rename[0]
+	}
+
+	result, err := t.uploadForm(ctx, r, uploadDir, renameFile, fileFilter{})
+	if ctx.Err() != nil {
+		return result.Files, ctx.Err()
+	}
+	return result.Files, err
+}
+
+// UploadFileCtx is like UploadFile, but stops reading the request and storing
+// the file once ctx is done, and returns ctx.Err(). The partial file is removed.
+func (t *Tools) UploadFileCtx(ctx context.Context, r *http.Request, uploadDir string, rename ...bool) (*UploadedFile, error) {
+	renameFile := true
+	if len(rename) > 0 {
+		renameFile = rename[0]
+	}
+
+	uploadedFile, err := t.uploadFile(ctx, r, uploadDir, renameFile)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return uploadedFile, err
+}
+
+// DownloadFileCtx is like DownloadFile, but stops sending the file once ctx is
+// done and returns ctx.Err() in that case. Rejected paths are answered with 404
+// Not Found and return nil.
+func (t *Tools) DownloadFileCtx(
+	ctx context.Context, w http.ResponseWriter, r *http.Request,
+	path, fileName, name string,
+) error {
+	filePath, err := t.downloadPath(path, fileName)
+	if err != nil {
+		_ = t.JSONError(w, errors.New("not found"), http.StatusNotFound)
+		return nil
+	}
+	return download.FileContext(ctx, w, r, filepath.Dir(filePath), filepath.Base(filePath), name, t.DefaultDisposition)
+}
+
+// ContextOptions configures ContextMiddleware.
+type ContextOptions struct {
+	// Timeout is the deadline given to requests, from their arrival. Requests
+	// whose context already has an earlier deadline keep it. Default to 30s
+	Timeout time.Duration
+}
+
+// ContextMiddleware returns a middleware giving the context of every request a
+// deadline, so the work done for it with the context-aware methods, such as
+// UploadFilesCtx, DownloadFileCtx, UploadFilesToS3, JSONPushToRemoteCtx or the
+// quota checks of uploads, is canceled once the request took too long, the
+// client went away or the server shuts down with http.Server.Shutdown.
+func (t *Tools) ContextMiddleware(next http.Handler, opts ...ContextOptions) http.Handler {
+	var options ContextOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = defaultRequestTimeout
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), options.Timeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// contextReader is a reader failing reads once ctx is done. A read already
+// waiting for data is not interrupted.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// Read reads from the wrapped reader unless ctx is done.
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// withBodyContext makes reads of the body of r fail once ctx is done. Contexts
+// that are never done, such as context.Background(), leave the body untouched.
+func withBodyContext(ctx context.Context, r *http.Request) {
+	if ctx.Done() == nil {
+		return
+	}
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{&contextReader{ctx: ctx, r: r.Body}, r.Body}
+}
@@ -0,0 +1,191 @@
+package gorigumi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/drunkleen/gorigumi/toolkittest"
+)
+
+// cancelReader reads from r and calls cancel once after bytes were read, like a client
+// disconnecting mid-upload.
+type cancelReader struct {
+	r      io.Reader
+	after  int
+	cancel context.CancelFunc
+}
+
+func (c *cancelReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p[:min(len(p), 1024)])
+	c.after -= n
+	if c.after <= 0 {
+		c.cancel()
+	}
+	return n, err
+}
+
+// TestTools_UploadFilesCtx tests that uploads stop once the context is cancelled and
+// that no partial file is left behind.
+func TestTools_UploadFilesCtx(t *testing.T) {
+	data := toolkittest.GenerateFile(64*1024, []byte("some text "))
+
+	for _, stream := range []bool{false, true} {
+		uploadDir := t.TempDir()
+		testTools := New()
+		testTools.AllowedFileTypes = []string{"*"}
+		testTools.StreamUploads = stream
+
+		ctx, cancel := context.WithCancel(context.Background())
+		body, contentType := newMultipartBody(t, map[string][]byte{"a.txt": data})
+		req := httptest.NewRequest("POST", "/", &cancelReader{r: bytes.NewReader(body), after: 8 * 1024, cancel: cancel})
+		req.Header.Set("Content-Type", contentType)
+
+		files, err := testTools.UploadFilesCtx(ctx, req, uploadDir)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("stream %t: expected context.Canceled, got %v", stream, err)
+		}
+		if len(files) != 0 {
+			t.Errorf("stream %t: expected no files, got %v", stream, files)
+		}
+		if entries, _ := os.ReadDir(uploadDir); len(entries) != 0 {
+			t.Errorf("stream %t: expected no files left behind, got %d", stream, len(entries))
+		}
+	}
+
+	// a context that is never cancelled does not change the upload
+	testTools := New()
+	testTools.AllowedFileTypes = []string{"*"}
+	file, err := testTools.UploadFileCtx(context.Background(), newMultipartRequest(t, map[string][]byte{"a.txt": data}), t.TempDir())
+	if err != nil || file.FileSize != int64(len(data)) {
+		t.Errorf("expected the file to be uploaded, got %v, %v", file, err)
+	}
+}
+
+// TestTools_UploadFiles_requestContext tests that UploadFiles, UploadFilesFromField
+// and UploadFile stop once the context of the request is done.
+func TestTools_UploadFiles_requestContext(t *testing.T) {
+	data := toolkittest.GenerateFile(64*1024, []byte("some text "))
+	testTools := New()
+	testTools.AllowedFileTypes = []string{"*"}
+
+	var uploadTests = []struct {
+		name   string
+		upload func(r *http.Request, dir string) error
+	}{
+		{"files", func(r *http.Request, dir string) error { _, err := testTools.UploadFiles(r, dir); return err }},
+		{"field", func(r *http.Request, dir string) error {
+			_, err := testTools.UploadFilesFromField(r, "file", dir)
+			return err
+		}},
+		{"file", func(r *http.Request, dir string) error { _, err := testTools.UploadFile(r, dir); return err }},
+	}
+
+	for _, ut := range uploadTests {
+		for _, stream := range []bool{false, true} {
+			uploadDir := t.TempDir()
+			testTools.StreamUploads = stream
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+			body, contentType := newMultipartBody(t, map[string][]byte{"a.txt": data})
+			req := httptest.NewRequestWithContext(ctx, "POST", "/", &cancelReader{r: bytes.NewReader(body), after: 8 * 1024, cancel: cancel})
+			req.Header.Set("Content-Type", contentType)
+
+			if err := ut.upload(req, uploadDir); !errors.Is(err, context.Canceled) {
+				t.Errorf("%s (stream %t): expected context.Canceled, got %v", ut.name, stream, err)
+			}
+			if entries, _ := os.ReadDir(uploadDir); len(entries) != 0 {
+				t.Errorf("%s (stream %t): expected no files left behind, got %d", ut.name, stream, len(entries))
+			}
+		}
+	}
+}
+
+// TestTools_ContextMiddleware tests the deadline given to requests.
+func TestTools_ContextMiddleware(t *testing.T) {
+	testTools := New()
+
+	var deadline time.Time
+	handler := testTools.ContextMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, _ = r.Context().Deadline()
+	}), ContextOptions{Timeout: time.Minute})
+
+	start := time.Now()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if deadline.Before(start.Add(time.Minute)) || deadline.After(time.Now().Add(time.Minute)) {
+		t.Errorf("expected a deadline a minute away, got %v", deadline.Sub(start))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	expected, _ := ctx.Deadline()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+	if !deadline.Equal(expected) {
+		t.Errorf("expected the earlier deadline to be kept, got %v", deadline.Sub(expected))
+	}
+}
+
+// TestTools_JSONPushToRemoteCtx tests that pushes are canceled with their context.
+func TestTools_JSONPushToRemoteCtx(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, _, err := New().JSONPushToRemoteCtx(ctx, srv.URL, map[string]string{"a": "b"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a deadline error, got %v", err)
+	}
+}
+
+// TestTools_RemoteTimeout tests that pushes to remotes that stop responding time out
+// after RemoteTimeout, and that timed out attempts are retried.
+func TestTools_RemoteTimeout(t *testing.T) {
+	release := make(chan struct{})
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 2 {
+			return
+		}
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	testTools := New()
+	testTools.RemoteTimeout = 50 * time.Millisecond
+
+	if _, _, err := testTools.JSONPushToRemote(srv.URL, map[string]string{"a": "b"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a deadline error, got %v", err)
+	}
+
+	testTools.PushRetry = &RetryPolicy{InitialBackoff: time.Millisecond}
+	if _, status, err := testTools.JSONPushToRemote(srv.URL, map[string]string{"a": "b"}); err != nil || status != http.StatusOK {
+		t.Errorf("expected the second attempt to succeed, got %d (%v)", status, err)
+	}
+
+	attempts.Store(10)
+	_, _, err := testTools.PushMultipartToRemote(context.Background(), srv.URL, map[string]string{"a": "b"}, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a deadline error, got %v", err)
+	}
+}
+
+// TestGetDirUsageCtx tests that walking a directory stops once the context is done.
+func TestGetDirUsageCtx(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := GetDirUsageCtx(ctx, t.TempDir()); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a canceled error, got %v", err)
+	}
+}
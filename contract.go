@@ -0,0 +1,269 @@
+package gorigumi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// defaultMaxContractBodySize is the default maximum size of a recorded body
+// it is inlcuded in the RecordContractsMiddleware method
+const defaultMaxContractBodySize int = 1024 * 1024 // 1MB
+
+// Contract is a recorded request/response pair of a single route.
+type Contract struct {
+	Request  ContractMessage `json:"request"`
+	Response ContractMessage `json:"response"`
+}
+
+// ContractMessage holds the recorded parts of a request or a response.
+// Method and Path are only set for requests, Status only for responses.
+type ContractMessage struct {
+	Method      string `json:"method,omitempty"`
+	Path        string `json:"path,omitempty"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	Body        string `json:"body,omitempty"`
+}
+
+// contractFilesMu serializes the writes of recorded contracts.
+var contractFilesMu sync.Mutex
+
+// RecordContractsMiddleware returns a middleware that captures every request/response
+// pair served by next into a golden file per route inside dir. The file is named after
+// the slug of the method and the path ("get-users.json") and holds a JSON array of
+// distinct Contract values. It is meant for development environments: the recorded
+// files are later replayed by VerifyContracts in tests.
+//
+// Bodies larger than MaxJSONSize (or 1MB if not set) are recorded truncated.
+func (t *Tools) RecordContractsMiddleware(next http.Handler, dir string) http.Handler {
+	maxBytes := defaultMaxContractBodySize
+	if t.MaxJSONSize != 0 {
+		maxBytes = t.MaxJSONSize
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reqBody []byte
+		if r.Body != nil {
+			reqBody, _ = io.ReadAll(io.LimitReader(r.Body, int64(maxBytes)))
+			r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(reqBody), r.Body))
+		}
+
+		rec := &contractRecorder{ResponseWriter: w, limit: maxBytes}
+		next.ServeHTTP(rec, r)
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		contract := Contract{
+			Request: ContractMessage{
+				Method:      r.Method,
+				Path:        r.URL.RequestURI(),
+				ContentType: r.Header.Get("Content-Type"),
+				Body:        string(reqBody),
+			},
+			Response: ContractMessage{
+				Status:      rec.status,
+				ContentType: w.Header().Get("Content-Type"),
+				Body:        rec.body.String(),
+			},
+		}
+
+		// recording must never break the request that is being served
+		_ = t.saveContract(dir, contract)
+	})
+}
+
+// VerifyContracts replays every contract recorded in dir against handler and
+// compares the status code, the content type and the shape of JSON bodies
+// (keys and value types, not values) with the recorded response. It returns
+// all mismatches joined into a single error, or nil if the contracts hold.
+func (t *Tools) VerifyContracts(handler http.Handler, dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no contracts found in %s", dir)
+	}
+
+	var errs []error
+	for _, file := range files {
+		contracts, err := loadContracts(file)
+		if err != nil {
+			return err
+		}
+
+		for _, contract := range contracts {
+			if err := verifyContract(handler, contract); err != nil {
+				errs = append(errs, fmt.Errorf("%s %s: %w", contract.Request.Method, contract.Request.Path, err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// contractFileName returns the golden file name of the route of c.
+func (t *Tools) contractFileName(c Contract) string {
+	path, _, _ := strings.Cut(c.Request.Path, "?")
+	slug, err := t.ConvertToSlug(c.Request.Method + " " + path)
+	if err != nil {
+		slug = strings.ToLower(c.Request.Method)
+	}
+	return slug + ".json"
+}
+
+// saveContract appends c to the golden file of its route unless an identical
+// request has already been recorded.
+func (t *Tools) saveContract(dir string, c Contract) error {
+	contractFilesMu.Lock()
+	defer contractFilesMu.Unlock()
+
+	if err := t.CreateDirIfNotExists(dir); err != nil {
+		return err
+	}
+
+	file := filepath.Join(dir, t.contractFileName(c))
+	contracts, err := loadContracts(file)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	for _, existing := range contracts {
+		if existing.Request == c.Request {
+			return nil
+		}
+	}
+	contracts = append(contracts, c)
+
+	out, err := json.MarshalIndent(contracts, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(file, out, 0644)
+}
+
+// loadContracts reads the contracts stored in a golden file.
+func loadContracts(file string) ([]Contract, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var contracts []Contract
+	if err := json.Unmarshal(data, &contracts); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	return contracts, nil
+}
+
+// verifyContract replays a single contract against handler.
+func verifyContract(handler http.Handler, c Contract) error {
+	req, err := http.NewRequest(c.Request.Method, c.Request.Path, strings.NewReader(c.Request.Body))
+	if err != nil {
+		return err
+	}
+	if c.Request.ContentType != "" {
+		req.Header.Set("Content-Type", c.Request.ContentType)
+	}
+
+	rec := &batchRecorder{header: make(http.Header)}
+	handler.ServeHTTP(rec, req)
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+
+	if rec.status != c.Response.Status {
+		return fmt.Errorf("expected status %d, got %d", c.Response.Status, rec.status)
+	}
+
+	if contentType := rec.header.Get("Content-Type"); contentType != c.Response.ContentType {
+		return fmt.Errorf("expected content type %q, got %q", c.Response.ContentType, contentType)
+	}
+
+	var want, got any
+	if json.Unmarshal([]byte(c.Response.Body), &want) != nil {
+		return nil
+	}
+	if err := json.Unmarshal(rec.body.Bytes(), &got); err != nil {
+		return fmt.Errorf("expected a JSON body: %w", err)
+	}
+
+	if wantShape, gotShape := jsonShape(want), jsonShape(got); !reflect.DeepEqual(wantShape, gotShape) {
+		return fmt.Errorf("expected body shape %v, got %v", wantShape, gotShape)
+	}
+
+	return nil
+}
+
+// jsonShape replaces every value of a decoded JSON document with the name of
+// its type. Lists are reduced to the shape of their first element.
+func jsonShape(v any) any {
+	switch x := v.(type) {
+	case map[string]any:
+		shape := make(map[string]any, len(x))
+		keys := make([]string, 0, len(x))
+		for key := range x {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			shape[key] = jsonShape(x[key])
+		}
+		return shape
+	case []any:
+		if len(x) == 0 {
+			return []any{}
+		}
+		return []any{jsonShape(x[0])}
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return "null"
+	}
+}
+
+// contractRecorder wraps an http.ResponseWriter and keeps a copy of
+// the status code and of the first bytes of the response body.
+type contractRecorder struct {
+	http.ResponseWriter
+	status int
+	limit  int
+	body   bytes.Buffer
+}
+
+// WriteHeader records the status code and forwards it.
+func (c *contractRecorder) WriteHeader(status int) {
+	if c.status == 0 {
+		c.status = status
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the body and forwards it.
+func (c *contractRecorder) Write(p []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	if room := c.limit - c.body.Len(); room > 0 {
+		c.body.Write(p[:min(len(p), room)])
+	}
+	return c.ResponseWriter.Write(p)
+}
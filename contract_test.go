@@ -0,0 +1,63 @@
+package gorigumi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestTools_RecordContracts tests the RecordContractsMiddleware and VerifyContracts methods
+// by recording a few requests into golden files, replaying them against the same handler,
+// and checking that a handler with a changed response shape breaks the contract.
+func TestTools_RecordContracts(t *testing.T) {
+	testTools := New()
+	dir := t.TempDir()
+
+	handler := func(idType string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/users" {
+				testTools.JSONError(w, os.ErrNotExist, http.StatusNotFound)
+				return
+			}
+			var id any = 42
+			if idType == "string" {
+				id = "42"
+			}
+			testTools.JSONWrite(w, http.StatusOK, map[string]any{"id": id, "name": "foo"})
+		})
+	}
+
+	recorder := testTools.RecordContractsMiddleware(handler("number"), dir)
+	for _, path := range []string{"/users", "/users?page=2", "/users", "/missing"} {
+		req := httptest.NewRequest("GET", path, strings.NewReader(""))
+		recorder.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 2 {
+		t.Fatalf("expected 2 contract files, got %d", len(files))
+	}
+
+	contracts, err := loadContracts(filepath.Join(dir, "get-users.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contracts) != 2 {
+		t.Errorf("expected 2 distinct contracts, got %d", len(contracts))
+	}
+
+	if err := testTools.VerifyContracts(handler("number"), dir); err != nil {
+		t.Errorf("expected contracts to hold: %v", err)
+	}
+
+	if err := testTools.VerifyContracts(handler("string"), dir); err == nil {
+		t.Error("expected changed response shape to break the contract")
+	}
+
+	if err := testTools.VerifyContracts(handler("number"), t.TempDir()); err == nil {
+		t.Error("expected error for a directory without contracts")
+	}
+}
@@ -0,0 +1,254 @@
+package gorigumi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// defaultDataTableMaxLength is the default maximum number of rows of a page
+	// it is inlcuded in the ParseDataTableRequest method
+	defaultDataTableMaxLength int = 100
+
+	// maxDataTableColumns is the maximum number of columns and orderings read
+	// from a request
+	maxDataTableColumns int = 100
+)
+
+// DataTableOptions configures ParseDataTableRequest.
+type DataTableOptions struct {
+	// MaxLength is the maximum number of rows of a page, also used when all
+	// rows are requested with a length of -1. Default to 100
+	MaxLength int
+	// Columns, if set, is the list of column names accepted from clients.
+	// It must be set when columns are mapped to database queries
+	Columns []string
+}
+
+// DataTableColumn is a column of a DataTableRequest.
+type DataTableColumn struct {
+	// Data is the field of the rows shown in the column
+	Data       string
+	Name       string
+	Searchable bool
+	Orderable  bool
+	// Search is the value the column is filtered with
+	Search string
+}
+
+// DataTableOrder is a sort order of a DataTableRequest.
+type DataTableOrder struct {
+	// Column is the Data of the sorted column
+	Column string
+	Desc   bool
+}
+
+// DataTableRequest is a request of the server-side processing protocol of grids
+// such as DataTables, asking for a page of rows.
+type DataTableRequest struct {
+	// Draw is a counter echoed in the response
+	Draw int
+	// Start is the index of the first row of the page
+	Start int
+	// Length is the number of rows of the page
+	Length int
+	// Search is the value searched in every searchable column
+	Search  string
+	Columns []DataTableColumn
+	Order   []DataTableOrder
+}
+
+// DataTableResponse is the response to a DataTableRequest.
+type DataTableResponse struct {
+	Draw int `json:"draw"`
+	// RecordsTotal is the number of rows before filtering
+	RecordsTotal int `json:"recordsTotal"`
+	// RecordsFiltered is the number of rows after filtering
+	RecordsFiltered int    `json:"recordsFiltered"`
+	Data            any    `json:"data"`
+	Error           string `json:"error,omitempty"`
+}
+
+// ParseDataTableRequest parses the query string or form of a server-side
+// processing request of grids such as DataTables: draw, start, length,
+// search[value], order[i][column], order[i][dir] and columns[i][data],
+// columns[i][name], columns[i][searchable], columns[i][orderable] and
+// columns[i][search][value]. The length is capped to MaxLength, and only the
+// Columns of the options are accepted if set.
+func (t *Tools) ParseDataTableRequest(r *http.Request, opts ...DataTableOptions) (*DataTableRequest, error) {
+	var options DataTableOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	if options.MaxLength == 0 {
+		options.MaxLength = defaultDataTableMaxLength
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	form := r.Form
+
+	req := &DataTableRequest{Search: form.Get("search[value]")}
+	var err error
+	if req.Draw, err = dataTableInt(form.Get("draw"), 0); err != nil {
+		return nil, fmt.Errorf("draw: %w", err)
+	}
+	if req.Start, err = dataTableInt(form.Get("start"), 0); err != nil || req.Start < 0 {
+		return nil, errors.New("start must be a positive integer")
+	}
+	if req.Length, err = dataTableInt(form.Get("length"), options.MaxLength); err != nil {
+		return nil, fmt.Errorf("length: %w", err)
+	}
+	if req.Length <= 0 || req.Length > options.MaxLength {
+		req.Length = options.MaxLength
+	}
+
+	for i := range maxDataTableColumns {
+		prefix := "columns[" + strconv.Itoa(i) + "]"
+		if !form.Has(prefix + "[data]") {
+			break
+		}
+		column := DataTableColumn{
+			Data:       form.Get(prefix + "[data]"),
+			Name:       form.Get(prefix + "[name]"),
+			Searchable: form.Get(prefix+"[searchable]") != "false",
+			Orderable:  form.Get(prefix+"[orderable]") != "false",
+			Search:     form.Get(prefix + "[search][value]"),
+		}
+		if options.Columns != nil && !slices.Contains(options.Columns, column.Data) {
+			return nil, fmt.Errorf("unknown column %q", column.Data)
+		}
+		req.Columns = append(req.Columns, column)
+	}
+
+	for i := range maxDataTableColumns {
+		prefix := "order[" + strconv.Itoa(i) + "]"
+		if !form.Has(prefix + "[column]") {
+			break
+		}
+		index, err := strconv.Atoi(form.Get(prefix + "[column]"))
+		if err != nil || index < 0 || index >= len(req.Columns) {
+			return nil, fmt.Errorf("order %d: invalid column", i)
+		}
+		if !req.Columns[index].Orderable {
+			return nil, fmt.Errorf("column %q is not orderable", req.Columns[index].Data)
+		}
+		dir := form.Get(prefix + "[dir]")
+		if dir != "" && dir != "asc" && dir != "desc" {
+			return nil, fmt.Errorf("order %d: invalid direction %q", i, dir)
+		}
+		req.Order = append(req.Order, DataTableOrder{Column: req.Columns[index].Data, Desc: dir == "desc"})
+	}
+
+	return req, nil
+}
+
+// dataTableInt parses an integer parameter, or returns def if it is empty.
+func dataTableInt(s string, def int) (int, error) {
+	if s == "" {
+		return def, nil
+	}
+	return strconv.Atoi(s)
+}
+
+// Filter returns the column searches of the request as a QuerySpec filter,
+// each column Data mapped with the "contains" operator to its search value.
+// The global Search is not part of it, as it matches any searchable column.
+func (req *DataTableRequest) Filter() map[string]any {
+	filter := make(map[string]any)
+	for _, column := range req.Columns {
+		if column.Searchable && column.Search != "" {
+			filter[column.Data+":contains"] = column.Search
+		}
+	}
+	return filter
+}
+
+// Apply answers the request from rows held in memory: rows, a JSON-serializable
+// list, is filtered with the column searches and the global search, which
+// match case-insensitively, sorted according to Order and cut to the page.
+func (req *DataTableRequest) Apply(rows any) (*DataTableResponse, error) {
+	buf, err := json.Marshal(rows)
+	if err != nil {
+		return nil, err
+	}
+	var items []any
+	if err := json.Unmarshal(buf, &items); err != nil {
+		return nil, errors.New("rows must be a list")
+	}
+
+	filtered := make([]any, 0, len(items))
+	for _, item := range items {
+		if req.match(item) {
+			filtered = append(filtered, item)
+		}
+	}
+
+	if len(req.Order) > 0 {
+		sort.SliceStable(filtered, func(i, j int) bool {
+			a, _ := filtered[i].(map[string]any)
+			b, _ := filtered[j].(map[string]any)
+			for _, order := range req.Order {
+				c, ok := orderQueryValues(a[order.Column], b[order.Column])
+				if !ok || c == 0 {
+					continue
+				}
+				return (c < 0) != order.Desc
+			}
+			return false
+		})
+	}
+
+	page := filtered[min(req.Start, len(filtered)):]
+	if req.Length > 0 && len(page) > req.Length {
+		page = page[:req.Length]
+	}
+
+	return &DataTableResponse{Draw: req.Draw, RecordsTotal: len(items), RecordsFiltered: len(filtered), Data: page}, nil
+}
+
+// match reports whether item satisfies the column searches and the global
+// search of the request.
+func (req *DataTableRequest) match(item any) bool {
+	obj, ok := item.(map[string]any)
+	if !ok {
+		return false
+	}
+
+	global := req.Search == ""
+	for _, column := range req.Columns {
+		if !column.Searchable {
+			continue
+		}
+		var value string
+		if v, ok := obj[column.Data]; ok && v != nil {
+			value = strings.ToLower(fmt.Sprint(v))
+		}
+		if column.Search != "" && !strings.Contains(value, strings.ToLower(column.Search)) {
+			return false
+		}
+		if !global && strings.Contains(value, strings.ToLower(req.Search)) {
+			global = true
+		}
+	}
+	return global
+}
+
+// DataTableWrite writes a response to req holding a page of rows, with
+// recordsTotal and recordsFiltered, the number of rows before and after
+// filtering, e.g. when the page was loaded from a database.
+func (t *Tools) DataTableWrite(w http.ResponseWriter, req *DataTableRequest, rows any, recordsTotal, recordsFiltered int) error {
+	return t.JSONWrite(w, http.StatusOK, DataTableResponse{
+		Draw:            req.Draw,
+		RecordsTotal:    recordsTotal,
+		RecordsFiltered: recordsFiltered,
+		Data:            rows,
+	})
+}
@@ -0,0 +1,124 @@
+package gorigumi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// dataTableColumns are the query parameters of the columns of the test grid
+const dataTableColumns = "columns[0][data]=name&columns[1][data]=age&columns[1][searchable]=false&columns[2][data]=team&columns[2][orderable]=false"
+
+// dataTableTests is a slice of structs that hold the name of the test, the query string,
+// a boolean that indicates if an error is expected, the expected names of the page and
+// the expected number of filtered rows
+var dataTableTests = []struct {
+	name          string
+	query         string
+	errorExpected bool
+	expected      []string
+	filtered      int
+}{
+	{"first page", "draw=1&start=0&length=2&" + dataTableColumns, false, []string{"alice", "bob"}, 4},
+	{"second page", "draw=2&start=2&length=2&" + dataTableColumns, false, []string{"carol", "dave"}, 4},
+	{"past the end", "start=10&" + dataTableColumns, false, []string{}, 4},
+	{"order desc", "order[0][column]=1&order[0][dir]=desc&" + dataTableColumns, false, []string{"bob", "dave", "carol", "alice"}, 4},
+	{"two orders", "order[0][column]=2&order[0][dir]=asc&" + strings.Replace(dataTableColumns, "&columns[2][orderable]=false", "", 1) + "&order[1][column]=0&order[1][dir]=desc", false, []string{"carol", "alice", "dave", "bob"}, 4},
+	{"global search", "search[value]=CORE&" + dataTableColumns, false, []string{"alice", "carol"}, 2},
+	{"global search skips unsearchable", "search[value]=40&" + dataTableColumns, false, []string{}, 0},
+	{"column search", "columns[0][search][value]=a&order[0][column]=0&order[0][dir]=desc&" + dataTableColumns, false, []string{"dave", "carol", "alice"}, 3},
+	{"all rows", "length=-1&" + dataTableColumns, false, []string{"alice", "bob", "carol", "dave"}, 4},
+	{"negative start", "start=-1&" + dataTableColumns, true, nil, 0},
+	{"invalid draw", "draw=x&" + dataTableColumns, true, nil, 0},
+	{"order out of range", "order[0][column]=5&" + dataTableColumns, true, nil, 0},
+	{"order not orderable", "order[0][column]=2&" + dataTableColumns, true, nil, 0},
+	{"invalid direction", "order[0][column]=0&order[0][dir]=up&" + dataTableColumns, true, nil, 0},
+	{"unknown column", "columns[3][data]=password&" + dataTableColumns, true, nil, 0},
+}
+
+// TestTools_ParseDataTableRequest tests parsing grid requests and answering them with Apply.
+func TestTools_ParseDataTableRequest(t *testing.T) {
+	testTools := New()
+
+	type user struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+		Team string `json:"team"`
+	}
+	users := []user{{"alice", 25, "core"}, {"bob", 40, "web"}, {"carol", 35, "core"}, {"dave", 38, "web"}}
+
+	for _, tt := range dataTableTests {
+		r := httptest.NewRequest("GET", "/users?"+tt.query, nil)
+		req, err := testTools.ParseDataTableRequest(r, DataTableOptions{MaxLength: 10, Columns: []string{"name", "age", "team"}})
+		if tt.errorExpected != (err != nil) {
+			t.Errorf("%s: expected error %t, got %v", tt.name, tt.errorExpected, err)
+		}
+		if err != nil {
+			continue
+		}
+
+		res, err := req.Apply(users)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+
+		names := []string{}
+		for _, row := range res.Data.([]any) {
+			names = append(names, row.(map[string]any)["name"].(string))
+		}
+		if !reflect.DeepEqual(names, tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, names)
+		}
+		if res.RecordsTotal != len(users) || res.RecordsFiltered != tt.filtered {
+			t.Errorf("%s: expected %d/%d records, got %d/%d", tt.name, tt.filtered, len(users), res.RecordsFiltered, res.RecordsTotal)
+		}
+	}
+}
+
+// TestTools_ParseDataTableRequest_form tests that POST forms are parsed and lengths capped.
+func TestTools_ParseDataTableRequest_form(t *testing.T) {
+	testTools := New()
+
+	form := url.Values{"draw": {"3"}, "length": {"500"}, "columns[0][data]": {"name"}, "columns[0][search][value]": {"al"}}
+	r := httptest.NewRequest("POST", "/users", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	req, err := testTools.ParseDataTableRequest(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Draw != 3 || req.Length != defaultDataTableMaxLength {
+		t.Errorf("expected draw 3 and length %d, got %d and %d", defaultDataTableMaxLength, req.Draw, req.Length)
+	}
+	if filter := req.Filter(); !reflect.DeepEqual(filter, map[string]any{"name:contains": "al"}) {
+		t.Errorf("unexpected filter %v", filter)
+	}
+}
+
+// TestTools_DataTableWrite tests the response envelope.
+func TestTools_DataTableWrite(t *testing.T) {
+	testTools := New()
+	rr := httptest.NewRecorder()
+
+	err := testTools.DataTableWrite(rr, &DataTableRequest{Draw: 7}, []string{"a", "b"}, 50, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", rr.Code)
+	}
+
+	var got map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]any{"draw": 7.0, "recordsTotal": 50.0, "recordsFiltered": 2.0, "data": []any{"a", "b"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
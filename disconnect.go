@@ -0,0 +1,95 @@
+package gorigumi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// StatusClientClosedRequest is the non-standard status code, borrowed from
+// nginx, recorded for requests whose client went away before the response
+// was sent. JSONError and RespondError use it for disconnect errors, so access
+// logs and metrics tell them apart from server errors
+const StatusClientClosedRequest int = 499
+
+// ErrClientDisconnected is wrapped by the errors of uploads interrupted because
+// the client went away, e.g. an aborted upload from a mobile app. Partial files
+// are removed, and such errors are not sent to the ErrorReporter.
+var ErrClientDisconnected = errors.New("client disconnected")
+
+// IsClientDisconnect reports whether err was caused by the client going away:
+// ErrClientDisconnected, context.Canceled, the error of the context of a
+// request whose client disconnected, or a broken pipe or connection reset while
+// writing the response.
+func IsClientDisconnect(err error) bool {
+	return errors.Is(err, ErrClientDisconnected) || errors.Is(err, context.Canceled) ||
+		errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// clientGone reports whether the error err, returned while the body of r was
+// read, is due to the client going away: the context of r was canceled by the
+// client, see requestCanceled, or it is not done and err is a disconnect error
+// or an unexpected end of the body. Clients cut off by UploadGuardMiddleware,
+// with ErrUploadTooSlow, by a deadline or by the shutdown of the server did
+// not go away on their own.
+func clientGone(r *http.Request, err error) bool {
+	if err == nil || errors.Is(err, ErrUploadTooSlow) {
+		return false
+	}
+	if r.Context().Err() != nil {
+		return requestCanceled(r)
+	}
+	return IsClientDisconnect(err) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// requestCanceled reports whether the context of r was canceled because its
+// client went away, rather than by a deadline or by the shutdown of the server,
+// according to the cause of its cancellation.
+func requestCanceled(r *http.Request) bool {
+	ctx := r.Context()
+	if !errors.Is(ctx.Err(), context.Canceled) {
+		return false
+	}
+	cause := context.Cause(ctx)
+	return !errors.Is(cause, context.DeadlineExceeded) && !errors.Is(cause, http.ErrServerClosed) &&
+		!errors.Is(cause, ErrUploadTooSlow)
+}
+
+// disconnectError returns err wrapped with ErrClientDisconnected if the client
+// of r went away, see clientGone, or err unchanged.
+func disconnectError(r *http.Request, err error) error {
+	if !clientGone(r, err) || errors.Is(err, ErrClientDisconnected) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrClientDisconnected, err)
+}
+
+// removeStoredFiles removes the files stored by an upload that did not
+// complete, along with their thumbnails and metadata sidecars.
+func (t *Tools) removeStoredFiles(uploadDir string, files ...*UploadedFile) {
+	if t.dryRun {
+		return
+	}
+	for _, file := range files {
+		path := filepath.Join(uploadDir, file.NewFileName)
+		os.Remove(path)
+		os.Remove(sidecarPath(path))
+		for _, thumb := range file.Thumbnails {
+			os.Remove(filepath.Join(uploadDir, thumb))
+		}
+	}
+}
+
+// responseStatus returns the status of the response of r recorded by sw, or
+// StatusClientClosedRequest if the client of r went away before it was sent.
+func responseStatus(sw *statusWriter, r *http.Request) int {
+	if requestCanceled(r) {
+		return StatusClientClosedRequest
+	}
+	return sw.Status()
+}
@@ -0,0 +1,211 @@
+package gorigumi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// isClientDisconnectTests is a slice of structs that hold the name of the test, the
+// error and whether it is a client disconnect
+var isClientDisconnectTests = []struct {
+	name       string
+	err        error
+	disconnect bool
+}{
+	{"nil", nil, false},
+	{"disconnected", fmt.Errorf("upload: %w", ErrClientDisconnected), true},
+	{"canceled", context.Canceled, true},
+	{"broken pipe", &net.OpError{Op: "write", Err: os.NewSyscallError("write", syscall.EPIPE)}, true},
+	{"connection reset", &net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, true},
+	{"deadline", context.DeadlineExceeded, false},
+	{"other", errors.New("disk full"), false},
+}
+
+// TestIsClientDisconnect tests that IsClientDisconnect tells client disconnects
+// from other errors.
+func TestIsClientDisconnect(t *testing.T) {
+	for _, tt := range isClientDisconnectTests {
+		if got := IsClientDisconnect(tt.err); got != tt.disconnect {
+			t.Errorf("%s: expected %t, got %t", tt.name, tt.disconnect, got)
+		}
+	}
+}
+
+// uploadDisconnectTests is a slice of structs that hold the name of the test and
+// whether uploads are streamed
+var uploadDisconnectTests = []struct {
+	name   string
+	stream bool
+}{
+	{"parsed form", false},
+	{"streamed", true},
+}
+
+// TestTools_UploadFiles_disconnect tests over a real connection that an upload
+// aborted by the client fails with ErrClientDisconnected and leaves no file
+// behind, not even the files received before the disconnect.
+func TestTools_UploadFiles_disconnect(t *testing.T) {
+	for _, tt := range uploadDisconnectTests {
+		testTools := New()
+		testTools.AllowedFileTypes = []string{"*"}
+		testTools.StreamUploads = tt.stream
+		dir := t.TempDir()
+
+		uploadErr := make(chan error, 1)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			files, err := testTools.UploadFiles(r, dir)
+			if len(files) > 0 {
+				err = fmt.Errorf("%d files returned: %w", len(files), err)
+			}
+			uploadErr <- err
+		}))
+
+		conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+		// send a complete file, then the beginning of a second one, and go away
+		body := "--x\r\nContent-Disposition: form-data; name=\"file\"; filename=\"a.txt\"\r\n\r\nfirst file\r\n" +
+			"--x\r\nContent-Disposition: form-data; name=\"file\"; filename=\"b.txt\"\r\n\r\nsecond"
+		fmt.Fprintf(conn, "POST / HTTP/1.1\r\nHost: x\r\nContent-Type: multipart/form-data; boundary=x\r\nContent-Length: 100000\r\n\r\n%s", body)
+		time.Sleep(50 * time.Millisecond)
+		conn.Close()
+
+		select {
+		case err := <-uploadErr:
+			if !errors.Is(err, ErrClientDisconnected) {
+				t.Errorf("%s: expected ErrClientDisconnected, got %v", tt.name, err)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("%s: expected the aborted upload to fail", tt.name)
+		}
+		srv.Close()
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			t.Errorf("%s: unexpected file %s left behind", tt.name, e.Name())
+		}
+	}
+}
+
+// uploadDeadlineTests is a slice of structs that hold the name of the test and the
+// function canceling the context of the upload mid-way
+var uploadDeadlineTests = []struct {
+	name   string
+	cancel func(ctx context.Context) (context.Context, context.CancelFunc)
+}{
+	{"deadline", func(ctx context.Context) (context.Context, context.CancelFunc) {
+		return context.WithTimeout(ctx, 50*time.Millisecond)
+	}},
+	{"shutdown", func(ctx context.Context) (context.Context, context.CancelFunc) {
+		ctx, cancel := context.WithCancelCause(ctx)
+		time.AfterFunc(50*time.Millisecond, func() { cancel(http.ErrServerClosed) })
+		return ctx, func() { cancel(nil) }
+	}},
+}
+
+// stalledBody is a request body sending data, then stalling until ctx is done
+// and failing like a body cut off mid-way.
+type stalledBody struct {
+	ctx  context.Context
+	data io.Reader
+}
+
+// Read implements io.Reader.
+func (b *stalledBody) Read(p []byte) (int, error) {
+	if n, err := b.data.Read(p); err != io.EOF {
+		return n, err
+	}
+	<-b.ctx.Done()
+	return 0, io.ErrUnexpectedEOF
+}
+
+// TestTools_UploadFiles_deadline tests that uploads cut off by a deadline or by the
+// shutdown of the server, rather than by their client, are not reported as client
+// disconnects.
+func TestTools_UploadFiles_deadline(t *testing.T) {
+	for _, tt := range uploadDeadlineTests {
+		for _, stream := range []bool{false, true} {
+			testTools := New()
+			testTools.AllowedFileTypes = []string{"*"}
+			testTools.StreamUploads = stream
+
+			ctx, cancel := tt.cancel(context.Background())
+			body := "--x\r\nContent-Disposition: form-data; name=\"file\"; filename=\"a.txt\"\r\n\r\nfirst"
+			req := httptest.NewRequestWithContext(ctx, http.MethodPost, "/upload", &stalledBody{ctx: ctx, data: strings.NewReader(body)})
+			req.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+
+			_, err := testTools.UploadFiles(req, t.TempDir())
+			cancel()
+			if err == nil || errors.Is(err, ErrClientDisconnected) {
+				t.Errorf("%s (stream %t): expected an error other than ErrClientDisconnected, got %v", tt.name, stream, err)
+			}
+
+			sw := &statusWriter{ResponseWriter: httptest.NewRecorder()}
+			sw.WriteHeader(http.StatusServiceUnavailable)
+			if status := responseStatus(sw, req); status != http.StatusServiceUnavailable {
+				t.Errorf("%s (stream %t): expected status %d, got %d", tt.name, stream, http.StatusServiceUnavailable, status)
+			}
+		}
+	}
+}
+
+// TestTools_JSONError_disconnect tests that client disconnects are written with
+// StatusClientClosedRequest and are not reported.
+func TestTools_JSONError_disconnect(t *testing.T) {
+	reporter := &recordingReporter{}
+	testTools := New()
+	testTools.ErrorReporter = reporter
+
+	rr := httptest.NewRecorder()
+	err := fmt.Errorf("%w: %w", ErrClientDisconnected, io.ErrUnexpectedEOF)
+	if werr := testTools.JSONError(rr, err, http.StatusInternalServerError); werr != nil {
+		t.Fatal(werr)
+	}
+
+	if rr.Code != StatusClientClosedRequest {
+		t.Errorf("expected status %d, got %d", StatusClientClosedRequest, rr.Code)
+	}
+	var res JSONResponse
+	if err := json.NewDecoder(rr.Body).Decode(&res); err != nil || res.Code != CodeClientClosedRequest {
+		t.Errorf("expected code %s, got %+v (%v)", CodeClientClosedRequest, res, err)
+	}
+	if reports := reporter.get(); len(reports) != 0 {
+		t.Errorf("expected no report, got %d", len(reports))
+	}
+}
+
+// TestTools_MetricsMiddleware_disconnect tests that requests whose client went
+// away are counted as disconnects rather than errors.
+func TestTools_MetricsMiddleware_disconnect(t *testing.T) {
+	m := NewMetrics()
+	handler := New().MetricsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}), m)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequestWithContext(ctx, http.MethodGet, "/download", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	stats := m.Snapshot()["unmatched"]
+	if stats.Requests != 1 || stats.Errors != 0 || stats.Disconnects != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
@@ -0,0 +1,183 @@
+// Package download serves files to clients as attachments. It is the
+// implementation of gorigumi's DownloadFile and can be imported on its own.
+package download
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Disposition tells the client whether to display a file or to save it.
+type Disposition string
+
+const (
+	// DispositionAttachment asks the client to save the file. It is the
+	// disposition used for an empty Disposition
+	DispositionAttachment Disposition = "attachment"
+	// DispositionInline asks the client to display the file, e.g. to preview
+	// PDFs and images in the browser
+	DispositionInline Disposition = "inline"
+)
+
+// Attachment sends the file fileName found in dir to the client with
+// http.ServeFile. The Content-Disposition header is set so that the client
+// saves the file as name instead of displaying it.
+func Attachment(w http.ResponseWriter, r *http.Request, dir, fileName, name string) {
+	File(w, r, dir, fileName, name, DispositionAttachment)
+}
+
+// File sends the file fileName found in dir to the client with http.ServeFile,
+// with a Content-Disposition header of disposition naming the file name. Unless
+// an ETag header is already set, the file is sent with the ETag returned by
+// FileETag, so If-None-Match requests for unchanged files are answered with
+// 304 Not Modified, as are If-Modified-Since requests.
+func File(w http.ResponseWriter, r *http.Request, dir, fileName, name string, disposition Disposition) {
+	filePath := filepath.Join(dir, fileName)
+	w.Header().Set("Content-Disposition", ContentDisposition(disposition, name))
+	if w.Header().Get("ETag") == "" {
+		if info, err := os.Stat(filePath); err == nil && info.Mode().IsRegular() {
+			w.Header().Set("ETag", FileETag(info))
+		}
+	}
+
+	http.ServeFile(w, r, filePath)
+}
+
+// Content sends content to the client with http.ServeContent, so Range,
+// If-Modified-Since and If-None-Match requests are handled as for files on
+// disk, e.g. to serve files kept in memory, a database or an object storage.
+// The Content-Type is detected from the extension of name, or else from the
+// content. modtime sets the Last-Modified header unless it is zero. The
+// Content-Disposition header is set to disposition, naming the content name.
+//
+// Unless an ETag header is already set, e.g. to the ETag of an object storage,
+// the content is hashed with ContentETag, which reads it entirely. Requests
+// with a matching If-None-Match or If-Modified-Since header are answered with
+// 304 Not Modified.
+func Content(w http.ResponseWriter, r *http.Request, content io.ReadSeeker, name string, modtime time.Time, disposition Disposition) {
+	w.Header().Set("Content-Disposition", ContentDisposition(disposition, name))
+	if w.Header().Get("ETag") == "" {
+		etag, err := ContentETag(content)
+		if err != nil {
+			http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("ETag", etag)
+	}
+
+	http.ServeContent(w, r, name, modtime, content)
+}
+
+// AttachmentContext is like Attachment, but stops sending the file once ctx is
+// done, e.g. on server shutdown, and returns ctx.Err() in that case.
+func AttachmentContext(ctx context.Context, w http.ResponseWriter, r *http.Request, dir, fileName, name string) error {
+	return FileContext(ctx, w, r, dir, fileName, name, DispositionAttachment)
+}
+
+// FileContext is like File, but stops sending the file once ctx is done and
+// returns ctx.Err() in that case.
+func FileContext(ctx context.Context, w http.ResponseWriter, r *http.Request, dir, fileName, name string, disposition Disposition) error {
+	File(&contextWriter{ResponseWriter: w, ctx: ctx}, r, dir, fileName, name, disposition)
+	return ctx.Err()
+}
+
+// FileETag returns a strong ETag for a file, derived from its modification
+// time and size, like common web servers do.
+func FileETag(info fs.FileInfo) string {
+	return `"` + strconv.FormatInt(info.ModTime().UnixNano(), 16) + "-" + strconv.FormatInt(info.Size(), 16) + `"`
+}
+
+// ContentETag returns a strong ETag for content, derived from the SHA-256 hash
+// of its bytes, and seeks content back to its start.
+func ContentETag(content io.ReadSeeker) (string, error) {
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`, nil
+}
+
+// ContentDisposition returns the value of a Content-Disposition header of
+// disposition for a file named name. Non-ASCII names are encoded as a
+// filename* parameter according to RFC 5987, along with a filename parameter
+// where non-ASCII characters are replaced with '_' for older clients.
+func ContentDisposition(disposition Disposition, name string) string {
+	if disposition == "" {
+		disposition = DispositionAttachment
+	}
+
+	var fallback strings.Builder
+	ascii := true
+	for _, r := range name {
+		switch {
+		case r >= 0x80:
+			ascii = false
+			fallback.WriteByte('_')
+		case r < 0x20 || r == 0x7f:
+			fallback.WriteByte('_')
+		case r == '"' || r == '\\':
+			fallback.WriteByte('\\')
+			fallback.WriteRune(r)
+		default:
+			fallback.WriteRune(r)
+		}
+	}
+
+	header := string(disposition) + `; filename="` + fallback.String() + `"`
+	if !ascii {
+		header += "; filename*=UTF-8''" + encodeExtValue(name)
+	}
+	return header
+}
+
+// encodeExtValue percent-encodes s for an RFC 5987 ext-value, keeping only
+// the attr-char characters.
+func encodeExtValue(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := range len(s) {
+		c := s[i]
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') || strings.IndexByte("!#$&+-.^_`|~", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0x0f])
+	}
+	return b.String()
+}
+
+// contextWriter is a http.ResponseWriter failing writes once ctx is done.
+type contextWriter struct {
+	http.ResponseWriter
+	ctx context.Context
+}
+
+// Write writes p unless ctx is done.
+func (w *contextWriter) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap returns the wrapped http.ResponseWriter, for http.ResponseController.
+func (w *contextWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
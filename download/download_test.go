@@ -0,0 +1,217 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestAttachment tests that the file is served with an attachment Content-Disposition.
+func TestAttachment(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a1b2.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	Attachment(rr, httptest.NewRequest("GET", "/", nil), dir, "a1b2.txt", "notes.txt")
+
+	res := rr.Result()
+	defer res.Body.Close()
+
+	if cd := res.Header.Get("Content-Disposition"); cd != `attachment; filename="notes.txt"` {
+		t.Errorf("unexpected Content-Disposition %q", cd)
+	}
+	if body, _ := io.ReadAll(res.Body); string(body) != "hello" {
+		t.Errorf("unexpected body %q", body)
+	}
+}
+
+// TestAttachmentContext tests that nothing is sent once the context is done.
+func TestAttachmentContext(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a1b2.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	err := AttachmentContext(context.Background(), rr, httptest.NewRequest("GET", "/", nil), dir, "a1b2.txt", "notes.txt")
+	if err != nil || rr.Body.String() != "hello" {
+		t.Errorf("expected the file to be sent, got %q, %v", rr.Body, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rr = httptest.NewRecorder()
+	err = AttachmentContext(ctx, rr, httptest.NewRequest("GET", "/", nil), dir, "a1b2.txt", "notes.txt")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if rr.Body.Len() != 0 {
+		t.Errorf("expected nothing to be sent, got %q", rr.Body)
+	}
+}
+
+// contentTests is a slice of structs that hold the name of the test, the request
+// headers, the expected status and the expected body
+var contentTests = []struct {
+	name     string
+	headers  map[string]string
+	status   int
+	expected string
+}{
+	{"whole content", nil, http.StatusOK, "hello world"},
+	{"range", map[string]string{"Range": "bytes=6-"}, http.StatusPartialContent, "world"},
+	{"not modified", map[string]string{"If-Modified-Since": "Mon, 02 Jan 2006 15:04:05 GMT"}, http.StatusNotModified, ""},
+	{"modified", map[string]string{"If-Modified-Since": "Sun, 01 Jan 2006 15:04:05 GMT"}, http.StatusOK, "hello world"},
+}
+
+// TestContent tests that content is served with range and conditional request support.
+func TestContent(t *testing.T) {
+	modtime := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	for _, ct := range contentTests {
+		req := httptest.NewRequest("GET", "/", nil)
+		for k, v := range ct.headers {
+			req.Header.Set(k, v)
+		}
+		rr := httptest.NewRecorder()
+		Content(rr, req, strings.NewReader("hello world"), "notes.txt", modtime, DispositionAttachment)
+
+		if rr.Code != ct.status {
+			t.Errorf("%s: expected status %d, got %d", ct.name, ct.status, rr.Code)
+		}
+		if rr.Body.String() != ct.expected {
+			t.Errorf("%s: expected body %q, got %q", ct.name, ct.expected, rr.Body)
+		}
+		if cd := rr.Header().Get("Content-Disposition"); cd != `attachment; filename="notes.txt"` {
+			t.Errorf("%s: unexpected Content-Disposition %q", ct.name, cd)
+		}
+		if ct.status == http.StatusOK && !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/plain") {
+			t.Errorf("%s: unexpected Content-Type %q", ct.name, rr.Header().Get("Content-Type"))
+		}
+	}
+}
+
+// dispositionTests is a slice of structs that hold the disposition, the file name and
+// the expected Content-Disposition header
+var dispositionTests = []struct {
+	disposition Disposition
+	name        string
+	expected    string
+}{
+	{"", "notes.txt", `attachment; filename="notes.txt"`},
+	{DispositionInline, "report.pdf", `inline; filename="report.pdf"`},
+	{DispositionAttachment, `say "hi".txt`, `attachment; filename="say \"hi\".txt"`},
+	{DispositionAttachment, "a\r\nb.txt", `attachment; filename="a__b.txt"`},
+	{DispositionAttachment, "résumé.pdf", `attachment; filename="r_sum_.pdf"; filename*=UTF-8''r%C3%A9sum%C3%A9.pdf`},
+	{DispositionInline, "日本 語.txt", `inline; filename="__ _.txt"; filename*=UTF-8''%E6%97%A5%E6%9C%AC%20%E8%AA%9E.txt`},
+}
+
+// TestContentDisposition tests the Content-Disposition header values.
+func TestContentDisposition(t *testing.T) {
+	for _, dt := range dispositionTests {
+		if got := ContentDisposition(dt.disposition, dt.name); got != dt.expected {
+			t.Errorf("%q: expected %s, got %s", dt.name, dt.expected, got)
+		}
+	}
+}
+
+// TestFile tests that the file is served inline.
+func TestFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a1b2.pdf"), []byte("%PDF-1.4"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	File(rr, httptest.NewRequest("GET", "/", nil), dir, "a1b2.pdf", "report.pdf", DispositionInline)
+
+	if cd := rr.Header().Get("Content-Disposition"); cd != `inline; filename="report.pdf"` {
+		t.Errorf("unexpected Content-Disposition %q", cd)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/pdf" {
+		t.Errorf("unexpected Content-Type %q", ct)
+	}
+}
+
+// TestFile_conditional tests that unchanged files are answered with 304.
+func TestFile_conditional(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a1b2.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	Attachment(rr, httptest.NewRequest("GET", "/", nil), dir, "a1b2.txt", "notes.txt")
+	etag := rr.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected an ETag")
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	Attachment(rr, req, dir, "a1b2.txt", "notes.txt")
+	if rr.Code != http.StatusNotModified || rr.Body.Len() != 0 {
+		t.Errorf("expected 304 for a matching ETag, got %d %q", rr.Code, rr.Body)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "a1b2.txt"), []byte("hello, world"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	Attachment(rr, req, dir, "a1b2.txt", "notes.txt")
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") == etag {
+		t.Errorf("expected changed files to be sent with a new ETag, got %d %s", rr.Code, rr.Header().Get("ETag"))
+	}
+
+	rr = httptest.NewRecorder()
+	rr.Header().Set("ETag", `"v1"`)
+	Attachment(rr, httptest.NewRequest("GET", "/", nil), dir, "a1b2.txt", "notes.txt")
+	if got := rr.Header().Get("ETag"); got != `"v1"` {
+		t.Errorf("expected the ETag set by the caller to be kept, got %s", got)
+	}
+}
+
+// TestContent_etag tests that content is hashed into an ETag honored by If-None-Match.
+func TestContent_etag(t *testing.T) {
+	content := strings.NewReader("hello world")
+	etag, err := ContentETag(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other, _ := ContentETag(strings.NewReader("hello world!")); other == etag {
+		t.Error("expected different content to have a different ETag")
+	}
+
+	rr := httptest.NewRecorder()
+	Content(rr, httptest.NewRequest("GET", "/", nil), content, "notes.txt", time.Time{}, DispositionAttachment)
+	if rr.Header().Get("ETag") != etag || rr.Body.String() != "hello world" {
+		t.Errorf("expected the content with ETag %s, got %s %q", etag, rr.Header().Get("ETag"), rr.Body)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	Content(rr, req, content, "notes.txt", time.Time{}, DispositionAttachment)
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for a matching ETag, got %d", rr.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("If-None-Match", `"abc"`)
+	rr = httptest.NewRecorder()
+	rr.Header().Set("ETag", `"abc"`)
+	Content(rr, req, content, "notes.txt", time.Time{}, DispositionAttachment)
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("expected the ETag set by the caller to be honored, got %d", rr.Code)
+	}
+}
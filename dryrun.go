@@ -0,0 +1,73 @@
+package gorigumi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// dryRunContextKey is the context key marking dry-run requests.
+type dryRunContextKey struct{}
+
+// WithDryRun returns a copy of ctx in dry-run mode, where mutating helpers
+// validate their input but do not persist or send anything:
+//   - UploadFiles, UploadForm, UploadFile and their variants check the files
+//     and return them as if stored, without writing them, processing images
+//     or writing sidecars
+//   - UploadFilesToS3 checks the files without sending them to the bucket
+//   - PushMultipartToRemote logs the request instead of sending it
+//   - WebhookSender logs the deliveries instead of sending or storing them
+//
+// Uploads observe the mode of the context of the request as well as the
+// context passed to the Ctx variants.
+func WithDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunContextKey{}, true)
+}
+
+// IsDryRun reports whether ctx is in dry-run mode.
+func IsDryRun(ctx context.Context) bool {
+	dryRun, _ := ctx.Value(dryRunContextKey{}).(bool)
+	return dryRun
+}
+
+// DryRunMiddleware returns a middleware putting requests carrying a true
+// X-Dry-Run header, such as "X-Dry-Run: true", in dry-run mode, e.g. to let
+// client integrations be tested against production-like environments. The
+// header is echoed in the response of dry-run requests, and invalid values are
+// answered with 400 Bad Request. Handlers must leave out their own side
+// effects when IsDryRun reports true.
+func (t *Tools) DryRunMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("X-Dry-Run")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		dryRun, err := strconv.ParseBool(header)
+		if err != nil {
+			_ = t.JSONError(w, errors.New("invalid X-Dry-Run header"), http.StatusBadRequest)
+			return
+		}
+		if !dryRun {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("X-Dry-Run", "true")
+		next.ServeHTTP(w, r.WithContext(WithDryRun(r.Context())))
+	})
+}
+
+// dryRunTools returns a copy of the Tools struct whose uploads are not
+// persisted, if ctx or the context of r is in dry-run mode and t is not such
+// a copy already.
+func (t *Tools) dryRunTools(ctx context.Context, r *http.Request) (*Tools, bool) {
+	if t.dryRun || !(IsDryRun(ctx) || IsDryRun(r.Context())) {
+		return t, false
+	}
+	dry := *t
+	dry.dryRun = true
+	return &dry, true
+}
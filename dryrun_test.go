@@ -0,0 +1,130 @@
+package gorigumi
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// dryRunHeaderTests is a slice of structs that hold the X-Dry-Run header, the expected
+// status and a boolean that indicates if the request is expected in dry-run mode
+var dryRunHeaderTests = []struct {
+	header string
+	status int
+	dryRun bool
+}{
+	{"", http.StatusOK, false},
+	{"true", http.StatusOK, true},
+	{"1", http.StatusOK, true},
+	{"false", http.StatusOK, false},
+	{"maybe", http.StatusBadRequest, false},
+}
+
+// TestTools_DryRunMiddleware tests that the X-Dry-Run header puts requests in dry-run mode.
+func TestTools_DryRunMiddleware(t *testing.T) {
+	testTools := New()
+
+	for _, tt := range dryRunHeaderTests {
+		var dryRun bool
+		handler := testTools.DryRunMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			dryRun = IsDryRun(r.Context())
+		}))
+
+		req := httptest.NewRequest("POST", "/", nil)
+		if tt.header != "" {
+			req.Header.Set("X-Dry-Run", tt.header)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.status {
+			t.Errorf("%q: expected status %d, got %d", tt.header, tt.status, rr.Code)
+		}
+		if dryRun != tt.dryRun {
+			t.Errorf("%q: expected dry run %t, got %t", tt.header, tt.dryRun, dryRun)
+		}
+		if echoed := rr.Header().Get("X-Dry-Run") == "true"; echoed != tt.dryRun {
+			t.Errorf("%q: expected the header to be echoed %t", tt.header, tt.dryRun)
+		}
+	}
+}
+
+// TestTools_UploadFiles_dryRun tests that dry-run uploads are checked but not stored.
+func TestTools_UploadFiles_dryRun(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		testTools := &Tools{AllowedFileTypes: []string{"text/plain; charset=utf-8"}, StreamUploads: stream, MetadataSidecars: true}
+		uploadDir := filepath.Join(t.TempDir(), "uploads")
+
+		req := newMultipartRequest(t, map[string][]byte{"a.txt": []byte("some text")})
+		files, err := testTools.UploadFiles(req.WithContext(WithDryRun(req.Context())), uploadDir)
+		if err != nil {
+			t.Fatalf("stream %t: %v", stream, err)
+		}
+		if len(files) != 1 || files[0].FileSize != 9 || files[0].NewFileName == "" {
+			t.Errorf("stream %t: expected the file to be reported, got %+v", stream, files)
+		}
+		if _, err := os.Stat(uploadDir); !os.IsNotExist(err) {
+			t.Errorf("stream %t: expected nothing to be written, got %v", stream, err)
+		}
+
+		req = newMultipartRequest(t, map[string][]byte{"a.png": {0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'}})
+		if _, err := testTools.UploadFilesCtx(WithDryRun(context.Background()), req, uploadDir); err == nil {
+			t.Errorf("stream %t: expected disallowed file types to be rejected", stream)
+		}
+	}
+
+	testTools := &Tools{AllowedFileTypes: []string{"text/plain; charset=utf-8"}}
+	uploadDir := t.TempDir()
+	req := newMultipartRequest(t, map[string][]byte{"a.txt": []byte("some text")})
+	file, err := testTools.UploadFile(req.WithContext(WithDryRun(req.Context())), uploadDir)
+	if err != nil || file == nil {
+		t.Fatalf("expected the file to be reported, got %v, %v", file, err)
+	}
+	if entries, _ := os.ReadDir(uploadDir); len(entries) != 0 {
+		t.Errorf("expected nothing to be written, got %d entries", len(entries))
+	}
+	if testTools.dryRun {
+		t.Error("expected the Tools struct to be left out of dry-run mode")
+	}
+}
+
+// TestTools_UploadFilesToS3_dryRun tests that dry-run uploads are not sent to the bucket.
+func TestTools_UploadFilesToS3_dryRun(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s %s", r.Method, r.URL)
+	}))
+	defer srv.Close()
+
+	testTools := &Tools{AllowedFileTypes: []string{"text/plain; charset=utf-8"}}
+	req := newMultipartRequest(t, map[string][]byte{"a.txt": []byte("some text")})
+	files, err := testTools.UploadFilesToS3(req.WithContext(WithDryRun(req.Context())), S3Options{Endpoint: srv.URL, Bucket: "media"})
+	if err != nil || len(files) != 1 {
+		t.Errorf("expected the file to be reported, got %v, %v", files, err)
+	}
+}
+
+// TestTools_PushMultipartToRemote_dryRun tests that dry-run pushes are logged instead of sent.
+func TestTools_PushMultipartToRemote_dryRun(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s %s", r.Method, r.URL)
+	}))
+	defer srv.Close()
+
+	var logs bytes.Buffer
+	testTools := &Tools{Logger: slog.New(slog.NewTextHandler(&logs, nil))}
+	files := []FilePart{{FileName: "a.txt", Reader: strings.NewReader("some text")}}
+
+	res, status, err := testTools.PushMultipartToRemote(WithDryRun(context.Background()), srv.URL, nil, files)
+	if res != nil || status != 0 || err != nil {
+		t.Errorf("expected nothing to be sent, got %v, %d, %v", res, status, err)
+	}
+	if !strings.Contains(logs.String(), "dry run") {
+		t.Errorf("expected the push to be logged, got %q", logs.String())
+	}
+}
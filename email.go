@@ -0,0 +1,75 @@
+package gorigumi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// emailVerificationPurpose is the purpose of email verification tokens
+const emailVerificationPurpose string = "email-verification"
+
+// NormalizeEmail validates an email address entered by a user and returns it
+// in a canonical form to store and compare: surrounding spaces and display
+// names are removed and the domain is lowercased. The local part is kept as
+// is, since it may be case sensitive.
+func (t *Tools) NormalizeEmail(email string) (string, error) {
+	addr, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil {
+		return "", errors.New("invalid email address")
+	}
+
+	local, domain, ok := strings.Cut(addr.Address, "@")
+	if !ok || local == "" || !strings.Contains(domain, ".") {
+		return "", errors.New("invalid email address")
+	}
+
+	return local + "@" + strings.ToLower(domain), nil
+}
+
+// GenerateEmailVerificationToken returns a signed token proving ownership of
+// email once it is sent back, valid for ttl. Send it to the address, e.g. in a
+// link to an EmailVerificationHandler. TokenSecret must be set.
+func (t *Tools) GenerateEmailVerificationToken(email string, ttl time.Duration) (string, error) {
+	email, err := t.NormalizeEmail(email)
+	if err != nil {
+		return "", err
+	}
+	return t.signToken(emailVerificationPurpose, email, "", ttl)
+}
+
+// VerifyEmailToken checks a token returned by GenerateEmailVerificationToken
+// and returns the verified email address. The error is ErrInvalidToken or
+// ErrTokenExpired for tokens that do not verify.
+func (t *Tools) VerifyEmailToken(token string) (string, error) {
+	email, _, err := t.verifyToken(emailVerificationPurpose, token, "")
+	return email, err
+}
+
+// EmailVerificationHandler returns a handler verifying the token in the token
+// query parameter with VerifyEmailToken and calling markVerified with the
+// verified address. It responds with a JSONResponse: 400 for invalid tokens,
+// 410 for expired ones, 500 if markVerified fails and 200 otherwise.
+func (t *Tools) EmailVerificationHandler(markVerified func(ctx context.Context, email string) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		email, err := t.VerifyEmailToken(r.URL.Query().Get("token"))
+		switch {
+		case errors.Is(err, ErrTokenExpired):
+			_ = t.JSONError(w, err, http.StatusGone)
+			return
+		case err != nil:
+			_ = t.JSONError(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if err := markVerified(r.Context(), email); err != nil {
+			_ = t.JSONError(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		_ = t.JSONWrite(w, http.StatusOK, JSONResponse{Message: "email verified", Data: map[string]string{"email": email}})
+	})
+}
@@ -0,0 +1,96 @@
+package gorigumi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/drunkleen/gorigumi/toolkittest"
+)
+
+// normalizeEmailTests is a slice of structs that hold the entered email address, the
+// expected normalized address, and a boolean that indicates if an error is expected
+var normalizeEmailTests = []struct {
+	email         string
+	expected      string
+	errorExpected bool
+}{
+	{"user@example.com", "user@example.com", false},
+	{"  User@Example.COM ", "User@example.com", false},
+	{"Jo Doe <jo@Example.org>", "jo@example.org", false},
+	{"not an email", "", true},
+	{"user@localhost", "", true},
+	{"", "", true},
+}
+
+// TestTools_NormalizeEmail tests the validation and normalization of email addresses.
+func TestTools_NormalizeEmail(t *testing.T) {
+	testTools := New()
+
+	for _, et := range normalizeEmailTests {
+		got, err := testTools.NormalizeEmail(et.email)
+		if et.errorExpected != (err != nil) {
+			t.Errorf("%q: expected error %t, got %v", et.email, et.errorExpected, err)
+		}
+		if got != et.expected {
+			t.Errorf("%q: expected %q, got %q", et.email, et.expected, got)
+		}
+	}
+}
+
+// TestTools_EmailVerificationHandler tests the verification of an email address through
+// a token sent back to the handler.
+func TestTools_EmailVerificationHandler(t *testing.T) {
+	clock := toolkittest.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	testTools := New()
+	testTools.Clock = clock
+	testTools.TokenSecret = []byte("secret")
+
+	var verified []string
+	handler := testTools.EmailVerificationHandler(func(ctx context.Context, email string) error {
+		if email == "broken@example.com" {
+			return errors.New("database is down")
+		}
+		verified = append(verified, email)
+		return nil
+	})
+
+	send := func(token string) int {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", "/verify?token="+url.QueryEscape(token), nil))
+		return rr.Code
+	}
+
+	token, err := testTools.GenerateEmailVerificationToken(" User@Example.COM", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := send(token); code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", code)
+	}
+	if len(verified) != 1 || verified[0] != "User@example.com" {
+		t.Errorf("expected the normalized address to be verified, got %v", verified)
+	}
+
+	if code := send(token + "x"); code != http.StatusBadRequest {
+		t.Errorf("expected invalid token to be rejected, got %d", code)
+	}
+
+	broken, _ := testTools.GenerateEmailVerificationToken("broken@example.com", time.Hour)
+	if code := send(broken); code != http.StatusInternalServerError {
+		t.Errorf("expected failing markVerified to be reported, got %d", code)
+	}
+
+	clock.Advance(2 * time.Hour)
+	if code := send(token); code != http.StatusGone {
+		t.Errorf("expected expired token to be rejected, got %d", code)
+	}
+
+	if _, err := testTools.GenerateEmailVerificationToken("nope", time.Hour); err == nil {
+		t.Error("expected invalid address to be rejected")
+	}
+}
@@ -0,0 +1,114 @@
+package gorigumi
+
+import (
+	"net/http"
+	"time"
+)
+
+const (
+	// defaultRequestIDHeader is the default response header holding the request ID
+	// it is inlcuded in the StandardEnvelope type
+	defaultRequestIDHeader string = "X-Request-Id"
+)
+
+// Envelope wraps the responses written by JSONWrite, JSONError and RespondError,
+// so every response follows the same organization-wide format. Wrap receives
+// the response as a JSONResponse, with Error set for errors, and returns the
+// value to encode. Headers set on w, such as a request ID, can be read.
+type Envelope interface {
+	Wrap(w http.ResponseWriter, status int, res JSONResponse) any
+}
+
+// EnvelopeFunc is a function implementing Envelope.
+type EnvelopeFunc func(w http.ResponseWriter, status int, res JSONResponse) any
+
+// Wrap calls f(w, status, res).
+func (f EnvelopeFunc) Wrap(w http.ResponseWriter, status int, res JSONResponse) any {
+	return f(w, status, res)
+}
+
+// Pagination describes the page of a paginated response. Fields that do not
+// apply to the pagination scheme used are left empty.
+type Pagination struct {
+	Page       int    `json:"page,omitempty"`
+	PerPage    int    `json:"per_page,omitempty"`
+	Total      int    `json:"total,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Paginated is the payload of a paginated response: the items of the page and
+// its pagination metadata. StandardEnvelope writes the items as the data and the
+// pagination in the metadata of the response.
+type Paginated struct {
+	Items      any        `json:"items"`
+	Pagination Pagination `json:"pagination"`
+}
+
+// EnvelopeBody is the format of the responses wrapped by StandardEnvelope:
+//
+//	{"data":{"id":1},"meta":{"request_id":"f3a1","timestamp":"2024-03-14T09:26:53Z"}}
+//	{"error":{"code":"not_found","message":"no such user"},"meta":{"request_id":"f3a1"}}
+type EnvelopeBody struct {
+	Data    any            `json:"data,omitempty"`
+	Message string         `json:"message,omitempty"`
+	Error   *EnvelopeError `json:"error,omitempty"`
+	Meta    *EnvelopeMeta  `json:"meta,omitempty"`
+}
+
+// EnvelopeError is the error of an EnvelopeBody. Details holds the data of
+// the error, such as the list of invalid fields of ValidationErrors.
+type EnvelopeError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details any    `json:"details,omitempty"`
+}
+
+// EnvelopeMeta is the metadata of an EnvelopeBody.
+type EnvelopeMeta struct {
+	RequestID  string      `json:"request_id,omitempty"`
+	Timestamp  *time.Time  `json:"timestamp,omitempty"`
+	Pagination *Pagination `json:"pagination,omitempty"`
+}
+
+// StandardEnvelope is an Envelope writing responses as an EnvelopeBody, with
+// the payload under data, errors under error along with their code, and the
+// request ID, timestamp and pagination metadata under meta.
+type StandardEnvelope struct {
+	// RequestIDHeader is the response header holding the ID of the request,
+	// e.g. set by a request ID middleware. Default to X-Request-Id
+	RequestIDHeader string
+	// Timestamp is a boolean that indicates if the time of the response is
+	// included in the metadata
+	Timestamp bool
+	// Clock is the source of the timestamps. Default to SystemClock
+	Clock Clock
+}
+
+// Wrap implements Envelope.
+func (e *StandardEnvelope) Wrap(w http.ResponseWriter, status int, res JSONResponse) any {
+	body := EnvelopeBody{}
+	meta := EnvelopeMeta{}
+
+	if res.Error {
+		body.Error = &EnvelopeError{Code: res.Code, Message: res.Message, Details: res.Data}
+	} else {
+		body.Data, body.Message = res.Data, res.Message
+		if page, ok := res.Data.(Paginated); ok {
+			body.Data, meta.Pagination = page.Items, &page.Pagination
+		}
+	}
+
+	header := e.RequestIDHeader
+	if header == "" {
+		header = defaultRequestIDHeader
+	}
+	meta.RequestID = w.Header().Get(header)
+	if e.Timestamp {
+		now := clockOrSystem(e.Clock).Now().UTC()
+		meta.Timestamp = &now
+	}
+	if meta != (EnvelopeMeta{}) {
+		body.Meta = &meta
+	}
+	return body
+}
@@ -0,0 +1,107 @@
+package gorigumi
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/drunkleen/gorigumi/toolkittest"
+)
+
+// envelopeTests is a slice of structs that hold the name of the test, the function
+// writing the response and the expected status code and body
+var envelopeTests = []struct {
+	name   string
+	write  func(t *Tools, w http.ResponseWriter) error
+	status int
+	body   string
+}{
+	{"data", func(t *Tools, w http.ResponseWriter) error {
+		return t.JSONWrite(w, http.StatusOK, map[string]int{"id": 1})
+	}, http.StatusOK, `{"data":{"id":1},"meta":{"request_id":"f3a1","timestamp":"2024-03-14T09:26:53Z"}}`},
+	{"response", func(t *Tools, w http.ResponseWriter) error {
+		return t.JSONWrite(w, http.StatusCreated, JSONResponse{Message: "created", Data: 1})
+	}, http.StatusCreated, `{"data":1,"message":"created","meta":{"request_id":"f3a1","timestamp":"2024-03-14T09:26:53Z"}}`},
+	{"paginated", func(t *Tools, w http.ResponseWriter) error {
+		page := Paginated{Items: []string{"a", "b"}, Pagination: Pagination{Page: 2, PerPage: 2, Total: 5}}
+		return t.JSONWrite(w, http.StatusOK, page)
+	}, http.StatusOK, `{"data":["a","b"],"meta":{"request_id":"f3a1","timestamp":"2024-03-14T09:26:53Z","pagination":{"page":2,"per_page":2,"total":5}}}`},
+	{"error", func(t *Tools, w http.ResponseWriter) error {
+		return t.JSONError(w, WithErrorCode(errors.New("no such user"), CodeNotFound), http.StatusNotFound)
+	}, http.StatusNotFound, `{"error":{"code":"not_found","message":"no such user"},"meta":{"request_id":"f3a1","timestamp":"2024-03-14T09:26:53Z"}}`},
+	{"validation", func(t *Tools, w http.ResponseWriter) error {
+		return t.JSONValidationError(w, ValidationErrors{{Field: "email", Message: "is required"}})
+	}, http.StatusUnprocessableEntity, `{"error":{"code":"validation_failed","message":"validation failed: email: is required","details":{"email":"is required"}},` +
+		`"meta":{"request_id":"f3a1","timestamp":"2024-03-14T09:26:53Z"}}`},
+	{"recovered panic", func(t *Tools, w http.ResponseWriter) error {
+		t.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+		t.RecoverMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		})).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		return nil
+	}, http.StatusInternalServerError, `{"error":{"code":"internal_error","message":"internal server error"},"meta":{"request_id":"f3a1","timestamp":"2024-03-14T09:26:53Z"}}`},
+}
+
+// TestTools_Envelope tests that JSONWrite, JSONError, JSONValidationError and
+// RecoverMiddleware wrap their responses with the Envelope of the Tools struct.
+func TestTools_Envelope(t *testing.T) {
+	clock := toolkittest.NewClock(time.Date(2024, 3, 14, 9, 26, 53, 0, time.UTC))
+	testTools := New()
+	testTools.Envelope = &StandardEnvelope{Timestamp: true, Clock: clock}
+
+	for _, tt := range envelopeTests {
+		rr := httptest.NewRecorder()
+		rr.Header().Set("X-Request-Id", "f3a1")
+		if err := tt.write(testTools, rr); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if rr.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.status, rr.Code)
+		}
+		if body := strings.TrimSpace(rr.Body.String()); body != tt.body {
+			t.Errorf("%s: expected body %s, got %s", tt.name, tt.body, body)
+		}
+	}
+}
+
+// TestStandardEnvelope_noMeta tests that StandardEnvelope omits the metadata when
+// there is none, and reads the request ID from the configured header.
+func TestStandardEnvelope_noMeta(t *testing.T) {
+	testTools := New()
+	testTools.Envelope = &StandardEnvelope{RequestIDHeader: "Trace-Id"}
+
+	rr := httptest.NewRecorder()
+	rr.Header().Set("X-Request-Id", "f3a1")
+	_ = testTools.JSONWrite(rr, http.StatusOK, "ok")
+	if body := strings.TrimSpace(rr.Body.String()); body != `{"data":"ok"}` {
+		t.Errorf("expected no metadata, got %s", body)
+	}
+
+	rr = httptest.NewRecorder()
+	rr.Header().Set("Trace-Id", "b7")
+	_ = testTools.JSONWrite(rr, http.StatusOK, "ok")
+	if body := strings.TrimSpace(rr.Body.String()); body != `{"data":"ok","meta":{"request_id":"b7"}}` {
+		t.Errorf("expected the request ID of Trace-Id, got %s", body)
+	}
+}
+
+// TestTools_Envelope_func tests that an EnvelopeFunc receives the status code and
+// the response to wrap.
+func TestTools_Envelope_func(t *testing.T) {
+	testTools := New()
+	testTools.Envelope = EnvelopeFunc(func(_ http.ResponseWriter, status int, res JSONResponse) any {
+		return map[string]any{"status": status, "ok": !res.Error, "payload": res.Data}
+	})
+
+	rr := httptest.NewRecorder()
+	_ = testTools.JSONError(rr, errors.New("boom"), http.StatusBadRequest)
+	if body := strings.TrimSpace(rr.Body.String()); body != `{"ok":false,"payload":null,"status":400}` {
+		t.Errorf("unexpected body %s", body)
+	}
+}
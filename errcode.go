@@ -0,0 +1,69 @@
+package gorigumi
+
+import (
+	"net/http"
+
+	"github.com/drunkleen/gorigumi/jsonx"
+)
+
+// Error codes of the code field of the responses written by JSONError. They
+// are stable, so clients can branch on them rather than on the message. They
+// are aliases of the codes of the jsonx package.
+const (
+	CodeBadRequest           = jsonx.CodeBadRequest
+	CodeInvalidJSON          = jsonx.CodeInvalidJSON
+	CodeValidationFailed     = jsonx.CodeValidationFailed
+	CodeUnauthorized         = jsonx.CodeUnauthorized
+	CodeForbidden            = jsonx.CodeForbidden
+	CodeNotFound             = jsonx.CodeNotFound
+	CodeMethodNotAllowed     = jsonx.CodeMethodNotAllowed
+	CodeConflict             = jsonx.CodeConflict
+	CodeGone                 = jsonx.CodeGone
+	CodePayloadTooLarge      = jsonx.CodePayloadTooLarge
+	CodeFileTooLarge         = jsonx.CodeFileTooLarge
+	CodeTooManyFiles         = jsonx.CodeTooManyFiles
+	CodeUnsupportedMediaType = jsonx.CodeUnsupportedMediaType
+	CodeQuotaExceeded        = jsonx.CodeQuotaExceeded
+	CodeInvalidToken         = jsonx.CodeInvalidToken
+	CodeTokenExpired         = jsonx.CodeTokenExpired
+	CodeRateLimited          = jsonx.CodeRateLimited
+	CodeInternal             = jsonx.CodeInternal
+	CodeBadGateway           = jsonx.CodeBadGateway
+	CodeUnavailable          = jsonx.CodeUnavailable
+	CodeTimeout              = jsonx.CodeTimeout
+	CodeClientClosedRequest  = jsonx.CodeClientClosedRequest
+	CodeFileQuarantined      = jsonx.CodeFileQuarantined
+)
+
+// WithErrorCode returns err with the error code code, written by JSONError
+// along with the message of err, e.g. to report a CodeValidationFailed error
+// or an application specific code.
+func WithErrorCode(err error, code string) error {
+	return jsonx.WithCode(err, code)
+}
+
+// WithErrorDetails returns err with details, written by JSONError as the data
+// of the response, e.g. the identifier of a missing resource.
+func WithErrorDetails(err error, details map[string]any) error {
+	return jsonx.WithDetails(err, details)
+}
+
+// JSONErrorCode writes an error response like JSONError, with the error code
+// code, e.g. "user_not_found", and the optional details, so clients can branch on
+// the code rather than parsing the message:
+//
+//	{"error":true,"message":"no user 42","code":"user_not_found","data":{"id":42}}
+//
+// With a StandardEnvelope, the details are written as error.details.
+func (t *Tools) JSONErrorCode(w http.ResponseWriter, err error, code string, status int, details map[string]any) error {
+	if details != nil {
+		err = WithErrorDetails(err, details)
+	}
+	return t.JSONError(w, WithErrorCode(err, code), status)
+}
+
+// ErrorCode returns the error code of err, or an empty string if it has none.
+// The errors of the toolkit, such as rejected uploads, carry one.
+func ErrorCode(err error) string {
+	return jsonx.Code(err)
+}
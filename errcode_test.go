@@ -0,0 +1,87 @@
+package gorigumi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// errorCodeTests is a slice of structs that hold the name of the test, the files of the
+// upload, the Tools configuration and the expected error code
+var errorCodeTests = []struct {
+	name     string
+	files    map[string][]byte
+	tools    Tools
+	expected string
+}{
+	{"file type", map[string][]byte{"a.txt": []byte("text")}, Tools{AllowedFileTypes: []string{"image/png"}}, CodeUnsupportedMediaType},
+	{"extension", map[string][]byte{"a.exe": []byte("text")}, Tools{AllowedFileTypes: []string{"*"}, AllowedExtensions: []string{".txt"}}, CodeUnsupportedMediaType},
+	{"file size", map[string][]byte{"a.txt": []byte("some text")}, Tools{AllowedFileTypes: []string{"*"}, MaxFileSizePerFile: 4}, CodeFileTooLarge},
+	{"file count", map[string][]byte{"a.txt": []byte("a"), "b.txt": []byte("b")}, Tools{AllowedFileTypes: []string{"*"}, MaxFilesPerRequest: 1}, CodeTooManyFiles},
+	{"quota", map[string][]byte{"a.txt": []byte("some text")}, Tools{AllowedFileTypes: []string{"*"}, MaxDirSize: 4}, CodeQuotaExceeded},
+}
+
+// TestErrorCode tests that rejected uploads carry an error code written by JSONError.
+func TestErrorCode(t *testing.T) {
+	for _, tt := range errorCodeTests {
+		testTools := tt.tools
+		_, err := testTools.UploadFiles(newMultipartRequest(t, tt.files), t.TempDir())
+		if code := ErrorCode(err); code != tt.expected {
+			t.Errorf("%s: expected code %q, got %q for %v", tt.name, tt.expected, code, err)
+			continue
+		}
+
+		rr := httptest.NewRecorder()
+		_ = testTools.JSONError(rr, err, http.StatusBadRequest)
+		var res JSONResponse
+		if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res.Code != tt.expected {
+			t.Errorf("%s: expected response code %q, got %q", tt.name, tt.expected, res.Code)
+		}
+	}
+
+	if code := ErrorCode(WithErrorCode(errors.New("name is required"), CodeValidationFailed)); code != CodeValidationFailed {
+		t.Errorf("expected %s, got %q", CodeValidationFailed, code)
+	}
+	if ErrorCode(ErrTokenExpired) != CodeTokenExpired {
+		t.Errorf("expected ErrTokenExpired to carry %s", CodeTokenExpired)
+	}
+}
+
+// jsonErrorCodeTests is a slice of structs that hold the name of the test, the
+// envelope, the details and the expected body
+var jsonErrorCodeTests = []struct {
+	name     string
+	envelope Envelope
+	details  map[string]any
+	expected string
+}{
+	{"details", nil, map[string]any{"id": 42}, `{"error":true,"message":"no user 42","code":"user_not_found","data":{"id":42}}`},
+	{"no details", nil, nil, `{"error":true,"message":"no user 42","code":"user_not_found"}`},
+	{"envelope", &StandardEnvelope{}, map[string]any{"id": 42}, `{"error":{"code":"user_not_found","message":"no user 42","details":{"id":42}}}`},
+}
+
+// TestTools_JSONErrorCode tests that JSONErrorCode writes the code and the details
+// of the error, with and without an envelope.
+func TestTools_JSONErrorCode(t *testing.T) {
+	for _, tt := range jsonErrorCodeTests {
+		testTools := New()
+		testTools.Envelope = tt.envelope
+
+		rr := httptest.NewRecorder()
+		if err := testTools.JSONErrorCode(rr, errors.New("no user 42"), "user_not_found", http.StatusNotFound, tt.details); err != nil {
+			t.Fatal(err)
+		}
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("%s: expected status 404, got %d", tt.name, rr.Code)
+		}
+		if body := strings.TrimSpace(rr.Body.String()); body != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.expected, body)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package gorigumi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+const (
+	// defaultErrorReportTimeout is the default timeout of the requests of an HTTPErrorReporter
+	// it is inlcuded in the Report method
+	defaultErrorReportTimeout time.Duration = 5 * time.Second
+)
+
+// ErrorReport is an error or a recovered panic reported to an ErrorReporter.
+type ErrorReport struct {
+	// Err is the reported error, a *PanicError for panics
+	Err error
+	// Request is the request being served when the error happened, nil for
+	// errors of background work or of JSONError, which has no request
+	Request *http.Request
+	// Status is the HTTP status code of the error response, 0 if none
+	Status int
+	// User identifies the user of the request, see WithReportUser
+	User string
+	// Tags are the labels of the report, see WithReportTags
+	Tags map[string]string
+	// Stack is the stack trace of panics
+	Stack []byte
+	// Time is when the error happened
+	Time time.Time
+}
+
+// ErrorReporter sends errors to an error tracking service, such as Sentry. It
+// is called by RecoverMiddleware for panics, by JSONError and RespondError for
+// 5xx responses, and by Go for failed and panicking goroutines. Report must be
+// safe for concurrent use and should not block for long, as it runs on the
+// path of the response.
+type ErrorReporter interface {
+	Report(ctx context.Context, report ErrorReport)
+}
+
+// NopErrorReporter is the ErrorReporter discarding reports. It is used when no
+// ErrorReporter is configured.
+var NopErrorReporter ErrorReporter = nopErrorReporter{}
+
+// nopErrorReporter is the type of NopErrorReporter.
+type nopErrorReporter struct{}
+
+// Report does nothing.
+func (nopErrorReporter) Report(context.Context, ErrorReport) {}
+
+// reportUserContextKey is the context key of the user of error reports.
+type reportUserContextKey struct{}
+
+// reportTagsContextKey is the context key of the tags of error reports.
+type reportTagsContextKey struct{}
+
+// WithReportUser returns a copy of ctx whose error reports are attributed to
+// user, e.g. set by an authentication middleware.
+func WithReportUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, reportUserContextKey{}, user)
+}
+
+// WithReportTags returns a copy of ctx whose error reports carry tags, along
+// with the tags already set on ctx.
+func WithReportTags(ctx context.Context, tags map[string]string) context.Context {
+	merged := maps.Clone(reportTags(ctx))
+	if merged == nil {
+		merged = make(map[string]string, len(tags))
+	}
+	maps.Copy(merged, tags)
+	return context.WithValue(ctx, reportTagsContextKey{}, merged)
+}
+
+// reportTags returns the tags set on ctx with WithReportTags.
+func reportTags(ctx context.Context) map[string]string {
+	tags, _ := ctx.Value(reportTagsContextKey{}).(map[string]string)
+	return tags
+}
+
+// errorReporter returns the ErrorReporter of the Tools struct, or
+// NopErrorReporter if not set.
+func (t *Tools) errorReporter() ErrorReporter {
+	if t.ErrorReporter == nil {
+		return NopErrorReporter
+	}
+	return t.ErrorReporter
+}
+
+// reportError sends err to the ErrorReporter, with the user and tags of ctx
+// and the optional request r. Client disconnects are not reported.
+func (t *Tools) reportError(ctx context.Context, r *http.Request, err error, status int, extraTags ...map[string]string) {
+	if IsClientDisconnect(err) {
+		return
+	}
+	report := ErrorReport{Err: err, Request: r, Status: status, Time: t.clock().Now()}
+	report.User, _ = ctx.Value(reportUserContextKey{}).(string)
+	report.Tags = maps.Clone(reportTags(ctx))
+	for _, tags := range extraTags {
+		if report.Tags == nil {
+			report.Tags = make(map[string]string, len(tags))
+		}
+		maps.Copy(report.Tags, tags)
+	}
+	var pe *PanicError
+	if errors.As(err, &pe) {
+		report.Stack = pe.Stack
+	}
+	t.errorReporter().Report(ctx, report)
+}
+
+// RecoverMiddleware returns a middleware recovering from panics of next. The
+// panic is logged, reported to the ErrorReporter as a *PanicError with the
+// request, and answered with a 500 Internal Server Error JSON response wrapped
+// by the Envelope of the Tools struct, unless the handler already started the
+// response. http.ErrAbortHandler panics, which abort the response on purpose,
+// are passed on.
+func (t *Tools) RecoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusWriter{ResponseWriter: w}
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+
+			pe := &PanicError{Value: v, Stack: debug.Stack()}
+			t.logger().Error("recovered handler panic", "method", r.Method, "path", r.URL.Path, "panic", v, "stack", string(pe.Stack))
+			t.reportError(r.Context(), r, pe, http.StatusInternalServerError)
+
+			if sw.status == 0 {
+				_ = t.writeJSONError(w, errors.New("internal server error"), http.StatusInternalServerError)
+			}
+		}()
+
+		next.ServeHTTP(sw, r)
+	})
+}
+
+// HTTPErrorReporter is an ErrorReporter posting every report as a JSON object
+// to URL, a reference implementation for error tracking services accepting
+// webhooks. The object holds the fields error, status, user, tags, stack,
+// time, and method and url for reports with a request.
+type HTTPErrorReporter struct {
+	// URL is the endpoint receiving the reports
+	URL string
+	// Header holds additional headers of the requests, e.g. an API key
+	Header http.Header
+	// Client is the HTTP client used to send reports. Default to
+	// http.DefaultClient
+	Client *http.Client
+	// Timeout bounds the time spent sending a report. Default to 5s
+	Timeout time.Duration
+	// OnError is called with the errors of sending reports, if set
+	OnError func(error)
+}
+
+// httpErrorReport is the JSON object posted by HTTPErrorReporter.
+type httpErrorReport struct {
+	Error  string            `json:"error"`
+	Status int               `json:"status,omitempty"`
+	Method string            `json:"method,omitempty"`
+	URL    string            `json:"url,omitempty"`
+	User   string            `json:"user,omitempty"`
+	Tags   map[string]string `json:"tags,omitempty"`
+	Stack  string            `json:"stack,omitempty"`
+	Time   time.Time         `json:"time"`
+}
+
+// Report implements ErrorReporter. The report is still sent once the request
+// context is canceled, e.g. after the client went away.
+func (h *HTTPErrorReporter) Report(ctx context.Context, report ErrorReport) {
+	if err := h.send(ctx, report); err != nil && h.OnError != nil {
+		h.OnError(err)
+	}
+}
+
+// send posts report to URL.
+func (h *HTTPErrorReporter) send(ctx context.Context, report ErrorReport) error {
+	payload := httpErrorReport{
+		Error:  report.Err.Error(),
+		Status: report.Status,
+		User:   report.User,
+		Tags:   report.Tags,
+		Stack:  string(report.Stack),
+		Time:   report.Time,
+	}
+	if report.Request != nil {
+		payload.Method = report.Request.Method
+		payload.URL = report.Request.URL.String()
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = defaultErrorReportTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range h.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("error report rejected: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package gorigumi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// recordingReporter is an ErrorReporter keeping the reports.
+type recordingReporter struct {
+	mu      sync.Mutex
+	reports []ErrorReport
+}
+
+// Report records report.
+func (r *recordingReporter) Report(ctx context.Context, report ErrorReport) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reports = append(r.reports, report)
+}
+
+// get returns the recorded reports.
+func (r *recordingReporter) get() []ErrorReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]ErrorReport(nil), r.reports...)
+}
+
+// TestTools_RecoverMiddleware tests that panics are answered with a 500 response and
+// reported with the request, user and tags.
+func TestTools_RecoverMiddleware(t *testing.T) {
+	reporter := &recordingReporter{}
+	testTools := New()
+	testTools.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	testTools.ErrorReporter = reporter
+
+	handler := testTools.RecoverMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	req := httptest.NewRequest("GET", "/orders/42", nil)
+	ctx := WithReportUser(req.Context(), "user-1")
+	ctx = WithReportTags(ctx, map[string]string{"tenant": "acme"})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req.WithContext(ctx))
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", rr.Code)
+	}
+	reports := reporter.get()
+	if len(reports) != 1 {
+		t.Fatalf("expected 1 report, got %d", len(reports))
+	}
+	report := reports[0]
+	var pe *PanicError
+	if !errors.As(report.Err, &pe) || pe.Value != "boom" || len(report.Stack) == 0 {
+		t.Errorf("expected a panic error with a stack, got %v", report.Err)
+	}
+	if report.Request == nil || report.Request.URL.Path != "/orders/42" || report.User != "user-1" || report.Tags["tenant"] != "acme" {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	aborting := testTools.RecoverMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Errorf("expected http.ErrAbortHandler to be passed on, got %v", v)
+		}
+	}()
+	aborting.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
+
+// TestTools_ErrorReporter tests that only 5xx errors of JSONError and RespondError, and
+// failed goroutines, are reported.
+func TestTools_ErrorReporter(t *testing.T) {
+	reporter := &recordingReporter{}
+	testTools := New()
+	testTools.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	testTools.ErrorReporter = reporter
+
+	testTools.JSONError(httptest.NewRecorder(), errors.New("bad input"), http.StatusBadRequest)
+	testTools.JSONError(httptest.NewRecorder(), errors.New("database down"))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	testTools.RespondError(httptest.NewRecorder(), req, errors.New("not found"), http.StatusNotFound)
+	testTools.RespondError(httptest.NewRecorder(), req, errors.New("upstream failed"), http.StatusBadGateway)
+
+	<-testTools.Go(context.Background(), func(ctx context.Context) error {
+		return errors.New("job failed")
+	}, GoOptions{Name: "cleanup"})
+
+	reports := reporter.get()
+	if len(reports) != 3 {
+		t.Fatalf("expected 3 reports, got %d", len(reports))
+	}
+	if reports[0].Err.Error() != "database down" || reports[0].Status != 500 || reports[0].Request != nil {
+		t.Errorf("unexpected JSONError report %+v", reports[0])
+	}
+	if reports[1].Err.Error() != "upstream failed" || reports[1].Status != 502 || reports[1].Request != req {
+		t.Errorf("unexpected RespondError report %+v", reports[1])
+	}
+	if reports[2].Err.Error() != "job failed" || reports[2].Tags["goroutine"] != "cleanup" {
+		t.Errorf("unexpected Go report %+v", reports[2])
+	}
+}
+
+// TestHTTPErrorReporter tests that reports are posted as JSON objects.
+func TestHTTPErrorReporter(t *testing.T) {
+	received := make(chan map[string]any, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var payload map[string]any
+		json.NewDecoder(r.Body).Decode(&payload)
+		received <- payload
+	}))
+	defer srv.Close()
+
+	var sendErr error
+	reporter := &HTTPErrorReporter{URL: srv.URL, Header: http.Header{"X-Api-Key": {"secret"}}, OnError: func(err error) { sendErr = err }}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest("POST", "/pay", nil)
+	reporter.Report(ctx, ErrorReport{Err: errors.New("boom"), Request: req, Status: 500, User: "user-1"})
+
+	if sendErr != nil {
+		t.Fatalf("unexpected error: %v", sendErr)
+	}
+	payload := <-received
+	if payload["error"] != "boom" || payload["method"] != "POST" || payload["url"] != "/pay" || payload["user"] != "user-1" {
+		t.Errorf("unexpected payload %v", payload)
+	}
+
+	reporter.Header = nil
+	reporter.Report(context.Background(), ErrorReport{Err: errors.New("boom")})
+	if sendErr == nil {
+		t.Error("expected a rejected report to be passed to OnError")
+	}
+}
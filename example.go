@@ -0,0 +1,305 @@
+package gorigumi
+
+import (
+	"encoding"
+	"hash/fnv"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/drunkleen/gorigumi/fake"
+)
+
+const (
+	// maxExampleDepth is the maximum depth of the nested structs of an example,
+	// so recursive types end
+	// it is inlcuded in the GenerateExamples method
+	maxExampleDepth int = 5
+)
+
+// exampleTime is the time of the time.Time fields of examples, fixed so that
+// examples are stable across runs.
+var exampleTime = time.Date(2024, time.March, 14, 9, 26, 53, 0, time.UTC)
+
+// exampleValues generate the sample values of the kinds of the example tag.
+var exampleValues = map[string]func(f *fake.Faker) string{
+	"email":      (*fake.Faker).Email,
+	"name":       func(f *fake.Faker) string { return f.Name(fake.DefaultLocale) },
+	"first_name": (*fake.Faker).FirstName,
+	"last_name":  (*fake.Faker).LastName,
+	"username":   (*fake.Faker).Username,
+	"uuid":       (*fake.Faker).UUID,
+	"url":        (*fake.Faker).URL,
+	"phone":      (*fake.Faker).Phone,
+	"city":       (*fake.Faker).City,
+	"country":    (*fake.Faker).Country,
+	"word":       (*fake.Faker).Word,
+	"sentence":   func(f *fake.Faker) string { return f.Sentence(8) },
+	"price":      func(f *fake.Faker) string { return strconv.FormatFloat(f.Price(), 'f', 2, 64) },
+	"id":         func(f *fake.Faker) string { return strconv.Itoa(f.Number(1, 9999)) },
+}
+
+// exampleKinds are the kinds inferred from the words of field names without
+// an example tag, in order of precedence.
+var exampleKinds = []struct {
+	word string
+	kind string
+}{
+	{"email", "email"},
+	{"uuid", "uuid"},
+	{"guid", "uuid"},
+	{"username", "username"},
+	{"firstname", "first_name"},
+	{"lastname", "last_name"},
+	{"name", "name"},
+	{"url", "url"},
+	{"link", "url"},
+	{"phone", "phone"},
+	{"city", "city"},
+	{"country", "country"},
+	{"price", "price"},
+	{"amount", "price"},
+	{"cost", "price"},
+	{"total", "price"},
+	{"description", "sentence"},
+	{"message", "sentence"},
+	{"id", "id"},
+}
+
+// ExampleOf returns a realistic example value of type T, e.g. for API
+// documentation, see GenerateExamples. It is a function rather than a method of
+// Tools as methods can't have type parameters.
+func ExampleOf[T any]() T {
+	var v T
+	fillExample(reflect.ValueOf(&v).Elem(), "", 0)
+	return v
+}
+
+// GenerateExamples returns realistic example payloads of the types of values,
+// keyed by type name, e.g. for documentation pages, Postman collections or the
+// examples of an OpenAPI document. Values are only used for their type, which
+// may also be a pointer.
+//
+// String fields are filled according to their example tag, either a kind among
+// email, name, first_name, last_name, username, uuid, url, phone, city,
+// country, word, sentence, price and id, generated by the fake package, or a
+// literal value:
+//
+//	type User struct {
+//		ID    string  `json:"id" example:"uuid"`
+//		Email string  `json:"email"`
+//		Plan  string  `json:"plan" example:"pro"`
+//		Price float64 `json:"price"`
+//	}
+//
+// Without a tag, the kind is inferred from the field name, e.g. Email or
+// UnitPrice, and other fields get a plain value of their type. Values are picked
+// by field name, so examples are the same across runs.
+func (t *Tools) GenerateExamples(values ...any) map[string]any {
+	examples := make(map[string]any, len(values))
+	for _, value := range values {
+		typ := reflect.TypeOf(value)
+		if typ == nil {
+			continue
+		}
+		for typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+		v := reflect.New(typ).Elem()
+		fillExample(v, "", 0)
+		examples[typ.Name()] = v.Interface()
+	}
+	return examples
+}
+
+// fillExample sets v to an example value. name is the name of the field v
+// is the value of, if any.
+func fillExample(v reflect.Value, name string, depth int) {
+	if v.Type() == reflect.TypeFor[time.Time]() {
+		v.Set(reflect.ValueOf(exampleTime))
+		return
+	}
+	if v.Type() == reflect.TypeFor[time.Duration]() {
+		v.SetInt(int64(30 * time.Second))
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		if depth >= maxExampleDepth {
+			return
+		}
+		typ := v.Type()
+		for i := range typ.NumField() {
+			f := typ.Field(i)
+			if !f.IsExported() || f.Tag.Get("json") == "-" {
+				continue
+			}
+			fv := v.Field(i)
+			if tag, ok := f.Tag.Lookup("example"); ok {
+				if setExampleTag(fv, tag, f.Name) {
+					continue
+				}
+			}
+			fillExample(fv, f.Name, depth+1)
+		}
+
+	case reflect.Pointer:
+		if depth >= maxExampleDepth {
+			return
+		}
+		elem := reflect.New(v.Type().Elem())
+		fillExample(elem.Elem(), name, depth+1)
+		v.Set(elem)
+
+	case reflect.Slice:
+		if depth >= maxExampleDepth {
+			return
+		}
+		items := reflect.MakeSlice(v.Type(), 1, 1)
+		fillExample(items.Index(0), singular(name), depth+1)
+		v.Set(items)
+
+	case reflect.Array:
+		for i := range v.Len() {
+			fillExample(v.Index(i), singular(name), depth+1)
+		}
+
+	case reflect.Map:
+		if depth >= maxExampleDepth || v.Type().Key().Kind() != reflect.String {
+			return
+		}
+		m := reflect.MakeMapWithSize(v.Type(), 1)
+		key := reflect.New(v.Type().Key()).Elem()
+		key.SetString("key")
+		value := reflect.New(v.Type().Elem()).Elem()
+		fillExample(value, name, depth+1)
+		m.SetMapIndex(key, value)
+		v.Set(m)
+
+	default:
+		setExampleKind(v, inferExampleKind(name), name)
+	}
+}
+
+// setExampleTag sets v according to the example tag of its field, a kind or
+// a literal value, and reports whether it could.
+func setExampleTag(v reflect.Value, tag, name string) bool {
+	if _, ok := exampleValues[tag]; ok {
+		return setExampleKind(v, tag, name)
+	}
+	if v.Kind() == reflect.Pointer {
+		elem := reflect.New(v.Type().Elem())
+		if !setExampleLiteral(elem.Elem(), tag) {
+			return false
+		}
+		v.Set(elem)
+		return true
+	}
+	return setExampleLiteral(v, tag)
+}
+
+// setExampleKind sets v to a sample value of kind, or to a plain value of its
+// type if kind is empty or does not suit it, and reports whether it could.
+func setExampleKind(v reflect.Value, kind, name string) bool {
+	if generate, ok := exampleValues[kind]; ok && setExampleLiteral(v, generate(exampleFaker(name))) {
+		return true
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		// the field name, e.g. "plan" for Plan
+		if name == "" {
+			name = "String"
+		}
+		v.SetString(strings.ToLower(name[:1]) + name[1:])
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(1)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(1.5)
+	default:
+		return false
+	}
+	return true
+}
+
+// setExampleLiteral sets v to the value of s converted to its type, and
+// reports whether it could.
+func setExampleLiteral(v reflect.Value, s string) bool {
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s)) == nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return false
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return false
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return false
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return false
+		}
+		v.SetFloat(f)
+	default:
+		return false
+	}
+	return true
+}
+
+// inferExampleKind returns the kind of example of a field named name, or ""
+// if none matches.
+func inferExampleKind(name string) string {
+	lower := strings.ToLower(name)
+	for _, k := range exampleKinds {
+		if k.word == "id" {
+			// only a trailing ID, e.g. UserID but not Width
+			if strings.HasSuffix(name, "ID") || strings.HasSuffix(name, "Id") || lower == "id" {
+				return k.kind
+			}
+			continue
+		}
+		if strings.Contains(lower, k.word) {
+			return k.kind
+		}
+	}
+	return ""
+}
+
+// exampleFaker returns the Faker of the examples of fields named name, seeded
+// by the name so that examples are the same across runs.
+func exampleFaker(name string) *fake.Faker {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+	return fake.New(h.Sum64())
+}
+
+// singular returns the name of the items of a collection field, e.g. Email
+// for Emails, so their kind is inferred like the one of a single value.
+func singular(name string) string {
+	if strings.HasSuffix(name, "es") && strings.HasSuffix(strings.TrimSuffix(name, "es"), "ss") {
+		return strings.TrimSuffix(name, "es")
+	}
+	return strings.TrimSuffix(name, "s")
+}
@@ -0,0 +1,124 @@
+package gorigumi
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// exampleOrder is the type of the example tests.
+type exampleOrder struct {
+	ID        string            `json:"id" example:"uuid"`
+	Email     string            `json:"email"`
+	Plan      string            `json:"plan" example:"pro"`
+	Quantity  int               `json:"quantity" example:"3"`
+	UnitPrice float64           `json:"unit_price"`
+	CreatedAt time.Time         `json:"created_at"`
+	Tags      []string          `json:"tags" example:"word"`
+	Customer  *exampleCustomer  `json:"customer"`
+	Labels    map[string]string `json:"labels"`
+	Parent    *exampleOrder     `json:"parent,omitempty"`
+	Secret    string            `json:"-"`
+}
+
+// exampleCustomer is a nested type of exampleOrder.
+type exampleCustomer struct {
+	FirstName string   `json:"first_name"`
+	Emails    []string `json:"emails"`
+	Active    bool     `json:"active"`
+}
+
+// TestExampleOf tests the values of the fields of an example.
+func TestExampleOf(t *testing.T) {
+	order := ExampleOf[exampleOrder]()
+
+	if len(order.ID) != 36 || strings.Count(order.ID, "-") != 4 {
+		t.Errorf("expected a uuid, got %q", order.ID)
+	}
+	if !strings.Contains(order.Email, "@example.") {
+		t.Errorf("expected an email, got %q", order.Email)
+	}
+	if order.Plan != "pro" || order.Quantity != 3 {
+		t.Errorf("expected the literal values of the tags, got %q and %d", order.Plan, order.Quantity)
+	}
+	if order.UnitPrice <= 1.5 || order.CreatedAt.IsZero() {
+		t.Errorf("expected a price and a time, got %v and %v", order.UnitPrice, order.CreatedAt)
+	}
+	if len(order.Tags) != 1 || order.Tags[0] == "" || order.Labels["key"] == "" {
+		t.Errorf("expected a tag and a label, got %v and %v", order.Tags, order.Labels)
+	}
+	if order.Customer == nil || order.Customer.FirstName == "" || !strings.Contains(order.Customer.Emails[0], "@") || !order.Customer.Active {
+		t.Errorf("expected a filled customer, got %+v", order.Customer)
+	}
+	if order.Secret != "" {
+		t.Errorf("expected ignored fields to be empty, got %q", order.Secret)
+	}
+
+	if again := ExampleOf[exampleOrder](); again.Email != order.Email {
+		t.Errorf("expected stable examples, got %q and %q", order.Email, again.Email)
+	}
+}
+
+// TestTools_GenerateExamples tests that examples are keyed by type name and that
+// recursive types end.
+func TestTools_GenerateExamples(t *testing.T) {
+	examples := New().GenerateExamples(exampleOrder{}, &exampleCustomer{}, nil)
+	if len(examples) != 2 {
+		t.Fatalf("expected 2 examples, got %d", len(examples))
+	}
+	if _, ok := examples["exampleCustomer"].(exampleCustomer); !ok {
+		t.Errorf("expected an exampleCustomer, got %T", examples["exampleCustomer"])
+	}
+
+	out, err := json.Marshal(examples["exampleOrder"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if depth := strings.Count(string(out), `"parent"`); depth == 0 || depth > maxExampleDepth {
+		t.Errorf("expected nested parents up to the maximum depth, got %d", depth)
+	}
+}
+
+// exampleKindTests is a slice of structs that hold every kind documented on
+// GenerateExamples and the pattern of its values
+var exampleKindTests = []struct {
+	kind    string
+	pattern string
+}{
+	{"email", `^[a-z0-9._]+@example\.(com|org|net)$`},
+	{"name", `^\pL+ \pL+$`},
+	{"first_name", `^\pL+$`},
+	{"last_name", `^\pL+$`},
+	{"username", `^[a-z0-9._]+$`},
+	{"uuid", `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`},
+	{"url", `^https://example\.(com|org|net)/`},
+	{"phone", `^\+1-\d{3}-555-01\d{2}$`},
+	{"city", `^\pL+$`},
+	{"country", `^[A-Z]{2}$`},
+	{"word", `^[a-z]+$`},
+	{"sentence", `^[A-Z][a-z ]+\.$`},
+	{"price", `^\d+\.\d{2}$`},
+	{"id", `^\d+$`},
+}
+
+// TestExampleOf_kinds tests that every documented kind of the example tag
+// generates a value of its own, rather than being used as a literal.
+func TestExampleOf_kinds(t *testing.T) {
+	if len(exampleKindTests) != len(exampleValues) {
+		t.Errorf("expected %d documented kinds, got %d", len(exampleValues), len(exampleKindTests))
+	}
+
+	for _, tt := range exampleKindTests {
+		field := reflect.StructField{Name: "Value", Type: reflect.TypeFor[string](), Tag: reflect.StructTag(`example:"` + tt.kind + `"`)}
+		v := reflect.New(reflect.StructOf([]reflect.StructField{field})).Elem()
+		fillExample(v, "", 0)
+
+		value := v.Field(0).String()
+		if !regexp.MustCompile(tt.pattern).MatchString(value) {
+			t.Errorf("%s: unexpected value %q", tt.kind, value)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+// Command server is a small file sharing service wiring the gorigumi features
+// together. It accepts image uploads, stores a thumbnail next to every image,
+// hands out signed, expiring download links, serves a JSON API and runs behind
+// the access log, metrics and load shedding middlewares.
+//
+//	go run ./examples/server -addr :8080 -dir ./uploads
+//
+//	curl -F file=@photo.png localhost:8080/api/uploads
+//	curl localhost:8080/api/download/<name>?expires=<unix>&sig=<signature>
+//	curl -d '{"title":"Hello, World!"}' localhost:8080/api/notes
+//	curl localhost:8080/api/metrics
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/drunkleen/gorigumi"
+)
+
+// config holds the settings of the example server.
+type config struct {
+	// UploadDir is the directory uploaded files and thumbnails are stored in
+	UploadDir string
+	// Secret is the key download links are signed with
+	Secret []byte
+	// LinkTTL is the lifetime of download links
+	LinkTTL time.Duration
+	// ThumbnailSize is the width and height thumbnails are scaled to fit
+	ThumbnailSize int
+	// AccessLog is where access log lines are written
+	AccessLog io.Writer
+	// Now returns the current time, it is replaced in tests
+	Now func() time.Time
+}
+
+// server holds the dependencies of the HTTP handlers.
+type server struct {
+	cfg     config
+	tools   *gorigumi.Tools
+	metrics *gorigumi.Metrics
+}
+
+// uploadResponse describes a stored file in the upload response.
+type uploadResponse struct {
+	Name         string `json:"name"`
+	Size         int64  `json:"size"`
+	DownloadURL  string `json:"download_url"`
+	ThumbnailURL string `json:"thumbnail_url,omitempty"`
+}
+
+// note is the payload of the notes API.
+type note struct {
+	Title string `json:"title"`
+	Body  string `json:"body,omitempty"`
+	Slug  string `json:"slug,omitempty"`
+}
+
+func main() {
+	addr := flag.String("addr", ":8080", "listen address")
+	dir := flag.String("dir", "./uploads", "upload directory")
+	flag.Parse()
+
+	secret := os.Getenv("DOWNLOAD_SECRET")
+	if secret == "" {
+		secret = gorigumi.New().GenerateRandomString(32)
+	}
+
+	handler, err := newServer(config{UploadDir: *dir, Secret: []byte(secret), AccessLog: os.Stdout})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, handler))
+}
+
+// newServer returns the handler of the example server, with its middleware stack.
+func newServer(cfg config) (http.Handler, error) {
+	if cfg.LinkTTL == 0 {
+		cfg.LinkTTL = 15 * time.Minute
+	}
+	if cfg.ThumbnailSize == 0 {
+		cfg.ThumbnailSize = 128
+	}
+	if cfg.AccessLog == nil {
+		cfg.AccessLog = io.Discard
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+
+	tools := gorigumi.New()
+	tools.MaxFileSize = 10 * 1024 * 1024
+	tools.MaxFilesPerRequest = 5
+	tools.AllowedFileTypes = []string{"image/png", "image/jpeg", "image/gif"}
+	tools.ImageOptions = &gorigumi.ImageOptions{
+		GenerateThumbnails: []gorigumi.Size{{Width: cfg.ThumbnailSize, Height: cfg.ThumbnailSize}},
+	}
+	if err := tools.CreateDirIfNotExists(cfg.UploadDir); err != nil {
+		return nil, err
+	}
+
+	accessLog, err := gorigumi.NewAccessLogger(gorigumi.AccessLogOptions{Format: gorigumi.AccessLogJSON, Output: cfg.AccessLog})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &server{cfg: cfg, tools: tools, metrics: gorigumi.NewMetrics()}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/uploads", s.upload)
+	mux.HandleFunc("GET /api/download/{name}", s.download)
+	mux.HandleFunc("POST /api/notes", s.createNote)
+	mux.HandleFunc("GET /api/metrics", s.metricsSnapshot)
+
+	var handler http.Handler = mux
+	handler = tools.LoadShedMiddleware(handler, gorigumi.LoadShedOptions{MaxInFlight: 50})
+	handler = tools.MetricsMiddleware(handler, s.metrics)
+	handler = tools.AccessLogMiddleware(handler, accessLog)
+
+	return handler, nil
+}
+
+// upload stores the uploaded images along with their thumbnails and returns
+// signed download links for both.
+func (s *server) upload(w http.ResponseWriter, r *http.Request) {
+	files, err := s.tools.UploadFiles(r, s.cfg.UploadDir)
+	if err != nil {
+		s.tools.JSONError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	var resp []uploadResponse
+	for _, f := range files {
+		file := uploadResponse{
+			Name:        f.OriginalFileName,
+			Size:        f.FileSize,
+			DownloadURL: s.signedURL(f.NewFileName),
+		}
+		if len(f.Thumbnails) > 0 {
+			file.ThumbnailURL = s.signedURL(f.Thumbnails[0])
+		}
+		resp = append(resp, file)
+	}
+
+	s.tools.JSONWrite(w, http.StatusCreated, gorigumi.JSONResponse{Message: "uploaded", Data: resp})
+}
+
+// download serves a stored file if the link signature is valid and not expired.
+func (s *server) download(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if err := s.verifyURL(name, r.URL.Query()); err != nil {
+		s.tools.JSONError(w, err, http.StatusForbidden)
+		return
+	}
+
+	s.tools.DownloadFile(w, r, s.cfg.UploadDir, name, name)
+}
+
+// createNote reads a note, derives its slug and echoes it back.
+func (s *server) createNote(w http.ResponseWriter, r *http.Request) {
+	var n note
+	if err := s.tools.JSONRead(w, r, &n); err != nil {
+		s.tools.JSONError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	slug, err := s.tools.ConvertToSlug(n.Title)
+	if err != nil {
+		s.tools.JSONError(w, err, http.StatusUnprocessableEntity)
+		return
+	}
+	n.Slug = slug
+
+	s.tools.JSONWrite(w, http.StatusCreated, gorigumi.JSONResponse{Message: "created", Data: n})
+}
+
+// metricsSnapshot returns the latency statistics collected by the metrics middleware.
+func (s *server) metricsSnapshot(w http.ResponseWriter, r *http.Request) {
+	s.tools.JSONWrite(w, http.StatusOK, s.metrics.Snapshot())
+}
+
+// signedURL returns a download link for name valid for LinkTTL.
+func (s *server) signedURL(name string) string {
+	expires := strconv.FormatInt(s.cfg.Now().Add(s.cfg.LinkTTL).Unix(), 10)
+	q := url.Values{"expires": {expires}, "sig": {s.sign(name, expires)}}
+	return "/api/download/" + url.PathEscape(name) + "?" + q.Encode()
+}
+
+// verifyURL checks the signature and expiry of a download link.
+func (s *server) verifyURL(name string, q url.Values) error {
+	expires := q.Get("expires")
+	if !hmac.Equal([]byte(q.Get("sig")), []byte(s.sign(name, expires))) {
+		return errors.New("invalid signature")
+	}
+
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || s.cfg.Now().After(time.Unix(unix, 0)) {
+		return errors.New("link expired")
+	}
+
+	return nil
+}
+
+// sign returns the hex encoded HMAC of a file name and expiry time.
+func (s *server) sign(name, expires string) string {
+	mac := hmac.New(sha256.New, s.cfg.Secret)
+	fmt.Fprintf(mac, "%s\n%s", name, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/drunkleen/gorigumi/toolkittest"
+)
+
+// newTestServer starts the example server on a random port with a controllable clock.
+func newTestServer(t *testing.T) (*httptest.Server, *toolkittest.Clock) {
+	t.Helper()
+
+	clock := toolkittest.NewClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	handler, err := newServer(config{
+		UploadDir: t.TempDir(),
+		Secret:    []byte("secret"),
+		LinkTTL:   time.Minute,
+		Now:       clock.Now,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return srv, clock
+}
+
+// decodeData decodes the Data field of a JSON response into data.
+func decodeData(t *testing.T, resp *http.Response, data any) {
+	t.Helper()
+	defer resp.Body.Close()
+
+	envelope := struct {
+		Error bool            `json:"error"`
+		Data  json.RawMessage `json:"data"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(envelope.Data, data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestUploadAndDownload uploads an image, downloads it and its thumbnail with the
+// signed links, and checks that tampered and expired links are rejected.
+func TestUploadAndDownload(t *testing.T) {
+	srv, clock := newTestServer(t)
+
+	img, err := toolkittest.GenerateImage(400, 200, "png")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("file", "photo.png")
+	part.Write(img)
+	writer.Close()
+
+	resp, err := http.Post(srv.URL+"/api/uploads", writer.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", resp.StatusCode)
+	}
+
+	var files []uploadResponse
+	decodeData(t, resp, &files)
+	if len(files) != 1 || files[0].Name != "photo.png" || files[0].Size != int64(len(img)) {
+		t.Fatalf("unexpected upload response %+v", files)
+	}
+
+	resp, err = http.Get(srv.URL + files[0].DownloadURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !bytes.Equal(data, img) {
+		t.Errorf("expected the original image, got status %d and %d bytes", resp.StatusCode, len(data))
+	}
+
+	resp, err = http.Get(srv.URL + files[0].ThumbnailURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, _, err := image.DecodeConfig(resp.Body)
+	resp.Body.Close()
+	if err != nil || cfg.Width != 128 || cfg.Height != 64 {
+		t.Errorf("expected a 128x64 thumbnail, got %+v, %v", cfg, err)
+	}
+
+	tampered := strings.Replace(files[0].DownloadURL, "sig=", "sig=0", 1)
+	if resp, _ := http.Get(srv.URL + tampered); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected tampered link to be rejected, got %d", resp.StatusCode)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if resp, _ := http.Get(srv.URL + files[0].DownloadURL); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected expired link to be rejected, got %d", resp.StatusCode)
+	}
+}
+
+// TestUploadRejectsOtherTypes checks that non-image uploads are rejected.
+func TestUploadRejectsOtherTypes(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("file", "notes.txt")
+	part.Write([]byte("hello"))
+	writer.Close()
+
+	resp, err := http.Post(srv.URL+"/api/uploads", writer.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", resp.StatusCode)
+	}
+}
+
+// TestNotesAndMetrics posts notes to the JSON API and checks that the requests
+// show up in the metrics snapshot.
+func TestNotesAndMetrics(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	resp, err := http.Post(srv.URL+"/api/notes", "application/json", strings.NewReader(`{"title":"Hello, World!"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n note
+	decodeData(t, resp, &n)
+	if n.Slug != "hello-world" {
+		t.Errorf("expected slug hello-world, got %q", n.Slug)
+	}
+
+	resp, err = http.Post(srv.URL+"/api/notes", "application/json", strings.NewReader(`{"unknown":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 for unknown fields, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(srv.URL + "/api/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var snapshot map[string]json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := snapshot["POST /api/notes"]; !ok {
+		t.Errorf("expected metrics for POST /api/notes, got %v", snapshot)
+	}
+}
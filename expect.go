@@ -0,0 +1,93 @@
+package gorigumi
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"slices"
+)
+
+// UploadPrecheckOptions configures UploadPrecheckMiddleware. Every check only
+// looks at the request headers.
+type UploadPrecheckOptions struct {
+	// MaxContentLength is the maximum declared size of request bodies.
+	// Default to the MaxFileSize of the Tools struct, or 512MB
+	MaxContentLength int64
+	// RequireContentLength is a boolean that indicates if requests without
+	// a declared size, e.g. chunked requests, are rejected
+	RequireContentLength bool
+	// ContentTypes is the list of accepted request media types.
+	// Default to multipart/form-data
+	ContentTypes []string
+	// Authorize is an optional check of the credentials of the request.
+	// Requests it returns an error for are rejected with 401 Unauthorized
+	Authorize func(r *http.Request) error
+	// Quota is an optional check of the declared size against the storage
+	// quota of the client. Requests it returns an error for are rejected
+	// with 413 Request Entity Too Large
+	Quota func(r *http.Request, size int64) error
+}
+
+// UploadPrecheckMiddleware returns a middleware that validates upload requests
+// from their headers alone, before next reads the body. Go's HTTP server only
+// sends the 100 Continue interim response to clients sending an Expect:
+// 100-continue header on the first read of the body, so clients of rejected
+// requests never send it: an oversized upload is refused before any of its
+// bytes crosses the wire. Rejections are JSON errors.
+func (t *Tools) UploadPrecheckMiddleware(next http.Handler, opts ...UploadPrecheckOptions) http.Handler {
+	var options UploadPrecheckOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	if options.MaxContentLength == 0 {
+		options.MaxContentLength = int64(defaultMaxFileSize)
+		if t.MaxFileSize != 0 {
+			options.MaxContentLength = int64(t.MaxFileSize)
+		}
+	}
+	if options.ContentTypes == nil {
+		options.ContentTypes = []string{"multipart/form-data"}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status, err := precheckUpload(r, options); err != nil {
+			// the unread body must not be drained to reuse the connection
+			w.Header().Set("Connection", "close")
+			_ = t.JSONError(w, err, status)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// precheckUpload runs the checks of UploadPrecheckMiddleware and returns the
+// status code and error a failing request is rejected with.
+func precheckUpload(r *http.Request, options UploadPrecheckOptions) (int, error) {
+	if r.ContentLength > options.MaxContentLength {
+		return http.StatusRequestEntityTooLarge, fmt.Errorf("upload of %d bytes exceeds the limit of %d bytes", r.ContentLength, options.MaxContentLength)
+	}
+	if r.ContentLength < 0 && options.RequireContentLength {
+		return http.StatusLengthRequired, errors.New("upload size must be declared with Content-Length")
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if !slices.Contains(options.ContentTypes, mediaType) {
+		return http.StatusUnsupportedMediaType, fmt.Errorf("content type %q is not accepted", mediaType)
+	}
+
+	if options.Authorize != nil {
+		if err := options.Authorize(r); err != nil {
+			return http.StatusUnauthorized, err
+		}
+	}
+
+	if options.Quota != nil {
+		if err := options.Quota(r, r.ContentLength); err != nil {
+			return http.StatusRequestEntityTooLarge, err
+		}
+	}
+
+	return 0, nil
+}
@@ -0,0 +1,112 @@
+package gorigumi
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// precheckTests is a slice of structs that hold the name of the test, the declared
+// content length and type, the authorization header, and the expected status
+var precheckTests = []struct {
+	name          string
+	contentLength int64
+	contentType   string
+	authorization string
+	status        int
+}{
+	{"accepted", 100, "multipart/form-data; boundary=x", "ok", http.StatusOK},
+	{"too large", 2000, "multipart/form-data; boundary=x", "ok", http.StatusRequestEntityTooLarge},
+	{"unknown length", -1, "multipart/form-data; boundary=x", "ok", http.StatusLengthRequired},
+	{"wrong type", 100, "application/json", "ok", http.StatusUnsupportedMediaType},
+	{"unauthorized", 100, "multipart/form-data; boundary=x", "", http.StatusUnauthorized},
+	{"over quota", 600, "multipart/form-data; boundary=x", "ok", http.StatusRequestEntityTooLarge},
+}
+
+// precheckOptions are the options used by the precheck tests
+var precheckOptions = UploadPrecheckOptions{
+	MaxContentLength:     1000,
+	RequireContentLength: true,
+	Authorize: func(r *http.Request) error {
+		if r.Header.Get("Authorization") != "ok" {
+			return errors.New("missing credentials")
+		}
+		return nil
+	},
+	Quota: func(r *http.Request, size int64) error {
+		if size > 500 {
+			return errors.New("quota exceeded")
+		}
+		return nil
+	},
+}
+
+// TestTools_UploadPrecheckMiddleware tests the checks of the middleware and that the
+// body of rejected requests is never read.
+func TestTools_UploadPrecheckMiddleware(t *testing.T) {
+	testTools := New()
+
+	for _, pt := range precheckTests {
+		bodyRead := false
+		handler := testTools.UploadPrecheckMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.ReadAll(r.Body)
+			bodyRead = true
+		}), precheckOptions)
+
+		req := httptest.NewRequest("POST", "/", strings.NewReader("data"))
+		req.ContentLength = pt.contentLength
+		req.Header.Set("Content-Type", pt.contentType)
+		req.Header.Set("Authorization", pt.authorization)
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != pt.status {
+			t.Errorf("%s: expected status %d, got %d", pt.name, pt.status, rr.Code)
+		}
+		if bodyRead != (pt.status == http.StatusOK) {
+			t.Errorf("%s: expected body read %t, got %t", pt.name, pt.status == http.StatusOK, bodyRead)
+		}
+	}
+}
+
+// TestTools_UploadPrecheckMiddlewareContinue tests over a real connection that rejected
+// requests sending Expect: 100-continue get the final response without 100 Continue.
+func TestTools_UploadPrecheckMiddlewareContinue(t *testing.T) {
+	handler := New().UploadPrecheckMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+	}), UploadPrecheckOptions{MaxContentLength: 1000})
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	for _, size := range []int{10, 1 << 30} {
+		conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+		fmt.Fprintf(conn, "POST / HTTP/1.1\r\nHost: x\r\nContent-Type: multipart/form-data; boundary=x\r\nContent-Length: %d\r\nExpect: 100-continue\r\n\r\n", size)
+		status, err := bufio.NewReader(conn).ReadString('\n')
+		conn.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := "HTTP/1.1 100 Continue"
+		if size > 1000 {
+			expected = "HTTP/1.1 413 Request Entity Too Large"
+		}
+		if strings.TrimSpace(status) != expected {
+			t.Errorf("size %d: expected %q, got %q", size, expected, status)
+		}
+	}
+}
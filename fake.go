@@ -0,0 +1,26 @@
+package gorigumi
+
+import "github.com/drunkleen/gorigumi/fake"
+
+// FakeEmail returns a fake email address in a domain reserved for
+// documentation, e.g. mary.clark42@example.org, to seed development databases.
+func (t *Tools) FakeEmail() string {
+	return fake.Email()
+}
+
+// FakeName returns a fake full name in the given locale, among en, fr, de, es
+// and pt. Other locales use English names.
+func (t *Tools) FakeName(locale string) string {
+	return fake.Name(locale)
+}
+
+// FakeSentence returns a lorem ipsum sentence of n words.
+func (t *Tools) FakeSentence(n int) string {
+	return fake.Sentence(n)
+}
+
+// FakeImagePNG returns a w x h PNG image of a random gradient, e.g. to load
+// test upload endpoints with valid images.
+func (t *Tools) FakeImagePNG(w, h int) ([]byte, error) {
+	return fake.ImagePNG(w, h)
+}
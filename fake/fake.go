@@ -0,0 +1,285 @@
+// Package fake generates realistic fake data, such as names, email addresses,
+// sentences and images, e.g. to seed development databases or to load test
+// upload endpoints. It is the implementation of gorigumi's Fake methods and of
+// its example payloads, and can be imported on its own.
+//
+// The package-level functions draw from a random source and are safe for
+// concurrent use. A Faker created with New draws from a seeded source, so the
+// same seed always produces the same data.
+//
+// Email addresses use the example.com, example.org and example.net domains,
+// which are reserved for documentation and never receive mail.
+package fake
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand/v2"
+	"strings"
+	"unicode"
+)
+
+// DefaultLocale is the locale of names whose locale is unknown.
+const DefaultLocale = "en"
+
+// names holds the first and last names of the supported locales.
+var names = map[string]struct {
+	first []string
+	last  []string
+}{
+	"en": {
+		first: []string{"James", "Mary", "John", "Patricia", "Robert", "Jennifer", "Michael", "Linda", "David", "Emily", "Daniel", "Sarah"},
+		last:  []string{"Smith", "Johnson", "Williams", "Brown", "Jones", "Miller", "Davis", "Wilson", "Taylor", "Clark", "Lewis", "Walker"},
+	},
+	"fr": {
+		first: []string{"Camille", "Louis", "Léa", "Gabriel", "Chloé", "Jules", "Manon", "Hugo", "Inès", "Arthur"},
+		last:  []string{"Martin", "Bernard", "Dubois", "Thomas", "Robert", "Richard", "Petit", "Durand", "Leroy", "Moreau"},
+	},
+	"de": {
+		first: []string{"Anna", "Lukas", "Lena", "Leon", "Mia", "Paul", "Hannah", "Jonas", "Sophie", "Felix"},
+		last:  []string{"Müller", "Schmidt", "Schneider", "Fischer", "Weber", "Meyer", "Wagner", "Becker", "Schulz", "Hoffmann"},
+	},
+	"es": {
+		first: []string{"Lucía", "Hugo", "Sofía", "Mateo", "Martina", "Pablo", "Valeria", "Diego", "Carmen", "Javier"},
+		last:  []string{"García", "Rodríguez", "González", "Fernández", "López", "Martínez", "Sánchez", "Pérez", "Gómez", "Ruiz"},
+	},
+	"pt": {
+		first: []string{"Maria", "João", "Ana", "Pedro", "Beatriz", "Tiago", "Inês", "Rafael", "Mariana", "Rodrigo"},
+		last:  []string{"Silva", "Santos", "Ferreira", "Pereira", "Oliveira", "Costa", "Rodrigues", "Martins", "Sousa", "Gomes"},
+	},
+}
+
+// words are the words of sentences.
+var words = []string{
+	"lorem", "ipsum", "dolor", "sit", "amet", "consectetur", "adipiscing", "elit",
+	"sed", "do", "eiusmod", "tempor", "incididunt", "ut", "labore", "et", "dolore",
+	"magna", "aliqua", "enim", "ad", "minim", "veniam", "quis", "nostrud",
+	"exercitation", "ullamco", "laboris", "nisi", "aliquip", "ex", "ea", "commodo",
+}
+
+// domains are the domains of email addresses and URLs.
+var domains = []string{"example.com", "example.org", "example.net"}
+
+// cities are the cities of addresses.
+var cities = []string{"Lisbon", "Toronto", "Osaka", "Berlin", "Nairobi", "Montevideo", "Oslo", "Melbourne"}
+
+// countries are the ISO 3166-1 alpha-2 codes of the countries of addresses.
+var countries = []string{"PT", "CA", "JP", "DE", "KE", "UY", "NO", "AU"}
+
+// Faker generates fake data from a seeded source. It is not safe for
+// concurrent use.
+type Faker struct {
+	r *rand.Rand
+}
+
+// New returns a Faker whose data is determined by seed.
+func New(seed uint64) *Faker {
+	return &Faker{r: rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15))}
+}
+
+// global is the Faker of the package-level functions, drawing from the
+// random source of math/rand/v2.
+var global = &Faker{}
+
+// intN returns a random number in [0, n).
+func (f *Faker) intN(n int) int {
+	if f.r == nil {
+		return rand.IntN(n)
+	}
+	return f.r.IntN(n)
+}
+
+// pick returns a random element of items.
+func pick[T any](f *Faker, items []T) T {
+	return items[f.intN(len(items))]
+}
+
+// Name returns a full name in the given locale, among en, fr, de, es and pt,
+// or in DefaultLocale if the locale is not supported. Regional locales, such
+// as fr-CA or pt_BR, use the names of their language.
+func (f *Faker) Name(locale string) string {
+	first, last := f.names(locale)
+	return first + " " + last
+}
+
+// names returns a first and a last name in locale.
+func (f *Faker) names(locale string) (string, string) {
+	lang, _, _ := strings.Cut(strings.ReplaceAll(strings.ToLower(locale), "_", "-"), "-")
+	n, ok := names[lang]
+	if !ok {
+		n = names[DefaultLocale]
+	}
+	return pick(f, n.first), pick(f, n.last)
+}
+
+// FirstName returns a first name in DefaultLocale.
+func (f *Faker) FirstName() string {
+	first, _ := f.names(DefaultLocale)
+	return first
+}
+
+// LastName returns a last name in DefaultLocale.
+func (f *Faker) LastName() string {
+	_, last := f.names(DefaultLocale)
+	return last
+}
+
+// Username returns a lowercase user name, e.g. mary.clark42.
+func (f *Faker) Username() string {
+	first, last := f.names(DefaultLocale)
+	return fmt.Sprintf("%s.%s%d", strings.ToLower(first), strings.ToLower(last), f.intN(100))
+}
+
+// Email returns an email address in a domain reserved for documentation,
+// e.g. mary.clark42@example.org.
+func (f *Faker) Email() string {
+	return f.Username() + "@" + pick(f, domains)
+}
+
+// Word returns a lorem ipsum word.
+func (f *Faker) Word() string {
+	return pick(f, words)
+}
+
+// Sentence returns a capitalized lorem ipsum sentence of n words, ending with
+// a period. It returns "" if n is not positive.
+func (f *Faker) Sentence(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	s := make([]string, n)
+	for i := range s {
+		s[i] = f.Word()
+	}
+	r := []rune(s[0])
+	r[0] = unicode.ToUpper(r[0])
+	s[0] = string(r)
+	return strings.Join(s, " ") + "."
+}
+
+// UUID returns a random version 4 UUID.
+func (f *Faker) UUID() string {
+	var b [16]byte
+	for i := range b {
+		b[i] = byte(f.intN(256))
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// URL returns an https URL in a domain reserved for documentation.
+func (f *Faker) URL() string {
+	return fmt.Sprintf("https://%s/%s/%d", pick(f, domains), f.Word(), f.intN(1000))
+}
+
+// Phone returns a fictional phone number of the North American 555-01XX
+// range.
+func (f *Faker) Phone() string {
+	return fmt.Sprintf("+1-%03d-555-01%02d", 200+f.intN(800), f.intN(100))
+}
+
+// City returns a city name.
+func (f *Faker) City() string {
+	return pick(f, cities)
+}
+
+// Country returns the ISO 3166-1 alpha-2 code of a country, e.g. PT.
+func (f *Faker) Country() string {
+	return pick(f, countries)
+}
+
+// Price returns a price between 0.99 and 999.99, with two decimals.
+func (f *Faker) Price() float64 {
+	return float64(99+f.intN(99901)) / 100
+}
+
+// Number returns a number between lo and hi, inclusive.
+func (f *Faker) Number(lo, hi int) int {
+	if hi <= lo {
+		return lo
+	}
+	return lo + f.intN(hi-lo+1)
+}
+
+// ImagePNG returns a w x h PNG image of a gradient between two random colors,
+// e.g. to load test upload endpoints with valid images.
+func (f *Faker) ImagePNG(w, h int) ([]byte, error) {
+	if w <= 0 || h <= 0 {
+		return nil, fmt.Errorf("invalid image size %dx%d", w, h)
+	}
+
+	from := color.RGBA{R: uint8(f.intN(256)), G: uint8(f.intN(256)), B: uint8(f.intN(256)), A: 255}
+	to := color.RGBA{R: uint8(f.intN(256)), G: uint8(f.intN(256)), B: uint8(f.intN(256)), A: 255}
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			t := (x + y) * 255 / max(w+h-2, 1)
+			img.Set(x, y, color.RGBA{
+				R: blend(from.R, to.R, t),
+				G: blend(from.G, to.G, t),
+				B: blend(from.B, to.B, t),
+				A: 255,
+			})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// blend returns the component t/255 of the way from a to b.
+func blend(a, b uint8, t int) uint8 {
+	return uint8((int(a)*(255-t) + int(b)*t) / 255)
+}
+
+// Name returns a full name in the given locale, see Faker.Name.
+func Name(locale string) string { return global.Name(locale) }
+
+// FirstName returns a first name, see Faker.FirstName.
+func FirstName() string { return global.FirstName() }
+
+// LastName returns a last name, see Faker.LastName.
+func LastName() string { return global.LastName() }
+
+// Username returns a user name, see Faker.Username.
+func Username() string { return global.Username() }
+
+// Email returns an email address, see Faker.Email.
+func Email() string { return global.Email() }
+
+// Word returns a lorem ipsum word, see Faker.Word.
+func Word() string { return global.Word() }
+
+// Sentence returns a lorem ipsum sentence of n words, see Faker.Sentence.
+func Sentence(n int) string { return global.Sentence(n) }
+
+// UUID returns a version 4 UUID, see Faker.UUID.
+func UUID() string { return global.UUID() }
+
+// URL returns an https URL, see Faker.URL.
+func URL() string { return global.URL() }
+
+// Phone returns a fictional phone number, see Faker.Phone.
+func Phone() string { return global.Phone() }
+
+// City returns a city name, see Faker.City.
+func City() string { return global.City() }
+
+// Country returns a country code, see Faker.Country.
+func Country() string { return global.Country() }
+
+// Price returns a price, see Faker.Price.
+func Price() float64 { return global.Price() }
+
+// Number returns a number between lo and hi, see Faker.Number.
+func Number(lo, hi int) int { return global.Number(lo, hi) }
+
+// ImagePNG returns a w x h PNG image, see Faker.ImagePNG.
+func ImagePNG(w, h int) ([]byte, error) { return global.ImagePNG(w, h) }
@@ -0,0 +1,79 @@
+package fake
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// uuidPattern matches version 4 UUIDs.
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+// TestNew tests that Fakers with the same seed generate the same data.
+func TestNew(t *testing.T) {
+	a, b := New(42), New(42)
+	for range 10 {
+		if x, y := a.Email(), b.Email(); x != y {
+			t.Fatalf("expected the same email, got %s and %s", x, y)
+		}
+	}
+	if New(1).UUID() == New(2).UUID() {
+		t.Error("expected different seeds to generate different data")
+	}
+}
+
+// nameTests is a slice of structs that hold a locale and the last names it draws from
+var nameTests = []struct {
+	locale string
+	lang   string
+}{
+	{"fr", "fr"},
+	{"pt_BR", "pt"},
+	{"ES-mx", "es"},
+	{"xx", DefaultLocale},
+	{"", DefaultLocale},
+}
+
+// TestFaker_Name tests the locales of names.
+func TestFaker_Name(t *testing.T) {
+	f := New(7)
+	for _, nt := range nameTests {
+		name := f.Name(nt.locale)
+		_, last, _ := strings.Cut(name, " ")
+		found := false
+		for _, l := range names[nt.lang].last {
+			found = found || l == last
+		}
+		if !found {
+			t.Errorf("%s: expected a %s name, got %q", nt.locale, nt.lang, name)
+		}
+	}
+}
+
+// TestFaker_values tests the format of the generated values.
+func TestFaker_values(t *testing.T) {
+	f := New(3)
+	for range 100 {
+		if u := f.UUID(); !uuidPattern.MatchString(u) {
+			t.Fatalf("invalid UUID %s", u)
+		}
+		if p := f.Price(); p < 0.99 || p > 999.99 {
+			t.Fatalf("price out of range: %v", p)
+		}
+		if n := f.Number(3, 5); n < 3 || n > 5 {
+			t.Fatalf("number out of range: %d", n)
+		}
+		if p := f.Phone(); !strings.Contains(p, "-555-01") {
+			t.Fatalf("expected a fictional phone number, got %s", p)
+		}
+		if c := f.Country(); len(c) != 2 || strings.ToUpper(c) != c {
+			t.Fatalf("expected a country code, got %s", c)
+		}
+	}
+	if s := f.Sentence(0); s != "" {
+		t.Errorf("expected an empty sentence, got %q", s)
+	}
+	if _, err := f.ImagePNG(0, 10); err == nil {
+		t.Error("expected an error for an empty image")
+	}
+}
@@ -0,0 +1,33 @@
+package gorigumi
+
+import (
+	"bytes"
+	"image/png"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+// TestTools_Fake tests the fake data methods.
+func TestTools_Fake(t *testing.T) {
+	testTools := New()
+
+	if _, err := mail.ParseAddress(testTools.FakeEmail()); err != nil {
+		t.Errorf("expected a valid email address: %v", err)
+	}
+	if name := testTools.FakeName("de-AT"); len(strings.Fields(name)) != 2 {
+		t.Errorf("expected a first and a last name, got %q", name)
+	}
+	if sentence := testTools.FakeSentence(5); len(strings.Fields(sentence)) != 5 || !strings.HasSuffix(sentence, ".") {
+		t.Errorf("expected a sentence of 5 words, got %q", sentence)
+	}
+
+	data, err := testTools.FakeImagePNG(40, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil || img.Bounds().Dx() != 40 || img.Bounds().Dy() != 30 {
+		t.Errorf("expected a 40x30 PNG image, got %v (%v)", img, err)
+	}
+}
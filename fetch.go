@@ -0,0 +1,417 @@
+package gorigumi
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/drunkleen/gorigumi/jsonx"
+)
+
+const (
+	// fetchPartialPrefix is the prefix of the partial files of FetchFile
+	fetchPartialPrefix string = ".fetch-"
+
+	// fetchPartialExt is the extension of the partial files of FetchFile
+	fetchPartialExt string = ".part"
+
+	// fetchValidatorExt is appended to the name of a partial file to get the
+	// name of the file holding the validator of the remote file
+	fetchValidatorExt string = ".validator"
+
+	// defaultFetchFileName is the name of fetched files whose URL has no file name
+	// it is inlcuded in the FetchFile method
+	defaultFetchFileName string = "download"
+)
+
+// ErrChecksumMismatch is returned by FetchFile for downloads whose SHA-256 does
+// not match the expected one.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// FetchOptions configures FetchFile.
+type FetchOptions struct {
+	// FileName is the name the file is stored under in destDir. Default to a
+	// random name with the extension of the remote file name
+	FileName string
+	// MaxSize is the maximum size of the file. Default to the MaxFileSize of
+	// the Tools struct, or 512MB
+	MaxSize int64
+	// SHA256 is the hex encoded SHA-256 the file must have, if set
+	SHA256 string
+	// Resume is a boolean that indicates if failed downloads are kept to be
+	// resumed with a Range request by the next call for the same URL and
+	// destDir. Default to false, failed downloads are removed
+	Resume bool
+	// Request holds the headers, query parameters, credentials and client of
+	// the request, as for JSONSendToRemote. Its Method is ignored
+	Request RemoteRequest
+}
+
+// FetchFile downloads the file at rawURL to destDir, the mirror image of
+// UploadFiles, e.g. to import a file from a third-party storage. The download is
+// checked like an uploaded file: its size against MaxSize, its content type,
+// sniffed from its first bytes before the rest is downloaded, against
+// AllowedFileTypes, its extension against AllowedExtensions, and it is scanned by
+// the Scanner, then processed according to ImageOptions and MetadataSidecars. The
+// file is written to a partial file in destDir and only moved to its name once
+// complete and checked, according to CollisionPolicy. Concurrent calls for the
+// same URL and destDir run one after the other. If SHA256 is set, files with
+// another hash return an error wrapping ErrChecksumMismatch. Responses with a
+// status other than 2xx return a *RemoteError. The MaxDirSize and MaxDirFiles
+// quota applies, and files larger than the room it leaves return a *QuotaError.
+// The RemoteBreaker applies, but not RemoteTimeout, so ctx must allow for the
+// whole download. If ctx is in dry-run mode, the file is downloaded and checked
+// but not written, scanned or processed.
+func (t *Tools) FetchFile(ctx context.Context, rawURL, destDir string, opts ...FetchOptions) (*UploadedFile, error) {
+	if IsDryRun(ctx) && !t.dryRun {
+		dry := *t
+		dry.dryRun = true
+		return dry.FetchFile(ctx, rawURL, destDir, opts...)
+	}
+
+	var options FetchOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	if options.MaxSize == 0 {
+		options.MaxSize = int64(t.MaxFileSize)
+	}
+	if options.MaxSize == 0 {
+		options.MaxSize = int64(defaultMaxFileSize)
+	}
+
+	if err := t.checkUploadDir(destDir); err != nil {
+		return nil, err
+	}
+	destDir = longPath(destDir)
+
+	// the partial file is named after the URL, so the next call resumes it
+	sum := sha256.Sum256([]byte(rawURL))
+	partial := filepath.Join(destDir, fetchPartialPrefix+hex.EncodeToString(sum[:16])+fetchPartialExt)
+
+	unlock, err := lockPartial(ctx, partial)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	quotaErr, err := t.fetchQuota(ctx, destDir, partial, &options)
+	if err != nil {
+		return nil, err
+	}
+
+	file, fileType, err := t.fetchPartial(ctx, rawURL, partial, options)
+	if quotaErr != nil && ErrorCode(err) == CodeFileTooLarge {
+		err = quotaErr
+	}
+	if err != nil {
+		if !options.Resume && !t.dryRun {
+			removePartial(partial)
+		}
+		return nil, err
+	}
+	if t.dryRun {
+		return file, nil
+	}
+
+	err = checkFetched(partial, file, options)
+	if err == nil {
+		err = t.scanUpload(ctx, destDir, partial, file)
+	}
+	if err == nil {
+		file.NewFileName, err = t.placeFile(partial, destDir, file.NewFileName)
+	}
+	if err != nil {
+		removePartial(partial)
+		return nil, err
+	}
+	os.Remove(partial + fetchValidatorExt)
+
+	if err := t.finishUpload(destDir, file, fileType); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// fetchQuota checks that destDir can hold one more file under the MaxDirSize and
+// MaxDirFiles quota, the partial file of the download and its validator aside,
+// and lowers the MaxSize of options to the room left. It returns the error to
+// report for files larger than that room, if the quota limits them.
+func (t *Tools) fetchQuota(ctx context.Context, destDir, partial string, options *FetchOptions) (*QuotaError, error) {
+	q, ok := t.dirQuota()
+	if !ok {
+		return nil, nil
+	}
+
+	// a resumed download already takes room, which the file may use
+	var pendingBytes int64
+	pendingFiles := 0
+	if !t.dryRun {
+		for _, p := range []string{partial, partial + fetchValidatorExt} {
+			if info, err := os.Stat(p); err == nil && info.Mode().IsRegular() {
+				pendingBytes += info.Size()
+				pendingFiles++
+			}
+		}
+	}
+
+	usage, err := q.CheckCtx(ctx, destDir, -pendingBytes, 1-pendingFiles)
+	if err != nil {
+		return nil, err
+	}
+	if room := q.MaxBytes - usage.Bytes + pendingBytes; q.MaxBytes > 0 && room < options.MaxSize {
+		options.MaxSize = room
+		return &QuotaError{Dir: destDir, Usage: usage, MaxBytes: q.MaxBytes, MaxFiles: q.MaxFiles}, nil
+	}
+	return nil, nil
+}
+
+// fetchPartial downloads rawURL to the partial file, resuming it with a Range
+// request if it exists, was saved along with the ETag or Last-Modified date of
+// the remote file, and that file did not change since. The type of the file is
+// checked before the rest of it is downloaded. It returns the record of the file
+// and its content type. In dry-run mode, the download is checked against the
+// SHA256 of options and discarded.
+func (t *Tools) fetchPartial(ctx context.Context, rawURL, partial string, options FetchOptions) (*UploadedFile, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	applyRemoteRequest(req, options.Request)
+
+	var offset int64
+	if info, err := os.Stat(partial); err == nil && info.Mode().IsRegular() && !t.dryRun {
+		// partial files are only resumed if the remote file is the same
+		if validator, err := os.ReadFile(partial + fetchValidatorExt); err == nil && len(validator) > 0 {
+			offset = info.Size()
+			req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+			req.Header.Set("If-Range", string(validator))
+		}
+	}
+
+	httpClient := options.Request.Client
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	res, err := t.doRemote(httpClient, req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer res.Body.Close()
+
+	flag := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	switch {
+	case offset > 0 && res.StatusCode == http.StatusPartialContent && contentRangeStart(res.Header.Get("Content-Range")) == offset:
+	case res.StatusCode >= 200 && res.StatusCode <= 299 && res.StatusCode != http.StatusPartialContent:
+		// the server sent the whole file
+		offset, flag = 0, os.O_CREATE|os.O_WRONLY|os.O_TRUNC
+		if t.dryRun {
+			break
+		}
+		if err := writeFileReplacing(partial+fetchValidatorExt, []byte(fetchValidator(res))); err != nil {
+			return nil, "", err
+		}
+	case res.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// the partial file does not match the remote file anymore
+		removePartial(partial)
+		return nil, "", newRemoteError(res, nil)
+	default:
+		body, _ := io.ReadAll(io.LimitReader(res.Body, int64(jsonx.DefaultMaxBytes)))
+		return nil, "", newRemoteError(res, body)
+	}
+
+	if res.ContentLength > options.MaxSize-offset {
+		return nil, "", WithErrorCode(fmt.Errorf("the remote file is too big, the maximum size is %d bytes", options.MaxSize), CodeFileTooLarge)
+	}
+
+	// the type is sniffed from the first bytes of the file, which may be
+	// partly in the partial file already
+	var head []byte
+	if offset > 0 {
+		if head, err = readHead(partial); err != nil {
+			return nil, "", err
+		}
+	}
+	start := make([]byte, max(512-len(head), 0))
+	n, err := io.ReadFull(res.Body, start)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, "", err
+	}
+	start = start[:n]
+
+	originalName := fetchedFileName(res, rawURL)
+	fileType, err := t.checkFileType(originalName, append(head, start...))
+	if err != nil {
+		// files of a rejected type are not worth resuming
+		if !t.dryRun {
+			removePartial(partial)
+		}
+		return nil, "", err
+	}
+
+	body := io.MultiReader(bytes.NewReader(start), res.Body)
+	var n64 int64
+	var sum string
+	if t.dryRun {
+		hash := sha256.New()
+		n64, err = io.Copy(hash, io.LimitReader(body, options.MaxSize+1))
+		sum = hex.EncodeToString(hash.Sum(nil))
+	} else {
+		var f *os.File
+		if f, err = os.OpenFile(partial, flag, 0644); err != nil {
+			return nil, "", err
+		}
+		n64, err = io.Copy(f, io.LimitReader(body, options.MaxSize-offset+1))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if offset+n64 > options.MaxSize {
+		return nil, "", WithErrorCode(fmt.Errorf("the remote file is too big, the maximum size is %d bytes", options.MaxSize), CodeFileTooLarge)
+	}
+	if res.ContentLength >= 0 && n64 != res.ContentLength {
+		return nil, "", fmt.Errorf("%w: expected %d bytes, received %d", ErrUploadTruncated, res.ContentLength, n64)
+	}
+	if t.dryRun {
+		if err := checkSHA256(sum, options); err != nil {
+			return nil, "", err
+		}
+	}
+
+	newName := t.newFileName(originalName, true)
+	if options.FileName != "" {
+		newName = t.newFileName(options.FileName, false)
+	}
+	return &UploadedFile{OriginalFileName: originalName, NewFileName: newName, FileSize: offset + n64}, fileType, nil
+}
+
+// checkFetched checks the checksum of the complete partial file of file.
+func checkFetched(partial string, file *UploadedFile, options FetchOptions) error {
+	if options.SHA256 == "" {
+		return nil
+	}
+	sum, err := fileSHA256(partial)
+	if err != nil {
+		return err
+	}
+	return checkSHA256(sum, options)
+}
+
+// checkSHA256 checks the hex encoded SHA-256 sum of a download against the
+// SHA256 of options, if set.
+func checkSHA256(sum string, options FetchOptions) error {
+	if options.SHA256 != "" && !strings.EqualFold(sum, options.SHA256) {
+		return fmt.Errorf("%w: expected %s, got %s", ErrChecksumMismatch, options.SHA256, sum)
+	}
+	return nil
+}
+
+// fetchValidator returns the validator of the remote file of res sent in the
+// If-Range header of resumed downloads: its ETag, unless it is weak, or else its
+// Last-Modified date. It is empty if the file has neither, so its downloads are
+// not resumed.
+func fetchValidator(res *http.Response) string {
+	if etag := res.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return res.Header.Get("Last-Modified")
+}
+
+// removePartial removes the partial file of a download and its validator.
+func removePartial(partial string) {
+	os.Remove(partial)
+	os.Remove(partial + fetchValidatorExt)
+}
+
+// partialLock is the lock of a partial file of FetchFile.
+type partialLock struct {
+	ch   chan struct{}
+	refs int
+}
+
+var (
+	// partialLocksMu guards partialLocks
+	partialLocksMu sync.Mutex
+	// partialLocks holds the locks of the partial files being downloaded to
+	partialLocks = make(map[string]*partialLock)
+)
+
+// lockPartial waits until no other FetchFile call downloads to the partial
+// file, or ctx is done, and returns the function releasing the lock.
+func lockPartial(ctx context.Context, partial string) (func(), error) {
+	partialLocksMu.Lock()
+	l := partialLocks[partial]
+	if l == nil {
+		l = &partialLock{ch: make(chan struct{}, 1)}
+		partialLocks[partial] = l
+	}
+	l.refs++
+	partialLocksMu.Unlock()
+
+	release := func() {
+		partialLocksMu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(partialLocks, partial)
+		}
+		partialLocksMu.Unlock()
+	}
+
+	select {
+	case l.ch <- struct{}{}:
+		return func() {
+			<-l.ch
+			release()
+		}, nil
+	case <-ctx.Done():
+		release()
+		return nil, ctx.Err()
+	}
+}
+
+// fetchedFileName returns the file name of the download res of rawURL, from its
+// Content-Disposition header or else from the path of the URL.
+func fetchedFileName(res *http.Response, rawURL string) string {
+	if _, params, err := mime.ParseMediaType(res.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		return filepath.Base(params["filename"])
+	}
+	if u, err := url.Parse(rawURL); err == nil {
+		if name := path.Base(u.Path); name != "/" && name != "." {
+			return name
+		}
+	}
+	return defaultFetchFileName
+}
+
+// contentRangeStart returns the first byte of a Content-Range header such as
+// "bytes 100-199/200", or -1 if it is invalid.
+func contentRangeStart(value string) int64 {
+	rangeSpec, ok := strings.CutPrefix(value, "bytes ")
+	if !ok {
+		return -1
+	}
+	first, _, ok := strings.Cut(rangeSpec, "-")
+	if !ok {
+		return -1
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return start
+}
@@ -0,0 +1,294 @@
+package gorigumi
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fetchContent is the file served by the FetchFile tests.
+var fetchContent = strings.Repeat("quarterly figures\n", 20)
+
+// fetchFileTests is a slice of structs that hold the name of the test, the path of the
+// file, the allowed file types, the options and the expected error code, error and
+// original file name
+var fetchFileTests = []struct {
+	name         string
+	path         string
+	allowedTypes []string
+	options      FetchOptions
+	code         string
+	err          error
+	originalName string
+}{
+	{"downloaded", "/files/report.txt", []string{"text/plain; charset=utf-8"}, FetchOptions{}, "", nil, "report.txt"},
+	{"content disposition", "/export?id=1", []string{"*"}, FetchOptions{}, "", nil, "export.csv"},
+	{"named", "/files/report.txt", []string{"*"}, FetchOptions{FileName: "../q3.txt"}, "", nil, "report.txt"},
+	{"checksum", "/files/report.txt", []string{"*"}, FetchOptions{SHA256: fetchSHA256(fetchContent)}, "", nil, "report.txt"},
+	{"checksum mismatch", "/files/report.txt", []string{"*"}, FetchOptions{SHA256: fetchSHA256("other")}, "", ErrChecksumMismatch, ""},
+	{"too large", "/files/report.txt", []string{"*"}, FetchOptions{MaxSize: 100}, CodeFileTooLarge, nil, ""},
+	{"type not allowed", "/files/report.txt", []string{"image/png"}, FetchOptions{}, CodeUnsupportedMediaType, nil, ""},
+	{"not found", "/files/missing.txt", []string{"*"}, FetchOptions{}, CodeNotFound, nil, ""},
+}
+
+// fetchSHA256 returns the hex encoded SHA-256 of s.
+func fetchSHA256(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// TestTools_FetchFile tests that remote files are downloaded and checked like uploads,
+// and that failed downloads leave no file behind.
+func TestTools_FetchFile(t *testing.T) {
+	testTools := New()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/files/report.txt":
+			http.ServeContent(w, r, "report.txt", time.Time{}, strings.NewReader(fetchContent))
+		case "/export":
+			w.Header().Set("Content-Disposition", `attachment; filename="export.csv"`)
+			w.Write([]byte(fetchContent))
+		default:
+			_ = testTools.JSONError(w, WithErrorCode(errors.New("no such file"), CodeNotFound), http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	for _, ft := range fetchFileTests {
+		dir := t.TempDir()
+		testTools.AllowedFileTypes = ft.allowedTypes
+
+		file, err := testTools.FetchFile(context.Background(), srv.URL+ft.path, dir, ft.options)
+		var remoteErr *RemoteError
+		errors.As(err, &remoteErr)
+		switch {
+		case ft.code != "" && ErrorCode(err) != ft.code && (remoteErr == nil || remoteErr.Code != ft.code):
+			t.Errorf("%s: expected code %s, got %v", ft.name, ft.code, err)
+		case ft.err != nil && !errors.Is(err, ft.err):
+			t.Errorf("%s: expected error %v, got %v", ft.name, ft.err, err)
+		case ft.code == "" && ft.err == nil && err != nil:
+			t.Errorf("%s: %v", ft.name, err)
+		}
+
+		entries, _ := os.ReadDir(dir)
+		if err != nil {
+			if len(entries) != 0 {
+				t.Errorf("%s: expected no file to be left, got %d", ft.name, len(entries))
+			}
+			continue
+		}
+
+		if file.OriginalFileName != ft.originalName || file.FileSize != int64(len(fetchContent)) || len(entries) != 1 {
+			t.Errorf("%s: unexpected file %+v with %d entries", ft.name, file, len(entries))
+			continue
+		}
+		if ft.options.FileName != "" && file.NewFileName != "q3.txt" {
+			t.Errorf("%s: expected the sanitized name, got %q", ft.name, file.NewFileName)
+		}
+		if content, _ := os.ReadFile(filepath.Join(dir, file.NewFileName)); string(content) != fetchContent {
+			t.Errorf("%s: unexpected content %q", ft.name, content)
+		}
+	}
+}
+
+// TestTools_FetchFile_resume tests that an interrupted download is resumed with a
+// Range request guarded by an If-Range header, and restarted if the remote file
+// changed since.
+func TestTools_FetchFile_resume(t *testing.T) {
+	for _, changed := range []bool{false, true} {
+		var ranges, ifRanges []string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ranges = append(ranges, r.Header.Get("Range"))
+			ifRanges = append(ifRanges, r.Header.Get("If-Range"))
+			w.Header().Set("ETag", `"v1"`)
+			if len(ranges) == 1 {
+				// the connection drops after half of the file
+				w.Header().Set("Content-Length", "360")
+				w.Write([]byte(fetchContent[:180]))
+				w.(http.Flusher).Flush()
+				panic(http.ErrAbortHandler)
+			}
+			if changed {
+				w.Header().Set("ETag", `"v2"`)
+			}
+			http.ServeContent(w, r, "report.txt", time.Time{}, strings.NewReader(fetchContent))
+		}))
+
+		testTools := New()
+		testTools.AllowedFileTypes = []string{"*"}
+		dir := t.TempDir()
+		options := FetchOptions{Resume: true, SHA256: fetchSHA256(fetchContent)}
+
+		if _, err := testTools.FetchFile(context.Background(), srv.URL+"/report.txt", dir, options); err == nil {
+			t.Fatal("expected the interrupted download to fail")
+		}
+		file, err := testTools.FetchFile(context.Background(), srv.URL+"/report.txt", dir, options)
+		srv.Close()
+		if err != nil {
+			t.Fatalf("changed %t: %v", changed, err)
+		}
+
+		if len(ranges) != 2 || ranges[0] != "" || ranges[1] != "bytes=180-" || ifRanges[1] != `"v1"` {
+			t.Errorf("changed %t: expected the download to be resumed from byte 180 of \"v1\", got the ranges %q and %q", changed, ranges, ifRanges)
+		}
+		if content, _ := os.ReadFile(filepath.Join(dir, file.NewFileName)); string(content) != fetchContent {
+			t.Errorf("changed %t: unexpected content %q", changed, content)
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+			t.Errorf("changed %t: expected the partial file to be gone, got %d entries", changed, len(entries))
+		}
+	}
+}
+
+// TestTools_FetchFile_sniff tests that files of a type that is not allowed are
+// rejected from their first bytes, without downloading the rest of them.
+func TestTools_FetchFile_sniff(t *testing.T) {
+	canceled := make(chan bool, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat(fetchContent, 4)))
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+			canceled <- true
+		case <-time.After(5 * time.Second):
+			canceled <- false
+		}
+	}))
+	defer srv.Close()
+
+	testTools := New()
+	testTools.AllowedFileTypes = []string{"image/png"}
+	dir := t.TempDir()
+
+	_, err := testTools.FetchFile(context.Background(), srv.URL+"/report.txt", dir, FetchOptions{Resume: true})
+	if ErrorCode(err) != CodeUnsupportedMediaType {
+		t.Errorf("expected code %s, got %v", CodeUnsupportedMediaType, err)
+	}
+	if !<-canceled {
+		t.Error("expected the download to stop once its type was rejected")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected no file to be left, got %d", len(entries))
+	}
+}
+
+// TestTools_FetchFile_concurrent tests that concurrent downloads of the same URL to
+// the same directory do not write to the partial file at once.
+func TestTools_FetchFile_concurrent(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// every response is a different version of the file
+		version := strings.Repeat(string(rune('a'+requests.Add(1))), 300)
+		for range 4 {
+			w.Write([]byte(version))
+			w.(http.Flusher).Flush()
+			time.Sleep(5 * time.Millisecond)
+		}
+	}))
+	defer srv.Close()
+
+	testTools := New()
+	testTools.AllowedFileTypes = []string{"*"}
+	dir := t.TempDir()
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			file, err := testTools.FetchFile(context.Background(), srv.URL+"/report.txt", dir)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			content, _ := os.ReadFile(filepath.Join(dir, file.NewFileName))
+			if len(content) != 1200 || strings.Count(string(content), string(content[:1])) != 1200 {
+				t.Errorf("expected a single version of the file, got %q", content)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if entries, _ := os.ReadDir(dir); len(entries) != 4 {
+		t.Errorf("expected 4 files, got %d", len(entries))
+	}
+}
+
+// TestTools_FetchFile_quota tests that downloads are checked against the quota of the
+// directory, and may not take more than the room it leaves.
+func TestTools_FetchFile_quota(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(fetchContent))
+	}))
+	defer srv.Close()
+
+	for _, tt := range []struct {
+		name     string
+		maxBytes int64
+		maxFiles int
+		err      error
+	}{
+		{"within quota", int64(len(fetchContent)) + 10, 2, nil},
+		{"no room left", int64(len(fetchContent)), 2, ErrQuotaExceeded},
+		{"too many files", 0, 1, ErrQuotaExceeded},
+	} {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "existing.txt"), []byte("0123456789"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		testTools := New()
+		testTools.AllowedFileTypes = []string{"*"}
+		testTools.MaxDirSize = tt.maxBytes
+		testTools.MaxDirFiles = tt.maxFiles
+
+		_, err := testTools.FetchFile(context.Background(), srv.URL+"/report.txt", dir)
+		var quotaErr *QuotaError
+		if !errors.Is(err, tt.err) || (err != nil) != (tt.err != nil) || (tt.err != nil && !errors.As(err, &quotaErr)) {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.err, err)
+		}
+
+		want := 2
+		if tt.err != nil {
+			want = 1
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) != want {
+			t.Errorf("%s: expected %d files, got %d", tt.name, want, len(entries))
+		}
+	}
+}
+
+// TestTools_FetchFile_dryRun tests that downloads in dry-run mode are checked but not
+// written.
+func TestTools_FetchFile_dryRun(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(fetchContent))
+	}))
+	defer srv.Close()
+
+	testTools := New()
+	testTools.AllowedFileTypes = []string{"*"}
+	testTools.MetadataSidecars = true
+	dir := t.TempDir()
+	ctx := WithDryRun(context.Background())
+
+	file, err := testTools.FetchFile(ctx, srv.URL+"/report.txt", dir, FetchOptions{SHA256: fetchSHA256(fetchContent)})
+	if err != nil || file.FileSize != int64(len(fetchContent)) {
+		t.Fatalf("expected the file to be checked, got %+v (%v)", file, err)
+	}
+	if _, err := testTools.FetchFile(ctx, srv.URL+"/report.txt", dir, FetchOptions{SHA256: fetchSHA256("other")}); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("expected error %v, got %v", ErrChecksumMismatch, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected no file to be written, got %d", len(entries))
+	}
+}
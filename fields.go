@@ -0,0 +1,77 @@
+package gorigumi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/drunkleen/gorigumi/jsonx"
+)
+
+// JSONWriteFields writes data like JSONWrite, keeping only the fields named by
+// fields, e.g. the ones of RequestedFields, so clients of list endpoints can ask
+// for the fields they display only. Nested fields are separated with dots, e.g.
+// "address.city", and lists are reduced item by item. Fields are named as in the
+// response, after FieldNaming, and unknown fields are ignored. The fields of the
+// Data of a JSONResponse and of the Items of a Paginated value are selected, so
+// messages and pagination are kept. An empty fields writes all the fields.
+func (t *Tools) JSONWriteFields(w http.ResponseWriter, status int, data any, fields []string, headers ...http.Header) error {
+	if len(fields) == 0 {
+		return t.JSONWrite(w, status, data, headers...)
+	}
+
+	paths := make([][]string, 0, len(fields))
+	for _, field := range fields {
+		paths = append(paths, strings.Split(field, "."))
+	}
+
+	var err error
+	switch v := data.(type) {
+	case JSONResponse:
+		v.Data, err = t.selectFields(v.Data, paths)
+		data = v
+	case Paginated:
+		v.Items, err = t.selectFields(v.Items, paths)
+		data = v
+	default:
+		data, err = t.selectFields(data, paths)
+	}
+	if err != nil {
+		return err
+	}
+	return t.JSONWrite(w, status, data, headers...)
+}
+
+// RequestedFields returns the fields requested by the fields query parameter of
+// r, a comma-separated list such as ?fields=name,email,address.city, which may
+// be repeated. It returns nil if no field is requested.
+func (t *Tools) RequestedFields(r *http.Request) []string {
+	var fields []string
+	for _, value := range r.URL.Query()["fields"] {
+		for _, field := range strings.Split(value, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				fields = append(fields, field)
+			}
+		}
+	}
+	return fields
+}
+
+// selectFields reduces data, with its fields named after FieldNaming, to the
+// given field paths.
+func (t *Tools) selectFields(data any, paths [][]string) (any, error) {
+	buf, err := json.Marshal(jsonx.Rename(data, t.FieldNaming))
+	if err != nil {
+		return nil, err
+	}
+
+	// numbers are kept as written, as large integers would lose precision
+	decoder := json.NewDecoder(bytes.NewReader(buf))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return selectQueryFields(value, paths), nil
+}
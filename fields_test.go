@@ -0,0 +1,80 @@
+package gorigumi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+// fieldsUser is the resource written by the JSONWriteFields tests.
+type fieldsUser struct {
+	ID       int64
+	Name     string
+	Email    string
+	Address  fieldsAddress
+	Password string `json:"-"`
+}
+
+// fieldsAddress is a nested object of fieldsUser.
+type fieldsAddress struct {
+	City    string
+	Country string
+}
+
+// fieldsUsers are the users written by the JSONWriteFields tests.
+var fieldsUsers = []fieldsUser{
+	{ID: 9007199254740993, Name: "ana", Email: "ana@example.com", Address: fieldsAddress{City: "Oslo", Country: "NO"}},
+	{ID: 2, Name: "bea", Email: "bea@example.com", Address: fieldsAddress{City: "Lima", Country: "PE"}},
+}
+
+// jsonWriteFieldsTests is a slice of structs that hold the name of the test, the data,
+// the selected fields and the expected body
+var jsonWriteFieldsTests = []struct {
+	name   string
+	data   any
+	fields []string
+	body   string
+}{
+	{"all fields", fieldsUsers[1], nil, `{"id":2,"name":"bea","email":"bea@example.com","address":{"city":"Lima","country":"PE"}}`},
+	{"object", fieldsUsers[1], []string{"name", "email"}, `{"email":"bea@example.com","name":"bea"}`},
+	{"list", fieldsUsers, []string{"id", "address.city"}, `[{"address":{"city":"Oslo"},"id":9007199254740993},{"address":{"city":"Lima"},"id":2}]`},
+	{"whole and nested", fieldsUsers[1], []string{"address.city", "address"}, `{"address":{"city":"Lima","country":"PE"}}`},
+	{"unknown and hidden fields", fieldsUsers[1], []string{"name", "password", "phone"}, `{"name":"bea"}`},
+	{"response", JSONResponse{Message: "found", Data: fieldsUsers[1]}, []string{"name"}, `{"message":"found","data":{"name":"bea"}}`},
+	{"paginated", Paginated{Items: fieldsUsers, Pagination: Pagination{Page: 1, Total: 2}}, []string{"name"},
+		`{"items":[{"name":"ana"},{"name":"bea"}],"pagination":{"page":1,"total":2}}`},
+}
+
+// TestTools_JSONWriteFields tests that only the selected fields are written, named
+// after the FieldNaming of the Tools struct.
+func TestTools_JSONWriteFields(t *testing.T) {
+	testTools := New()
+	testTools.FieldNaming = NamingSnakeCase
+
+	for _, ft := range jsonWriteFieldsTests {
+		responseRecorder := httptest.NewRecorder()
+		if err := testTools.JSONWriteFields(responseRecorder, http.StatusOK, ft.data, ft.fields); err != nil {
+			t.Errorf("%s: %v", ft.name, err)
+			continue
+		}
+		if body := responseRecorder.Body.String(); body != ft.body {
+			t.Errorf("%s: expected %s, got %s", ft.name, ft.body, body)
+		}
+	}
+}
+
+// TestTools_RequestedFields tests that the fields query parameter is split on commas.
+func TestTools_RequestedFields(t *testing.T) {
+	testTools := New()
+
+	req := httptest.NewRequest(http.MethodGet, "/users?fields=name,%20email,,address.city&fields=id", nil)
+	if fields := testTools.RequestedFields(req); !slices.Equal(fields, []string{"name", "email", "address.city", "id"}) {
+		t.Errorf("unexpected fields %v", fields)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/users", nil)
+	if fields := testTools.RequestedFields(req); fields != nil {
+		t.Errorf("expected no fields, got %v", fields)
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !windows
+
+package gorigumi
+
+import "os"
+
+// caseInsensitiveFS is a boolean that indicates if file names differing only
+// in case name the same file. Case-insensitive volumes, such as the default
+// ones of macOS, are detected by the file system itself when linking
+const caseInsensitiveFS bool = false
+
+// longPath returns dir unchanged, as only Windows limits the length of paths
+// below what file names allow.
+func longPath(dir string) string {
+	return dir
+}
+
+// chmodFile sets the permissions of f to mode.
+func chmodFile(f *os.File, mode os.FileMode) error {
+	return f.Chmod(mode)
+}
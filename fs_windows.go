@@ -0,0 +1,28 @@
+//go:build windows
+
+package gorigumi
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// caseInsensitiveFS is a boolean that indicates if file names differing only
+// in case name the same file, as on NTFS
+const caseInsensitiveFS bool = true
+
+// longPath returns dir as an absolute path, so the os package prefixes the
+// paths of files in it with \\?\ when they exceed MAX_PATH, the 260 characters
+// Windows otherwise limits paths to.
+func longPath(dir string) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		return abs
+	}
+	return dir
+}
+
+// chmodFile does nothing, as Windows only has a read-only attribute and files
+// created by the process are already writable and readable by their owner.
+func chmodFile(*os.File, os.FileMode) error {
+	return nil
+}
@@ -0,0 +1,69 @@
+//go:build windows
+
+package gorigumi
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestTools_UploadFiles_longPath tests that files are stored in directories whose
+// paths exceed the 260 characters of MAX_PATH.
+func TestTools_UploadFiles_longPath(t *testing.T) {
+	uploadDir := t.TempDir()
+	for range 6 {
+		uploadDir = filepath.Join(uploadDir, strings.Repeat("d", 50))
+	}
+
+	testTools := New()
+	testTools.AllowedFileTypes = []string{"*"}
+	testTools.MetadataSidecars = true
+
+	req := newMultipartRequest(t, map[string][]byte{"a.txt": []byte("content")})
+	files, err := testTools.UploadFiles(req, uploadDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(longPath(filepath.Join(uploadDir, files[0].NewFileName))); err != nil || string(data) != "content" {
+		t.Errorf("expected the file to be stored, got %q (%v)", data, err)
+	}
+}
+
+// TestTools_UploadFiles_reservedName tests that files named after reserved device
+// names are stored under a regular name.
+func TestTools_UploadFiles_reservedName(t *testing.T) {
+	uploadDir := t.TempDir()
+	testTools := New()
+	testTools.AllowedFileTypes = []string{"*"}
+
+	req := newMultipartRequest(t, map[string][]byte{"nul.txt": []byte("content")})
+	files, err := testTools.UploadFiles(req, uploadDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(uploadDir, files[0].NewFileName)); err != nil || string(data) != "content" {
+		t.Errorf("expected the file to be stored as %s, got %q (%v)", files[0].NewFileName, data, err)
+	}
+}
+
+// TestTools_CollisionPolicy_caseInsensitive tests that names differing only in
+// case collide on Windows without CaseInsensitiveNames.
+func TestTools_CollisionPolicy_caseInsensitive(t *testing.T) {
+	uploadDir := t.TempDir()
+	os.WriteFile(filepath.Join(uploadDir, "Photo.txt"), []byte("first"), 0644)
+
+	testTools := New()
+	testTools.AllowedFileTypes = []string{"*"}
+	testTools.CollisionPolicy = CollisionSuffix
+
+	req := newMultipartRequest(t, map[string][]byte{"photo.txt": []byte("second")})
+	files, err := testTools.UploadFiles(req, uploadDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].NewFileName != "photo-1.txt" {
+		t.Errorf("expected the file to be stored as photo-1.txt, got %v", files)
+	}
+}
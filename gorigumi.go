@@ -2,29 +2,47 @@ package gorigumi
 
 import (
 	"bytes"
-	"crypto/rand"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"iter"
+	"log/slog"
+	"mime"
 	"mime/multipart"
 	"net/http"
+	"net/textproto"
 	"os"
 	"path/filepath"
-	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/drunkleen/gorigumi/download"
+	"github.com/drunkleen/gorigumi/jsonx"
+	"github.com/drunkleen/gorigumi/random"
+	"github.com/drunkleen/gorigumi/slug"
+	"github.com/drunkleen/gorigumi/upload"
+	"github.com/drunkleen/gorigumi/urlx"
 )
 
 const (
-	// randomStringSource is used to generate random strings
-	// it is inlcuded in the GenerateRandomString method
-	randomStringSource string = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_"
-
 	// defaultMaxFileSize is the default maximum file size in bytes
 	// it is inlcuded in the UploadFiles method
 	defaultMaxFileSize int = 512 * 1024 * 1024 // default to 512MB
+
+	// defaultRemoteTimeout is the default timeout of the requests to remotes
+	// it is inlcuded in the JSONPushToRemote method
+	defaultRemoteTimeout time.Duration = 30 * time.Second
 )
 
+// ErrUploadTruncated is wrapped by the errors of uploads whose received size
+// differs from the declared or expected one, e.g. because the client lied
+// about it or the connection was cut. Nothing is stored for such files.
+var ErrUploadTruncated = errors.New("upload truncated")
+
 // Tools is the type used to instantiate this module.
 // Any variable of this type will have access to all methods with receiver *Tools
 type Tools struct {
@@ -38,104 +56,451 @@ type Tools struct {
 	// AllowUnknownFields is a boolean that indicates if unknown fields
 	// are allowed in JSON
 	AllowUnknownFields bool
+	// FieldNaming names the JSON fields of structs without a json tag name
+	// in the responses of JSONWrite. Default to NamingDefault, the Go names
+	FieldNaming FieldNaming
+	// JSONIndent is the indentation of the responses of JSONWrite and JSONError,
+	// e.g. two spaces, for human-facing endpoints. See PrettyJSONMiddleware to
+	// indent them on demand. Default to none, responses are compact
+	JSONIndent string
+	// TolerantFieldNames is a boolean that indicates if JSONRead and
+	// JSONReadPatch match the keys of objects to struct fields whatever their
+	// naming, e.g. user_id and userId both match the field UserID. Schemas
+	// are checked against the names of the fields
+	TolerantFieldNames bool
+	// MaxXMLSize is the maximum size of an XML document. Default to 1MB
+	MaxXMLSize int
+	// MaxYAMLSize is the maximum size of a YAML document. Default to 1MB
+	MaxYAMLSize int
+	// MaxBatchOperations is the maximum number of operations accepted
+	// in a single batch request. Default to 50
+	MaxBatchOperations int
+	// Logger is the logger used by background work such as goroutines
+	// started with Go. Default to slog.Default()
+	Logger *slog.Logger
+	// Clock is the source of time of time-dependent features.
+	// Default to SystemClock
+	Clock Clock
+	// StreamUploads is a boolean that indicates if uploads are written to
+	// disk part by part as they arrive, instead of being parsed into memory
+	// and temporary files first
+	StreamUploads bool
+	// MaxFilesPerRequest is the maximum number of files accepted in a
+	// single upload request. Default to no limit
+	MaxFilesPerRequest int
+	// MaxFileSizePerFile is the maximum size in bytes of each uploaded
+	// file. Default to no limit other than MaxFileSize
+	MaxFileSizePerFile int
+	// AllowedExtensions is the list of allowed file name extensions,
+	// e.g. ".png". Default to any extension
+	AllowedExtensions []string
+	// RequireMatchingExtension is a boolean that indicates if the extension
+	// of an uploaded file must match its sniffed file type
+	RequireMatchingExtension bool
+	// ImageOptions configures the processing of uploaded images. Default
+	// to nil, images are stored as uploaded
+	ImageOptions *ImageOptions
+	// CollisionPolicy decides what happens when a file that is not renamed
+	// is uploaded under an existing name. Default to CollisionOverwrite
+	CollisionPolicy CollisionPolicy
+	// CaseInsensitiveNames is a boolean that indicates if names differing only
+	// in case collide, e.g. Photo.png and photo.png, as on Windows, where it is
+	// always the case, and macOS. Set it when stored files are synced to such
+	// systems. Default to false
+	CaseInsensitiveNames bool
+	// SymlinkPolicy decides how symbolic links are handled in the paths of
+	// served files and in upload directories. Default to SymlinkContain
+	SymlinkPolicy SymlinkPolicy
+	// MetadataSidecars is a boolean that indicates if a .json file holding
+	// the FileMetadata of each stored file is written to the metadata
+	// directory next to the upload directory, see FileMetadata
+	MetadataSidecars bool
+	// Scanner scans every uploaded file for malware before it is moved to its
+	// name. Flagged files are moved to the quarantine instead, see
+	// ListQuarantined. Default to nil, files are not scanned
+	Scanner Scanner
+	// QuarantineDir is the directory files flagged by the Scanner are moved to,
+	// on the same file system as the upload directories. TokenSecret must be
+	// set to sign their reports. Default to the upload directory suffixed with
+	// .quarantine, e.g. uploads.quarantine next to uploads
+	QuarantineDir string
+	// TokenSecret is the key signed tokens, such as email verification
+	// tokens, are signed with. It must be set to use them
+	TokenSecret []byte
+	// TokenStore records the single-use tokens that were used, such as
+	// password reset tokens. It must be set to use them
+	TokenStore TokenStore
+	// UploadConcurrency is the number of files of a form stored at once.
+	// Above one, all files are processed and their errors are joined.
+	// Default to one file at a time. It does not apply to StreamUploads
+	UploadConcurrency int
+	// MaxDirSize is the maximum total size in bytes of the files of an
+	// upload directory. Uploads exceeding it fail with a *QuotaError.
+	// Default to no limit
+	MaxDirSize int64
+	// MaxDirFiles is the maximum number of files of an upload directory.
+	// Uploads exceeding it fail with a *QuotaError. Default to no limit
+	MaxDirFiles int
+	// DefaultDisposition decides whether downloaded files are displayed by
+	// the client or saved. Default to DispositionAttachment
+	DefaultDisposition Disposition
+	// ShortLinkStore stores the links of CreateShortLink. It must be set to
+	// use short links
+	ShortLinkStore ShortLinkStore
+	// ResponseTypes is the list of media types Respond may encode responses
+	// to, in order of preference. The first one is used when the Accept header
+	// matches none of them. Supported types are application/json,
+	// application/xml and text/plain. Default to all of them, in that order
+	ResponseTypes []string
+	// Validator validates the values decoded by JSONRead, after their own
+	// Validate method. Default to nil, no validation other than Validatable
+	Validator Validator
+	// ValidateTags is a boolean that indicates if JSONRead validates decoded
+	// values according to their validate tags, see Validate. Default to false
+	ValidateTags bool
+	// SchemaValidator is the JSON Schema JSONRead validates request bodies
+	// against before decoding them. Default to nil, no schema
+	SchemaValidator *JSONSchema
+	// Envelope wraps the responses of JSONWrite, JSONError and RespondError in
+	// an organization-wide format, see StandardEnvelope. Default to nil, data
+	// is written as is and errors as a JSONResponse
+	Envelope Envelope
+	// ErrorReporter receives panics, 5xx errors and failed goroutines, e.g. to
+	// send them to an error tracking service. Default to NopErrorReporter
+	ErrorReporter ErrorReporter
+	// RemoteTimeout is the timeout of the requests of JSONPushToRemote, and
+	// of each of their attempts, and of the wait for the response once
+	// PushMultipartToRemote sent its body, so remotes that stop responding
+	// can't hold them forever. A negative value disables it. Default to 30
+	// seconds
+	RemoteTimeout time.Duration
+	// PushRetry is the retry policy of JSONPushToRemote, which then retries
+	// network errors, and 5xx and 429 responses after the delay of their
+	// Retry-After header if any, e.g. for flaky webhooks. Delays longer than
+	// the MaxBackoff of the policy are not waited for. Remotes should accept
+	// duplicate pushes. Default to nil, pushes are sent once
+	PushRetry *RetryPolicy
+	// RemoteBreaker guards the requests to remotes of JSONPushToRemote,
+	// JSONSendToRemote, JSONCall and PushMultipartToRemote, which fail with
+	// ErrCircuitOpen while the breaker of their host is open. Default to nil,
+	// no breaker
+	RemoteBreaker *CircuitBreaker
+	// Auditor receives security events, such as quarantined uploads. Default
+	// to logging them with Logger
+	Auditor Auditor
+	// Metrics counts the client errors of JSONRead and of uploads by route,
+	// see ClientErrors. Default to nil, nothing is counted
+	Metrics *Metrics
+
+	// dryRun is a boolean that indicates if uploads are checked without
+	// being stored, see WithDryRun
+	dryRun bool
 }
 
+// FieldNaming is the strategy naming the JSON fields of structs. It is an alias
+// of jsonx.Naming.
+type FieldNaming = jsonx.Naming
+
+const (
+	// NamingDefault names fields after the Go field, as encoding/json does
+	NamingDefault = jsonx.NamingDefault
+	// NamingSnakeCase names fields in snake_case, e.g. user_id
+	NamingSnakeCase = jsonx.NamingSnakeCase
+	// NamingCamelCase names fields in camelCase, e.g. userId
+	NamingCamelCase = jsonx.NamingCamelCase
+)
+
+// Disposition tells the client whether to display a downloaded file or to
+// save it. It is an alias of download.Disposition.
+type Disposition = download.Disposition
+
+const (
+	// DispositionAttachment asks the client to save the file
+	DispositionAttachment = download.DispositionAttachment
+	// DispositionInline asks the client to display the file, e.g. to preview
+	// PDFs and images in the browser
+	DispositionInline = download.DispositionInline
+)
+
 // New returns a new empty instance of Tools.
 func New() *Tools {
 	return &Tools{}
 }
 
-// GenerateRandomString generates a random string of length n.
-// The string is composed of characters from the predefined
-// randomStringSource, which includes uppercase and lowercase
-// letters, digits, and an underscore.
-func (t *Tools) GenerateRandomString(n int) string {
-	s, r := make([]rune, n), []rune(randomStringSource)
-	for i := range s {
-		p, _ := rand.Prime(rand.Reader, len(r))
-		x, y := p.Uint64(), uint64(len(r))
-		s[i] = r[x%y]
+// logger returns the Logger of the Tools struct, or slog.Default() if not set.
+func (t *Tools) logger() *slog.Logger {
+	if t.Logger != nil {
+		return t.Logger
 	}
+	return slog.Default()
+}
 
-	return string(s)
+// GenerateRandomString generates a random string of length n.
+// The string is composed of characters from random.Source,
+// which includes uppercase and lowercase letters, digits, and
+// an underscore.
+func (t *Tools) GenerateRandomString(n int) string {
+	return random.String(n)
 }
 
 // UploadedFile struct represents an uploaded file.
 // It contains the original file name, the new file name, and the file size.
+// Files uploaded to object storage also carry their object key and ETag, and
+// processed images the file names of their thumbnails.
 type UploadedFile struct {
 	OriginalFileName string
 	NewFileName      string
 	FileSize         int64
+	Key              string
+	ETag             string
+	Thumbnails       []string
+}
+
+// UploadResult holds the files stored by UploadForm and the non-file fields of the form.
+type UploadResult struct {
+	Files  []*UploadedFile
+	Fields map[string][]string
 }
 
 // UploadFiles parses a request and uploads all files in the request to the
 // directory specified by uploadDir. It takes an optional boolean argument
 // rename, which, if true, will rename all uploaded files with a random filename.
 // The default value of rename is true. If MaxFileSize is not specified in the
-// Tools struct, the default value of 512MB is used.
+// Tools struct, the default value of 512MB is used. If StreamUploads is set,
+// every file is written to disk as it arrives and MaxFileSize applies to each
+// file instead of the whole form. MaxFilesPerRequest and MaxFileSizePerFile are
+// checked for all files before any of them is stored. If ImageOptions is set,
+// uploaded images are processed once stored, and if MetadataSidecars is set a
+// metadata sidecar is written for each stored file. Reading the body and storing
+// files stop once the context of r is done. Use UploadForm to also get the
+// non-file fields of the form.
 func (t *Tools) UploadFiles(r *http.Request, uploadDir string, rename ...bool) ([]*UploadedFile, error) {
+	result, err := t.UploadForm(r, uploadDir, rename...)
+	return result.Files, err
+}
+
+// UploadForm uploads all files in the request like UploadFiles and also returns
+// the non-file fields of the form, such as a title or an album id, so handlers
+// do not have to parse the form again. The returned result is never nil; on
+// error it holds the files stored so far. If StreamUploads is set, fields are
+// read as they arrive and their values may not exceed 10MB in total.
+func (t *Tools) UploadForm(r *http.Request, uploadDir string, rename ...bool) (*UploadResult, error) {
 	renameFile := true
 	if len(rename) > 0 {
 		renameFile = rename[0]
 	}
+	return t.uploadForm(r.Context(), r, uploadDir, renameFile, fileFilter{})
+}
+
+// UploadFieldOptions configures UploadFilesFromField.
+type UploadFieldOptions struct {
+	// KeepFileName is a boolean that indicates if files keep their original
+	// name instead of being renamed with a random filename
+	KeepFileName bool
+	// RejectOtherFields is a boolean that indicates if files sent in other
+	// fields fail the upload instead of being ignored
+	RejectOtherFields bool
+}
+
+// UploadFilesFromField uploads the files of the form field named field like
+// UploadFiles, so forms with several distinct file inputs can be handled one
+// field at a time. Files of other fields are ignored, or fail the upload before
+// any file is stored if RejectOtherFields is set. With StreamUploads, files are
+// stored as they arrive, so files preceding a rejected one are kept.
+func (t *Tools) UploadFilesFromField(r *http.Request, field, uploadDir string, opts ...UploadFieldOptions) ([]*UploadedFile, error) {
+	var options UploadFieldOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+
+	result, err := t.uploadForm(r.Context(), r, uploadDir, !options.KeepFileName, fileFilter{field: field, rejectOthers: options.RejectOtherFields})
+	return result.Files, err
+}
+
+// fileFilter selects the file fields of a form an upload stores files from.
+type fileFilter struct {
+	// field is the only field files are stored from, any field if empty
+	field string
+	// rejectOthers is a boolean that indicates if files of other fields are
+	// errors instead of being skipped
+	rejectOthers bool
+}
+
+// accept reports whether the files of the form field name are stored, or an
+// error if they are rejected.
+func (f fileFilter) accept(name string) (bool, error) {
+	if f.field == "" || name == f.field {
+		return true, nil
+	}
+	if f.rejectOthers {
+		return false, fmt.Errorf("unexpected file field %q", name)
+	}
+	return false, nil
+}
+
+// uploadForm implements UploadForm, UploadFilesFromField and UploadFilesCtx,
+// storing the files of the fields accepted by filter. Reading the body and
+// storing files stop once ctx is done. If the client goes away, the files
+// already stored are removed and the error wraps ErrClientDisconnected.
+func (t *Tools) uploadForm(ctx context.Context, r *http.Request, uploadDir string, renameFile bool, filter fileFilter) (result *UploadResult, err error) {
+	// the dry-run copy handles the errors itself, so they are counted once
+	if dry, ok := t.dryRunTools(ctx, r); ok {
+		return dry.uploadForm(ctx, r, uploadDir, renameFile, filter)
+	}
+
+	defer func() {
+		if err = disconnectError(r, err); errors.Is(err, ErrClientDisconnected) && result != nil {
+			t.removeStoredFiles(uploadDir, result.Files...)
+			result.Files = nil
+		}
+		t.countUploadError(r, err)
+	}()
 
-	var uploadedFiles []*UploadedFile
+	result = &UploadResult{Fields: make(map[string][]string)}
+	withBodyContext(ctx, r)
 
 	if t.MaxFileSize == 0 {
 		t.MaxFileSize = defaultMaxFileSize
 	}
 
-	if err := t.CreateDirIfNotExists(uploadDir); err != nil {
-		return nil, err
+	if !t.dryRun {
+		if err := t.CreateDirIfNotExists(uploadDir); err != nil {
+			return result, err
+		}
+	}
+
+	if t.StreamUploads {
+		return result, t.streamUploads(ctx, r, uploadDir, renameFile, 0, filter, result)
 	}
 
-	err := r.ParseMultipartForm(int64(t.MaxFileSize))
+	err = r.ParseMultipartForm(int64(t.MaxFileSize))
+	// the guard cutting off a slow client may also cancel the request context
+	if errors.Is(err, ErrUploadTooSlow) {
+		return result, err
+	}
+	if ctx.Err() != nil {
+		return result, ctx.Err()
+	}
+	if clientGone(r, err) {
+		return result, err
+	}
 	if err != nil {
-		return nil, errors.New("the uploaded files are too big")
+		return result, WithErrorCode(errors.New("the uploaded files are too big"), CodeFileTooLarge)
+	}
+
+	for name, values := range r.MultipartForm.Value {
+		result.Fields[name] = values
+	}
+
+	files := make(map[string][]*multipart.FileHeader)
+	for name, fHeaders := range r.MultipartForm.File {
+		ok, err := filter.accept(name)
+		if err != nil {
+			return result, err
+		}
+		if ok {
+			files[name] = fHeaders
+		}
+	}
+
+	if err := t.checkFileLimits(files); err != nil {
+		return result, err
+	}
+	if err := t.checkDirQuota(ctx, uploadDir, files); err != nil {
+		return result, err
+	}
+
+	if t.UploadConcurrency > 1 {
+		result.Files, err = t.uploadConcurrently(ctx, files, uploadDir, renameFile)
+		return result, err
 	}
 
-	for _, fHeaders := range r.MultipartForm.File {
+	for _, fHeaders := range files {
 		for _, hdr := range fHeaders {
-			uploadedFile, err := t.uploadCheck(hdr, uploadDir, renameFile)
+			uploadedFile, err := t.uploadCheck(ctx, hdr, uploadDir, renameFile)
 			if err != nil {
-				return uploadedFiles, err
+				return result, err
 			}
-			uploadedFiles = append(uploadedFiles, uploadedFile)
+			result.Files = append(result.Files, uploadedFile)
 		}
 	}
 
-	return uploadedFiles, nil
+	return result, nil
 }
 
 // UploadFile handles the upload of a single file from an HTTP request to the specified directory.
 // If the optional rename argument is true or not provided, the uploaded file is renamed with a
 // randomly generated filename. The function returns the details of the uploaded file or an error
 // if the upload fails. It enforces the maximum file size defined in the Tools struct or defaults
-// to 512MB if not specified.
+// to 512MB if not specified. Reading the body and storing the file stop once the context of r
+// is done.
 
 func (t *Tools) UploadFile(r *http.Request, uploadDir string, rename ...bool) (*UploadedFile, error) {
 	renameFile := true
 	if len(rename) > 0 {
 		renameFile = rename[0]
 	}
+	return t.uploadFile(r.Context(), r, uploadDir, renameFile)
+}
+
+// uploadFile implements UploadFile and UploadFileCtx. Reading the body and
+// storing the file stop once ctx is done. If the client goes away, the stored
+// file is removed and the error wraps ErrClientDisconnected.
+func (t *Tools) uploadFile(ctx context.Context, r *http.Request, uploadDir string, renameFile bool) (uploadedFile *UploadedFile, err error) {
+	// the dry-run copy handles the errors itself, so they are counted once
+	if dry, ok := t.dryRunTools(ctx, r); ok {
+		return dry.uploadFile(ctx, r, uploadDir, renameFile)
+	}
+
+	defer func() {
+		if err = disconnectError(r, err); errors.Is(err, ErrClientDisconnected) && uploadedFile != nil {
+			t.removeStoredFiles(uploadDir, uploadedFile)
+			uploadedFile = nil
+		}
+		t.countUploadError(r, err)
+	}()
 
-	var uploadedFile *UploadedFile
+	withBodyContext(ctx, r)
 
 	if t.MaxFileSize == 0 {
 		t.MaxFileSize = defaultMaxFileSize
 	}
 
-	if err := t.CreateDirIfNotExists(uploadDir); err != nil {
-		return nil, err
+	if !t.dryRun {
+		if err := t.CreateDirIfNotExists(uploadDir); err != nil {
+			return nil, err
+		}
 	}
 
-	err := r.ParseMultipartForm(int64(t.MaxFileSize))
+	if t.StreamUploads {
+		result := &UploadResult{Fields: make(map[string][]string)}
+		err := t.streamUploads(ctx, r, uploadDir, renameFile, 1, fileFilter{}, result)
+		if err != nil || len(result.Files) == 0 {
+			return nil, err
+		}
+		return result.Files[0], nil
+	}
+
+	err = r.ParseMultipartForm(int64(t.MaxFileSize))
+	// the guard cutting off a slow client may also cancel the request context
+	if errors.Is(err, ErrUploadTooSlow) {
+		return nil, err
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if clientGone(r, err) {
+		return nil, err
+	}
 	if err != nil {
-		return nil, errors.New("the uploaded file is too big")
+		return nil, WithErrorCode(errors.New("the uploaded file is too big"), CodeFileTooLarge)
 	}
 
-	for _, fileHeader := range r.MultipartForm.File {
-		uploadedFile, err = t.uploadCheck(fileHeader[0], uploadDir, renameFile)
+	for name, fileHeader := range r.MultipartForm.File {
+		if err := t.checkDirQuota(ctx, uploadDir, map[string][]*multipart.FileHeader{name: fileHeader[:1]}); err != nil {
+			return nil, err
+		}
+		uploadedFile, err = t.uploadCheck(ctx, fileHeader[0], uploadDir, renameFile)
 		if err != nil {
 			return uploadedFile, err
 		}
@@ -145,16 +510,39 @@ func (t *Tools) UploadFile(r *http.Request, uploadDir string, rename ...bool) (*
 
 }
 
+// checkFileLimits checks the number of files against MaxFilesPerRequest and the
+// size of each file against MaxFileSizePerFile.
+func (t *Tools) checkFileLimits(files map[string][]*multipart.FileHeader) error {
+	count := 0
+	for _, fHeaders := range files {
+		for _, hdr := range fHeaders {
+			count++
+			if t.MaxFilesPerRequest > 0 && count > t.MaxFilesPerRequest {
+				return WithErrorCode(fmt.Errorf("too many files in request, the maximum is %d", t.MaxFilesPerRequest), CodeTooManyFiles)
+			}
+			if t.MaxFileSizePerFile > 0 && hdr.Size > int64(t.MaxFileSizePerFile) {
+				return WithErrorCode(fmt.Errorf("file %q is too big, the maximum size is %d bytes", hdr.Filename, t.MaxFileSizePerFile), CodeFileTooLarge)
+			}
+		}
+	}
+	return nil
+}
+
 // uploadCheck parses a single file from an HTTP request and uploads it to the directory
 // specified by uploadDir. If the optional rename argument is true or not provided, the
 // uploaded file is renamed with a randomly generated filename. The function returns the
 // details of the uploaded file or an error if the upload fails. It enforces the maximum
-// file size defined in the Tools struct or defaults to 512MB if not specified.
+// file size defined in the Tools struct or defaults to 512MB if not specified. The copy
+// stops once ctx is done.
 func (t *Tools) uploadCheck(
-	hdr *multipart.FileHeader, uploadDir string, renameFile bool,
+	ctx context.Context, hdr *multipart.FileHeader, uploadDir string, renameFile bool,
 ) (*UploadedFile, error) {
 	var file UploadedFile
 
+	if err := checkDeclaredSize(hdr.Filename, hdr.Header, hdr.Size); err != nil {
+		return nil, err
+	}
+
 	inFile, err := hdr.Open()
 
 	if err != nil {
@@ -163,74 +551,232 @@ func (t *Tools) uploadCheck(
 	defer inFile.Close()
 
 	buff := make([]byte, 512)
-	_, err = inFile.Read(buff)
+	n, err := inFile.Read(buff)
+	if err != nil {
+		return nil, err
+	}
+
+	fileType, err := t.checkFileType(hdr.Filename, buff[:n])
 	if err != nil {
 		return nil, err
 	}
 
-	allowed := false
-	fileType := http.DetectContentType(buff)
+	_, err = inFile.Seek(0, 0)
+	if err != nil {
+		return nil, err
+	}
 
-	if len(t.AllowedFileTypes) > 0 {
-		for _, v := range t.AllowedFileTypes {
-			if strings.EqualFold(v, fileType) || strings.EqualFold(v, "*") {
-				allowed = true
-			}
+	file.NewFileName = t.newFileName(hdr.Filename, renameFile)
+	file.OriginalFileName = hdr.Filename
+
+	src := io.Reader(inFile)
+	if ctx.Done() != nil {
+		src = &contextReader{ctx: ctx, r: inFile}
+	}
+	err = t.storeFile(ctx, uploadDir, &file, src, func(size int64, err error) error {
+		if err == nil && size != hdr.Size {
+			return fmt.Errorf("%w: file %q: expected %d bytes, copied %d", ErrUploadTruncated, hdr.Filename, hdr.Size, size)
 		}
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if !allowed {
-		return nil, errors.New("file type is not allowed")
+	if err := t.finishUpload(uploadDir, &file, fileType); err != nil {
+		return nil, err
 	}
 
-	_, err = inFile.Seek(0, 0)
+	return &file, nil
+}
+
+// storeFile copies src to a temporary file in uploadDir and moves it to the
+// NewFileName of file once check accepted the copy and the Scanner, if set,
+// found it clean, so partial or flagged files never appear under that name, even
+// if the server crashes mid-copy. check receives the number of bytes copied and
+// the copy error, and returns the error to report. Existing files are handled
+// according to CollisionPolicy, and the name the file was stored under and its
+// size are set in file. The temporary file is removed on failure. In dry-run
+// mode, src is read and checked but not written.
+func (t *Tools) storeFile(ctx context.Context, uploadDir string, file *UploadedFile, src io.Reader, check func(size int64, err error) error) error {
+	if t.dryRun {
+		size, err := io.Copy(io.Discard, src)
+		if check != nil {
+			err = check(size, err)
+		}
+		if err != nil {
+			return err
+		}
+		file.FileSize = size
+		return nil
+	}
+
+	if err := t.checkUploadDir(uploadDir); err != nil {
+		return err
+	}
+	uploadDir = longPath(uploadDir)
+	tmp, err := os.CreateTemp(uploadDir, ".upload-*")
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	if renameFile {
-		file.NewFileName = fmt.Sprintf("%s_%s", t.GenerateRandomString(32), filepath.Ext(hdr.Filename))
-	} else {
-		file.NewFileName = hdr.Filename
+	size, err := io.Copy(tmp, src)
+	if err == nil {
+		err = chmodFile(tmp, 0644)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if check != nil {
+		err = check(size, err)
+	}
+	file.FileSize = size
+	if err == nil {
+		err = t.scanUpload(ctx, uploadDir, tmp.Name(), file)
+	}
+	if err == nil {
+		file.NewFileName, err = t.placeFile(tmp.Name(), uploadDir, file.NewFileName)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
 	}
 
-	file.OriginalFileName = hdr.Filename
+	return nil
+}
 
-	var oFile *os.File
-	defer oFile.Close()
+// checkDeclaredSize compares the size declared by the Content-Length header of a
+// multipart part, if any, with size, the number of bytes received for it.
+func checkDeclaredSize(fileName string, header textproto.MIMEHeader, size int64) error {
+	declared := header.Get("Content-Length")
+	if declared == "" {
+		return nil
+	}
 
-	if oFile, err = os.Create(filepath.Join(uploadDir, file.NewFileName)); err != nil {
-		return nil, err
-	} else {
-		fileSize, err := io.Copy(oFile, inFile)
-		if err != nil {
-			return nil, err
+	n, err := strconv.ParseInt(declared, 10, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("file %q has an invalid Content-Length %q", fileName, declared)
+	}
+	if n != size {
+		return fmt.Errorf("%w: file %q: declared %d bytes, received %d", ErrUploadTruncated, fileName, n, size)
+	}
+
+	return nil
+}
+
+// checkFileType sniffs the content type of an uploaded file from its first bytes
+// and checks it against AllowedFileTypes, the extension of fileName against
+// AllowedExtensions and, if RequireMatchingExtension is set, that the extension
+// is one registered for the sniffed type. It returns the sniffed content type.
+func (t *Tools) checkFileType(fileName string, head []byte) (string, error) {
+	fileType := http.DetectContentType(head)
+	if !t.isAllowedFileType(fileType) {
+		return "", &fileTypeError{fileType, WithErrorCode(errors.New("file type is not allowed"), CodeUnsupportedMediaType)}
+	}
+
+	ext := strings.ToLower(filepath.Ext(fileName))
+	if len(t.AllowedExtensions) > 0 && !slices.ContainsFunc(t.AllowedExtensions, func(v string) bool {
+		return strings.EqualFold(v, ext)
+	}) {
+		return "", &fileTypeError{fileType, WithErrorCode(fmt.Errorf("file extension %q is not allowed", ext), CodeUnsupportedMediaType)}
+	}
+
+	if t.RequireMatchingExtension && !extensionMatchesType(ext, fileType) {
+		if ext == "" {
+			return "", &fileTypeError{fileType, WithErrorCode(fmt.Errorf("file without extension containing %s", fileType), CodeUnsupportedMediaType)}
 		}
-		file.FileSize = fileSize
+		return "", &fileTypeError{fileType, WithErrorCode(fmt.Errorf("%s file containing %s", ext, fileType), CodeUnsupportedMediaType)}
 	}
 
-	return &file, nil
+	return fileType, nil
+}
+
+// extensionMatchesType reports whether ext is registered for the media type of
+// fileType in the mime package tables.
+func extensionMatchesType(ext, fileType string) bool {
+	if ext == "" {
+		return false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(fileType)
+	if err != nil {
+		return false
+	}
+	if byExt, _, err := mime.ParseMediaType(mime.TypeByExtension(ext)); err == nil && byExt == mediaType {
+		return true
+	}
+
+	exts, _ := mime.ExtensionsByType(mediaType)
+	return slices.Contains(exts, ext)
+}
+
+// isAllowedFileType reports whether the detected fileType is listed in the
+// AllowedFileTypes of the Tools struct, or if all file types are allowed.
+func (t *Tools) isAllowedFileType(fileType string) bool {
+	for _, v := range t.AllowedFileTypes {
+		if strings.EqualFold(v, fileType) || strings.EqualFold(v, "*") {
+			return true
+		}
+	}
+	return false
+}
+
+// newFileName returns the name an uploaded file is stored under: a random
+// name keeping the extension of originalName if renameFile is true, or
+// originalName itself otherwise. Either way originalName is first sanitized
+// with upload.SanitizeFileName, so client names cannot escape the upload
+// directory.
+func (t *Tools) newFileName(originalName string, renameFile bool) string {
+	originalName = upload.SanitizeFileName(originalName)
+	if renameFile {
+		return fmt.Sprintf("%s_%s", t.GenerateRandomString(32), filepath.Ext(originalName))
+	}
+	return originalName
 }
 
 // DownloadFile sends a file to the client as an attachment.
 // It takes four parameters, a http.ResponseWriter, a *http.Request, the path to the file,
 // the filename of the file, and the name that the file should have when the client downloads it.
-// The method sets the Content-Disposition header so that the file is downloaded as an attachment.
-// It then uses http.ServeFile to send the file to the client.
+// The method sets the Content-Disposition header so that the file is downloaded as an attachment,
+// or displayed if DefaultDisposition is DispositionInline. Non-ASCII names are encoded
+// according to RFC 5987. It then uses http.ServeFile to send the file to the client,
+// with an ETag derived from the modification time and the size of the file unless one
+// is already set, so conditional requests for unchanged files are answered with 304.
+// The file cannot leave path, neither with ".." segments nor through symbolic links
+// resolving elsewhere, and is answered with 404 Not Found otherwise, according to the
+// SymlinkPolicy of the Tools struct.
 func (t *Tools) DownloadFile(
 	w http.ResponseWriter, r *http.Request,
 	path, fileName, name string,
 ) {
-	filePath := filepath.Join(path, fileName)
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", name))
+	filePath, err := t.downloadPath(path, fileName)
+	if err != nil {
+		_ = t.JSONError(w, errors.New("not found"), http.StatusNotFound)
+		return
+	}
+	download.File(w, r, filepath.Dir(filePath), filepath.Base(filePath), name, t.DefaultDisposition)
+}
 
-	http.ServeFile(w, r, filePath)
+// DownloadContent sends content to the client as a file named name, like
+// DownloadFile, but from any io.ReadSeeker, e.g. a file kept in memory, a
+// database or an object storage. It uses http.ServeContent, so Range and
+// conditional requests are supported; modtime sets the Last-Modified header
+// unless it is zero. Unless an ETag header is already set, the content is
+// hashed to compute one, which reads it entirely.
+func (t *Tools) DownloadContent(w http.ResponseWriter, r *http.Request, content io.ReadSeeker, name string, modtime time.Time) {
+	download.Content(w, r, content, name, modtime, t.DefaultDisposition)
 }
 
 // CreateDirIfNotExists creates a directory if it does not exist.
 // It takes a single parameter, the path to the directory.
-// The method returns an error if the directory cannot be created.
+// The method returns an error if the directory cannot be created, or one wrapping
+// ErrSymlink if the SymlinkPolicy is SymlinkRefuse and the path goes through a
+// symbolic link.
 func (t *Tools) CreateDirIfNotExists(path string) error {
+	if err := t.checkUploadDir(path); err != nil {
+		return err
+	}
+	path = longPath(path)
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		err = os.MkdirAll(path, 0755)
 		if err != nil {
@@ -246,20 +792,34 @@ func (t *Tools) CreateDirIfNotExists(path string) error {
 // error if the input string is empty or if the resulting slug
 // is empty due to invalid characters.
 func (t *Tools) ConvertToSlug(s string) (string, error) {
-	if s == "" {
-		return "", errors.New("string is empty")
-	}
-	regex := regexp.MustCompile(`[^a-z\d]+`)
-
-	slug := strings.Trim(
-		regex.ReplaceAllString(strings.ToLower(s), "-"), "-",
-	)
+	return slug.Make(s)
+}
 
-	if len(slug) == 0 {
-		return "", errors.New("not valid string characters")
+// NormalizeURLOptions configures NormalizeURL.
+// It is an alias of urlx.Options.
+type NormalizeURLOptions = urlx.Options
+
+// NormalizeURL returns raw in a canonical form, so equivalent URLs compare
+// equal, e.g. to deduplicate bookmarks or cache keys. A missing scheme
+// defaults to https, the scheme and host are lowercased, internationalized
+// hosts are converted to punycode, default ports, dot segments and the
+// fragment are removed, tracking parameters such as utm_source are stripped
+// and the remaining query parameters are sorted.
+func (t *Tools) NormalizeURL(raw string, opts ...NormalizeURLOptions) (string, error) {
+	var options NormalizeURLOptions
+	if len(opts) > 0 {
+		options = opts[0]
 	}
+	return urlx.Normalize(raw, options)
+}
 
-	return slug, nil
+// ValidateRedirectURL returns an error unless target is safe to redirect to,
+// e.g. the "next" parameter of a login page: a path on the same site, or an
+// http(s) URL whose host is in allowlist, where "*.example.com" matches the
+// subdomains of example.com. Protocol-relative URLs and backslash tricks are
+// rejected, preventing open redirects.
+func (t *Tools) ValidateRedirectURL(target string, allowlist []string) error {
+	return urlx.ValidateRedirect(target, allowlist)
 }
 
 // JSONResponse is a struct that is used to return a JSON response to the client.
@@ -268,11 +828,8 @@ func (t *Tools) ConvertToSlug(s string) (string, error) {
 // The Message field is a string that contains the error message if the response is an error.
 // The Data field is a generic type that contains the data to be returned in the response.
 // If the Data field is not set, it will be set to nil.
-type JSONResponse struct {
-	Error   bool   `json:"error,omitempty"`
-	Message string `json:"message,omitempty"`
-	Data    any    `json:"data,omitempty"`
-}
+// It is an alias of jsonx.Response.
+type JSONResponse = jsonx.Response
 
 // ReadJSON reads a JSON request body into the given destination.
 //
@@ -291,82 +848,146 @@ type JSONResponse struct {
 //
 // If the request body contains more than one JSON value, an error will be returned
 // with the message "body should'nt contain more than one json value".
+//
+// Bodies with a gzip or deflate Content-Encoding are decompressed as they are read,
+// and MaxJSONSize applies to the decompressed body, so compressed bodies cannot
+// expand past it. Other encodings are rejected with CodeUnsupportedMediaType.
+//
+// If the TolerantFieldNames field of the Tools struct is set to true, the keys of
+// objects match struct fields in any naming, e.g. both user_id and userId match
+// the field UserID.
+//
+// Once decoded, jsonData is validated by its Validate method if it implements
+// Validatable, then by its validate tags if the ValidateTags field of the Tools
+// struct is set to true, then by the Validator of the Tools struct if set. Validation
+// failures are returned as ValidationErrors, which JSONError writes with the list
+// of field errors, usually with the status 422 Unprocessable Entity.
+//
+// If the SchemaValidator field of the Tools struct is set, the body is first
+// validated against that JSON Schema, see JSONReadWithSchema.
 func (t *Tools) JSONRead(w http.ResponseWriter, r *http.Request, jsonData any) error {
-	maxBytes := 1024 * 1024 // 1MB
-	if t.MaxJSONSize != 0 {
-		maxBytes = t.MaxJSONSize
-	}
-	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
-
-	decoder := json.NewDecoder(r.Body)
-	if !t.AllowUnknownFields {
-		decoder.DisallowUnknownFields()
-	}
-	if err := decoder.Decode(jsonData); err != nil {
-		var syntaxError *json.SyntaxError
-		var unmarshalTypeError *json.UnmarshalTypeError
-		var invalidUnmarshalError *json.InvalidUnmarshalError
-
-		switch {
-		case errors.As(err, &syntaxError):
-			return fmt.Errorf("body contains badly-formed JSON (at position %d)", syntaxError.Offset)
-
-		case errors.Is(err, io.ErrUnexpectedEOF):
-			return errors.New("body contains badly-formed JSON")
-
-		case errors.As(err, &unmarshalTypeError):
-			if unmarshalTypeError.Field != "" {
-				return fmt.Errorf("body contains incorrect JSON type for field %q", unmarshalTypeError.Field)
-			}
-			return fmt.Errorf("body contains an invalid JSON type at position %d", unmarshalTypeError.Offset)
-
-		case errors.Is(err, io.EOF):
-			return errors.New("body must not be empty")
-
-		case strings.HasPrefix(err.Error(), "json: unknown field"):
-			fieldName := strings.TrimPrefix(err.Error(), "json: unknown field")
-			return fmt.Errorf("body contains unknown key %s", fieldName)
-
-		case err.Error() == "http: request body too large":
-			return fmt.Errorf("body must not be larger than %d bytes", maxBytes)
+	return t.JSONReadWithSchema(w, r, jsonData, t.SchemaValidator)
+}
 
-		case errors.As(err, &invalidUnmarshalError):
-			return fmt.Errorf("body contains badly-formed JSON (at position %d)", invalidUnmarshalError)
+// JSONReadWithSchema reads the JSON request body into jsonData like JSONRead,
+// after validating it against schema, e.g. the schema of the contract of the
+// endpoint. Every violation of the schema is returned at once as ValidationErrors,
+// with the code CodeValidationFailed, and nothing is decoded in that case. A nil
+// schema validates nothing.
+func (t *Tools) JSONReadWithSchema(w http.ResponseWriter, r *http.Request, jsonData any, schema *JSONSchema) error {
+	err := t.readJSON(w, r, jsonData, schema)
+	t.countReadError(r, err)
+	return err
+}
 
-		default:
+// readJSON reads and validates the JSON request body into jsonData.
+func (t *Tools) readJSON(w http.ResponseWriter, r *http.Request, jsonData any, schema *JSONSchema) error {
+	if schema != nil {
+		if err := t.checkSchema(w, r, schema, jsonData); err != nil {
 			return err
 		}
 	}
 
-	if err := decoder.Decode(&struct{}{}); err != io.EOF {
-		return errors.New("body should'nt contain more than one json value")
+	var err error
+	if t.TolerantFieldNames {
+		maxBytes := t.MaxJSONSize
+		if maxBytes == 0 {
+			maxBytes = jsonx.DefaultMaxBytes
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+		if err := jsonx.Decompress(r); err != nil {
+			return err
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+		err = jsonx.DecodeTolerant(r.Body, jsonData, maxBytes, t.AllowUnknownFields)
+	} else {
+		err = jsonx.Read(w, r, jsonData, t.MaxJSONSize, t.AllowUnknownFields)
 	}
-
-	return nil
-
+	if err != nil {
+		return err
+	}
+	return t.validate(jsonData)
 }
 
 // JSONWrite writes a JSON response to the client with the specified HTTP status code.
 // It takes an optional set of HTTP headers to include in the response. The function
 // marshals the provided data into JSON format and writes it to the response writer.
 // If marshaling the data fails, or if writing to the response writer fails, it returns an error.
-
+// The fields of structs without a json tag name are named according to the FieldNaming
+// field of the Tools struct.
+//
+// If the Envelope field of the Tools struct is set, data is wrapped by it, as the
+// Data of a JSONResponse unless it already is one. The response is indented with
+// the JSONIndent of the Tools struct if set, or on demand with PrettyJSONMiddleware.
 func (t *Tools) JSONWrite(w http.ResponseWriter, status int, data any, headers ...http.Header) error {
-	out, err := json.Marshal(data)
-	if err != nil {
-		return err
-	}
-	if len(headers) > 0 {
-		for key, value := range headers[0] {
-			w.Header()[key] = value
+	return jsonx.WriteIndent(w, status, t.responseData(w, status, data), t.jsonIndent(w), headers...)
+}
+
+// JSONPWrite writes data like JSONWrite, wrapped in a call of callback for the
+// legacy cross-origin consumers still requiring JSONP, e.g. with the callback
+// parameter of the query of the request. The callback must be a JavaScript
+// identifier or a dotted path of identifiers, such as jQuery123 or app.onData;
+// other names return an error with the code CodeBadRequest and nothing is
+// written, so it can be answered with JSONError. JSONP lets any site read the
+// response, so it must not be used for private data.
+func (t *Tools) JSONPWrite(w http.ResponseWriter, status int, data any, callback string, headers ...http.Header) error {
+	return jsonx.WriteJSONP(w, status, t.responseData(w, status, data), callback, headers...)
+}
+
+// responseData returns data wrapped by the Envelope of the Tools struct, if
+// set, with the fields named according to FieldNaming.
+func (t *Tools) responseData(w http.ResponseWriter, status int, data any) any {
+	if t.Envelope != nil {
+		res, ok := data.(JSONResponse)
+		if !ok {
+			res = JSONResponse{Data: data}
 		}
+		data = t.Envelope.Wrap(w, status, res)
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	if _, err = w.Write(out); err != nil {
-		return err
-	}
-	return nil
+	return jsonx.Rename(data, t.FieldNaming)
+}
+
+// JSONStream writes data as a JSON response like JSONWrite, but encodes it directly
+// to the response writer instead of marshaling the whole payload in memory first.
+// As the status code is sent before data is encoded, an encoding error leaves the
+// client with a truncated body.
+func (t *Tools) JSONStream(w http.ResponseWriter, status int, data any, headers ...http.Header) error {
+	return jsonx.Stream(w, status, data, headers...)
+}
+
+// JSONLinesWrite writes the values received on lines as newline-delimited JSON
+// (NDJSON), one value per line, until lines is closed, e.g. for export endpoints.
+// The response is flushed whenever no other value is waiting. If writing fails,
+// e.g. once the client went away, the remaining values are not received, so
+// producers should also stop on the cancellation of the request context.
+func (t *Tools) JSONLinesWrite(w http.ResponseWriter, lines <-chan any) error {
+	return jsonx.WriteLines(w, lines)
+}
+
+// JSONLinesRead reads newline-delimited JSON (NDJSON) from r, e.g. the body of a
+// request, and calls fn with each value, stopping at the first error it returns.
+// Blank lines are skipped and each line is limited to MaxJSONSize, or 1MB if it is
+// not set, so log-style ingestion handles bodies of any size without buffering them.
+func (t *Tools) JSONLinesRead(r io.Reader, fn func(json.RawMessage) error) error {
+	return jsonx.ReadLines(r, fn, t.MaxJSONSize)
+}
+
+// JSONReadStream reads a stream of concatenated JSON documents from r, e.g. the body
+// of a bulk-ingest request, and calls fn with each document, stopping at the first
+// error it returns. Unlike JSONRead, it accepts more than one top-level value, with
+// or without whitespace between them. Each document is limited to MaxJSONSize, or
+// 1MB if it is not set, while the stream may be of any size.
+func (t *Tools) JSONReadStream(r io.Reader, fn func(json.RawMessage) error) error {
+	return jsonx.ReadStream(r, fn, t.MaxJSONSize)
+}
+
+// JSONStreamArray writes the values of items as a JSON array, encoding each value as
+// it is produced, so large result sets are never held in memory. It is a function
+// rather than a method of Tools as methods can't have type parameters. The iteration
+// stops at the first encoding or write error, which leaves the client with a
+// truncated array.
+func JSONStreamArray[T any](w http.ResponseWriter, status int, items iter.Seq[T], headers ...http.Header) error {
+	return jsonx.StreamArray(w, status, items, headers...)
 }
 
 // JSONError writes an error response to the client with the specified HTTP status code.
@@ -374,17 +995,40 @@ func (t *Tools) JSONWrite(w http.ResponseWriter, status int, data any, headers .
 // is not provided, it defaults to 500 Internal Server Error. The function marshals
 // the error into a JSONResponse and writes it to the response writer. If marshaling
 // the error fails, or if writing to the response writer fails, it returns an error.
+//
+// Errors with a 5xx status code are sent to the ErrorReporter, without request
+// information; use RespondError to report them along with the request. Errors
+// caused by the client going away, see IsClientDisconnect, are written with the
+// status StatusClientClosedRequest instead, and never reported. The response is
+// wrapped by the Envelope of the Tools struct, if set.
 func (t *Tools) JSONError(w http.ResponseWriter, err error, status ...int) error {
-	statusCode := http.StatusInternalServerError
-	if len(status) > 0 {
-		statusCode = status[0]
+	statusCode := errorStatus(err, status)
+	if statusCode >= 500 {
+		t.reportError(context.Background(), nil, err, statusCode)
 	}
+	return t.writeJSONError(w, err, statusCode)
+}
 
-	var res JSONResponse
-	res.Error = true
-	res.Message = err.Error()
+// writeJSONError writes the error response of err with the given status code,
+// wrapped by the Envelope of the Tools struct if set.
+func (t *Tools) writeJSONError(w http.ResponseWriter, err error, status int) error {
+	if t.Envelope == nil {
+		return jsonx.WriteIndent(w, status, jsonx.ErrorResponse(err, status), t.jsonIndent(w))
+	}
+	return t.JSONWrite(w, status, jsonx.ErrorResponse(err, status))
+}
 
-	return t.JSONWrite(w, statusCode, res)
+// errorStatus returns the optional status code of the error response of err,
+// 500 if none is provided, or StatusClientClosedRequest if err is a client
+// disconnect.
+func errorStatus(err error, status []int) int {
+	if IsClientDisconnect(err) {
+		return StatusClientClosedRequest
+	}
+	if len(status) > 0 {
+		return status[0]
+	}
+	return http.StatusInternalServerError
 }
 
 // JSONPush sends a JSON request to the given URL.
@@ -397,7 +1041,17 @@ func (t *Tools) JSONError(w http.ResponseWriter, err error, status ...int) error
 //
 // If an http.Client is provided, it will be used to make the request. Otherwise, a new
 // http.Client will be created.
+//
+// If the PushRetry field of the Tools struct is set, failed pushes are retried
+// according to it, and the response of the last attempt is returned.
 func (t *Tools) JSONPushToRemote(url string, data any, client ...*http.Client) (*http.Response, int, error) {
+	return t.JSONPushToRemoteCtx(context.Background(), url, data, client...)
+}
+
+// JSONPushToRemoteCtx is like JSONPushToRemote, but the request is canceled once
+// ctx is done, e.g. when the request being served times out or the server shuts
+// down. The RemoteTimeout of the Tools struct applies within the deadline of ctx.
+func (t *Tools) JSONPushToRemoteCtx(ctx context.Context, url string, data any, client ...*http.Client) (*http.Response, int, error) {
 	jsonData, err := json.Marshal(data)
 	if err != nil {
 		return nil, 0, err
@@ -408,19 +1062,94 @@ func (t *Tools) JSONPushToRemote(url string, data any, client ...*http.Client) (
 		httpClient = client[0]
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonData))
 	if err != nil {
 		return nil, 0, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 
-	res, err := httpClient.Do(req)
+	return t.push(httpClient, req, nil)
+}
+
+// push sends req with httpClient within RemoteTimeout, retrying it according to
+// PushRetry if set, and returns the response with its body closed. If readBody
+// is not nil, it is called with the body of every response before it is closed.
+func (t *Tools) push(httpClient *http.Client, req *http.Request, readBody func(io.Reader) error) (*http.Response, int, error) {
+	if t.PushRetry != nil {
+		return t.pushWithRetry(req.Context(), httpClient, req, *t.PushRetry, readBody)
+	}
+
+	ctx, cancel := t.remoteContext(req.Context())
+	defer cancel()
+
+	res, err := t.doRemote(httpClient, req.WithContext(ctx))
 	if err != nil {
 		return nil, 0, err
 	}
 	defer res.Body.Close()
 
+	if readBody != nil {
+		if err := readBody(res.Body); err != nil {
+			return nil, 0, err
+		}
+	}
 	return res, res.StatusCode, nil
+}
+
+// pushWithRetry sends req with httpClient until it succeeds or policy gives up,
+// retrying network errors, and 5xx and 429 responses after their Retry-After
+// delay if any. Delays longer than the MaxBackoff of policy are not waited for.
+// The last response is returned with its body closed, whatever its status.
+func (t *Tools) pushWithRetry(ctx context.Context, httpClient *http.Client, req *http.Request, policy RetryPolicy, readBody func(io.Reader) error) (*http.Response, int, error) {
+	if policy.Clock == nil {
+		policy.Clock = t.clock()
+	}
+	if policy.Retryable == nil {
+		// attempts timing out after RemoteTimeout are retried, Retry stops
+		// once ctx is done
+		policy.Retryable = func(error) bool { return true }
+	}
+	policy = policy.withDefaults()
 
+	res, err := Retry(ctx, policy, func(ctx context.Context) (*http.Response, error) {
+		ctx, cancel := t.remoteContext(ctx)
+		defer cancel()
+
+		attempt := req.Clone(ctx)
+		if req.GetBody != nil {
+			attempt.Body, _ = req.GetBody()
+		}
+
+		res, err := t.doRemote(httpClient, attempt)
+		if errors.Is(err, ErrCircuitOpen) {
+			return nil, Permanent(err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if readBody != nil {
+			err = readBody(res.Body)
+		}
+		res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		if res.StatusCode < 500 && res.StatusCode != http.StatusTooManyRequests {
+			return res, nil
+		}
+		err = fmt.Errorf("remote responded with status %d", res.StatusCode)
+		if after := retryAfter(res.Header.Get("Retry-After"), policy.Clock.Now()); after > 0 {
+			if after > policy.MaxBackoff {
+				return res, Permanent(err)
+			}
+			return res, &RetryAfterError{Err: err, After: after}
+		}
+		return res, err
+	})
+	if res != nil {
+		return res, res.StatusCode, nil
+	}
+	return nil, 0, err
 }
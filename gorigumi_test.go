@@ -2,18 +2,25 @@ package gorigumi
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
-	"image"
-	"image/png"
+	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/textproto"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
+	"time"
+
+	"github.com/drunkleen/gorigumi/toolkittest"
 )
 
 // TestTools_GenerateRandomString tests the GenerateRandomString method by generating a random
@@ -46,9 +53,11 @@ var uploadTests = []struct {
 }
 
 // TestTools_uploadFiles tests the UploadFiles method by simulating a request with a single file in the form data.
-// The file is read from testdata/img.png and written to the pipe. The AllowedFileTypes is set to only allow PNG files.
+// The file is a PNG image generated with toolkittest and written to the pipe. The AllowedFileTypes is set to only allow PNG files.
 // The test checks that the file is uploaded and that the error returned is nil.
 func TestTools_uploadFiles(t *testing.T) {
+	uploadDir := t.TempDir()
+
 	for _, entry := range uploadTests {
 		pipeReader, pipeWriter := io.Pipe()
 		writer := multipart.NewWriter(pipeWriter)
@@ -60,24 +69,17 @@ func TestTools_uploadFiles(t *testing.T) {
 			defer writer.Close()
 
 			// create the form data field
-			part, err := writer.CreateFormFile("file", "./testdata/img.png")
+			part, err := writer.CreateFormFile("file", "img.png")
 			if err != nil {
 				t.Error(err)
 			}
 
-			f, err := os.Open("./testdata/img.png")
+			img, err := toolkittest.GenerateImage(64, 64, "png")
 			if err != nil {
-				t.Error(err)
+				t.Error("Error generating image", err)
 			}
-			defer f.Close()
 
-			img, _, err := image.Decode(f)
-			if err != nil {
-				t.Error("Error decoding image", err)
-			}
-
-			err = png.Encode(part, img)
-			if err != nil {
+			if _, err = part.Write(img); err != nil {
 				t.Error(err)
 			}
 		}()
@@ -89,16 +91,16 @@ func TestTools_uploadFiles(t *testing.T) {
 		testTools := New()
 		testTools.AllowedFileTypes = entry.alowedFileTypes
 
-		UploadedFiles, err := testTools.UploadFiles(request, "./testdata/uploads/", entry.renameFile)
+		UploadedFiles, err := testTools.UploadFiles(request, uploadDir, entry.renameFile)
 		if err != nil && !entry.errorExpected {
 			t.Error(err)
 		}
 
 		if !entry.errorExpected {
-			if _, err := os.Stat("./testdata/uploads/" + UploadedFiles[0].NewFileName); os.IsNotExist(err) {
+			if _, err := os.Stat(filepath.Join(uploadDir, UploadedFiles[0].NewFileName)); os.IsNotExist(err) {
 				t.Errorf("%s: expected file to be created: %s", entry.name, err.Error())
 			}
-			_ = os.Remove("./testdata/uploads/" + UploadedFiles[0].NewFileName)
+			_ = os.Remove(filepath.Join(uploadDir, UploadedFiles[0].NewFileName))
 		}
 
 		if entry.errorExpected && err == nil {
@@ -107,14 +109,315 @@ func TestTools_uploadFiles(t *testing.T) {
 
 		wg.Wait()
 	}
-	os.RemoveAll("./testdata/uploads/")
+}
+
+// uploadLimitTests is a slice of structs that hold the name of the test, the maximum number
+// of files per request, the maximum size per file, a boolean that indicates if uploads are
+// streamed, and a boolean that indicates if an error is expected
+var uploadLimitTests = []struct {
+	name               string
+	maxFilesPerRequest int
+	maxFileSizePerFile int
+	stream             bool
+	errorExpected      bool
+}{
+	{"within limits", 2, 1024, false, false},
+	{"too many files", 1, 0, false, true},
+	{"file too big", 0, 100, false, true},
+	{"within limits streamed", 2, 1024, true, false},
+	{"too many files streamed", 1, 0, true, true},
+	{"file too big streamed", 0, 100, true, true},
+}
+
+// TestTools_UploadForm tests that the non-file fields of the form are returned with the
+// stored files, whether uploads are streamed or not.
+func TestTools_UploadForm(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		testTools := New()
+		testTools.AllowedFileTypes = []string{"*"}
+		testTools.StreamUploads = stream
+
+		req := newMultipartRequest(t, map[string][]byte{"a.txt": []byte("some text")})
+		result, err := testTools.UploadForm(req, t.TempDir())
+		if err != nil {
+			t.Fatalf("stream %t: %v", stream, err)
+		}
+		if len(result.Files) != 1 || result.Files[0].OriginalFileName != "a.txt" {
+			t.Errorf("stream %t: unexpected files %v", stream, result.Files)
+		}
+		if title := result.Fields["title"]; len(title) != 1 || title[0] != "holiday" {
+			t.Errorf("stream %t: expected title field, got %v", stream, result.Fields)
+		}
+		if _, ok := result.Fields["file"]; ok {
+			t.Errorf("stream %t: expected file parts to be excluded from fields", stream)
+		}
+	}
+}
+
+// uploadFieldTests is a slice of structs that hold the name of the test, the field files
+// are uploaded from, the options, a boolean that indicates if uploads are streamed, the
+// expected number of files, and a boolean that indicates if an error is expected
+var uploadFieldTests = []struct {
+	name          string
+	field         string
+	opts          UploadFieldOptions
+	stream        bool
+	files         int
+	errorExpected bool
+}{
+	{"field only", "avatar", UploadFieldOptions{}, false, 1, false},
+	{"field only streamed", "avatar", UploadFieldOptions{}, true, 1, false},
+	{"missing field", "cover", UploadFieldOptions{}, false, 0, false},
+	{"reject others", "avatar", UploadFieldOptions{RejectOtherFields: true}, false, 0, true},
+	{"reject others streamed", "avatar", UploadFieldOptions{RejectOtherFields: true}, true, 1, true},
+	{"keep file name", "avatar", UploadFieldOptions{KeepFileName: true}, false, 1, false},
+}
+
+// TestTools_UploadFilesFromField tests that only the files of the requested field are
+// stored, and that files of other fields can be rejected.
+func TestTools_UploadFilesFromField(t *testing.T) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, field := range []string{"avatar", "documents", "documents"} {
+		part, err := writer.CreateFormFile(field, field+".txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write([]byte("some text"))
+	}
+	writer.Close()
+
+	for _, ft := range uploadFieldTests {
+		testTools := New()
+		testTools.AllowedFileTypes = []string{"*"}
+		testTools.StreamUploads = ft.stream
+
+		req := httptest.NewRequest("POST", "/", bytes.NewReader(body.Bytes()))
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+
+		files, err := testTools.UploadFilesFromField(req, ft.field, t.TempDir(), ft.opts)
+		if ft.errorExpected != (err != nil) {
+			t.Errorf("%s: expected error %t, got %v", ft.name, ft.errorExpected, err)
+		}
+		if len(files) != ft.files {
+			t.Fatalf("%s: expected %d files, got %d", ft.name, ft.files, len(files))
+		}
+		for _, f := range files {
+			if f.OriginalFileName != "avatar.txt" {
+				t.Errorf("%s: unexpected file %s", ft.name, f.OriginalFileName)
+			}
+			if ft.opts.KeepFileName != (f.NewFileName == "avatar.txt") {
+				t.Errorf("%s: unexpected stored name %s", ft.name, f.NewFileName)
+			}
+		}
+	}
+}
+
+// TestTools_uploadLimits tests that UploadFiles enforces MaxFilesPerRequest and
+// MaxFileSizePerFile, and that no file is stored when a buffered request is rejected.
+func TestTools_uploadLimits(t *testing.T) {
+	img, err := toolkittest.GenerateImage(64, 64, "png")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entry := range uploadLimitTests {
+		uploadDir := t.TempDir()
+
+		testTools := New()
+		testTools.AllowedFileTypes = []string{"image/png"}
+		testTools.StreamUploads = entry.stream
+		testTools.MaxFilesPerRequest = entry.maxFilesPerRequest
+		testTools.MaxFileSizePerFile = entry.maxFileSizePerFile
+
+		req := newMultipartRequest(t, map[string][]byte{"a.png": img, "b.png": img})
+		uploadedFiles, err := testTools.UploadFiles(req, uploadDir, false)
+
+		if !entry.errorExpected {
+			if err != nil {
+				t.Errorf("%s: %s", entry.name, err)
+			}
+			if len(uploadedFiles) != 2 {
+				t.Errorf("%s: expected 2 uploaded files, got %d", entry.name, len(uploadedFiles))
+			}
+			continue
+		}
+
+		if err == nil {
+			t.Errorf("%s: expected error but got none", entry.name)
+		}
+		if entries, _ := os.ReadDir(uploadDir); !entry.stream && len(entries) != 0 {
+			t.Errorf("%s: expected no files to be stored, got %d", entry.name, len(entries))
+		}
+	}
+}
+
+// fileTypeTests is a slice of structs that hold the name of the test, the uploaded file name,
+// a boolean that indicates if the content is a zip archive instead of a PNG image, the allowed
+// extensions, a boolean that indicates if the extension must match the sniffed type, and the
+// expected error message
+var fileTypeTests = []struct {
+	name              string
+	fileName          string
+	zip               bool
+	allowedExtensions []string
+	requireMatch      bool
+	expectedError     string
+}{
+	{"matching extension", "photo.png", false, []string{".png"}, true, ""},
+	{"uppercase extension", "photo.PNG", false, []string{".png"}, true, ""},
+	{"extension not allowed", "photo.gif", false, []string{".png", ".jpg"}, false, `file extension ".gif" is not allowed`},
+	{"mismatch allowed", "photo.jpg", false, nil, false, ""},
+	{"mismatching extension", "photo.jpg", false, nil, true, ".jpg file containing image/png"},
+	{"disguised archive", "photo.png", true, nil, true, ".png file containing application/zip"},
+	{"no extension", "photo", false, nil, true, "file without extension containing image/png"},
+}
+
+// TestTools_checkFileType tests that UploadFiles enforces AllowedExtensions and
+// RequireMatchingExtension.
+func TestTools_checkFileType(t *testing.T) {
+	img, err := toolkittest.GenerateImage(64, 64, "png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := append([]byte("PK\x03\x04"), toolkittest.GenerateFile(100, nil)...)
+
+	for _, ft := range fileTypeTests {
+		testTools := New()
+		testTools.AllowedFileTypes = []string{"*"}
+		testTools.AllowedExtensions = ft.allowedExtensions
+		testTools.RequireMatchingExtension = ft.requireMatch
+
+		data := img
+		if ft.zip {
+			data = archive
+		}
+
+		req := newMultipartRequest(t, map[string][]byte{ft.fileName: data})
+		_, err := testTools.UploadFiles(req, t.TempDir())
+
+		if ft.expectedError == "" {
+			if err != nil {
+				t.Errorf("%s: %s", ft.name, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != ft.expectedError {
+			t.Errorf("%s: expected error %q, got %v", ft.name, ft.expectedError, err)
+		}
+	}
+}
+
+// declaredSizeTests is a slice of structs that hold the name of the test, the Content-Length
+// declared for the file part, a boolean that indicates if the body is cut in the middle of
+// the part, a boolean that indicates if uploads are streamed, and a boolean that indicates
+// if an error wrapping ErrUploadTruncated is expected
+var declaredSizeTests = []struct {
+	name         string
+	declared     string
+	cut          bool
+	stream       bool
+	errTruncated bool
+}{
+	{"matching size", "2000", false, false, false},
+	{"matching size streamed", "2000", false, true, false},
+	{"lying size", "5000", false, false, true},
+	{"lying size streamed", "5000", false, true, true},
+	{"truncated streamed", "", true, true, true},
+}
+
+// TestTools_uploadDeclaredSize tests that uploads whose received size differs from the
+// declared one, or whose body is cut, fail without leaving files behind.
+func TestTools_uploadDeclaredSize(t *testing.T) {
+	data := toolkittest.GenerateFile(2000, []byte("some text "))
+
+	for _, dt := range declaredSizeTests {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", `form-data; name="file"; filename="a.txt"`)
+		if dt.declared != "" {
+			header.Set("Content-Length", dt.declared)
+		}
+		part, _ := writer.CreatePart(header)
+		part.Write(data)
+		writer.Close()
+
+		payload := body.Bytes()
+		if dt.cut {
+			payload = payload[:len(payload)/2]
+		}
+
+		req, _ := http.NewRequest("POST", "/", bytes.NewReader(payload))
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+
+		testTools := New()
+		testTools.AllowedFileTypes = []string{"*"}
+		testTools.StreamUploads = dt.stream
+
+		uploadDir := t.TempDir()
+		_, err := testTools.UploadFiles(req, uploadDir)
+
+		if dt.errTruncated != errors.Is(err, ErrUploadTruncated) {
+			t.Errorf("%s: expected truncation error %t, got %v", dt.name, dt.errTruncated, err)
+		}
+		if entries, _ := os.ReadDir(uploadDir); dt.errTruncated && len(entries) != 0 {
+			t.Errorf("%s: expected no file to be left behind, got %d", dt.name, len(entries))
+		}
+	}
+}
+
+// TestTools_storeFile tests that files are only visible under their name once completely
+// written, and that failed copies leave neither partial files nor temporary files.
+func TestTools_storeFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("previous"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	testTools := New()
+
+	ctx := context.Background()
+	err := testTools.storeFile(ctx, dir, &UploadedFile{NewFileName: "a.txt"}, &failingReader{data: []byte("partial")}, nil)
+	if err == nil {
+		t.Error("expected the failed copy to be reported")
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "a.txt")); string(data) != "previous" {
+		t.Errorf("expected the existing file to be untouched, got %q", data)
+	}
+
+	err = testTools.storeFile(ctx, dir, &UploadedFile{NewFileName: "b.txt"}, strings.NewReader("rejected"), func(size int64, err error) error {
+		return errors.New("rejected")
+	})
+	if err == nil || err.Error() != "rejected" {
+		t.Errorf("expected the check error to be returned, got %v", err)
+	}
+
+	file := &UploadedFile{NewFileName: "c.txt"}
+	err = testTools.storeFile(ctx, dir, file, strings.NewReader("complete"), nil)
+	if err != nil || file.FileSize != 8 {
+		t.Errorf("expected 8 bytes to be stored, got %d, %v", file.FileSize, err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if strings.Join(names, ",") != "a.txt,c.txt" {
+		t.Errorf("expected only a.txt and c.txt, got %v", names)
+	}
+	if info, _ := os.Stat(filepath.Join(dir, "c.txt")); info.Mode().Perm() != 0644 {
+		t.Errorf("expected mode 0644, got %v", info.Mode().Perm())
+	}
 }
 
 // TestTools_uploadSingleFile tests the UploadFile method by simulating a request
-// with a single file in the form data. The file is read from testdata/img.png
-// and written to the pipe. The AllowedFileTypes is set to only allow PNG files.
+// with a single file in the form data. The file is a PNG image generated with
+// toolkittest and written to the pipe. The AllowedFileTypes is set to only allow PNG files.
 // The test checks that the file is uploaded and that the error returned is nil.
 func TestTools_uploadSingleFile(t *testing.T) {
+	uploadDir := t.TempDir()
 	pipeReader, pipeWriter := io.Pipe()
 	writer := multipart.NewWriter(pipeWriter)
 
@@ -122,24 +425,17 @@ func TestTools_uploadSingleFile(t *testing.T) {
 		defer writer.Close()
 
 		// create the form data field
-		part, err := writer.CreateFormFile("file", "./testdata/img.png")
-		if err != nil {
-			t.Error(err)
-		}
-
-		f, err := os.Open("./testdata/img.png")
+		part, err := writer.CreateFormFile("file", "img.png")
 		if err != nil {
 			t.Error(err)
 		}
-		defer f.Close()
 
-		img, _, err := image.Decode(f)
+		img, err := toolkittest.GenerateImage(64, 64, "png")
 		if err != nil {
-			t.Error("Error decoding image", err)
+			t.Error("Error generating image", err)
 		}
 
-		err = png.Encode(part, img)
-		if err != nil {
+		if _, err = part.Write(img); err != nil {
 			t.Error(err)
 		}
 	}()
@@ -152,18 +448,15 @@ func TestTools_uploadSingleFile(t *testing.T) {
 
 	testTools.AllowedFileTypes = []string{"image/png"}
 
-	UploadedSingleFile, err := testTools.UploadFile(request, "./testdata/uploads/", true)
+	UploadedSingleFile, err := testTools.UploadFile(request, uploadDir, true)
 	if err != nil {
 		t.Error(err)
 	}
 
-	if _, err := os.Stat("./testdata/uploads/" + UploadedSingleFile.NewFileName); os.IsNotExist(err) {
+	if _, err := os.Stat(filepath.Join(uploadDir, UploadedSingleFile.NewFileName)); os.IsNotExist(err) {
 		t.Error("expected file to be created:", err.Error())
 	}
 
-	_ = os.Remove("./testdata/uploads/" + UploadedSingleFile.NewFileName)
-	os.RemoveAll("./testdata/uploads/")
-
 }
 
 func TestTools_DownloadFile(t *testing.T) {
@@ -171,7 +464,16 @@ func TestTools_DownloadFile(t *testing.T) {
 	responseRecorder := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/", nil)
 
-	testTools.DownloadFile(responseRecorder, req, "./testdata", "img.png", "rgb.png")
+	dir := t.TempDir()
+	img, err := toolkittest.GenerateImage(32, 32, "png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "img.png"), img, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	testTools.DownloadFile(responseRecorder, req, dir, "img.png", "rgb.png")
 
 	if responseRecorder.Code != http.StatusOK {
 		t.Error("Expected status code 200, but got", responseRecorder.Code)
@@ -188,8 +490,8 @@ func TestTools_DownloadFile(t *testing.T) {
 		t.Error("Expected content-disposition attachment; filename=\"rgb.png\", but got", result.Header.Get("Content-Disposition"))
 	}
 
-	if result.Header.Get("Content-Length") != "1422" {
-		t.Error("Expected content-length 100, but got", result.Header.Get("Content-Length"))
+	if result.Header.Get("Content-Length") != strconv.Itoa(len(img)) {
+		t.Errorf("Expected content-length %d, but got %s", len(img), result.Header.Get("Content-Length"))
 	}
 
 	if _, err := io.ReadAll(result.Body); err != nil {
@@ -202,15 +504,14 @@ func TestTools_DownloadFile(t *testing.T) {
 // second call does nothing and returns nil.
 func TestTools_CreateDirIfNotExists(t *testing.T) {
 	testTools := New()
-	if err := testTools.CreateDirIfNotExists("./testdata/test-dir"); err != nil {
+	dir := filepath.Join(t.TempDir(), "test-dir")
+	if err := testTools.CreateDirIfNotExists(dir); err != nil {
 		t.Error(err)
 	}
 
-	if err := testTools.CreateDirIfNotExists("./testdata/test-dir"); err != nil {
+	if err := testTools.CreateDirIfNotExists(dir); err != nil {
 		t.Error(err)
 	}
-
-	os.RemoveAll("./testdata/test-dir")
 }
 
 var slugTests = []struct {
@@ -347,6 +648,134 @@ func TestTools_JSONWrite(t *testing.T) {
 
 }
 
+// TestTools_FieldNaming tests that JSONWrite renames untagged fields and that
+// JSONRead accepts both namings when TolerantFieldNames is set.
+func TestTools_FieldNaming(t *testing.T) {
+	type account struct {
+		AccountID int
+		Message   string `json:"msg"`
+	}
+	testTools := New()
+	testTools.FieldNaming = NamingSnakeCase
+
+	responseRecorder := httptest.NewRecorder()
+	if err := testTools.JSONWrite(responseRecorder, http.StatusOK, account{AccountID: 1, Message: "foo"}); err != nil {
+		t.Fatalf("failed to write JSON: %v", err)
+	}
+	if body := responseRecorder.Body.String(); body != `{"account_id":1,"msg":"foo"}` {
+		t.Errorf("expected snake case fields, got %s", body)
+	}
+
+	testTools.TolerantFieldNames = true
+	for _, body := range []string{`{"account_id":2}`, `{"accountId":2}`, `{"AccountID":2}`} {
+		var acc account
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		if err := testTools.JSONRead(httptest.NewRecorder(), req, &acc); err != nil || acc.AccountID != 2 {
+			t.Errorf("%s: expected account 2, got %+v (%v)", body, acc, err)
+		}
+	}
+}
+
+// TestTools_JSONStream tests that JSONStream and JSONStreamArray write values that
+// decode like the ones of JSONWrite.
+func TestTools_JSONStream(t *testing.T) {
+	testTools := New()
+
+	responseRecorder := httptest.NewRecorder()
+	if err := testTools.JSONStream(responseRecorder, http.StatusOK, JSONResponse{Message: "foo"}); err != nil {
+		t.Errorf("failed to stream JSON: %v", err)
+	}
+	var res JSONResponse
+	if err := json.NewDecoder(responseRecorder.Body).Decode(&res); err != nil || res.Message != "foo" {
+		t.Errorf("expected message foo, got %+v (%v)", res, err)
+	}
+
+	responseRecorder = httptest.NewRecorder()
+	if err := JSONStreamArray(responseRecorder, http.StatusOK, slices.Values([]string{"a", "b"})); err != nil {
+		t.Errorf("failed to stream JSON array: %v", err)
+	}
+	var items []string
+	if err := json.NewDecoder(responseRecorder.Body).Decode(&items); err != nil || !slices.Equal(items, []string{"a", "b"}) {
+		t.Errorf("expected [a b], got %v (%v)", items, err)
+	}
+}
+
+// TestTools_JSONLines tests that values written by JSONLinesWrite are read back by
+// JSONLinesRead.
+func TestTools_JSONLines(t *testing.T) {
+	testTools := New()
+
+	lines := make(chan any, 3)
+	for i := range 3 {
+		lines <- JSONResponse{Message: strconv.Itoa(i)}
+	}
+	close(lines)
+
+	responseRecorder := httptest.NewRecorder()
+	if err := testTools.JSONLinesWrite(responseRecorder, lines); err != nil {
+		t.Fatalf("failed to write JSON lines: %v", err)
+	}
+
+	var messages []string
+	err := testTools.JSONLinesRead(responseRecorder.Body, func(raw json.RawMessage) error {
+		var res JSONResponse
+		if err := json.Unmarshal(raw, &res); err != nil {
+			return err
+		}
+		messages = append(messages, res.Message)
+		return nil
+	})
+	if err != nil || !slices.Equal(messages, []string{"0", "1", "2"}) {
+		t.Errorf("expected messages [0 1 2], got %v (%v)", messages, err)
+	}
+}
+
+// TestTools_JSONPWrite tests that data is wrapped in the callback with the fields named
+// according to FieldNaming, and that invalid callbacks are refused.
+func TestTools_JSONPWrite(t *testing.T) {
+	testTools := New()
+	testTools.FieldNaming = NamingSnakeCase
+
+	responseRecorder := httptest.NewRecorder()
+	data := struct{ UserID int }{UserID: 7}
+	if err := testTools.JSONPWrite(responseRecorder, http.StatusOK, data, "jQuery123"); err != nil {
+		t.Fatal(err)
+	}
+	if want := `/**/ typeof jQuery123 === 'function' && jQuery123({"user_id":7});`; responseRecorder.Body.String() != want {
+		t.Errorf("expected %s, got %s", want, responseRecorder.Body.String())
+	}
+
+	err := testTools.JSONPWrite(httptest.NewRecorder(), http.StatusOK, data, "alert(document.cookie)")
+	if ErrorCode(err) != CodeBadRequest {
+		t.Errorf("expected code %s, got %v", CodeBadRequest, err)
+	}
+}
+
+// TestTools_JSONReadStream tests that concatenated documents are read one by one,
+// and that documents larger than MaxJSONSize are refused.
+func TestTools_JSONReadStream(t *testing.T) {
+	testTools := New()
+	testTools.MaxJSONSize = 20
+
+	var messages []string
+	err := testTools.JSONReadStream(strings.NewReader(`{"message":"a"}{"message":"b"} {"message":"c"}`), func(raw json.RawMessage) error {
+		var res JSONResponse
+		if err := json.Unmarshal(raw, &res); err != nil {
+			return err
+		}
+		messages = append(messages, res.Message)
+		return nil
+	})
+	if err != nil || !slices.Equal(messages, []string{"a", "b", "c"}) {
+		t.Errorf("expected messages [a b c], got %v (%v)", messages, err)
+	}
+
+	err = testTools.JSONReadStream(strings.NewReader(`{"message":"a"}{"message":"too large"}`), func(json.RawMessage) error { return nil })
+	if ErrorCode(err) != CodePayloadTooLarge {
+		t.Errorf("expected code %s, got %v", CodePayloadTooLarge, err)
+	}
+}
+
 func TestTools_JSONError(t *testing.T) {
 	testTools := New()
 
@@ -422,3 +851,284 @@ func TestTools_JSONPushToRemote(t *testing.T) {
 		t.Errorf("failed to reach remote url: %v", err)
 	}
 }
+
+// TestTools_JSONPushToRemote_retry tests that failed pushes are retried with their body
+// according to PushRetry, and that long Retry-After delays are not waited for.
+func TestTools_JSONPushToRemote_retry(t *testing.T) {
+	var statuses []int
+	var bodies []string
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		body, _ := io.ReadAll(req.Body)
+		bodies = append(bodies, string(body))
+		header := make(http.Header)
+		status := statuses[len(bodies)-1]
+		if status == http.StatusTooManyRequests {
+			header.Set("Retry-After", "120")
+		}
+		return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader("")), Header: header}
+	})
+
+	testTools := New()
+	testTools.PushRetry = &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	statuses = []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK}
+	_, status, err := testTools.JSONPushToRemote("http://example.com/hook", map[string]int{"id": 1}, client)
+	if err != nil || status != http.StatusOK || len(bodies) != 3 || bodies[2] != `{"id":1}` {
+		t.Errorf("expected the push to succeed on the third attempt, got %d after %v (%v)", status, bodies, err)
+	}
+
+	bodies, statuses = nil, []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusOK}
+	_, status, err = testTools.JSONPushToRemote("http://example.com/hook", map[string]int{"id": 1}, client)
+	if err != nil || status != http.StatusInternalServerError || len(bodies) != 3 {
+		t.Errorf("expected the last response after 3 attempts, got %d after %d attempts (%v)", status, len(bodies), err)
+	}
+
+	bodies, statuses = nil, []int{http.StatusTooManyRequests, http.StatusOK}
+	_, status, _ = testTools.JSONPushToRemote("http://example.com/hook", map[string]int{"id": 1}, client)
+	if status != http.StatusTooManyRequests || len(bodies) != 1 {
+		t.Errorf("expected no retry after a long Retry-After, got %d after %d attempts", status, len(bodies))
+	}
+
+	bodies, statuses = nil, []int{http.StatusBadRequest, http.StatusOK}
+	_, status, _ = testTools.JSONPushToRemote("http://example.com/hook", map[string]int{"id": 1}, client)
+	if status != http.StatusBadRequest || len(bodies) != 1 {
+		t.Errorf("expected no retry of client errors, got %d after %d attempts", status, len(bodies))
+	}
+}
+
+// benchSizes are the payload sizes used by the upload and JSON benchmarks
+var benchSizes = []struct {
+	name string
+	size int
+}{
+	{"1KB", 1024},
+	{"64KB", 64 * 1024},
+	{"1MB", 1024 * 1024},
+}
+
+// BenchmarkTools_UploadFiles measures buffered and streamed uploads of a small image
+// and of larger generated files.
+func BenchmarkTools_UploadFiles(b *testing.B) {
+	img, err := toolkittest.GenerateImage(64, 64, "png")
+	if err != nil {
+		b.Fatal(err)
+	}
+	files := map[string][]byte{
+		"small": img,
+		"large": toolkittest.GenerateFile(8*1024*1024, nil),
+	}
+
+	for _, name := range []string{"small", "large"} {
+		for _, stream := range []bool{false, true} {
+			b.Run(fmt.Sprintf("%s/stream=%t", name, stream), func(b *testing.B) {
+				uploadDir := b.TempDir()
+				body, contentType := newMultipartBody(b, map[string][]byte{name + ".bin": files[name]})
+
+				testTools := New()
+				testTools.AllowedFileTypes = []string{"*"}
+				testTools.StreamUploads = stream
+
+				b.SetBytes(int64(len(files[name])))
+				b.ReportAllocs()
+				b.ResetTimer()
+				for range b.N {
+					req, _ := http.NewRequest("POST", "/", bytes.NewReader(body))
+					req.Header.Set("Content-Type", contentType)
+
+					uploadedFiles, err := testTools.UploadFiles(req, uploadDir)
+					if err != nil {
+						b.Fatal(err)
+					}
+					if req.MultipartForm != nil {
+						req.MultipartForm.RemoveAll()
+					}
+					os.Remove(filepath.Join(uploadDir, uploadedFiles[0].NewFileName))
+				}
+			})
+		}
+	}
+}
+
+// benchJSONPayload returns a JSON payload of roughly size bytes.
+func benchJSONPayload(size int) map[string][]string {
+	items := make([]string, size/64)
+	for i := range items {
+		items[i] = strings.Repeat("x", 60)
+	}
+	return map[string][]string{"items": items}
+}
+
+// BenchmarkTools_JSONRead measures JSONRead for payloads of different sizes.
+func BenchmarkTools_JSONRead(b *testing.B) {
+	for _, bs := range benchSizes {
+		b.Run(bs.name, func(b *testing.B) {
+			body, _ := json.Marshal(benchJSONPayload(bs.size))
+			testTools := New()
+			testTools.MaxJSONSize = 2 * len(body)
+
+			b.SetBytes(int64(len(body)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				req, _ := http.NewRequest("POST", "/", bytes.NewReader(body))
+				var decoded map[string][]string
+				if err := testTools.JSONRead(httptest.NewRecorder(), req, &decoded); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkTools_JSONWrite measures JSONWrite for payloads of different sizes.
+func BenchmarkTools_JSONWrite(b *testing.B) {
+	for _, bs := range benchSizes {
+		b.Run(bs.name, func(b *testing.B) {
+			payload := benchJSONPayload(bs.size)
+			testTools := New()
+
+			b.SetBytes(int64(bs.size))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				if err := testTools.JSONWrite(httptest.NewRecorder(), http.StatusOK, payload); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkTools_ConvertToSlug measures slug conversion of a typical title.
+func BenchmarkTools_ConvertToSlug(b *testing.B) {
+	testTools := New()
+
+	b.ReportAllocs()
+	for range b.N {
+		if _, err := testTools.ConvertToSlug("Hello, World! 123 -- Go is Fun & Fast"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkTools_GenerateRandomString measures the generation of 32 character tokens.
+func BenchmarkTools_GenerateRandomString(b *testing.B) {
+	testTools := New()
+
+	b.ReportAllocs()
+	for range b.N {
+		testTools.GenerateRandomString(32)
+	}
+}
+
+// TestBenchmarkRegressions compares two saved benchmark runs and fails if a benchmark
+// got slower or allocates more than the allowed threshold. It only runs when the
+// GORIGUMI_BENCH_BASELINE and GORIGUMI_BENCH_CURRENT environment variables point to
+// the outputs of go test -bench -benchmem; GORIGUMI_BENCH_THRESHOLD defaults to 0.1.
+func TestBenchmarkRegressions(t *testing.T) {
+	baselinePath, currentPath := os.Getenv("GORIGUMI_BENCH_BASELINE"), os.Getenv("GORIGUMI_BENCH_CURRENT")
+	if baselinePath == "" || currentPath == "" {
+		t.Skip("GORIGUMI_BENCH_BASELINE and GORIGUMI_BENCH_CURRENT are not set")
+	}
+
+	threshold := 0.1
+	if v := os.Getenv("GORIGUMI_BENCH_THRESHOLD"); v != "" {
+		var err error
+		if threshold, err = strconv.ParseFloat(v, 64); err != nil {
+			t.Fatalf("invalid threshold %q: %s", v, err)
+		}
+	}
+
+	parse := func(path string) map[string]toolkittest.BenchmarkResult {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		results, err := toolkittest.ParseBenchmarks(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return results
+	}
+
+	for _, d := range toolkittest.CompareBenchmarks(parse(baselinePath), parse(currentPath), threshold) {
+		if d.Regressed {
+			t.Errorf("regression: %s", d)
+		} else {
+			t.Log(d)
+		}
+	}
+}
+
+// TestTools_NormalizeURL tests that NormalizeURL applies its options.
+func TestTools_NormalizeURL(t *testing.T) {
+	testTools := New()
+
+	got, err := testTools.NormalizeURL("Bücher.example/?utm_source=x&b=2&a=1")
+	if err != nil || got != "https://xn--bcher-kva.example/?a=1&b=2" {
+		t.Errorf("expected normalized URL, got %q, %v", got, err)
+	}
+
+	got, err = testTools.NormalizeURL("example.com", NormalizeURLOptions{DefaultScheme: "http"})
+	if err != nil || got != "http://example.com/" {
+		t.Errorf("expected http URL, got %q, %v", got, err)
+	}
+}
+
+// TestTools_ValidateRedirectURL tests that ValidateRedirectURL rejects open redirects.
+func TestTools_ValidateRedirectURL(t *testing.T) {
+	testTools := New()
+
+	if err := testTools.ValidateRedirectURL("/account", nil); err != nil {
+		t.Errorf("expected local path to be allowed, got %v", err)
+	}
+	if err := testTools.ValidateRedirectURL("https://evil.com/", []string{"example.com"}); err == nil {
+		t.Error("expected foreign host to be rejected")
+	}
+}
+
+// TestTools_DownloadContent tests that content is served as an attachment with range support.
+func TestTools_DownloadContent(t *testing.T) {
+	testTools := New()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Range", "bytes=0-4")
+	rr := httptest.NewRecorder()
+
+	testTools.DownloadContent(rr, req, strings.NewReader("hello world"), "notes.txt", time.Time{})
+
+	if rr.Code != http.StatusPartialContent || rr.Body.String() != "hello" {
+		t.Errorf("expected partial content, got %d %q", rr.Code, rr.Body)
+	}
+	if cd := rr.Header().Get("Content-Disposition"); cd != `attachment; filename="notes.txt"` {
+		t.Errorf("unexpected Content-Disposition %q", cd)
+	}
+	if lm := rr.Header().Get("Last-Modified"); lm != "" {
+		t.Errorf("expected no Last-Modified for a zero modtime, got %q", lm)
+	}
+}
+
+// TestTools_DefaultDisposition tests that files are served inline with RFC 5987 encoded names.
+func TestTools_DefaultDisposition(t *testing.T) {
+	testTools := &Tools{DefaultDisposition: DispositionInline}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a1b2.pdf"), []byte("%PDF-1.4"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	testTools.DownloadFile(rr, httptest.NewRequest("GET", "/", nil), dir, "a1b2.pdf", "café.pdf")
+
+	expected := `inline; filename="caf_.pdf"; filename*=UTF-8''caf%C3%A9.pdf`
+	if cd := rr.Header().Get("Content-Disposition"); cd != expected {
+		t.Errorf("expected %s, got %s", expected, cd)
+	}
+
+	rr = httptest.NewRecorder()
+	testTools.DownloadContent(rr, httptest.NewRequest("GET", "/", nil), strings.NewReader("hello"), "notes.txt", time.Time{})
+	if cd := rr.Header().Get("Content-Disposition"); cd != `inline; filename="notes.txt"` {
+		t.Errorf("unexpected Content-Disposition %s", cd)
+	}
+}
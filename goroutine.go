@@ -0,0 +1,129 @@
+package gorigumi
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// RestartPolicy tells Go whether a finished goroutine is started again.
+type RestartPolicy int
+
+const (
+	// RestartNever runs the function once
+	RestartNever RestartPolicy = iota
+	// RestartOnPanic runs the function again after a panic
+	RestartOnPanic
+	// RestartOnFailure runs the function again after a panic or an error
+	RestartOnFailure
+	// RestartAlways runs the function again whenever it returns, until
+	// the context is done
+	RestartAlways
+)
+
+// GoOptions configures a goroutine started with Go.
+type GoOptions struct {
+	// Name identifies the goroutine in logs
+	Name string
+	// WaitGroup, if set, is incremented when the goroutine starts and
+	// decremented once it is finished for good
+	WaitGroup *sync.WaitGroup
+	// Restart is the restart policy. Default to RestartNever
+	Restart RestartPolicy
+	// MaxRestarts caps the number of restarts. Zero means no limit
+	MaxRestarts int
+	// RestartDelay is the delay before every restart
+	RestartDelay time.Duration
+}
+
+// PanicError is the error reported for a recovered panic.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+// Error returns the error message.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Go runs fn in a new goroutine with panic recovery. Panics are logged with the
+// Logger of the Tools struct and converted into a *PanicError. Panics and errors
+// of runs are sent to the ErrorReporter with a goroutine tag holding the name,
+// unless ctx is done. According to the
+// restart policy of the optional GoOptions, fn is started again after it returns,
+// until ctx is done or MaxRestarts is reached.
+//
+// The returned channel receives the result of the last run of fn (nil, its error
+// or a *PanicError) and is then closed.
+func (t *Tools) Go(ctx context.Context, fn func(ctx context.Context) error, opts ...GoOptions) <-chan error {
+	var options GoOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+
+	done := make(chan error, 1)
+	if options.WaitGroup != nil {
+		options.WaitGroup.Add(1)
+	}
+
+	go func() {
+		defer close(done)
+		if options.WaitGroup != nil {
+			defer options.WaitGroup.Done()
+		}
+
+		for restarts := 0; ; restarts++ {
+			err := t.runRecovered(ctx, fn, options.Name)
+			if err != nil && ctx.Err() == nil {
+				t.reportError(ctx, nil, err, 0, map[string]string{"goroutine": options.Name})
+			}
+			if !shouldRestart(options.Restart, err) || ctx.Err() != nil ||
+				(options.MaxRestarts > 0 && restarts >= options.MaxRestarts) {
+				done <- err
+				return
+			}
+
+			t.logger().Warn("restarting goroutine", "name", options.Name, "error", err, "restarts", restarts+1)
+
+			select {
+			case <-t.clock().After(options.RestartDelay):
+			case <-ctx.Done():
+				done <- err
+				return
+			}
+		}
+	}()
+
+	return done
+}
+
+// runRecovered runs fn and converts a panic into a *PanicError.
+func (t *Tools) runRecovered(ctx context.Context, fn func(ctx context.Context) error, name string) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			pe := &PanicError{Value: v, Stack: debug.Stack()}
+			t.logger().Error("recovered goroutine panic", "name", name, "panic", v, "stack", string(pe.Stack))
+			err = pe
+		}
+	}()
+
+	return fn(ctx)
+}
+
+// shouldRestart applies policy to the result of a run.
+func shouldRestart(policy RestartPolicy, err error) bool {
+	switch policy {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return err != nil
+	case RestartOnPanic:
+		_, panicked := err.(*PanicError)
+		return panicked
+	default:
+		return false
+	}
+}
@@ -0,0 +1,91 @@
+package gorigumi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// goTests is a slice of structs that hold the name of the test, the restart policy, the maximum
+// number of restarts, the number of runs that fail before success, a boolean that indicates if
+// runs fail with a panic, and the expected number of runs
+var goTests = []struct {
+	name         string
+	restart      RestartPolicy
+	maxRestarts  int
+	failures     int32
+	panics       bool
+	expectedRuns int32
+	errExpected  bool
+}{
+	{"success", RestartNever, 0, 0, false, 1, false},
+	{"panic without restart", RestartNever, 0, 1, true, 1, true},
+	{"restart on panic", RestartOnPanic, 0, 2, true, 3, false},
+	{"no restart on error", RestartOnPanic, 0, 2, false, 1, true},
+	{"restart on failure", RestartOnFailure, 0, 2, false, 3, false},
+	{"max restarts", RestartOnFailure, 1, 5, true, 2, true},
+}
+
+// TestTools_Go tests the Go method by running failing and panicking functions with the
+// different restart policies and checking the number of runs and the final result.
+func TestTools_Go(t *testing.T) {
+	testTools := New()
+	testTools.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	for _, gt := range goTests {
+		var runs atomic.Int32
+		var wg sync.WaitGroup
+
+		done := testTools.Go(context.Background(), func(ctx context.Context) error {
+			if runs.Add(1) <= gt.failures {
+				if gt.panics {
+					panic("boom")
+				}
+				return errors.New("failed")
+			}
+			return nil
+		}, GoOptions{Name: gt.name, WaitGroup: &wg, Restart: gt.restart, MaxRestarts: gt.maxRestarts})
+
+		wg.Wait()
+		err := <-done
+
+		if runs.Load() != gt.expectedRuns {
+			t.Errorf("%s: expected %d runs, got %d", gt.name, gt.expectedRuns, runs.Load())
+		}
+		if gt.errExpected && err == nil {
+			t.Errorf("%s: expected error but got none", gt.name)
+		}
+		if !gt.errExpected && err != nil {
+			t.Errorf("%s: %s", gt.name, err)
+		}
+
+		var pe *PanicError
+		if gt.panics && gt.errExpected && !errors.As(err, &pe) {
+			t.Errorf("%s: expected a *PanicError, got %v", gt.name, err)
+		}
+	}
+}
+
+// TestTools_GoCancel tests that a goroutine restarted forever stops once its context is done.
+func TestTools_GoCancel(t *testing.T) {
+	testTools := New()
+	testTools.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var runs atomic.Int32
+	done := testTools.Go(ctx, func(ctx context.Context) error {
+		if runs.Add(1) == 3 {
+			cancel()
+		}
+		return nil
+	}, GoOptions{Restart: RestartAlways})
+
+	<-done
+	if runs.Load() != 3 {
+		t.Errorf("expected 3 runs, got %d", runs.Load())
+	}
+}
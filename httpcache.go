@@ -0,0 +1,280 @@
+package gorigumi
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultMaxCachedBodySize is the default maximum size of cached bodies
+	// it is inlcuded in the CachingTransport type
+	defaultMaxCachedBodySize int64 = 10 * 1024 * 1024
+)
+
+// CachedResponse is a response stored by a CachingTransport.
+type CachedResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	// StoredAt is when the response was received or last revalidated
+	StoredAt time.Time
+}
+
+// CacheStore stores the responses of a CachingTransport. Implementations backed
+// by a shared cache let several instances of an application share responses.
+type CacheStore interface {
+	// Get returns the response stored under key, if any
+	Get(key string) (*CachedResponse, bool)
+	// Set stores res under key
+	Set(key string, res *CachedResponse)
+	// Delete removes the response stored under key
+	Delete(key string)
+}
+
+// MemoryCacheStore is a CacheStore keeping responses in memory. The zero value
+// is ready to use.
+type MemoryCacheStore struct {
+	mu        sync.Mutex
+	responses map[string]*CachedResponse
+}
+
+// Get implements CacheStore.
+func (s *MemoryCacheStore) Get(key string) (*CachedResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res, ok := s.responses[key]
+	return res, ok
+}
+
+// Set implements CacheStore.
+func (s *MemoryCacheStore) Set(key string, res *CachedResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.responses == nil {
+		s.responses = make(map[string]*CachedResponse)
+	}
+	s.responses[key] = res
+}
+
+// Delete implements CacheStore.
+func (s *MemoryCacheStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.responses, key)
+}
+
+// CachingTransport is a http.RoundTripper caching the responses of GET requests
+// in a CacheStore, e.g. to poll partner APIs without downloading unchanged data.
+// Responses are reused without a request while fresh according to their
+// Cache-Control max-age or Expires header. Stale responses carrying an ETag or
+// a Last-Modified header are revalidated with a conditional request, and reused
+// when the server answers 304 Not Modified. Responses marked no-store, varying
+// on other headers than Accept-Encoding, or larger than MaxBodySize are not
+// cached. Reused responses carry an X-Cache header set to HIT or REVALIDATED.
+type CachingTransport struct {
+	// Transport is the transport making the requests. Default to
+	// http.DefaultTransport
+	Transport http.RoundTripper
+	// Store is where responses are cached
+	Store CacheStore
+	// Clock is the source of time deciding the freshness of responses.
+	// Default to SystemClock
+	Clock Clock
+	// MaxBodySize is the maximum size of cached bodies. Default to 10MB
+	MaxBodySize int64
+}
+
+// RoundTrip implements http.RoundTripper.
+func (c *CachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := c.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	if req.Method != http.MethodGet || c.Store == nil {
+		return transport.RoundTrip(req)
+	}
+
+	key := cacheKey(req)
+	cached, ok := c.Store.Get(key)
+	reqDirectives := cacheDirectives(req.Header.Get("Cache-Control"))
+	if ok && !reqDirectives.has("no-cache") && c.fresh(cached) {
+		return cached.response(req, "HIT"), nil
+	}
+
+	// the request is only made conditional if the caller did not do it
+	conditional := ok && req.Header.Get("If-None-Match") == "" && req.Header.Get("If-Modified-Since") == ""
+	if conditional {
+		etag, modified := cached.Header.Get("ETag"), cached.Header.Get("Last-Modified")
+		conditional = etag != "" || modified != ""
+		if conditional {
+			req = req.Clone(req.Context())
+			if etag != "" {
+				req.Header.Set("If-None-Match", etag)
+			}
+			if modified != "" {
+				req.Header.Set("If-Modified-Since", modified)
+			}
+		}
+	}
+
+	res, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if conditional && res.StatusCode == http.StatusNotModified {
+		res.Body.Close()
+		updated := *cached
+		updated.Header = cached.Header.Clone()
+		for _, h := range []string{"Cache-Control", "Expires", "Date", "ETag", "Last-Modified"} {
+			if v := res.Header.Get(h); v != "" {
+				updated.Header.Set(h, v)
+			}
+		}
+		updated.StoredAt = c.now()
+		c.Store.Set(key, &updated)
+		return updated.response(req, "REVALIDATED"), nil
+	}
+
+	if res.StatusCode != http.StatusOK || !cacheable(res) {
+		if res.StatusCode != http.StatusNotModified {
+			c.Store.Delete(key)
+		}
+		return res, nil
+	}
+
+	return c.store(key, res)
+}
+
+// store reads the body of res and caches it under key, unless it is larger
+// than MaxBodySize. The returned response replaces res.
+func (c *CachingTransport) store(key string, res *http.Response) (*http.Response, error) {
+	maxSize := c.MaxBodySize
+	if maxSize == 0 {
+		maxSize = defaultMaxCachedBodySize
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxSize+1))
+	if err != nil {
+		res.Body.Close()
+		return nil, err
+	}
+	if int64(len(body)) > maxSize {
+		// too large to cache, the caller gets the whole body anyway
+		res.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), res.Body), res.Body}
+		return res, nil
+	}
+	res.Body.Close()
+
+	c.Store.Set(key, &CachedResponse{StatusCode: res.StatusCode, Header: res.Header.Clone(), Body: body, StoredAt: c.now()})
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	return res, nil
+}
+
+// fresh reports whether cached can be reused without revalidation.
+func (c *CachingTransport) fresh(cached *CachedResponse) bool {
+	directives := cacheDirectives(cached.Header.Get("Cache-Control"))
+	if directives.has("no-cache") {
+		return false
+	}
+
+	var lifetime time.Duration
+	if maxAge, ok := directives["max-age"]; ok {
+		seconds, err := strconv.Atoi(maxAge)
+		if err != nil {
+			return false
+		}
+		lifetime = time.Duration(seconds) * time.Second
+	} else if expires, err := http.ParseTime(cached.Header.Get("Expires")); err == nil {
+		date, err := http.ParseTime(cached.Header.Get("Date"))
+		if err != nil {
+			date = cached.StoredAt
+		}
+		lifetime = expires.Sub(date)
+	}
+
+	return c.now().Sub(cached.StoredAt) < lifetime
+}
+
+// now returns the current time of the Clock of the transport.
+func (c *CachingTransport) now() time.Time {
+	if c.Clock == nil {
+		return SystemClock.Now()
+	}
+	return c.Clock.Now()
+}
+
+// response returns a new response for req holding cached, with an X-Cache
+// header set to status.
+func (cached *CachedResponse) response(req *http.Request, status string) *http.Response {
+	header := cached.Header.Clone()
+	header.Set("X-Cache", status)
+	return &http.Response{
+		Status:        strconv.Itoa(cached.StatusCode) + " " + http.StatusText(cached.StatusCode),
+		StatusCode:    cached.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(cached.Body)),
+		ContentLength: int64(len(cached.Body)),
+		Request:       req,
+	}
+}
+
+// cacheable reports whether res may be stored.
+func cacheable(res *http.Response) bool {
+	if cacheDirectives(res.Header.Get("Cache-Control")).has("no-store") {
+		return false
+	}
+	for _, v := range res.Header.Values("Vary") {
+		for _, field := range strings.Split(v, ",") {
+			if field = strings.TrimSpace(field); field != "" && !strings.EqualFold(field, "Accept-Encoding") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// cacheKey returns the key of the responses to req. Requests with different
+// credentials never share responses.
+func cacheKey(req *http.Request) string {
+	key := req.URL.String()
+	if auth := req.Header.Get("Authorization"); auth != "" {
+		sum := sha256.Sum256([]byte(auth))
+		key += " " + hex.EncodeToString(sum[:8])
+	}
+	return key
+}
+
+// cacheControl holds the directives of a Cache-Control header.
+type cacheControl map[string]string
+
+// cacheDirectives parses a Cache-Control header.
+func cacheDirectives(header string) cacheControl {
+	directives := make(cacheControl)
+	for _, part := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name != "" {
+			directives[strings.ToLower(name)] = strings.Trim(value, `"`)
+		}
+	}
+	return directives
+}
+
+// has reports whether the directive name is present.
+func (c cacheControl) has(name string) bool {
+	_, ok := c[name]
+	return ok
+}
@@ -0,0 +1,70 @@
+package gorigumi
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/drunkleen/gorigumi/toolkittest"
+)
+
+// TestCachingTransport tests that fresh responses are reused, that stale ones are
+// revalidated, and that uncacheable ones are not stored.
+func TestCachingTransport(t *testing.T) {
+	version := "v1"
+	var requests, transfers int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/partners":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("ETag", `"`+version+`"`)
+			if r.Header.Get("If-None-Match") == `"`+version+`"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/private":
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		transfers++
+		io.WriteString(w, "data "+version)
+	}))
+	defer srv.Close()
+
+	clock := toolkittest.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	client := &http.Client{Transport: &CachingTransport{Store: &MemoryCacheStore{}, Clock: clock}}
+
+	get := func(path string) (string, string) {
+		res, err := client.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		return string(body), res.Header.Get("X-Cache")
+	}
+
+	expect := func(name, path, body, cache string, wantRequests, wantTransfers int) {
+		gotBody, gotCache := get(path)
+		if gotBody != body || gotCache != cache {
+			t.Errorf("%s: expected %q from %q, got %q from %q", name, body, cache, gotBody, gotCache)
+		}
+		if requests != wantRequests || transfers != wantTransfers {
+			t.Errorf("%s: expected %d requests and %d transfers, got %d and %d", name, wantRequests, wantTransfers, requests, transfers)
+		}
+	}
+
+	expect("first", "/partners", "data v1", "", 1, 1)
+	expect("fresh", "/partners", "data v1", "HIT", 1, 1)
+	clock.Advance(2 * time.Minute)
+	expect("revalidated", "/partners", "data v1", "REVALIDATED", 2, 1)
+	expect("fresh again", "/partners", "data v1", "HIT", 2, 1)
+	clock.Advance(2 * time.Minute)
+	version = "v2"
+	expect("changed", "/partners", "data v2", "", 3, 2)
+
+	expect("no-store", "/private", "data v2", "", 4, 3)
+	expect("no-store again", "/private", "data v2", "", 5, 4)
+}
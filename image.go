@@ -0,0 +1,238 @@
+package gorigumi
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// defaultImageQuality is the default JPEG quality of processed images
+	// it is inlcuded in the processImage method
+	defaultImageQuality int = 85
+
+	// defaultImageMaxPixels is the default maximum number of pixels of uploaded
+	// images when MaxWidth or MaxHeight is not set
+	// it is inlcuded in the maxPixels method
+	defaultImageMaxPixels int64 = 50_000_000
+
+	// imagePixelFactor is the factor by which the number of pixels of uploaded
+	// images may exceed the bounds they are scaled down to
+	// it is inlcuded in the maxPixels method
+	imagePixelFactor int64 = 64
+)
+
+// Size is a width and height in pixels.
+type Size struct {
+	Width  int
+	Height int
+}
+
+// ImageOptions configures the processing of uploaded images. Images are decoded
+// and re-encoded, which strips EXIF and other metadata, scaled down to fit
+// MaxWidth x MaxHeight, and thumbnails are written next to them. PNG, JPEG and
+// GIF images are supported; other images are stored untouched. Only the first
+// frame of animated GIFs is kept.
+type ImageOptions struct {
+	// MaxWidth and MaxHeight are the bounds images are scaled down to fit,
+	// keeping their aspect ratio. Zero means no limit
+	MaxWidth  int
+	MaxHeight int
+	// Quality is the quality of JPEG images, from 1 to 100. Default to 85
+	Quality int
+	// Format is the format images are converted to, "png", "jpeg" or "gif".
+	// Default to the format of the uploaded image
+	Format string
+	// GenerateThumbnails is the list of bounds thumbnails are scaled to fit.
+	// A thumbnail is written for each of them
+	GenerateThumbnails []Size
+	// MaxPixels is the maximum number of pixels of uploaded images, checked
+	// before they are decoded so small files can't claim huge dimensions.
+	// Default to 64 times MaxWidth x MaxHeight, or 50 megapixels if either
+	// is not set
+	MaxPixels int64
+}
+
+// maxPixels returns the maximum number of pixels of uploaded images.
+func (o *ImageOptions) maxPixels() int64 {
+	if o.MaxPixels > 0 {
+		return o.MaxPixels
+	}
+	if o.MaxWidth <= 0 || o.MaxHeight <= 0 {
+		return defaultImageMaxPixels
+	}
+	return imagePixelFactor * int64(o.MaxWidth) * int64(o.MaxHeight)
+}
+
+// processImage applies ImageOptions to the uploaded image file stored in
+// uploadDir, updating its name, size and thumbnails. Images with more than
+// MaxPixels pixels are rejected before they are decoded.
+func (t *Tools) processImage(uploadDir string, file *UploadedFile) error {
+	opts := t.ImageOptions
+	path := filepath.Join(uploadDir, file.NewFileName)
+
+	cfg, _, err := decodeImageConfig(path)
+	if errors.Is(err, image.ErrFormat) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if limit := opts.maxPixels(); int64(cfg.Width)*int64(cfg.Height) > limit {
+		return WithErrorCode(fmt.Errorf("image %q is too big, the maximum is %d pixels", file.OriginalFileName, limit), CodeFileTooLarge)
+	}
+
+	src, format, err := decodeImageFile(path)
+	if err != nil {
+		return err
+	}
+
+	if opts.Format != "" {
+		format = strings.ToLower(opts.Format)
+		if format == "jpg" {
+			format = "jpeg"
+		}
+	}
+
+	// the extension follows the format, the file is renamed if it changes
+	ext := filepath.Ext(file.NewFileName)
+	base := strings.TrimSuffix(file.NewFileName, ext)
+	if !strings.EqualFold(ext, "."+format) && !(format == "jpeg" && strings.EqualFold(ext, ".jpg")) {
+		ext = "." + format
+	}
+
+	newName := base + ext
+	size, err := writeImageFile(filepath.Join(uploadDir, newName), scaleImage(src, opts.MaxWidth, opts.MaxHeight), format, opts.Quality)
+	if err != nil {
+		return err
+	}
+	if newName != file.NewFileName {
+		os.Remove(path)
+		file.NewFileName = newName
+	}
+	file.FileSize = size
+
+	for _, s := range opts.GenerateThumbnails {
+		name := fmt.Sprintf("%s_%dx%d%s", base, s.Width, s.Height, ext)
+		if _, err := writeImageFile(filepath.Join(uploadDir, name), scaleImage(src, s.Width, s.Height), format, opts.Quality); err != nil {
+			return err
+		}
+		file.Thumbnails = append(file.Thumbnails, name)
+	}
+
+	return nil
+}
+
+// decodeImageConfig decodes the dimensions of the image stored at path, without
+// decoding the image itself.
+func decodeImageConfig(path string) (image.Config, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return image.Config{}, "", err
+	}
+	defer f.Close()
+
+	return image.DecodeConfig(f)
+}
+
+// decodeImageFile decodes the image stored at path.
+func decodeImageFile(path string) (image.Image, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	return image.Decode(f)
+}
+
+// writeImageFile encodes img in format to path through a temporary file, so an
+// existing file is only replaced once encoding succeeded. It returns the size
+// of the written file.
+func writeImageFile(path string, img image.Image, format string, quality int) (int64, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".image-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	err = encodeImage(tmp, img, format, quality)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	info, err := os.Stat(tmp.Name())
+	if err != nil {
+		return 0, err
+	}
+
+	return info.Size(), os.Rename(tmp.Name(), path)
+}
+
+// encodeImage writes img to w in format.
+func encodeImage(w io.Writer, img image.Image, format string, quality int) error {
+	switch format {
+	case "png":
+		return png.Encode(w, img)
+	case "jpeg":
+		if quality == 0 {
+			quality = defaultImageQuality
+		}
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	case "gif":
+		return gif.Encode(w, img, nil)
+	default:
+		return fmt.Errorf("unsupported image format %q", format)
+	}
+}
+
+// scaleImage scales src down to fit in maxWidth x maxHeight, keeping its
+// aspect ratio, by averaging the source pixels covered by each destination
+// pixel. A zero bound is not enforced, and images that already fit are
+// returned unchanged.
+func scaleImage(src image.Image, maxWidth, maxHeight int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	scale := 1.0
+	if maxWidth > 0 && w > maxWidth {
+		scale = float64(maxWidth) / float64(w)
+	}
+	if maxHeight > 0 && h > maxHeight {
+		scale = min(scale, float64(maxHeight)/float64(h))
+	}
+	if scale == 1 {
+		return src
+	}
+
+	tw, th := max(int(float64(w)*scale), 1), max(int(float64(h)*scale), 1)
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := range th {
+		y0, y1 := b.Min.Y+y*h/th, b.Min.Y+max((y+1)*h/th, y*h/th+1)
+		for x := range tw {
+			x0, x1 := b.Min.X+x*w/tw, b.Min.X+max((x+1)*w/tw, x*w/tw+1)
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a, n = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			dst.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: uint16(a / n)})
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,149 @@
+package gorigumi
+
+import (
+	"bytes"
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/drunkleen/gorigumi/toolkittest"
+)
+
+// withExif inserts an APP1 EXIF segment right after the start of image marker of a JPEG.
+func withExif(jpg []byte) []byte {
+	payload := []byte("Exif\x00\x00GPS 51.5N 0.1W")
+	segment := append([]byte{0xFF, 0xE1, 0, byte(len(payload) + 2)}, payload...)
+	return append(append(append([]byte{}, jpg[:2]...), segment...), jpg[2:]...)
+}
+
+// imageProcessTests is a slice of structs that hold the name of the test, the uploaded file name
+// and format, the image options, the expected stored file extension and size, and the
+// expected thumbnail sizes
+var imageProcessTests = []struct {
+	name           string
+	fileName       string
+	format         string
+	opts           ImageOptions
+	expectedExt    string
+	expectedSize   Size
+	thumbnailSizes []Size
+}{
+	{"untouched bounds", "a.png", "png", ImageOptions{MaxWidth: 1000}, ".png", Size{400, 200}, nil},
+	{"scaled down", "a.png", "png", ImageOptions{MaxWidth: 200, MaxHeight: 200}, ".png", Size{200, 100}, nil},
+	{"height bound", "a.png", "png", ImageOptions{MaxHeight: 50}, ".png", Size{100, 50}, nil},
+	{"converted", "a.png", "png", ImageOptions{Format: "jpg"}, ".jpeg", Size{400, 200}, nil},
+	{"jpg extension kept", "a.jpg", "jpeg", ImageOptions{Format: "jpeg", Quality: 50}, ".jpg", Size{400, 200}, nil},
+	{"thumbnails", "a.gif", "gif", ImageOptions{GenerateThumbnails: []Size{{64, 64}, {100, 10}}}, ".gif", Size{400, 200}, []Size{{64, 32}, {20, 10}}},
+}
+
+// TestTools_processImage tests the scaling, conversion and thumbnails of uploaded images.
+func TestTools_processImage(t *testing.T) {
+	for _, it := range imageProcessTests {
+		uploadDir := t.TempDir()
+		img, err := toolkittest.GenerateImage(400, 200, it.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		testTools := New()
+		testTools.AllowedFileTypes = []string{"*"}
+		opts := it.opts
+		testTools.ImageOptions = &opts
+
+		req := newMultipartRequest(t, map[string][]byte{it.fileName: img})
+		uploadedFiles, err := testTools.UploadFiles(req, uploadDir)
+		if err != nil {
+			t.Errorf("%s: %s", it.name, err)
+			continue
+		}
+		f := uploadedFiles[0]
+
+		if filepath.Ext(f.NewFileName) != it.expectedExt {
+			t.Errorf("%s: expected extension %s, got %s", it.name, it.expectedExt, f.NewFileName)
+		}
+		if entries, _ := os.ReadDir(uploadDir); len(entries) != 1+len(it.thumbnailSizes) {
+			t.Errorf("%s: expected %d files, got %d", it.name, 1+len(it.thumbnailSizes), len(entries))
+		}
+		if info, err := os.Stat(filepath.Join(uploadDir, f.NewFileName)); err != nil || info.Size() != f.FileSize {
+			t.Errorf("%s: expected file size to match the stored file", it.name)
+		}
+
+		checkSize := func(name string, expected Size) {
+			data, err := os.ReadFile(filepath.Join(uploadDir, name))
+			if err != nil {
+				t.Errorf("%s: %s", it.name, err)
+				return
+			}
+			cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+			if err != nil || cfg.Width != expected.Width || cfg.Height != expected.Height {
+				t.Errorf("%s: expected %s to be %dx%d, got %dx%d", it.name, name, expected.Width, expected.Height, cfg.Width, cfg.Height)
+			}
+		}
+
+		checkSize(f.NewFileName, it.expectedSize)
+		if len(f.Thumbnails) != len(it.thumbnailSizes) {
+			t.Errorf("%s: expected %d thumbnails, got %d", it.name, len(it.thumbnailSizes), len(f.Thumbnails))
+			continue
+		}
+		for i, thumbnail := range f.Thumbnails {
+			checkSize(thumbnail, it.thumbnailSizes[i])
+		}
+	}
+}
+
+// TestTools_processImageMetadata tests that metadata is stripped from processed images
+// and that files which are not decodable images are stored untouched.
+func TestTools_processImageMetadata(t *testing.T) {
+	uploadDir := t.TempDir()
+	jpg, err := toolkittest.GenerateImage(32, 32, "jpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := []byte("just some notes")
+
+	testTools := New()
+	testTools.AllowedFileTypes = []string{"*"}
+	testTools.ImageOptions = &ImageOptions{}
+
+	req := newMultipartRequest(t, map[string][]byte{"a.jpg": withExif(jpg), "b.txt": text})
+	uploadedFiles, err := testTools.UploadFiles(req, uploadDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uploadedFiles) != 2 {
+		t.Fatalf("expected 2 uploaded files, got %d", len(uploadedFiles))
+	}
+
+	data, _ := os.ReadFile(filepath.Join(uploadDir, "a.jpg"))
+	if bytes.Contains(data, []byte("Exif")) {
+		t.Error("expected EXIF metadata to be stripped")
+	}
+	if data, _ := os.ReadFile(filepath.Join(uploadDir, "b.txt")); !bytes.Equal(data, text) {
+		t.Error("expected text file to be stored untouched")
+	}
+}
+
+// TestTools_processImage_tooLarge tests that images with more pixels than allowed are
+// rejected before they are decoded, and that the stored upload is removed.
+func TestTools_processImage_tooLarge(t *testing.T) {
+	img, err := toolkittest.GenerateImage(400, 200, "png")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, opts := range []ImageOptions{{MaxWidth: 10, MaxHeight: 10}, {MaxPixels: 1000}} {
+		uploadDir := t.TempDir()
+		testTools := New()
+		testTools.AllowedFileTypes = []string{"*"}
+		testTools.ImageOptions = &opts
+
+		_, err := testTools.UploadFiles(newMultipartRequest(t, map[string][]byte{"a.png": img}), uploadDir)
+		if ErrorCode(err) != CodeFileTooLarge {
+			t.Errorf("%+v: expected code %s, got %v", opts, CodeFileTooLarge, err)
+		}
+		if entries, _ := os.ReadDir(uploadDir); len(entries) != 0 {
+			t.Errorf("%+v: expected the upload to be removed, got %d files", opts, len(entries))
+		}
+	}
+}
@@ -0,0 +1,215 @@
+// Package jsonschema validates JSON documents against JSON Schema (draft
+// 2020-12) schemas, reporting every violation with the path of the invalid
+// value. It is the implementation of gorigumi's schema validation of request
+// bodies and can be imported on its own.
+//
+// As the standard library has no JSON Schema support, the package implements
+// the keywords used by request contracts:
+//
+//   - type, enum and const
+//   - properties, required, additionalProperties, patternProperties,
+//     minProperties and maxProperties
+//   - items, prefixItems, minItems, maxItems and uniqueItems
+//   - minLength, maxLength, pattern and format (email, uri, date-time, date,
+//     uuid and ipv4)
+//   - minimum, maximum, exclusiveMinimum, exclusiveMaximum and multipleOf
+//   - allOf, anyOf, oneOf and not
+//   - $ref to the schemas of $defs in the same document, e.g. "#/$defs/item"
+//
+// Annotations such as title, description and examples are ignored. Keywords
+// that cannot be honored, such as remote references or $dynamicRef, are
+// rejected by Compile, so a schema never passes silently.
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// unsupported are the keywords Compile rejects.
+var unsupported = []string{
+	"$dynamicRef", "$dynamicAnchor", "$recursiveRef", "$anchor", "if", "then", "else",
+	"dependentSchemas", "dependentRequired", "unevaluatedProperties",
+	"unevaluatedItems", "contains", "propertyNames",
+}
+
+// Violation is a value of a document breaking a rule of the schema.
+type Violation struct {
+	// Path is the path of the invalid value, e.g. "items[2].quantity". Empty
+	// for the document itself
+	Path string `json:"path"`
+	// Message describes the problem, e.g. "is required"
+	Message string `json:"message"`
+}
+
+// Error returns the path and the message of the violation.
+func (v Violation) Error() string {
+	if v.Path == "" {
+		return v.Message
+	}
+	return v.Path + ": " + v.Message
+}
+
+// Schema is a compiled JSON Schema. It is safe for concurrent use.
+type Schema struct {
+	root     any
+	patterns map[string]*regexp.Regexp
+}
+
+// Compile compiles the JSON Schema document data. It returns an error if the
+// document is not a schema or uses an unsupported keyword.
+func Compile(data []byte) (*Schema, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var root any
+	if err := decoder.Decode(&root); err != nil {
+		return nil, fmt.Errorf("jsonschema: %w", err)
+	}
+
+	s := &Schema{root: root, patterns: make(map[string]*regexp.Regexp)}
+	if err := s.check(root, "#"); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// MustCompile is like Compile but panics if the schema cannot be compiled,
+// e.g. to initialize global variables holding schemas.
+func MustCompile(data []byte) *Schema {
+	s, err := Compile(data)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// check checks the schema node at location and compiles its patterns.
+func (s *Schema) check(node any, location string) error {
+	switch node := node.(type) {
+	case bool:
+		return nil
+	case map[string]any:
+		for _, keyword := range unsupported {
+			if _, ok := node[keyword]; ok {
+				return fmt.Errorf("jsonschema: %s: unsupported keyword %q", location, keyword)
+			}
+		}
+		if ref, ok := node["$ref"]; ok {
+			ref, _ := ref.(string)
+			if _, err := s.resolve(ref); err != nil {
+				return fmt.Errorf("jsonschema: %s: %w", location, err)
+			}
+		}
+		if pattern, ok := node["pattern"].(string); ok {
+			if err := s.compilePattern(pattern); err != nil {
+				return fmt.Errorf("jsonschema: %s: %w", location, err)
+			}
+		}
+
+		for _, keyword := range []string{"additionalProperties", "items", "not"} {
+			if sub, ok := node[keyword]; ok {
+				if err := s.check(sub, location+"/"+keyword); err != nil {
+					return err
+				}
+			}
+		}
+		for _, keyword := range []string{"properties", "patternProperties", "$defs"} {
+			subs, _ := node[keyword].(map[string]any)
+			for name, sub := range subs {
+				if keyword == "patternProperties" {
+					if err := s.compilePattern(name); err != nil {
+						return fmt.Errorf("jsonschema: %s: %w", location, err)
+					}
+				}
+				if err := s.check(sub, location+"/"+keyword+"/"+name); err != nil {
+					return err
+				}
+			}
+		}
+		for _, keyword := range []string{"prefixItems", "allOf", "anyOf", "oneOf"} {
+			subs, _ := node[keyword].([]any)
+			for i, sub := range subs {
+				if err := s.check(sub, fmt.Sprintf("%s/%s/%d", location, keyword, i)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("jsonschema: %s: a schema must be an object or a boolean", location)
+}
+
+// compilePattern compiles the regular expression of a pattern keyword.
+func (s *Schema) compilePattern(pattern string) error {
+	if _, ok := s.patterns[pattern]; ok {
+		return nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	s.patterns[pattern] = re
+	return nil
+}
+
+// resolve returns the schema node ref refers to, a JSON pointer in the same
+// document such as "#/$defs/item".
+func (s *Schema) resolve(ref string) (any, error) {
+	if ref == "#" {
+		return s.root, nil
+	}
+	pointer, ok := strings.CutPrefix(ref, "#/")
+	if !ok {
+		return nil, fmt.Errorf("unsupported reference %q, only references within the schema are", ref)
+	}
+
+	node := s.root
+	for _, token := range strings.Split(pointer, "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch n := node.(type) {
+		case map[string]any:
+			node, ok = n[token]
+		case []any:
+			i, err := strconv.Atoi(token)
+			ok = err == nil && i >= 0 && i < len(n)
+			if ok {
+				node = n[i]
+			}
+		default:
+			ok = false
+		}
+		if !ok {
+			return nil, fmt.Errorf("unresolved reference %q", ref)
+		}
+	}
+	return node, nil
+}
+
+// Validate validates the document v, as decoded by encoding/json into an any,
+// and returns its violations, or nil if it is valid. Numbers may be float64 or
+// json.Number values.
+func (s *Schema) Validate(v any) []Violation {
+	var violations []Violation
+	s.validate(s.root, v, "", &violations, 0)
+	return violations
+}
+
+// ValidateJSON validates the JSON document data and returns its violations,
+// or nil if it is valid. It returns an error if data is not valid JSON.
+func (s *Schema) ValidateJSON(data []byte) ([]Violation, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var v any
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, errors.New("jsonschema: data must contain a single JSON value")
+	}
+	return s.Validate(v), nil
+}
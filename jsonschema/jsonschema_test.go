@@ -0,0 +1,190 @@
+package jsonschema
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// orderSchema is the schema of the validation tests.
+const orderSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"title": "Order",
+	"type": "object",
+	"required": ["id", "email", "items"],
+	"additionalProperties": false,
+	"properties": {
+		"id": {"type": "string", "format": "uuid"},
+		"email": {"type": "string", "format": "email"},
+		"note": {"type": ["string", "null"], "maxLength": 5},
+		"status": {"enum": ["new", "paid"]},
+		"code": {"type": "string", "pattern": "^[A-Z]{3}$"},
+		"total": {"type": "number", "exclusiveMinimum": 0, "multipleOf": 0.01},
+		"items": {"type": "array", "minItems": 1, "uniqueItems": true, "items": {"$ref": "#/$defs/item"}},
+		"contact": {"oneOf": [{"required": ["phone"]}, {"required": ["fax"]}]},
+		"tags": {"not": {"type": "array", "maxItems": 0}}
+	},
+	"$defs": {
+		"item": {
+			"type": "object",
+			"required": ["sku", "quantity"],
+			"properties": {
+				"sku": {"type": "string", "minLength": 1},
+				"quantity": {"type": "integer", "minimum": 1, "maximum": 10}
+			}
+		}
+	}
+}`
+
+// validateTests is a slice of structs that hold the name of the test, the document
+// and the expected violations
+var validateTests = []struct {
+	name       string
+	document   string
+	violations []string
+}{
+	{"valid", `{"id":"3f2504e0-4f89-41d3-9a0c-0305e82c3301","email":"a@example.com","items":[{"sku":"a","quantity":2}],"total":9.99,"note":null}`, nil},
+	{"required", `{}`, []string{"id: is required", "email: is required", "items: is required"}},
+	{"not an object", `[]`, []string{"must be of type object"}},
+	{"formats", `{"id":"nope","email":"Ana <a@example.com>","items":[{"sku":"a","quantity":1}]}`, []string{
+		"email: must be a valid email address", "id: must be a valid UUID",
+	}},
+	{"additional property", `{"id":"3f2504e0-4f89-41d3-9a0c-0305e82c3301","email":"a@example.com","items":[{"sku":"a","quantity":1}],"role":"admin"}`, []string{
+		"role: is not allowed",
+	}},
+	{"nested items", `{"id":"3f2504e0-4f89-41d3-9a0c-0305e82c3301","email":"a@example.com","items":[{"sku":"","quantity":1.5},{"quantity":11}]}`, []string{
+		"items[0].quantity: must be of type integer", "items[0].sku: must be at least 1 characters long",
+		"items[1].sku: is required", "items[1].quantity: must be at most 10",
+	}},
+	{"scalars", `{"id":"3f2504e0-4f89-41d3-9a0c-0305e82c3301","email":"a@example.com","items":[{"sku":"a","quantity":1},{"sku":"a","quantity":1}],` +
+		`"note":"too long","status":"lost","code":"ab","total":0}`, []string{
+		"code: must match the pattern ^[A-Z]{3}$", "items: must not contain duplicate items",
+		"note: must be at most 5 characters long", "status: must be one of: \"new\", \"paid\"", "total: must be greater than 0",
+	}},
+	{"combinators", `{"id":"3f2504e0-4f89-41d3-9a0c-0305e82c3301","email":"a@example.com","items":[{"sku":"a","quantity":1}],` +
+		`"contact":{"phone":"1","fax":"2"},"tags":[]}`, []string{
+		"contact: must match exactly one of the allowed schemas", "tags: must not match the disallowed schema",
+	}},
+}
+
+// TestSchema_Validate tests that Validate reports every violation of a document
+// with its path.
+func TestSchema_Validate(t *testing.T) {
+	schema := MustCompile([]byte(orderSchema))
+	for _, tt := range validateTests {
+		violations, err := schema.ValidateJSON([]byte(tt.document))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		got := make(map[string]bool, len(violations))
+		for _, v := range violations {
+			got[v.Error()] = true
+		}
+		if len(got) != len(tt.violations) {
+			t.Errorf("%s: expected %q, got %v", tt.name, tt.violations, violations)
+			continue
+		}
+		for _, want := range tt.violations {
+			if !got[want] {
+				t.Errorf("%s: missing violation %q in %v", tt.name, want, violations)
+			}
+		}
+	}
+}
+
+// compileTests is a slice of structs that hold the name of the test, the schema
+// and the expected error, if any
+var compileTests = []struct {
+	name   string
+	schema string
+	err    string
+}{
+	{"boolean", `true`, ""},
+	{"recursive reference", `{"type":"object","properties":{"child":{"$ref":"#"}}}`, ""},
+	{"not a schema", `42`, "must be an object or a boolean"},
+	{"unsupported keyword", `{"properties":{"a":{"if":{"type":"string"}}}}`, `unsupported keyword "if"`},
+	{"remote reference", `{"$ref":"https://example.com/schema.json"}`, "unsupported reference"},
+	{"unresolved reference", `{"$ref":"#/$defs/missing"}`, "unresolved reference"},
+	{"invalid pattern", `{"pattern":"("}`, "invalid pattern"},
+	{"invalid JSON", `{`, "unexpected EOF"},
+}
+
+// TestCompile tests that Compile rejects invalid and unsupported schemas.
+func TestCompile(t *testing.T) {
+	for _, tt := range compileTests {
+		_, err := Compile([]byte(tt.schema))
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: expected an error containing %q, got %v", tt.name, tt.err, err)
+		}
+	}
+}
+
+// TestSchema_Validate_recursive tests that recursive schemas validate nested
+// documents and end.
+func TestSchema_Validate_recursive(t *testing.T) {
+	schema := MustCompile([]byte(`{"type":"object","properties":{"name":{"type":"string"},"child":{"$ref":"#"}}}`))
+	violations, err := schema.ValidateJSON([]byte(`{"name":"a","child":{"name":"b","child":{"name":3}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violations) != 1 || violations[0].Path != "child.child.name" {
+		t.Errorf("unexpected violations %v", violations)
+	}
+
+	loop := MustCompile([]byte(`{"$defs":{"a":{"$ref":"#/$defs/a"}},"$ref":"#/$defs/a"}`))
+	if violations := loop.Validate(map[string]any{}); len(violations) != 1 {
+		t.Errorf("expected the loop to be cut, got %v", violations)
+	}
+}
+
+// uniqueItemsTests is a slice of structs that hold the name of the test, the array
+// and a boolean that indicates if it holds duplicates
+var uniqueItemsTests = []struct {
+	name       string
+	document   string
+	duplicates bool
+}{
+	{"unique", `[1, "1", [1], {"a": 1}, null, true]`, false},
+	{"numbers by value", `[1, 1.0]`, true},
+	{"zero", `[0, -0]`, true},
+	{"objects in any order", `[{"a": 1, "b": [2]}, {"b": [2.0], "a": 1}]`, true},
+	{"nested arrays in order", `[[1, 2], [2, 1]]`, false},
+	{"strings", `["a,b", "a", "b"]`, false},
+}
+
+// TestSchema_Validate_uniqueItems tests that duplicates are found among equal
+// JSON values, and that long arrays are checked quickly.
+func TestSchema_Validate_uniqueItems(t *testing.T) {
+	schema := MustCompile([]byte(`{"type":"array","uniqueItems":true}`))
+	for _, tt := range uniqueItemsTests {
+		violations, err := schema.ValidateJSON([]byte(tt.document))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if (len(violations) != 0) != tt.duplicates {
+			t.Errorf("%s: expected duplicates %t, got %v", tt.name, tt.duplicates, violations)
+		}
+	}
+
+	items := make([]string, 100000)
+	for i := range items {
+		items[i] = strconv.Itoa(i)
+	}
+	start := time.Now()
+	violations, err := schema.ValidateJSON([]byte("[" + strings.Join(items, ",") + "]"))
+	if err != nil || len(violations) != 0 {
+		t.Errorf("expected a long array of unique items to be valid, got %v (%v)", violations, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected a long array to be checked quickly, took %s", elapsed)
+	}
+}
@@ -0,0 +1,444 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/mail"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxDepth is the maximum number of nested schemas applied to a document, so
+// recursive references without progress end.
+const maxDepth int = 256
+
+// formats check the values of the supported format keywords, with the
+// message of invalid values.
+var formats = map[string]struct {
+	valid   func(s string) bool
+	message string
+}{
+	"email": {func(s string) bool {
+		addr, err := mail.ParseAddress(s)
+		return err == nil && addr.Name == "" && addr.Address == s
+	}, "must be a valid email address"},
+	"uri": {func(s string) bool {
+		u, err := url.Parse(s)
+		return err == nil && u.Scheme != ""
+	}, "must be a valid URI"},
+	"date-time": {func(s string) bool {
+		_, err := time.Parse(time.RFC3339, s)
+		return err == nil
+	}, "must be a valid RFC 3339 date-time"},
+	"date": {func(s string) bool {
+		_, err := time.Parse(time.DateOnly, s)
+		return err == nil
+	}, "must be a valid date"},
+	"uuid": {isUUID, "must be a valid UUID"},
+	"ipv4": {func(s string) bool {
+		ip := net.ParseIP(s)
+		return ip != nil && ip.To4() != nil && strings.Count(s, ".") == 3
+	}, "must be a valid IPv4 address"},
+}
+
+// validate appends to violations the violations of the value v at path
+// against the schema node.
+func (s *Schema) validate(node, v any, path string, violations *[]Violation, depth int) {
+	add := func(path, format string, args ...any) {
+		*violations = append(*violations, Violation{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	if depth > maxDepth {
+		add(path, "exceeds the maximum schema depth")
+		return
+	}
+
+	schema, ok := node.(map[string]any)
+	if !ok {
+		if node == false {
+			add(path, "is not allowed")
+		}
+		return
+	}
+
+	if ref, ok := schema["$ref"].(string); ok {
+		// resolved by Compile
+		target, _ := s.resolve(ref)
+		s.validate(target, v, path, violations, depth+1)
+	}
+
+	if types, ok := schema["type"]; ok && !hasType(v, types) {
+		add(path, "must be of type %s", joinTypes(types))
+		// other keywords would only restate the problem
+		return
+	}
+	if enum, ok := schema["enum"].([]any); ok && !slices.ContainsFunc(enum, func(e any) bool { return equal(e, v) }) {
+		values := make([]string, len(enum))
+		for i, e := range enum {
+			values[i] = encode(e)
+		}
+		add(path, "must be one of: %s", strings.Join(values, ", "))
+	}
+	if c, ok := schema["const"]; ok && !equal(c, v) {
+		add(path, "must be equal to %s", encode(c))
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		s.validateObject(schema, v, path, violations, depth)
+	case []any:
+		s.validateArray(schema, v, path, violations, depth)
+	case string:
+		s.validateString(schema, v, path, add)
+	default:
+		if n, ok := number(v); ok {
+			validateNumber(schema, n, path, add)
+		}
+	}
+
+	if subs, ok := schema["allOf"].([]any); ok {
+		for _, sub := range subs {
+			s.validate(sub, v, path, violations, depth+1)
+		}
+	}
+	if subs, ok := schema["anyOf"].([]any); ok && s.matches(subs, v, depth) == 0 {
+		add(path, "must match at least one of the allowed schemas")
+	}
+	if subs, ok := schema["oneOf"].([]any); ok && s.matches(subs, v, depth) != 1 {
+		add(path, "must match exactly one of the allowed schemas")
+	}
+	if sub, ok := schema["not"]; ok && s.matches([]any{sub}, v, depth) == 1 {
+		add(path, "must not match the disallowed schema")
+	}
+}
+
+// matches returns the number of schemas of subs v is valid against.
+func (s *Schema) matches(subs []any, v any, depth int) int {
+	n := 0
+	for _, sub := range subs {
+		var violations []Violation
+		s.validate(sub, v, "", &violations, depth+1)
+		if len(violations) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// validateObject validates the object keywords of schema against v.
+func (s *Schema) validateObject(schema, v map[string]any, path string, violations *[]Violation, depth int) {
+	required, _ := schema["required"].([]any)
+	for _, name := range required {
+		if name, ok := name.(string); ok {
+			if _, ok := v[name]; !ok {
+				*violations = append(*violations, Violation{Path: child(path, name), Message: "is required"})
+			}
+		}
+	}
+	if n, ok := number(schema["minProperties"]); ok && float64(len(v)) < n {
+		*violations = append(*violations, Violation{Path: path, Message: fmt.Sprintf("must have at least %s properties", format(n))})
+	}
+	if n, ok := number(schema["maxProperties"]); ok && float64(len(v)) > n {
+		*violations = append(*violations, Violation{Path: path, Message: fmt.Sprintf("must have at most %s properties", format(n))})
+	}
+
+	properties, _ := schema["properties"].(map[string]any)
+	patternProperties, _ := schema["patternProperties"].(map[string]any)
+	additional, hasAdditional := schema["additionalProperties"]
+
+	names := make([]string, 0, len(v))
+	for name := range v {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		matched := false
+		if sub, ok := properties[name]; ok {
+			matched = true
+			s.validate(sub, v[name], child(path, name), violations, depth+1)
+		}
+		for pattern, sub := range patternProperties {
+			if s.patterns[pattern].MatchString(name) {
+				matched = true
+				s.validate(sub, v[name], child(path, name), violations, depth+1)
+			}
+		}
+		if !matched && hasAdditional {
+			s.validate(additional, v[name], child(path, name), violations, depth+1)
+		}
+	}
+}
+
+// validateArray validates the array keywords of schema against v.
+func (s *Schema) validateArray(schema map[string]any, v []any, path string, violations *[]Violation, depth int) {
+	if n, ok := number(schema["minItems"]); ok && float64(len(v)) < n {
+		*violations = append(*violations, Violation{Path: path, Message: fmt.Sprintf("must have at least %s items", format(n))})
+	}
+	if n, ok := number(schema["maxItems"]); ok && float64(len(v)) > n {
+		*violations = append(*violations, Violation{Path: path, Message: fmt.Sprintf("must have at most %s items", format(n))})
+	}
+	if unique, _ := schema["uniqueItems"].(bool); unique {
+		// items are compared by canonical encoding, so long arrays are
+		// checked in linear time
+		seen := make(map[string]struct{}, len(v))
+		for _, item := range v {
+			key := canonical(item)
+			if _, ok := seen[key]; ok {
+				*violations = append(*violations, Violation{Path: path, Message: "must not contain duplicate items"})
+				break
+			}
+			seen[key] = struct{}{}
+		}
+	}
+
+	prefix, _ := schema["prefixItems"].([]any)
+	items, hasItems := schema["items"]
+	for i, item := range v {
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+		switch {
+		case i < len(prefix):
+			s.validate(prefix[i], item, itemPath, violations, depth+1)
+		case hasItems:
+			s.validate(items, item, itemPath, violations, depth+1)
+		}
+	}
+}
+
+// validateString validates the string keywords of schema against v.
+func (s *Schema) validateString(schema map[string]any, v, path string, add func(path, format string, args ...any)) {
+	length := float64(utf8.RuneCountInString(v))
+	if n, ok := number(schema["minLength"]); ok && length < n {
+		add(path, "must be at least %s characters long", format(n))
+	}
+	if n, ok := number(schema["maxLength"]); ok && length > n {
+		add(path, "must be at most %s characters long", format(n))
+	}
+	if pattern, ok := schema["pattern"].(string); ok && !s.patterns[pattern].MatchString(v) {
+		add(path, "must match the pattern %s", pattern)
+	}
+	if name, ok := schema["format"].(string); ok {
+		// unknown formats are annotations
+		if f, ok := formats[name]; ok && !f.valid(v) {
+			add(path, "%s", f.message)
+		}
+	}
+}
+
+// validateNumber validates the number keywords of schema against v.
+func validateNumber(schema map[string]any, v float64, path string, add func(path, format string, args ...any)) {
+	if n, ok := number(schema["minimum"]); ok && v < n {
+		add(path, "must be at least %s", format(n))
+	}
+	if n, ok := number(schema["maximum"]); ok && v > n {
+		add(path, "must be at most %s", format(n))
+	}
+	if n, ok := number(schema["exclusiveMinimum"]); ok && v <= n {
+		add(path, "must be greater than %s", format(n))
+	}
+	if n, ok := number(schema["exclusiveMaximum"]); ok && v >= n {
+		add(path, "must be less than %s", format(n))
+	}
+	if n, ok := number(schema["multipleOf"]); ok && n > 0 {
+		if q := v / n; math.Abs(q-math.Round(q)) > 1e-9 {
+			add(path, "must be a multiple of %s", format(n))
+		}
+	}
+}
+
+// hasType reports whether v is of one of types, a type name or a list of
+// type names.
+func hasType(v, types any) bool {
+	switch types := types.(type) {
+	case string:
+		return isType(v, types)
+	case []any:
+		return slices.ContainsFunc(types, func(t any) bool {
+			name, _ := t.(string)
+			return isType(v, name)
+		})
+	}
+	return true
+}
+
+// isType reports whether v is of the type name.
+func isType(v any, name string) bool {
+	switch name {
+	case "null":
+		return v == nil
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := number(v)
+		return ok
+	case "integer":
+		n, ok := number(v)
+		return ok && n == math.Trunc(n)
+	}
+	return false
+}
+
+// joinTypes returns the type names of types, e.g. "string or null".
+func joinTypes(types any) string {
+	if list, ok := types.([]any); ok {
+		names := make([]string, len(list))
+		for i, t := range list {
+			names[i] = fmt.Sprint(t)
+		}
+		return strings.Join(names, " or ")
+	}
+	return fmt.Sprint(types)
+}
+
+// number returns the value of the number v, a json.Number or a Go number.
+func number(v any) (float64, bool) {
+	switch v := v.(type) {
+	case json.Number:
+		n, err := v.Float64()
+		return n, err == nil
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// equal reports whether the JSON values a and b are equal, numbers being
+// compared by value.
+func equal(a, b any) bool {
+	if x, ok := number(a); ok {
+		y, ok := number(b)
+		return ok && x == y
+	}
+	switch a := a.(type) {
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for key, value := range a {
+			other, ok := b[key]
+			if !ok || !equal(value, other) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		b, ok := b.([]any)
+		return ok && slices.EqualFunc(a, b, equal)
+	}
+	return a == b
+}
+
+// canonical returns an encoding of the JSON value v that is the same for all the
+// values equal to v, the keys of objects being sorted and numbers being
+// encoded by value.
+func canonical(v any) string {
+	var b strings.Builder
+	writeCanonical(&b, v)
+	return b.String()
+}
+
+// writeCanonical writes the canonical encoding of v to b.
+func writeCanonical(b *strings.Builder, v any) {
+	if n, ok := number(v); ok {
+		if n == 0 {
+			// -0 equals 0
+			n = 0
+		}
+		b.WriteString(strconv.FormatFloat(n, 'g', -1, 64))
+		return
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		b.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(strconv.Quote(key))
+			b.WriteByte(':')
+			writeCanonical(b, v[key])
+		}
+		b.WriteByte('}')
+	case []any:
+		b.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeCanonical(b, item)
+		}
+		b.WriteByte(']')
+	case string:
+		b.WriteString(strconv.Quote(v))
+	default:
+		b.WriteString(encode(v))
+	}
+}
+
+// encode returns the JSON encoding of v, for messages.
+func encode(v any) string {
+	out, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(out)
+}
+
+// format formats the number n of a keyword, for messages.
+func format(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+// child returns the path of the property name of the object at path.
+func child(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// isUUID reports whether s is a UUID in its canonical textual form.
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i, c := range s {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,125 @@
+package jsonx
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Error codes of the Code field of error responses. They are stable, so
+// clients can branch on them rather than on the English message.
+const (
+	CodeBadRequest           = "bad_request"
+	CodeInvalidJSON          = "invalid_json"
+	CodeValidationFailed     = "validation_failed"
+	CodeUnauthorized         = "unauthorized"
+	CodeForbidden            = "forbidden"
+	CodeNotFound             = "not_found"
+	CodeMethodNotAllowed     = "method_not_allowed"
+	CodeConflict             = "conflict"
+	CodeGone                 = "gone"
+	CodePayloadTooLarge      = "payload_too_large"
+	CodeFileTooLarge         = "file_too_large"
+	CodeTooManyFiles         = "too_many_files"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeQuotaExceeded        = "quota_exceeded"
+	CodeInvalidToken         = "invalid_token"
+	CodeTokenExpired         = "token_expired"
+	CodeRateLimited          = "rate_limited"
+	CodeInternal             = "internal_error"
+	CodeBadGateway           = "bad_gateway"
+	CodeUnavailable          = "service_unavailable"
+	CodeTimeout              = "timeout"
+	CodeClientClosedRequest  = "client_closed_request"
+	CodeFileQuarantined      = "file_quarantined"
+)
+
+// statusCodes are the codes of errors without one, by HTTP status
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            CodeBadRequest,
+	http.StatusUnauthorized:          CodeUnauthorized,
+	http.StatusForbidden:             CodeForbidden,
+	http.StatusNotFound:              CodeNotFound,
+	http.StatusMethodNotAllowed:      CodeMethodNotAllowed,
+	http.StatusConflict:              CodeConflict,
+	http.StatusGone:                  CodeGone,
+	http.StatusRequestEntityTooLarge: CodePayloadTooLarge,
+	http.StatusUnsupportedMediaType:  CodeUnsupportedMediaType,
+	http.StatusUnprocessableEntity:   CodeValidationFailed,
+	http.StatusTooManyRequests:       CodeRateLimited,
+	http.StatusInternalServerError:   CodeInternal,
+	http.StatusBadGateway:            CodeBadGateway,
+	http.StatusServiceUnavailable:    CodeUnavailable,
+	http.StatusGatewayTimeout:        CodeTimeout,
+	499:                              CodeClientClosedRequest, // nginx's Client Closed Request
+}
+
+// CodedError is an error carrying an error code, see WithCode.
+type CodedError struct {
+	Code string
+	Err  error
+}
+
+// Error returns the message of the wrapped error.
+func (e *CodedError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *CodedError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorCode returns the code of the error.
+func (e *CodedError) ErrorCode() string {
+	return e.Code
+}
+
+// WithCode returns err with the error code code, reported by Error.
+func WithCode(err error, code string) error {
+	return &CodedError{Code: code, Err: err}
+}
+
+// DetailedError is an error carrying details written as the Data of its error
+// Response, see WithDetails.
+type DetailedError struct {
+	Details map[string]any
+	Err     error
+}
+
+// Error returns the message of the wrapped error.
+func (e *DetailedError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *DetailedError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorData returns the details of the error.
+func (e *DetailedError) ErrorData() any {
+	return e.Details
+}
+
+// WithDetails returns err with details, e.g. the identifier of a missing
+// resource, written as the Data of the Response of Error.
+func WithDetails(err error, details map[string]any) error {
+	return &DetailedError{Details: details, Err: err}
+}
+
+// Code returns the code of the first error in the chain of err having an
+// ErrorCode() string method, such as the errors returned by WithCode, or an
+// empty string if there is none.
+func Code(err error) string {
+	var coder interface{ ErrorCode() string }
+	if errors.As(err, &coder) {
+		return coder.ErrorCode()
+	}
+	return ""
+}
+
+// StatusCode returns the error code matching an HTTP status, or an empty
+// string for statuses without one.
+func StatusCode(status int) string {
+	return statusCodes[status]
+}
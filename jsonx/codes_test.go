@@ -0,0 +1,82 @@
+package jsonx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// codeTests is a slice of structs that hold the name of the test, the error, the status
+// and the expected code of the response
+var codeTests = []struct {
+	name     string
+	err      error
+	status   int
+	expected string
+}{
+	{"status code", errors.New("missing"), http.StatusNotFound, CodeNotFound},
+	{"default status", errors.New("boom"), 0, CodeInternal},
+	{"coded error", WithCode(errors.New("too big"), CodeFileTooLarge), http.StatusBadRequest, CodeFileTooLarge},
+	{"wrapped coded error", fmt.Errorf("upload: %w", WithCode(errors.New("too big"), CodeFileTooLarge)), http.StatusBadRequest, CodeFileTooLarge},
+	{"status without code", errors.New("teapot"), http.StatusTeapot, ""},
+}
+
+// TestError_code tests the code of error responses.
+func TestError_code(t *testing.T) {
+	for _, ct := range codeTests {
+		rr := httptest.NewRecorder()
+		var err error
+		if ct.status == 0 {
+			err = Error(rr, ct.err)
+		} else {
+			err = Error(rr, ct.err, ct.status)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var res Response
+		if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res.Code != ct.expected {
+			t.Errorf("%s: expected code %q, got %q", ct.name, ct.expected, res.Code)
+		}
+		if res.Message != ct.err.Error() {
+			t.Errorf("%s: expected message %q, got %q", ct.name, ct.err, res.Message)
+		}
+	}
+}
+
+// TestDecode_code tests that decoding errors carry a code.
+func TestDecode_code(t *testing.T) {
+	var v struct{ Foo string }
+	if err := Decode(strings.NewReader(`{"foo":`), &v, 1024, false); Code(err) != CodeInvalidJSON {
+		t.Errorf("expected %s, got %q for %v", CodeInvalidJSON, Code(err), err)
+	}
+	if err := Decode(strings.NewReader(`{"bar":1}`), &v, 1024, false); Code(err) != CodeInvalidJSON {
+		t.Errorf("expected %s, got %q for %v", CodeInvalidJSON, Code(err), err)
+	}
+	if code := Code(errors.New("plain")); code != "" {
+		t.Errorf("expected no code, got %q", code)
+	}
+}
+
+// TestError_details tests that the details of an error are written as the data of
+// its response, along with its code.
+func TestError_details(t *testing.T) {
+	rr := httptest.NewRecorder()
+	err := WithCode(WithDetails(errors.New("no user 42"), map[string]any{"id": 42}), "user_not_found")
+	if err := Error(rr, err, http.StatusNotFound); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"error":true,"message":"no user 42","code":"user_not_found","data":{"id":42}}`
+	if body := strings.TrimSpace(rr.Body.String()); body != expected {
+		t.Errorf("expected %s, got %s", expected, body)
+	}
+}
@@ -0,0 +1,107 @@
+package jsonx
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxContentEncodings is the maximum number of content codings of a body, so
+// a request cannot make the server stack decompressors
+const maxContentEncodings int = 2
+
+// Decompress replaces the body of r with its decoded content if it has a gzip or
+// deflate Content-Encoding, and removes the header, so the body is read as sent
+// uncompressed. The size limit of a body must be applied both to the body of r
+// before Decompress, so a client cannot stream an endless compressed body that
+// decompresses to little, and to the body Decompress leaves, which is
+// decompressed as it is read, so a small compressed body cannot expand into a
+// huge one in memory. Unsupported codings return an error with the
+// code CodeUnsupportedMediaType, and invalid compressed data one with the code
+// CodeBadRequest.
+func Decompress(r *http.Request) error {
+	header := r.Header.Get("Content-Encoding")
+	if header == "" {
+		return nil
+	}
+
+	codings := strings.Split(header, ",")
+	if len(codings) > maxContentEncodings {
+		return WithCode(fmt.Errorf("body must not have more than %d content codings", maxContentEncodings), CodeUnsupportedMediaType)
+	}
+
+	// codings are listed in the order they were applied
+	body := r.Body
+	for i := len(codings) - 1; i >= 0; i-- {
+		var err error
+		switch coding := strings.ToLower(strings.TrimSpace(codings[i])); coding {
+		case "identity", "":
+		case "gzip", "x-gzip":
+			body, err = newGzipReader(body)
+		case "deflate":
+			body, err = newDeflateReader(body)
+		default:
+			return WithCode(fmt.Errorf("unsupported Content-Encoding %q, use gzip or deflate", coding), CodeUnsupportedMediaType)
+		}
+		if err != nil {
+			return WithCode(fmt.Errorf("body is not valid compressed data: %w", err), CodeBadRequest)
+		}
+	}
+
+	r.Body = body
+	r.Header.Del("Content-Encoding")
+	r.ContentLength = -1
+	return nil
+}
+
+// newGzipReader returns a reader decompressing the gzip data of body, closing
+// body when closed.
+func newGzipReader(body io.ReadCloser) (io.ReadCloser, error) {
+	zr, err := gzip.NewReader(body)
+	if err != nil {
+		return nil, err
+	}
+	return &decompressor{Reader: zr, body: body}, nil
+}
+
+// newDeflateReader returns a reader decompressing the deflate data of body,
+// closing body when closed. The data is zlib wrapped, as HTTP specifies, or raw
+// deflate, as some clients send.
+func newDeflateReader(body io.ReadCloser) (io.ReadCloser, error) {
+	br := bufio.NewReader(body)
+	if head, err := br.Peek(2); err == nil && head[0]&0x0f == 8 && (uint16(head[0])<<8|uint16(head[1]))%31 == 0 {
+		zr, err := zlib.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return &decompressor{Reader: zr, body: body}, nil
+	}
+	return &decompressor{Reader: flate.NewReader(br), body: body}, nil
+}
+
+// decompressor is the decompressed body of a request.
+type decompressor struct {
+	io.Reader
+	body io.Closer
+}
+
+// Close closes the body of the request.
+func (d *decompressor) Close() error {
+	if c, ok := d.Reader.(io.Closer); ok {
+		c.Close()
+	}
+	return d.body.Close()
+}
+
+// isCorrupt reports whether err is caused by invalid compressed data.
+func isCorrupt(err error) bool {
+	var corrupt flate.CorruptInputError
+	return errors.As(err, &corrupt) || errors.Is(err, gzip.ErrChecksum) || errors.Is(err, gzip.ErrHeader) ||
+		errors.Is(err, zlib.ErrChecksum) || errors.Is(err, zlib.ErrHeader)
+}
@@ -0,0 +1,104 @@
+package jsonx
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// compress returns data compressed with the writer returned by newWriter. Writes
+// to a bytes.Buffer cannot fail.
+func compress(data []byte, newWriter func(io.Writer) io.WriteCloser) []byte {
+	var buf bytes.Buffer
+	zw := newWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+	return buf.Bytes()
+}
+
+// gzipWriter, zlibWriter and flateWriter return compressing writers for compress.
+var (
+	gzipWriter  = func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }
+	zlibWriter  = func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }
+	flateWriter = func(w io.Writer) io.WriteCloser { fw, _ := flate.NewWriter(w, flate.BestSpeed); return fw }
+)
+
+// paddedFlateWriter is a raw deflate writer padding the data with empty blocks, so
+// the compressed body is far larger than the decompressed one.
+type paddedFlateWriter struct {
+	*flate.Writer
+}
+
+// newPaddedFlateWriter returns a paddedFlateWriter for compress.
+func newPaddedFlateWriter(w io.Writer) io.WriteCloser {
+	fw, _ := flate.NewWriter(w, flate.BestSpeed)
+	return paddedFlateWriter{fw}
+}
+
+// Close writes 1000 empty blocks before closing the writer.
+func (w paddedFlateWriter) Close() error {
+	for range 1000 {
+		w.Flush()
+	}
+	return w.Writer.Close()
+}
+
+// compressedPayload is the JSON body of the compression tests, and bomb a body
+// expanding far beyond the size limit
+var (
+	compressedPayload = []byte(`{"name":"Ana"}`)
+	bomb              = []byte(`{"name":"` + strings.Repeat("a", 10*1024*1024) + `"}`)
+)
+
+// readCompressedTests is a slice of structs that hold the name of the test, the
+// Content-Encoding, the body and the expected error code, if any
+var readCompressedTests = []struct {
+	name     string
+	encoding string
+	body     []byte
+	code     string
+}{
+	{"gzip", "gzip", compress(compressedPayload, gzipWriter), ""},
+	{"zlib deflate", "deflate", compress(compressedPayload, zlibWriter), ""},
+	{"raw deflate", "Deflate", compress(compressedPayload, flateWriter), ""},
+	{"stacked", "deflate, gzip", compress(compress(compressedPayload, zlibWriter), gzipWriter), ""},
+	{"identity", "identity", compressedPayload, ""},
+	{"zip bomb", "gzip", compress(bomb, gzipWriter), CodePayloadTooLarge},
+	{"padded", "deflate", compress(compressedPayload, newPaddedFlateWriter), CodePayloadTooLarge},
+	{"not gzip", "gzip", compressedPayload, CodeBadRequest},
+	{"corrupt", "gzip", append(compress(compressedPayload, gzipWriter)[:12], 0xff, 0xff, 0xff, 0xff), CodeBadRequest},
+	{"unsupported", "br", compressedPayload, CodeUnsupportedMediaType},
+	{"too many codings", "gzip, gzip, gzip", compressedPayload, CodeUnsupportedMediaType},
+}
+
+// TestRead_compressed tests that compressed bodies are decompressed within the
+// size limit, and that invalid or unsupported encodings are rejected.
+func TestRead_compressed(t *testing.T) {
+	for _, tt := range readCompressedTests {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(tt.body))
+		req.Header.Set("Content-Encoding", tt.encoding)
+
+		var v struct {
+			Name string `json:"name"`
+		}
+		err := Read(httptest.NewRecorder(), req, &v, 1024, false)
+		if tt.code == "" {
+			if err != nil || v.Name != "Ana" {
+				t.Errorf("%s: expected the body to be decoded, got %v", tt.name, err)
+			}
+			if req.Header.Get("Content-Encoding") != "" {
+				t.Errorf("%s: expected the Content-Encoding header to be removed", tt.name)
+			}
+			continue
+		}
+		if code := Code(err); code != tt.code {
+			t.Errorf("%s: expected code %q, got %q for %v", tt.name, tt.code, code, err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package jsonx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+// maxCallbackLength is the maximum length of a JSONP callback name
+const maxCallbackLength int = 128
+
+// reservedWords are the JavaScript reserved words, which cannot name a
+// callback.
+var reservedWords = map[string]bool{
+	"await": true, "break": true, "case": true, "catch": true, "class": true, "const": true,
+	"continue": true, "debugger": true, "default": true, "delete": true, "do": true, "else": true,
+	"enum": true, "export": true, "extends": true, "false": true, "finally": true, "for": true,
+	"function": true, "if": true, "implements": true, "import": true, "in": true, "instanceof": true,
+	"interface": true, "let": true, "new": true, "null": true, "package": true, "private": true,
+	"protected": true, "public": true, "return": true, "static": true, "super": true, "switch": true,
+	"this": true, "throw": true, "true": true, "try": true, "typeof": true, "var": true, "void": true,
+	"while": true, "with": true, "yield": true,
+}
+
+// WriteJSONP marshals data and writes it to w as a JSONP response, the call of
+// callback with data, with the given status code and the optional headers. The
+// callback must be a JavaScript identifier or a dotted path of identifiers, such
+// as jQuery123 or app.handlers.onData, as it is usually taken from the query of
+// the request; other names return an error with the code CodeBadRequest and
+// nothing is written. The response is served as application/javascript with
+// nosniff, and starts with an empty comment so it cannot be mistaken for another
+// type of file.
+func WriteJSONP(w http.ResponseWriter, status int, data any, callback string, headers ...http.Header) error {
+	if err := ValidateCallback(callback); err != nil {
+		return err
+	}
+
+	out, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	if len(headers) > 0 {
+		for key, value := range headers[0] {
+			w.Header()[key] = value
+		}
+	}
+	w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+
+	// json.Marshal escapes U+2028 and U+2029, which end JavaScript lines
+	_, err = fmt.Fprintf(w, "/**/ typeof %s === 'function' && %s(%s);", callback, callback, out)
+	return err
+}
+
+// ValidateCallback returns an error with the code CodeBadRequest if callback is
+// not a valid JSONP callback name, a JavaScript identifier or a dotted path of
+// identifiers that are not reserved words.
+func ValidateCallback(callback string) error {
+	if callback == "" {
+		return WithCode(errors.New("callback is required"), CodeBadRequest)
+	}
+	if len(callback) > maxCallbackLength {
+		return WithCode(fmt.Errorf("callback must not be longer than %d characters", maxCallbackLength), CodeBadRequest)
+	}
+
+	for _, name := range strings.Split(callback, ".") {
+		if !isIdentifier(name) || reservedWords[name] {
+			return WithCode(fmt.Errorf("callback %q is not a valid JavaScript identifier", callback), CodeBadRequest)
+		}
+	}
+	return nil
+}
+
+// isIdentifier reports whether name is a JavaScript identifier made of letters,
+// digits, underscores and dollar signs.
+func isIdentifier(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == '_' || r == '$' || unicode.IsLetter(r):
+		case i > 0 && unicode.IsDigit(r):
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package jsonx
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+// callbackTests is a slice of structs that hold the name of the test, the callback name,
+// and a boolean that indicates if it is valid
+var callbackTests = []struct {
+	name     string
+	callback string
+	valid    bool
+}{
+	{"identifier", "callback", true},
+	{"jQuery", "jQuery3600_1700000000", true},
+	{"dotted path", "app.handlers.$onData", true},
+	{"unicode", "données", true},
+	{"empty", "", false},
+	{"leading digit", "1callback", false},
+	{"call", "alert(1)", false},
+	{"statement", "cb;alert", false},
+	{"brackets", "cb['x']", false},
+	{"empty segment", "app..cb", false},
+	{"trailing dot", "cb.", false},
+	{"reserved word", "function", false},
+	{"reserved segment", "app.delete", false},
+	{"too long", string(make([]byte, 129)), false},
+}
+
+// TestValidateCallback tests that only JavaScript identifiers are valid callbacks.
+func TestValidateCallback(t *testing.T) {
+	for _, ct := range callbackTests {
+		err := ValidateCallback(ct.callback)
+		if ct.valid && err != nil {
+			t.Errorf("%s: expected %q to be valid, got %v", ct.name, ct.callback, err)
+		}
+		if !ct.valid && (err == nil || Code(err) != CodeBadRequest) {
+			t.Errorf("%s: expected %q to be invalid, got %v", ct.name, ct.callback, err)
+		}
+	}
+}
+
+// TestWriteJSONP tests that data is wrapped in the callback, and that nothing is
+// written for invalid callbacks.
+func TestWriteJSONP(t *testing.T) {
+	rr := httptest.NewRecorder()
+	if err := WriteJSONP(rr, 200, map[string]string{"line": "a\u2028b"}, "app.cb"); err != nil {
+		t.Fatal(err)
+	}
+	want := `/**/ typeof app.cb === 'function' && app.cb({"line":"a\u2028b"});`
+	if rr.Body.String() != want {
+		t.Errorf("expected %s, got %s", want, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/javascript; charset=utf-8" || rr.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Errorf("unexpected headers %v", rr.Header())
+	}
+
+	rr = httptest.NewRecorder()
+	if err := WriteJSONP(rr, 200, "data", "alert(1)//"); err == nil || rr.Body.Len() != 0 {
+		t.Errorf("expected an error and no response, got %v %q", err, rr.Body.String())
+	}
+}
@@ -0,0 +1,175 @@
+// Package jsonx reads and writes JSON request and response bodies. It is the
+// implementation of gorigumi's JSON methods and can be imported on its own.
+package jsonx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultMaxBytes is the default maximum size of a JSON request body
+const DefaultMaxBytes int = 1024 * 1024 // 1MB
+
+// Response is the envelope of JSON responses. Error indicates whether the
+// response is an error, Message holds the error or informational message, Code
+// the machine-readable code of errors and Data the payload. Empty fields are
+// omitted.
+type Response struct {
+	Error   bool   `json:"error,omitempty"`
+	Message string `json:"message,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Data    any    `json:"data,omitempty"`
+}
+
+// Read decodes the body of r into v. The body is limited to maxBytes, or to
+// DefaultMaxBytes if maxBytes is zero, and unknown fields are rejected unless
+// allowUnknownFields is true. Bodies compressed with gzip or deflate are
+// decompressed, see Decompress, and the limit applies to both the compressed and
+// the decompressed body.
+func Read(w http.ResponseWriter, r *http.Request, v any, maxBytes int, allowUnknownFields bool) error {
+	if maxBytes == 0 {
+		maxBytes = DefaultMaxBytes
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+	if err := Decompress(r); err != nil {
+		return err
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+
+	return Decode(r.Body, v, maxBytes, allowUnknownFields)
+}
+
+// Decode decodes exactly one JSON value from body into v and classifies
+// decoding errors with ClassifyError. The size limit must already be enforced
+// on body, e.g. with http.MaxBytesReader; maxBytes is only used in error messages.
+func Decode(body io.Reader, v any, maxBytes int, allowUnknownFields bool) error {
+	decoder := json.NewDecoder(body)
+	if !allowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	if err := decoder.Decode(v); err != nil {
+		return ClassifyError(err, maxBytes)
+	}
+
+	if err := decoder.Decode(&struct{}{}); err != io.EOF {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			return ClassifyError(err, maxBytes)
+		}
+		return WithCode(errors.New("body should'nt contain more than one json value"), CodeInvalidJSON)
+	}
+
+	return nil
+}
+
+// ClassifyError converts an error returned by a json.Decoder into an error
+// message suitable for the client, with the code CodeInvalidJSON, or
+// CodePayloadTooLarge when the body was too large. maxBytes is the size limit
+// reported in that case.
+func ClassifyError(err error, maxBytes int) error {
+	var syntaxError *json.SyntaxError
+	var unmarshalTypeError *json.UnmarshalTypeError
+	var invalidUnmarshalError *json.InvalidUnmarshalError
+	var maxBytesError *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &syntaxError):
+		return WithCode(fmt.Errorf("body contains badly-formed JSON (at position %d)", syntaxError.Offset), CodeInvalidJSON)
+
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return WithCode(errors.New("body contains badly-formed JSON"), CodeInvalidJSON)
+
+	case errors.As(err, &unmarshalTypeError):
+		if unmarshalTypeError.Field != "" {
+			return WithCode(fmt.Errorf("body contains incorrect JSON type for field %q", unmarshalTypeError.Field), CodeInvalidJSON)
+		}
+		return WithCode(fmt.Errorf("body contains an invalid JSON type at position %d", unmarshalTypeError.Offset), CodeInvalidJSON)
+
+	case errors.Is(err, io.EOF):
+		return WithCode(errors.New("body must not be empty"), CodeInvalidJSON)
+
+	case strings.HasPrefix(err.Error(), "json: unknown field"):
+		fieldName := strings.TrimPrefix(err.Error(), "json: unknown field")
+		return WithCode(fmt.Errorf("body contains unknown key %s", fieldName), CodeInvalidJSON)
+
+	case errors.As(err, &maxBytesError), err.Error() == "http: request body too large":
+		return WithCode(fmt.Errorf("body must not be larger than %d bytes", maxBytes), CodePayloadTooLarge)
+
+	case isCorrupt(err):
+		return WithCode(fmt.Errorf("body is not valid compressed data: %w", err), CodeBadRequest)
+
+	case errors.As(err, &invalidUnmarshalError):
+		return fmt.Errorf("error unmarshalling JSON: %s", invalidUnmarshalError)
+
+	default:
+		return err
+	}
+}
+
+// Write marshals data and writes it to w with the given status code and the
+// optional headers.
+func Write(w http.ResponseWriter, status int, data any, headers ...http.Header) error {
+	return WriteIndent(w, status, data, "", headers...)
+}
+
+// WriteIndent writes data like Write, indented with indent, e.g. two spaces, and
+// followed by a newline, for responses read by humans. An empty indent writes
+// compact JSON like Write.
+func WriteIndent(w http.ResponseWriter, status int, data any, indent string, headers ...http.Header) error {
+	var out []byte
+	var err error
+	if indent == "" {
+		out, err = json.Marshal(data)
+	} else {
+		out, err = json.MarshalIndent(data, "", indent)
+		out = append(out, '\n')
+	}
+	if err != nil {
+		return err
+	}
+	if len(headers) > 0 {
+		for key, value := range headers[0] {
+			w.Header()[key] = value
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err = w.Write(out); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Error writes err as an error Response with the given status code, or
+// 500 Internal Server Error if none is provided. The Code of the response is
+// the code of err, see Code, or else the code of the status. The Data of the
+// response is the result of the ErrorData() any method of the first error in
+// the chain of err having one, such as a list of invalid fields.
+func Error(w http.ResponseWriter, err error, status ...int) error {
+	statusCode := http.StatusInternalServerError
+	if len(status) > 0 {
+		statusCode = status[0]
+	}
+
+	return Write(w, statusCode, ErrorResponse(err, statusCode))
+}
+
+// ErrorResponse returns the error Response Error writes for err with the
+// given status code, e.g. to wrap it in another envelope.
+func ErrorResponse(err error, status int) Response {
+	code := Code(err)
+	if code == "" {
+		code = StatusCode(status)
+	}
+
+	res := Response{Error: true, Message: err.Error(), Code: code}
+	var withData interface{ ErrorData() any }
+	if errors.As(err, &withData) {
+		res.Data = withData.ErrorData()
+	}
+	return res
+}
@@ -0,0 +1,132 @@
+package jsonx
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// decodeTests is a slice of structs that hold the name of the test, the body, a boolean
+// that indicates if unknown fields are allowed and the expected error message prefix
+var decodeTests = []struct {
+	name               string
+	body               string
+	allowUnknownFields bool
+	errorPrefix        string
+}{
+	{"valid", `{"foo":"bar"}`, false, ""},
+	{"unknown field", `{"fooo":"bar"}`, false, "body contains unknown key"},
+	{"allowed unknown field", `{"fooo":"bar"}`, true, ""},
+	{"syntax", `{"foo":"bar",}`, false, "body contains badly-formed JSON (at position"},
+	{"truncated", `{"foo":`, false, "body contains badly-formed JSON"},
+	{"type", `{"foo":1}`, false, `body contains incorrect JSON type for field "foo"`},
+	{"empty", ``, false, "body must not be empty"},
+	{"two values", `{"foo":"a"}{"foo":"b"}`, false, "body should'nt contain more than one json value"},
+}
+
+// TestDecode tests the classification of decoding errors.
+func TestDecode(t *testing.T) {
+	for _, dt := range decodeTests {
+		var decoded struct {
+			Foo string `json:"foo"`
+		}
+		err := Decode(strings.NewReader(dt.body), &decoded, DefaultMaxBytes, dt.allowUnknownFields)
+
+		if dt.errorPrefix == "" {
+			if err != nil {
+				t.Errorf("%s: %s", dt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.HasPrefix(err.Error(), dt.errorPrefix) {
+			t.Errorf("%s: expected error starting with %q, got %v", dt.name, dt.errorPrefix, err)
+		}
+	}
+}
+
+// TestRead tests that Read enforces the size limit.
+func TestRead(t *testing.T) {
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{"foo":"`+strings.Repeat("x", 100)+`"}`))
+	var decoded map[string]string
+	err := Read(httptest.NewRecorder(), req, &decoded, 50, false)
+	if err == nil || err.Error() != "body must not be larger than 50 bytes" {
+		t.Errorf("expected size error, got %v", err)
+	}
+}
+
+// TestError tests that Error writes an error Response with the given status.
+func TestError(t *testing.T) {
+	rr := httptest.NewRecorder()
+	if err := Error(rr, errors.New("boom"), 418); err != nil {
+		t.Fatal(err)
+	}
+
+	var res Response
+	if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if rr.Code != 418 || !res.Error || res.Message != "boom" {
+		t.Errorf("unexpected response %d %+v", rr.Code, res)
+	}
+}
+
+// dataError is an error carrying response data.
+type dataError struct{}
+
+func (dataError) Error() string  { return "invalid" }
+func (dataError) ErrorData() any { return []string{"name"} }
+
+// TestError_data tests that the data of errors is written as the Data of the response.
+func TestError_data(t *testing.T) {
+	rr := httptest.NewRecorder()
+	if err := Error(rr, fmt.Errorf("wrapped: %w", dataError{}), 422); err != nil {
+		t.Fatal(err)
+	}
+	if body := rr.Body.String(); !strings.Contains(body, `"data":["name"]`) {
+		t.Errorf("expected the data of the error, got %s", body)
+	}
+}
+
+// TestWriteIndent tests that responses are indented, and compact without indent.
+func TestWriteIndent(t *testing.T) {
+	data := map[string]any{"name": "ana", "tags": []string{"a"}}
+
+	rr := httptest.NewRecorder()
+	if err := WriteIndent(rr, 201, data, "  "); err != nil {
+		t.Fatal(err)
+	}
+	want := "{\n  \"name\": \"ana\",\n  \"tags\": [\n    \"a\"\n  ]\n}\n"
+	if rr.Code != 201 || rr.Body.String() != want {
+		t.Errorf("expected %q, got %d %q", want, rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	if err := WriteIndent(rr, 200, data, ""); err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"name":"ana","tags":["a"]}`; rr.Body.String() != want {
+		t.Errorf("expected %q, got %q", want, rr.Body.String())
+	}
+}
+
+// FuzzDecode checks that Decode never panics on arbitrary bodies and that successfully
+// decoded bodies are valid JSON.
+func FuzzDecode(f *testing.F) {
+	for _, dt := range decodeTests {
+		f.Add([]byte(dt.body), dt.allowUnknownFields)
+	}
+
+	f.Fuzz(func(t *testing.T, body []byte, allowUnknownFields bool) {
+		var decoded struct {
+			Foo string `json:"foo"`
+		}
+		err := Decode(bytes.NewReader(body), &decoded, 1024, allowUnknownFields)
+		if err == nil && !json.Valid(bytes.TrimSpace(body)) {
+			t.Fatalf("decoded invalid JSON %q", body)
+		}
+	})
+}
@@ -0,0 +1,143 @@
+package jsonx
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// WriteLines writes the values received on lines to w as newline-delimited
+// JSON (NDJSON), one value per line, until lines is closed. The response is
+// flushed whenever no other value is waiting, so clients receive the values as
+// they are produced. On a write error, e.g. once the client went away, the
+// values left on lines are not received, so producers should also stop on the
+// cancellation of the request context.
+func WriteLines(w http.ResponseWriter, lines <-chan any) error {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+	for v := range lines {
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		if len(lines) == 0 {
+			if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ReadLines reads newline-delimited JSON (NDJSON) from r and calls fn with each
+// value, in order, stopping at the first error returned by fn. Blank lines are
+// skipped. Lines are limited to maxLineBytes, or to DefaultMaxBytes if
+// maxLineBytes is zero. Malformed and too long lines are reported with their
+// line number and the codes CodeInvalidJSON and CodePayloadTooLarge.
+func ReadLines(r io.Reader, fn func(json.RawMessage) error, maxLineBytes int) error {
+	if maxLineBytes == 0 {
+		maxLineBytes = DefaultMaxBytes
+	}
+
+	scanner := bufio.NewScanner(r)
+	// the buffer holds the line and its newline
+	scanner.Buffer(make([]byte, 0, min(maxLineBytes+1, 64*1024)), maxLineBytes+1)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) > maxLineBytes {
+			return WithCode(fmt.Errorf("line %d must not be larger than %d bytes", line, maxLineBytes), CodePayloadTooLarge)
+		}
+		value := bytes.TrimSpace(scanner.Bytes())
+		if len(value) == 0 {
+			continue
+		}
+		if !json.Valid(value) {
+			return WithCode(fmt.Errorf("line %d contains badly-formed JSON", line), CodeInvalidJSON)
+		}
+		if err := fn(bytes.Clone(value)); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return WithCode(fmt.Errorf("line %d must not be larger than %d bytes", line+1, maxLineBytes), CodePayloadTooLarge)
+		}
+		return ClassifyError(err, maxLineBytes)
+	}
+	return nil
+}
+
+// ReadStream reads a stream of concatenated JSON values from r, such as
+// `{"id":1}{"id":2}` or values separated by whitespace, and calls fn with each
+// value, in order, stopping at the first error returned by fn. Unlike Decode, it
+// accepts any number of top-level values, e.g. for bulk-ingest endpoints. Each
+// value is limited to maxBytes, or to DefaultMaxBytes if maxBytes is zero, while
+// the stream may be of any size. Malformed and too large values are reported
+// with their index, from 1, and the codes CodeInvalidJSON and
+// CodePayloadTooLarge.
+func ReadStream(r io.Reader, fn func(json.RawMessage) error, maxBytes int) error {
+	if maxBytes == 0 {
+		maxBytes = DefaultMaxBytes
+	}
+
+	lr := &valueLimitReader{r: r, limit: int64(maxBytes) + 1}
+	decoder := json.NewDecoder(lr)
+	for n := 1; ; n++ {
+		var value json.RawMessage
+		err := decoder.Decode(&value)
+		if err == io.EOF {
+			return nil
+		}
+		if errors.Is(err, errValueTooLarge) || len(value) > maxBytes {
+			return WithCode(fmt.Errorf("value %d must not be larger than %d bytes", n, maxBytes), CodePayloadTooLarge)
+		}
+		if err != nil {
+			err = ClassifyError(err, maxBytes)
+			if Code(err) == CodeInvalidJSON {
+				return WithCode(fmt.Errorf("value %d contains badly-formed JSON", n), CodeInvalidJSON)
+			}
+			return err
+		}
+		if err := fn(value); err != nil {
+			return err
+		}
+		// the next value may be read up to maxBytes past the end of this one
+		lr.start = decoder.InputOffset()
+	}
+}
+
+// errValueTooLarge is returned by a valueLimitReader reading past its limit.
+var errValueTooLarge = errors.New("value too large")
+
+// valueLimitReader is a reader failing once more than limit bytes past start,
+// the offset of the value being decoded, were read, so a json.Decoder never
+// buffers more than a value of limit bytes.
+type valueLimitReader struct {
+	r     io.Reader
+	read  int64
+	start int64
+	limit int64
+}
+
+// Read reads from the underlying reader up to the limit of the current value.
+func (l *valueLimitReader) Read(p []byte) (int, error) {
+	left := l.start + l.limit - l.read
+	if left <= 0 {
+		return 0, errValueTooLarge
+	}
+	if int64(len(p)) > left {
+		p = p[:left]
+	}
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	return n, err
+}
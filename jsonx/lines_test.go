@@ -0,0 +1,135 @@
+package jsonx
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// readLinesTests is a slice of structs that hold the name of the test, the body, the
+// maximum line size, the expected number of values and the expected error message
+var readLinesTests = []struct {
+	name         string
+	body         string
+	maxLineBytes int
+	values       int
+	errorMessage string
+}{
+	{"values", "{\"a\":1}\n[1,2]\n\"x\"\n", 0, 3, ""},
+	{"crlf and blank lines", "{\"a\":1}\r\n\r\n{\"a\":2}", 0, 2, ""},
+	{"empty", "", 0, 0, ""},
+	{"malformed", "{\"a\":1}\n{\"a\":\n", 0, 1, "line 2 contains badly-formed JSON"},
+	{"too long", "{\"a\":1}\n\"" + strings.Repeat("x", 20) + "\"\n", 10, 1, "line 2 must not be larger than 10 bytes"},
+	{"too long at end", "\"" + strings.Repeat("x", 9) + "\"", 10, 0, "line 1 must not be larger than 10 bytes"},
+}
+
+// TestReadLines tests the values and errors of ReadLines.
+func TestReadLines(t *testing.T) {
+	for _, rt := range readLinesTests {
+		var values []json.RawMessage
+		err := ReadLines(strings.NewReader(rt.body), func(v json.RawMessage) error {
+			values = append(values, v)
+			return nil
+		}, rt.maxLineBytes)
+
+		if rt.errorMessage == "" && err != nil {
+			t.Errorf("%s: %s", rt.name, err)
+		}
+		if rt.errorMessage != "" && (err == nil || err.Error() != rt.errorMessage) {
+			t.Errorf("%s: expected error %q, got %v", rt.name, rt.errorMessage, err)
+		}
+		if len(values) != rt.values {
+			t.Errorf("%s: expected %d values, got %d", rt.name, rt.values, len(values))
+		}
+	}
+}
+
+// TestReadLinesStop tests that ReadLines returns the error of the callback.
+func TestReadLinesStop(t *testing.T) {
+	stop := errors.New("stop")
+	calls := 0
+	err := ReadLines(strings.NewReader("1\n2\n3\n"), func(json.RawMessage) error {
+		calls++
+		return stop
+	}, 0)
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("expected the callback error after 1 call, got %v after %d", err, calls)
+	}
+}
+
+// TestWriteLines tests that WriteLines writes one value per line.
+func TestWriteLines(t *testing.T) {
+	lines := make(chan any)
+	go func() {
+		defer close(lines)
+		lines <- map[string]int{"a": 1}
+		lines <- "x"
+	}()
+
+	rr := httptest.NewRecorder()
+	if err := WriteLines(rr, lines); err != nil {
+		t.Fatal(err)
+	}
+
+	if rr.Body.String() != "{\"a\":1}\n\"x\"\n" {
+		t.Errorf("unexpected body %q", rr.Body.String())
+	}
+	if rr.Header().Get("Content-Type") != "application/x-ndjson" || !rr.Flushed {
+		t.Errorf("expected a flushed NDJSON response, got %q flushed %v", rr.Header().Get("Content-Type"), rr.Flushed)
+	}
+}
+
+// readStreamTests is a slice of structs that hold the name of the test, the body, the
+// maximum value size, the expected values and the expected error message
+var readStreamTests = []struct {
+	name         string
+	body         string
+	maxBytes     int
+	values       []string
+	errorMessage string
+}{
+	{"concatenated", `{"a":1}{"a":2}[3]"x"`, 0, []string{`{"a":1}`, `{"a":2}`, `[3]`, `"x"`}, ""},
+	{"whitespace", " {\"a\":1}\n\n\t2 3 ", 0, []string{`{"a":1}`, `2`, `3`}, ""},
+	{"empty", "", 0, nil, ""},
+	{"malformed", `{"a":1}{"a":`, 0, []string{`{"a":1}`}, "value 2 contains badly-formed JSON"},
+	{"syntax error", `{"a":1}]`, 0, []string{`{"a":1}`}, "value 2 contains badly-formed JSON"},
+	{"too large", `{"a":1}"` + strings.Repeat("x", 40) + `"`, 10, []string{`{"a":1}`}, "value 2 must not be larger than 10 bytes"},
+	{"many small values", strings.Repeat(`{"a":1}`, 100), 10, slices.Repeat([]string{`{"a":1}`}, 100), ""},
+}
+
+// TestReadStream tests the values and errors of ReadStream.
+func TestReadStream(t *testing.T) {
+	for _, rt := range readStreamTests {
+		var values []string
+		err := ReadStream(strings.NewReader(rt.body), func(v json.RawMessage) error {
+			values = append(values, string(v))
+			return nil
+		}, rt.maxBytes)
+
+		if rt.errorMessage == "" && err != nil {
+			t.Errorf("%s: %s", rt.name, err)
+		}
+		if rt.errorMessage != "" && (err == nil || err.Error() != rt.errorMessage) {
+			t.Errorf("%s: expected error %q, got %v", rt.name, rt.errorMessage, err)
+		}
+		if !slices.Equal(values, rt.values) {
+			t.Errorf("%s: expected values %v, got %v", rt.name, rt.values, values)
+		}
+	}
+}
+
+// TestReadStreamStop tests that ReadStream returns the error of the callback.
+func TestReadStreamStop(t *testing.T) {
+	stop := errors.New("stop")
+	calls := 0
+	err := ReadStream(strings.NewReader("1 2 3"), func(json.RawMessage) error {
+		calls++
+		return stop
+	}, 0)
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("expected the callback error after 1 call, got %v after %d", err, calls)
+	}
+}
@@ -0,0 +1,457 @@
+package jsonx
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Naming is the strategy naming the JSON fields of structs that have no name
+// in their json tag.
+type Naming int
+
+const (
+	// NamingDefault names fields after the Go field, as encoding/json does.
+	NamingDefault Naming = iota
+	// NamingSnakeCase names fields in snake_case, e.g. UserID becomes user_id.
+	NamingSnakeCase
+	// NamingCamelCase names fields in camelCase, e.g. UserID becomes userId.
+	NamingCamelCase
+)
+
+// Name returns the JSON name of the Go field name.
+func (n Naming) Name(field string) string {
+	words := splitWords(field)
+	switch n {
+	case NamingSnakeCase:
+		for i, word := range words {
+			words[i] = strings.ToLower(word)
+		}
+		return strings.Join(words, "_")
+	case NamingCamelCase:
+		for i, word := range words {
+			word = strings.ToLower(word)
+			if i > 0 {
+				r := []rune(word)
+				r[0] = unicode.ToUpper(r[0])
+				word = string(r)
+			}
+			words[i] = word
+		}
+		return strings.Join(words, "")
+	}
+	return field
+}
+
+// splitWords splits a Go identifier into its words, keeping acronyms
+// together, e.g. HTTPServerID into HTTP, Server and ID.
+func splitWords(name string) []string {
+	var words []string
+	runes := []rune(name)
+	start := 0
+	for i := 0; i < len(runes); i++ {
+		if runes[i] == '_' {
+			if i > start {
+				words = append(words, string(runes[start:i]))
+			}
+			start = i + 1
+			continue
+		}
+		if i == start {
+			continue
+		}
+		prev, cur := runes[i-1], runes[i]
+		lowerToUpper := (unicode.IsLower(prev) || unicode.IsDigit(prev)) && unicode.IsUpper(cur)
+		acronymEnd := unicode.IsUpper(prev) && unicode.IsUpper(cur) && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+		if lowerToUpper || acronymEnd {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	if start < len(runes) {
+		words = append(words, string(runes[start:]))
+	}
+	return words
+}
+
+// Rename returns a value encoding like data, except that the fields of structs
+// without a name in their json tag are named by n. The json tag options, the
+// promotion of the fields of embedded structs and the types implementing
+// json.Marshaler or encoding.TextMarshaler are handled like encoding/json does.
+// As data is copied into maps and slices, Rename suits responses rather than
+// large streamed payloads.
+func Rename(data any, n Naming) any {
+	if n == NamingDefault {
+		return data
+	}
+	return rename(reflect.ValueOf(data), n)
+}
+
+var (
+	marshalerType     = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+	unmarshalerType   = reflect.TypeFor[json.Unmarshaler]()
+	anyType           = reflect.TypeFor[any]()
+)
+
+// rename is the implementation of Rename.
+func rename(v reflect.Value, n Naming) any {
+	if !v.IsValid() {
+		return nil
+	}
+	t := v.Type()
+	if t.Implements(marshalerType) || t.Implements(textMarshalerType) {
+		return v.Interface()
+	}
+	if v.CanAddr() && (reflect.PointerTo(t).Implements(marshalerType) || reflect.PointerTo(t).Implements(textMarshalerType)) {
+		return v.Addr().Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return rename(v.Elem(), n)
+
+	case reflect.Struct:
+		obj := make(object, 0, t.NumField())
+		for _, f := range typeFields(t) {
+			fv, err := v.FieldByIndexErr(f.index)
+			if err != nil || (f.omitEmpty && isEmptyValue(fv)) {
+				continue
+			}
+			name := f.name
+			if !f.tagged {
+				name = n.Name(f.name)
+			}
+			var value any
+			if f.quoted {
+				value = quote(fv)
+			} else {
+				value = rename(fv, n)
+			}
+			obj = append(obj, member{name: name, value: value})
+		}
+		return obj
+
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		m := reflect.MakeMapWithSize(reflect.MapOf(t.Key(), anyType), v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			value := rename(iter.Value(), n)
+			m.SetMapIndex(iter.Key(), reflect.ValueOf(&value).Elem())
+		}
+		return m.Interface()
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 {
+			// byte slices encode as base64 strings
+			return v.Interface()
+		}
+		fallthrough
+
+	case reflect.Array:
+		items := make([]any, v.Len())
+		for i := range items {
+			items[i] = rename(v.Index(i), n)
+		}
+		return items
+	}
+
+	return v.Interface()
+}
+
+// quote returns the value of a field with the string tag option, encoded
+// as a JSON string holding its JSON encoding.
+func quote(v reflect.Value) any {
+	switch v.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.String:
+		out, err := json.Marshal(v.Interface())
+		if err != nil {
+			return v.Interface()
+		}
+		return string(out)
+	}
+	return v.Interface()
+}
+
+// member is a field of an object.
+type member struct {
+	name  string
+	value any
+}
+
+// object is a JSON object whose fields are encoded in order, like the fields
+// of the struct it was built from.
+type object []member
+
+// MarshalJSON implements json.Marshaler.
+func (o object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(m.name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// field is a JSON field of a struct type.
+type field struct {
+	// name is the name given by encoding/json, the tag name or the Go name
+	name string
+	// tagged is a boolean that indicates if the name comes from the tag
+	tagged    bool
+	index     []int
+	typ       reflect.Type
+	omitEmpty bool
+	quoted    bool
+}
+
+// fieldCache holds the fields of the struct types seen by typeFields.
+var fieldCache sync.Map // map[reflect.Type][]field
+
+// typeFields returns the JSON fields of the struct type t, including the
+// fields promoted from embedded structs. A promoted field is hidden by a
+// field of the same name at a shallower depth; among fields of the same depth,
+// the first one wins.
+func typeFields(t reflect.Type) []field {
+	if cached, ok := fieldCache.Load(t); ok {
+		return cached.([]field)
+	}
+
+	var fields []field
+	seen := make(map[string]bool)
+	type level struct {
+		typ   reflect.Type
+		index []int
+	}
+	current := []level{{typ: t}}
+	visited := map[reflect.Type]bool{t: true}
+	for len(current) > 0 {
+		var next []level
+		depthNames := make(map[string]bool)
+		for _, l := range current {
+			for i := range l.typ.NumField() {
+				sf := l.typ.Field(i)
+				tag := sf.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				name, opts, _ := strings.Cut(tag, ",")
+				index := append(append([]int(nil), l.index...), i)
+
+				ft := sf.Type
+				if ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+					if !visited[ft] {
+						visited[ft] = true
+						next = append(next, level{typ: ft, index: index})
+					}
+					continue
+				}
+				if !sf.IsExported() {
+					continue
+				}
+
+				f := field{name: sf.Name, index: index, typ: sf.Type}
+				if name != "" {
+					f.name, f.tagged = name, true
+				}
+				for _, opt := range strings.Split(opts, ",") {
+					switch opt {
+					case "omitempty":
+						f.omitEmpty = true
+					case "string":
+						f.quoted = true
+					}
+				}
+				if seen[f.name] || depthNames[f.name] {
+					continue
+				}
+				depthNames[f.name] = true
+				fields = append(fields, f)
+			}
+		}
+		for name := range depthNames {
+			seen[name] = true
+		}
+		current = next
+	}
+	slices.SortFunc(fields, func(a, b field) int { return slices.Compare(a.index, b.index) })
+
+	fieldCache.Store(t, fields)
+	return fields
+}
+
+// isEmptyValue reports whether v is empty according to the omitempty tag
+// option.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
+
+// DecodeTolerant decodes body into v like Decode, except that the keys of
+// objects decoded into structs match their fields whatever their naming
+// strategy, e.g. user_id, userId and UserID all match the field UserID, so
+// snake_case and camelCase clients are served by the same structs. When an
+// object holds several keys of the same field, the exact name wins. As the body
+// is decoded twice, the positions of type errors may differ from the body.
+func DecodeTolerant(body io.Reader, v any, maxBytes int, allowUnknownFields bool) error {
+	decoder := json.NewDecoder(body)
+	decoder.UseNumber()
+	var raw any
+	if err := decoder.Decode(&raw); err != nil {
+		return ClassifyError(err, maxBytes)
+	}
+	if err := decoder.Decode(&struct{}{}); err != io.EOF {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			return ClassifyError(err, maxBytes)
+		}
+		return WithCode(errors.New("body should'nt contain more than one json value"), CodeInvalidJSON)
+	}
+
+	out, err := json.Marshal(matchFields(raw, reflect.TypeOf(v)))
+	if err != nil {
+		return err
+	}
+	return Decode(bytes.NewReader(out), v, maxBytes, allowUnknownFields)
+}
+
+// NormalizeKeys returns the JSON value data with the keys of the objects that
+// would be decoded into structs of v renamed to the names of their fields, like
+// DecodeTolerant, e.g. to validate the value against a JSON Schema written for
+// these names or to tell which fields it holds. Keys matching no field are kept.
+// Numbers are kept as written.
+func NormalizeKeys(data []byte, v any) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var raw any
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, err
+	}
+	return json.Marshal(matchFields(raw, reflect.TypeOf(v)))
+}
+
+// matchFields renames the keys of the objects of raw decoded into structs of
+// type t to the names of their fields.
+func matchFields(raw any, t reflect.Type) any {
+	for t != nil {
+		if t.Implements(unmarshalerType) || reflect.PointerTo(t).Implements(unmarshalerType) {
+			return raw
+		}
+		if t.Kind() != reflect.Pointer {
+			break
+		}
+		t = t.Elem()
+	}
+	if t == nil {
+		return raw
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := raw.(map[string]any)
+		if !ok {
+			return raw
+		}
+		fields := typeFields(t)
+		out := make(map[string]any, len(obj))
+		for key, value := range obj {
+			f, ok := lookupField(fields, key)
+			if !ok {
+				out[key] = value
+				continue
+			}
+			if _, exists := out[f.name]; exists && key != f.name {
+				continue
+			}
+			out[f.name] = matchFields(value, f.typ)
+		}
+		return out
+
+	case reflect.Map:
+		obj, ok := raw.(map[string]any)
+		if !ok {
+			return raw
+		}
+		for key, value := range obj {
+			obj[key] = matchFields(value, t.Elem())
+		}
+		return obj
+
+	case reflect.Slice, reflect.Array:
+		items, ok := raw.([]any)
+		if !ok {
+			return raw
+		}
+		for i, item := range items {
+			items[i] = matchFields(item, t.Elem())
+		}
+		return items
+	}
+	return raw
+}
+
+// lookupField returns the field of fields matching key, ignoring case and
+// underscores.
+func lookupField(fields []field, key string) (field, bool) {
+	for _, f := range fields {
+		if f.name == key {
+			return f, true
+		}
+	}
+	normalized := normalizeKey(key)
+	for _, f := range fields {
+		if normalizeKey(f.name) == normalized {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// normalizeKey returns key in lower case without underscores.
+func normalizeKey(key string) string {
+	return strings.ToLower(strings.ReplaceAll(key, "_", ""))
+}
@@ -0,0 +1,151 @@
+package jsonx
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// namingTests is a slice of structs that hold a Go field name and its snake_case and
+// camelCase names
+var namingTests = []struct {
+	field string
+	snake string
+	camel string
+}{
+	{"Name", "name", "name"},
+	{"UserID", "user_id", "userId"},
+	{"HTTPServer", "http_server", "httpServer"},
+	{"Page2Size", "page2_size", "page2Size"},
+	{"Legacy_Field", "legacy_field", "legacyField"},
+	{"ID", "id", "id"},
+}
+
+// TestNaming_Name tests the names of the naming strategies.
+func TestNaming_Name(t *testing.T) {
+	for _, nt := range namingTests {
+		if got := NamingSnakeCase.Name(nt.field); got != nt.snake {
+			t.Errorf("%s: expected snake case %s, got %s", nt.field, nt.snake, got)
+		}
+		if got := NamingCamelCase.Name(nt.field); got != nt.camel {
+			t.Errorf("%s: expected camel case %s, got %s", nt.field, nt.camel, got)
+		}
+		if got := NamingDefault.Name(nt.field); got != nt.field {
+			t.Errorf("%s: expected the Go name, got %s", nt.field, got)
+		}
+	}
+}
+
+// namingBase is embedded in namingUser to test promoted fields.
+type namingBase struct {
+	CreatedAt time.Time
+}
+
+// namingUser is the struct renamed by the naming tests.
+type namingUser struct {
+	UserID   int
+	FullName string `json:"name"`
+	Nickname string `json:",omitempty"`
+	Secret   string `json:"-"`
+	Count    int    `json:"count,string"`
+	Tags     map[string]namingTag
+	Friends  []*namingUser `json:",omitempty"`
+	namingBase
+}
+
+// namingTag is a nested struct of namingUser.
+type namingTag struct {
+	TagColor string
+}
+
+// TestRename tests that only the fields without a tag name are renamed.
+func TestRename(t *testing.T) {
+	user := namingUser{
+		UserID:     1,
+		FullName:   "Jane",
+		Secret:     "x",
+		Count:      3,
+		Tags:       map[string]namingTag{"a": {TagColor: "red"}},
+		Friends:    []*namingUser{{UserID: 2}},
+		namingBase: namingBase{CreatedAt: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+	}
+
+	out, err := json.Marshal(Rename(user, NamingSnakeCase))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"user_id":1,"name":"Jane","count":"3","tags":{"a":{"tag_color":"red"}},` +
+		`"friends":[{"user_id":2,"name":"","count":"0","tags":null,"created_at":"0001-01-01T00:00:00Z"}],` +
+		`"created_at":"2024-01-02T00:00:00Z"}`
+	if string(out) != expected {
+		t.Errorf("expected %s, got %s", expected, out)
+	}
+
+	out, err = json.Marshal(Rename(&user, NamingCamelCase))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(out), `{"userId":1,"name":"Jane"`) {
+		t.Errorf("expected camel case fields, got %s", out)
+	}
+
+	if _, ok := Rename(user, NamingDefault).(namingUser); !ok {
+		t.Error("expected the default naming to return the data as is")
+	}
+}
+
+// tolerantTests is a slice of structs that hold the name of the test, the body and the
+// expected user ID and tag color
+var tolerantTests = []struct {
+	name     string
+	body     string
+	userID   int
+	tagColor string
+}{
+	{"go names", `{"UserID":1,"Tags":{"a":{"TagColor":"red"}}}`, 1, "red"},
+	{"snake case", `{"user_id":2,"tags":{"a":{"tag_color":"blue"}}}`, 2, "blue"},
+	{"camel case", `{"userId":3,"tags":{"a":{"tagColor":"green"}}}`, 3, "green"},
+	{"exact name wins", `{"user_id":4,"UserID":5}`, 5, ""},
+}
+
+// TestDecodeTolerant tests that keys in any naming match the fields of structs.
+func TestDecodeTolerant(t *testing.T) {
+	for _, tt := range tolerantTests {
+		var user namingUser
+		if err := DecodeTolerant(strings.NewReader(tt.body), &user, DefaultMaxBytes, false); err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if user.UserID != tt.userID || user.Tags["a"].TagColor != tt.tagColor {
+			t.Errorf("%s: expected user %d with color %q, got %+v", tt.name, tt.userID, tt.tagColor, user)
+		}
+	}
+
+	var user namingUser
+	err := DecodeTolerant(strings.NewReader(`{"user_ident":1}`), &user, DefaultMaxBytes, false)
+	if err == nil || !strings.HasPrefix(err.Error(), "body contains unknown key") {
+		t.Errorf("expected an unknown key error, got %v", err)
+	}
+	err = DecodeTolerant(strings.NewReader(`{"user_id":1}{}`), &user, DefaultMaxBytes, false)
+	if err == nil || Code(err) != CodeInvalidJSON {
+		t.Errorf("expected an invalid JSON error, got %v", err)
+	}
+}
+
+// TestNormalizeKeys tests that keys are renamed to the names of the fields, nested
+// ones included, and that other keys and numbers are kept as written.
+func TestNormalizeKeys(t *testing.T) {
+	out, err := NormalizeKeys([]byte(`{"user_id":1.50,"NAME":"ana","friends":[{"userId":2}],"extra_key":{"user_id":3}}`), &namingUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"Friends":[{"UserID":2}],"UserID":1.50,"extra_key":{"user_id":3},"name":"ana"}`
+	if string(out) != want {
+		t.Errorf("expected %s, got %s", want, out)
+	}
+
+	if _, err := NormalizeKeys([]byte(`{"user_id":`), &namingUser{}); err == nil {
+		t.Error("expected an error for badly-formed JSON")
+	}
+}
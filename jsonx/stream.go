@@ -0,0 +1,67 @@
+package jsonx
+
+import (
+	"encoding/json"
+	"io"
+	"iter"
+	"net/http"
+)
+
+// Stream encodes data directly to w with the given status code and the
+// optional headers, without buffering the whole payload like Write does. As
+// the status is sent before data is encoded, an encoding error leaves the
+// client with a truncated body, so Stream suits large values already known to
+// encode, such as slices of plain structs.
+func Stream(w http.ResponseWriter, status int, data any, headers ...http.Header) error {
+	writeStreamHeader(w, status, headers)
+	return json.NewEncoder(w).Encode(data)
+}
+
+// StreamArray writes the values of items to w as a JSON array, encoding each
+// value as it is produced, e.g. rows read from a database cursor. Values sent
+// on a channel ch can be streamed with a sequence such as
+//
+//	func(yield func(T) bool) {
+//		for v := range ch {
+//			if !yield(v) {
+//				return
+//			}
+//		}
+//	}
+//
+// The iteration stops at the first encoding or write error, which leaves the
+// client with a truncated array.
+func StreamArray[T any](w http.ResponseWriter, status int, items iter.Seq[T], headers ...http.Header) error {
+	writeStreamHeader(w, status, headers)
+
+	enc := json.NewEncoder(w)
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	first := true
+	for item := range items {
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		first = false
+		if err := enc.Encode(item); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "]\n")
+	return err
+}
+
+// writeStreamHeader sets the optional headers and the JSON content type, and
+// sends the status code.
+func writeStreamHeader(w http.ResponseWriter, status int, headers []http.Header) {
+	if len(headers) > 0 {
+		for key, value := range headers[0] {
+			w.Header()[key] = value
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+}
@@ -0,0 +1,79 @@
+package jsonx
+
+import (
+	"encoding/json"
+	"errors"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+// streamArrayTests is a slice of structs that hold the name of the test, the streamed
+// values and the expected body
+var streamArrayTests = []struct {
+	name     string
+	items    []int
+	expected string
+}{
+	{"empty", nil, "[]\n"},
+	{"one", []int{1}, "[1\n]\n"},
+	{"several", []int{1, 2, 3}, "[1\n,2\n,3\n]\n"},
+}
+
+// TestStreamArray tests that streamed values form a valid JSON array.
+func TestStreamArray(t *testing.T) {
+	for _, st := range streamArrayTests {
+		rr := httptest.NewRecorder()
+		if err := StreamArray(rr, http.StatusOK, slices.Values(st.items)); err != nil {
+			t.Errorf("%s: %s", st.name, err)
+			continue
+		}
+
+		if rr.Body.String() != st.expected {
+			t.Errorf("%s: expected body %q, got %q", st.name, st.expected, rr.Body.String())
+		}
+		var decoded []int
+		if err := json.Unmarshal(rr.Body.Bytes(), &decoded); err != nil || len(decoded) != len(st.items) {
+			t.Errorf("%s: expected a valid array of %d values, got %v (%v)", st.name, len(st.items), decoded, err)
+		}
+		if rr.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("%s: expected JSON content type, got %q", st.name, rr.Header().Get("Content-Type"))
+		}
+	}
+}
+
+// TestStreamArrayError tests that the iteration stops at the first encoding error.
+func TestStreamArrayError(t *testing.T) {
+	var produced int
+	items := iter.Seq[any](func(yield func(any) bool) {
+		for _, v := range []any{1, func() {}, 3} {
+			produced++
+			if !yield(v) {
+				return
+			}
+		}
+	})
+
+	var unsupported *json.UnsupportedTypeError
+	if err := StreamArray(httptest.NewRecorder(), http.StatusOK, items); !errors.As(err, &unsupported) {
+		t.Errorf("expected an unsupported type error, got %v", err)
+	}
+	if produced != 2 {
+		t.Errorf("expected the iteration to stop after 2 values, got %d", produced)
+	}
+}
+
+// TestStream tests that Stream writes the headers, the status and the value.
+func TestStream(t *testing.T) {
+	rr := httptest.NewRecorder()
+	err := Stream(rr, http.StatusCreated, map[string]string{"foo": "bar"}, http.Header{"X-Request-Id": {"42"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rr.Code != http.StatusCreated || rr.Header().Get("X-Request-Id") != "42" || rr.Body.String() != "{\"foo\":\"bar\"}\n" {
+		t.Errorf("unexpected response %d %v %q", rr.Code, rr.Header(), rr.Body.String())
+	}
+}
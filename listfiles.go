@@ -0,0 +1,269 @@
+package gorigumi
+
+import (
+	"cmp"
+	"container/heap"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultListFilesLimit is the default number of entries of a page
+	// it is inlcuded in the ListFilesPage method
+	defaultListFilesLimit int = 100
+
+	// maxListFilesLimit is the maximum number of entries of a page
+	// it is inlcuded in the ListFilesPage method
+	maxListFilesLimit int = 1000
+
+	// listFilesBatch is the number of directory entries read at once
+	listFilesBatch int = 1024
+)
+
+// ErrInvalidCursor is returned by ListFilesPage for a cursor it did not
+// create, or created with other sort options.
+var ErrInvalidCursor = WithErrorCode(errors.New("invalid cursor"), CodeBadRequest)
+
+// FileSort is the order of the entries listed by ListFilesPage.
+type FileSort int
+
+const (
+	// SortByName sorts entries by name.
+	SortByName FileSort = iota
+	// SortByModTime sorts entries by modification time, then by name.
+	SortByModTime
+	// SortBySize sorts entries by size, then by name. Directories have a
+	// size of 0.
+	SortBySize
+)
+
+// ListFilesOptions configures ListFilesPage.
+type ListFilesOptions struct {
+	// Sort is the order of the entries. Default to SortByName
+	Sort FileSort
+	// Desc is a boolean that indicates if the entries are listed in
+	// descending order
+	Desc bool
+	// ShowHidden is a boolean that indicates if entries whose name starts
+	// with a dot are listed
+	ShowHidden bool
+}
+
+// FilePage is a page of entries returned by ListFilesPage.
+type FilePage struct {
+	Entries []DirEntry `json:"entries"`
+	// NextCursor is the cursor of the next page, empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ListFilesPage returns the page of at most limit entries of dir following
+// cursor, the NextCursor of the previous page or "" for the first page. The
+// default limit is 100, and at most 1000 entries are returned. The directory is
+// read in batches and only the entries of the page are kept, so listing a
+// directory of hundreds of thousands of files does not load all of them in
+// memory. As the cursor holds the sort key of the last entry rather than an
+// offset, files added or removed between two requests do not shift the pages.
+func (t *Tools) ListFilesPage(dir, cursor string, limit int, opts ...ListFilesOptions) (*FilePage, error) {
+	var options ListFilesOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	if limit <= 0 {
+		limit = defaultListFilesLimit
+	}
+	limit = min(limit, maxListFilesLimit)
+
+	compare := options.compare
+	var after *fileItem
+	if cursor != "" {
+		item, err := options.decodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = &item
+	}
+
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	page := &fileHeap{compare: compare}
+	more := false
+	for {
+		batch, err := f.ReadDir(listFilesBatch)
+		for _, e := range batch {
+			if !options.ShowHidden && strings.HasPrefix(e.Name(), ".") {
+				continue
+			}
+			item := fileItem{name: e.Name(), entry: e}
+			if options.Sort != SortByName && !item.stat() {
+				continue
+			}
+			if after != nil && compare(item, *after) <= 0 {
+				continue
+			}
+
+			switch {
+			case page.Len() < limit:
+				heap.Push(page, item)
+			case compare(item, page.items[0]) < 0:
+				page.items[0] = item
+				heap.Fix(page, 0)
+				more = true
+			default:
+				more = true
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	items := page.items
+	slices.SortFunc(items, compare)
+
+	result := &FilePage{Entries: make([]DirEntry, 0, len(items))}
+	for _, item := range items {
+		if item.info == nil && !item.stat() {
+			continue
+		}
+		entry := DirEntry{Name: item.name, Dir: item.entry.IsDir(), ModTime: item.info.ModTime()}
+		if !entry.Dir {
+			entry.Size = item.info.Size()
+		}
+		result.Entries = append(result.Entries, entry)
+	}
+	if more && len(items) > 0 {
+		result.NextCursor = options.encodeCursor(items[len(items)-1])
+	}
+	return result, nil
+}
+
+// fileItem is a directory entry being listed, with its information once
+// read.
+type fileItem struct {
+	name  string
+	entry fs.DirEntry
+	info  fs.FileInfo
+}
+
+// stat reads the information of the entry, and reports whether it still
+// exists.
+func (f *fileItem) stat() bool {
+	info, err := f.entry.Info()
+	if err != nil {
+		return false
+	}
+	f.info = info
+	return true
+}
+
+// size returns the size of the entry, 0 for directories.
+func (f fileItem) size() int64 {
+	if f.entry != nil && f.entry.IsDir() {
+		return 0
+	}
+	return f.info.Size()
+}
+
+// compare orders a and b according to the sort options.
+func (o ListFilesOptions) compare(a, b fileItem) int {
+	var c int
+	switch o.Sort {
+	case SortByModTime:
+		c = a.info.ModTime().Compare(b.info.ModTime())
+	case SortBySize:
+		c = cmp.Compare(a.size(), b.size())
+	}
+	if c == 0 {
+		c = strings.Compare(a.name, b.name)
+	}
+	if o.Desc {
+		return -c
+	}
+	return c
+}
+
+// encodeCursor returns the cursor of the entries following item.
+func (o ListFilesOptions) encodeCursor(item fileItem) string {
+	var key int64
+	switch o.Sort {
+	case SortByModTime:
+		key = item.info.ModTime().UnixNano()
+	case SortBySize:
+		key = item.size()
+	}
+	return base64.RawURLEncoding.EncodeToString(fmt.Appendf(nil, "%d|%t|%d|%s", o.Sort, o.Desc, key, item.name))
+}
+
+// decodeCursor returns the entry encoded in cursor, with the information
+// compared by the sort options.
+func (o ListFilesOptions) decodeCursor(cursor string) (fileItem, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return fileItem{}, ErrInvalidCursor
+	}
+
+	parts := strings.SplitN(string(raw), "|", 4)
+	if len(parts) != 4 || parts[0] != strconv.Itoa(int(o.Sort)) || parts[1] != strconv.FormatBool(o.Desc) {
+		return fileItem{}, ErrInvalidCursor
+	}
+	key, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return fileItem{}, ErrInvalidCursor
+	}
+
+	return fileItem{name: parts[3], info: cursorInfo{modTime: time.Unix(0, key), size: key}}, nil
+}
+
+// cursorInfo is the fs.FileInfo of the entry of a cursor, holding its sort key.
+type cursorInfo struct {
+	fs.FileInfo
+	modTime time.Time
+	size    int64
+}
+
+// ModTime implements fs.FileInfo.
+func (c cursorInfo) ModTime() time.Time { return c.modTime }
+
+// Size implements fs.FileInfo.
+func (c cursorInfo) Size() int64 { return c.size }
+
+// fileHeap is a heap of the entries of a page whose root is the last entry, so
+// it is the one replaced when a preceding entry is found.
+type fileHeap struct {
+	items   []fileItem
+	compare func(a, b fileItem) int
+}
+
+// Len implements heap.Interface.
+func (h *fileHeap) Len() int { return len(h.items) }
+
+// Less implements heap.Interface.
+func (h *fileHeap) Less(i, j int) bool { return h.compare(h.items[i], h.items[j]) > 0 }
+
+// Swap implements heap.Interface.
+func (h *fileHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+// Push implements heap.Interface.
+func (h *fileHeap) Push(x any) { h.items = append(h.items, x.(fileItem)) }
+
+// Pop implements heap.Interface.
+func (h *fileHeap) Pop() any {
+	item := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return item
+}
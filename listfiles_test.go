@@ -0,0 +1,129 @@
+package gorigumi
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// newListFilesDir creates a directory of 25 files whose size grows and whose
+// modification time decreases with their number, a directory and a hidden file.
+func newListFilesDir(tb testing.TB) string {
+	tb.Helper()
+	dir := tb.TempDir()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 25 {
+		name := filepath.Join(dir, fmt.Sprintf("file%02d.txt", i))
+		if err := os.WriteFile(name, make([]byte, i*10), 0644); err != nil {
+			tb.Fatal(err)
+		}
+		modTime := base.Add(-time.Duration(i) * time.Hour)
+		if err := os.Chtimes(name, modTime, modTime); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		tb.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".hidden"), nil, 0644); err != nil {
+		tb.Fatal(err)
+	}
+	return dir
+}
+
+// listAllPages follows the cursors of ListFilesPage and returns the names of
+// all entries and the number of pages.
+func listAllPages(tb testing.TB, testTools *Tools, dir string, limit int, opts ListFilesOptions) ([]string, int) {
+	tb.Helper()
+	var names []string
+	cursor, pages := "", 0
+	for {
+		page, err := testTools.ListFilesPage(dir, cursor, limit, opts)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		pages++
+		for _, e := range page.Entries {
+			names = append(names, e.Name)
+		}
+		if page.NextCursor == "" {
+			return names, pages
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// listFilesTests is a slice of structs that hold the name of the test, the options and
+// the expected names of the first and last entries
+var listFilesTests = []struct {
+	name  string
+	opts  ListFilesOptions
+	first string
+	last  string
+}{
+	{"name", ListFilesOptions{}, "file00.txt", "sub"},
+	{"name desc", ListFilesOptions{Desc: true}, "sub", "file00.txt"},
+	{"mod time", ListFilesOptions{Sort: SortByModTime}, "file24.txt", "sub"},
+	{"size desc", ListFilesOptions{Sort: SortBySize, Desc: true}, "file24.txt", "file00.txt"},
+	{"hidden", ListFilesOptions{ShowHidden: true}, ".hidden", "sub"},
+}
+
+// TestTools_ListFilesPage tests that following the cursors lists every entry once, in
+// order.
+func TestTools_ListFilesPage(t *testing.T) {
+	testTools := New()
+	dir := newListFilesDir(t)
+
+	for _, lt := range listFilesTests {
+		names, pages := listAllPages(t, testTools, dir, 10, lt.opts)
+
+		expected := 26
+		if lt.opts.ShowHidden {
+			expected++
+		}
+		if len(names) != expected || pages != 3 {
+			t.Errorf("%s: expected %d entries in 3 pages, got %d in %d", lt.name, expected, len(names), pages)
+			continue
+		}
+		if names[0] != lt.first || names[len(names)-1] != lt.last {
+			t.Errorf("%s: expected %s first and %s last, got %s and %s", lt.name, lt.first, lt.last, names[0], names[len(names)-1])
+		}
+		if len(slices.Compact(slices.Sorted(slices.Values(names)))) != len(names) {
+			t.Errorf("%s: expected no duplicate entries, got %v", lt.name, names)
+		}
+	}
+}
+
+// TestTools_ListFilesPage_changes tests that files added before the cursor don't
+// shift the following pages, and that invalid cursors are rejected.
+func TestTools_ListFilesPage_changes(t *testing.T) {
+	testTools := New()
+	dir := newListFilesDir(t)
+
+	page, err := testTools.ListFilesPage(dir, "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a-new.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	next, err := testTools.ListFilesPage(dir, page.NextCursor, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(next.Entries) == 0 || next.Entries[0].Name != "file10.txt" {
+		t.Errorf("expected the second page to start at file10.txt, got %+v", next.Entries)
+	}
+
+	if _, err := testTools.ListFilesPage(dir, page.NextCursor, 10, ListFilesOptions{Sort: SortBySize}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("expected a cursor of another sort to be rejected, got %v", err)
+	}
+	if _, err := testTools.ListFilesPage(dir, "not a cursor", 10); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("expected an invalid cursor to be rejected, got %v", err)
+	}
+}
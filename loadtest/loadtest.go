@@ -0,0 +1,312 @@
+// Package loadtest drives concurrent requests against an HTTP endpoint, such as
+// the upload and JSON endpoints built on gorigumi, and reports latency
+// percentiles and error rates, e.g. to size MaxFileSize, MaxJSONSize or
+// concurrency limits with measurements rather than guesses.
+//
+//	res, err := loadtest.Run(ctx, loadtest.MultipartUpload("http://localhost:8080/upload", "file",
+//		loadtest.GeneratedFiles(5<<20)), loadtest.Options{Concurrency: 20, Requests: 500})
+//	fmt.Println(res)
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/drunkleen/gorigumi/toolkittest"
+)
+
+const (
+	// defaultConcurrency is the default number of concurrent workers
+	// it is inlcuded in the Run function
+	defaultConcurrency int = 10
+
+	// defaultRequests is the default number of requests of a run without
+	// a duration
+	// it is inlcuded in the Run function
+	defaultRequests int = 100
+)
+
+// RequestFunc builds the i-th request of a run, from 0. It is called
+// concurrently by the workers.
+type RequestFunc func(ctx context.Context, i int) (*http.Request, error)
+
+// Options configures Run.
+type Options struct {
+	// Concurrency is the number of requests in flight at once. Default to 10
+	Concurrency int
+	// Requests is the number of requests sent. Default to 100, or to no limit
+	// if Duration is set
+	Requests int
+	// Duration stops the run once elapsed, if set. Requests in flight are
+	// canceled and not counted
+	Duration time.Duration
+	// Client is the HTTP client sending the requests. Default to a client
+	// whose transport keeps Concurrency idle connections per host
+	Client *http.Client
+	// IsError reports whether a response counts as an error. Default to
+	// statuses of 400 and above
+	IsError func(*http.Response) bool
+}
+
+// Result holds the measurements of a run.
+type Result struct {
+	// Requests is the number of requests sent
+	Requests int
+	// Errors is the number of requests that failed, or whose response
+	// counts as an error
+	Errors int
+	// StatusCodes counts the responses by status code
+	StatusCodes map[int]int
+	// FirstError is the first transport error, if any
+	FirstError error
+	// Duration is the duration of the run
+	Duration time.Duration
+	// BytesSent is the total size of the request bodies sent
+	BytesSent int64
+	// Min, Mean and Max are the latencies of the requests, and P50, P90, P95
+	// and P99 their percentiles
+	Min, Mean, Max     time.Duration
+	P50, P90, P95, P99 time.Duration
+}
+
+// ErrorRate returns the fraction of requests that failed, from 0 to 1.
+func (r *Result) ErrorRate() float64 {
+	if r.Requests == 0 {
+		return 0
+	}
+	return float64(r.Errors) / float64(r.Requests)
+}
+
+// Throughput returns the number of requests per second.
+func (r *Result) Throughput() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Requests) / r.Duration.Seconds()
+}
+
+// String formats the result as a short human-readable report.
+func (r *Result) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "requests: %d in %s (%.1f/s), errors: %d (%.2f%%), sent: %d bytes\n",
+		r.Requests, r.Duration.Round(time.Millisecond), r.Throughput(), r.Errors, r.ErrorRate()*100, r.BytesSent)
+	fmt.Fprintf(&b, "latency: min %s, mean %s, p50 %s, p90 %s, p95 %s, p99 %s, max %s\n",
+		r.Min, r.Mean, r.P50, r.P90, r.P95, r.P99, r.Max)
+
+	codes := make([]int, 0, len(r.StatusCodes))
+	for code := range r.StatusCodes {
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+	statuses := make([]string, len(codes))
+	for i, code := range codes {
+		statuses[i] = fmt.Sprintf("%d: %d", code, r.StatusCodes[code])
+	}
+	fmt.Fprintf(&b, "statuses: %s", strings.Join(statuses, ", "))
+	if r.FirstError != nil {
+		fmt.Fprintf(&b, "\nfirst error: %v", r.FirstError)
+	}
+	return b.String()
+}
+
+// sample is the outcome of a request.
+type sample struct {
+	latency time.Duration
+	status  int
+	sent    int64
+	err     error
+	failed  bool
+}
+
+// Run sends the requests built by build with opts.Concurrency workers, until
+// opts.Requests requests were sent, opts.Duration elapsed or ctx is done, and
+// returns the measurements. Response bodies are read and discarded, so their
+// transfer counts in the latency. Run only returns an error if build does; the
+// errors of requests are counted in the result.
+func Run(ctx context.Context, build RequestFunc, opts ...Options) (*Result, error) {
+	var options Options
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	if options.Concurrency <= 0 {
+		options.Concurrency = defaultConcurrency
+	}
+	if options.Requests <= 0 && options.Duration <= 0 {
+		options.Requests = defaultRequests
+	}
+	if options.Client == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.MaxIdleConnsPerHost = options.Concurrency
+		options.Client = &http.Client{Transport: transport}
+	}
+	if options.IsError == nil {
+		options.IsError = func(res *http.Response) bool { return res.StatusCode >= 400 }
+	}
+	if options.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.Duration)
+		defer cancel()
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var next atomic.Int64
+	samples := make([][]sample, options.Concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for w := range options.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				i := int(next.Add(1) - 1)
+				if options.Requests > 0 && i >= options.Requests {
+					return
+				}
+				req, err := build(ctx, i)
+				if err != nil {
+					cancel(fmt.Errorf("building request %d: %w", i, err))
+					return
+				}
+				s := send(options, req)
+				if s.err != nil && ctx.Err() != nil {
+					// canceled at the end of the run
+					return
+				}
+				samples[w] = append(samples[w], s)
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	if err := context.Cause(ctx); err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+		return nil, err
+	}
+	return summarize(slices.Concat(samples...), elapsed), nil
+}
+
+// send sends req and measures its outcome.
+func send(options Options, req *http.Request) sample {
+	s := sample{sent: max(req.ContentLength, 0)}
+	start := time.Now()
+	res, err := options.Client.Do(req)
+	if err != nil {
+		s.latency, s.err, s.failed = time.Since(start), err, true
+		return s
+	}
+	_, err = io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+	s.latency = time.Since(start)
+	s.status = res.StatusCode
+	s.failed = err != nil || options.IsError(res)
+	s.err = err
+	return s
+}
+
+// summarize returns the result of the samples of a run of the given duration.
+func summarize(samples []sample, elapsed time.Duration) *Result {
+	res := &Result{StatusCodes: make(map[int]int), Duration: elapsed}
+	var latencies []time.Duration
+	var total time.Duration
+	for _, s := range samples {
+		res.Requests++
+		res.BytesSent += s.sent
+		if s.failed {
+			res.Errors++
+		}
+		if s.status != 0 {
+			res.StatusCodes[s.status]++
+		}
+		if s.err != nil && res.FirstError == nil {
+			res.FirstError = s.err
+		}
+		latencies = append(latencies, s.latency)
+		total += s.latency
+	}
+	if len(latencies) == 0 {
+		return res
+	}
+
+	slices.Sort(latencies)
+	res.Min, res.Max = latencies[0], latencies[len(latencies)-1]
+	res.Mean = total / time.Duration(len(latencies))
+	res.P50 = percentile(latencies, 50)
+	res.P90 = percentile(latencies, 90)
+	res.P95 = percentile(latencies, 95)
+	res.P99 = percentile(latencies, 99)
+	return res
+}
+
+// percentile returns the p-th percentile of the sorted latencies, with the
+// nearest-rank method.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank-1, 0)]
+}
+
+// JSONPost returns a RequestFunc posting the JSON encoding of body(i) to url.
+func JSONPost(url string, body func(i int) any) RequestFunc {
+	return func(ctx context.Context, i int) (*http.Request, error) {
+		data, err := json.Marshal(body(i))
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	}
+}
+
+// MultipartUpload returns a RequestFunc uploading the file returned by
+// file(i) to url, as the form field field of a multipart/form-data request,
+// like a browser does.
+func MultipartUpload(url, field string, file func(i int) (name string, data []byte)) RequestFunc {
+	return func(ctx context.Context, i int) (*http.Request, error) {
+		name, data := file(i)
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		part, err := writer.CreateFormFile(field, name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := part.Write(data); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &body)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		return req, nil
+	}
+}
+
+// GeneratedFiles returns a file function of MultipartUpload returning files
+// of size bytes named file-<i>.bin, generated once with
+// toolkittest.GenerateFile. Use fake.ImagePNG for endpoints only accepting
+// images.
+func GeneratedFiles(size int) func(i int) (string, []byte) {
+	data := toolkittest.GenerateFile(size, nil)
+	return func(i int) (string, []byte) {
+		return fmt.Sprintf("file-%d.bin", i), data
+	}
+}
@@ -0,0 +1,109 @@
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestRun_upload tests a run of uploads against a server rejecting large files.
+func TestRun_upload(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, header, err := r.FormFile("file"); err != nil || header.Size > 1024 {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	files := GeneratedFiles(2048)
+	small := func(i int) (string, []byte) {
+		name, data := files(i)
+		if i%2 == 0 {
+			data = data[:512]
+		}
+		return name, data
+	}
+	res, err := Run(context.Background(), MultipartUpload(srv.URL, "file", small), Options{Concurrency: 4, Requests: 40})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Requests != 40 || res.Errors != 20 || res.ErrorRate() != 0.5 {
+		t.Errorf("expected 40 requests and 20 errors, got %d and %d", res.Requests, res.Errors)
+	}
+	if res.StatusCodes[http.StatusCreated] != 20 || res.StatusCodes[http.StatusRequestEntityTooLarge] != 20 {
+		t.Errorf("unexpected status codes %v", res.StatusCodes)
+	}
+	if maxInFlight.Load() > 4 {
+		t.Errorf("expected at most 4 requests in flight, got %d", maxInFlight.Load())
+	}
+	if res.Min < 5*time.Millisecond || res.P50 < res.Min || res.P99 > res.Max || res.BytesSent < 20*2048 {
+		t.Errorf("unexpected measurements %+v", res)
+	}
+	if !strings.Contains(res.String(), "413: 20") {
+		t.Errorf("expected the status codes in the report, got %s", res)
+	}
+}
+
+// TestRun_duration tests that a run without a number of requests stops after its duration.
+func TestRun_duration(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	body := func(i int) any { return map[string]int{"i": i} }
+	res, err := Run(context.Background(), JSONPost(srv.URL, body), Options{Concurrency: 2, Duration: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Requests == 0 || res.Errors != 0 || res.Duration > time.Second {
+		t.Errorf("unexpected result %+v", res)
+	}
+}
+
+// TestRun_buildError tests that the errors of building requests stop the run.
+func TestRun_buildError(t *testing.T) {
+	build := func(ctx context.Context, i int) (*http.Request, error) {
+		return nil, errors.New("no more data")
+	}
+	if _, err := Run(context.Background(), build); err == nil || !strings.Contains(err.Error(), "no more data") {
+		t.Errorf("expected the build error, got %v", err)
+	}
+}
+
+// TestPercentile tests the nearest-rank percentiles.
+func TestPercentile(t *testing.T) {
+	sorted := make([]time.Duration, 100)
+	for i := range sorted {
+		sorted[i] = time.Duration(i + 1)
+	}
+	for _, p := range []int{50, 90, 99} {
+		if got := percentile(sorted, p); got != time.Duration(p) {
+			t.Errorf("p%d: expected %d, got %d", p, p, got)
+		}
+	}
+	if got := percentile(sorted[:1], 99); got != 1 {
+		t.Errorf("expected the single latency, got %d", got)
+	}
+}
@@ -0,0 +1,173 @@
+package gorigumi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+const (
+	// magicLinkPurpose is the purpose of magic link tokens
+	magicLinkPurpose string = "magic-link"
+
+	// defaultMagicLinkTTL is the default lifetime of magic links
+	// it is inlcuded in the MagicLinkRequestHandler method
+	defaultMagicLinkTTL time.Duration = 15 * time.Minute
+
+	// magicLinkDeviceCookie is the name of the cookie binding magic links
+	// to the device they were requested from
+	magicLinkDeviceCookie string = "magic_link_device"
+)
+
+// MagicLinkOptions configures MagicLinkRequestHandler and MagicLinkLoginHandler.
+// Both handlers of a flow must use the same options.
+type MagicLinkOptions struct {
+	// TTL is the lifetime of links. Default to 15 minutes
+	TTL time.Duration
+	// BindDevice is a boolean that indicates if links only work in the
+	// browser they were requested from, which holds a device cookie set
+	// by MagicLinkRequestHandler. This defeats links forwarded to, or
+	// intercepted by, someone else
+	BindDevice bool
+	// Allow is called before a link is issued, with the normalized email
+	// address, and may return an error to refuse it, e.g. to rate limit
+	// requests per address or client. Refused requests get a 429 response
+	Allow func(r *http.Request, email string) error
+}
+
+// GenerateMagicLinkToken returns a signed, single-use login token for email,
+// valid for ttl. If device is not empty, the token only verifies with the same
+// device. TokenSecret and TokenStore must be set.
+func (t *Tools) GenerateMagicLinkToken(email string, ttl time.Duration, device string) (string, error) {
+	email, err := t.NormalizeEmail(email)
+	if err != nil {
+		return "", err
+	}
+	return t.signOneTimeToken(magicLinkPurpose, email, device, ttl)
+}
+
+// ConsumeMagicLinkToken checks a token returned by GenerateMagicLinkToken for
+// device, marks it as used and returns the email address to log in. The error
+// is ErrInvalidToken, ErrTokenExpired or ErrTokenUsed for tokens that cannot
+// be used.
+func (t *Tools) ConsumeMagicLinkToken(ctx context.Context, token, device string) (string, error) {
+	return t.consumeToken(ctx, magicLinkPurpose, token, device)
+}
+
+// MagicLinkRequestHandler returns a handler issuing magic links. It reads a JSON
+// body holding an email, checks Allow, and calls send with the normalized
+// address and a token, e.g. to mail a link to a MagicLinkLoginHandler. It is up
+// to send to skip unknown addresses, the response is 202 in both cases.
+func (t *Tools) MagicLinkRequestHandler(send func(ctx context.Context, email, token string) error, opts ...MagicLinkOptions) http.Handler {
+	options := magicLinkOptions(opts)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Email string `json:"email"`
+		}
+		if err := t.JSONRead(w, r, &payload); err != nil {
+			_ = t.JSONError(w, err, http.StatusBadRequest)
+			return
+		}
+
+		email, err := t.NormalizeEmail(payload.Email)
+		if err != nil {
+			_ = t.JSONError(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if options.Allow != nil {
+			if err := options.Allow(r, email); err != nil {
+				_ = t.JSONError(w, err, http.StatusTooManyRequests)
+				return
+			}
+		}
+
+		device := ""
+		if options.BindDevice {
+			device = t.magicLinkDevice(w, r, options.TTL)
+		}
+
+		token, err := t.GenerateMagicLinkToken(email, options.TTL, device)
+		if err == nil {
+			err = send(r.Context(), email, token)
+		}
+		if err != nil {
+			_ = t.JSONError(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		_ = t.JSONWrite(w, http.StatusAccepted, JSONResponse{Message: "if the address is known, a login link was sent"})
+	})
+}
+
+// MagicLinkLoginHandler returns a handler consuming the token in the token query
+// parameter with ConsumeMagicLinkToken and calling login with the address, which
+// starts the session of the user and writes the response, e.g. a redirect. It
+// responds 400 for invalid or used tokens, 410 for expired ones and 500 if the
+// TokenStore fails.
+func (t *Tools) MagicLinkLoginHandler(login func(w http.ResponseWriter, r *http.Request, email string), opts ...MagicLinkOptions) http.Handler {
+	options := magicLinkOptions(opts)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		device := ""
+		if options.BindDevice {
+			if c, err := r.Cookie(magicLinkDeviceCookie); err == nil {
+				device = c.Value
+			}
+			// a missing cookie never matches the binding of a token
+			if device == "" {
+				_ = t.JSONError(w, ErrInvalidToken, http.StatusBadRequest)
+				return
+			}
+		}
+
+		email, err := t.ConsumeMagicLinkToken(r.Context(), r.URL.Query().Get("token"), device)
+		switch {
+		case errors.Is(err, ErrTokenExpired):
+			_ = t.JSONError(w, err, http.StatusGone)
+			return
+		case errors.Is(err, ErrInvalidToken), errors.Is(err, ErrTokenUsed):
+			_ = t.JSONError(w, err, http.StatusBadRequest)
+			return
+		case err != nil:
+			_ = t.JSONError(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		login(w, r, email)
+	})
+}
+
+// magicLinkDevice returns the device id of the client of r, setting a new
+// device cookie valid for at least ttl if it has none.
+func (t *Tools) magicLinkDevice(w http.ResponseWriter, r *http.Request, ttl time.Duration) string {
+	if c, err := r.Cookie(magicLinkDeviceCookie); err == nil && validUploadID(c.Value) {
+		return c.Value
+	}
+
+	device := t.GenerateRandomString(32)
+	http.SetCookie(w, &http.Cookie{
+		Name:     magicLinkDeviceCookie,
+		Value:    device,
+		Path:     "/",
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return device
+}
+
+// magicLinkOptions returns the options of opts with their defaults applied.
+func magicLinkOptions(opts []MagicLinkOptions) MagicLinkOptions {
+	var options MagicLinkOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	if options.TTL == 0 {
+		options.TTL = defaultMagicLinkTTL
+	}
+	return options
+}
@@ -0,0 +1,97 @@
+package gorigumi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/drunkleen/gorigumi/toolkittest"
+)
+
+// TestTools_MagicLink tests a passwordless login from the request of a link to its
+// single use, with the rate limiting hook and device binding.
+func TestTools_MagicLink(t *testing.T) {
+	clock := toolkittest.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	testTools := newTokenTools(clock)
+
+	requests := 0
+	opts := MagicLinkOptions{
+		BindDevice: true,
+		Allow: func(r *http.Request, email string) error {
+			requests++
+			if requests > 2 {
+				return errors.New("too many login links requested")
+			}
+			return nil
+		},
+	}
+
+	var token string
+	requestHandler := testTools.MagicLinkRequestHandler(func(ctx context.Context, email, tok string) error {
+		token = tok
+		return nil
+	}, opts)
+
+	var loggedIn []string
+	loginHandler := testTools.MagicLinkLoginHandler(func(w http.ResponseWriter, r *http.Request, email string) {
+		loggedIn = append(loggedIn, email)
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	}, opts)
+
+	rr := httptest.NewRecorder()
+	requestHandler.ServeHTTP(rr, httptest.NewRequest("POST", "/", strings.NewReader(`{"email":"User@Example.com"}`)))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d", rr.Code)
+	}
+	cookies := rr.Result().Cookies()
+	if len(cookies) != 1 || !cookies[0].HttpOnly {
+		t.Fatalf("expected a device cookie, got %v", cookies)
+	}
+
+	login := func(withCookie bool) int {
+		req := httptest.NewRequest("GET", "/login?token="+url.QueryEscape(token), nil)
+		if withCookie {
+			req.AddCookie(cookies[0])
+		}
+		rr := httptest.NewRecorder()
+		loginHandler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code := login(false); code != http.StatusBadRequest {
+		t.Errorf("expected link opened on another device to be rejected, got %d", code)
+	}
+	if code := login(true); code != http.StatusSeeOther {
+		t.Errorf("expected status 303, got %d", code)
+	}
+	if len(loggedIn) != 1 || loggedIn[0] != "User@example.com" {
+		t.Errorf("expected a single login, got %v", loggedIn)
+	}
+	if code := login(true); code != http.StatusBadRequest {
+		t.Errorf("expected used link to be rejected, got %d", code)
+	}
+
+	// the device cookie of the client is reused
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{"email":"user@example.com"}`))
+	req.AddCookie(cookies[0])
+	rr = httptest.NewRecorder()
+	requestHandler.ServeHTTP(rr, req)
+	if len(rr.Result().Cookies()) != 0 {
+		t.Error("expected the device cookie to be reused")
+	}
+	clock.Advance(time.Hour)
+	if code := login(true); code != http.StatusGone {
+		t.Errorf("expected expired link to be rejected, got %d", code)
+	}
+
+	rr = httptest.NewRecorder()
+	requestHandler.ServeHTTP(rr, httptest.NewRequest("POST", "/", strings.NewReader(`{"email":"user@example.com"}`)))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected refused request to get status 429, got %d", rr.Code)
+	}
+}
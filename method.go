@@ -0,0 +1,30 @@
+package gorigumi
+
+import (
+	"net/http"
+
+	"github.com/drunkleen/gorigumi/middleware"
+)
+
+// MethodOverrideOptions configures MethodOverrideMiddleware. It is an alias of
+// middleware.MethodOverrideOptions.
+type MethodOverrideOptions = middleware.MethodOverrideOptions
+
+// MethodOverrideMiddleware returns a middleware that lets clients limited to GET
+// and POST, such as HTML forms, send other methods. The method of a POST request
+// is replaced by the value of the override header or, for URL-encoded form bodies,
+// of the override form field, if it is one of the allowed methods. Multipart
+// bodies are never parsed to look for the field, so uploads are not buffered.
+func (t *Tools) MethodOverrideMiddleware(next http.Handler, opts ...MethodOverrideOptions) http.Handler {
+	return middleware.MethodOverride(next, opts...)
+}
+
+// AutoHeadMiddleware returns a middleware that answers HEAD requests with the
+// GET handler of next: the request is passed on as a GET, the response headers
+// are sent with a Content-Length computed from the discarded body, unless the
+// handler set one itself, and no body is sent. Once the handler set a
+// Content-Length, writes fail with http.ErrBodyNotAllowed, so handlers copying
+// files, such as DownloadFile, stop early.
+func (t *Tools) AutoHeadMiddleware(next http.Handler) http.Handler {
+	return middleware.AutoHead(next)
+}
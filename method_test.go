@@ -0,0 +1,115 @@
+package gorigumi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/drunkleen/gorigumi/toolkittest"
+)
+
+// methodOverrideTests is a slice of structs that hold the name of the test, the method,
+// headers and body of the request, and the method expected to reach the handler
+var methodOverrideTests = []struct {
+	name     string
+	method   string
+	headers  map[string]string
+	body     string
+	expected string
+}{
+	{"header", "POST", map[string]string{"X-HTTP-Method-Override": "put"}, "", "PUT"},
+	{"form field", "POST", map[string]string{"Content-Type": "application/x-www-form-urlencoded"}, "_method=DELETE&name=x", "DELETE"},
+	{"header wins", "POST", map[string]string{"X-HTTP-Method-Override": "PATCH", "Content-Type": "application/x-www-form-urlencoded"}, "_method=DELETE", "PATCH"},
+	{"not allowed", "POST", map[string]string{"X-HTTP-Method-Override": "TRACE"}, "", "POST"},
+	{"only post", "GET", map[string]string{"X-HTTP-Method-Override": "DELETE"}, "", "GET"},
+	{"multipart not parsed", "POST", map[string]string{"Content-Type": "multipart/form-data; boundary=x"}, "--x\r\nContent-Disposition: form-data; name=\"_method\"\r\n\r\nDELETE\r\n--x--\r\n", "POST"},
+}
+
+// TestTools_MethodOverrideMiddleware tests the methods seen by the handler.
+func TestTools_MethodOverrideMiddleware(t *testing.T) {
+	testTools := New()
+
+	for _, mt := range methodOverrideTests {
+		var seen string
+		handler := testTools.MethodOverrideMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = r.Method
+		}))
+
+		req := httptest.NewRequest(mt.method, "/", strings.NewReader(mt.body))
+		for k, v := range mt.headers {
+			req.Header.Set(k, v)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		if seen != mt.expected {
+			t.Errorf("%s: expected method %s, got %s", mt.name, mt.expected, seen)
+		}
+	}
+}
+
+// TestTools_AutoHeadMiddleware tests that HEAD requests get the headers of the GET
+// response with a correct Content-Length and no body.
+func TestTools_AutoHeadMiddleware(t *testing.T) {
+	testTools := New()
+
+	dir := t.TempDir()
+	data := toolkittest.GenerateFile(10000, nil)
+	if err := os.WriteFile(filepath.Join(dir, "a.bin"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("X-Greeting", "yes")
+		w.Write([]byte("hello "))
+		w.Write([]byte("world"))
+	})
+	mux.HandleFunc("/download", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		testTools.DownloadFile(w, r, dir, "a.bin", "data.bin")
+	})
+	handler := testTools.AutoHeadMiddleware(mux)
+
+	var headTests = []struct {
+		path          string
+		contentLength string
+		header        string
+	}{
+		{"/hello", "11", "X-Greeting"},
+		{"/download", "10000", "Content-Disposition"},
+	}
+
+	for _, ht := range headTests {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("HEAD", ht.path, nil))
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d", ht.path, rr.Code)
+		}
+		if cl := rr.Header().Get("Content-Length"); cl != ht.contentLength {
+			t.Errorf("%s: expected Content-Length %s, got %q", ht.path, ht.contentLength, cl)
+		}
+		if rr.Header().Get(ht.header) == "" {
+			t.Errorf("%s: expected header %s", ht.path, ht.header)
+		}
+		if rr.Body.Len() != 0 {
+			t.Errorf("%s: expected no body, got %d bytes", ht.path, rr.Body.Len())
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/hello", nil))
+	if rr.Body.String() != "hello world" {
+		t.Errorf("expected GET requests to pass through, got %q", rr.Body.String())
+	}
+}
@@ -0,0 +1,302 @@
+package gorigumi
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultBudgetWindow is the default window over which budgets are evaluated
+	// it is inlcuded in the MetricsMiddleware method
+	defaultBudgetWindow time.Duration = time.Minute
+
+	// defaultBudgetPercentile is the default latency percentile checked against
+	// MaxLatency. It is inlcuded in the MetricsMiddleware method
+	defaultBudgetPercentile float64 = 0.95
+
+	// maxRouteSamples caps the number of samples kept per route
+	maxRouteSamples int = 10000
+)
+
+// RouteBudget is the latency and error budget of a route.
+type RouteBudget struct {
+	// MaxLatency is the maximum latency of the LatencyPercentile of the
+	// requests in the window. Zero disables the latency budget
+	MaxLatency time.Duration
+	// LatencyPercentile is the percentile checked against MaxLatency.
+	// Default to 0.95
+	LatencyPercentile float64
+	// MaxErrorRate is the maximum share (0-1) of 5xx responses in the
+	// window. Zero disables the error budget
+	MaxErrorRate float64
+	// Window is the sliding window the budget is evaluated over.
+	// Default to 1 minute
+	Window time.Duration
+	// MinRequests is the minimum number of requests in the window before
+	// the budget is evaluated
+	MinRequests int
+}
+
+// BudgetViolation describes a route exceeding its budget.
+type BudgetViolation struct {
+	Route     string        `json:"route"`
+	Reason    string        `json:"reason"`
+	Requests  int           `json:"requests"`
+	ErrorRate float64       `json:"errorRate"`
+	Latency   time.Duration `json:"latency"`
+	Budget    RouteBudget   `json:"budget"`
+	At        time.Time     `json:"at"`
+}
+
+// RouteStats holds the figures of a route over its budget window.
+type RouteStats struct {
+	Requests  int           `json:"requests"`
+	Errors    int           `json:"errors"`
+	ErrorRate float64       `json:"errorRate"`
+	Latency   time.Duration `json:"latency"`
+	// Disconnects counts the requests whose client went away, which are not
+	// errors
+	Disconnects int `json:"disconnects"`
+}
+
+// Metrics collects per-route request figures and checks them against
+// latency and error budgets. Use NewMetrics to create one and wire it
+// with MetricsMiddleware.
+type Metrics struct {
+	// Budgets holds the budget of each route, keyed by route
+	Budgets map[string]RouteBudget
+	// DefaultBudget is applied to routes without an entry in Budgets
+	DefaultBudget *RouteBudget
+	// OnViolation is called, at most once per window and route, when a
+	// route exceeds its budget
+	OnViolation func(BudgetViolation)
+	// Clock is the source of time of the budget windows.
+	// Default to SystemClock
+	Clock Clock
+
+	mu           sync.Mutex
+	routes       map[string]*routeMetrics
+	clientErrors map[string]*ClientErrorStats
+}
+
+// routeMetrics holds the samples of a single route.
+type routeMetrics struct {
+	samples      []routeSample
+	lastViolated time.Time
+}
+
+// routeSample is a single served request.
+type routeSample struct {
+	at           time.Time
+	latency      time.Duration
+	failed       bool
+	disconnected bool
+}
+
+// NewMetrics returns a new empty instance of Metrics.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		Budgets: make(map[string]RouteBudget),
+		routes:  make(map[string]*routeMetrics),
+	}
+}
+
+// BudgetWebhook returns an OnViolation callback pushing every violation as JSON
+// to the given URL with JSONPushToRemote. Delivery errors are ignored.
+func (t *Tools) BudgetWebhook(url string, client ...*http.Client) func(BudgetViolation) {
+	return func(v BudgetViolation) {
+		_, _, _ = t.JSONPushToRemote(url, v, client...)
+	}
+}
+
+// MetricsMiddleware returns a middleware recording the latency and the status of
+// every request served by next into m. Requests are grouped by the pattern matched
+// by an http.ServeMux, and those matching no pattern under the "unmatched" route,
+// so scanners requesting random paths can't grow m without bound. Requests
+// whose client went away are recorded with the status StatusClientClosedRequest.
+func (t *Tools) MetricsMiddleware(next http.Handler, m *Metrics) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+
+		next.ServeHTTP(sw, r)
+
+		m.Observe(requestRoute(r), time.Since(start), responseStatus(sw, r))
+	})
+}
+
+// Observe records a request of route served in latency with the given status,
+// and fires OnViolation if the route exceeds its budget. Statuses of 500 and
+// above count as errors, and StatusClientClosedRequest as a disconnect.
+func (m *Metrics) Observe(route string, latency time.Duration, status int) {
+	now := clockOrSystem(m.Clock).Now()
+
+	m.mu.Lock()
+	if m.routes == nil {
+		m.routes = make(map[string]*routeMetrics)
+	}
+	rm, ok := m.routes[route]
+	if !ok {
+		rm = &routeMetrics{}
+		m.routes[route] = rm
+	}
+
+	budget, hasBudget := m.budget(route)
+	rm.samples = append(rm.samples, routeSample{
+		at: now, latency: latency, failed: status >= 500, disconnected: status == StatusClientClosedRequest,
+	})
+	rm.prune(now.Add(-budget.Window))
+
+	var violation *BudgetViolation
+	if hasBudget && now.Sub(rm.lastViolated) >= budget.Window {
+		if violation = rm.check(budget); violation != nil {
+			violation.Route, violation.At = route, now
+			rm.lastViolated = now
+		}
+	}
+	m.mu.Unlock()
+
+	if violation != nil && m.OnViolation != nil {
+		go m.OnViolation(*violation)
+	}
+}
+
+// Snapshot returns the current figures of every route.
+func (m *Metrics) Snapshot() map[string]RouteStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := clockOrSystem(m.Clock).Now()
+	stats := make(map[string]RouteStats, len(m.routes))
+	for route, rm := range m.routes {
+		budget, _ := m.budget(route)
+		rm.prune(now.Add(-budget.Window))
+		stats[route] = rm.stats(budget.LatencyPercentile)
+	}
+
+	return stats
+}
+
+// budget returns the budget of route with defaults applied, and whether the
+// route has a budget at all.
+func (m *Metrics) budget(route string) (RouteBudget, bool) {
+	budget, ok := m.Budgets[route]
+	if !ok && m.DefaultBudget != nil {
+		budget, ok = *m.DefaultBudget, true
+	}
+	if budget.Window == 0 {
+		budget.Window = defaultBudgetWindow
+	}
+	if budget.LatencyPercentile == 0 {
+		budget.LatencyPercentile = defaultBudgetPercentile
+	}
+	return budget, ok
+}
+
+// prune drops the samples older than cutoff and caps the number of samples.
+func (rm *routeMetrics) prune(cutoff time.Time) {
+	i := sort.Search(len(rm.samples), func(i int) bool {
+		return !rm.samples[i].at.Before(cutoff)
+	})
+	if over := len(rm.samples) - i - maxRouteSamples; over > 0 {
+		i += over
+	}
+	if i > 0 {
+		rm.samples = append(rm.samples[:0], rm.samples[i:]...)
+	}
+}
+
+// stats computes the figures of the samples in the window.
+func (rm *routeMetrics) stats(percentile float64) RouteStats {
+	stats := RouteStats{Requests: len(rm.samples)}
+	if stats.Requests == 0 {
+		return stats
+	}
+
+	latencies := make([]time.Duration, 0, len(rm.samples))
+	for _, s := range rm.samples {
+		if s.failed {
+			stats.Errors++
+		}
+		if s.disconnected {
+			stats.Disconnects++
+		}
+		latencies = append(latencies, s.latency)
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	idx := int(float64(len(latencies))*percentile+0.5) - 1
+	stats.Latency = latencies[max(0, min(idx, len(latencies)-1))]
+	stats.ErrorRate = float64(stats.Errors) / float64(stats.Requests)
+
+	return stats
+}
+
+// check returns a violation if the samples in the window exceed budget.
+func (rm *routeMetrics) check(budget RouteBudget) *BudgetViolation {
+	stats := rm.stats(budget.LatencyPercentile)
+	if stats.Requests == 0 || stats.Requests < budget.MinRequests {
+		return nil
+	}
+
+	violation := &BudgetViolation{
+		Requests:  stats.Requests,
+		ErrorRate: stats.ErrorRate,
+		Latency:   stats.Latency,
+		Budget:    budget,
+	}
+
+	switch {
+	case budget.MaxErrorRate > 0 && stats.ErrorRate > budget.MaxErrorRate:
+		violation.Reason = fmt.Sprintf("error rate %.2f exceeds budget of %.2f", stats.ErrorRate, budget.MaxErrorRate)
+	case budget.MaxLatency > 0 && stats.Latency > budget.MaxLatency:
+		violation.Reason = fmt.Sprintf("p%g latency %s exceeds budget of %s", budget.LatencyPercentile*100, stats.Latency, budget.MaxLatency)
+	default:
+		return nil
+	}
+
+	return violation
+}
+
+// statusWriter wraps an http.ResponseWriter and records the status code
+// and the number of bytes written.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// WriteHeader records the status code and forwards it.
+func (s *statusWriter) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// Write counts the written bytes and forwards them.
+func (s *statusWriter) Write(p []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(p)
+	s.bytes += int64(n)
+	return n, err
+}
+
+// Status returns the recorded status code, 200 if none was written.
+func (s *statusWriter) Status() int {
+	if s.status == 0 {
+		return http.StatusOK
+	}
+	return s.status
+}
+
+// Unwrap returns the underlying http.ResponseWriter, so http.ResponseController
+// can reach its optional interfaces.
+func (s *statusWriter) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
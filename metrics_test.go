@@ -0,0 +1,94 @@
+package gorigumi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// budgetTests is a slice of structs that hold the name of the test, the budget, the statuses
+// and the latency of the observed requests, and a boolean that indicates if a violation is expected
+var budgetTests = []struct {
+	name              string
+	budget            RouteBudget
+	statuses          []int
+	latency           time.Duration
+	violationExpected bool
+}{
+	{"within budget", RouteBudget{MaxErrorRate: 0.5, MaxLatency: time.Second}, []int{200, 200, 500}, time.Millisecond, false},
+	{"error budget", RouteBudget{MaxErrorRate: 0.1}, []int{200, 500, 500}, time.Millisecond, true},
+	{"latency budget", RouteBudget{MaxLatency: 10 * time.Millisecond}, []int{200, 200}, 20 * time.Millisecond, true},
+	{"not enough requests", RouteBudget{MaxErrorRate: 0.1, MinRequests: 10}, []int{500, 500}, time.Millisecond, false},
+}
+
+// TestMetrics_Observe tests the Observe method by recording requests against route budgets
+// and checking that a violation is reported exactly once per window.
+func TestMetrics_Observe(t *testing.T) {
+	for _, bt := range budgetTests {
+		violations := make(chan BudgetViolation, 10)
+
+		m := NewMetrics()
+		m.Budgets["GET /items"] = bt.budget
+		m.OnViolation = func(v BudgetViolation) { violations <- v }
+
+		for _, status := range bt.statuses {
+			m.Observe("GET /items", bt.latency, status)
+		}
+
+		if !bt.violationExpected {
+			select {
+			case v := <-violations:
+				t.Errorf("%s: expected no violation, got %s", bt.name, v.Reason)
+			case <-time.After(20 * time.Millisecond):
+			}
+			continue
+		}
+
+		select {
+		case v := <-violations:
+			if v.Route != "GET /items" {
+				t.Errorf("%s: expected route GET /items, got %s", bt.name, v.Route)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("%s: expected a violation but got none", bt.name)
+		}
+
+		select {
+		case <-violations:
+			t.Errorf("%s: expected a single violation per window", bt.name)
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+}
+
+// TestTools_MetricsMiddleware tests the MetricsMiddleware method by serving requests through
+// a mux and checking that they are grouped by the matched pattern.
+func TestTools_MetricsMiddleware(t *testing.T) {
+	testTools := New()
+	m := NewMetrics()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") == "0" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+
+	handler := testTools.MetricsMiddleware(mux, m)
+	for _, path := range []string{"/items/1", "/items/2", "/items/0", "/unknown"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	stats := m.Snapshot()
+	items, ok := stats["GET /items/{id}"]
+	if !ok {
+		t.Fatalf("expected stats for pattern, got %v", stats)
+	}
+	if items.Requests != 3 || items.Errors != 1 {
+		t.Errorf("expected 3 requests and 1 error, got %d and %d", items.Requests, items.Errors)
+	}
+	if _, ok := stats["unmatched"]; !ok || len(stats) != 2 {
+		t.Errorf("expected stats for unmatched paths under a single route, got %v", stats)
+	}
+}
@@ -0,0 +1,151 @@
+// Package middleware holds the stateless HTTP middlewares of gorigumi, which
+// need none of the configuration of its Tools struct. It is the implementation
+// of MethodOverrideMiddleware, AutoHeadMiddleware and NormalizePathMiddleware
+// and can be imported on its own.
+package middleware
+
+import (
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// defaultMethodOverrideHeader is the default header holding the overriding method
+	// it is inlcuded in the MethodOverride function
+	defaultMethodOverrideHeader string = "X-HTTP-Method-Override"
+
+	// defaultMethodOverrideField is the default form field holding the overriding method
+	// it is inlcuded in the MethodOverride function
+	defaultMethodOverrideField string = "_method"
+)
+
+// MethodOverrideOptions configures MethodOverride.
+type MethodOverrideOptions struct {
+	// Header is the request header holding the overriding method.
+	// Default to X-HTTP-Method-Override
+	Header string
+	// FormField is the field of URL-encoded form bodies holding the
+	// overriding method. Default to _method
+	FormField string
+	// AllowedMethods is the list of methods a POST request may be turned
+	// into. Default to PUT, PATCH and DELETE
+	AllowedMethods []string
+}
+
+// MethodOverride returns a middleware that lets clients limited to GET
+// and POST, such as HTML forms, send other methods. The method of a POST request
+// is replaced by the value of the override header or, for URL-encoded form bodies,
+// of the override form field, if it is one of the allowed methods. Multipart
+// bodies are never parsed to look for the field, so uploads are not buffered.
+func MethodOverride(next http.Handler, opts ...MethodOverrideOptions) http.Handler {
+	var options MethodOverrideOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	if options.Header == "" {
+		options.Header = defaultMethodOverrideHeader
+	}
+	if options.FormField == "" {
+		options.FormField = defaultMethodOverrideField
+	}
+	if options.AllowedMethods == nil {
+		options.AllowedMethods = []string{http.MethodPut, http.MethodPatch, http.MethodDelete}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			method := r.Header.Get(options.Header)
+			if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); method == "" && mediaType == "application/x-www-form-urlencoded" {
+				method = r.PostFormValue(options.FormField)
+			}
+
+			method = strings.ToUpper(strings.TrimSpace(method))
+			if slices.Contains(options.AllowedMethods, method) {
+				r.Method = method
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AutoHead returns a middleware that answers HEAD requests with the
+// GET handler of next: the request is passed on as a GET, the response headers
+// are sent with a Content-Length computed from the discarded body, unless the
+// handler set one itself, and no body is sent. Once the handler set a
+// Content-Length, writes fail with http.ErrBodyNotAllowed, so handlers copying
+// files, such as http.ServeFile, stop early.
+func AutoHead(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		r2 := r.Clone(r.Context())
+		r2.Method = http.MethodGet
+
+		hw := &headWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(hw, r2)
+		hw.commit()
+	})
+}
+
+// headWriter is a http.ResponseWriter that discards the body of responses and
+// delays the headers until the body length is known.
+type headWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	committed   bool
+	length      int64
+}
+
+// WriteHeader records the status code sent once the response is complete.
+func (h *headWriter) WriteHeader(status int) {
+	if h.wroteHeader {
+		return
+	}
+	h.status, h.wroteHeader = status, true
+}
+
+// Write counts and discards p. If the handler set a Content-Length, the
+// headers are sent right away and http.ErrBodyNotAllowed is returned.
+func (h *headWriter) Write(p []byte) (int, error) {
+	h.wroteHeader = true
+	if h.Header().Get("Content-Length") != "" {
+		h.commit()
+		return 0, http.ErrBodyNotAllowed
+	}
+
+	h.length += int64(len(p))
+	return len(p), nil
+}
+
+// commit sends the headers, with the computed Content-Length if none was set.
+func (h *headWriter) commit() {
+	if h.committed {
+		return
+	}
+	h.committed = true
+
+	header := h.Header()
+	if header.Get("Content-Length") == "" && header.Get("Transfer-Encoding") == "" && bodyAllowedForStatus(h.status) {
+		header.Set("Content-Length", strconv.FormatInt(h.length, 10))
+	}
+	h.ResponseWriter.WriteHeader(h.status)
+}
+
+// Unwrap returns the underlying http.ResponseWriter.
+func (h *headWriter) Unwrap() http.ResponseWriter {
+	return h.ResponseWriter
+}
+
+// bodyAllowedForStatus reports whether a response with the given status may
+// have a body, and so a Content-Length.
+func bodyAllowedForStatus(status int) bool {
+	return status >= 200 && status != http.StatusNoContent && status != http.StatusNotModified
+}
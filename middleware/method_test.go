@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestMethodOverride tests that the method of POST requests is overridden by the
+// header or the form field.
+func TestMethodOverride(t *testing.T) {
+	var seen string
+	handler := MethodOverride(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.Method
+	}))
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader("_method=delete"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if seen != http.MethodDelete {
+		t.Errorf("expected method DELETE, got %s", seen)
+	}
+}
+
+// TestAutoHead tests that HEAD requests get the headers of the GET response with
+// a Content-Length and no body.
+func TestAutoHead(t *testing.T) {
+	handler := AutoHead(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello world"))
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("HEAD", "/", nil))
+	if rr.Header().Get("Content-Length") != "11" || rr.Body.Len() != 0 {
+		t.Errorf("expected Content-Length 11 and no body, got %q and %d bytes", rr.Header().Get("Content-Length"), rr.Body.Len())
+	}
+}
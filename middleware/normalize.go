@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// TrailingSlashPolicy tells NormalizePath what to do with the
+// trailing slash of request paths.
+type TrailingSlashPolicy int
+
+const (
+	// TrailingSlashKeep leaves trailing slashes as they are
+	TrailingSlashKeep TrailingSlashPolicy = iota
+	// TrailingSlashStrip redirects /a/ to /a
+	TrailingSlashStrip
+	// TrailingSlashAdd redirects /a to /a/. Paths whose last segment
+	// has an extension, such as /a/logo.png, are left alone
+	TrailingSlashAdd
+)
+
+// NormalizePathOptions configures NormalizePath.
+type NormalizePathOptions struct {
+	// TrailingSlash is the trailing slash policy. Default to TrailingSlashKeep
+	TrailingSlash TrailingSlashPolicy
+	// RedirectDuplicateSlashes is a boolean that indicates if paths with
+	// duplicate slashes are redirected to their collapsed form, instead of
+	// being rewritten before next sees them
+	RedirectDuplicateSlashes bool
+	// LowercaseHost is a boolean that indicates if the Host of requests is
+	// lowercased before next sees it
+	LowercaseHost bool
+	// RedirectStatus is the status of redirects of GET and HEAD requests.
+	// Other methods are always redirected with 308 Permanent Redirect so
+	// the body is sent again. Default to 301 Moved Permanently
+	RedirectStatus int
+}
+
+// NormalizePath returns a middleware that canonicalizes request paths
+// before they reach next, so caches, signed URLs and routers always see the same
+// path for the same resource. Duplicate slashes are collapsed, the trailing slash
+// policy is applied with a redirect, and the host is optionally lowercased.
+func NormalizePath(next http.Handler, opts ...NormalizePathOptions) http.Handler {
+	var options NormalizePathOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	if options.RedirectStatus == 0 {
+		options.RedirectStatus = http.StatusMovedPermanently
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if options.LowercaseHost {
+			r.Host = strings.ToLower(r.Host)
+			r.URL.Host = strings.ToLower(r.URL.Host)
+		}
+
+		p, rawPath := collapseSlashes(r.URL.Path), collapseSlashes(r.URL.RawPath)
+		redirect := options.RedirectDuplicateSlashes && p != r.URL.Path
+
+		if p != "/" {
+			switch options.TrailingSlash {
+			case TrailingSlashStrip:
+				if strings.HasSuffix(p, "/") {
+					p, rawPath = strings.TrimSuffix(p, "/"), strings.TrimSuffix(rawPath, "/")
+					redirect = true
+				}
+			case TrailingSlashAdd:
+				if !strings.HasSuffix(p, "/") && path.Ext(p) == "" {
+					p = p + "/"
+					if rawPath != "" {
+						rawPath = rawPath + "/"
+					}
+					redirect = true
+				}
+			}
+		}
+
+		if !redirect {
+			r.URL.Path, r.URL.RawPath = p, rawPath
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		u := *r.URL
+		u.Path, u.RawPath = p, rawPath
+		status := options.RedirectStatus
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			status = http.StatusPermanentRedirect
+		}
+		http.Redirect(w, r, u.RequestURI(), status)
+	})
+}
+
+// collapseSlashes replaces every run of slashes in p by a single slash.
+func collapseSlashes(p string) string {
+	if !strings.Contains(p, "//") {
+		return p
+	}
+
+	var b strings.Builder
+	b.Grow(len(p))
+	for i := 0; i < len(p); i++ {
+		if p[i] == '/' && i > 0 && p[i-1] == '/' {
+			continue
+		}
+		b.WriteByte(p[i])
+	}
+	return b.String()
+}
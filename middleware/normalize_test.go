@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestNormalizePath tests that duplicate slashes are collapsed and the trailing
+// slash policy is applied with a redirect.
+func TestNormalizePath(t *testing.T) {
+	var seen string
+	handler := NormalizePath(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.URL.Path
+	}), NormalizePathOptions{TrailingSlash: TrailingSlashStrip})
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "//a///b", nil))
+	if seen != "/a/b" {
+		t.Errorf("expected path /a/b, got %q", seen)
+	}
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/a/", nil))
+	if rr.Code != http.StatusMovedPermanently || rr.Header().Get("Location") != "/a" {
+		t.Errorf("expected a redirect to /a, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+}
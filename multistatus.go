@@ -0,0 +1,101 @@
+package gorigumi
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ItemResult is the outcome of a single item of a bulk create, update or delete
+// request, written by MultiStatusWrite.
+type ItemResult struct {
+	// ID identifies the item in the request, e.g. its index or its id
+	ID string `json:"id,omitempty"`
+	// Status is the HTTP status the item would have had on its own. Default
+	// to 500 if Error is set, 200 otherwise
+	Status int `json:"status"`
+	// Error is the error message of a failed item
+	Error string `json:"error,omitempty"`
+	// Resource is the created or updated resource
+	Resource any `json:"resource,omitempty"`
+}
+
+// ItemError returns the ItemResult of an item identified by id that failed
+// with err, with an optional status. Default to 500, as for JSONError.
+func ItemError(id string, err error, status ...int) ItemResult {
+	statusCode := http.StatusInternalServerError
+	if len(status) > 0 {
+		statusCode = status[0]
+	}
+	return ItemResult{ID: id, Status: statusCode, Error: err.Error()}
+}
+
+// MultiStatusResponse is the envelope written by MultiStatusWrite.
+type MultiStatusResponse struct {
+	Results []ItemResult `json:"results"`
+	// Succeeded is the number of items with a 2xx status
+	Succeeded int `json:"succeeded"`
+	// Failed is the number of the other items
+	Failed int `json:"failed"`
+}
+
+// MultiStatusWrite writes the results of a bulk operation as a
+// MultiStatusResponse. The status of the response is the status shared by all
+// items, or 207 Multi-Status when they differ, so clients can tell a partial
+// success from a complete one without reading every item. Empty results are
+// answered with 200.
+func (t *Tools) MultiStatusWrite(w http.ResponseWriter, results []ItemResult, headers ...http.Header) error {
+	response := MultiStatusResponse{Results: make([]ItemResult, len(results))}
+	status := http.StatusOK
+	for i, result := range results {
+		if result.Status == 0 {
+			result.Status = http.StatusOK
+			if result.Error != "" {
+				result.Status = http.StatusInternalServerError
+			}
+		}
+		if result.Status >= 200 && result.Status < 300 {
+			response.Succeeded++
+		} else {
+			response.Failed++
+		}
+
+		switch {
+		case i == 0:
+			status = result.Status
+		case result.Status != status:
+			status = http.StatusMultiStatus
+		}
+		response.Results[i] = result
+	}
+
+	return t.JSONWrite(w, status, response, headers...)
+}
+
+// UploadItemResults converts the outcome of an upload processed with
+// UploadConcurrency, where failing files do not stop the others, into
+// ItemResult values for MultiStatusWrite: stored files are 201 Created with the
+// file as resource, and files of a *FileError are 400 Bad Request, all
+// identified by their original name. Other errors fail the whole upload and
+// are returned.
+func UploadItemResults(files []*UploadedFile, err error) ([]ItemResult, error) {
+	results := make([]ItemResult, 0, len(files))
+	for _, file := range files {
+		results = append(results, ItemResult{ID: file.OriginalFileName, Status: http.StatusCreated, Resource: file})
+	}
+	if err == nil {
+		return results, nil
+	}
+
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+	for _, e := range errs {
+		var fileErr *FileError
+		if !errors.As(e, &fileErr) {
+			return nil, err
+		}
+		results = append(results, ItemError(fileErr.FileName, fileErr.Err, http.StatusBadRequest))
+	}
+	return results, nil
+}
@@ -0,0 +1,80 @@
+package gorigumi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// multiStatusTests is a slice of structs that hold the name of the test, the item
+// results, the expected status and the expected numbers of succeeded and failed items
+var multiStatusTests = []struct {
+	name      string
+	results   []ItemResult
+	status    int
+	succeeded int
+	failed    int
+}{
+	{"empty", nil, http.StatusOK, 0, 0},
+	{"all created", []ItemResult{{ID: "1", Status: 201}, {ID: "2", Status: 201}}, http.StatusCreated, 2, 0},
+	{"mixed", []ItemResult{{ID: "1", Status: 201}, ItemError("2", errors.New("name is required"), 422)}, http.StatusMultiStatus, 1, 1},
+	{"all failed", []ItemResult{ItemError("1", errors.New("conflict"), 409), ItemError("2", errors.New("conflict"), 409)}, http.StatusConflict, 0, 2},
+	{"default statuses", []ItemResult{{ID: "1"}, {ID: "2", Error: "boom"}}, http.StatusMultiStatus, 1, 1},
+}
+
+// TestTools_MultiStatusWrite tests the status and the envelope of bulk responses.
+func TestTools_MultiStatusWrite(t *testing.T) {
+	testTools := New()
+
+	for _, tt := range multiStatusTests {
+		rr := httptest.NewRecorder()
+		if err := testTools.MultiStatusWrite(rr, tt.results); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if rr.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.status, rr.Code)
+		}
+
+		var res MultiStatusResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if res.Succeeded != tt.succeeded || res.Failed != tt.failed || len(res.Results) != len(tt.results) {
+			t.Errorf("%s: expected %d succeeded and %d failed, got %+v", tt.name, tt.succeeded, tt.failed, res)
+		}
+		for _, item := range res.Results {
+			if item.Status == 0 {
+				t.Errorf("%s: expected item %s to have a status", tt.name, item.ID)
+			}
+		}
+	}
+}
+
+// TestUploadItemResults tests the conversion of concurrent upload outcomes.
+func TestUploadItemResults(t *testing.T) {
+	files := []*UploadedFile{{OriginalFileName: "a.png", NewFileName: "x.png"}}
+	err := errors.Join(&FileError{FileName: "b.exe", Err: errors.New("file type not allowed")})
+
+	results, err := UploadItemResults(files, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if results[0].ID != "a.png" || results[0].Status != http.StatusCreated || results[0].Resource != files[0] {
+		t.Errorf("unexpected result %+v", results[0])
+	}
+	if results[1].ID != "b.exe" || results[1].Status != http.StatusBadRequest || results[1].Error != "file type not allowed" {
+		t.Errorf("unexpected result %+v", results[1])
+	}
+
+	if _, err := UploadItemResults(nil, fmt.Errorf("too many files")); err == nil {
+		t.Error("expected errors of the whole upload to be returned")
+	}
+}
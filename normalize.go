@@ -0,0 +1,34 @@
+package gorigumi
+
+import (
+	"net/http"
+
+	"github.com/drunkleen/gorigumi/middleware"
+)
+
+// TrailingSlashPolicy tells NormalizePathMiddleware what to do with the
+// trailing slash of request paths. It is an alias of
+// middleware.TrailingSlashPolicy.
+type TrailingSlashPolicy = middleware.TrailingSlashPolicy
+
+const (
+	// TrailingSlashKeep leaves trailing slashes as they are
+	TrailingSlashKeep = middleware.TrailingSlashKeep
+	// TrailingSlashStrip redirects /a/ to /a
+	TrailingSlashStrip = middleware.TrailingSlashStrip
+	// TrailingSlashAdd redirects /a to /a/. Paths whose last segment
+	// has an extension, such as /a/logo.png, are left alone
+	TrailingSlashAdd = middleware.TrailingSlashAdd
+)
+
+// NormalizePathOptions configures NormalizePathMiddleware. It is an alias of
+// middleware.NormalizePathOptions.
+type NormalizePathOptions = middleware.NormalizePathOptions
+
+// NormalizePathMiddleware returns a middleware that canonicalizes request paths
+// before they reach next, so caches, signed URLs and routers always see the same
+// path for the same resource. Duplicate slashes are collapsed, the trailing slash
+// policy is applied with a redirect, and the host is optionally lowercased.
+func (t *Tools) NormalizePathMiddleware(next http.Handler, opts ...NormalizePathOptions) http.Handler {
+	return middleware.NormalizePath(next, opts...)
+}
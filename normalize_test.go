@@ -0,0 +1,70 @@
+package gorigumi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// normalizeTests is a slice of structs that hold the name of the test, the options, the
+// method and target of the request, the expected status, and the expected path seen by the
+// handler or the expected redirect location
+var normalizeTests = []struct {
+	name     string
+	opts     NormalizePathOptions
+	method   string
+	target   string
+	status   int
+	expected string
+}{
+	{"clean path", NormalizePathOptions{}, "GET", "/a/b", http.StatusOK, "/a/b"},
+	{"duplicate slashes rewritten", NormalizePathOptions{}, "GET", "//a///b", http.StatusOK, "/a/b"},
+	{"duplicate slashes redirected", NormalizePathOptions{RedirectDuplicateSlashes: true}, "GET", "/a//b?x=1", http.StatusMovedPermanently, "/a/b?x=1"},
+	{"keep trailing slash", NormalizePathOptions{}, "GET", "/a/", http.StatusOK, "/a/"},
+	{"strip trailing slash", NormalizePathOptions{TrailingSlash: TrailingSlashStrip}, "GET", "/a/", http.StatusMovedPermanently, "/a"},
+	{"strip keeps root", NormalizePathOptions{TrailingSlash: TrailingSlashStrip}, "GET", "/", http.StatusOK, "/"},
+	{"strip with post", NormalizePathOptions{TrailingSlash: TrailingSlashStrip}, "POST", "/a/", http.StatusPermanentRedirect, "/a"},
+	{"add trailing slash", NormalizePathOptions{TrailingSlash: TrailingSlashAdd, RedirectStatus: http.StatusFound}, "GET", "/a", http.StatusFound, "/a/"},
+	{"add skips files", NormalizePathOptions{TrailingSlash: TrailingSlashAdd}, "GET", "/a/logo.png", http.StatusOK, "/a/logo.png"},
+	{"no open redirect", NormalizePathOptions{TrailingSlash: TrailingSlashStrip}, "GET", "//evil.com/", http.StatusMovedPermanently, "/evil.com"},
+}
+
+// TestTools_NormalizePathMiddleware tests the rewriting and redirecting of request paths.
+func TestTools_NormalizePathMiddleware(t *testing.T) {
+	testTools := New()
+
+	for _, nt := range normalizeTests {
+		var seen string
+		handler := testTools.NormalizePathMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = r.URL.Path
+		}), nt.opts)
+
+		req := httptest.NewRequest(nt.method, "http://example.com"+nt.target, nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != nt.status {
+			t.Errorf("%s: expected status %d, got %d", nt.name, nt.status, rr.Code)
+			continue
+		}
+		if rr.Code == http.StatusOK && seen != nt.expected {
+			t.Errorf("%s: expected path %q, got %q", nt.name, nt.expected, seen)
+		}
+		if rr.Code != http.StatusOK && rr.Header().Get("Location") != nt.expected {
+			t.Errorf("%s: expected location %q, got %q", nt.name, nt.expected, rr.Header().Get("Location"))
+		}
+	}
+}
+
+// TestTools_NormalizePathMiddlewareHost tests that the host is lowercased when enabled.
+func TestTools_NormalizePathMiddlewareHost(t *testing.T) {
+	var host string
+	handler := New().NormalizePathMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+	}), NormalizePathOptions{LowercaseHost: true})
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://Example.COM/", nil))
+	if host != "example.com" {
+		t.Errorf("expected lowercased host, got %q", host)
+	}
+}
@@ -0,0 +1,65 @@
+package gorigumi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime/multipart"
+)
+
+// FileError is the error of a single file of an upload processed with
+// UploadConcurrency, joined with the errors of the other files.
+type FileError struct {
+	FileName string
+	Err      error
+}
+
+// Error returns the error message, prefixed with the file name.
+func (e *FileError) Error() string {
+	return fmt.Sprintf("file %q: %v", e.FileName, e.Err)
+}
+
+// Unwrap returns the error of the file.
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// uploadConcurrently stores files with at most UploadConcurrency of them at
+// once. Unlike the sequential upload, a failing file does not stop the others:
+// the stored files are returned with the errors of the failed
+// ones joined as *FileError values.
+func (t *Tools) uploadConcurrently(ctx context.Context, files map[string][]*multipart.FileHeader, uploadDir string, renameFile bool) ([]*UploadedFile, error) {
+	var hdrs []*multipart.FileHeader
+	for _, fHeaders := range files {
+		hdrs = append(hdrs, fHeaders...)
+	}
+
+	uploaded := make([]*UploadedFile, len(hdrs))
+	errs := make([]error, len(hdrs))
+
+	// per-file errors are collected rather than returned, so that one file
+	// does not cancel the others; only panics reach the group
+	g, _ := NewGroup(ctx, t.UploadConcurrency)
+	for i, hdr := range hdrs {
+		g.Go(func(ctx context.Context) error {
+			uploadedFile, err := t.uploadCheck(ctx, hdr, uploadDir, renameFile)
+			if err != nil {
+				errs[i] = &FileError{FileName: hdr.Filename, Err: err}
+				return nil
+			}
+			uploaded[i] = uploadedFile
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		errs = append(errs, err)
+	}
+
+	var uploadedFiles []*UploadedFile
+	for _, f := range uploaded {
+		if f != nil {
+			uploadedFiles = append(uploadedFiles, f)
+		}
+	}
+	return uploadedFiles, errors.Join(errs...)
+}
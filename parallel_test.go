@@ -0,0 +1,49 @@
+package gorigumi
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/drunkleen/gorigumi/toolkittest"
+)
+
+// TestTools_UploadConcurrency tests that files are all processed when uploaded
+// concurrently, and that the errors of the rejected ones are joined.
+func TestTools_UploadConcurrency(t *testing.T) {
+	img, err := toolkittest.GenerateImage(10, 10, "png")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string][]byte{"bad-1.png": img, "bad-2.png": img}
+	for i := range 20 {
+		files[fmt.Sprintf("file-%d.txt", i)] = []byte("some text")
+	}
+
+	uploadDir := t.TempDir()
+	testTools := New()
+	testTools.AllowedFileTypes = []string{"text/plain; charset=utf-8"}
+	testTools.UploadConcurrency = 4
+
+	uploaded, err := testTools.UploadFiles(newMultipartRequest(t, files), uploadDir)
+	if len(uploaded) != 20 {
+		t.Errorf("expected 20 files to be stored, got %d", len(uploaded))
+	}
+
+	var joined interface{ Unwrap() []error }
+	if !errors.As(err, &joined) || len(joined.Unwrap()) != 2 {
+		t.Fatalf("expected the errors of the 2 rejected files to be joined, got %v", err)
+	}
+	for _, e := range joined.Unwrap() {
+		var fileErr *FileError
+		if !errors.As(e, &fileErr) || (fileErr.FileName != "bad-1.png" && fileErr.FileName != "bad-2.png") {
+			t.Errorf("unexpected error %v", e)
+		}
+	}
+
+	if entries, _ := os.ReadDir(uploadDir); len(entries) != 20 {
+		t.Errorf("expected 20 files in the upload directory, got %d", len(entries))
+	}
+}
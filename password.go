@@ -0,0 +1,131 @@
+package gorigumi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// passwordResetPurpose is the purpose of password reset tokens
+const passwordResetPurpose string = "password-reset"
+
+// GeneratePasswordResetToken returns a signed, single-use token allowing the
+// password of the user userID to be reset within ttl. TokenSecret and
+// TokenStore must be set.
+func (t *Tools) GeneratePasswordResetToken(userID string, ttl time.Duration) (string, error) {
+	return t.signOneTimeToken(passwordResetPurpose, userID, "", ttl)
+}
+
+// ConsumePasswordResetToken checks a token returned by GeneratePasswordResetToken,
+// marks it as used and returns the id of its user. The error is ErrInvalidToken,
+// ErrTokenExpired or ErrTokenUsed for tokens that cannot be used.
+func (t *Tools) ConsumePasswordResetToken(ctx context.Context, token string) (string, error) {
+	return t.consumeToken(ctx, passwordResetPurpose, token, "")
+}
+
+// PasswordResetRequestHandler returns a handler starting password resets. It
+// reads a JSON body holding an email, looks the user up with lookup, which
+// returns an empty id for unknown addresses, and calls send with the address
+// and a token valid for ttl, e.g. to mail a link to a reset page. It responds
+// 202 whether the address is known or not, so accounts cannot be enumerated.
+func (t *Tools) PasswordResetRequestHandler(
+	lookup func(ctx context.Context, email string) (userID string, err error),
+	send func(ctx context.Context, email, token string) error,
+	ttl time.Duration,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Email string `json:"email"`
+		}
+		if err := t.JSONRead(w, r, &payload); err != nil {
+			_ = t.JSONError(w, err, http.StatusBadRequest)
+			return
+		}
+
+		email, err := t.NormalizeEmail(payload.Email)
+		if err != nil {
+			_ = t.JSONError(w, err, http.StatusBadRequest)
+			return
+		}
+
+		userID, err := lookup(r.Context(), email)
+		if err != nil {
+			_ = t.JSONError(w, err, http.StatusInternalServerError)
+			return
+		}
+		if userID != "" {
+			token, err := t.GeneratePasswordResetToken(userID, ttl)
+			if err == nil {
+				err = send(r.Context(), email, token)
+			}
+			if err != nil {
+				_ = t.JSONError(w, err, http.StatusInternalServerError)
+				return
+			}
+		}
+
+		_ = t.JSONWrite(w, http.StatusAccepted, JSONResponse{Message: "if the address is known, a reset link was sent"})
+	})
+}
+
+// PasswordResetOptions configures PasswordResetHandler.
+type PasswordResetOptions struct {
+	// Validate checks a new password against the policy of the application
+	// before the token is consumed, so a rejected password does not use it up
+	Validate func(password string) error
+}
+
+// PasswordResetHandler returns a handler completing password resets. It reads
+// a JSON body holding a token and a new password, consumes the token with
+// ConsumePasswordResetToken and calls reset with its user and the password,
+// which is where the application hashes and stores it. It responds 400 for
+// invalid or used tokens, 410 for expired ones, 422 for passwords rejected
+// by Validate, 500 if the TokenStore or reset fails, and 200 otherwise.
+func (t *Tools) PasswordResetHandler(reset func(ctx context.Context, userID, password string) error, opts ...PasswordResetOptions) http.Handler {
+	var options PasswordResetOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Token    string `json:"token"`
+			Password string `json:"password"`
+		}
+		if err := t.JSONRead(w, r, &payload); err != nil {
+			_ = t.JSONError(w, err, http.StatusBadRequest)
+			return
+		}
+		if payload.Password == "" {
+			_ = t.JSONError(w, errors.New("password is required"), http.StatusBadRequest)
+			return
+		}
+		if options.Validate != nil {
+			if err := options.Validate(payload.Password); err != nil {
+				_ = t.JSONError(w, err, http.StatusUnprocessableEntity)
+				return
+			}
+		}
+
+		userID, err := t.ConsumePasswordResetToken(r.Context(), payload.Token)
+		switch {
+		case errors.Is(err, ErrTokenExpired):
+			_ = t.JSONError(w, err, http.StatusGone)
+			return
+		case errors.Is(err, ErrInvalidToken), errors.Is(err, ErrTokenUsed):
+			_ = t.JSONError(w, err, http.StatusBadRequest)
+			return
+		case err != nil:
+			_ = t.JSONError(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		if err := reset(r.Context(), userID, payload.Password); err != nil {
+			_ = t.JSONError(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		_ = t.JSONWrite(w, http.StatusOK, JSONResponse{Message: "password reset"})
+	})
+}
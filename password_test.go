@@ -0,0 +1,102 @@
+package gorigumi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/drunkleen/gorigumi/toolkittest"
+)
+
+// newTokenTools returns a Tools struct signing tokens with a fixed secret and clock.
+func newTokenTools(clock *toolkittest.Clock) *Tools {
+	testTools := New()
+	testTools.Clock = clock
+	testTools.TokenSecret = []byte("secret")
+	testTools.TokenStore = NewMemoryTokenStore(clock)
+	return testTools
+}
+
+// TestTools_PasswordReset tests a password reset from the request of a link to the
+// use of its token, which only works once.
+func TestTools_PasswordReset(t *testing.T) {
+	clock := toolkittest.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	testTools := newTokenTools(clock)
+
+	sent := map[string]string{}
+	requestHandler := testTools.PasswordResetRequestHandler(
+		func(ctx context.Context, email string) (string, error) {
+			if email == "user@example.com" {
+				return "42", nil
+			}
+			return "", nil
+		},
+		func(ctx context.Context, email, token string) error {
+			sent[email] = token
+			return nil
+		},
+		time.Hour,
+	)
+
+	passwords := map[string]string{}
+	resetHandler := testTools.PasswordResetHandler(func(ctx context.Context, userID, password string) error {
+		passwords[userID] = password
+		return nil
+	}, PasswordResetOptions{Validate: func(password string) error {
+		if len(password) < 8 {
+			return errors.New("password is too short")
+		}
+		return nil
+	}})
+
+	send := func(handler http.Handler, body string) int {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+		return rr.Code
+	}
+
+	for _, email := range []string{"user@Example.com", "nobody@example.com"} {
+		if code := send(requestHandler, `{"email":"`+email+`"}`); code != http.StatusAccepted {
+			t.Errorf("%s: expected status 202, got %d", email, code)
+		}
+	}
+	if len(sent) != 1 || sent["user@example.com"] == "" {
+		t.Fatalf("expected a single token to be sent, got %v", sent)
+	}
+	token := sent["user@example.com"]
+
+	if code := send(requestHandler, `{"email":"not an email"}`); code != http.StatusBadRequest {
+		t.Errorf("expected invalid address to be rejected, got %d", code)
+	}
+	if code := send(resetHandler, `{"token":"`+token+`"}`); code != http.StatusBadRequest {
+		t.Errorf("expected missing password to be rejected, got %d", code)
+	}
+	if code := send(resetHandler, `{"token":"`+token+`","password":"short"}`); code != http.StatusUnprocessableEntity {
+		t.Errorf("expected weak password to be rejected, got %d", code)
+	}
+	if code := send(resetHandler, `{"token":"`+token+`","password":"correct horse"}`); code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", code)
+	}
+	if passwords["42"] != "correct horse" {
+		t.Errorf("expected the password of user 42 to be reset, got %v", passwords)
+	}
+	if code := send(resetHandler, `{"token":"`+token+`","password":"another one"}`); code != http.StatusBadRequest {
+		t.Errorf("expected used token to be rejected, got %d", code)
+	}
+
+	expired, _ := testTools.GeneratePasswordResetToken("42", time.Minute)
+	clock.Advance(2 * time.Minute)
+	if code := send(resetHandler, `{"token":"`+expired+`","password":"correct horse"}`); code != http.StatusGone {
+		t.Errorf("expected expired token to be rejected, got %d", code)
+	}
+
+	// tokens of other flows are rejected
+	emailToken, _ := testTools.GenerateEmailVerificationToken("user@example.com", time.Hour)
+	if _, err := testTools.ConsumePasswordResetToken(context.Background(), emailToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken, got %v", err)
+	}
+}
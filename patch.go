@@ -0,0 +1,108 @@
+package gorigumi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/drunkleen/gorigumi/jsonx"
+)
+
+// PatchFields holds the raw values of the fields present in the body of a
+// PATCH request, keyed by their name in the body, as returned by
+// JSONReadPatch. A field set to null is present, with the value null.
+type PatchFields map[string]json.RawMessage
+
+// Has reports whether the field at path was present in the body, even if set
+// to null. Fields of nested objects are separated by dots, e.g. "address.city".
+func (p PatchFields) Has(path string) bool {
+	_, ok := p.lookup(path)
+	return ok
+}
+
+// IsNull reports whether the field at path was present in the body with the
+// value null, which JSON Merge Patch (RFC 7396) defines as removing the field.
+func (p PatchFields) IsNull(path string) bool {
+	raw, ok := p.lookup(path)
+	return ok && string(raw) == "null"
+}
+
+// Keys returns the sorted names of the top-level fields present in the body.
+func (p PatchFields) Keys() []string {
+	keys := make([]string, 0, len(p))
+	for key := range p {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// lookup returns the raw value of the field at path, if present.
+func (p PatchFields) lookup(path string) (json.RawMessage, bool) {
+	name, rest, nested := strings.Cut(path, ".")
+	raw, ok := p[name]
+	if !ok || !nested {
+		return raw, ok
+	}
+
+	var fields PatchFields
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		// not an object, e.g. null
+		return nil, false
+	}
+	return fields.lookup(rest)
+}
+
+// JSONReadPatch reads the JSON object of the body of a PATCH request into v,
+// like JSONRead, and returns the fields present in the body, so handlers can
+// tell a field that was omitted from a field set to its zero value:
+//
+//	user := loadUser(id) // decoding into the current value only updates the fields sent
+//	fields, err := tools.JSONReadPatch(w, r, &user)
+//	if err != nil { ... }
+//	if fields.IsNull("nickname") {
+//		user.Nickname = "" // removed, with JSON Merge Patch semantics
+//	}
+//	if fields.Has("email") { ... } // verify the new address
+//
+// The size limit, unknown field checks and TolerantFieldNames apply like for
+// JSONRead, but v is not validated, as a patch usually holds few fields:
+// validate the patched value instead. The body must be a JSON object. If
+// TolerantFieldNames is set, the fields are keyed by the names of the fields of
+// v rather than as sent, e.g. "user_id" for a body holding "userId".
+func (t *Tools) JSONReadPatch(w http.ResponseWriter, r *http.Request, v any) (PatchFields, error) {
+	var raw json.RawMessage
+	if err := jsonx.Read(w, r, &raw, t.MaxJSONSize, true); err != nil {
+		return nil, err
+	}
+	if t.TolerantFieldNames {
+		normalized, err := jsonx.NormalizeKeys(raw, v)
+		if err != nil {
+			return nil, err
+		}
+		raw = normalized
+	}
+
+	var fields PatchFields
+	if err := json.Unmarshal(raw, &fields); err != nil || fields == nil {
+		return nil, WithErrorCode(errors.New("body must be a JSON object"), CodeInvalidJSON)
+	}
+
+	maxBytes := t.MaxJSONSize
+	if maxBytes == 0 {
+		maxBytes = jsonx.DefaultMaxBytes
+	}
+	var err error
+	if t.TolerantFieldNames {
+		err = jsonx.DecodeTolerant(bytes.NewReader(raw), v, maxBytes, t.AllowUnknownFields)
+	} else {
+		err = jsonx.Decode(bytes.NewReader(raw), v, maxBytes, t.AllowUnknownFields)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
@@ -0,0 +1,116 @@
+package gorigumi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// patchUser is the resource patched by the JSONReadPatch tests.
+type patchUser struct {
+	Name     string       `json:"name"`
+	Nickname string       `json:"nickname"`
+	Age      int          `json:"age"`
+	Address  *patchStreet `json:"address"`
+}
+
+// patchStreet is a nested object of patchUser.
+type patchStreet struct {
+	City string `json:"city"`
+}
+
+// jsonReadPatchTests is a slice of structs that hold the name of the test, the body,
+// the expected present and null fields, the expected patched value and whether an
+// error is expected
+var jsonReadPatchTests = []struct {
+	name    string
+	body    string
+	present []string
+	null    []string
+	want    patchUser
+	wantErr bool
+}{
+	{"zero value", `{"age":0}`, []string{"age"}, nil, patchUser{Name: "Ana", Nickname: "ana", Age: 0, Address: &patchStreet{City: "Oslo"}}, false},
+	{"omitted fields", `{"name":"Bea"}`, []string{"name"}, nil, patchUser{Name: "Bea", Nickname: "ana", Age: 30, Address: &patchStreet{City: "Oslo"}}, false},
+	{"null", `{"nickname":null,"address":null}`, []string{"address", "nickname"}, []string{"address", "nickname"}, patchUser{Name: "Ana", Nickname: "ana", Age: 30}, false},
+	{"nested", `{"address":{"city":""}}`, []string{"address", "address.city"}, nil, patchUser{Name: "Ana", Nickname: "ana", Age: 30, Address: &patchStreet{}}, false},
+	{"empty object", `{}`, nil, nil, patchUser{Name: "Ana", Nickname: "ana", Age: 30, Address: &patchStreet{City: "Oslo"}}, false},
+	{"not an object", `[1]`, nil, nil, patchUser{}, true},
+	{"null body", `null`, nil, nil, patchUser{}, true},
+	{"unknown field", `{"role":"admin"}`, nil, nil, patchUser{}, true},
+}
+
+// TestTools_JSONReadPatch tests that JSONReadPatch only updates the fields present
+// in the body and reports them.
+func TestTools_JSONReadPatch(t *testing.T) {
+	for _, tt := range jsonReadPatchTests {
+		user := patchUser{Name: "Ana", Nickname: "ana", Age: 30, Address: &patchStreet{City: "Oslo"}}
+		req := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(tt.body))
+		fields, err := New().JSONReadPatch(httptest.NewRecorder(), req, &user)
+
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+
+		for _, path := range []string{"name", "nickname", "age", "address", "address.city"} {
+			present := slices.Contains(tt.present, path)
+			if fields.Has(path) != present {
+				t.Errorf("%s: expected Has(%q) to be %t", tt.name, path, present)
+			}
+			null := slices.Contains(tt.null, path)
+			if fields.IsNull(path) != null {
+				t.Errorf("%s: expected IsNull(%q) to be %t", tt.name, path, null)
+			}
+		}
+		if keys := fields.Keys(); strings.Join(keys, ",") != strings.Join(filterTopLevel(tt.present), ",") {
+			t.Errorf("%s: unexpected keys %v", tt.name, keys)
+		}
+
+		if user.Name != tt.want.Name || user.Nickname != tt.want.Nickname || user.Age != tt.want.Age ||
+			(user.Address == nil) != (tt.want.Address == nil) ||
+			(user.Address != nil && *user.Address != *tt.want.Address) {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.want, user)
+		}
+	}
+}
+
+// filterTopLevel returns the paths of top-level fields among paths.
+func filterTopLevel(paths []string) []string {
+	var top []string
+	for _, p := range paths {
+		if !strings.Contains(p, ".") {
+			top = append(top, p)
+		}
+	}
+	return top
+}
+
+// TestTools_JSONReadPatch_tolerant tests that the fields are keyed by the names of the
+// fields of the value when TolerantFieldNames is set.
+func TestTools_JSONReadPatch_tolerant(t *testing.T) {
+	testTools := New()
+	testTools.TolerantFieldNames = true
+
+	user := patchUser{Name: "Ana", Address: &patchStreet{City: "Oslo"}}
+	req := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(`{"NickName":null,"Address":{"CITY":"Bergen"}}`))
+	fields, err := testTools.JSONReadPatch(httptest.NewRecorder(), req, &user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !fields.IsNull("nickname") || !fields.Has("address.city") || strings.Join(fields.Keys(), ",") != "address,nickname" {
+		t.Errorf("expected the fields to be keyed by their names, got %v", fields.Keys())
+	}
+	if user.Address.City != "Bergen" {
+		t.Errorf("expected the city to be patched, got %+v", user.Address)
+	}
+}
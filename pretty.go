@@ -0,0 +1,64 @@
+package gorigumi
+
+import (
+	"net/http"
+	"strconv"
+)
+
+const (
+	// defaultJSONIndent is the default indentation of pretty-printed responses
+	// it is inlcuded in the PrettyJSONMiddleware method
+	defaultJSONIndent string = "  "
+)
+
+// PrettyJSONMiddleware returns a middleware pretty-printing the responses of
+// JSONWrite, JSONError and Respond for the requests with a pretty query parameter
+// set to a true value, e.g. ?pretty=1 or ?pretty=true, so responses can be read
+// in a browser or with curl while debugging. Responses are indented with
+// JSONIndent, or two spaces if it is not set. Other requests get the responses
+// configured by JSONIndent.
+func (t *Tools) PrettyJSONMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if pretty, err := strconv.ParseBool(r.URL.Query().Get("pretty")); err == nil && pretty {
+			w = &prettyWriter{ResponseWriter: w}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// jsonIndent returns the indentation of the JSON responses written to w, the
+// JSONIndent of the Tools struct, or its default if w was marked by
+// PrettyJSONMiddleware.
+func (t *Tools) jsonIndent(w http.ResponseWriter) string {
+	if t.JSONIndent != "" || !isPretty(w) {
+		return t.JSONIndent
+	}
+	return defaultJSONIndent
+}
+
+// prettyWriter marks the http.ResponseWriter of a request asking for
+// pretty-printed responses.
+type prettyWriter struct {
+	http.ResponseWriter
+}
+
+// Unwrap returns the underlying http.ResponseWriter, so http.ResponseController
+// can reach its optional interfaces.
+func (p *prettyWriter) Unwrap() http.ResponseWriter {
+	return p.ResponseWriter
+}
+
+// isPretty reports whether w, or a writer it wraps, was marked by
+// PrettyJSONMiddleware.
+func isPretty(w http.ResponseWriter) bool {
+	for {
+		switch rw := w.(type) {
+		case *prettyWriter:
+			return true
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = rw.Unwrap()
+		default:
+			return false
+		}
+	}
+}
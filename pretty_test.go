@@ -0,0 +1,62 @@
+package gorigumi
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// prettyTests is a slice of structs that hold the name of the test, the JSONIndent of the
+// Tools struct, the target of the request, and the expected response body
+var prettyTests = []struct {
+	name   string
+	indent string
+	target string
+	body   string
+}{
+	{"compact", "", "/", `{"name":"ana"}`},
+	{"pretty", "", "/?pretty=1", "{\n  \"name\": \"ana\"\n}\n"},
+	{"pretty true", "", "/?pretty=true", "{\n  \"name\": \"ana\"\n}\n"},
+	{"not pretty", "", "/?pretty=0", `{"name":"ana"}`},
+	{"invalid", "", "/?pretty=yes", `{"name":"ana"}`},
+	{"indent", "\t", "/", "{\n\t\"name\": \"ana\"\n}\n"},
+	{"pretty with indent", "    ", "/?pretty=1", "{\n    \"name\": \"ana\"\n}\n"},
+}
+
+// TestTools_PrettyJSONMiddleware tests that responses are indented with JSONIndent, and
+// with two spaces for the requests asking for it.
+func TestTools_PrettyJSONMiddleware(t *testing.T) {
+	for _, pt := range prettyTests {
+		testTools := New()
+		testTools.JSONIndent = pt.indent
+
+		handler := testTools.PrettyJSONMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_ = testTools.JSONWrite(w, http.StatusOK, map[string]string{"name": "ana"})
+		}))
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", pt.target, nil))
+
+		if rr.Body.String() != pt.body {
+			t.Errorf("%s: expected %q, got %q", pt.name, pt.body, rr.Body.String())
+		}
+	}
+}
+
+// TestTools_PrettyJSONMiddleware_errors tests that errors are pretty-printed, through
+// the writers of other middlewares.
+func TestTools_PrettyJSONMiddleware_errors(t *testing.T) {
+	testTools := New()
+	handler := testTools.PrettyJSONMiddleware(testTools.MetricsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = testTools.JSONError(w, errors.New("not found"), http.StatusNotFound)
+	}), NewMetrics()))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/?pretty=1", nil))
+
+	if !strings.HasPrefix(rr.Body.String(), "{\n  \"error\": true,\n") || rr.Code != http.StatusNotFound {
+		t.Errorf("expected a pretty-printed error, got %d %q", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,151 @@
+package gorigumi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultProxyTimeout is the default timeout of proxied requests
+	// it is inlcuded in the ProxyDownload method
+	defaultProxyTimeout time.Duration = 30 * time.Second
+
+	// defaultProxyMaxSize is the default maximum size of proxied resources
+	// it is inlcuded in the ProxyDownload method
+	defaultProxyMaxSize int64 = 10 * 1024 * 1024
+)
+
+// proxyRequestHeaders are the headers of the client forwarded to the remote
+var proxyRequestHeaders = []string{"Accept", "If-None-Match", "If-Modified-Since"}
+
+// proxyResponseHeaders are the headers of the remote forwarded to the client
+var proxyResponseHeaders = []string{"Content-Type", "Content-Length", "ETag", "Last-Modified", "Cache-Control", "Expires"}
+
+// ProxyOptions configures ProxyDownload.
+type ProxyOptions struct {
+	// Client is the client used to fetch remote resources. Default to a
+	// new http.Client
+	Client *http.Client
+	// Timeout is the maximum duration of the remote request, including the
+	// transfer of its body. Default to 30s
+	Timeout time.Duration
+	// MaxSize is the maximum size in bytes of a remote resource. Default to 10MB
+	MaxSize int64
+	// AllowedContentTypes is the list of content types served, e.g.
+	// "image/png" or "image/*". Default to any type
+	AllowedContentTypes []string
+	// CacheMaxAge, if set, replaces the caching headers of the remote with a
+	// Cache-Control header letting clients and CDNs cache the resource
+	CacheMaxAge time.Duration
+}
+
+// ProxyDownload streams the resource at remoteURL to the client, e.g. to serve
+// third-party images from our own domain because of a Content-Security-Policy.
+// Only the GET method is proxied, conditional request headers are forwarded,
+// and only content and caching headers of the remote are passed on, along with
+// X-Content-Type-Options: nosniff. Remote errors, resources over MaxSize and
+// types not allowed are answered with 502 Bad Gateway. Resources without a
+// Content-Length growing past MaxSize while being streamed are cut off after
+// MaxSize bytes by panicking with http.ErrAbortHandler, so the client sees an
+// aborted response rather than a complete one. remoteURL must be trusted, or
+// checked against an allow list, as it is fetched from the server.
+func (t *Tools) ProxyDownload(w http.ResponseWriter, r *http.Request, remoteURL string, opts ...ProxyOptions) error {
+	var options ProxyOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	if options.Client == nil {
+		options.Client = &http.Client{}
+	}
+	if options.Timeout == 0 {
+		options.Timeout = defaultProxyTimeout
+	}
+	if options.MaxSize == 0 {
+		options.MaxSize = defaultProxyMaxSize
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), options.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, remoteURL, nil)
+	if err != nil {
+		_ = t.JSONError(w, errors.New("invalid remote URL"), http.StatusBadGateway)
+		return err
+	}
+	for _, h := range proxyRequestHeaders {
+		if v := r.Header.Get(h); v != "" {
+			req.Header.Set(h, v)
+		}
+	}
+
+	res, err := options.Client.Do(req)
+	if err != nil {
+		_ = t.JSONError(w, errors.New("remote resource is unavailable"), http.StatusBadGateway)
+		return err
+	}
+	defer res.Body.Close()
+
+	if err := checkProxyResponse(res, options); err != nil {
+		_ = t.JSONError(w, err, http.StatusBadGateway)
+		return err
+	}
+
+	for _, h := range proxyResponseHeaders {
+		if v := res.Header.Get(h); v != "" {
+			w.Header().Set(h, v)
+		}
+	}
+	if options.CacheMaxAge > 0 {
+		w.Header().Del("Expires")
+		w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(options.CacheMaxAge.Seconds())))
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(res.StatusCode)
+
+	if res.StatusCode == http.StatusNotModified {
+		return nil
+	}
+
+	if _, err := io.Copy(w, io.LimitReader(res.Body, options.MaxSize)); err != nil {
+		return err
+	}
+	// a resource of unknown length may not stop at MaxSize
+	if n, _ := io.ReadFull(res.Body, make([]byte, 1)); n > 0 {
+		panic(http.ErrAbortHandler)
+	}
+	return nil
+}
+
+// checkProxyResponse checks the status, size and type of a remote response.
+func checkProxyResponse(res *http.Response, options ProxyOptions) error {
+	if res.StatusCode == http.StatusNotModified {
+		return nil
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("remote resource returned status %d", res.StatusCode)
+	}
+	if res.ContentLength > options.MaxSize {
+		return fmt.Errorf("remote resource exceeds %d bytes", options.MaxSize)
+	}
+	if len(options.AllowedContentTypes) == 0 {
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	for _, allowed := range options.AllowedContentTypes {
+		if prefix, ok := strings.CutSuffix(allowed, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return nil
+		}
+		if strings.EqualFold(allowed, mediaType) {
+			return nil
+		}
+	}
+	return fmt.Errorf("remote content type %q is not allowed", mediaType)
+}
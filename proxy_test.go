@@ -0,0 +1,107 @@
+package gorigumi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// proxyTests is a slice of structs that hold the name of the test, the remote path, the
+// request headers, the proxy options, the expected status and the expected body
+var proxyTests = []struct {
+	name    string
+	path    string
+	headers map[string]string
+	opts    ProxyOptions
+	status  int
+	body    string
+}{
+	{"image", "/image.png", nil, ProxyOptions{AllowedContentTypes: []string{"image/*"}}, http.StatusOK, "png data"},
+	{"type not allowed", "/page.html", nil, ProxyOptions{AllowedContentTypes: []string{"image/*"}}, http.StatusBadGateway, ""},
+	{"too large", "/image.png", nil, ProxyOptions{MaxSize: 4}, http.StatusBadGateway, ""},
+	{"remote error", "/missing", nil, ProxyOptions{}, http.StatusBadGateway, ""},
+	{"not modified", "/image.png", map[string]string{"If-None-Match": `"v1"`}, ProxyOptions{}, http.StatusNotModified, ""},
+	{"timeout", "/slow", nil, ProxyOptions{Timeout: 50 * time.Millisecond}, http.StatusBadGateway, ""},
+}
+
+// TestTools_ProxyDownload tests the forwarding of remote resources and the rejection of
+// failing, oversized and disallowed ones.
+func TestTools_ProxyDownload(t *testing.T) {
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=remote")
+		switch r.URL.Path {
+		case "/image.png":
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("png data"))
+		case "/page.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html>"))
+		case "/slow":
+			time.Sleep(time.Second)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer remote.Close()
+
+	testTools := New()
+	for _, pt := range proxyTests {
+		req := httptest.NewRequest("GET", "/proxy", nil)
+		for k, v := range pt.headers {
+			req.Header.Set(k, v)
+		}
+		rr := httptest.NewRecorder()
+		testTools.ProxyDownload(rr, req, remote.URL+pt.path, pt.opts)
+
+		if rr.Code != pt.status {
+			t.Errorf("%s: expected status %d, got %d", pt.name, pt.status, rr.Code)
+		}
+		if pt.status == http.StatusOK && rr.Body.String() != pt.body {
+			t.Errorf("%s: expected body %q, got %q", pt.name, pt.body, rr.Body)
+		}
+		if rr.Header().Get("Set-Cookie") != "" {
+			t.Errorf("%s: expected remote cookies to be filtered", pt.name)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	testTools.ProxyDownload(rr, httptest.NewRequest("GET", "/proxy", nil), remote.URL+"/image.png", ProxyOptions{CacheMaxAge: time.Hour})
+	if cc := rr.Header().Get("Cache-Control"); !strings.Contains(cc, "max-age=3600") {
+		t.Errorf("expected a cacheable response, got Cache-Control %q", cc)
+	}
+	if rr.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Error("expected X-Content-Type-Options: nosniff")
+	}
+}
+
+// TestTools_ProxyDownload_overflow tests that a resource of unknown length growing
+// past MaxSize is cut off at MaxSize bytes and the response aborted.
+func TestTools_ProxyDownload_overflow(t *testing.T) {
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		for range 4 {
+			// flushed writes are sent without a Content-Length
+			w.Write([]byte("png "))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer remote.Close()
+
+	rr := httptest.NewRecorder()
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Errorf("expected the response to be aborted, got %v", v)
+		}
+		if rr.Body.String() != "png png " {
+			t.Errorf("expected the body to be cut off at 8 bytes, got %q", rr.Body)
+		}
+	}()
+	New().ProxyDownload(rr, httptest.NewRequest("GET", "/proxy", nil), remote.URL, ProxyOptions{MaxSize: 8})
+}
@@ -0,0 +1,355 @@
+package gorigumi
+
+import (
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/drunkleen/gorigumi/random"
+)
+
+const (
+	// defaultQuarantineSuffix is the suffix of the default quarantine directory,
+	// appended to the upload directory so it is outside of it
+	// it is inlcuded in the quarantineDir method
+	defaultQuarantineSuffix string = ".quarantine"
+
+	// quarantineReportExt is the extension appended to the ID of a quarantined
+	// file to get the name of its report
+	quarantineReportExt string = ".report.json"
+
+	// quarantineIDLength is the length of the IDs of quarantined files
+	quarantineIDLength int = 32
+)
+
+// Actions of the audit events of the quarantine.
+const (
+	AuditUploadQuarantined = "upload.quarantined"
+	AuditUploadReleased    = "upload.released"
+	AuditUploadDeleted     = "upload.quarantine_deleted"
+)
+
+var (
+	// ErrFileQuarantined is returned for uploaded files flagged by the Scanner,
+	// which were moved to the quarantine.
+	ErrFileQuarantined = WithErrorCode(errors.New("file was flagged by the malware scanner"), CodeFileQuarantined)
+	// ErrQuarantineNotFound is returned for unknown quarantine IDs.
+	ErrQuarantineNotFound = WithErrorCode(errors.New("quarantined file not found"), CodeNotFound)
+	// ErrInvalidQuarantineReport is returned for quarantine reports whose
+	// signature does not match, e.g. edited on disk.
+	ErrInvalidQuarantineReport = errors.New("invalid quarantine report signature")
+)
+
+// Scanner scans uploaded files for malware, e.g. with ClamAV. Scan receives
+// the path of the temporary file holding an upload, before it is moved to its
+// name, and returns an error if the file could not be scanned, in which case
+// the upload fails and the file is removed.
+type Scanner interface {
+	Scan(ctx context.Context, path string) (ScanResult, error)
+}
+
+// ScannerFunc is a function implementing Scanner.
+type ScannerFunc func(ctx context.Context, path string) (ScanResult, error)
+
+// Scan calls f(ctx, path).
+func (f ScannerFunc) Scan(ctx context.Context, path string) (ScanResult, error) {
+	return f(ctx, path)
+}
+
+// ScanResult is the verdict of a Scanner on a file.
+type ScanResult struct {
+	// Infected is a boolean that indicates if the file was flagged
+	Infected bool
+	// Verdict describes the finding, e.g. "Eicar-Test-Signature"
+	Verdict string
+	// Scanner names the scanner and its version, e.g. "ClamAV 1.3.1"
+	Scanner string
+}
+
+// QuarantineReport describes a file moved to the quarantine. It is stored next
+// to the file and signed with the TokenSecret of the Tools struct, so it cannot
+// be edited without being detected.
+type QuarantineReport struct {
+	ID               string    `json:"id"`
+	OriginalFileName string    `json:"original_file_name"`
+	FileName         string    `json:"file_name"`
+	FileSize         int64     `json:"file_size"`
+	SHA256           string    `json:"sha256"`
+	Verdict          string    `json:"verdict"`
+	Scanner          string    `json:"scanner"`
+	QuarantinedAt    time.Time `json:"quarantined_at"`
+	Signature        string    `json:"signature"`
+}
+
+// scanUpload scans the file at path, the temporary file of an upload to
+// uploadDir, with the Scanner of the Tools struct if set, and moves it to the
+// quarantine if it is flagged. Files that cannot be scanned or quarantined are
+// removed.
+func (t *Tools) scanUpload(ctx context.Context, uploadDir, path string, file *UploadedFile) error {
+	if t.Scanner == nil {
+		return nil
+	}
+	result, err := t.Scanner.Scan(ctx, path)
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("scanning file %q: %w", file.OriginalFileName, err)
+	}
+	if !result.Infected {
+		return nil
+	}
+
+	if err := t.quarantine(ctx, uploadDir, path, file, result); err != nil {
+		os.Remove(path)
+		return fmt.Errorf("quarantining file %q: %w", file.OriginalFileName, err)
+	}
+	return fmt.Errorf("%w: file %q", ErrFileQuarantined, file.OriginalFileName)
+}
+
+// quarantine moves the file at path, uploaded to uploadDir, to the quarantine
+// along with its signed report, and emits an audit event.
+func (t *Tools) quarantine(ctx context.Context, uploadDir, path string, file *UploadedFile, result ScanResult) error {
+	if len(t.TokenSecret) == 0 {
+		return errors.New("TokenSecret is not set")
+	}
+	dir := t.quarantineDir(uploadDir)
+	if err := t.CreateDirIfNotExists(dir); err != nil {
+		return err
+	}
+
+	sum, err := fileSHA256(path)
+	if err != nil {
+		return err
+	}
+
+	report := QuarantineReport{
+		ID:               t.GenerateRandomString(quarantineIDLength),
+		OriginalFileName: file.OriginalFileName,
+		FileName:         file.NewFileName,
+		FileSize:         file.FileSize,
+		SHA256:           sum,
+		Verdict:          result.Verdict,
+		Scanner:          result.Scanner,
+		QuarantinedAt:    t.clock().Now().UTC(),
+	}
+	report.Signature, err = t.quarantineSignature(report)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	quarantined := filepath.Join(dir, report.ID)
+	if err := os.Rename(path, quarantined); err != nil {
+		return err
+	}
+	// quarantined files are only readable by their owner
+	os.Chmod(quarantined, 0600)
+	if err := writeFileReplacing(quarantined+quarantineReportExt, data); err != nil {
+		os.Remove(quarantined)
+		return err
+	}
+
+	t.audit(ctx, AuditEvent{
+		Action:   AuditUploadQuarantined,
+		Resource: report.ID,
+		Time:     report.QuarantinedAt,
+		Attrs: map[string]string{
+			"file_name": report.OriginalFileName,
+			"sha256":    report.SHA256,
+			"verdict":   report.Verdict,
+			"scanner":   report.Scanner,
+		},
+	})
+	return nil
+}
+
+// ListQuarantined returns the reports of the files quarantined from uploadDir,
+// oldest first. Reports whose signature does not match are left out, and
+// reported by an error wrapping ErrInvalidQuarantineReport along with the
+// valid reports.
+func (t *Tools) ListQuarantined(uploadDir string) ([]QuarantineReport, error) {
+	entries, err := os.ReadDir(t.quarantineDir(uploadDir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var reports []QuarantineReport
+	var errs []error
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), quarantineReportExt)
+		if !ok || e.IsDir() {
+			continue
+		}
+		report, err := t.readQuarantineReport(uploadDir, id)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("quarantine %s: %w", id, err))
+			continue
+		}
+		reports = append(reports, *report)
+	}
+
+	slices.SortFunc(reports, func(a, b QuarantineReport) int {
+		return cmp.Or(a.QuarantinedAt.Compare(b.QuarantinedAt), strings.Compare(a.ID, b.ID))
+	})
+	return reports, errors.Join(errs...)
+}
+
+// ReleaseFromQuarantine moves the quarantined file id back to uploadDir under the
+// name it was stored under, e.g. once an administrator reviewed a false positive,
+// and emits an audit event naming admin. Existing files are handled according to
+// the CollisionPolicy, and the name the file was released under is returned.
+func (t *Tools) ReleaseFromQuarantine(ctx context.Context, uploadDir, id, admin string) (*UploadedFile, error) {
+	report, err := t.reviewQuarantined(uploadDir, id, admin)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := t.placeFile(filepath.Join(t.quarantineDir(uploadDir), id), uploadDir, report.FileName)
+	if err != nil {
+		return nil, err
+	}
+	os.Chmod(filepath.Join(uploadDir, name), 0644)
+	os.Remove(filepath.Join(t.quarantineDir(uploadDir), id+quarantineReportExt))
+
+	t.audit(ctx, AuditEvent{
+		Action:   AuditUploadReleased,
+		Actor:    admin,
+		Resource: id,
+		Attrs:    map[string]string{"file_name": name, "sha256": report.SHA256, "verdict": report.Verdict},
+	})
+	return &UploadedFile{OriginalFileName: report.OriginalFileName, NewFileName: name, FileSize: report.FileSize}, nil
+}
+
+// DeleteQuarantined deletes the quarantined file id and its report, once an
+// administrator reviewed it, and emits an audit event naming admin.
+func (t *Tools) DeleteQuarantined(ctx context.Context, uploadDir, id, admin string) error {
+	report, err := t.reviewQuarantined(uploadDir, id, admin)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(t.quarantineDir(uploadDir), id)
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	os.Remove(path + quarantineReportExt)
+
+	t.audit(ctx, AuditEvent{
+		Action:   AuditUploadDeleted,
+		Actor:    admin,
+		Resource: id,
+		Attrs:    map[string]string{"sha256": report.SHA256, "verdict": report.Verdict},
+	})
+	return nil
+}
+
+// reviewQuarantined returns the verified report of the quarantined file id,
+// reviewed by admin.
+func (t *Tools) reviewQuarantined(uploadDir, id, admin string) (*QuarantineReport, error) {
+	if admin == "" {
+		return nil, errors.New("the administrator reviewing quarantined files is required")
+	}
+	report, err := t.readQuarantineReport(uploadDir, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// the file must still be the one that was flagged
+	sum, err := fileSHA256(filepath.Join(t.quarantineDir(uploadDir), id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrQuarantineNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if sum != report.SHA256 {
+		return nil, fmt.Errorf("%w: the file does not match its hash", ErrInvalidQuarantineReport)
+	}
+	return report, nil
+}
+
+// readQuarantineReport reads and verifies the report of the quarantined file id.
+func (t *Tools) readQuarantineReport(uploadDir, id string) (*QuarantineReport, error) {
+	if len(id) != quarantineIDLength || strings.ContainsFunc(id, func(r rune) bool {
+		return !strings.ContainsRune(random.Source, r)
+	}) {
+		return nil, ErrQuarantineNotFound
+	}
+
+	data, err := os.ReadFile(filepath.Join(t.quarantineDir(uploadDir), id+quarantineReportExt))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrQuarantineNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var report QuarantineReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidQuarantineReport, err)
+	}
+	signature, err := t.quarantineSignature(report)
+	if err != nil {
+		return nil, err
+	}
+	if report.ID != id || !hmac.Equal([]byte(report.Signature), []byte(signature)) {
+		return nil, ErrInvalidQuarantineReport
+	}
+	return &report, nil
+}
+
+// quarantineSignature returns the signature of report, made with TokenSecret
+// over all its fields but the signature.
+func (t *Tools) quarantineSignature(report QuarantineReport) (string, error) {
+	if len(t.TokenSecret) == 0 {
+		return "", errors.New("TokenSecret is not set")
+	}
+	report.Signature = ""
+	payload, err := json.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+	return t.tokenSignature("quarantine", string(payload), ""), nil
+}
+
+// quarantineDir returns the quarantine directory of uploadDir, the
+// QuarantineDir of the Tools struct if set. The default one is next to
+// uploadDir rather than in it, so flagged files can't be downloaded from it.
+func (t *Tools) quarantineDir(uploadDir string) string {
+	if t.QuarantineDir != "" {
+		return t.QuarantineDir
+	}
+	return filepath.Clean(uploadDir) + defaultQuarantineSuffix
+}
+
+// fileSHA256 returns the hex encoded SHA-256 hash of the file at path.
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
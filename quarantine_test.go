@@ -0,0 +1,176 @@
+package gorigumi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// eicar flags the files of the quarantine tests holding it, like the EICAR test file.
+var eicar = []byte("X5O!P%@AP[4\\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*")
+
+// eicarScanner is the Scanner of the quarantine tests.
+var eicarScanner = ScannerFunc(func(_ context.Context, path string) (ScanResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ScanResult{}, err
+	}
+	return ScanResult{Infected: bytes.Contains(data, eicar), Verdict: "Eicar-Test-Signature", Scanner: "test 1.0"}, nil
+})
+
+// auditLog collects the audit events of the tests.
+type auditLog struct {
+	mu     sync.Mutex
+	events []AuditEvent
+}
+
+// Audit implements Auditor.
+func (l *auditLog) Audit(_ context.Context, event AuditEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+}
+
+// newQuarantineTools returns Tools scanning uploads with eicarScanner and
+// recording audit events in log.
+func newQuarantineTools(log *auditLog) *Tools {
+	testTools := New()
+	testTools.AllowedFileTypes = []string{"*"}
+	testTools.TokenSecret = []byte("secret")
+	testTools.Scanner = ScannerFunc(func(ctx context.Context, path string) (ScanResult, error) {
+		// files are scanned before they are moved to their name
+		if !strings.HasPrefix(filepath.Base(path), ".upload-") {
+			return ScanResult{}, errors.New("scanned the stored file " + path)
+		}
+		return eicarScanner(ctx, path)
+	})
+	testTools.Auditor = log
+	return testTools
+}
+
+// TestTools_Scanner tests that flagged uploads are quarantined with a signed
+// report, and clean ones stored.
+func TestTools_Scanner(t *testing.T) {
+	log := &auditLog{}
+	testTools := newQuarantineTools(log)
+	dir := t.TempDir()
+
+	files, err := testTools.UploadFiles(newMultipartRequest(t, map[string][]byte{"clean.txt": []byte("hello")}), dir, false)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected the clean file to be stored, got %v", err)
+	}
+
+	_, err = testTools.UploadFiles(newMultipartRequest(t, map[string][]byte{"virus.txt": eicar}), dir, false)
+	if !errors.Is(err, ErrFileQuarantined) || ErrorCode(err) != CodeFileQuarantined {
+		t.Fatalf("expected ErrFileQuarantined, got %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 || entries[0].Name() != "clean.txt" {
+		t.Errorf("expected the flagged file and the quarantine to be outside the upload directory, got %v", entries)
+	}
+
+	reports, err := testTools.ListQuarantined(dir)
+	if err != nil || len(reports) != 1 {
+		t.Fatalf("expected one report, got %v, %v", reports, err)
+	}
+	report := reports[0]
+	if report.FileName != "virus.txt" || report.Verdict != "Eicar-Test-Signature" || report.Scanner != "test 1.0" ||
+		report.FileSize != int64(len(eicar)) || len(report.SHA256) != 64 || report.QuarantinedAt.IsZero() {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if len(log.events) != 1 || log.events[0].Action != AuditUploadQuarantined || log.events[0].Resource != report.ID ||
+		log.events[0].Attrs["sha256"] != report.SHA256 {
+		t.Errorf("expected a quarantine audit event, got %+v", log.events)
+	}
+}
+
+// TestTools_ReleaseFromQuarantine tests that reviewed files are released or
+// deleted, and that tampered reports are refused.
+func TestTools_ReleaseFromQuarantine(t *testing.T) {
+	log := &auditLog{}
+	testTools := newQuarantineTools(log)
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		_, _ = testTools.UploadFiles(newMultipartRequest(t, map[string][]byte{name: eicar}), dir, false)
+	}
+	reports, err := testTools.ListQuarantined(dir)
+	if err != nil || len(reports) != 3 {
+		t.Fatalf("expected three reports, got %v, %v", reports, err)
+	}
+	ids := make(map[string]string, len(reports))
+	for _, r := range reports {
+		ids[r.FileName] = r.ID
+	}
+
+	if _, err := testTools.ReleaseFromQuarantine(ctx, dir, ids["a.txt"], ""); err == nil {
+		t.Error("expected the administrator to be required")
+	}
+	if _, err := testTools.ReleaseFromQuarantine(ctx, dir, "../../etc/passwd", "ana"); !errors.Is(err, ErrQuarantineNotFound) {
+		t.Errorf("expected ErrQuarantineNotFound, got %v", err)
+	}
+
+	file, err := testTools.ReleaseFromQuarantine(ctx, dir, ids["a.txt"], "ana")
+	if err != nil || file.NewFileName != "a.txt" {
+		t.Fatalf("expected a.txt to be released, got %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "a.txt")); !bytes.Equal(data, eicar) {
+		t.Error("expected the released file to be back in the upload directory")
+	}
+
+	if err := testTools.DeleteQuarantined(ctx, dir, ids["b.txt"], "ana"); err != nil {
+		t.Fatal(err)
+	}
+
+	reportPath := filepath.Join(testTools.quarantineDir(dir), ids["c.txt"]+quarantineReportExt)
+	data, _ := os.ReadFile(reportPath)
+	if err := os.WriteFile(reportPath, bytes.Replace(data, []byte("Eicar-Test-Signature"), []byte("clean"), 1), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testTools.ReleaseFromQuarantine(ctx, dir, ids["c.txt"], "ana"); !errors.Is(err, ErrInvalidQuarantineReport) {
+		t.Errorf("expected ErrInvalidQuarantineReport, got %v", err)
+	}
+	reports, err = testTools.ListQuarantined(dir)
+	if !errors.Is(err, ErrInvalidQuarantineReport) || len(reports) != 0 {
+		t.Errorf("expected the tampered report to be reported, got %v, %v", reports, err)
+	}
+
+	var actions []string
+	for _, e := range log.events {
+		if e.Actor == "ana" {
+			actions = append(actions, e.Action)
+		}
+	}
+	if strings.Join(actions, ",") != AuditUploadReleased+","+AuditUploadDeleted {
+		t.Errorf("unexpected audit events %v", actions)
+	}
+}
+
+// TestTools_Scanner_failure tests that files are removed when they cannot be
+// scanned or quarantined.
+func TestTools_Scanner_failure(t *testing.T) {
+	testTools := newQuarantineTools(&auditLog{})
+	testTools.Scanner = ScannerFunc(func(context.Context, string) (ScanResult, error) {
+		return ScanResult{}, errors.New("scanner unavailable")
+	})
+	dir := t.TempDir()
+
+	if _, err := testTools.UploadFiles(newMultipartRequest(t, map[string][]byte{"a.txt": []byte("a")}), dir, false); err == nil {
+		t.Error("expected the scan error to be returned")
+	}
+
+	testTools = newQuarantineTools(&auditLog{})
+	testTools.TokenSecret = nil
+	if _, err := testTools.UploadFiles(newMultipartRequest(t, map[string][]byte{"b.txt": eicar}), dir, false); err == nil || errors.Is(err, ErrFileQuarantined) {
+		t.Errorf("expected the quarantine to fail, got %v", err)
+	}
+
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected the files to be removed, got %v", entries)
+	}
+}
@@ -0,0 +1,331 @@
+package gorigumi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	// defaultQueryMaxDepth is the default maximum depth of a selected field path
+	// it is inlcuded in the QueryHandler method
+	defaultQueryMaxDepth int = 3
+
+	// defaultQueryMaxComplexity is the default maximum complexity of a query
+	// it is inlcuded in the QueryHandler method
+	defaultQueryMaxComplexity int = 100
+)
+
+// QueryLoader loads the data exposed under a single name of a query endpoint.
+// The returned value must be JSON-serializable. Lists are filtered and limited
+// according to the query, and every item is reduced to the selected fields.
+type QueryLoader func(ctx context.Context) (any, error)
+
+// QueryOptions holds the limits of a query endpoint. Zero values fall back
+// to a maximum depth of 3 and a maximum complexity of 100.
+type QueryOptions struct {
+	// MaxDepth is the maximum number of segments in a selected field path
+	MaxDepth int
+	// MaxComplexity is the maximum number of loaders and fields in a query
+	MaxComplexity int
+}
+
+// QuerySpec describes what a client wants from a single loader.
+//
+// Fields is the list of selected fields, nested fields are separated with dots
+// ("author.name"). Filter holds equality filters by default, other operators are
+// selected with a suffix: "age:gt", "age:gte", "age:lt", "age:lte", "name:ne",
+// "id:in" and "tags:contains". Limit caps the number of returned list items.
+type QuerySpec struct {
+	Fields []string       `json:"fields"`
+	Filter map[string]any `json:"filter,omitempty"`
+	Limit  int            `json:"limit,omitempty"`
+}
+
+// QueryHandler returns an http.Handler exposing the given loaders behind a single
+// POST endpoint with a restricted query language. The request body maps loader
+// names to a QuerySpec:
+//
+//	{"users": {"fields": ["id", "name", "team.name"], "filter": {"age:gte": 18}, "limit": 10}}
+//
+// The response is a JSONResponse whose Data field maps every requested name to
+// its result. Queries exceeding the depth or complexity limits of the optional
+// QueryOptions, referencing unknown loaders or failing to load are answered
+// with JSONError.
+func (t *Tools) QueryHandler(loaders map[string]QueryLoader, opts ...QueryOptions) http.Handler {
+	options := QueryOptions{MaxDepth: defaultQueryMaxDepth, MaxComplexity: defaultQueryMaxComplexity}
+	if len(opts) > 0 {
+		if opts[0].MaxDepth != 0 {
+			options.MaxDepth = opts[0].MaxDepth
+		}
+		if opts[0].MaxComplexity != 0 {
+			options.MaxComplexity = opts[0].MaxComplexity
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			_ = t.JSONError(w, errors.New("method not allowed"), http.StatusMethodNotAllowed)
+			return
+		}
+
+		var query map[string]QuerySpec
+		if err := t.JSONRead(w, r, &query); err != nil {
+			_ = t.JSONError(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if err := checkQuery(query, loaders, options); err != nil {
+			_ = t.JSONError(w, err, http.StatusBadRequest)
+			return
+		}
+
+		data := make(map[string]any, len(query))
+		for name, spec := range query {
+			result, err := runQuery(r.Context(), loaders[name], spec)
+			if err != nil {
+				_ = t.JSONError(w, fmt.Errorf("%s: %w", name, err))
+				return
+			}
+			data[name] = result
+		}
+
+		_ = t.JSONWrite(w, http.StatusOK, JSONResponse{Data: data})
+	})
+}
+
+// checkQuery validates a query against the registered loaders and the limits.
+func checkQuery(query map[string]QuerySpec, loaders map[string]QueryLoader, options QueryOptions) error {
+	if len(query) == 0 {
+		return errors.New("query must not be empty")
+	}
+
+	complexity := 0
+	for name, spec := range query {
+		if _, ok := loaders[name]; !ok {
+			return fmt.Errorf("unknown query %q", name)
+		}
+		if len(spec.Fields) == 0 {
+			return fmt.Errorf("%s: at least one field must be selected", name)
+		}
+		for _, field := range spec.Fields {
+			if depth := len(strings.Split(field, ".")); depth > options.MaxDepth {
+				return fmt.Errorf("%s: field %q exceeds the maximum depth of %d", name, field, options.MaxDepth)
+			}
+		}
+		complexity += 1 + len(spec.Fields) + len(spec.Filter)
+	}
+
+	if complexity > options.MaxComplexity {
+		return fmt.Errorf("query complexity %d exceeds the maximum of %d", complexity, options.MaxComplexity)
+	}
+
+	return nil
+}
+
+// runQuery loads the data of a single loader and applies the filters,
+// the limit and the field selection of spec to it.
+func runQuery(ctx context.Context, loader QueryLoader, spec QuerySpec) (any, error) {
+	raw, err := loader(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// normalize the loaded value into maps, slices and scalars
+	buf, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var value any
+	if err := json.Unmarshal(buf, &value); err != nil {
+		return nil, err
+	}
+
+	if items, ok := value.([]any); ok {
+		filtered := make([]any, 0, len(items))
+		for _, item := range items {
+			match, err := matchQueryFilter(item, spec.Filter)
+			if err != nil {
+				return nil, err
+			}
+			if match {
+				filtered = append(filtered, item)
+			}
+		}
+		if spec.Limit > 0 && len(filtered) > spec.Limit {
+			filtered = filtered[:spec.Limit]
+		}
+		value = filtered
+	}
+
+	paths := make([][]string, 0, len(spec.Fields))
+	for _, field := range spec.Fields {
+		paths = append(paths, strings.Split(field, "."))
+	}
+
+	return selectQueryFields(value, paths), nil
+}
+
+// selectQueryFields reduces value to the given field paths. Lists are
+// reduced item by item, scalars are returned unchanged.
+func selectQueryFields(value any, paths [][]string) any {
+	switch v := value.(type) {
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = selectQueryFields(item, paths)
+		}
+		return out
+
+	case map[string]any:
+		nested := make(map[string][][]string)
+		out := make(map[string]any)
+		for _, path := range paths {
+			field, ok := v[path[0]]
+			if !ok {
+				continue
+			}
+			if len(path) == 1 {
+				out[path[0]] = field
+				continue
+			}
+			nested[path[0]] = append(nested[path[0]], path[1:])
+		}
+		for key, subPaths := range nested {
+			if _, whole := out[key]; whole {
+				continue
+			}
+			out[key] = selectQueryFields(v[key], subPaths)
+		}
+		return out
+
+	default:
+		return value
+	}
+}
+
+// matchQueryFilter reports whether item satisfies every condition of filter.
+func matchQueryFilter(item any, filter map[string]any) (bool, error) {
+	if len(filter) == 0 {
+		return true, nil
+	}
+
+	obj, ok := item.(map[string]any)
+	if !ok {
+		return false, nil
+	}
+
+	keys := make([]string, 0, len(filter))
+	for key := range filter {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		field, op, _ := strings.Cut(key, ":")
+		if op == "" {
+			op = "eq"
+		}
+
+		match, err := compareQueryValue(obj[field], op, filter[key])
+		if err != nil {
+			return false, fmt.Errorf("filter %q: %w", key, err)
+		}
+		if !match {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// compareQueryValue applies the filter operator op to a field value and
+// the value given in the query.
+func compareQueryValue(field any, op string, want any) (bool, error) {
+	switch op {
+	case "eq":
+		return reflect.DeepEqual(field, want), nil
+
+	case "ne":
+		return !reflect.DeepEqual(field, want), nil
+
+	case "gt", "gte", "lt", "lte":
+		c, ok := orderQueryValues(field, want)
+		if !ok {
+			return false, nil
+		}
+		switch op {
+		case "gt":
+			return c > 0, nil
+		case "gte":
+			return c >= 0, nil
+		case "lt":
+			return c < 0, nil
+		default:
+			return c <= 0, nil
+		}
+
+	case "in":
+		list, ok := want.([]any)
+		if !ok {
+			return false, errors.New("in operator expects a list")
+		}
+		for _, candidate := range list {
+			if reflect.DeepEqual(field, candidate) {
+				return true, nil
+			}
+		}
+		return false, nil
+
+	case "contains":
+		switch f := field.(type) {
+		case string:
+			s, ok := want.(string)
+			return ok && strings.Contains(f, s), nil
+		case []any:
+			for _, candidate := range f {
+				if reflect.DeepEqual(candidate, want) {
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+
+	default:
+		return false, fmt.Errorf("unknown operator %q", op)
+	}
+}
+
+// orderQueryValues compares two numbers or two strings. The boolean result
+// is false if the values are not comparable.
+func orderQueryValues(a, b any) (int, bool) {
+	switch x := a.(type) {
+	case float64:
+		y, ok := b.(float64)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+
+	case string:
+		y, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(x, y), true
+	}
+
+	return 0, false
+}
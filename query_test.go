@@ -0,0 +1,93 @@
+package gorigumi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// queryTests is a slice of structs that hold the name of the test, the query body,
+// the expected status code and the expected data of a successful query
+var queryTests = []struct {
+	name           string
+	query          string
+	expectedStatus int
+	expectedData   string
+}{
+	{"select fields", `{"users": {"fields": ["name"]}}`, http.StatusOK, `{"users": [{"name": "alice"}, {"name": "bob"}, {"name": "carol"}]}`},
+	{"nested fields", `{"users": {"fields": ["name", "team.name"], "limit": 1}}`, http.StatusOK, `{"users": [{"name": "alice", "team": {"name": "core"}}]}`},
+	{"equality filter", `{"users": {"fields": ["name"], "filter": {"active": true}}}`, http.StatusOK, `{"users": [{"name": "alice"}, {"name": "carol"}]}`},
+	{"operator filter", `{"users": {"fields": ["name"], "filter": {"age:gte": 30, "name:ne": "carol"}}}`, http.StatusOK, `{"users": [{"name": "bob"}]}`},
+	{"in filter", `{"users": {"fields": ["age"], "filter": {"name:in": ["alice", "bob"]}}}`, http.StatusOK, `{"users": [{"age": 25}, {"age": 40}]}`},
+	{"object loader", `{"stats": {"fields": ["total"]}}`, http.StatusOK, `{"stats": {"total": 3}}`},
+	{"unknown loader", `{"posts": {"fields": ["title"]}}`, http.StatusBadRequest, ""},
+	{"no fields", `{"users": {}}`, http.StatusBadRequest, ""},
+	{"too deep", `{"users": {"fields": ["team.lead.name"]}}`, http.StatusBadRequest, ""},
+	{"too complex", `{"users": {"fields": ["a", "b", "c", "d", "e", "f"]}}`, http.StatusBadRequest, ""},
+	{"unknown operator", `{"users": {"fields": ["name"], "filter": {"age:like": 1}}}`, http.StatusInternalServerError, ""},
+}
+
+// TestTools_QueryHandler tests the QueryHandler method by running field selections,
+// filters and limits against static loaders, and checking that the depth and
+// complexity limits reject expensive queries.
+func TestTools_QueryHandler(t *testing.T) {
+	testTools := New()
+
+	type team struct {
+		Name string `json:"name"`
+		Lead string `json:"lead"`
+	}
+	type user struct {
+		Name   string `json:"name"`
+		Age    int    `json:"age"`
+		Active bool   `json:"active"`
+		Team   team   `json:"team"`
+	}
+
+	loaders := map[string]QueryLoader{
+		"users": func(ctx context.Context) (any, error) {
+			return []user{
+				{"alice", 25, true, team{"core", "dave"}},
+				{"bob", 40, false, team{"web", "erin"}},
+				{"carol", 35, true, team{"core", "dave"}},
+			}, nil
+		},
+		"stats": func(ctx context.Context) (any, error) {
+			return map[string]int{"total": 3, "active": 2}, nil
+		},
+	}
+
+	handler := testTools.QueryHandler(loaders, QueryOptions{MaxDepth: 2, MaxComplexity: 6})
+
+	for _, qt := range queryTests {
+		req := httptest.NewRequest("POST", "/query", strings.NewReader(qt.query))
+		responseRecorder := httptest.NewRecorder()
+
+		handler.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != qt.expectedStatus {
+			t.Errorf("%s: expected status code %d, got %d", qt.name, qt.expectedStatus, responseRecorder.Code)
+			continue
+		}
+
+		if qt.expectedData == "" {
+			continue
+		}
+
+		var res JSONResponse
+		if err := json.NewDecoder(responseRecorder.Body).Decode(&res); err != nil {
+			t.Errorf("%s: failed to decode JSON: %v", qt.name, err)
+			continue
+		}
+
+		var expected any
+		_ = json.Unmarshal([]byte(qt.expectedData), &expected)
+		if !reflect.DeepEqual(res.Data, expected) {
+			t.Errorf("%s: expected %v, got %v", qt.name, expected, res.Data)
+		}
+	}
+}
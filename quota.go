@@ -0,0 +1,135 @@
+package gorigumi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime/multipart"
+	"path/filepath"
+)
+
+// ErrQuotaExceeded is matched by the *QuotaError returned when an upload would
+// exceed the quota of its directory.
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// DirUsage is the number of regular files in a directory and its subdirectories,
+// and their total size in bytes.
+type DirUsage struct {
+	Bytes int64 `json:"bytes"`
+	Files int   `json:"files"`
+}
+
+// GetDirUsage walks dir and returns its usage. A missing directory is empty.
+func GetDirUsage(dir string) (DirUsage, error) {
+	return GetDirUsageCtx(context.Background(), dir)
+}
+
+// GetDirUsageCtx is like GetDirUsage, but stops walking dir once ctx is done
+// and returns ctx.Err() in that case.
+func GetDirUsageCtx(ctx context.Context, dir string) (DirUsage, error) {
+	var usage DirUsage
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		usage.Bytes += info.Size()
+		usage.Files++
+		return nil
+	})
+	return usage, err
+}
+
+// QuotaError reports an upload refused by a DirQuota, with the usage of the
+// directory before the upload and the quota.
+type QuotaError struct {
+	Dir      string
+	Usage    DirUsage
+	MaxBytes int64
+	MaxFiles int
+}
+
+// Error returns the error message with the usage figures.
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%v: %s holds %d files and %d bytes, the quota is %d files and %d bytes",
+		ErrQuotaExceeded, e.Dir, e.Usage.Files, e.Usage.Bytes, e.MaxFiles, e.MaxBytes)
+}
+
+// ErrorCode returns CodeQuotaExceeded.
+func (e *QuotaError) ErrorCode() string {
+	return CodeQuotaExceeded
+}
+
+// Is reports whether target is ErrQuotaExceeded.
+func (e *QuotaError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// DirQuota is a byte and file count quota of a directory. Zero values mean
+// no limit.
+type DirQuota struct {
+	MaxBytes int64
+	MaxFiles int
+}
+
+// Check returns the usage of dir, and a *QuotaError if adding files files of
+// bytes bytes to it would exceed the quota. The usage is read from disk on
+// every call, so concurrent uploads may overshoot the quota by their size.
+func (q DirQuota) Check(dir string, bytes int64, files int) (DirUsage, error) {
+	return q.CheckCtx(context.Background(), dir, bytes, files)
+}
+
+// CheckCtx is like Check, but stops reading the usage of dir once ctx is done
+// and returns ctx.Err() in that case.
+func (q DirQuota) CheckCtx(ctx context.Context, dir string, bytes int64, files int) (DirUsage, error) {
+	usage, err := GetDirUsageCtx(ctx, dir)
+	if err != nil {
+		return usage, err
+	}
+
+	if (q.MaxBytes > 0 && usage.Bytes+bytes > q.MaxBytes) || (q.MaxFiles > 0 && usage.Files+files > q.MaxFiles) {
+		return usage, &QuotaError{Dir: dir, Usage: usage, MaxBytes: q.MaxBytes, MaxFiles: q.MaxFiles}
+	}
+	return usage, nil
+}
+
+// dirQuota returns the quota of upload directories set in the Tools struct,
+// and whether there is one.
+func (t *Tools) dirQuota() (DirQuota, bool) {
+	q := DirQuota{MaxBytes: t.MaxDirSize, MaxFiles: t.MaxDirFiles}
+	return q, q.MaxBytes > 0 || q.MaxFiles > 0
+}
+
+// checkDirQuota checks that storing files in uploadDir keeps it within the
+// MaxDirSize and MaxDirFiles quota.
+func (t *Tools) checkDirQuota(ctx context.Context, uploadDir string, files map[string][]*multipart.FileHeader) error {
+	q, ok := t.dirQuota()
+	if !ok {
+		return nil
+	}
+
+	var size int64
+	count := 0
+	for _, fHeaders := range files {
+		for _, hdr := range fHeaders {
+			size += hdr.Size
+			count++
+		}
+	}
+
+	_, err := q.CheckCtx(ctx, uploadDir, size, count)
+	return err
+}
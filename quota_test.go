@@ -0,0 +1,71 @@
+package gorigumi
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// quotaTests is a slice of structs that hold the name of the test, the directory quota,
+// a boolean that indicates if uploads are streamed, and a boolean that indicates if an
+// ErrQuotaExceeded error is expected
+var quotaTests = []struct {
+	name        string
+	maxBytes    int64
+	maxFiles    int
+	stream      bool
+	errExpected bool
+}{
+	{"within quota", 1000, 3, false, false},
+	{"within quota streamed", 1000, 3, true, false},
+	{"too many bytes", 150, 0, false, true},
+	{"too many bytes streamed", 150, 0, true, true},
+	{"too many files", 0, 2, false, true},
+	{"too many files streamed", 0, 2, true, true},
+}
+
+// TestTools_DirQuota tests that uploads exceeding the quota of their directory are
+// refused with the usage of the directory.
+func TestTools_DirQuota(t *testing.T) {
+	for _, qt := range quotaTests {
+		uploadDir := t.TempDir()
+		os.WriteFile(filepath.Join(uploadDir, "existing.txt"), []byte(strings.Repeat("x", 100)), 0644)
+		os.MkdirAll(filepath.Join(uploadDir, "sub"), 0755)
+		os.WriteFile(filepath.Join(uploadDir, "sub", "nested.txt"), []byte("x"), 0644)
+
+		testTools := New()
+		testTools.AllowedFileTypes = []string{"*"}
+		testTools.StreamUploads = qt.stream
+		testTools.MaxDirSize = qt.maxBytes
+		testTools.MaxDirFiles = qt.maxFiles
+
+		req := newMultipartRequest(t, map[string][]byte{"a.txt": []byte(strings.Repeat("some text ", 10))})
+		_, err := testTools.UploadFiles(req, uploadDir)
+
+		if qt.errExpected != errors.Is(err, ErrQuotaExceeded) {
+			t.Errorf("%s: expected ErrQuotaExceeded %t, got %v", qt.name, qt.errExpected, err)
+		}
+
+		var quotaErr *QuotaError
+		if qt.errExpected && errors.As(err, &quotaErr) {
+			if quotaErr.Usage != (DirUsage{Bytes: 101, Files: 2}) {
+				t.Errorf("%s: unexpected usage %+v", qt.name, quotaErr.Usage)
+			}
+		}
+
+		usage, _ := GetDirUsage(uploadDir)
+		if qt.errExpected && usage.Files != 2 {
+			t.Errorf("%s: expected nothing to be stored, got %d files", qt.name, usage.Files)
+		}
+	}
+}
+
+// TestGetDirUsage tests that a missing directory is empty.
+func TestGetDirUsage(t *testing.T) {
+	usage, err := GetDirUsage(filepath.Join(t.TempDir(), "missing"))
+	if err != nil || usage != (DirUsage{}) {
+		t.Errorf("expected an empty usage, got %+v, %v", usage, err)
+	}
+}
@@ -0,0 +1,24 @@
+// Package random generates random strings from a cryptographically secure
+// source. It is the implementation of gorigumi's GenerateRandomString and can
+// be imported on its own.
+package random
+
+import (
+	"crypto/rand"
+)
+
+// Source holds the characters random strings are made of: uppercase and
+// lowercase letters, digits, and an underscore.
+const Source string = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_"
+
+// String generates a random string of length n made of characters of Source.
+func String(n int) string {
+	s, r := make([]rune, n), []rune(Source)
+	for i := range s {
+		p, _ := rand.Prime(rand.Reader, len(r))
+		x, y := p.Uint64(), uint64(len(r))
+		s[i] = r[x%y]
+	}
+
+	return string(s)
+}
@@ -0,0 +1,23 @@
+package random
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestString tests the length and the characters of generated strings, and that two
+// strings differ.
+func TestString(t *testing.T) {
+	s := String(32)
+	if len(s) != 32 {
+		t.Errorf("expected length 32, got %d", len(s))
+	}
+	for _, c := range s {
+		if !strings.ContainsRune(Source, c) {
+			t.Errorf("unexpected character %q", c)
+		}
+	}
+	if s == String(32) {
+		t.Error("expected two random strings to differ")
+	}
+}
@@ -0,0 +1,186 @@
+package gorigumi
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultCostBudget is the default number of tokens of a principal per period
+	// it is inlcuded in the CostLimitMiddleware method
+	defaultCostBudget int = 60
+
+	// defaultUploadCost is the default cost of multipart requests matching no route
+	// it is inlcuded in the CostLimitMiddleware method
+	defaultUploadCost int = 10
+)
+
+// ErrRateLimited is reported to requests exceeding the budget of their principal.
+var ErrRateLimited = WithErrorCode(errors.New("rate limit exceeded, please retry later"), CodeRateLimited)
+
+// CostLimitOptions configures CostLimitMiddleware.
+type CostLimitOptions struct {
+	// Budget is the number of tokens a principal may spend per Period.
+	// Default to 60
+	Budget int
+	// Period is the time over which an empty budget is refilled. Default to
+	// 1 minute
+	Period time.Duration
+	// Costs holds the cost of the routes, by http.ServeMux pattern, e.g.
+	// {"POST /upload": 10, "GET /files/{name}": 2}. The most specific
+	// pattern matching a request applies, as with http.ServeMux
+	Costs map[string]int
+	// DefaultCost is the cost of the requests matching no pattern of Costs.
+	// Default to 1
+	DefaultCost int
+	// UploadCost is the cost of the multipart requests matching no pattern
+	// of Costs. Default to 10
+	UploadCost int
+	// Principal returns the principal a request is charged to, e.g. a user ID
+	// or an API key. Default to the IP address of the client
+	Principal func(r *http.Request) string
+	// Budgets holds the budgets of principals overriding Budget, e.g. for
+	// internal services or paid plans. Principals with a budget of 0 or less
+	// are always limited
+	Budgets map[string]int
+	// Clock is the source of time of the budgets. Default to the Clock of
+	// the Tools struct
+	Clock Clock
+}
+
+// costBucket is the token bucket of a principal.
+type costBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// CostLimitMiddleware returns a middleware charging every request a cost in tokens
+// to the budget of its principal, so heavy endpoints can't be abused within a
+// nominal request rate: with a budget of 60 tokens per minute, a client may read
+// JSON 60 times or upload 6 files. Budgets are token buckets refilled
+// continuously, and requests exceeding them are answered with 429 Too Many
+// Requests, the CodeRateLimited code and a Retry-After header. The RateLimit-Limit
+// and RateLimit-Remaining headers of every response advertise the budget. Requests
+// costing 0 are never limited, and costs above the budget of a principal are
+// charged the whole budget, so such routes stay reachable. The budgets are kept in memory, per process.
+func (t *Tools) CostLimitMiddleware(next http.Handler, opts ...CostLimitOptions) http.Handler {
+	var options CostLimitOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	if options.Budget == 0 {
+		options.Budget = defaultCostBudget
+	}
+	if options.Period == 0 {
+		options.Period = time.Minute
+	}
+	if options.DefaultCost == 0 {
+		options.DefaultCost = 1
+	}
+	if options.UploadCost == 0 {
+		options.UploadCost = defaultUploadCost
+	}
+	if options.Principal == nil {
+		options.Principal = clientAddr
+	}
+	clock := options.Clock
+	if clock == nil {
+		clock = t.clock()
+	}
+
+	// the patterns are matched by a mux whose handlers are never called
+	routes := http.NewServeMux()
+	for pattern := range options.Costs {
+		routes.Handle(pattern, http.NotFoundHandler())
+	}
+	cost := func(r *http.Request) int {
+		if _, pattern := routes.Handler(r); pattern != "" {
+			return options.Costs[pattern]
+		}
+		if classifyRequest(r) == UploadRequestClass {
+			return options.UploadCost
+		}
+		return options.DefaultCost
+	}
+
+	var mu sync.Mutex
+	buckets := make(map[string]*costBucket)
+	lastSweep := clock.Now()
+
+	// spend charges cost to principal, and returns its budget, the remaining
+	// tokens and, if the budget is exceeded, the time until it allows the request
+	spend := func(principal string, cost int) (int, int, time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		now := clock.Now()
+		budget, ok := options.Budgets[principal]
+		if !ok {
+			budget = options.Budget
+		}
+		if budget <= 0 {
+			return max(budget, 0), 0, options.Period
+		}
+		cost = min(cost, budget)
+		rate := float64(budget) / options.Period.Seconds()
+
+		// buckets refilled since the last sweep are forgotten, as new ones
+		if now.Sub(lastSweep) >= options.Period {
+			for p, b := range buckets {
+				if now.Sub(b.last) >= options.Period {
+					delete(buckets, p)
+				}
+			}
+			lastSweep = now
+		}
+
+		b, ok := buckets[principal]
+		if !ok {
+			b = &costBucket{tokens: float64(budget), last: now}
+			buckets[principal] = b
+		}
+		b.tokens = min(float64(budget), b.tokens+now.Sub(b.last).Seconds()*rate)
+		b.last = now
+
+		if b.tokens < float64(cost) {
+			wait := time.Duration((float64(cost) - b.tokens) / rate * float64(time.Second))
+			return budget, int(b.tokens), wait
+		}
+		b.tokens -= float64(cost)
+		return budget, int(b.tokens), 0
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := cost(r)
+		if c <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		budget, remaining, wait := spend(options.Principal(r), c)
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(budget))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(remaining))
+		if wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			_ = t.JSONError(w, ErrRateLimited, http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientAddr returns the IP address of the client of r, the default principal
+// of CostLimitMiddleware.
+func clientAddr(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
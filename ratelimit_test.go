@@ -0,0 +1,129 @@
+package gorigumi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/drunkleen/gorigumi/toolkittest"
+)
+
+// costLimitSteps is a slice of structs that hold the name of the step, the time
+// elapsed before it, the request and the expected status code and remaining tokens
+var costLimitSteps = []struct {
+	name      string
+	elapsed   time.Duration
+	method    string
+	path      string
+	upload    bool
+	status    int
+	remaining string
+}{
+	{"read", 0, "GET", "/items", false, http.StatusOK, "29"},
+	{"upload route", 0, "POST", "/upload", true, http.StatusOK, "19"},
+	{"route wildcard", 0, "GET", "/files/a.png", false, http.StatusOK, "17"},
+	{"free route", 0, "GET", "/health", false, http.StatusOK, ""},
+	{"other upload", 0, "POST", "/avatar", true, http.StatusOK, "7"},
+	{"budget exceeded", 0, "POST", "/upload", true, http.StatusTooManyRequests, "7"},
+	{"cheap request within budget", 0, "GET", "/items", false, http.StatusOK, "6"},
+	{"refilled", 30 * time.Second, "POST", "/upload", true, http.StatusOK, "11"},
+}
+
+// TestTools_CostLimitMiddleware tests that requests are charged the cost of their
+// route to the budget of their principal, which refills over time.
+func TestTools_CostLimitMiddleware(t *testing.T) {
+	clock := toolkittest.NewClock(time.Now())
+	handler := New().CostLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), CostLimitOptions{
+		Budget: 30,
+		Costs:  map[string]int{"POST /upload": 10, "GET /files/{name}": 2, "/health": 0},
+		Clock:  clock,
+	})
+
+	for _, tt := range costLimitSteps {
+		clock.Advance(tt.elapsed)
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.upload {
+			req.Header.Set("Content-Type", "multipart/form-data; boundary=foo")
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.status, rr.Code)
+		}
+		if got := rr.Header().Get("RateLimit-Remaining"); got != tt.remaining {
+			t.Errorf("%s: expected %s remaining tokens, got %s", tt.name, tt.remaining, got)
+		}
+		if tt.status == http.StatusTooManyRequests && (rr.Header().Get("Retry-After") != "6" || ErrorCode(ErrRateLimited) != CodeRateLimited) {
+			t.Errorf("%s: expected a Retry-After of 6s, got %q", tt.name, rr.Header().Get("Retry-After"))
+		}
+	}
+}
+
+// TestTools_CostLimitMiddleware_principals tests that principals have their own
+// budgets.
+func TestTools_CostLimitMiddleware_principals(t *testing.T) {
+	handler := New().CostLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), CostLimitOptions{
+		Budget:    1,
+		Principal: func(r *http.Request) string { return r.Header.Get("X-Api-Key") },
+		Budgets:   map[string]int{"premium": 3},
+	})
+
+	for key, served := range map[string]int{"basic": 1, "premium": 3, "other": 1} {
+		n := 0
+		for range 5 {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("X-Api-Key", key)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code == http.StatusOK {
+				n++
+			}
+		}
+		if n != served {
+			t.Errorf("%s: expected %d requests to be served, got %d", key, served, n)
+		}
+	}
+}
+
+// TestTools_CostLimitMiddleware_bounds tests that principals without budget are
+// always limited, and that requests costing more than a budget are charged the
+// whole budget.
+func TestTools_CostLimitMiddleware_bounds(t *testing.T) {
+	clock := toolkittest.NewClock(time.Now())
+	handler := New().CostLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), CostLimitOptions{
+		Budget:    5,
+		Costs:     map[string]int{"/export": 20},
+		Principal: func(r *http.Request) string { return r.Header.Get("X-Api-Key") },
+		Budgets:   map[string]int{"banned": 0, "negative": -1},
+		Clock:     clock,
+	})
+
+	serve := func(key, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("X-Api-Key", key)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for _, key := range []string{"banned", "negative"} {
+		for range 3 {
+			if rr := serve(key, "/"); rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "60" {
+				t.Errorf("%s: expected status 429 with a Retry-After of 60s, got %d and %q", key, rr.Code, rr.Header().Get("Retry-After"))
+			}
+		}
+	}
+
+	if rr := serve("user", "/export"); rr.Code != http.StatusOK || rr.Header().Get("RateLimit-Remaining") != "0" {
+		t.Errorf("expensive route: expected status 200 with no remaining tokens, got %d and %s", rr.Code, rr.Header().Get("RateLimit-Remaining"))
+	}
+	if rr := serve("user", "/export"); rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "60" {
+		t.Errorf("expensive route: expected status 429 with a Retry-After of 60s, got %d and %q", rr.Code, rr.Header().Get("Retry-After"))
+	}
+	clock.Advance(time.Minute)
+	if rr := serve("user", "/export"); rr.Code != http.StatusOK {
+		t.Errorf("expensive route: expected status 200 once refilled, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,275 @@
+package gorigumi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// FilePart is a file sent by PushMultipartToRemote. Its content is read from
+// Reader if set, or from the file at Path.
+type FilePart struct {
+	// FieldName is the form field of the file. Default to "file"
+	FieldName string
+	// FileName is the file name sent to the remote. Default to the base
+	// name of Path
+	FileName string
+	// ContentType is the type of the file. Default to application/octet-stream
+	ContentType string
+	// Path is the path of the file to send
+	Path string
+	// Reader is the content of the file to send, e.g. an object of a storage
+	Reader io.Reader
+}
+
+// RemoteRequest configures the requests of JSONSendToRemote.
+type RemoteRequest struct {
+	// Method is the HTTP method of the request, e.g. PUT, PATCH or DELETE.
+	// Default to POST
+	Method string
+	// Header holds the headers added to the request
+	Header http.Header
+	// Query holds the query parameters added to those of the URL
+	Query url.Values
+	// BearerToken is sent in the Authorization header if set
+	BearerToken string
+	// Username and Password are sent with HTTP basic authentication if
+	// Username is set
+	Username string
+	Password string
+	// Client is used to make the request. Default to a new http.Client
+	Client *http.Client
+}
+
+// JSONSendToRemote sends data as JSON to rawURL like JSONPushToRemoteCtx, with the
+// method, headers, query parameters and credentials of req, e.g. to update a
+// resource of a third-party API with PUT and a bearer token. A nil data sends no
+// body. The body is sent as application/json unless req sets a Content-Type. It returns the response with its body closed and its status code.
+// RemoteTimeout and PushRetry apply like for JSONPushToRemote.
+func (t *Tools) JSONSendToRemote(ctx context.Context, rawURL string, data any, req RemoteRequest) (*http.Response, int, error) {
+	return t.sendToRemote(ctx, rawURL, data, req, nil)
+}
+
+// sendToRemote sends data as JSON to rawURL with the options of req, calling
+// readBody with the body of the responses if it is not nil.
+func (t *Tools) sendToRemote(ctx context.Context, rawURL string, data any, req RemoteRequest, readBody func(io.Reader) error) (*http.Response, int, error) {
+	if req.BearerToken != "" && req.Username != "" {
+		return nil, 0, errors.New("only one of BearerToken and Username may be set")
+	}
+	method := req.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	var body io.Reader
+	if data != nil {
+		jsonData, err := json.Marshal(data)
+		if err != nil {
+			return nil, 0, err
+		}
+		body = bytes.NewReader(jsonData)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, rawURL, body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	applyRemoteRequest(httpReq, req)
+	if body != nil && httpReq.Header.Get("Content-Type") == "" {
+		// a type set in the headers of req wins, e.g. application/merge-patch+json
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+
+	httpClient := req.Client
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	return t.push(httpClient, httpReq, readBody)
+}
+
+// applyRemoteRequest adds the query parameters, headers and credentials of req
+// to httpReq.
+func applyRemoteRequest(httpReq *http.Request, req RemoteRequest) {
+	if len(req.Query) > 0 {
+		query := httpReq.URL.Query()
+		for key, values := range req.Query {
+			for _, v := range values {
+				query.Add(key, v)
+			}
+		}
+		httpReq.URL.RawQuery = query.Encode()
+	}
+	for key, values := range req.Header {
+		for _, v := range values {
+			httpReq.Header.Add(key, v)
+		}
+	}
+	switch {
+	case req.BearerToken != "":
+		httpReq.Header.Set("Authorization", "Bearer "+req.BearerToken)
+	case req.Username != "":
+		httpReq.SetBasicAuth(req.Username, req.Password)
+	}
+}
+
+// PushMultipartToRemote posts a multipart/form-data request holding fields and
+// files to url, e.g. to forward uploads to a third-party processing service.
+// The body is streamed as it is written, so files are never buffered in memory.
+// Like JSONPushToRemote, it returns the response with its body closed and its
+// status code. If an http.Client is provided, it is used to make the request.
+// If ctx is in dry-run mode, the request is logged instead of being sent, and
+// a nil response with status 0 is returned. The RemoteTimeout of the Tools struct
+// only applies once the body was sent, to the wait for the response, so large
+// files are not cut off: ctx bounds the whole request.
+func (t *Tools) PushMultipartToRemote(ctx context.Context, url string, fields map[string]string, files []FilePart, client ...*http.Client) (*http.Response, int, error) {
+	if IsDryRun(ctx) {
+		names := make([]string, 0, len(files))
+		for _, f := range files {
+			names = append(names, f.FileName)
+		}
+		t.logger().InfoContext(ctx, "dry run: multipart push not sent", "url", url, "fields", len(fields), "files", names)
+		return nil, 0, nil
+	}
+
+	httpClient := &http.Client{}
+	if len(client) > 0 {
+		httpClient = client[0]
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, pr)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	var timedOut atomic.Bool
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		err := writeMultipart(writer, fields, files)
+		pw.CloseWithError(err)
+		if err != nil || t.remoteTimeout() < 0 {
+			return
+		}
+
+		// the timeout starts once the body is sent, however long it took
+		timer := time.NewTimer(t.remoteTimeout())
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			timedOut.Store(true)
+			cancel()
+		case <-done:
+		}
+	}()
+
+	res, err := t.doRemote(httpClient, req)
+	// stops the writer if the request ended before the whole body was sent
+	pr.CloseWithError(errors.New("request ended"))
+	if err != nil && timedOut.Load() {
+		err = fmt.Errorf("%w: no response within %s of sending the body", context.DeadlineExceeded, t.remoteTimeout())
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+
+	return res, res.StatusCode, nil
+}
+
+// writeMultipart writes fields and files with writer and closes it.
+func writeMultipart(writer *multipart.Writer, fields map[string]string, files []FilePart) error {
+	for name, value := range fields {
+		if err := writer.WriteField(name, value); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range files {
+		if err := writeFilePart(writer, f); err != nil {
+			return err
+		}
+	}
+
+	return writer.Close()
+}
+
+// writeFilePart writes the part of the file f with writer.
+func writeFilePart(writer *multipart.Writer, f FilePart) error {
+	src := f.Reader
+	if src == nil {
+		file, err := os.Open(f.Path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		src = file
+	}
+
+	if f.FieldName == "" {
+		f.FieldName = "file"
+	}
+	if f.FileName == "" {
+		f.FileName = filepath.Base(f.Path)
+	}
+	if f.ContentType == "" {
+		f.ContentType = "application/octet-stream"
+	}
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(f.FieldName), escapeQuotes(f.FileName)))
+	header.Set("Content-Type", f.ContentType)
+
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(part, src)
+	return err
+}
+
+// quoteEscaper escapes the characters of Content-Disposition parameters, as
+// mime/multipart does.
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// escapeQuotes escapes quotes and backslashes of s.
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
+
+// remoteContext returns ctx with the RemoteTimeout of the Tools struct, or its
+// default, and the function canceling it.
+func (t *Tools) remoteContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := t.remoteTimeout()
+	if timeout < 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// remoteTimeout returns the RemoteTimeout of the Tools struct, or its default.
+func (t *Tools) remoteTimeout() time.Duration {
+	if t.RemoteTimeout == 0 {
+		return defaultRemoteTimeout
+	}
+	return t.RemoteTimeout
+}
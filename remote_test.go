@@ -0,0 +1,176 @@
+package gorigumi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestTools_PushMultipartToRemote tests that fields and files from disk and readers
+// reach the remote as a multipart form.
+func TestTools_PushMultipartToRemote(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report.pdf")
+	if err := os.WriteFile(path, []byte("%PDF-1.4 content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	received := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength != -1 {
+			t.Errorf("expected a streamed body, got a Content-Length of %d", r.ContentLength)
+		}
+		mr, err := r.MultipartReader()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			data, _ := io.ReadAll(part)
+			received[part.FormName()+"|"+part.FileName()+"|"+part.Header.Get("Content-Type")] = string(data)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	testTools := New()
+	_, status, err := testTools.PushMultipartToRemote(context.Background(), srv.URL, map[string]string{"album": "42"}, []FilePart{
+		{Path: path, ContentType: "application/pdf"},
+		{FieldName: "notes", FileName: `my "notes".txt`, Reader: strings.NewReader("some text")},
+	})
+	if err != nil || status != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d, %v", status, err)
+	}
+
+	expected := map[string]string{
+		"album||":                         "42",
+		"file|report.pdf|application/pdf": "%PDF-1.4 content",
+		`notes|my "notes".txt|application/octet-stream`: "some text",
+	}
+	for key, value := range expected {
+		if received[key] != value {
+			t.Errorf("expected part %s to hold %q, got %q", key, value, received[key])
+		}
+	}
+
+	_, _, err = testTools.PushMultipartToRemote(context.Background(), srv.URL, nil, []FilePart{{Path: filepath.Join(dir, "missing")}})
+	if err == nil {
+		t.Error("expected a missing file to fail the push")
+	}
+}
+
+// slowFilePart is the content of a file sent slowly, in four chunks of a byte
+// every 40ms.
+type slowFilePart struct {
+	sent int
+}
+
+// Read returns the next byte of the file after a delay.
+func (r *slowFilePart) Read(p []byte) (int, error) {
+	if r.sent == 4 {
+		return 0, io.EOF
+	}
+	time.Sleep(40 * time.Millisecond)
+	r.sent++
+	p[0] = 'a'
+	return 1, nil
+}
+
+// TestTools_PushMultipartToRemote_timeout tests that RemoteTimeout does not cut
+// off files taking longer to send, but still applies to the wait for the
+// response.
+func TestTools_PushMultipartToRemote_timeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		if r.URL.Path == "/stuck" {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	testTools := New()
+	testTools.RemoteTimeout = 100 * time.Millisecond
+
+	_, status, err := testTools.PushMultipartToRemote(context.Background(), srv.URL, nil, []FilePart{{FileName: "a.txt", Reader: &slowFilePart{}}})
+	if err != nil || status != http.StatusCreated {
+		t.Errorf("expected a slow file to be sent, got %d (%v)", status, err)
+	}
+
+	_, _, err = testTools.PushMultipartToRemote(context.Background(), srv.URL+"/stuck", nil, []FilePart{{FileName: "a.txt", Reader: strings.NewReader("a")}})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the wait for the response to time out, got %v", err)
+	}
+}
+
+// jsonSendToRemoteTests is a slice of structs that hold the name of the test, the data, the
+// request options and the expected method, target, authorization, custom header and body
+var jsonSendToRemoteTests = []struct {
+	name          string
+	data          any
+	req           RemoteRequest
+	method        string
+	target        string
+	authorization string
+	header        string
+	body          string
+}{
+	{"default", map[string]int{"id": 1}, RemoteRequest{}, "POST", "/items?v=1", "", "", `{"id":1}`},
+	{"put with bearer", map[string]int{"id": 2}, RemoteRequest{Method: http.MethodPut, BearerToken: "tok"}, "PUT", "/items?v=1", "Bearer tok", "", `{"id":2}`},
+	{"patch with basic auth", map[string]string{"a": "b"}, RemoteRequest{Method: http.MethodPatch, Username: "ana", Password: "pw"}, "PATCH", "/items?v=1", "Basic YW5hOnB3", "", `{"a":"b"}`},
+	{"delete without body", nil, RemoteRequest{Method: http.MethodDelete, Query: url.Values{"force": {"true"}}}, "DELETE", "/items?force=true&v=1", "", "", ""},
+	{"headers", 1, RemoteRequest{Header: http.Header{"X-Tenant": {"acme"}}}, "POST", "/items?v=1", "", "acme", "1"},
+}
+
+// TestTools_JSONSendToRemote tests that the method, query, headers and credentials of
+// the request options reach the remote.
+func TestTools_JSONSendToRemote(t *testing.T) {
+	var method, target, authorization, header, contentType, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		method, target, body = r.Method, r.URL.RequestURI(), string(data)
+		authorization, header, contentType = r.Header.Get("Authorization"), r.Header.Get("X-Tenant"), r.Header.Get("Content-Type")
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	testTools := New()
+	for _, st := range jsonSendToRemoteTests {
+		_, status, err := testTools.JSONSendToRemote(context.Background(), srv.URL+"/items?v=1", st.data, st.req)
+		if err != nil || status != http.StatusAccepted {
+			t.Errorf("%s: expected status 202, got %d (%v)", st.name, status, err)
+			continue
+		}
+		if method != st.method || target != st.target || authorization != st.authorization || header != st.header || body != st.body {
+			t.Errorf("%s: unexpected request %s %s %q %q %q", st.name, method, target, authorization, header, body)
+		}
+		if (st.data == nil) != (contentType == "") {
+			t.Errorf("%s: unexpected content type %q", st.name, contentType)
+		}
+	}
+
+	patch := RemoteRequest{Method: http.MethodPatch, Header: http.Header{"Content-Type": {"application/merge-patch+json"}}}
+	if _, _, err := testTools.JSONSendToRemote(context.Background(), srv.URL, map[string]any{"name": nil}, patch); err != nil || contentType != "application/merge-patch+json" {
+		t.Errorf("expected the content type of the headers to be kept, got %q (%v)", contentType, err)
+	}
+
+	_, _, err := testTools.JSONSendToRemote(context.Background(), srv.URL, nil, RemoteRequest{BearerToken: "tok", Username: "ana"})
+	if err == nil {
+		t.Error("expected an error for both bearer and basic credentials")
+	}
+}
@@ -0,0 +1,134 @@
+package gorigumi
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/drunkleen/gorigumi/xmlx"
+)
+
+const (
+	// MediaTypeJSON is the media type of JSON responses
+	MediaTypeJSON = "application/json"
+	// MediaTypeXML is the media type of XML responses
+	MediaTypeXML = "application/xml"
+	// MediaTypeText is the media type of plain text responses
+	MediaTypeText = "text/plain"
+)
+
+// defaultResponseTypes are the media types of Respond when ResponseTypes is not set.
+var defaultResponseTypes = []string{MediaTypeJSON, MediaTypeXML, MediaTypeText}
+
+// Respond writes data with the given status code in the media type of
+// ResponseTypes preferred by the Accept header of r: as JSON with JSONWrite, as
+// XML with XMLWrite, or as plain text formatted with fmt.Sprint. Clients
+// accepting none of them, or sending no Accept header, get the first type of
+// ResponseTypes, JSON by default. The response varies on the Accept header, so
+// handlers serving several formats need no branching of their own.
+func (t *Tools) Respond(w http.ResponseWriter, r *http.Request, status int, data any, headers ...http.Header) error {
+	w.Header().Add("Vary", "Accept")
+
+	switch t.NegotiateResponseType(r) {
+	case MediaTypeXML:
+		return t.XMLWrite(w, status, data, headers...)
+	case MediaTypeText:
+		if len(headers) > 0 {
+			for key, value := range headers[0] {
+				w.Header()[key] = value
+			}
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+		_, err := fmt.Fprintln(w, data)
+		return err
+	default:
+		return t.JSONWrite(w, status, data, headers...)
+	}
+}
+
+// RespondError writes err like JSONError, XMLError, or as a plain text message,
+// in the media type negotiated by Respond. The status code defaults to 500
+// Internal Server Error. Errors with a 5xx status code are sent to the
+// ErrorReporter along with r and the user and tags of its context. Client
+// disconnects are handled like by JSONError.
+func (t *Tools) RespondError(w http.ResponseWriter, r *http.Request, err error, status ...int) error {
+	w.Header().Add("Vary", "Accept")
+
+	statusCode := errorStatus(err, status)
+	if statusCode >= 500 {
+		t.reportError(r.Context(), r, err, statusCode)
+	}
+
+	switch t.NegotiateResponseType(r) {
+	case MediaTypeXML:
+		return xmlx.Error(w, err, statusCode)
+	case MediaTypeText:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(statusCode)
+		_, writeErr := fmt.Fprintln(w, err.Error())
+		return writeErr
+	default:
+		return t.writeJSONError(w, err, statusCode)
+	}
+}
+
+// NegotiateResponseType returns the media type of ResponseTypes preferred by
+// the Accept header of r, or the first one of ResponseTypes if it accepts none.
+func (t *Tools) NegotiateResponseType(r *http.Request) string {
+	types := t.ResponseTypes
+	if len(types) == 0 {
+		types = defaultResponseTypes
+	}
+
+	best, bestQ, bestSpecificity := types[0], 0.0, -1
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+
+		for _, candidate := range types {
+			specificity, ok := matchMediaRange(mediaType, candidate)
+			if !ok {
+				continue
+			}
+			// a higher quality wins, then a more specific range, then the
+			// order of ResponseTypes
+			if q > bestQ || (q == bestQ && specificity > bestSpecificity) {
+				best, bestQ, bestSpecificity = candidate, q, specificity
+			}
+			break
+		}
+	}
+	return best
+}
+
+// matchMediaRange reports whether the media range of an Accept header, such as
+// "text/*", matches mediaType, and how specific the range is: 0 for "*/*", 1
+// for "type/*" and 2 for a full media type.
+func matchMediaRange(mediaRange, mediaType string) (int, bool) {
+	rangeType, rangeSub, _ := strings.Cut(mediaRange, "/")
+	typ, sub, _ := strings.Cut(mediaType, "/")
+	switch {
+	case rangeType == "*" && rangeSub == "*":
+		return 0, true
+	case rangeType == typ && rangeSub == "*":
+		return 1, true
+	case rangeType == typ && rangeSub == sub:
+		return 2, true
+	}
+	return 0, false
+}
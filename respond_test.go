@@ -0,0 +1,99 @@
+package gorigumi
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// negotiateTests is a slice of structs that hold the name of the test, the supported
+// response types, the Accept header and the expected media type
+var negotiateTests = []struct {
+	name     string
+	types    []string
+	accept   string
+	expected string
+}{
+	{"no accept header", nil, "", MediaTypeJSON},
+	{"xml", nil, "application/xml", MediaTypeXML},
+	{"text", nil, "text/plain", MediaTypeText},
+	{"browser", nil, "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", MediaTypeXML},
+	{"wildcard", nil, "*/*", MediaTypeJSON},
+	{"type wildcard", nil, "text/*", MediaTypeText},
+	{"quality", nil, "application/json;q=0.5, application/xml", MediaTypeXML},
+	{"specific over wildcard", nil, "*/*, text/plain", MediaTypeText},
+	{"refused", nil, "application/json;q=0, text/plain;q=0.1", MediaTypeText},
+	{"unsupported", nil, "image/png", MediaTypeJSON},
+	{"configured default", []string{MediaTypeXML, MediaTypeJSON}, "image/png", MediaTypeXML},
+	{"configured set", []string{MediaTypeJSON}, "text/plain", MediaTypeJSON},
+}
+
+// TestTools_NegotiateResponseType tests the media type chosen for Accept headers.
+func TestTools_NegotiateResponseType(t *testing.T) {
+	for _, nt := range negotiateTests {
+		testTools := New()
+		testTools.ResponseTypes = nt.types
+
+		req := httptest.NewRequest("GET", "/", nil)
+		if nt.accept != "" {
+			req.Header.Set("Accept", nt.accept)
+		}
+		if got := testTools.NegotiateResponseType(req); got != nt.expected {
+			t.Errorf("%s: expected %s, got %s", nt.name, nt.expected, got)
+		}
+	}
+}
+
+// greeting is a response body encodable as JSON, XML and plain text.
+type greeting struct {
+	XMLName xml.Name `json:"-" xml:"greeting"`
+	Message string   `json:"message" xml:"message"`
+}
+
+// String returns the message of the greeting.
+func (g greeting) String() string {
+	return g.Message
+}
+
+// TestTools_Respond tests that Respond and RespondError encode in the negotiated type.
+func TestTools_Respond(t *testing.T) {
+	testTools := New()
+
+	var respondTests = []struct {
+		accept      string
+		contentType string
+		body        string
+		errorBody   string
+	}{
+		{"application/json", "application/json", `{"message":"hello"}`, `{"error":true,"message":"gone fishing","code":"service_unavailable"}`},
+		{"application/xml", "application/xml; charset=utf-8", "<greeting><message>hello</message></greeting>", "<response><error>true</error><message>gone fishing</message><code>service_unavailable</code></response>"},
+		{"text/plain", "text/plain; charset=utf-8", "hello\n", "gone fishing\n"},
+	}
+
+	for _, rt := range respondTests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", rt.accept)
+
+		rr := httptest.NewRecorder()
+		if err := testTools.Respond(rr, req, http.StatusOK, greeting{Message: "hello"}); err != nil {
+			t.Errorf("%s: %s", rt.accept, err)
+		}
+		if rr.Header().Get("Content-Type") != rt.contentType || !strings.HasSuffix(rr.Body.String(), rt.body) {
+			t.Errorf("%s: unexpected response %q %q", rt.accept, rr.Header().Get("Content-Type"), rr.Body.String())
+		}
+		if rr.Header().Get("Vary") != "Accept" {
+			t.Errorf("%s: expected Vary: Accept, got %q", rt.accept, rr.Header().Get("Vary"))
+		}
+
+		rr = httptest.NewRecorder()
+		if err := testTools.RespondError(rr, req, errors.New("gone fishing"), http.StatusServiceUnavailable); err != nil {
+			t.Errorf("%s: %s", rt.accept, err)
+		}
+		if rr.Code != http.StatusServiceUnavailable || !strings.HasSuffix(rr.Body.String(), rt.errorBody) {
+			t.Errorf("%s: unexpected error response %d %q", rt.accept, rr.Code, rr.Body.String())
+		}
+	}
+}
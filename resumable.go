@@ -0,0 +1,497 @@
+package gorigumi
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// resumableStateDir is the directory of uploadDir holding the data and
+	// state of uploads in progress
+	resumableStateDir string = ".resumable"
+
+	// tusVersion is the version of the tus protocol spoken by ResumableUploads
+	tusVersion string = "1.0.0"
+
+	// defaultMaxPendingUploads is the default maximum number of uploads in progress
+	// it is inlcuded in the NewResumableUploads method
+	defaultMaxPendingUploads int = 1000
+
+	// defaultUploadExpiry is the default time after which idle uploads are removed
+	// it is inlcuded in the NewResumableUploads method
+	defaultUploadExpiry time.Duration = 24 * time.Hour
+)
+
+// ResumableOptions configures a ResumableUploads handler.
+type ResumableOptions struct {
+	// MaxSize is the maximum size of an upload. Default to the MaxFileSize
+	// of the Tools struct, or 512MB
+	MaxSize int64
+	// BasePath is the path the handler is mounted at, used to build the
+	// Location of created uploads. Default to the path of the creation request
+	BasePath string
+	// MaxPending is the maximum number of uploads in progress at once, the
+	// creation of more uploads is refused with 503 Service Unavailable.
+	// Default to 1000
+	MaxPending int
+	// Expiry is the time after which an upload that received no chunk is
+	// abandoned, and removed by the next creation of an upload. Default to
+	// 24 hours
+	Expiry time.Duration
+	// OnComplete is called once an upload is complete and stored
+	OnComplete func(id string, file *UploadedFile)
+}
+
+// UploadProgress is the state of a resumable upload in progress.
+type UploadProgress struct {
+	ID       string `json:"id"`
+	FileName string `json:"file_name"`
+	Length   int64  `json:"length"`
+	Offset   int64  `json:"offset"`
+}
+
+// ResumableUploads is a http.Handler implementing the core of the tus resumable
+// upload protocol (https://tus.io), so large files survive flaky connections:
+//
+//   - POST with an Upload-Length header creates an upload and returns its URL in
+//     the Location header. The file name is read from the filename key of the
+//     Upload-Metadata header.
+//   - HEAD on the upload URL returns the Upload-Offset to resume from.
+//   - PATCH (or PUT) with an Upload-Offset header appends the body as a chunk.
+//   - DELETE abandons the upload.
+//
+// Chunks are appended to a file on disk, so uploads resume after a disconnect
+// or a restart. Once all bytes were received, the file is checked against the
+// allowed file types and extensions of the Tools struct and moved to the upload
+// directory under a random name, then processed like other uploads according to
+// ImageOptions and MetadataSidecars, and its state is removed. At most
+// MaxPending uploads are in progress at once, and uploads idle for longer than
+// Expiry are abandoned. Use NewResumableUploads to create one.
+type ResumableUploads struct {
+	tools   *Tools
+	dir     string
+	options ResumableOptions
+
+	mu   sync.Mutex
+	busy map[string]bool
+}
+
+// NewResumableUploads returns a new ResumableUploads handler storing completed
+// files in uploadDir.
+func (t *Tools) NewResumableUploads(uploadDir string, opts ...ResumableOptions) (*ResumableUploads, error) {
+	var options ResumableOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	if options.MaxSize == 0 {
+		options.MaxSize = int64(defaultMaxFileSize)
+		if t.MaxFileSize != 0 {
+			options.MaxSize = int64(t.MaxFileSize)
+		}
+	}
+	if options.MaxPending == 0 {
+		options.MaxPending = defaultMaxPendingUploads
+	}
+	if options.Expiry == 0 {
+		options.Expiry = defaultUploadExpiry
+	}
+
+	if err := t.CreateDirIfNotExists(filepath.Join(uploadDir, resumableStateDir)); err != nil {
+		return nil, err
+	}
+
+	return &ResumableUploads{tools: t, dir: uploadDir, options: options, busy: make(map[string]bool)}, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (u *ResumableUploads) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+
+	if r.Method == http.MethodPost {
+		u.create(w, r)
+		return
+	}
+
+	id := path.Base(r.URL.Path)
+	if !validUploadID(id) {
+		_ = u.tools.JSONError(w, errors.New("upload not found"), http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodHead:
+		p, err := u.Progress(id)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Upload-Offset", strconv.FormatInt(p.Offset, 10))
+		w.Header().Set("Upload-Length", strconv.FormatInt(p.Length, 10))
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+	case http.MethodPatch, http.MethodPut:
+		u.appendChunk(w, r, id)
+	case http.MethodDelete:
+		if !u.lock(id) {
+			_ = u.tools.JSONError(w, errors.New("upload is busy"), http.StatusLocked)
+			return
+		}
+		defer u.unlock(id)
+
+		if _, err := u.Progress(id); err != nil {
+			_ = u.tools.JSONError(w, err, http.StatusNotFound)
+			return
+		}
+		u.remove(id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "POST, HEAD, PATCH, PUT, DELETE")
+		_ = u.tools.JSONError(w, errors.New("method not allowed"), http.StatusMethodNotAllowed)
+	}
+}
+
+// Progress returns the state of the upload id. Completed, abandoned and expired
+// uploads are not found.
+func (u *ResumableUploads) Progress(id string) (*UploadProgress, error) {
+	if !validUploadID(id) {
+		return nil, errors.New("upload not found")
+	}
+
+	data, err := os.ReadFile(u.statePath(id, ".json"))
+	if err != nil {
+		return nil, errors.New("upload not found")
+	}
+
+	var p UploadProgress
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(u.statePath(id, ".part"))
+	if err != nil {
+		return nil, err
+	}
+	if u.expired(info) {
+		return nil, errors.New("upload not found")
+	}
+	p.Offset = info.Size()
+
+	return &p, nil
+}
+
+// create starts a new upload.
+func (u *ResumableUploads) create(w http.ResponseWriter, r *http.Request) {
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		_ = u.tools.JSONError(w, errors.New("a valid Upload-Length header is required"), http.StatusBadRequest)
+		return
+	}
+	if length > u.options.MaxSize {
+		_ = u.tools.JSONError(w, fmt.Errorf("upload of %d bytes exceeds the limit of %d bytes", length, u.options.MaxSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if q, ok := u.tools.dirQuota(); ok {
+		if _, err := q.CheckCtx(r.Context(), u.dir, length, 1); err != nil {
+			_ = u.tools.JSONError(w, err, http.StatusRequestEntityTooLarge)
+			return
+		}
+	}
+
+	p := UploadProgress{
+		ID:       u.tools.GenerateRandomString(32),
+		FileName: parseUploadMetadata(r.Header.Get("Upload-Metadata"))["filename"],
+		Length:   length,
+	}
+
+	if err := u.start(&p); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errTooManyUploads) {
+			status = http.StatusServiceUnavailable
+		}
+		_ = u.tools.JSONError(w, err, status)
+		return
+	}
+
+	base := u.options.BasePath
+	if base == "" {
+		base = r.URL.Path
+	}
+	w.Header().Set("Location", strings.TrimSuffix(base, "/")+"/"+p.ID)
+	w.WriteHeader(http.StatusCreated)
+
+	// empty uploads are complete as soon as they are created
+	if length == 0 {
+		u.lock(p.ID)
+		defer u.unlock(p.ID)
+		u.tools.countUploadError(r, u.complete(r.Context(), &p))
+	}
+}
+
+// errTooManyUploads is returned by start once MaxPending uploads are in progress.
+var errTooManyUploads = errors.New("too many uploads in progress, retry later")
+
+// start creates the data and state of the new upload p, unless MaxPending
+// uploads are already in progress.
+func (u *ResumableUploads) start(p *UploadProgress) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	pending, err := u.prunePending()
+	if err != nil {
+		return err
+	}
+	if pending >= u.options.MaxPending {
+		return errTooManyUploads
+	}
+
+	// the data is created exclusively, and appended to without following links
+	part, err := os.OpenFile(u.statePath(p.ID, ".part"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	part.Close()
+
+	if err := u.saveState(p); err != nil {
+		u.remove(p.ID)
+		return err
+	}
+	return nil
+}
+
+// appendChunk appends the body of r to the upload id at the offset declared in
+// the Upload-Offset header.
+func (u *ResumableUploads) appendChunk(w http.ResponseWriter, r *http.Request, id string) {
+	if !u.lock(id) {
+		_ = u.tools.JSONError(w, errors.New("upload is busy"), http.StatusLocked)
+		return
+	}
+	defer u.unlock(id)
+
+	p, err := u.Progress(id)
+	if err != nil {
+		_ = u.tools.JSONError(w, err, http.StatusNotFound)
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset != p.Offset {
+		w.Header().Set("Upload-Offset", strconv.FormatInt(p.Offset, 10))
+		_ = u.tools.JSONError(w, fmt.Errorf("upload offset is %d", p.Offset), http.StatusConflict)
+		return
+	}
+
+	part, err := openNoFollow(u.statePath(id, ".part"), os.O_WRONLY|os.O_APPEND)
+	if err != nil {
+		_ = u.tools.JSONError(w, err)
+		return
+	}
+
+	// read one byte past the remaining length to detect oversized chunks;
+	// bytes received before a disconnect are kept so the client can resume
+	remaining := p.Length - p.Offset
+	n, err := io.Copy(part, io.LimitReader(r.Body, remaining+1))
+	if n > remaining {
+		part.Truncate(p.Length)
+		n = remaining
+		err = errors.New("chunk exceeds the upload length")
+	}
+	if cerr := part.Close(); err == nil {
+		err = cerr
+	}
+	err = disconnectError(r, err)
+
+	p.Offset += n
+	w.Header().Set("Upload-Offset", strconv.FormatInt(p.Offset, 10))
+	if err != nil {
+		_ = u.tools.JSONError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	if p.Offset == p.Length {
+		if err := u.complete(r.Context(), p); err != nil {
+			u.tools.countUploadError(r, err)
+			_ = u.tools.JSONError(w, err, http.StatusUnsupportedMediaType)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// complete checks the type of a fully received upload, scans it, and moves it to
+// the upload directory. The state of the upload is removed, whether it was
+// stored or rejected.
+func (u *ResumableUploads) complete(ctx context.Context, p *UploadProgress) error {
+	partPath := u.statePath(p.ID, ".part")
+
+	var fileType string
+	head, err := readHead(partPath)
+	if err == nil {
+		fileType, err = u.tools.checkFileType(p.FileName, head)
+	}
+	if err != nil {
+		u.remove(p.ID)
+		return err
+	}
+
+	file := &UploadedFile{
+		OriginalFileName: p.FileName,
+		NewFileName:      u.tools.newFileName(p.FileName, true),
+		FileSize:         p.Length,
+	}
+	if err := u.tools.scanUpload(ctx, u.dir, partPath, file); err != nil {
+		u.remove(p.ID)
+		return err
+	}
+	if err := os.Rename(partPath, filepath.Join(u.dir, file.NewFileName)); err != nil {
+		return err
+	}
+	u.remove(p.ID)
+	if err := u.tools.finishUpload(u.dir, file, fileType); err != nil {
+		return err
+	}
+
+	if u.options.OnComplete != nil {
+		u.options.OnComplete(p.ID, file)
+	}
+	return nil
+}
+
+// readHead returns the first 512 bytes of the file at path.
+func readHead(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return head[:n], nil
+}
+
+// saveState writes the state of an upload next to its data.
+func (u *ResumableUploads) saveState(p *UploadProgress) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return writeFileReplacing(u.statePath(p.ID, ".json"), data)
+}
+
+// remove deletes the data and state of the upload id.
+func (u *ResumableUploads) remove(id string) {
+	os.Remove(u.statePath(id, ".part"))
+	os.Remove(u.statePath(id, ".json"))
+}
+
+// prunePending removes the uploads in progress that expired, and the data or
+// state left alone by a crash, and returns the number of the remaining uploads.
+// Busy uploads are kept. u.mu must be held.
+func (u *ResumableUploads) prunePending() (int, error) {
+	entries, err := os.ReadDir(filepath.Join(u.dir, resumableStateDir))
+	if err != nil {
+		return 0, err
+	}
+
+	ids := make(map[string]bool)
+	for _, entry := range entries {
+		if id := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())); validUploadID(id) {
+			ids[id] = true
+		}
+	}
+
+	pending := 0
+	for id := range ids {
+		if u.busy[id] {
+			pending++
+			continue
+		}
+		info, err := os.Stat(u.statePath(id, ".part"))
+		if err != nil || u.expired(info) || !fileExists(u.statePath(id, ".json")) {
+			u.remove(id)
+			continue
+		}
+		pending++
+	}
+	return pending, nil
+}
+
+// expired reports whether the upload whose data has info received no chunk
+// for longer than Expiry.
+func (u *ResumableUploads) expired(info os.FileInfo) bool {
+	return u.tools.clock().Now().Sub(info.ModTime()) > u.options.Expiry
+}
+
+// statePath returns the path of the state file of the upload id with ext.
+func (u *ResumableUploads) statePath(id, ext string) string {
+	return filepath.Join(u.dir, resumableStateDir, id+ext)
+}
+
+// lock marks the upload id as busy, it reports false if it already was.
+func (u *ResumableUploads) lock(id string) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.busy[id] {
+		return false
+	}
+	u.busy[id] = true
+	return true
+}
+
+// unlock marks the upload id as idle.
+func (u *ResumableUploads) unlock(id string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	delete(u.busy, id)
+}
+
+// validUploadID reports whether id only holds characters of generated ids,
+// so it can safely be used in file names.
+func validUploadID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// parseUploadMetadata parses a tus Upload-Metadata header, a comma separated
+// list of keys followed by their base64 encoded value.
+func parseUploadMetadata(header string) map[string]string {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+		if err != nil {
+			continue
+		}
+		metadata[key] = string(decoded)
+	}
+	return metadata
+}
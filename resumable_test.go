@@ -0,0 +1,205 @@
+package gorigumi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/drunkleen/gorigumi/toolkittest"
+)
+
+// failingReader returns its data, then an error, like a connection cut mid-chunk.
+type failingReader struct {
+	data []byte
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	if len(f.data) == 0 {
+		return 0, errors.New("connection reset")
+	}
+	n := copy(p, f.data)
+	f.data = f.data[n:]
+	return n, nil
+}
+
+// resumableRequest sends a request to handler and returns the recorded response.
+func resumableRequest(handler http.Handler, method, target string, body io.Reader, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, body)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+// TestTools_ResumableUploads tests an upload sent in chunks, interrupted, resumed
+// and completed.
+func TestTools_ResumableUploads(t *testing.T) {
+	img, err := toolkittest.GenerateImage(200, 200, "png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	uploadDir := t.TempDir()
+
+	testTools := New()
+	testTools.AllowedFileTypes = []string{"image/png"}
+
+	var completed *UploadedFile
+	uploads, err := testTools.NewResumableUploads(uploadDir, ResumableOptions{
+		OnComplete: func(id string, file *UploadedFile) { completed = file },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := resumableRequest(uploads, "POST", "/files/", nil, map[string]string{
+		"Upload-Length":   strconv.Itoa(len(img)),
+		"Upload-Metadata": "filename " + base64.StdEncoding.EncodeToString([]byte("photo.png")) + ",private",
+	})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", rr.Code)
+	}
+	location := rr.Header().Get("Location")
+
+	// the first chunk is cut after 100 bytes
+	third := len(img) / 3
+	rr = resumableRequest(uploads, "PATCH", location, &failingReader{data: img[:100]}, map[string]string{"Upload-Offset": "0"})
+	if rr.Code != http.StatusBadRequest || rr.Header().Get("Upload-Offset") != "100" {
+		t.Errorf("expected interrupted chunk to keep 100 bytes, got %d with offset %s", rr.Code, rr.Header().Get("Upload-Offset"))
+	}
+
+	rr = resumableRequest(uploads, "HEAD", location, nil, nil)
+	if rr.Header().Get("Upload-Offset") != "100" || rr.Header().Get("Upload-Length") != strconv.Itoa(len(img)) {
+		t.Errorf("unexpected progress headers %v", rr.Header())
+	}
+
+	rr = resumableRequest(uploads, "PATCH", location, bytes.NewReader(img[:third]), map[string]string{"Upload-Offset": "0"})
+	if rr.Code != http.StatusConflict {
+		t.Errorf("expected wrong offset to conflict, got %d", rr.Code)
+	}
+
+	rr = resumableRequest(uploads, "PATCH", location, bytes.NewReader(img[100:third]), map[string]string{"Upload-Offset": "100"})
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("expected status 204, got %d", rr.Code)
+	}
+
+	p, err := uploads.Progress(filepath.Base(location))
+	if err != nil || p.Offset != int64(third) {
+		t.Errorf("unexpected progress %+v, %v", p, err)
+	}
+
+	rr = resumableRequest(uploads, "PUT", location, bytes.NewReader(img[third:]), map[string]string{"Upload-Offset": strconv.Itoa(third)})
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d: %s", rr.Code, rr.Body)
+	}
+
+	if completed == nil || completed.OriginalFileName != "photo.png" {
+		t.Fatalf("expected OnComplete to be called, got %+v", completed)
+	}
+	if data, _ := os.ReadFile(filepath.Join(uploadDir, completed.NewFileName)); !bytes.Equal(data, img) {
+		t.Error("expected the assembled file to match the upload")
+	}
+	if entries, _ := os.ReadDir(filepath.Join(uploadDir, resumableStateDir)); len(entries) != 0 {
+		t.Errorf("expected the state of the completed upload to be removed, got %d entries", len(entries))
+	}
+
+	rr = resumableRequest(uploads, "PATCH", location, bytes.NewReader(img), map[string]string{"Upload-Offset": strconv.Itoa(len(img))})
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected completed upload to be gone, got %d", rr.Code)
+	}
+}
+
+// TestTools_ResumableUploadsRejections tests the rejection of oversized and disallowed
+// uploads, and the deletion of uploads.
+func TestTools_ResumableUploadsRejections(t *testing.T) {
+	testTools := New()
+	testTools.AllowedFileTypes = []string{"image/png"}
+	uploads, err := testTools.NewResumableUploads(t.TempDir(), ResumableOptions{MaxSize: 1000, BasePath: "/uploads"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rr := resumableRequest(uploads, "POST", "/", nil, map[string]string{"Upload-Length": "1001"}); rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected oversized upload to be rejected, got %d", rr.Code)
+	}
+	if rr := resumableRequest(uploads, "POST", "/", nil, nil); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected missing length to be rejected, got %d", rr.Code)
+	}
+
+	text := []byte("not an image")
+	rr := resumableRequest(uploads, "POST", "/", nil, map[string]string{"Upload-Length": strconv.Itoa(len(text))})
+	location := rr.Header().Get("Location")
+	if filepath.Dir(location) != "/uploads" {
+		t.Errorf("expected location under the base path, got %s", location)
+	}
+
+	rr = resumableRequest(uploads, "PATCH", location, bytes.NewReader(text), map[string]string{"Upload-Offset": "0"})
+	if rr.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("expected disallowed type to be rejected, got %d", rr.Code)
+	}
+	if _, err := uploads.Progress(filepath.Base(location)); err == nil {
+		t.Error("expected rejected upload to be removed")
+	}
+
+	rr = resumableRequest(uploads, "POST", "/", nil, map[string]string{"Upload-Length": "10"})
+	location = rr.Header().Get("Location")
+	if rr := resumableRequest(uploads, "DELETE", location, nil, nil); rr.Code != http.StatusNoContent {
+		t.Errorf("expected status 204, got %d", rr.Code)
+	}
+	if rr := resumableRequest(uploads, "HEAD", location, nil, nil); rr.Code != http.StatusNotFound {
+		t.Errorf("expected deleted upload to be gone, got %d", rr.Code)
+	}
+	if rr := resumableRequest(uploads, "HEAD", "/uploads/..", nil, nil); rr.Code != http.StatusNotFound {
+		t.Errorf("expected invalid id to be rejected, got %d", rr.Code)
+	}
+}
+
+// TestTools_ResumableUploadsPending tests that the number of uploads in progress is
+// capped, and that expired uploads are removed to make room for new ones.
+func TestTools_ResumableUploadsPending(t *testing.T) {
+	uploadDir := t.TempDir()
+	testTools := New()
+	testTools.AllowedFileTypes = []string{"*"}
+	uploads, err := testTools.NewResumableUploads(uploadDir, ResumableOptions{MaxPending: 2, Expiry: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var locations []string
+	for range 2 {
+		rr := resumableRequest(uploads, "POST", "/", nil, map[string]string{"Upload-Length": "10"})
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status 201, got %d", rr.Code)
+		}
+		locations = append(locations, rr.Header().Get("Location"))
+	}
+	if rr := resumableRequest(uploads, "POST", "/", nil, map[string]string{"Upload-Length": "10"}); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected the third upload to be refused, got %d", rr.Code)
+	}
+
+	// the first upload received its last chunk two hours ago
+	idle := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(filepath.Join(uploadDir, resumableStateDir, filepath.Base(locations[0])+".part"), idle, idle)
+	if rr := resumableRequest(uploads, "HEAD", locations[0], nil, nil); rr.Code != http.StatusNotFound {
+		t.Errorf("expected the expired upload to be gone, got %d", rr.Code)
+	}
+
+	if rr := resumableRequest(uploads, "POST", "/", nil, map[string]string{"Upload-Length": "10"}); rr.Code != http.StatusCreated {
+		t.Errorf("expected the expired upload to make room, got %d", rr.Code)
+	}
+	if _, err := os.Stat(filepath.Join(uploadDir, resumableStateDir, filepath.Base(locations[0])+".json")); err == nil {
+		t.Error("expected the state of the expired upload to be removed")
+	}
+	if rr := resumableRequest(uploads, "HEAD", locations[1], nil, nil); rr.Code != http.StatusOK {
+		t.Errorf("expected the other upload to be kept, got %d", rr.Code)
+	}
+}
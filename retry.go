@@ -0,0 +1,183 @@
+package gorigumi
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultRetryAttempts is the default maximum number of attempts
+	// it is inlcuded in the Retry function
+	defaultRetryAttempts int = 3
+
+	// defaultRetryInitialBackoff is the default delay before the first retry
+	// it is inlcuded in the Retry function
+	defaultRetryInitialBackoff time.Duration = 100 * time.Millisecond
+
+	// defaultRetryMaxBackoff is the default maximum delay between two attempts
+	// it is inlcuded in the Retry function
+	defaultRetryMaxBackoff time.Duration = 10 * time.Second
+
+	// defaultRetryMultiplier is the default growth factor of the backoff
+	// it is inlcuded in the Retry function
+	defaultRetryMultiplier float64 = 2
+)
+
+// RetryPolicy configures Retry. Zero values fall back to 3 attempts, an initial
+// backoff of 100ms doubled after every attempt up to 10s, and no jitter.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between two attempts
+	MaxBackoff time.Duration
+	// Multiplier is the growth factor of the backoff
+	Multiplier float64
+	// Jitter is the share (0-1) of every delay that is randomized
+	Jitter float64
+	// MaxElapsedTime caps the total time spent retrying. Zero means no limit
+	MaxElapsedTime time.Duration
+	// Retryable classifies errors. By default every error is retryable
+	// except those wrapped with Permanent and context errors
+	Retryable func(err error) bool
+	// Clock is the source of time of the backoff. Default to SystemClock
+	Clock Clock
+}
+
+// permanentError wraps an error that must not be retried.
+type permanentError struct {
+	err error
+}
+
+// Error returns the message of the wrapped error.
+func (p *permanentError) Error() string {
+	return p.err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (p *permanentError) Unwrap() error {
+	return p.err
+}
+
+// Permanent wraps err so Retry gives up immediately instead of retrying it.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// RetryAfterError is an error carrying the delay a server asked the client to
+// wait before retrying, e.g. from a Retry-After header. Retry waits for this
+// delay instead of its own backoff.
+type RetryAfterError struct {
+	Err   error
+	After time.Duration
+}
+
+// Error returns the message of the wrapped error.
+func (e *RetryAfterError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
+
+// Retry calls fn until it succeeds, returns a non-retryable error, the attempts or
+// the elapsed time of policy are exhausted, or ctx is done. Between attempts it
+// waits for an exponential backoff with optional jitter. It returns the result of
+// the last attempt; errors wrapped with Permanent are returned unwrapped.
+func Retry[T any](ctx context.Context, policy RetryPolicy, fn func(ctx context.Context) (T, error)) (T, error) {
+	policy = policy.withDefaults()
+
+	clock := clockOrSystem(policy.Clock)
+	start := clock.Now()
+	backoff := policy.InitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		result, err := fn(ctx)
+		if err == nil {
+			return result, nil
+		}
+
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return result, permanent.err
+		}
+
+		if attempt >= policy.MaxAttempts || !policy.Retryable(err) || ctx.Err() != nil {
+			return result, err
+		}
+
+		delay := policy.jitter(backoff)
+		var retryAfter *RetryAfterError
+		if errors.As(err, &retryAfter) && retryAfter.After > 0 {
+			delay = retryAfter.After
+		}
+
+		if policy.MaxElapsedTime > 0 && clock.Now().Sub(start)+delay > policy.MaxElapsedTime {
+			return result, err
+		}
+
+		select {
+		case <-clock.After(delay):
+		case <-ctx.Done():
+			return result, err
+		}
+
+		backoff = min(time.Duration(float64(backoff)*policy.Multiplier), policy.MaxBackoff)
+	}
+}
+
+// withDefaults returns a copy of the policy with zero values replaced by defaults.
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = defaultRetryAttempts
+	}
+	if p.InitialBackoff == 0 {
+		p.InitialBackoff = defaultRetryInitialBackoff
+	}
+	if p.MaxBackoff == 0 {
+		p.MaxBackoff = defaultRetryMaxBackoff
+	}
+	if p.Multiplier == 0 {
+		p.Multiplier = defaultRetryMultiplier
+	}
+	if p.Retryable == nil {
+		p.Retryable = func(err error) bool {
+			return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+		}
+	}
+	return p
+}
+
+// jitter randomizes the Jitter share of d.
+func (p RetryPolicy) jitter(d time.Duration) time.Duration {
+	if p.Jitter <= 0 {
+		return d
+	}
+	spread := float64(d) * min(p.Jitter, 1)
+	return time.Duration(float64(d) - spread + rand.Float64()*2*spread)
+}
+
+// retryAfter returns the delay of the Retry-After header value, in seconds or
+// an HTTP date, from now. It returns 0 if the value is empty or invalid.
+func retryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0)
+	}
+	return 0
+}
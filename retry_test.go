@@ -0,0 +1,116 @@
+package gorigumi
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// retryTests is a slice of structs that hold the name of the test, the retry policy, the
+// errors returned by the successive attempts, the expected number of attempts and a
+// boolean that indicates if an error is expected
+var retryTests = []struct {
+	name             string
+	policy           RetryPolicy
+	errs             []error
+	expectedAttempts int
+	errorExpected    bool
+}{
+	{"first attempt", RetryPolicy{}, nil, 1, false},
+	{"succeeds after retries", RetryPolicy{}, []error{errors.New("a"), errors.New("b")}, 3, false},
+	{"attempts exhausted", RetryPolicy{MaxAttempts: 2}, []error{errors.New("a"), errors.New("b"), errors.New("c")}, 2, true},
+	{"permanent error", RetryPolicy{}, []error{Permanent(errors.New("a"))}, 1, true},
+	{"not retryable", RetryPolicy{Retryable: func(err error) bool { return err.Error() != "fatal" }}, []error{errors.New("a"), errors.New("fatal")}, 2, true},
+	{"max elapsed time", RetryPolicy{InitialBackoff: time.Second, MaxElapsedTime: 100 * time.Millisecond}, []error{errors.New("a"), errors.New("b")}, 1, true},
+	{"retry after", RetryPolicy{InitialBackoff: time.Hour}, []error{&RetryAfterError{Err: errors.New("a"), After: time.Millisecond}}, 2, false},
+}
+
+// TestRetry tests the Retry function with several policies and sequences of errors,
+// checking the number of attempts and the returned result.
+func TestRetry(t *testing.T) {
+	for _, rt := range retryTests {
+		rt.policy.InitialBackoff = max(rt.policy.InitialBackoff, time.Millisecond)
+		if rt.policy.InitialBackoff == time.Millisecond {
+			rt.policy.MaxBackoff = 5 * time.Millisecond
+		}
+
+		attempts := 0
+		result, err := Retry(context.Background(), rt.policy, func(ctx context.Context) (int, error) {
+			attempts++
+			if attempts <= len(rt.errs) {
+				return 0, rt.errs[attempts-1]
+			}
+			return 42, nil
+		})
+
+		if attempts != rt.expectedAttempts {
+			t.Errorf("%s: expected %d attempts, got %d", rt.name, rt.expectedAttempts, attempts)
+		}
+		if rt.errorExpected && err == nil {
+			t.Errorf("%s: expected error but got none", rt.name)
+		}
+		if !rt.errorExpected && (err != nil || result != 42) {
+			t.Errorf("%s: expected 42, got %d, %v", rt.name, result, err)
+		}
+
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			t.Errorf("%s: expected permanent error to be unwrapped", rt.name)
+		}
+	}
+}
+
+// TestRetryContext tests that Retry stops waiting once its context is done.
+func TestRetryContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := Retry(ctx, RetryPolicy{MaxAttempts: 10, InitialBackoff: time.Hour}, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, errors.New("failed")
+	})
+
+	if err == nil {
+		t.Error("expected error but got none")
+	}
+	if time.Since(start) > time.Second {
+		t.Error("expected Retry to return once the context is done")
+	}
+}
+
+// TestRetryPolicy_jitter tests that jittered delays stay within the configured spread.
+func TestRetryPolicy_jitter(t *testing.T) {
+	policy := RetryPolicy{Jitter: 0.5}
+	for range 100 {
+		d := policy.jitter(time.Second)
+		if d < 500*time.Millisecond || d > 1500*time.Millisecond {
+			t.Fatalf("expected delay within 0.5s and 1.5s, got %s", d)
+		}
+	}
+}
+
+// retryAfterTests is a slice of structs that hold the name of the test, the value of the
+// Retry-After header and the expected delay
+var retryAfterTests = []struct {
+	name  string
+	value string
+	delay time.Duration
+}{
+	{"empty", "", 0},
+	{"seconds", "120", 2 * time.Minute},
+	{"negative", "-5", 0},
+	{"date", "Thu, 14 Mar 2024 09:27:23 GMT", 30 * time.Second},
+	{"past date", "Thu, 14 Mar 2024 09:00:00 GMT", 0},
+	{"invalid", "soon", 0},
+}
+
+// TestRetryAfter tests the parsing of Retry-After header values.
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 3, 14, 9, 26, 53, 0, time.UTC)
+	for _, rt := range retryAfterTests {
+		if delay := retryAfter(rt.value, now); delay != rt.delay {
+			t.Errorf("%s: expected %s, got %s", rt.name, rt.delay, delay)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package gorigumi
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotatedFileTimeFormat is the time layout appended to rotated file names.
+const rotatedFileTimeFormat = "20060102T150405.000"
+
+// RotatingWriter is an io.WriteCloser writing to a file that is rotated once it
+// grows beyond a maximum size. Rotated files are renamed with a timestamp suffix,
+// e.g. "access.log.20250102T150405.000", optionally gzip-compressed, and pruned by
+// age and count. It is safe for concurrent use.
+type RotatingWriter struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	interval   time.Duration
+	maxAge     time.Duration
+	maxBackups int
+	compress   bool
+	file       *os.File
+	size       int64
+	openedAt   time.Time
+	wg         sync.WaitGroup
+}
+
+// NewRotatingWriter opens (or creates) the file at path for appending and returns
+// a RotatingWriter for it. The file is rotated once it grows beyond maxSize bytes.
+// Rotated files older than maxAge are removed, as are all but the maxBackups most
+// recent ones. If compress is true, rotated files are gzip-compressed. A zero
+// maxSize, maxAge or maxBackups disables the corresponding behavior.
+func NewRotatingWriter(path string, maxSize int64, maxAge time.Duration, maxBackups int, compress bool) (*RotatingWriter, error) {
+	rw := &RotatingWriter{
+		path:       path,
+		maxSize:    maxSize,
+		maxAge:     maxAge,
+		maxBackups: maxBackups,
+		compress:   compress,
+	}
+	if err := rw.open(); err != nil {
+		return nil, err
+	}
+	return rw, nil
+}
+
+// Write writes p to the current file, rotating it first if needed.
+func (rw *RotatingWriter) Write(p []byte) (int, error) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	if rw.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if rw.shouldRotate(len(p)) {
+		if err := rw.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := rw.file.Write(p)
+	rw.size += int64(n)
+	return n, err
+}
+
+// Reopen closes and reopens the file at the same path. It is meant to be called
+// after an external tool such as logrotate moved the file away.
+func (rw *RotatingWriter) Reopen() error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	if rw.file != nil {
+		if err := rw.file.Close(); err != nil {
+			return err
+		}
+	}
+	return rw.open()
+}
+
+// Rotate forces the rotation of the current file.
+func (rw *RotatingWriter) Rotate() error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	return rw.rotate()
+}
+
+// Close closes the current file and waits for pending compressions.
+func (rw *RotatingWriter) Close() error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	defer rw.wg.Wait()
+
+	if rw.file == nil {
+		return nil
+	}
+	err := rw.file.Close()
+	rw.file = nil
+	return err
+}
+
+// shouldRotate reports whether writing n more bytes requires a rotation.
+func (rw *RotatingWriter) shouldRotate(n int) bool {
+	if rw.maxSize > 0 && rw.size > 0 && rw.size+int64(n) > rw.maxSize {
+		return true
+	}
+	return rw.interval > 0 && time.Since(rw.openedAt) >= rw.interval
+}
+
+// rotate renames the current file with a timestamp suffix, opens a new one and
+// compresses and prunes the rotated files in the background.
+func (rw *RotatingWriter) rotate() error {
+	if rw.file != nil {
+		if err := rw.file.Close(); err != nil {
+			return err
+		}
+		rw.file = nil
+	}
+
+	rotated := rw.path + "." + time.Now().Format(rotatedFileTimeFormat)
+	for i := 1; fileExists(rotated) || fileExists(rotated+".gz"); i++ {
+		rotated = fmt.Sprintf("%s.%s_%d", rw.path, time.Now().Format(rotatedFileTimeFormat), i)
+	}
+
+	err := os.Rename(rw.path, rotated)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := rw.open(); err != nil {
+		return err
+	}
+
+	if err == nil && (rw.compress || rw.maxAge > 0 || rw.maxBackups > 0) {
+		rw.wg.Add(1)
+		go func() {
+			defer rw.wg.Done()
+			if rw.compress {
+				_ = gzipFile(rotated)
+			}
+			_ = rw.prune()
+		}()
+	}
+
+	return nil
+}
+
+// open opens the file at path, creating its directory if needed.
+func (rw *RotatingWriter) open() error {
+	if err := os.MkdirAll(filepath.Dir(rw.path), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(rw.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	rw.file, rw.size, rw.openedAt = file, info.Size(), time.Now()
+	return nil
+}
+
+// prune removes the rotated files older than maxAge and all but the
+// maxBackups most recent ones. Only the files named by rotate are considered,
+// a rotated file and its compressed copy counting as a single backup.
+func (rw *RotatingWriter) prune() error {
+	entries, err := os.ReadDir(filepath.Dir(rw.path))
+	if err != nil {
+		return err
+	}
+
+	// backups maps the suffix of every rotation to its files
+	backups := make(map[string][]string)
+	for _, entry := range entries {
+		if suffix, ok := rw.rotatedSuffix(entry.Name()); ok && entry.Type().IsRegular() {
+			backups[suffix] = append(backups[suffix], filepath.Join(filepath.Dir(rw.path), entry.Name()))
+		}
+	}
+
+	// rotated names embed a sortable timestamp
+	suffixes := make([]string, 0, len(backups))
+	for suffix := range backups {
+		suffixes = append(suffixes, suffix)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(suffixes)))
+
+	var errs []error
+	for i, suffix := range suffixes {
+		expired := rw.maxBackups > 0 && i >= rw.maxBackups
+		for _, name := range backups[suffix] {
+			if info, err := os.Stat(name); err == nil && rw.maxAge > 0 && time.Since(info.ModTime()) > rw.maxAge {
+				expired = true
+			}
+		}
+		if !expired {
+			continue
+		}
+		for _, name := range backups[suffix] {
+			if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// rotatedSuffix returns the suffix added to the name of the file by rotate, its
+// timestamp and counter without the ".gz" extension, and false if name is not
+// the name of a rotated file.
+func (rw *RotatingWriter) rotatedSuffix(name string) (string, bool) {
+	suffix, ok := strings.CutPrefix(name, filepath.Base(rw.path)+".")
+	if !ok {
+		return "", false
+	}
+	suffix = strings.TrimSuffix(suffix, ".gz")
+
+	timestamp, counter, found := strings.Cut(suffix, "_")
+	if found {
+		if _, err := strconv.Atoi(counter); err != nil || strings.HasPrefix(counter, "+") || strings.HasPrefix(counter, "-") {
+			return "", false
+		}
+	}
+	if _, err := time.Parse(rotatedFileTimeFormat, timestamp); err != nil {
+		return "", false
+	}
+	return suffix, true
+}
+
+// gzipFile compresses the file at path into path + ".gz" and removes it.
+func gzipFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := path + ".gz.tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(out)
+	_, err = io.Copy(zw, in)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, path+".gz"); err != nil {
+		return err
+	}
+	in.Close()
+	return os.Remove(path)
+}
+
+// fileExists reports whether a file exists at path.
+func fileExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// NewTeeWriter returns an io.Writer duplicating its writes to all the given
+// writers, e.g. a RotatingWriter and os.Stdout. Unlike io.MultiWriter, a failing
+// writer does not stop the others from receiving the data; the first error is
+// returned once every writer has been written to.
+func NewTeeWriter(writers ...io.Writer) io.Writer {
+	return teeWriter(writers)
+}
+
+// teeWriter is the io.Writer returned by NewTeeWriter.
+type teeWriter []io.Writer
+
+// Write writes p to every writer.
+func (tw teeWriter) Write(p []byte) (int, error) {
+	var first error
+	for _, w := range tw {
+		if _, err := w.Write(p); err != nil && first == nil {
+			first = err
+		}
+	}
+	if first != nil {
+		return 0, first
+	}
+	return len(p), nil
+}
@@ -0,0 +1,161 @@
+package gorigumi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestRotatingWriter_Write tests that the file is rotated once it exceeds its maximum
+// size and once it gets older than the rotation interval.
+func TestRotatingWriter_Write(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	rw, err := NewRotatingWriter(path, 10, 0, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rw.Write([]byte("12345678"))
+	rw.Write([]byte("12345678"))
+	rw.Close()
+
+	if files, _ := filepath.Glob(path + ".*"); len(files) != 1 {
+		t.Errorf("expected one rotated file, got %v", files)
+	}
+
+	if _, err := rw.Write([]byte("x")); err == nil {
+		t.Error("expected error writing to a closed file")
+	}
+
+	timed := filepath.Join(dir, "timed.log")
+	rw, err = NewRotatingWriter(timed, 0, 0, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rw.Close()
+	rw.interval = time.Millisecond
+
+	rw.Write([]byte("a"))
+	time.Sleep(5 * time.Millisecond)
+	rw.Write([]byte("b"))
+
+	if files, _ := filepath.Glob(timed + ".*"); len(files) != 1 {
+		t.Errorf("expected one rotated file, got %v", files)
+	}
+
+	data, _ := os.ReadFile(timed)
+	if string(data) != "b" {
+		t.Errorf("expected current file to contain b, got %q", data)
+	}
+}
+
+// TestRotatingWriter_Backups tests that rotated files are compressed and that only
+// the most recent backups are kept.
+func TestRotatingWriter_Backups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	rw, err := NewRotatingWriter(path, 0, 0, 2, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{"one\n", "two\n", "three\n", "four\n"} {
+		rw.Write([]byte(line))
+		if err := rw.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+		// wait for the background compression before the next rotation
+		rw.wg.Wait()
+	}
+	rw.Close()
+
+	files, _ := filepath.Glob(path + ".*.gz")
+	if len(files) != 2 {
+		t.Fatalf("expected two compressed backups, got %v", files)
+	}
+
+	f, err := os.Open(files[len(files)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(zr)
+	if string(data) != "four\n" {
+		t.Errorf("expected the most recent backup to contain four, got %q", data)
+	}
+}
+
+// TestRotatingWriter_prune tests that only the files named by the rotations are
+// pruned, and that a rotated file and its compressed copy count as one backup.
+func TestRotatingWriter_prune(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	files := []string{
+		"app.log.20250101T000000.000.gz",
+		"app.log.20250102T000000.000",
+		"app.log.20250103T000000.000", "app.log.20250103T000000.000.gz",
+		"app.log.20250103T000000.000_1.gz",
+		"app.log.bak", "app.log.old.gz", "app.log.20250101T000000.000.gz.tmp", "app.log.20250101T000000.000_x",
+	}
+	for _, name := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("entry\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rw := &RotatingWriter{path: path, maxBackups: 2}
+	if err := rw.prune(); err != nil {
+		t.Fatal(err)
+	}
+
+	kept := []string{
+		"app.log.20250103T000000.000", "app.log.20250103T000000.000.gz",
+		"app.log.20250103T000000.000_1.gz",
+		"app.log.bak", "app.log.old.gz", "app.log.20250101T000000.000.gz.tmp", "app.log.20250101T000000.000_x",
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != len(kept) {
+		t.Errorf("expected %d files to be kept, got %d", len(kept), len(entries))
+	}
+	for _, name := range kept {
+		if !fileExists(filepath.Join(dir, name)) {
+			t.Errorf("expected %s to be kept", name)
+		}
+	}
+}
+
+// failingWriter is an io.Writer that always fails.
+type failingWriter struct{}
+
+// Write returns an error.
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+// TestNewTeeWriter tests that every writer receives the data even if one of them fails.
+func TestNewTeeWriter(t *testing.T) {
+	var a, b bytes.Buffer
+	w := NewTeeWriter(&a, failingWriter{}, &b)
+
+	if _, err := w.Write([]byte("foo")); err == nil {
+		t.Error("expected error to be returned but got none")
+	}
+
+	if a.String() != "foo" || b.String() != "foo" {
+		t.Errorf("expected both writers to receive foo, got %q and %q", a.String(), b.String())
+	}
+}
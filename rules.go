@@ -0,0 +1,298 @@
+package gorigumi
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// rule is a validation rule of the validate tag, with its parameter if any,
+// e.g. min=3.
+type rule struct {
+	name  string
+	param string
+}
+
+// ruleCache holds the parsed validate tags, by tag.
+var ruleCache sync.Map // map[string][]rule
+
+// Validate validates the fields of the struct v, or of the struct v points
+// to, according to their validate tag, a comma-separated list of rules:
+//
+//	type Signup struct {
+//		Email string   `json:"email" validate:"required,email"`
+//		Name  string   `json:"name" validate:"required,min=2,max=50"`
+//		Plan  string   `json:"plan" validate:"oneof=free pro"`
+//		Site  *string  `json:"site" validate:"url"`
+//		Tags  []string `json:"tags" validate:"max=5"`
+//	}
+//
+// The rules are:
+//   - required: the value is not the zero value, nor an empty slice or map
+//   - min=n and max=n: the length of strings, in characters, of slices and
+//     of maps, or the value of numbers, is at least or at most n
+//   - email: the string is an email address, without display name
+//   - url: the string is an absolute URL with a host
+//   - oneof=a b c: the string or number is one of the space-separated values
+//
+// Rules other than required are skipped for nil pointers and empty strings, so
+// optional fields are only checked when set. Numbers, slices and maps are
+// always checked, so min=1 rejects 0 and empty slices: use a pointer for an
+// optional number. Nested structs, and the structs of slices, arrays
+// and maps, are validated too, and their invalid fields named after their path,
+// e.g. "items[2].quantity". Fields are named like in the JSON body, after
+// their json tag or the FieldNaming of the Tools struct. Each field reports
+// its first failing rule.
+//
+// Validate returns the field errors and false if v is invalid, or nil and true.
+// It panics if a tag has an unknown rule or an invalid parameter, as it is a
+// programming error. Set ValidateTags to validate the values decoded by
+// JSONRead, and write the errors with JSONValidationError.
+func (t *Tools) Validate(v any) (ValidationErrors, bool) {
+	var errs ValidationErrors
+	t.validateValue(reflect.ValueOf(v), "", &errs)
+	return errs, len(errs) == 0
+}
+
+// JSONValidationError writes errs with the status 422 Unprocessable Entity and
+// the optional headers. The Data of the response maps the invalid fields to
+// their message, and errors of the whole body have an empty field name:
+//
+//	{"error":true,"message":"validation failed: email: is required","code":"validation_failed",
+//	 "data":{"email":"is required"}}
+func (t *Tools) JSONValidationError(w http.ResponseWriter, errs ValidationErrors, headers ...http.Header) error {
+	fields := make(map[string]string, len(errs))
+	for _, fe := range errs {
+		if _, ok := fields[fe.Field]; !ok {
+			fields[fe.Field] = fe.Message
+		}
+	}
+	res := JSONResponse{Error: true, Message: errs.Error(), Code: CodeValidationFailed, Data: fields}
+	return t.JSONWrite(w, http.StatusUnprocessableEntity, res, headers...)
+}
+
+// validateValue appends the field errors of the structs of v to errs. path
+// is the name of v in the JSON body.
+func (t *Tools) validateValue(v reflect.Value, path string, errs *ValidationErrors) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		typ := v.Type()
+		for i := range typ.NumField() {
+			f := typ.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			fv := v.Field(i)
+			if f.Anonymous && name == "" {
+				// promoted fields are named like the fields of v
+				t.validateValue(fv, path, errs)
+				continue
+			}
+			if name == "" {
+				name = t.FieldNaming.Name(f.Name)
+			}
+			if path != "" {
+				name = path + "." + name
+			}
+
+			if tag := f.Tag.Get("validate"); tag != "" {
+				if message := checkRules(fv, parseRules(tag)); message != "" {
+					*errs = append(*errs, FieldError{Field: name, Message: message})
+					continue
+				}
+			}
+			t.validateValue(fv, name, errs)
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			t.validateValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+
+	case reflect.Map:
+		keys := v.MapKeys()
+		slices.SortFunc(keys, func(a, b reflect.Value) int {
+			return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+		})
+		for _, key := range keys {
+			t.validateValue(v.MapIndex(key), fmt.Sprintf("%s[%v]", path, key), errs)
+		}
+	}
+}
+
+// parseRules returns the rules of a validate tag, caching them. It panics
+// if the tag has an unknown rule or an invalid parameter.
+func parseRules(tag string) []rule {
+	if rules, ok := ruleCache.Load(tag); ok {
+		return rules.([]rule)
+	}
+
+	var rules []rule
+	for _, s := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(s), "=")
+		switch name {
+		case "required", "email", "url":
+			if param != "" {
+				panic(fmt.Sprintf("gorigumi: validate rule %q takes no parameter", name))
+			}
+		case "min", "max":
+			if _, err := strconv.ParseFloat(param, 64); err != nil {
+				panic(fmt.Sprintf("gorigumi: validate rule %q needs a number, got %q", name, param))
+			}
+		case "oneof":
+			if strings.TrimSpace(param) == "" {
+				panic(`gorigumi: validate rule "oneof" needs values`)
+			}
+		default:
+			panic(fmt.Sprintf("gorigumi: unknown validate rule %q", name))
+		}
+		rules = append(rules, rule{name: name, param: param})
+	}
+	ruleCache.Store(tag, rules)
+	return rules
+}
+
+// checkRules returns the message of the first rule v breaks, or "" if it
+// follows them all.
+func checkRules(v reflect.Value, rules []rule) string {
+	for _, r := range rules {
+		if r.name == "required" {
+			if isEmptyField(v) {
+				return "is required"
+			}
+			continue
+		}
+		if isUnsetField(v) {
+			// optional value not set
+			return ""
+		}
+		if message := checkRule(indirect(v), r); message != "" {
+			return message
+		}
+	}
+	return ""
+}
+
+// checkRule returns the message of r if the non-empty value v breaks it, or "".
+func checkRule(v reflect.Value, r rule) string {
+	switch r.name {
+	case "min", "max":
+		limit, _ := strconv.ParseFloat(r.param, 64)
+		size, unit, ok := measure(v)
+		if !ok {
+			return ""
+		}
+		if r.name == "min" && size < limit {
+			return strings.TrimSpace("must be at least " + r.param + " " + plural(unit, limit))
+		}
+		if r.name == "max" && size > limit {
+			return strings.TrimSpace("must be at most " + r.param + " " + plural(unit, limit))
+		}
+
+	case "email":
+		if v.Kind() == reflect.String && !isEmail(v.String()) {
+			return "must be a valid email address"
+		}
+
+	case "url":
+		if v.Kind() == reflect.String {
+			u, err := url.Parse(v.String())
+			if err != nil || u.Scheme == "" || u.Host == "" {
+				return "must be a valid URL"
+			}
+		}
+
+	case "oneof":
+		values := strings.Fields(r.param)
+		if !slices.Contains(values, fmt.Sprint(v.Interface())) {
+			return "must be one of: " + strings.Join(values, ", ")
+		}
+	}
+	return ""
+}
+
+// measure returns the size min and max compare to: the length of strings,
+// slices, arrays and maps, or the value of numbers, with its unit.
+func measure(v reflect.Value) (float64, string, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), "character", true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), "item", true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), "", true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), "", true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), "", true
+	}
+	return 0, "", false
+}
+
+// plural returns unit, in the plural unless n is 1.
+func plural(unit string, n float64) string {
+	if unit == "" || n == 1 {
+		return unit
+	}
+	return unit + "s"
+}
+
+// isEmptyField reports whether the field value v is unset: the zero value, a
+// nil pointer, or an empty slice or map.
+func isEmptyField(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+// isUnsetField reports whether the optional field value v was not set, so its
+// rules other than required are skipped: a nil pointer or interface, or an empty
+// string.
+func isUnsetField(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	case reflect.String:
+		return v.Len() == 0
+	}
+	return false
+}
+
+// indirect returns the value v points to, if it is a pointer.
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v
+}
+
+// isEmail reports whether s is an email address without display name, such
+// as a@example.com.
+func isEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	if err != nil || addr.Name != "" || addr.Address != s {
+		return false
+	}
+	_, domain, ok := strings.Cut(s, "@")
+	return ok && strings.Contains(domain, ".")
+}
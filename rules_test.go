@@ -0,0 +1,124 @@
+package gorigumi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// ruleAddress is a nested struct validated by its tags.
+type ruleAddress struct {
+	City string `json:"city" validate:"required"`
+}
+
+// ruleItem is an item of a slice validated by its tags.
+type ruleItem struct {
+	Quantity int `json:"quantity" validate:"min=1,max=10"`
+}
+
+// ruleSignup is a request body validated by its tags.
+type ruleSignup struct {
+	Email   string       `json:"email" validate:"required,email"`
+	Name    string       `json:"name" validate:"required,min=2,max=5"`
+	Plan    string       `json:"plan" validate:"oneof=free pro"`
+	Site    *string      `json:"site" validate:"url"`
+	Tags    []string     `json:"tags" validate:"max=2"`
+	Age     int          `validate:"max=130"`
+	Address *ruleAddress `json:"address"`
+	Items   []ruleItem   `json:"items"`
+}
+
+// ruleTests is a slice of structs that hold the name of the test, the value to
+// validate and the expected field errors
+var ruleTests = []struct {
+	name   string
+	value  ruleSignup
+	errors []string
+}{
+	{"valid", ruleSignup{Email: "a@example.com", Name: "Ana", Plan: "pro"}, nil},
+	{"required", ruleSignup{}, []string{"email: is required", "name: is required"}},
+	{"email", ruleSignup{Email: "Ana <a@example.com>", Name: "Ana"}, []string{"email: must be a valid email address"}},
+	{"min", ruleSignup{Email: "a@example.com", Name: "A"}, []string{"name: must be at least 2 characters"}},
+	{"max in characters", ruleSignup{Email: "a@example.com", Name: "Zoë Zoë"}, []string{"name: must be at most 5 characters"}},
+	{"oneof", ruleSignup{Email: "a@example.com", Name: "Ana", Plan: "gold"}, []string{"plan: must be one of: free, pro"}},
+	{"url", ruleSignup{Email: "a@example.com", Name: "Ana", Site: new(string)}, []string{"site: must be a valid URL"}},
+	{"max items", ruleSignup{Email: "a@example.com", Name: "Ana", Tags: []string{"a", "b", "c"}}, []string{"tags: must be at most 2 items"}},
+	{"max number", ruleSignup{Email: "a@example.com", Name: "Ana", Age: 200}, []string{"Age: must be at most 130"}},
+	{"min zero", ruleSignup{Email: "a@example.com", Name: "Ana", Items: []ruleItem{{Quantity: 0}}}, []string{"items[0].quantity: must be at least 1"}},
+	{"nested", ruleSignup{
+		Email: "a@example.com", Name: "Ana",
+		Address: &ruleAddress{},
+		Items:   []ruleItem{{Quantity: 1}, {Quantity: 11}},
+	}, []string{"address.city: is required", "items[1].quantity: must be at most 10"}},
+}
+
+// TestTools_Validate tests that Validate reports the fields breaking the rules
+// of their validate tag.
+func TestTools_Validate(t *testing.T) {
+	testTools := New()
+	for _, tt := range ruleTests {
+		errs, ok := testTools.Validate(&tt.value)
+		if ok != (tt.errors == nil) {
+			t.Errorf("%s: expected valid %t, got %t", tt.name, tt.errors == nil, ok)
+		}
+		var got []string
+		for _, fe := range errs {
+			got = append(got, fe.Error())
+		}
+		if strings.Join(got, "; ") != strings.Join(tt.errors, "; ") {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.errors, got)
+		}
+	}
+}
+
+// TestTools_Validate_unknownRule tests that Validate panics on unknown rules.
+func TestTools_Validate_unknownRule(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+	New().Validate(struct {
+		Name string `validate:"requird"`
+	}{})
+}
+
+// TestTools_JSONRead_validateTags tests that JSONRead validates decoded values by
+// their tags when ValidateTags is set.
+func TestTools_JSONRead_validateTags(t *testing.T) {
+	testTools := New()
+	testTools.ValidateTags = true
+
+	var s ruleSignup
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"email":"a@example.com"}`))
+	err := testTools.JSONRead(httptest.NewRecorder(), req, &s)
+
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Field != "name" {
+		t.Errorf("expected the name to be required, got %v", err)
+	}
+}
+
+// TestTools_JSONValidationError tests that JSONValidationError writes a 422
+// response mapping the invalid fields to their message.
+func TestTools_JSONValidationError(t *testing.T) {
+	rr := httptest.NewRecorder()
+	errs := ValidationErrors{{Field: "email", Message: "is required"}, {Field: "name", Message: "is required"}}
+	if err := New().JSONValidationError(rr, errs); err != nil {
+		t.Fatal(err)
+	}
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status 422, got %d", rr.Code)
+	}
+	var res JSONResponseOf[map[string]string]
+	if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if !res.Error || res.Code != CodeValidationFailed || len(res.Data) != 2 || res.Data["email"] != "is required" {
+		t.Errorf("unexpected response %+v", res)
+	}
+}
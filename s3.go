@@ -0,0 +1,441 @@
+package gorigumi
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/drunkleen/gorigumi/download"
+)
+
+const (
+	// defaultS3Region is the default region used to sign S3 requests
+	// it is inlcuded in the UploadFilesToS3 method
+	defaultS3Region string = "us-east-1"
+
+	// s3UnsignedPayload is the payload hash used for streamed uploads, whose
+	// body is not hashed before it is sent
+	s3UnsignedPayload string = "UNSIGNED-PAYLOAD"
+
+	// s3EmptyPayload is the payload hash of requests without a body
+	s3EmptyPayload string = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// S3Options configures the S3-compatible destination of UploadFilesToS3.
+// Objects are addressed path-style, as Endpoint/Bucket/Key, which AWS S3,
+// MinIO and Cloudflare R2 all support.
+type S3Options struct {
+	// Endpoint is the base URL of the service, e.g. https://s3.us-east-1.amazonaws.com
+	// or http://localhost:9000 for MinIO
+	Endpoint string
+	// Region is the region used to sign requests. Default to us-east-1
+	Region string
+	// Bucket is the name of the destination bucket
+	Bucket string
+	// Prefix is prepended to the key of every uploaded object
+	Prefix string
+	// AccessKeyID and SecretAccessKey are the credentials used to sign requests
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is the optional token of temporary credentials
+	SessionToken string
+	// Client is the HTTP client used to send requests. Default to http.DefaultClient
+	Client *http.Client
+}
+
+// UploadFilesToS3 reads the multipart body of r part by part and streams every
+// file in it to an S3-compatible bucket with a PUT Object request signed with AWS
+// Signature Version 4, so files are never buffered in memory or temporary files.
+// Files are checked against AllowedFileTypes, MaxFileSize, MaxFilesPerRequest and
+// MaxFileSizePerFile like with UploadFiles. Parts declaring their size with a
+// Content-Length header are sent with that length; others are sent with chunked
+// encoding, which MinIO accepts but AWS S3 does not. The requests are canceled
+// along with the context of r. The optional rename argument, true by default,
+// stores objects under a random name. The returned records hold the object key
+// and the ETag returned by the service.
+func (t *Tools) UploadFilesToS3(r *http.Request, opts S3Options, rename ...bool) ([]*UploadedFile, error) {
+	renameFile := true
+	if len(rename) > 0 {
+		renameFile = rename[0]
+	}
+
+	if opts.Endpoint == "" || opts.Bucket == "" {
+		return nil, errors.New("s3: endpoint and bucket are required")
+	}
+
+	if t.MaxFileSize == 0 {
+		t.MaxFileSize = defaultMaxFileSize
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	var uploadedFiles []*UploadedFile
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return uploadedFiles, disconnectError(r, err)
+		}
+		if part.FileName() == "" {
+			part.Close()
+			continue
+		}
+
+		if t.MaxFilesPerRequest > 0 && len(uploadedFiles) >= t.MaxFilesPerRequest {
+			part.Close()
+			return uploadedFiles, WithErrorCode(fmt.Errorf("too many files in request, the maximum is %d", t.MaxFilesPerRequest), CodeTooManyFiles)
+		}
+
+		uploadedFile, err := t.uploadToS3(r, part, opts, renameFile)
+		part.Close()
+		if err != nil {
+			err = disconnectError(r, err)
+			t.countUploadError(r, err)
+			return uploadedFiles, err
+		}
+		uploadedFiles = append(uploadedFiles, uploadedFile)
+	}
+
+	return uploadedFiles, nil
+}
+
+// uploadToS3 checks the type of a single file part and streams it to the bucket.
+// The request fails if the part is too big or fewer bytes than declared by its
+// Content-Length header were received.
+func (t *Tools) uploadToS3(r *http.Request, part *multipart.Part, opts S3Options, renameFile bool) (*UploadedFile, error) {
+	limit := int64(t.MaxFileSize)
+	if t.MaxFileSizePerFile > 0 {
+		limit = min(limit, int64(t.MaxFileSizePerFile))
+	}
+	tooLarge := WithErrorCode(fmt.Errorf("file %q is too big, the maximum size is %d bytes", part.FileName(), limit), CodeFileTooLarge)
+
+	declared := int64(-1)
+	if v := part.Header.Get("Content-Length"); v != "" {
+		var err error
+		if declared, err = strconv.ParseInt(v, 10, 64); err != nil || declared < 0 {
+			return nil, fmt.Errorf("file %q has an invalid Content-Length %q", part.FileName(), v)
+		}
+		if declared > limit {
+			return nil, tooLarge
+		}
+	}
+
+	buff := make([]byte, 512)
+	n, err := io.ReadFull(part, buff)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	contentType, err := t.checkFileType(part.FileName(), buff[:n])
+	if err != nil {
+		return nil, err
+	}
+
+	file := UploadedFile{
+		OriginalFileName: part.FileName(),
+		NewFileName:      t.newFileName(part.FileName(), renameFile),
+	}
+	file.Key = file.NewFileName
+	if opts.Prefix != "" {
+		file.Key = strings.TrimSuffix(opts.Prefix, "/") + "/" + file.NewFileName
+	}
+
+	// oversized parts fail the read, which aborts the request before the
+	// object is stored
+	body := &sizeLimitReader{r: io.MultiReader(bytes.NewReader(buff[:n]), part), limit: limit, err: tooLarge}
+	checkSize := func(err error) error {
+		switch {
+		case body.n > limit:
+			return tooLarge
+		case err != nil:
+			return err
+		case declared >= 0 && body.n != declared:
+			return fmt.Errorf("%w: file %q: declared %d bytes, received %d", ErrUploadTruncated, file.OriginalFileName, declared, body.n)
+		}
+		return nil
+	}
+
+	if IsDryRun(r.Context()) {
+		_, err := io.Copy(io.Discard, body)
+		if err := checkSize(err); err != nil {
+			return nil, err
+		}
+		file.FileSize = body.n
+		return &file, nil
+	}
+
+	objectURL := strings.TrimSuffix(opts.Endpoint, "/") + "/" + s3URIEncode(opts.Bucket, true) + "/" + s3URIEncode(file.Key, false)
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodPut, objectURL, io.NopCloser(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = declared
+	req.Header.Set("Content-Type", contentType)
+	signS3Request(req, opts, s3UnsignedPayload, t.clock().Now())
+
+	client := opts.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, checkSize(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		if body.n > limit {
+			return nil, tooLarge
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("s3: uploading %s: %s: %s", file.Key, resp.Status, strings.TrimSpace(string(body)))
+	}
+	if err := checkSize(nil); err != nil {
+		return nil, err
+	}
+
+	file.FileSize = body.n
+	file.ETag = strings.Trim(resp.Header.Get("ETag"), `"`)
+	return &file, nil
+}
+
+// sizeLimitReader counts the bytes read from r, and returns err once more than
+// limit bytes were read.
+type sizeLimitReader struct {
+	r     io.Reader
+	n     int64
+	limit int64
+	err   error
+}
+
+// Read implements io.Reader.
+func (l *sizeLimitReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if l.n > l.limit {
+		return n, l.err
+	}
+	return n, err
+}
+
+// S3ReadRange returns a reader of length bytes of the object key, starting at
+// offset off, fetched with a ranged GET Object request, so only the requested
+// bytes leave the bucket. A negative length reads up to the end of the object.
+// key is the full key of the object, as in UploadedFile.Key. The caller must
+// close the returned reader.
+func (t *Tools) S3ReadRange(ctx context.Context, opts S3Options, key string, off, length int64) (io.ReadCloser, error) {
+	if off < 0 || length == 0 {
+		return nil, errors.New("s3: invalid range")
+	}
+
+	byteRange := fmt.Sprintf("bytes=%d-", off)
+	if length > 0 {
+		byteRange += strconv.FormatInt(off+length-1, 10)
+	}
+
+	resp, err := t.getS3Object(ctx, http.MethodGet, opts, key, http.Header{"Range": {byteRange}})
+	if err != nil {
+		return nil, err
+	}
+	// a service ignoring the range sends the whole object, which is only
+	// right if the whole object was asked for
+	if resp.StatusCode == http.StatusPartialContent || (resp.StatusCode == http.StatusOK && off == 0 && length < 0) {
+		return resp.Body, nil
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return nil, fmt.Errorf("s3: reading %s: %s: %s", key, resp.Status, strings.TrimSpace(string(body)))
+}
+
+// DownloadFromS3 sends the object key to the client like DownloadFile, with a
+// Content-Disposition header naming the file name. The Range and conditional
+// headers of r are forwarded to the bucket, so range requests are answered with
+// the requested bytes only, without fetching the whole object to the server,
+// and the service answers 304 Not Modified and 412 Precondition Failed itself.
+// key is the full key of the object, as in UploadedFile.Key.
+func (t *Tools) DownloadFromS3(w http.ResponseWriter, r *http.Request, opts S3Options, key, name string) {
+	method := http.MethodGet
+	if r.Method == http.MethodHead {
+		method = http.MethodHead
+	}
+
+	forwarded := make(http.Header)
+	for _, h := range s3ForwardedRequestHeaders {
+		if v := r.Header.Get(h); v != "" {
+			forwarded.Set(h, v)
+		}
+	}
+
+	resp, err := t.getS3Object(r.Context(), method, opts, key, forwarded)
+	if err != nil {
+		t.JSONError(w, err, http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusPartialContent, http.StatusNotModified,
+		http.StatusPreconditionFailed, http.StatusRequestedRangeNotSatisfiable:
+	case http.StatusNotFound:
+		t.JSONError(w, errors.New("file not found"), http.StatusNotFound)
+		return
+	default:
+		t.JSONError(w, fmt.Errorf("s3: reading %s: %s", key, resp.Status), http.StatusBadGateway)
+		return
+	}
+
+	for _, h := range s3ForwardedResponseHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			w.Header().Set(h, v)
+		}
+	}
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusPartialContent {
+		w.Header().Set("Content-Disposition", download.ContentDisposition(t.DefaultDisposition, name))
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// s3ForwardedRequestHeaders are the request headers DownloadFromS3 forwards to
+// the bucket.
+var s3ForwardedRequestHeaders = []string{"Range", "If-Range", "If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since"}
+
+// s3ForwardedResponseHeaders are the response headers DownloadFromS3 copies
+// from the bucket.
+var s3ForwardedResponseHeaders = []string{"Content-Type", "Content-Length", "Content-Range", "Accept-Ranges", "ETag", "Last-Modified"}
+
+// getS3Object sends a signed GET or HEAD Object request for key with the
+// given headers.
+func (t *Tools) getS3Object(ctx context.Context, method string, opts S3Options, key string, headers http.Header) (*http.Response, error) {
+	if opts.Endpoint == "" || opts.Bucket == "" {
+		return nil, errors.New("s3: endpoint and bucket are required")
+	}
+
+	objectURL := strings.TrimSuffix(opts.Endpoint, "/") + "/" + s3URIEncode(opts.Bucket, true) + "/" + s3URIEncode(key, false)
+	req, err := http.NewRequestWithContext(ctx, method, objectURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header[k] = v
+	}
+	signS3Request(req, opts, s3EmptyPayload, t.clock().Now())
+
+	client := opts.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+// signS3Request adds the AWS Signature Version 4 Authorization header to req.
+// The host and all headers already set on req are signed.
+func signS3Request(req *http.Request, opts S3Options, payloadHash string, now time.Time) {
+	region := opts.Region
+	if region == "" {
+		region = defaultS3Region
+	}
+
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	scope := now.Format("20060102") + "/" + region + "/s3/aws4_request"
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if opts.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", opts.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.Join(v, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + strings.TrimSpace(headers[k]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+opts.SecretAccessKey), now.Format("20060102"))
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		opts.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// s3CanonicalQuery returns the query parameters sorted and encoded as required
+// by Signature Version 4.
+func s3CanonicalQuery(query url.Values) string {
+	var params []string
+	for k, values := range query {
+		for _, v := range values {
+			params = append(params, s3URIEncode(k, true)+"="+s3URIEncode(v, true))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+// s3URIEncode percent-encodes every byte of s except the unreserved characters
+// and, unless encodeSlash is true, the slash.
+func s3URIEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key.
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,56 @@
+package gorigumi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/drunkleen/gorigumi/jsonschema"
+	"github.com/drunkleen/gorigumi/jsonx"
+)
+
+// JSONSchema is a compiled JSON Schema (draft 2020-12) validating request
+// bodies, see JSONReadWithSchema. It is an alias of jsonschema.Schema, whose
+// documentation lists the supported keywords.
+type JSONSchema = jsonschema.Schema
+
+// CompileJSONSchema compiles the JSON Schema document data, e.g. a schema
+// embedded with go:embed. It returns an error if the document is not a schema
+// or uses an unsupported keyword.
+func CompileJSONSchema(data []byte) (*JSONSchema, error) {
+	return jsonschema.Compile(data)
+}
+
+// checkSchema reads the JSON body of r, within the size limit of JSONRead, and
+// validates it against schema. If TolerantFieldNames is set, the keys of the body
+// are first renamed to the fields of jsonData, so the schema is written for these
+// names only. The body is replaced by the data read, so it can be decoded
+// afterwards.
+func (t *Tools) checkSchema(w http.ResponseWriter, r *http.Request, schema *JSONSchema, jsonData any) error {
+	var raw json.RawMessage
+	if err := jsonx.Read(w, r, &raw, t.MaxJSONSize, true); err != nil {
+		return err
+	}
+	if t.TolerantFieldNames {
+		normalized, err := jsonx.NormalizeKeys(raw, jsonData)
+		if err != nil {
+			return err
+		}
+		raw = normalized
+	}
+	r.Body = io.NopCloser(bytes.NewReader(raw))
+
+	violations, err := schema.ValidateJSON(raw)
+	if err != nil {
+		return err
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	errs := make(ValidationErrors, len(violations))
+	for i, v := range violations {
+		errs[i] = FieldError{Field: v.Path, Message: v.Message}
+	}
+	return errs
+}
@@ -0,0 +1,142 @@
+package gorigumi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// signupSchema is the contract of the signup request bodies of the schema tests.
+const signupSchema = `{
+	"type": "object",
+	"required": ["email", "password"],
+	"properties": {
+		"email": {"type": "string", "format": "email"},
+		"password": {"type": "string", "minLength": 8}
+	}
+}`
+
+// jsonReadWithSchemaTests is a slice of structs that hold the name of the test, the
+// body, the expected invalid fields and whether another error is expected
+var jsonReadWithSchemaTests = []struct {
+	name    string
+	body    string
+	fields  []string
+	wantErr bool
+}{
+	{"valid", `{"email":"a@example.com","password":"correct horse"}`, nil, false},
+	{"all violations", `{"password":"short"}`, []string{"email", "password"}, false},
+	{"wrong type", `{"email":1,"password":"correct horse"}`, []string{"email"}, false},
+	{"malformed JSON", `{"email":`, nil, true},
+}
+
+// TestTools_JSONReadWithSchema tests that JSONReadWithSchema returns every schema
+// violation of the body before decoding it.
+func TestTools_JSONReadWithSchema(t *testing.T) {
+	schema, err := CompileJSONSchema([]byte(signupSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range jsonReadWithSchemaTests {
+		var s struct {
+			Email    string `json:"email"`
+			Password string `json:"password"`
+		}
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+		err := New().JSONReadWithSchema(httptest.NewRecorder(), req, &s, schema)
+
+		var list ValidationErrors
+		switch {
+		case tt.wantErr:
+			if err == nil || errors.As(err, &list) {
+				t.Errorf("%s: expected a decoding error, got %v", tt.name, err)
+			}
+		case tt.fields == nil:
+			if err != nil || s.Email == "" {
+				t.Errorf("%s: expected the body to be decoded, got %v", tt.name, err)
+			}
+		default:
+			if !errors.As(err, &list) || ErrorCode(err) != CodeValidationFailed {
+				t.Errorf("%s: expected validation errors, got %v", tt.name, err)
+				continue
+			}
+			var fields []string
+			for _, fe := range list {
+				fields = append(fields, fe.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(tt.fields, ",") {
+				t.Errorf("%s: expected fields %v, got %v", tt.name, tt.fields, fields)
+			}
+		}
+	}
+}
+
+// TestTools_JSONRead_schemaValidator tests that JSONRead validates bodies against
+// the SchemaValidator of the Tools struct.
+func TestTools_JSONRead_schemaValidator(t *testing.T) {
+	testTools := New()
+	testTools.SchemaValidator = mustCompileSchema(t, signupSchema)
+
+	var s map[string]any
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"email":"a@example.com"}`))
+	err := testTools.JSONRead(httptest.NewRecorder(), req, &s)
+
+	var list ValidationErrors
+	if !errors.As(err, &list) || len(list) != 1 || list[0].Field != "password" || list[0].Message != "is required" {
+		t.Errorf("expected the password to be required, got %v", err)
+	}
+}
+
+// mustCompileSchema compiles the JSON Schema schema or fails the test.
+func mustCompileSchema(t *testing.T, schema string) *JSONSchema {
+	t.Helper()
+	s, err := CompileJSONSchema([]byte(schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// TestTools_JSONRead_compressedSchema tests that compressed bodies are decompressed
+// once, when they are validated against a schema before being decoded.
+func TestTools_JSONRead_compressedSchema(t *testing.T) {
+	testTools := New()
+	testTools.SchemaValidator = mustCompileSchema(t, signupSchema)
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(`{"email":"a@example.com","password":"correct horse"}`))
+	zw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/", &buf)
+	req.Header.Set("Content-Encoding", "gzip")
+	var s map[string]any
+	if err := testTools.JSONRead(httptest.NewRecorder(), req, &s); err != nil || s["email"] != "a@example.com" {
+		t.Errorf("expected the body to be decoded, got %v", err)
+	}
+}
+
+// TestTools_JSONRead_tolerantSchema tests that the keys of bodies are renamed to the
+// fields of the value before the schema is checked when TolerantFieldNames is set.
+func TestTools_JSONRead_tolerantSchema(t *testing.T) {
+	type signup struct {
+		EmailAddress string `json:"email_address"`
+		Password     string `json:"password"`
+	}
+	testTools := New()
+	testTools.TolerantFieldNames = true
+	schema := mustCompileSchema(t, `{"type":"object","required":["email_address","password"]}`)
+
+	for _, body := range []string{`{"email_address":"a@example.com","password":"x"}`, `{"emailAddress":"a@example.com","Password":"x"}`} {
+		var s signup
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		if err := testTools.JSONReadWithSchema(httptest.NewRecorder(), req, &s, schema); err != nil || s.EmailAddress != "a@example.com" {
+			t.Errorf("%s: expected the body to be decoded, got %+v (%v)", body, s, err)
+		}
+	}
+}
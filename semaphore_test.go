@@ -0,0 +1,103 @@
+package gorigumi
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestSemaphore_Acquire tests the weighted acquisition and release of a Semaphore, and
+// that a blocked acquisition gives up once its context is done.
+func TestSemaphore_Acquire(t *testing.T) {
+	s := NewSemaphore(10)
+	ctx := context.Background()
+
+	if err := s.Acquire(ctx, 7); err != nil {
+		t.Fatal(err)
+	}
+	if s.TryAcquire(4) {
+		t.Error("expected TryAcquire to fail when not enough weight is left")
+	}
+	if !s.TryAcquire(3) {
+		t.Error("expected TryAcquire to succeed when enough weight is left")
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := s.Acquire(timeoutCtx, 5); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		if err := s.Acquire(ctx, 5); err != nil {
+			t.Error(err)
+		}
+		close(acquired)
+	}()
+
+	s.Release(7)
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("expected waiter to acquire after release")
+	}
+
+	if err := s.Acquire(ctx, 11); err == nil {
+		t.Error("expected error for a weight larger than the semaphore")
+	}
+}
+
+// TestGroup_Go tests that a Group never runs more functions than its limit, and that the
+// first error cancels its context and is returned by Wait.
+func TestGroup_Go(t *testing.T) {
+	g, _ := NewGroup(context.Background(), 3)
+
+	var running, peak atomic.Int32
+	var mu sync.Mutex
+	for range 10 {
+		g.Go(func(ctx context.Context) error {
+			n := running.Add(1)
+			mu.Lock()
+			if n > peak.Load() {
+				peak.Store(n)
+			}
+			mu.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			running.Add(-1)
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		t.Error(err)
+	}
+	if peak.Load() > 3 {
+		t.Errorf("expected at most 3 concurrent functions, got %d", peak.Load())
+	}
+
+	g, ctx := NewGroup(context.Background(), 0)
+	failure := errors.New("failed")
+	g.Go(func(ctx context.Context) error { return failure })
+	g.Go(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	if err := g.Wait(); !errors.Is(err, failure) {
+		t.Errorf("expected the first error, got %v", err)
+	}
+	if ctx.Err() == nil {
+		t.Error("expected the group context to be cancelled")
+	}
+
+	g, _ = NewGroup(context.Background(), 1)
+	g.Go(func(ctx context.Context) error { panic("boom") })
+	var pe *PanicError
+	if err := g.Wait(); !errors.As(err, &pe) {
+		t.Errorf("expected a *PanicError, got %v", err)
+	}
+}
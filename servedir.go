@@ -0,0 +1,203 @@
+package gorigumi
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/drunkleen/gorigumi/download"
+)
+
+// DirListing decides how ServeDir answers requests for directories without an
+// index file.
+type DirListing int
+
+const (
+	// ListingNone answers 404 Not Found.
+	ListingNone DirListing = iota
+	// ListingJSON answers a JSON array of DirEntry values.
+	ListingJSON
+	// ListingHTML answers an HTML page linking the entries.
+	ListingHTML
+	// ListingAuto answers JSON to clients accepting application/json, and
+	// HTML to the others.
+	ListingAuto
+)
+
+// ServeDirOptions configures ServeDir.
+type ServeDirOptions struct {
+	// Listing decides how directories without an index file are answered.
+	// Default to ListingNone
+	Listing DirListing
+	// ShowHidden is a boolean that indicates if files and directories whose
+	// name starts with a dot are served and listed
+	ShowHidden bool
+	// IndexFiles are the files served for a directory, in order of
+	// preference. Default to index.html
+	IndexFiles []string
+}
+
+// DirEntry is an entry of a JSON directory listing of ServeDir.
+type DirEntry struct {
+	Name    string    `json:"name"`
+	Dir     bool      `json:"dir"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// ServeDir serves the file of root at the path of r, a safer alternative to
+// http.FileServer: the path cannot leave root, neither with ".." segments nor
+// through symbolic links, which are refused altogether if the SymlinkPolicy is
+// SymlinkRefuse, hidden files are not served unless ShowHidden is set,
+// and directories are only listed if Listing is set. Directories are served
+// their index file, and requested with a trailing slash. Files are sent with
+// http.ServeContent along with an ETag, so conditional and Range requests are
+// supported. Only the GET and HEAD methods are allowed. Mount it under a prefix
+// with http.StripPrefix.
+func (t *Tools) ServeDir(w http.ResponseWriter, r *http.Request, root string, opts ...ServeDirOptions) {
+	var options ServeDirOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	if options.IndexFiles == nil {
+		options.IndexFiles = []string{"index.html"}
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		_ = t.JSONError(w, errors.New("method not allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+
+	urlPath := path.Clean("/" + r.URL.Path)
+	if !options.ShowHidden && slices.ContainsFunc(strings.Split(urlPath, "/"), func(s string) bool {
+		return strings.HasPrefix(s, ".")
+	}) {
+		_ = t.JSONError(w, errors.New("not found"), http.StatusNotFound)
+		return
+	}
+
+	name, info, err := t.resolveInRoot(root, urlPath)
+	if err != nil {
+		_ = t.JSONError(w, errors.New("not found"), http.StatusNotFound)
+		return
+	}
+
+	if !info.IsDir() {
+		t.serveDirFile(w, r, name, info)
+		return
+	}
+
+	if !strings.HasSuffix(r.URL.Path, "/") {
+		target := path.Base(urlPath) + "/"
+		if r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+		return
+	}
+
+	for _, index := range options.IndexFiles {
+		indexName, indexInfo, err := t.resolveInRoot(root, path.Join(urlPath, index))
+		if err == nil && indexInfo.Mode().IsRegular() {
+			t.serveDirFile(w, r, indexName, indexInfo)
+			return
+		}
+	}
+
+	listing := options.Listing
+	if listing == ListingAuto {
+		listing = ListingHTML
+		if strings.Contains(r.Header.Get("Accept"), "application/json") {
+			listing = ListingJSON
+		}
+	}
+	if listing == ListingNone {
+		_ = t.JSONError(w, errors.New("not found"), http.StatusNotFound)
+		return
+	}
+
+	entries, err := readDirEntries(name, options.ShowHidden)
+	if err != nil {
+		_ = t.JSONError(w, err)
+		return
+	}
+
+	if listing == ListingJSON {
+		_ = t.JSONWrite(w, http.StatusOK, entries)
+		return
+	}
+	writeDirListing(w, urlPath, entries)
+}
+
+// serveDirFile sends the regular file name with http.ServeContent.
+func (t *Tools) serveDirFile(w http.ResponseWriter, r *http.Request, name string, info fs.FileInfo) {
+	if !info.Mode().IsRegular() {
+		_ = t.JSONError(w, errors.New("not found"), http.StatusNotFound)
+		return
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		_ = t.JSONError(w, errors.New("not found"), http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("ETag", download.FileETag(info))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
+
+// readDirEntries returns the entries of the directory name sorted by name,
+// leaving out hidden entries unless showHidden is set.
+func readDirEntries(name string, showHidden bool) ([]DirEntry, error) {
+	dirEntries, err := os.ReadDir(name)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]DirEntry, 0, len(dirEntries))
+	for _, e := range dirEntries {
+		if !showHidden && strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		entry := DirEntry{Name: e.Name(), Dir: e.IsDir(), ModTime: info.ModTime()}
+		if !e.IsDir() {
+			entry.Size = info.Size()
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// writeDirListing writes an HTML page listing entries of the directory urlPath.
+func writeDirListing(w http.ResponseWriter, urlPath string, entries []DirEntry) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	title := html.EscapeString(urlPath)
+	fmt.Fprintf(w, "<!doctype html>\n<html><head><meta charset=\"utf-8\"><title>%s</title></head><body>\n<h1>%s</h1>\n<ul>\n", title, title)
+	for _, e := range entries {
+		name := e.Name
+		if e.Dir {
+			name += "/"
+		}
+		// the ./ prefix keeps names such as "a:b" from being read as a scheme
+		href := (&url.URL{Path: "./" + name}).String()
+		fmt.Fprintf(w, "<li><a href=\"%s\">%s</a></li>\n", html.EscapeString(href), html.EscapeString(name))
+	}
+	fmt.Fprint(w, "</ul>\n</body></html>\n")
+}
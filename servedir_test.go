@@ -0,0 +1,120 @@
+package gorigumi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// serveDirTests is a slice of structs that hold the name of the test, the options, the
+// method, the path and Accept header of the request, the expected status and a string
+// the body is expected to contain
+var serveDirTests = []struct {
+	name     string
+	opts     ServeDirOptions
+	method   string
+	path     string
+	accept   string
+	status   int
+	contains string
+}{
+	{"file", ServeDirOptions{}, "GET", "/docs/a.txt", "", http.StatusOK, "alpha"},
+	{"head", ServeDirOptions{}, "HEAD", "/docs/a.txt", "", http.StatusOK, ""},
+	{"missing", ServeDirOptions{}, "GET", "/docs/z.txt", "", http.StatusNotFound, ""},
+	{"traversal", ServeDirOptions{}, "GET", "/../secret.txt", "", http.StatusNotFound, ""},
+	{"encoded traversal", ServeDirOptions{}, "GET", "/docs/..%2f..%2fsecret.txt", "", http.StatusNotFound, ""},
+	{"symlink escape", ServeDirOptions{}, "GET", "/escape/secret.txt", "", http.StatusNotFound, ""},
+	{"hidden file", ServeDirOptions{}, "GET", "/.env", "", http.StatusNotFound, ""},
+	{"hidden shown", ServeDirOptions{ShowHidden: true}, "GET", "/.env", "", http.StatusOK, "TOKEN"},
+	{"index file", ServeDirOptions{}, "GET", "/site/", "", http.StatusOK, "<h1>site</h1>"},
+	{"custom index", ServeDirOptions{IndexFiles: []string{"home.html"}}, "GET", "/site/", "", http.StatusOK, "home"},
+	{"directory redirect", ServeDirOptions{}, "GET", "/site", "", http.StatusMovedPermanently, ""},
+	{"no listing", ServeDirOptions{}, "GET", "/docs/", "", http.StatusNotFound, ""},
+	{"html listing", ServeDirOptions{Listing: ListingHTML}, "GET", "/docs/", "", http.StatusOK, `<a href="./a.txt">a.txt</a>`},
+	{"html escaping", ServeDirOptions{Listing: ListingHTML}, "GET", "/docs/", "", http.StatusOK, "&lt;b&gt;.txt"},
+	{"listing hides", ServeDirOptions{Listing: ListingJSON}, "GET", "/docs/", "", http.StatusOK, `"name":"a.txt"`},
+	{"auto json", ServeDirOptions{Listing: ListingAuto}, "GET", "/docs/", "application/json", http.StatusOK, `"name":"sub","dir":true`},
+	{"method", ServeDirOptions{}, "POST", "/docs/a.txt", "", http.StatusMethodNotAllowed, ""},
+}
+
+// TestTools_ServeDir tests serving files, index files and listings, and that paths
+// cannot leave the root.
+func TestTools_ServeDir(t *testing.T) {
+	testTools := New()
+
+	base := t.TempDir()
+	root := filepath.Join(base, "public")
+	files := map[string]string{
+		"docs/a.txt":      "alpha",
+		"docs/<b>.txt":    "bold",
+		"docs/.hidden":    "hidden",
+		"docs/sub/c.txt":  "gamma",
+		"site/index.html": "<h1>site</h1>",
+		"site/home.html":  "home",
+		".env":            "TOKEN=1",
+	}
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(base, "secret.txt"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(base, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range serveDirTests {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			testTools.ServeDir(w, r, root, tt.opts)
+		})
+		req := httptest.NewRequest(tt.method, "http://example.com/static"+tt.path, nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		rr := httptest.NewRecorder()
+		http.StripPrefix("/static", handler).ServeHTTP(rr, req)
+
+		if rr.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.status, rr.Code)
+		}
+		if !strings.Contains(rr.Body.String(), tt.contains) {
+			t.Errorf("%s: expected body to contain %q, got %q", tt.name, tt.contains, rr.Body)
+		}
+		if strings.Contains(rr.Body.String(), "hidden") || strings.Contains(rr.Body.String(), "secret") {
+			t.Errorf("%s: unexpected hidden entry in %q", tt.name, rr.Body)
+		}
+	}
+}
+
+// TestTools_ServeDir_listing tests the JSON listing entries.
+func TestTools_ServeDir_listing(t *testing.T) {
+	testTools := New()
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("alpha"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	testTools.ServeDir(rr, httptest.NewRequest("GET", "/", nil), root, ServeDirOptions{Listing: ListingJSON})
+
+	var entries []DirEntry
+	if err := json.Unmarshal(rr.Body.Bytes(), &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Name != "a.txt" || entries[0].Size != 5 || !entries[1].Dir {
+		t.Errorf("unexpected entries %+v", entries)
+	}
+}
@@ -0,0 +1,137 @@
+package gorigumi
+
+import (
+	"errors"
+	"math"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultMaxInFlight is the default maximum number of in-flight requests
+	// it is inlcuded in the LoadShedMiddleware method
+	defaultMaxInFlight int = 100
+
+	// defaultLowPriorityShare is the default share of MaxInFlight usable by
+	// low priority classes. It is inlcuded in the LoadShedMiddleware method
+	defaultLowPriorityShare float64 = 0.75
+
+	// UploadRequestClass is the class given to multipart requests by the
+	// default classifier of LoadShedMiddleware
+	UploadRequestClass = "upload"
+
+	// DefaultRequestClass is the class given to every other request by the
+	// default classifier of LoadShedMiddleware
+	DefaultRequestClass = "default"
+)
+
+// ErrOverloaded is reported to shed requests.
+var ErrOverloaded = errors.New("server is overloaded, please retry later")
+
+// LoadShedOptions configures LoadShedMiddleware.
+type LoadShedOptions struct {
+	// MaxInFlight is the maximum number of requests served concurrently.
+	// Default to 100
+	MaxInFlight int
+	// Classify returns the class of a request. By default multipart
+	// requests are in the "upload" class, every other request is in
+	// the "default" class
+	Classify func(r *http.Request) string
+	// ClassLimits holds optional per-class limits of in-flight requests
+	ClassLimits map[string]int
+	// LowPriority is the list of classes shed first when the server is
+	// saturated. Default to the "upload" class
+	LowPriority []string
+	// LowPriorityShare is the share of MaxInFlight low priority classes
+	// may use, the rest is reserved to cheaper requests. Default to 0.75
+	LowPriorityShare float64
+	// RetryAfter is the delay advertised in the Retry-After header of shed
+	// requests. Default to 1 second
+	RetryAfter time.Duration
+}
+
+// LoadShedMiddleware returns a middleware that tracks the requests in flight in next,
+// optionally by request class, and sheds the excess with a 503 Service Unavailable
+// response and a Retry-After header. When the server gets saturated, low priority
+// classes (uploads by default) are shed before cheap requests, which may use the
+// share of capacity reserved to them.
+func (t *Tools) LoadShedMiddleware(next http.Handler, opts ...LoadShedOptions) http.Handler {
+	var options LoadShedOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	if options.MaxInFlight == 0 {
+		options.MaxInFlight = defaultMaxInFlight
+	}
+	if options.Classify == nil {
+		options.Classify = classifyRequest
+	}
+	if options.LowPriority == nil {
+		options.LowPriority = []string{UploadRequestClass}
+	}
+	if options.LowPriorityShare == 0 {
+		options.LowPriorityShare = defaultLowPriorityShare
+	}
+	if options.RetryAfter == 0 {
+		options.RetryAfter = time.Second
+	}
+
+	lowPriorityLimit := int(float64(options.MaxInFlight) * options.LowPriorityShare)
+	retryAfter := strconv.Itoa(int(math.Ceil(options.RetryAfter.Seconds())))
+
+	var mu sync.Mutex
+	inFlight := 0
+	classInFlight := make(map[string]int)
+
+	acquire := func(class string) bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		limit := options.MaxInFlight
+		if slices.Contains(options.LowPriority, class) {
+			limit = lowPriorityLimit
+		}
+		if inFlight >= limit {
+			return false
+		}
+		if classLimit, ok := options.ClassLimits[class]; ok && classInFlight[class] >= classLimit {
+			return false
+		}
+
+		inFlight++
+		classInFlight[class]++
+		return true
+	}
+
+	release := func(class string) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		inFlight--
+		classInFlight[class]--
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		class := options.Classify(r)
+		if !acquire(class) {
+			w.Header().Set("Retry-After", retryAfter)
+			_ = t.JSONError(w, ErrOverloaded, http.StatusServiceUnavailable)
+			return
+		}
+		defer release(class)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// classifyRequest is the default classifier of LoadShedMiddleware.
+func classifyRequest(r *http.Request) string {
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err == nil && mediaType == "multipart/form-data" {
+		return UploadRequestClass
+	}
+	return DefaultRequestClass
+}
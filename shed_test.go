@@ -0,0 +1,109 @@
+package gorigumi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// TestTools_LoadShedMiddleware tests the LoadShedMiddleware method by holding requests in
+// flight and checking that uploads are shed before cheap requests, that cheap requests may
+// use the reserved capacity, and that shed requests get a 503 with a Retry-After header.
+func TestTools_LoadShedMiddleware(t *testing.T) {
+	testTools := New()
+
+	hold := make(chan struct{})
+	started := make(chan struct{}, 10)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-hold
+	})
+
+	handler := testTools.LoadShedMiddleware(next, LoadShedOptions{MaxInFlight: 4, LowPriorityShare: 0.5})
+
+	newRequest := func(class string) *http.Request {
+		req := httptest.NewRequest("POST", "/", nil)
+		if class == UploadRequestClass {
+			req.Header.Set("Content-Type", "multipart/form-data; boundary=foo")
+		}
+		return req
+	}
+
+	var wg sync.WaitGroup
+	serve := func(class string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handler.ServeHTTP(httptest.NewRecorder(), newRequest(class))
+		}()
+		<-started
+	}
+
+	// two uploads use the whole low priority share
+	serve(UploadRequestClass)
+	serve(UploadRequestClass)
+
+	responseRecorder := httptest.NewRecorder()
+	handler.ServeHTTP(responseRecorder, newRequest(UploadRequestClass))
+	if responseRecorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected upload to be shed with status %d, got %d", http.StatusServiceUnavailable, responseRecorder.Code)
+	}
+	if responseRecorder.Header().Get("Retry-After") != "1" {
+		t.Errorf("expected Retry-After 1, got %q", responseRecorder.Header().Get("Retry-After"))
+	}
+
+	// cheap requests still fit in the reserved capacity
+	serve(DefaultRequestClass)
+	serve(DefaultRequestClass)
+
+	responseRecorder = httptest.NewRecorder()
+	handler.ServeHTTP(responseRecorder, newRequest(DefaultRequestClass))
+	if responseRecorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected request to be shed with status %d, got %d", http.StatusServiceUnavailable, responseRecorder.Code)
+	}
+
+	close(hold)
+	wg.Wait()
+
+	responseRecorder = httptest.NewRecorder()
+	handler.ServeHTTP(responseRecorder, newRequest(UploadRequestClass))
+	<-started
+	if responseRecorder.Code != http.StatusOK {
+		t.Errorf("expected upload to be served after release, got %d", responseRecorder.Code)
+	}
+}
+
+// TestTools_LoadShedMiddlewareClassLimits tests that per-class limits are enforced
+// independently of the global limit.
+func TestTools_LoadShedMiddlewareClassLimits(t *testing.T) {
+	testTools := New()
+
+	hold := make(chan struct{})
+	started := make(chan struct{})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-hold
+	})
+
+	handler := testTools.LoadShedMiddleware(next, LoadShedOptions{
+		ClassLimits: map[string]int{"reports": 1},
+		Classify:    func(r *http.Request) string { return r.URL.Path[1:] },
+	})
+
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/reports", nil))
+		close(done)
+	}()
+	<-started
+
+	responseRecorder := httptest.NewRecorder()
+	handler.ServeHTTP(responseRecorder, httptest.NewRequest("GET", "/reports", nil))
+	if responseRecorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected class limit to shed request, got %d", responseRecorder.Code)
+	}
+
+	close(hold)
+	<-done
+}
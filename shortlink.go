@@ -0,0 +1,239 @@
+package gorigumi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/drunkleen/gorigumi/urlx"
+)
+
+const (
+	// defaultShortCodeLength is the default length of generated short codes
+	// it is inlcuded in the CreateShortLink method
+	defaultShortCodeLength int = 7
+
+	// shortCodeAttempts is the number of codes generated before giving up
+	// when they are all taken
+	shortCodeAttempts int = 5
+)
+
+var (
+	// ErrShortLinkNotFound is returned for unknown short codes
+	ErrShortLinkNotFound = errors.New("short link not found")
+	// ErrShortLinkExpired is returned for short links past their expiry or
+	// their maximum number of clicks
+	ErrShortLinkExpired = errors.New("short link expired")
+	// ErrShortCodeTaken is returned when the requested short code is used
+	ErrShortCodeTaken = errors.New("short code is already taken")
+)
+
+// ShortLink is a short code redirecting to a target URL.
+type ShortLink struct {
+	Code   string `json:"code"`
+	Target string `json:"target"`
+	// Clicks is the number of redirects made
+	Clicks    int       `json:"clicks"`
+	CreatedAt time.Time `json:"created_at"`
+	// ExpiresAt is when the link stops redirecting. Zero means never
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+	// MaxClicks is the number of redirects after which the link stops
+	// redirecting. Zero means no limit
+	MaxClicks int `json:"max_clicks,omitempty"`
+}
+
+// Expired reports whether l no longer redirects at now.
+func (l *ShortLink) Expired(now time.Time) bool {
+	return (!l.ExpiresAt.IsZero() && !now.Before(l.ExpiresAt)) || (l.MaxClicks > 0 && l.Clicks >= l.MaxClicks)
+}
+
+// ShortLinkStore stores short links. Implementations backed by a database or a
+// cache let several instances of an application share them.
+type ShortLinkStore interface {
+	// Create stores link, unless its code is taken, in which case it
+	// returns false
+	Create(ctx context.Context, link *ShortLink) (bool, error)
+	// Get returns the link of code, or ErrShortLinkNotFound
+	Get(ctx context.Context, code string) (*ShortLink, error)
+	// Click counts a redirect of the link of code at now and returns the
+	// link. It returns ErrShortLinkNotFound for unknown codes, and
+	// ErrShortLinkExpired without counting the click if the link is Expired,
+	// so the check and the count must be atomic
+	Click(ctx context.Context, code string, now time.Time) (*ShortLink, error)
+	// Delete removes the link of code
+	Delete(ctx context.Context, code string) error
+}
+
+// MemoryShortLinkStore is a ShortLinkStore keeping links in memory, suitable
+// for a single instance. The zero value is ready to use.
+type MemoryShortLinkStore struct {
+	mu    sync.Mutex
+	links map[string]ShortLink
+}
+
+// Create implements ShortLinkStore.
+func (s *MemoryShortLinkStore) Create(ctx context.Context, link *ShortLink) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.links[link.Code]; ok {
+		return false, nil
+	}
+	if s.links == nil {
+		s.links = make(map[string]ShortLink)
+	}
+	s.links[link.Code] = *link
+	return true, nil
+}
+
+// Get implements ShortLinkStore.
+func (s *MemoryShortLinkStore) Get(ctx context.Context, code string) (*ShortLink, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	link, ok := s.links[code]
+	if !ok {
+		return nil, ErrShortLinkNotFound
+	}
+	return &link, nil
+}
+
+// Click implements ShortLinkStore.
+func (s *MemoryShortLinkStore) Click(ctx context.Context, code string, now time.Time) (*ShortLink, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	link, ok := s.links[code]
+	if !ok {
+		return nil, ErrShortLinkNotFound
+	}
+	if link.Expired(now) {
+		return nil, ErrShortLinkExpired
+	}
+	link.Clicks++
+	s.links[code] = link
+	return &link, nil
+}
+
+// Delete implements ShortLinkStore.
+func (s *MemoryShortLinkStore) Delete(ctx context.Context, code string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.links, code)
+	return nil
+}
+
+// ShortLinkOptions configures CreateShortLink.
+type ShortLinkOptions struct {
+	// Code is the short code of the link. Default to a random code
+	Code string
+	// CodeLength is the length of random codes. Default to 7
+	CodeLength int
+	// TTL is how long the link redirects. Default to no expiry
+	TTL time.Duration
+	// MaxClicks is the number of redirects after which the link expires.
+	// Default to no limit
+	MaxClicks int
+	// AllowedHosts, if set, restricts the targets to paths of the site and
+	// URLs of these hosts, as checked by ValidateRedirectURL
+	AllowedHosts []string
+}
+
+// CreateShortLink stores a short link to target in ShortLinkStore, e.g. to
+// share a long download URL, and returns it. The code of the link is the last
+// segment of the path served by ShortLinkHandler. Targets must be http(s) URLs
+// or paths of the site. ShortLinkStore must be set.
+func (t *Tools) CreateShortLink(ctx context.Context, target string, opts ...ShortLinkOptions) (*ShortLink, error) {
+	if t.ShortLinkStore == nil {
+		return nil, errors.New("ShortLinkStore is not set")
+	}
+
+	var options ShortLinkOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	if options.CodeLength == 0 {
+		options.CodeLength = defaultShortCodeLength
+	}
+
+	if err := checkShortLinkTarget(target, options.AllowedHosts); err != nil {
+		return nil, err
+	}
+
+	now := t.clock().Now()
+	link := &ShortLink{Target: target, CreatedAt: now, MaxClicks: options.MaxClicks}
+	if options.TTL > 0 {
+		link.ExpiresAt = now.Add(options.TTL)
+	}
+
+	attempts := shortCodeAttempts
+	if options.Code != "" {
+		attempts = 1
+	}
+	for range attempts {
+		link.Code = options.Code
+		if link.Code == "" {
+			link.Code = t.GenerateRandomString(options.CodeLength)
+		}
+		created, err := t.ShortLinkStore.Create(ctx, link)
+		if err != nil {
+			return nil, err
+		}
+		if created {
+			return link, nil
+		}
+	}
+	return nil, ErrShortCodeTaken
+}
+
+// checkShortLinkTarget checks that target is a path of the site or an http(s)
+// URL, of one of allowedHosts if set.
+func checkShortLinkTarget(target string, allowedHosts []string) error {
+	if allowedHosts != nil {
+		return urlx.ValidateRedirect(target, allowedHosts)
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "" {
+		return urlx.ValidateRedirect(target, nil)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("short link target %q is not an http(s) URL", target)
+	}
+	return nil
+}
+
+// ShortLinkHandler returns a handler redirecting short links of ShortLinkStore
+// to their target with 302 Found, counting the clicks. The code is the path
+// value "code" when routed with a pattern such as "GET /s/{code}", or else the
+// last segment of the path. It responds 404 for unknown codes and 410 for
+// expired links. ShortLinkStore must be set.
+func (t *Tools) ShortLinkHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code := r.PathValue("code")
+		if code == "" {
+			code = path.Base(r.URL.Path)
+		}
+
+		link, err := t.ShortLinkStore.Click(r.Context(), code, t.clock().Now())
+		switch {
+		case errors.Is(err, ErrShortLinkNotFound):
+			_ = t.JSONError(w, err, http.StatusNotFound)
+			return
+		case errors.Is(err, ErrShortLinkExpired):
+			_ = t.JSONError(w, err, http.StatusGone)
+			return
+		case err != nil:
+			_ = t.JSONError(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		http.Redirect(w, r, link.Target, http.StatusFound)
+	})
+}
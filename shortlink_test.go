@@ -0,0 +1,135 @@
+package gorigumi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/drunkleen/gorigumi/toolkittest"
+)
+
+// shortLinkTargetTests is a slice of structs that hold the name of the test, the target,
+// the allowed hosts and a boolean that indicates if an error is expected
+var shortLinkTargetTests = []struct {
+	name          string
+	target        string
+	allowedHosts  []string
+	errorExpected bool
+}{
+	{"absolute URL", "https://example.com/files/report.pdf?sig=abc", nil, false},
+	{"local path", "/downloads/report.pdf", nil, false},
+	{"protocol relative", "//evil.com/", nil, true},
+	{"other scheme", "javascript:alert(1)", nil, true},
+	{"no host", "https:///path", nil, true},
+	{"allowed host", "https://cdn.example.com/a", []string{"*.example.com"}, false},
+	{"host not allowed", "https://evil.com/a", []string{"*.example.com"}, true},
+}
+
+// TestTools_CreateShortLink tests the validation of targets and the generation of codes.
+func TestTools_CreateShortLink(t *testing.T) {
+	testTools := &Tools{ShortLinkStore: &MemoryShortLinkStore{}}
+
+	for _, tt := range shortLinkTargetTests {
+		_, err := testTools.CreateShortLink(context.Background(), tt.target, ShortLinkOptions{AllowedHosts: tt.allowedHosts})
+		if tt.errorExpected != (err != nil) {
+			t.Errorf("%s: expected error %t, got %v", tt.name, tt.errorExpected, err)
+		}
+	}
+
+	link, err := testTools.CreateShortLink(context.Background(), "/a", ShortLinkOptions{CodeLength: 10})
+	if err != nil || len(link.Code) != 10 {
+		t.Errorf("expected a 10 characters code, got %v, %v", link, err)
+	}
+
+	if _, err := testTools.CreateShortLink(context.Background(), "/a", ShortLinkOptions{Code: "promo"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testTools.CreateShortLink(context.Background(), "/b", ShortLinkOptions{Code: "promo"}); !errors.Is(err, ErrShortCodeTaken) {
+		t.Errorf("expected ErrShortCodeTaken, got %v", err)
+	}
+
+	if _, err := New().CreateShortLink(context.Background(), "/a"); err == nil {
+		t.Error("expected an error without ShortLinkStore")
+	}
+}
+
+// TestTools_ShortLinkHandler tests redirects, click counting and expiry.
+func TestTools_ShortLinkHandler(t *testing.T) {
+	clock := toolkittest.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	store := &MemoryShortLinkStore{}
+	testTools := &Tools{Clock: clock, ShortLinkStore: store}
+	ctx := context.Background()
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /s/{code}", testTools.ShortLinkHandler())
+	visit := func(code string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", "/s/"+code, nil))
+		return rr
+	}
+
+	timed, err := testTools.CreateShortLink(ctx, "https://example.com/report.pdf", ShortLinkOptions{TTL: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	limited, err := testTools.CreateShortLink(ctx, "/welcome", ShortLinkOptions{MaxClicks: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := visit(timed.Code)
+	if rr.Code != http.StatusFound || rr.Header().Get("Location") != "https://example.com/report.pdf" {
+		t.Errorf("expected redirect to the target, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	if code := visit("unknown").Code; code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", code)
+	}
+
+	for i := range 3 {
+		expected := http.StatusFound
+		if i == 2 {
+			expected = http.StatusGone
+		}
+		if code := visit(limited.Code).Code; code != expected {
+			t.Errorf("click %d: expected status %d, got %d", i+1, expected, code)
+		}
+	}
+	if link, _ := store.Get(ctx, limited.Code); link.Clicks != 2 {
+		t.Errorf("expected 2 clicks, got %d", link.Clicks)
+	}
+
+	clock.Advance(time.Hour)
+	if code := visit(timed.Code).Code; code != http.StatusGone {
+		t.Errorf("expected expired link to answer 410, got %d", code)
+	}
+	if link, _ := store.Get(ctx, timed.Code); link.Clicks != 1 {
+		t.Errorf("expected 1 click, got %d", link.Clicks)
+	}
+}
+
+// TestMemoryShortLinkStore tests that links are copied in and out of the store.
+func TestMemoryShortLinkStore(t *testing.T) {
+	var store MemoryShortLinkStore
+	ctx := context.Background()
+
+	link := &ShortLink{Code: "abc", Target: "/a"}
+	if ok, err := store.Create(ctx, link); !ok || err != nil {
+		t.Fatalf("expected link to be created, got %t, %v", ok, err)
+	}
+	link.Target = "/changed"
+
+	got, err := store.Get(ctx, "abc")
+	if err != nil || got.Target != "/a" {
+		t.Errorf("expected stored target /a, got %v, %v", got, err)
+	}
+
+	if err := store.Delete(ctx, "abc"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(ctx, "abc"); !errors.Is(err, ErrShortLinkNotFound) {
+		t.Errorf("expected ErrShortLinkNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package gorigumi
+
+import (
+	"encoding/json"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// sidecarDirSuffix is appended to the directory of a stored file to get the
+	// directory of its metadata sidecar
+	sidecarDirSuffix string = ".meta"
+
+	// sidecarExt is the extension appended to the name of a stored file to get
+	// the name of its metadata sidecar
+	sidecarExt string = ".json"
+)
+
+// FileMetadata is the content of the sidecar file written for each stored file
+// when MetadataSidecars is set, so offline tools and backup scripts can
+// understand the upload tree without the application database. Sidecars are
+// kept in a directory next to the upload directory, named after it with a
+// ".meta" suffix, e.g. "uploads.meta/photo.png.json" for "uploads/photo.png", so
+// uploaded files can't overwrite them.
+type FileMetadata struct {
+	OriginalFileName string    `json:"original_file_name"`
+	FileName         string    `json:"file_name"`
+	FileSize         int64     `json:"file_size"`
+	ContentType      string    `json:"content_type"`
+	Thumbnails       []string  `json:"thumbnails,omitempty"`
+	UploadedAt       time.Time `json:"uploaded_at"`
+}
+
+// ReadFileMetadata reads the metadata sidecar of the file stored at path.
+func ReadFileMetadata(path string) (*FileMetadata, error) {
+	data, err := os.ReadFile(sidecarPath(path))
+	if err != nil {
+		return nil, err
+	}
+
+	var m FileMetadata
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// finishUpload runs the steps following the storage of an uploaded file of the
+// sniffed fileType, once it was scanned: the processing of images if
+// ImageOptions is set, and the writing of its metadata sidecar if
+// MetadataSidecars is set. The stored files are removed if the image can't be
+// processed or the sidecar cannot be written. Nothing is done in dry-run
+// mode, as the file was not stored.
+func (t *Tools) finishUpload(uploadDir string, file *UploadedFile, fileType string) error {
+	if t.dryRun {
+		return nil
+	}
+
+	if t.ImageOptions != nil && strings.HasPrefix(fileType, "image/") {
+		if err := t.processImage(uploadDir, file); err != nil {
+			t.removeStoredFiles(uploadDir, file)
+			return err
+		}
+		// the image may have been converted to another format
+		if converted := mime.TypeByExtension(filepath.Ext(file.NewFileName)); strings.HasPrefix(converted, "image/") {
+			fileType = converted
+		}
+	}
+
+	if !t.MetadataSidecars {
+		return nil
+	}
+
+	err := writeSidecar(filepath.Join(uploadDir, file.NewFileName), &FileMetadata{
+		OriginalFileName: file.OriginalFileName,
+		FileName:         file.NewFileName,
+		FileSize:         file.FileSize,
+		ContentType:      fileType,
+		Thumbnails:       file.Thumbnails,
+		UploadedAt:       t.clock().Now().UTC(),
+	})
+	if err != nil {
+		t.removeStoredFiles(uploadDir, file)
+	}
+	return err
+}
+
+// sidecarPath returns the path of the metadata sidecar of the file stored at
+// path.
+func sidecarPath(path string) string {
+	return filepath.Join(filepath.Clean(filepath.Dir(path))+sidecarDirSuffix, filepath.Base(path)+sidecarExt)
+}
+
+// writeSidecar writes m as the sidecar of the file stored at path, through a
+// temporary file so a partial sidecar never appears.
+func writeSidecar(path string, m *FileMetadata) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	sidecar := sidecarPath(path)
+	if err := os.MkdirAll(filepath.Dir(sidecar), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(sidecar), ".sidecar-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = chmodFile(tmp, 0644)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), sidecar)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,93 @@
+package gorigumi
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/drunkleen/gorigumi/toolkittest"
+)
+
+// sidecarTests is a slice of structs that hold the name of the test, the image options,
+// a boolean that indicates if uploads are streamed, the expected content type and the
+// expected number of thumbnails
+var sidecarTests = []struct {
+	name        string
+	options     *ImageOptions
+	stream      bool
+	contentType string
+	thumbnails  int
+}{
+	{"as uploaded", nil, false, "image/png", 0},
+	{"as uploaded streamed", nil, true, "image/png", 0},
+	{"converted", &ImageOptions{Format: "jpeg", GenerateThumbnails: []Size{{Width: 50, Height: 50}}}, false, "image/jpeg", 1},
+}
+
+// TestTools_MetadataSidecars tests that a sidecar describing each stored file is
+// written next to it.
+func TestTools_MetadataSidecars(t *testing.T) {
+	img, err := toolkittest.GenerateImage(200, 100, "png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, st := range sidecarTests {
+		uploadDir := t.TempDir()
+
+		testTools := New()
+		testTools.AllowedFileTypes = []string{"image/png"}
+		testTools.Clock = toolkittest.NewClock(now)
+		testTools.StreamUploads = st.stream
+		testTools.ImageOptions = st.options
+		testTools.MetadataSidecars = true
+
+		files, err := testTools.UploadFiles(newMultipartRequest(t, map[string][]byte{"photo.png": img}), uploadDir)
+		if err != nil || len(files) != 1 {
+			t.Fatalf("%s: unexpected upload result %v, %v", st.name, files, err)
+		}
+
+		m, err := ReadFileMetadata(filepath.Join(uploadDir, files[0].NewFileName))
+		if err != nil {
+			t.Fatalf("%s: %v", st.name, err)
+		}
+		if m.OriginalFileName != "photo.png" || m.FileName != files[0].NewFileName || m.FileSize != files[0].FileSize {
+			t.Errorf("%s: metadata %+v does not match the uploaded file %+v", st.name, m, files[0])
+		}
+		if m.ContentType != st.contentType {
+			t.Errorf("%s: expected content type %s, got %s", st.name, st.contentType, m.ContentType)
+		}
+		if len(m.Thumbnails) != st.thumbnails {
+			t.Errorf("%s: expected %d thumbnails, got %v", st.name, st.thumbnails, m.Thumbnails)
+		}
+		if !m.UploadedAt.Equal(now) {
+			t.Errorf("%s: expected upload time %v, got %v", st.name, now, m.UploadedAt)
+		}
+	}
+}
+
+// TestTools_MetadataSidecars_uploadedJSON tests that sidecars are kept out of the
+// upload directory, so an upload named after a sidecar does not overwrite it.
+func TestTools_MetadataSidecars_uploadedJSON(t *testing.T) {
+	uploadDir := t.TempDir()
+
+	testTools := New()
+	testTools.AllowedFileTypes = []string{"*"}
+	testTools.MetadataSidecars = true
+
+	if _, err := testTools.UploadFiles(newMultipartRequest(t, map[string][]byte{"notes.txt": []byte("notes")}), uploadDir, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testTools.UploadFiles(newMultipartRequest(t, map[string][]byte{"notes.txt.json": []byte(`{"file_name":"forged"}`)}), uploadDir, false); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := ReadFileMetadata(filepath.Join(uploadDir, "notes.txt"))
+	if err != nil || m.FileName != "notes.txt" || m.FileSize != 5 {
+		t.Errorf("expected the metadata of notes.txt, got %+v (%v)", m, err)
+	}
+	if entries, _ := os.ReadDir(uploadDir); len(entries) != 2 {
+		t.Errorf("expected only the uploaded files in the upload directory, got %d entries", len(entries))
+	}
+}
@@ -0,0 +1,370 @@
+package gorigumi
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/drunkleen/gorigumi/random"
+)
+
+const (
+	// defaultSignatureMaxSkew is the default maximum age of signatures
+	// it is inlcuded in the SignatureVerifier type
+	defaultSignatureMaxSkew time.Duration = 5 * time.Minute
+
+	// defaultSignedBodySize is the default maximum size of signed bodies
+	// it is inlcuded in the SignatureVerifier type
+	defaultSignedBodySize int64 = 10 * 1024 * 1024
+
+	// signatureLabel is the label of the signatures of RequestSigner
+	signatureLabel string = "sig1"
+
+	// signatureNonceLength is the length of the nonces of RequestSigner
+	signatureNonceLength int = 22
+)
+
+// signedComponents are the components covered by request signatures, in order
+var signedComponents = []string{"@method", "@authority", "@path", "@query", "content-digest"}
+
+var (
+	// ErrInvalidSignature is returned for requests without a valid signature
+	ErrInvalidSignature = errors.New("invalid request signature")
+	// ErrSignatureExpired is returned for signatures created out of MaxSkew
+	ErrSignatureExpired = errors.New("request signature expired")
+	// ErrSignatureReplayed is returned for signatures whose nonce was seen
+	ErrSignatureReplayed = errors.New("request signature replayed")
+)
+
+// RequestSigner is a http.RoundTripper signing requests between internal
+// services with HTTP message signatures (RFC 9421), e.g. as the Transport of
+// the client given to JSONPushToRemote. The signature covers the method, the
+// host, the path, the query and a Content-Digest header (RFC 9530) of the body,
+// and carries the creation time, a random nonce and KeyID. It is made with
+// Secret using HMAC-SHA256, or with PrivateKey using Ed25519 if set. Requests
+// are verified with SignatureVerifier.
+type RequestSigner struct {
+	// KeyID tells the receiver which key verifies the signature
+	KeyID string
+	// Secret is the shared HMAC-SHA256 key
+	Secret []byte
+	// PrivateKey is the Ed25519 key used instead of Secret if set
+	PrivateKey ed25519.PrivateKey
+	// Transport is the transport sending the signed requests. Default to
+	// http.DefaultTransport
+	Transport http.RoundTripper
+	// Clock is the source of the creation time. Default to SystemClock
+	Clock Clock
+}
+
+// RoundTrip implements http.RoundTripper.
+func (s *RequestSigner) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := s.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	// a RoundTripper must not modify the request it is given
+	req = req.Clone(req.Context())
+	if err := s.Sign(req); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return transport.RoundTrip(req)
+}
+
+// Sign adds the Content-Digest, Signature-Input and Signature headers to req.
+// The body is read to compute its digest and replaced with a copy.
+func (s *RequestSigner) Sign(req *http.Request) error {
+	if len(s.Secret) == 0 && s.PrivateKey == nil {
+		return errors.New("signer has no key")
+	}
+
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+	req.Header.Set("Content-Digest", contentDigest(body))
+
+	created := SystemClock.Now()
+	if s.Clock != nil {
+		created = s.Clock.Now()
+	}
+	alg := "hmac-sha256"
+	if s.PrivateKey != nil {
+		alg = "ed25519"
+	}
+	params := `("` + strings.Join(signedComponents, `" "`) + `");created=` + strconv.FormatInt(created.Unix(), 10) +
+		`;nonce="` + random.String(signatureNonceLength) + `";keyid=` + strconv.Quote(s.KeyID) + `;alg="` + alg + `"`
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	base, err := signatureBase(signedComponents, params, req.Method, host, req.URL.EscapedPath(), req.URL.RawQuery, req.Header)
+	if err != nil {
+		return err
+	}
+
+	var sig []byte
+	if s.PrivateKey != nil {
+		sig = ed25519.Sign(s.PrivateKey, []byte(base))
+	} else {
+		mac := hmac.New(sha256.New, s.Secret)
+		mac.Write([]byte(base))
+		sig = mac.Sum(nil)
+	}
+
+	req.Header.Set("Signature-Input", signatureLabel+"="+params)
+	req.Header.Set("Signature", signatureLabel+"=:"+base64.StdEncoding.EncodeToString(sig)+":")
+	return nil
+}
+
+// VerificationKey verifies the signatures made with a key ID: with Secret using
+// HMAC-SHA256, or with PublicKey using Ed25519 if set.
+type VerificationKey struct {
+	Secret    []byte
+	PublicKey ed25519.PublicKey
+}
+
+// SignatureVerifier configures VerifySignatureMiddleware.
+type SignatureVerifier struct {
+	// Keys maps the key IDs accepted to their key
+	Keys map[string]VerificationKey
+	// MaxSkew is the maximum difference between the creation time of a
+	// signature and the current time. Default to 5m
+	MaxSkew time.Duration
+	// MaxBodySize is the maximum size of signed bodies. Default to 10MB
+	MaxBodySize int64
+	// NonceStore, if set, records the nonces of verified signatures until
+	// they expire, so each signature is only accepted once. Signatures
+	// without a nonce are then rejected
+	NonceStore TokenStore
+}
+
+// signatureKeyContextKey is the context key of the key ID of verified requests.
+type signatureKeyContextKey struct{}
+
+// SignatureKeyID returns the key ID of a request verified by
+// VerifySignatureMiddleware, identifying the calling service.
+func SignatureKeyID(ctx context.Context) string {
+	keyID, _ := ctx.Value(signatureKeyContextKey{}).(string)
+	return keyID
+}
+
+// VerifySignatureMiddleware returns a middleware only passing requests signed by
+// a RequestSigner with one of the keys of v, so internal services can call each
+// other authenticated without mutual TLS. The signature must cover the method,
+// the host, the path, the query and the Content-Digest of the body, and be
+// created within MaxSkew. Other requests are answered with 401 Unauthorized.
+// The key ID is available to next with SignatureKeyID. Unless NonceStore is
+// set, signatures can be replayed within MaxSkew, so they only suit requests
+// that can safely be repeated or that carry their own idempotency key.
+func (t *Tools) VerifySignatureMiddleware(next http.Handler, v SignatureVerifier) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keyID, err := t.VerifyRequestSignature(r, v)
+		switch {
+		case errors.Is(err, ErrInvalidSignature), errors.Is(err, ErrSignatureExpired), errors.Is(err, ErrSignatureReplayed):
+			_ = t.JSONError(w, err, http.StatusUnauthorized)
+			return
+		case err != nil:
+			_ = t.JSONError(w, err, http.StatusInternalServerError)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), signatureKeyContextKey{}, keyID)))
+	})
+}
+
+// VerifyRequestSignature checks the signature of r like
+// VerifySignatureMiddleware and returns its key ID. The error matches
+// ErrSignatureExpired for signatures created out of MaxSkew,
+// ErrSignatureReplayed for nonces already seen, and ErrInvalidSignature
+// otherwise, and other errors come from NonceStore. The body of r is replaced
+// with a copy.
+func (t *Tools) VerifyRequestSignature(r *http.Request, v SignatureVerifier) (string, error) {
+	if v.MaxSkew == 0 {
+		v.MaxSkew = defaultSignatureMaxSkew
+	}
+	if v.MaxBodySize == 0 {
+		v.MaxBodySize = defaultSignedBodySize
+	}
+
+	label, params, ok := strings.Cut(r.Header.Get("Signature-Input"), "=")
+	if !ok {
+		return "", ErrInvalidSignature
+	}
+	sigLabel, sigValue, _ := strings.Cut(r.Header.Get("Signature"), "=")
+	if sigLabel != label || len(sigValue) < 2 || sigValue[0] != ':' || sigValue[len(sigValue)-1] != ':' {
+		return "", ErrInvalidSignature
+	}
+	sig, err := base64.StdEncoding.DecodeString(sigValue[1 : len(sigValue)-1])
+	if err != nil {
+		return "", ErrInvalidSignature
+	}
+
+	components, attrs, err := parseSignatureParams(params)
+	if err != nil {
+		return "", err
+	}
+	for _, c := range signedComponents {
+		if !slices.Contains(components, c) {
+			return "", fmt.Errorf("%w: %s is not covered", ErrInvalidSignature, c)
+		}
+	}
+
+	key, ok := v.Keys[attrs["keyid"]]
+	if !ok {
+		return "", fmt.Errorf("%w: unknown key", ErrInvalidSignature)
+	}
+	created, err := strconv.ParseInt(attrs["created"], 10, 64)
+	if err != nil {
+		return "", ErrInvalidSignature
+	}
+	if skew := t.clock().Now().Sub(time.Unix(created, 0)); skew > v.MaxSkew || skew < -v.MaxSkew {
+		return "", ErrSignatureExpired
+	}
+	if v.NonceStore != nil && attrs["nonce"] == "" {
+		return "", fmt.Errorf("%w: missing nonce", ErrInvalidSignature)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, v.MaxBodySize+1))
+	r.Body.Close()
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	if int64(len(body)) > v.MaxBodySize {
+		return "", fmt.Errorf("%w: body is too large", ErrInvalidSignature)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if !hmac.Equal([]byte(r.Header.Get("Content-Digest")), []byte(contentDigest(body))) {
+		return "", fmt.Errorf("%w: content digest mismatch", ErrInvalidSignature)
+	}
+
+	base, err := signatureBase(components, params, r.Method, r.Host, r.URL.EscapedPath(), r.URL.RawQuery, r.Header)
+	if err != nil {
+		return "", err
+	}
+
+	// the algorithm is decided by the key, never by the request
+	if key.PublicKey != nil {
+		if (attrs["alg"] != "" && attrs["alg"] != "ed25519") || !ed25519.Verify(key.PublicKey, []byte(base), sig) {
+			return "", ErrInvalidSignature
+		}
+	} else {
+		mac := hmac.New(sha256.New, key.Secret)
+		mac.Write([]byte(base))
+		if (attrs["alg"] != "" && attrs["alg"] != "hmac-sha256") || len(key.Secret) == 0 || !hmac.Equal(sig, mac.Sum(nil)) {
+			return "", ErrInvalidSignature
+		}
+	}
+
+	// nonces are only recorded for authentic signatures, so forged requests
+	// cannot burn the nonces of legitimate ones
+	if v.NonceStore != nil {
+		fresh, err := v.NonceStore.Consume(r.Context(), attrs["keyid"]+":"+attrs["nonce"], time.Unix(created, 0).Add(v.MaxSkew))
+		if err != nil {
+			return "", err
+		}
+		if !fresh {
+			return "", ErrSignatureReplayed
+		}
+	}
+
+	return attrs["keyid"], nil
+}
+
+// contentDigest returns the Content-Digest header of body.
+func contentDigest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+}
+
+// signatureBase returns the signature base of RFC 9421 covering components of
+// a request, ending with the signature parameters params.
+func signatureBase(components []string, params, method, authority, path, rawQuery string, header http.Header) (string, error) {
+	var b strings.Builder
+	for _, c := range components {
+		var value string
+		switch c {
+		case "@method":
+			value = method
+		case "@authority":
+			value = strings.ToLower(authority)
+		case "@path":
+			value = path
+			if value == "" {
+				value = "/"
+			}
+		case "@query":
+			value = "?" + rawQuery
+		default:
+			if strings.HasPrefix(c, "@") {
+				return "", fmt.Errorf("%w: unsupported component %s", ErrInvalidSignature, c)
+			}
+			values := header.Values(c)
+			if len(values) == 0 {
+				return "", fmt.Errorf("%w: missing header %s", ErrInvalidSignature, c)
+			}
+			value = strings.Join(values, ", ")
+		}
+		b.WriteString(`"` + c + `": ` + value + "\n")
+	}
+	b.WriteString(`"@signature-params": ` + params)
+	return b.String(), nil
+}
+
+// parseSignatureParams parses the inner list of components and the parameters
+// of a Signature-Input member, such as ("@method" "@path");created=1;keyid="a".
+func parseSignatureParams(params string) ([]string, map[string]string, error) {
+	if !strings.HasPrefix(params, "(") {
+		return nil, nil, ErrInvalidSignature
+	}
+	list, rest, ok := strings.Cut(params[1:], ")")
+	if !ok {
+		return nil, nil, ErrInvalidSignature
+	}
+
+	var components []string
+	for _, c := range strings.Fields(list) {
+		unquoted, err := strconv.Unquote(c)
+		if err != nil {
+			return nil, nil, ErrInvalidSignature
+		}
+		components = append(components, unquoted)
+	}
+
+	attrs := make(map[string]string)
+	for _, attr := range strings.Split(rest, ";") {
+		if attr == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(attr, "=")
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+		attrs[name] = value
+	}
+	return components, attrs, nil
+}
@@ -0,0 +1,170 @@
+package gorigumi
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/drunkleen/gorigumi/toolkittest"
+)
+
+// TestTools_VerifySignatureMiddleware tests requests signed by a RequestSigner against
+// the verifying middleware, with HMAC and Ed25519 keys.
+func TestTools_VerifySignatureMiddleware(t *testing.T) {
+	clock := toolkittest.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	testTools := &Tools{Clock: clock}
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var gotKey, gotBody string
+	handler := testTools.VerifySignatureMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotKey, gotBody = SignatureKeyID(r.Context()), string(body)
+		w.WriteHeader(http.StatusNoContent)
+	}), SignatureVerifier{Keys: map[string]VerificationKey{
+		"billing": {Secret: []byte("shared secret")},
+		"orders":  {PublicKey: pub},
+	}})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	signers := map[string]*RequestSigner{
+		"billing": {KeyID: "billing", Secret: []byte("shared secret"), Clock: clock},
+		"orders":  {KeyID: "orders", PrivateKey: priv, Clock: clock},
+	}
+	for keyID, signer := range signers {
+		client := &http.Client{Transport: signer}
+		_, status, err := testTools.JSONPushToRemote(srv.URL+"/events?kind=paid", map[string]int{"id": 1}, client)
+		if err != nil || status != http.StatusNoContent {
+			t.Errorf("%s: expected status 204, got %d, %v", keyID, status, err)
+		}
+		if gotKey != keyID || gotBody != `{"id":1}` {
+			t.Errorf("%s: expected the key ID and the body to reach the handler, got %q, %q", keyID, gotKey, gotBody)
+		}
+	}
+
+	send := func(signer *RequestSigner, tamper func(req *http.Request)) int {
+		req, _ := http.NewRequest("POST", srv.URL+"/events?kind=paid", strings.NewReader(`{"id":1}`))
+		if signer != nil {
+			if err := signer.Sign(req); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if tamper != nil {
+			tamper(req)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	tampered := map[string]func(req *http.Request){
+		"body":   func(req *http.Request) { req.Body = io.NopCloser(strings.NewReader(`{"id":2}`)); req.ContentLength = 8 },
+		"query":  func(req *http.Request) { req.URL.RawQuery = "kind=refunded" },
+		"method": func(req *http.Request) { req.Method = "PUT" },
+		"digest": func(req *http.Request) {
+			req.Header.Set("Content-Digest", contentDigest([]byte(`{"id":2}`)))
+			req.Body = io.NopCloser(strings.NewReader(`{"id":2}`))
+		},
+		"coverage": func(req *http.Request) {
+			req.Header.Set("Signature-Input", strings.Replace(req.Header.Get("Signature-Input"), `"@query" `, "", 1))
+		},
+		"algorithm": func(req *http.Request) {
+			req.Header.Set("Signature-Input", strings.Replace(req.Header.Get("Signature-Input"), "hmac-sha256", "ed25519", 1))
+		},
+	}
+	for name, tamper := range tampered {
+		if code := send(signers["billing"], tamper); code != http.StatusUnauthorized {
+			t.Errorf("%s: expected tampered request to be rejected, got %d", name, code)
+		}
+	}
+
+	if code := send(nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("expected unsigned request to be rejected, got %d", code)
+	}
+	if code := send(&RequestSigner{KeyID: "billing", Secret: []byte("wrong"), Clock: clock}, nil); code != http.StatusUnauthorized {
+		t.Errorf("expected wrong secret to be rejected, got %d", code)
+	}
+	if code := send(&RequestSigner{KeyID: "unknown", Secret: []byte("shared secret"), Clock: clock}, nil); code != http.StatusUnauthorized {
+		t.Errorf("expected unknown key to be rejected, got %d", code)
+	}
+	if code := send(&RequestSigner{KeyID: "orders", Secret: []byte("shared secret"), Clock: clock}, nil); code != http.StatusUnauthorized {
+		t.Errorf("expected HMAC signature for an Ed25519 key to be rejected, got %d", code)
+	}
+
+	old := toolkittest.NewClock(clock.Now().Add(-10 * time.Minute))
+	if code := send(&RequestSigner{KeyID: "billing", Secret: []byte("shared secret"), Clock: old}, nil); code != http.StatusUnauthorized {
+		t.Errorf("expected old signature to be rejected, got %d", code)
+	}
+}
+
+// TestRequestSigner_noKey tests that signers without a key fail.
+func TestRequestSigner_noKey(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	if err := (&RequestSigner{KeyID: "a"}).Sign(req); err == nil {
+		t.Error("expected an error without a key")
+	}
+}
+
+// TestTools_VerifyRequestSignature_replay tests that signatures are only accepted once
+// with a NonceStore, and that the errors tell replays from expired signatures.
+func TestTools_VerifyRequestSignature_replay(t *testing.T) {
+	clock := toolkittest.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	testTools := &Tools{Clock: clock}
+	signer := &RequestSigner{KeyID: "billing", Secret: []byte("shared secret"), Clock: clock}
+	v := SignatureVerifier{
+		Keys:       map[string]VerificationKey{"billing": {Secret: []byte("shared secret")}},
+		NonceStore: NewMemoryTokenStore(clock),
+	}
+
+	req := httptest.NewRequest("POST", "http://billing.internal/events", strings.NewReader(`{"id":1}`))
+	if err := signer.Sign(req); err != nil {
+		t.Fatal(err)
+	}
+	replay := req.Clone(req.Context())
+
+	if _, err := testTools.VerifyRequestSignature(req, v); err != nil {
+		t.Fatalf("expected the first request to be accepted, got %v", err)
+	}
+	replay.Body = io.NopCloser(strings.NewReader(`{"id":1}`))
+	if _, err := testTools.VerifyRequestSignature(replay, v); !errors.Is(err, ErrSignatureReplayed) {
+		t.Errorf("expected ErrSignatureReplayed, got %v", err)
+	}
+
+	req = httptest.NewRequest("POST", "http://billing.internal/events", strings.NewReader(`{"id":1}`))
+	if err := signer.Sign(req); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testTools.VerifyRequestSignature(req, v); err != nil {
+		t.Errorf("expected a new signature of the same request to be accepted, got %v", err)
+	}
+
+	req = httptest.NewRequest("POST", "http://billing.internal/events", strings.NewReader(`{"id":1}`))
+	if err := signer.Sign(req); err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Signature-Input", strings.Replace(req.Header.Get("Signature-Input"), ";nonce=", ";x=", 1))
+	if _, err := testTools.VerifyRequestSignature(req, v); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected signatures without a nonce to be rejected, got %v", err)
+	}
+
+	req = httptest.NewRequest("POST", "http://billing.internal/events", strings.NewReader(`{"id":1}`))
+	if err := signer.Sign(req); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(6 * time.Minute)
+	if _, err := testTools.VerifyRequestSignature(req, v); !errors.Is(err, ErrSignatureExpired) {
+		t.Errorf("expected ErrSignatureExpired, got %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package gorigumi
+
+import (
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// SingleFlight deduplicates concurrent calls sharing the same key: while a call
+// for a key is in flight, later callers wait for it and share its result instead
+// of running the function again. The zero value is ready to use.
+type SingleFlight[T any] struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall[T]
+}
+
+// flightCall is a call in flight of a SingleFlight.
+type flightCall[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+	dups  int
+}
+
+// Do runs fn for key unless a call for key is already in flight, in which case it
+// waits for that call and returns its result. The shared result is true if the
+// result was given to more than one caller. A panic in fn is returned to every
+// caller as a *PanicError.
+func (g *SingleFlight[T]) Do(key string, fn func() (T, error)) (value T, err error, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall[T])
+	}
+	if c, ok := g.calls[key]; ok {
+		c.dups++
+		g.mu.Unlock()
+		<-c.done
+		return c.value, c.err, true
+	}
+
+	c := &flightCall[T]{done: make(chan struct{})}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	func() {
+		defer func() {
+			if v := recover(); v != nil {
+				c.err = &PanicError{Value: v, Stack: debug.Stack()}
+			}
+		}()
+		c.value, c.err = fn()
+	}()
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	shared = c.dups > 0
+	g.mu.Unlock()
+	close(c.done)
+
+	return c.value, c.err, shared
+}
+
+// Forget makes the next call for key run fn even if a call is still in flight.
+func (g *SingleFlight[T]) Forget(key string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.calls, key)
+}
+
+// Debouncer delays a function until no new trigger for the same key happened
+// for Wait, e.g. to regenerate a thumbnail once after a burst of writes.
+type Debouncer struct {
+	// Wait is the quiet period after the last trigger
+	Wait time.Duration
+	// Clock is the source of time of the debouncer. Default to SystemClock
+	Clock Clock
+
+	mu     sync.Mutex
+	timers map[string]*debounceTimer
+}
+
+// debounceTimer is a pending run of a Debouncer.
+type debounceTimer struct {
+	stop func() bool
+}
+
+// NewDebouncer returns a new Debouncer with the given quiet period.
+func NewDebouncer(wait time.Duration) *Debouncer {
+	return &Debouncer{Wait: wait, timers: make(map[string]*debounceTimer)}
+}
+
+// Trigger schedules fn to run in its own goroutine once Wait elapsed without
+// another trigger for key. Only the fn of the last trigger runs.
+func (d *Debouncer) Trigger(key string, fn func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.timers == nil {
+		d.timers = make(map[string]*debounceTimer)
+	}
+	if timer, ok := d.timers[key]; ok {
+		timer.stop()
+	}
+
+	timer := &debounceTimer{}
+	timer.stop = clockOrSystem(d.Clock).AfterFunc(d.Wait, func() {
+		d.mu.Lock()
+		if d.timers[key] != timer {
+			d.mu.Unlock()
+			return
+		}
+		delete(d.timers, key)
+		d.mu.Unlock()
+
+		fn()
+	})
+	d.timers[key] = timer
+}
+
+// Cancel drops the pending run for key, if any.
+func (d *Debouncer) Cancel(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if timer, ok := d.timers[key]; ok {
+		timer.stop()
+		delete(d.timers, key)
+	}
+}
+
+// Throttler runs a function at most once per Interval for the same key, e.g. to
+// refresh a cache entry requested by many concurrent clients.
+type Throttler struct {
+	// Interval is the minimum delay between two runs for the same key
+	Interval time.Duration
+	// Clock is the source of time of the throttler. Default to SystemClock
+	Clock Clock
+
+	mu      sync.Mutex
+	last    map[string]time.Time
+	cleaned time.Time
+}
+
+// NewThrottler returns a new Throttler with the given interval.
+func NewThrottler(interval time.Duration) *Throttler {
+	return &Throttler{Interval: interval, last: make(map[string]time.Time)}
+}
+
+// Do runs fn synchronously and returns true, unless fn already ran for key less
+// than Interval ago, in which case it returns false without running it.
+func (th *Throttler) Do(key string, fn func()) bool {
+	now := clockOrSystem(th.Clock).Now()
+
+	th.mu.Lock()
+	if th.last == nil {
+		th.last = make(map[string]time.Time)
+	}
+	if last, ok := th.last[key]; ok && now.Sub(last) < th.Interval {
+		th.mu.Unlock()
+		return false
+	}
+	th.last[key] = now
+
+	// drop expired keys from time to time to bound memory
+	if now.Sub(th.cleaned) >= th.Interval {
+		for k, last := range th.last {
+			if now.Sub(last) >= th.Interval {
+				delete(th.last, k)
+			}
+		}
+		th.last[key] = now
+		th.cleaned = now
+	}
+	th.mu.Unlock()
+
+	fn()
+	return true
+}
@@ -0,0 +1,114 @@
+package gorigumi
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestSingleFlight_Do tests that concurrent calls for the same key run the function once
+// and share its result, while calls for other keys run independently.
+func TestSingleFlight_Do(t *testing.T) {
+	var g SingleFlight[string]
+	var runs atomic.Int32
+
+	release := make(chan struct{})
+	fn := func() (string, error) {
+		runs.Add(1)
+		<-release
+		return "thumbnail", nil
+	}
+
+	var wg sync.WaitGroup
+	results := make(chan string, 10)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err, _ := g.Do("img.png", fn)
+			if err != nil {
+				t.Error(err)
+			}
+			results <- v
+		}()
+	}
+
+	// let the callers pile up behind the first one
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	if runs.Load() != 1 {
+		t.Errorf("expected a single run, got %d", runs.Load())
+	}
+	for v := range results {
+		if v != "thumbnail" {
+			t.Errorf("expected shared result thumbnail, got %s", v)
+		}
+	}
+
+	_, err, shared := g.Do("other.png", func() (string, error) { panic("boom") })
+	var pe *PanicError
+	if !errors.As(err, &pe) {
+		t.Errorf("expected a *PanicError, got %v", err)
+	}
+	if shared {
+		t.Error("expected a single caller result not to be shared")
+	}
+}
+
+// TestDebouncer_Trigger tests that a burst of triggers runs only the last function once.
+func TestDebouncer_Trigger(t *testing.T) {
+	d := NewDebouncer(20 * time.Millisecond)
+
+	ran := make(chan int, 10)
+	for i := range 5 {
+		d.Trigger("key", func() { ran <- i })
+	}
+
+	select {
+	case i := <-ran:
+		if i != 4 {
+			t.Errorf("expected the last trigger to run, got %d", i)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the debounced function to run")
+	}
+
+	select {
+	case i := <-ran:
+		t.Errorf("expected a single run, got another one for %d", i)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	d.Trigger("key", func() { ran <- 0 })
+	d.Cancel("key")
+	select {
+	case <-ran:
+		t.Error("expected cancelled trigger not to run")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// TestThrottler_Do tests that a function runs at most once per interval for the same key.
+func TestThrottler_Do(t *testing.T) {
+	th := NewThrottler(30 * time.Millisecond)
+	runs := 0
+
+	for range 3 {
+		th.Do("a", func() { runs++ })
+	}
+	th.Do("b", func() { runs++ })
+
+	if runs != 2 {
+		t.Errorf("expected 2 runs, got %d", runs)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	if !th.Do("a", func() { runs++ }) {
+		t.Error("expected function to run again after the interval")
+	}
+}
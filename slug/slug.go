@@ -0,0 +1,30 @@
+// Package slug converts strings to URL-friendly slugs. It is the implementation
+// of gorigumi's ConvertToSlug and can be imported on its own.
+package slug
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// separators matches the runs of characters replaced by a hyphen in slugs.
+var separators = regexp.MustCompile(`[^a-z\d]+`)
+
+// Make converts s to a slug made of lowercase letters, digits and hyphens.
+// It returns an error if s is empty or contains no letter or digit.
+func Make(s string) (string, error) {
+	if s == "" {
+		return "", errors.New("string is empty")
+	}
+
+	slug := strings.Trim(
+		separators.ReplaceAllString(strings.ToLower(s), "-"), "-",
+	)
+
+	if len(slug) == 0 {
+		return "", errors.New("not valid string characters")
+	}
+
+	return slug, nil
+}
@@ -0,0 +1,55 @@
+package slug
+
+import (
+	"strings"
+	"testing"
+)
+
+// slugTests is a slice of structs that hold the input string, the expected slug and a
+// boolean that indicates if an error is expected
+var slugTests = []struct {
+	s             string
+	expected      string
+	errorExpected bool
+}{
+	{"Hello, World! 123", "hello-world-123", false},
+	{"--Go__is  fun--", "go-is-fun", false},
+	{"", "", true},
+	{"こんにちは", "", true},
+}
+
+// TestMake tests the conversion of strings to slugs.
+func TestMake(t *testing.T) {
+	for _, st := range slugTests {
+		got, err := Make(st.s)
+		if st.errorExpected != (err != nil) {
+			t.Errorf("%q: expected error %t, got %v", st.s, st.errorExpected, err)
+		}
+		if got != st.expected {
+			t.Errorf("%q: expected %q, got %q", st.s, st.expected, got)
+		}
+	}
+}
+
+// FuzzMake checks that Make never panics and only returns non-empty slugs made of
+// lowercase letters, digits and inner hyphens.
+func FuzzMake(f *testing.F) {
+	for _, seed := range []string{"Hello World", "--a--", "こんにちは", "Ünïcödé 123", ""} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		slug, err := Make(s)
+		if err != nil {
+			return
+		}
+		if slug == "" || strings.HasPrefix(slug, "-") || strings.HasSuffix(slug, "-") {
+			t.Fatalf("invalid slug %q for %q", slug, s)
+		}
+		for _, c := range slug {
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+				t.Fatalf("invalid character %q in slug %q", c, slug)
+			}
+		}
+	})
+}
@@ -0,0 +1,163 @@
+package gorigumi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+)
+
+// maxStreamedFieldsSize is the maximum total size in bytes of the non-file field
+// values read by streamUploads, the same limit r.ParseMultipartForm applies
+const maxStreamedFieldsSize int64 = 10 << 20
+
+// streamUploads reads the multipart body of r part by part with r.MultipartReader and
+// writes every file part directly to uploadDir as it arrives, so large uploads are
+// never buffered in memory or temporary files. MaxFileSize, or MaxFileSizePerFile if
+// smaller, is enforced per part. If maxFiles is greater than zero, reading stops once
+// that many files were stored. Exceeding MaxFilesPerRequest is an error. Only files of
+// the fields accepted by filter are stored. Stored files and non-file field values are
+// added to result.
+func (t *Tools) streamUploads(ctx context.Context, r *http.Request, uploadDir string, renameFile bool, maxFiles int, filter fileFilter, result *UploadResult) error {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return err
+	}
+
+	fieldsSize := int64(0)
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if part.FileName() == "" {
+			value, err := io.ReadAll(io.LimitReader(part, maxStreamedFieldsSize-fieldsSize+1))
+			part.Close()
+			if err != nil {
+				return err
+			}
+			fieldsSize += int64(len(value))
+			if fieldsSize > maxStreamedFieldsSize {
+				return WithErrorCode(errors.New("the form fields are too big"), CodePayloadTooLarge)
+			}
+			name := part.FormName()
+			result.Fields[name] = append(result.Fields[name], string(value))
+			continue
+		}
+
+		ok, err := filter.accept(part.FormName())
+		if !ok {
+			part.Close()
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if t.MaxFilesPerRequest > 0 && len(result.Files) >= t.MaxFilesPerRequest {
+			part.Close()
+			return WithErrorCode(fmt.Errorf("too many files in request, the maximum is %d", t.MaxFilesPerRequest), CodeTooManyFiles)
+		}
+
+		endPart := guardPart(r.Context())
+		uploadedFile, err := t.streamPart(ctx, part, uploadDir, renameFile)
+		endPart()
+		part.Close()
+		if err != nil {
+			return err
+		}
+		result.Files = append(result.Files, uploadedFile)
+
+		if maxFiles > 0 && len(result.Files) >= maxFiles {
+			break
+		}
+	}
+
+	return nil
+}
+
+// streamPart checks the type of a single file part and copies it to uploadDir.
+// Nothing is stored if the part is too big, the copy fails, or fewer bytes than
+// declared by the Content-Length header of the part were received.
+func (t *Tools) streamPart(ctx context.Context, part *multipart.Part, uploadDir string, renameFile bool) (*UploadedFile, error) {
+	// read one byte past the limit to detect oversized parts
+	limit := int64(t.MaxFileSize)
+	if t.MaxFileSizePerFile > 0 {
+		limit = min(limit, int64(t.MaxFileSizePerFile))
+	}
+
+	// the part may not take more than the room left by the directory quota
+	var quotaErr error
+	if q, ok := t.dirQuota(); ok {
+		usage, err := q.CheckCtx(ctx, uploadDir, 0, 1)
+		if err != nil {
+			return nil, err
+		}
+		if q.MaxBytes > 0 && q.MaxBytes-usage.Bytes < limit {
+			limit = q.MaxBytes - usage.Bytes
+			quotaErr = &QuotaError{Dir: uploadDir, Usage: usage, MaxBytes: q.MaxBytes, MaxFiles: q.MaxFiles}
+		}
+	}
+
+	declared := int64(-1)
+	if v := part.Header.Get("Content-Length"); v != "" {
+		var err error
+		if declared, err = strconv.ParseInt(v, 10, 64); err != nil || declared < 0 {
+			return nil, fmt.Errorf("file %q has an invalid Content-Length %q", part.FileName(), v)
+		}
+		if declared > limit && quotaErr != nil {
+			return nil, quotaErr
+		}
+		if declared > limit {
+			return nil, WithErrorCode(fmt.Errorf("file %q is too big, the maximum size is %d bytes", part.FileName(), limit), CodeFileTooLarge)
+		}
+	}
+
+	buff := make([]byte, 512)
+	n, err := io.ReadFull(part, buff)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+
+	fileType, err := t.checkFileType(part.FileName(), buff[:n])
+	if err != nil {
+		return nil, err
+	}
+
+	file := UploadedFile{
+		OriginalFileName: part.FileName(),
+		NewFileName:      t.newFileName(part.FileName(), renameFile),
+	}
+
+	src := io.MultiReader(bytes.NewReader(buff[:n]), io.LimitReader(part, limit-int64(n)+1))
+	err = t.storeFile(ctx, uploadDir, &file, src, func(size int64, err error) error {
+		switch {
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			return fmt.Errorf("%w: file %q: %w", ErrUploadTruncated, file.OriginalFileName, err)
+		case err == nil && size > limit && quotaErr != nil:
+			return quotaErr
+		case err == nil && size > limit:
+			return WithErrorCode(fmt.Errorf("file %q is too big, the maximum size is %d bytes", file.OriginalFileName, limit), CodeFileTooLarge)
+		case err == nil && declared >= 0 && size != declared:
+			return fmt.Errorf("%w: file %q: declared %d bytes, received %d", ErrUploadTruncated, file.OriginalFileName, declared, size)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.finishUpload(uploadDir, &file, fileType); err != nil {
+		return nil, err
+	}
+
+	return &file, nil
+}
@@ -0,0 +1,112 @@
+package gorigumi
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/drunkleen/gorigumi/toolkittest"
+)
+
+// newMultipartRequest builds a multipart request with a text field and one file part
+// per entry of files, keyed by file name.
+func newMultipartRequest(tb testing.TB, files map[string][]byte) *http.Request {
+	tb.Helper()
+
+	body, contentType := newMultipartBody(tb, files)
+	req, _ := http.NewRequest("POST", "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	return req
+}
+
+// newMultipartBody encodes the form of newMultipartRequest and returns the body
+// along with its content type.
+func newMultipartBody(tb testing.TB, files map[string][]byte) ([]byte, string) {
+	tb.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	if err := writer.WriteField("title", "holiday"); err != nil {
+		tb.Fatal(err)
+	}
+	for name, data := range files {
+		part, err := writer.CreateFormFile("file", name)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		part.Write(data)
+	}
+	writer.Close()
+
+	return body.Bytes(), writer.FormDataContentType()
+}
+
+// streamTests is a slice of structs that hold the name of the test, the maximum file size,
+// and a boolean that indicates if an error is expected
+var streamTests = []struct {
+	name          string
+	maxFileSize   int
+	errorExpected bool
+}{
+	{"within limit", 1024 * 1024, false},
+	{"oversized part", 100, true},
+}
+
+// TestTools_streamUploads tests the UploadFiles method with StreamUploads enabled by
+// uploading generated images, and checking that oversized parts are rejected without
+// leaving partial files behind.
+func TestTools_streamUploads(t *testing.T) {
+	img, err := toolkittest.GenerateImage(64, 64, "png")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, st := range streamTests {
+		uploadDir := t.TempDir()
+
+		testTools := New()
+		testTools.StreamUploads = true
+		testTools.MaxFileSize = st.maxFileSize
+		testTools.AllowedFileTypes = []string{"image/png"}
+
+		req := newMultipartRequest(t, map[string][]byte{"a.png": img, "b.png": img})
+		uploadedFiles, err := testTools.UploadFiles(req, uploadDir, false)
+
+		entries, _ := os.ReadDir(uploadDir)
+		if st.errorExpected {
+			if err == nil {
+				t.Errorf("%s: expected error but got none", st.name)
+			}
+			if len(entries) != 0 {
+				t.Errorf("%s: expected no files to be left behind, got %d", st.name, len(entries))
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: %s", st.name, err)
+			continue
+		}
+		if len(uploadedFiles) != 2 || len(entries) != 2 {
+			t.Errorf("%s: expected 2 uploaded files, got %d and %d on disk", st.name, len(uploadedFiles), len(entries))
+		}
+		for _, f := range uploadedFiles {
+			data, _ := os.ReadFile(filepath.Join(uploadDir, f.NewFileName))
+			if !bytes.Equal(data, img) || f.FileSize != int64(len(img)) {
+				t.Errorf("%s: %s was not stored intact", st.name, f.NewFileName)
+			}
+		}
+	}
+
+	testTools := New()
+	testTools.StreamUploads = true
+	testTools.AllowedFileTypes = []string{"image/jpeg"}
+	req := newMultipartRequest(t, map[string][]byte{"a.png": img})
+	if _, err := testTools.UploadFile(req, t.TempDir()); err == nil {
+		t.Error("expected disallowed file type to be rejected")
+	}
+}
@@ -0,0 +1,165 @@
+package gorigumi
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrSymlink is returned when a path goes through a symbolic link the
+// SymlinkPolicy of the Tools struct does not allow.
+var ErrSymlink = errors.New("path goes through a symbolic link")
+
+// SymlinkPolicy decides how symbolic links are handled in the paths of the files
+// served by ServeDir and DownloadFile, below their root directory, and in the
+// upload directories files are created in. Whatever the policy, files are never
+// served from outside of their root, and uploaded files, sidecars and thumbnails
+// are created under a temporary name and renamed, so a symbolic link planted at
+// their name is replaced rather than written through.
+type SymlinkPolicy int
+
+const (
+	// SymlinkContain follows symbolic links, but rejects the paths they resolve
+	// to outside of the root. Upload directories may contain symbolic links.
+	SymlinkContain SymlinkPolicy = iota
+	// SymlinkRefuse rejects the paths going through a symbolic link, wherever it
+	// points, e.g. in shared hosting where other users can create links in the
+	// served or upload directories. The root itself may be a symbolic link, but
+	// an upload directory may not contain any: pass the resolved path of
+	// directories below links such as /var on macOS.
+	SymlinkRefuse
+)
+
+// resolveInRoot returns the file of root at name, a slash-separated path that
+// cannot leave root, and its information, according to the SymlinkPolicy of the
+// Tools struct. Rejected paths return an error wrapping fs.ErrNotExist.
+func (t *Tools) resolveInRoot(root, name string) (string, fs.FileInfo, error) {
+	rel := filepath.FromSlash(strings.TrimPrefix(path.Clean("/"+name), "/"))
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return "", nil, err
+	}
+	realRoot, err := filepath.EvalSymlinks(absRoot)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if t.SymlinkPolicy == SymlinkRefuse {
+		if err := checkNoSymlinks(realRoot, rel); err != nil {
+			return "", nil, fmt.Errorf("%w: %w", fs.ErrNotExist, err)
+		}
+	}
+
+	resolved, err := filepath.EvalSymlinks(filepath.Join(realRoot, rel))
+	if err != nil {
+		return "", nil, err
+	}
+	if r, err := filepath.Rel(realRoot, resolved); err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+		return "", nil, fmt.Errorf("%w: %s resolves outside of the root", fs.ErrNotExist, name)
+	}
+
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", nil, err
+	}
+	return resolved, info, nil
+}
+
+// checkUploadDir checks that files can be created in dir according to the
+// SymlinkPolicy of the Tools struct.
+func (t *Tools) checkUploadDir(dir string) error {
+	if t.SymlinkPolicy != SymlinkRefuse {
+		return nil
+	}
+	volume := filepath.VolumeName(dir)
+	base := volume
+	if rest := dir[len(volume):]; rest != "" && os.IsPathSeparator(rest[0]) {
+		base += string(filepath.Separator)
+	}
+	return checkNoSymlinks(base, dir[len(base):])
+}
+
+// checkNoSymlinks returns an error wrapping ErrSymlink if one of the existing
+// components of rel, a path relative to base, is a symbolic link. The missing
+// components are left to be created.
+func checkNoSymlinks(base, rel string) error {
+	current := base
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		if part == "" || part == "." {
+			continue
+		}
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return fmt.Errorf("%w: %s", ErrSymlink, current)
+		}
+	}
+	return nil
+}
+
+// writeFileReplacing writes data to a temporary file next to name and renames
+// it to name, so a symbolic link at name is replaced rather than written through.
+func writeFileReplacing(name string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = chmodFile(tmp, 0644)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), name)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// openNoFollow opens the existing file name like os.OpenFile, but returns an
+// error wrapping ErrSymlink if name is a symbolic link, even one swapped in
+// while the file was being opened.
+func openNoFollow(name string, flag int) (*os.File, error) {
+	f, err := os.OpenFile(name, flag, 0)
+	if err != nil {
+		return nil, err
+	}
+	opened, err := f.Stat()
+	if err == nil {
+		var info fs.FileInfo
+		info, err = os.Lstat(name)
+		if err == nil && (info.Mode()&fs.ModeSymlink != 0 || !os.SameFile(opened, info)) {
+			err = fmt.Errorf("%w: %s", ErrSymlink, name)
+		}
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// downloadPath returns the path of the file fileName of dir sent by DownloadFile,
+// checked against the SymlinkPolicy of the Tools struct. If dir is empty, the
+// directory of fileName is the root.
+func (t *Tools) downloadPath(dir, fileName string) (string, error) {
+	if dir == "" {
+		dir, fileName = filepath.Split(fileName)
+	}
+	name, _, err := t.resolveInRoot(dir, filepath.ToSlash(fileName))
+	return name, err
+}
@@ -0,0 +1,166 @@
+package gorigumi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// symlinkTests is a slice of structs that hold the name of the test, the policy,
+// the requested path and the expected status codes of ServeDir and DownloadFile
+var symlinkTests = []struct {
+	name   string
+	policy SymlinkPolicy
+	path   string
+	status int
+}{
+	{"file", SymlinkContain, "a.txt", http.StatusOK},
+	{"inner link", SymlinkContain, "alias.txt", http.StatusOK},
+	{"inner directory link", SymlinkContain, "linked/b.txt", http.StatusOK},
+	{"outer link", SymlinkContain, "leak.txt", http.StatusNotFound},
+	{"outer directory link", SymlinkContain, "escape/secret.txt", http.StatusNotFound},
+	{"file refused", SymlinkRefuse, "a.txt", http.StatusOK},
+	{"inner link refused", SymlinkRefuse, "alias.txt", http.StatusNotFound},
+	{"inner directory link refused", SymlinkRefuse, "linked/b.txt", http.StatusNotFound},
+	{"outer link refused", SymlinkRefuse, "leak.txt", http.StatusNotFound},
+}
+
+// TestTools_SymlinkPolicy tests that ServeDir and DownloadFile follow symbolic
+// links within the root only, and none of them with SymlinkRefuse.
+func TestTools_SymlinkPolicy(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "uploads")
+	mustWriteFiles(t, root, map[string]string{"a.txt": "alpha", "sub/b.txt": "beta"})
+	mustWriteFiles(t, base, map[string]string{"secret.txt": "secret"})
+	for link, target := range map[string]string{
+		"alias.txt": filepath.Join(root, "a.txt"),
+		"linked":    filepath.Join(root, "sub"),
+		"leak.txt":  filepath.Join(base, "secret.txt"),
+		"escape":    base,
+	} {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Skip("symbolic links are not supported:", err)
+		}
+	}
+
+	// the root itself may be a link
+	rootLink := filepath.Join(base, "current")
+	if err := os.Symlink(root, rootLink); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range symlinkTests {
+		testTools := New()
+		testTools.SymlinkPolicy = tt.policy
+
+		rr := httptest.NewRecorder()
+		testTools.ServeDir(rr, httptest.NewRequest(http.MethodGet, "/"+tt.path, nil), rootLink)
+		if rr.Code != tt.status {
+			t.Errorf("%s: expected ServeDir status %d, got %d", tt.name, tt.status, rr.Code)
+		}
+
+		rr = httptest.NewRecorder()
+		testTools.DownloadFile(rr, httptest.NewRequest(http.MethodGet, "/", nil), rootLink, tt.path, "file.txt")
+		if rr.Code != tt.status {
+			t.Errorf("%s: expected DownloadFile status %d, got %d", tt.name, tt.status, rr.Code)
+		}
+		if strings.Contains(rr.Body.String(), "secret") {
+			t.Errorf("%s: leaked a file outside of the root", tt.name)
+		}
+	}
+}
+
+// TestTools_DownloadFile_traversal tests that DownloadFile does not leave its
+// directory with ".." segments.
+func TestTools_DownloadFile_traversal(t *testing.T) {
+	base := t.TempDir()
+	mustWriteFiles(t, base, map[string]string{"secret.txt": "secret", "public/a.txt": "alpha"})
+
+	rr := httptest.NewRecorder()
+	New().DownloadFile(rr, httptest.NewRequest(http.MethodGet, "/", nil), filepath.Join(base, "public"), "../secret.txt", "a.txt")
+	if rr.Code != http.StatusNotFound || strings.Contains(rr.Body.String(), "secret") {
+		t.Errorf("expected 404, got %d %q", rr.Code, rr.Body)
+	}
+}
+
+// TestTools_SymlinkPolicy_uploads tests that files are never created through
+// symbolic links.
+func TestTools_SymlinkPolicy_uploads(t *testing.T) {
+	base := t.TempDir()
+	mustWriteFiles(t, base, map[string]string{"other/victim.txt": "untouched", "uploads/.keep": ""})
+	if err := os.Symlink(filepath.Join(base, "other", "victim.txt"), filepath.Join(base, "uploads", "a.txt")); err != nil {
+		t.Skip("symbolic links are not supported:", err)
+	}
+	if err := os.Symlink(filepath.Join(base, "other"), filepath.Join(base, "shared")); err != nil {
+		t.Fatal(err)
+	}
+
+	testTools := New()
+	ctx := context.Background()
+	if err := testTools.storeFile(ctx, filepath.Join(base, "uploads"), &UploadedFile{NewFileName: "a.txt"}, strings.NewReader("upload"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(base, "other", "victim.txt")); string(data) != "untouched" {
+		t.Errorf("expected the link target to be untouched, got %q", data)
+	}
+	if info, err := os.Lstat(filepath.Join(base, "uploads", "a.txt")); err != nil || !info.Mode().IsRegular() {
+		t.Errorf("expected the link to be replaced by the file, got %v", err)
+	}
+
+	if err := testTools.storeFile(ctx, filepath.Join(base, "shared"), &UploadedFile{NewFileName: "b.txt"}, strings.NewReader("upload"), nil); err != nil {
+		t.Errorf("expected SymlinkContain to allow linked upload directories, got %v", err)
+	}
+
+	testTools.SymlinkPolicy = SymlinkRefuse
+	if err := testTools.storeFile(ctx, filepath.Join(base, "shared"), &UploadedFile{NewFileName: "c.txt"}, strings.NewReader("upload"), nil); !errors.Is(err, ErrSymlink) {
+		t.Errorf("expected ErrSymlink, got %v", err)
+	}
+	if err := testTools.CreateDirIfNotExists(filepath.Join(base, "shared", "new")); !errors.Is(err, ErrSymlink) {
+		t.Errorf("expected ErrSymlink, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(base, "other", "new")); !os.IsNotExist(err) {
+		t.Error("expected no directory to be created through the link")
+	}
+	if err := testTools.CreateDirIfNotExists(filepath.Join(base, "uploads", "new")); err != nil {
+		t.Errorf("expected directories without links to be created, got %v", err)
+	}
+}
+
+// TestOpenNoFollow tests that openNoFollow refuses symbolic links.
+func TestOpenNoFollow(t *testing.T) {
+	dir := t.TempDir()
+	mustWriteFiles(t, dir, map[string]string{"a.part": "data"})
+	if err := os.Symlink(filepath.Join(dir, "a.part"), filepath.Join(dir, "b.part")); err != nil {
+		t.Skip("symbolic links are not supported:", err)
+	}
+
+	f, err := openNoFollow(filepath.Join(dir, "a.part"), os.O_WRONLY|os.O_APPEND)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if _, err := openNoFollow(filepath.Join(dir, "b.part"), os.O_WRONLY|os.O_APPEND); !errors.Is(err, ErrSymlink) {
+		t.Errorf("expected ErrSymlink, got %v", err)
+	}
+}
+
+// mustWriteFiles writes the files of the slash-separated names of files below
+// dir, with their content, or fails the test.
+func mustWriteFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
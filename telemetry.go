@@ -0,0 +1,185 @@
+package gorigumi
+
+import (
+	"errors"
+	"maps"
+	"net/http"
+	"strings"
+)
+
+const (
+	// maxClientErrorReasons caps the number of reasons counted per route and kind,
+	// as reasons are derived from the requests. Further reasons are counted as
+	// otherClientErrorReason
+	maxClientErrorReasons int = 100
+
+	// otherClientErrorReason is the reason of the client errors past the cap
+	otherClientErrorReason string = "other"
+
+	// unmatchedRoute is the route of the requests matching no pattern, so
+	// arbitrary paths don't each get their own route
+	unmatchedRoute string = "unmatched"
+)
+
+// ClientErrorKind is a kind of mistake made by clients, counted by Metrics.
+type ClientErrorKind int
+
+const (
+	// ValidationFailure is a field failing validation, counted by field and
+	// message, e.g. "email: is required". The indexes of paths are removed, so
+	// "items[2].quantity" and "items[3].quantity" are counted as
+	// "items[].quantity"
+	ValidationFailure ClientErrorKind = iota
+	// RejectedUploadType is an uploaded file rejected for its type or
+	// extension, counted by sniffed content type, e.g. "application/pdf"
+	RejectedUploadType
+	// JSONReadFailure is a JSON body that could not be read, counted by error
+	// code, e.g. "invalid_json" or "payload_too_large"
+	JSONReadFailure
+)
+
+// ClientErrorStats counts the mistakes made by the clients of a route, by reason,
+// e.g. to find out which ones are the most common and fix the SDKs making them.
+type ClientErrorStats struct {
+	Validation  map[string]int `json:"validation,omitempty"`
+	UploadTypes map[string]int `json:"uploadTypes,omitempty"`
+	JSONRead    map[string]int `json:"jsonRead,omitempty"`
+}
+
+// counters returns the counters of kind.
+func (s *ClientErrorStats) counters(kind ClientErrorKind) *map[string]int {
+	switch kind {
+	case ValidationFailure:
+		return &s.Validation
+	case RejectedUploadType:
+		return &s.UploadTypes
+	default:
+		return &s.JSONRead
+	}
+}
+
+// CountClientError counts a client error of kind with reason on route. Set the
+// Metrics field of the Tools struct to count the errors of JSONRead and of
+// uploads. The counters are kept since the creation of m, not over the budget
+// window, and at most 100 reasons are counted per route and kind, the others
+// being counted as "other".
+func (m *Metrics) CountClientError(route string, kind ClientErrorKind, reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.clientErrors == nil {
+		m.clientErrors = make(map[string]*ClientErrorStats)
+	}
+	stats, ok := m.clientErrors[route]
+	if !ok {
+		stats = &ClientErrorStats{}
+		m.clientErrors[route] = stats
+	}
+
+	counters := stats.counters(kind)
+	if *counters == nil {
+		*counters = make(map[string]int)
+	}
+	if _, ok := (*counters)[reason]; !ok && len(*counters) >= maxClientErrorReasons {
+		reason = otherClientErrorReason
+	}
+	(*counters)[reason]++
+}
+
+// ClientErrors returns the client errors counted on every route.
+func (m *Metrics) ClientErrors() map[string]ClientErrorStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	errs := make(map[string]ClientErrorStats, len(m.clientErrors))
+	for route, stats := range m.clientErrors {
+		errs[route] = ClientErrorStats{
+			Validation:  maps.Clone(stats.Validation),
+			UploadTypes: maps.Clone(stats.UploadTypes),
+			JSONRead:    maps.Clone(stats.JSONRead),
+		}
+	}
+	return errs
+}
+
+// countReadError counts err, returned by reading the JSON body of r, in the
+// Metrics of the Tools struct, as validation failures or as a read failure.
+func (t *Tools) countReadError(r *http.Request, err error) {
+	if t.Metrics == nil || err == nil {
+		return
+	}
+	route := requestRoute(r)
+
+	var errs ValidationErrors
+	if errors.As(err, &errs) {
+		for _, fe := range errs {
+			t.Metrics.CountClientError(route, ValidationFailure, fieldReason(fe))
+		}
+		return
+	}
+
+	code := ErrorCode(err)
+	if code == "" {
+		code = otherClientErrorReason
+	}
+	t.Metrics.CountClientError(route, JSONReadFailure, code)
+}
+
+// countUploadError counts the file type rejected by err, returned by an upload
+// of r, in the Metrics of the Tools struct.
+func (t *Tools) countUploadError(r *http.Request, err error) {
+	if t.Metrics == nil {
+		return
+	}
+	var typeErr *fileTypeError
+	if errors.As(err, &typeErr) {
+		t.Metrics.CountClientError(requestRoute(r), RejectedUploadType, typeErr.fileType)
+	}
+}
+
+// fieldReason returns the field and the message of fe without the indexes of
+// the field path.
+func fieldReason(fe FieldError) string {
+	var field strings.Builder
+	inIndex := false
+	for _, r := range fe.Field {
+		switch {
+		case r == '[':
+			inIndex = true
+		case r == ']':
+			inIndex = false
+		case inIndex:
+			continue
+		}
+		field.WriteRune(r)
+	}
+
+	fe.Field = field.String()
+	return fe.Error()
+}
+
+// fileTypeError is the error of an uploaded file rejected for its type, which
+// holds the sniffed type for the Metrics of the Tools struct.
+type fileTypeError struct {
+	fileType string
+	err      error
+}
+
+// Error returns the message of the error.
+func (e *fileTypeError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *fileTypeError) Unwrap() error {
+	return e.err
+}
+
+// requestRoute returns the route of r, the pattern matched by an http.ServeMux,
+// or unmatchedRoute if no pattern is available.
+func requestRoute(r *http.Request) string {
+	if r.Pattern != "" {
+		return r.Pattern
+	}
+	return unmatchedRoute
+}
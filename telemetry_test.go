@@ -0,0 +1,114 @@
+package gorigumi
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// clientErrorTests is a slice of structs that hold the name of the test, the body of the
+// request, the kind of the expected client error and its reason
+var clientErrorTests = []struct {
+	name   string
+	body   string
+	kind   ClientErrorKind
+	reason string
+}{
+	{"badly-formed JSON", `{"email":`, JSONReadFailure, CodeInvalidJSON},
+	{"too large", `{"email":"` + strings.Repeat("a", 2<<20) + `"}`, JSONReadFailure, CodePayloadTooLarge},
+	{"missing field", `{}`, ValidationFailure, "email: is required"},
+	{"nested field", `{"email":"a@b.c","items":[{"qty":1},{}]}`, ValidationFailure, "items[].qty: is required"},
+}
+
+// TestTools_countReadError tests that the errors of JSONRead are counted by route and
+// reason in the Metrics of the Tools struct.
+func TestTools_countReadError(t *testing.T) {
+	type item struct {
+		Qty int `json:"qty" validate:"required"`
+	}
+	type signup struct {
+		Email string `json:"email" validate:"required,email"`
+		Items []item `json:"items"`
+	}
+
+	for _, ct := range clientErrorTests {
+		testTools := New()
+		testTools.ValidateTags = true
+		testTools.Metrics = NewMetrics()
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("POST /signup", func(w http.ResponseWriter, r *http.Request) {
+			var s signup
+			_ = testTools.JSONRead(w, r, &s)
+		})
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/signup", strings.NewReader(ct.body)))
+
+		stats := testTools.Metrics.ClientErrors()["POST /signup"]
+		counters := stats.counters(ct.kind)
+		if len(*counters) != 1 || (*counters)[ct.reason] != 1 {
+			t.Errorf("%s: expected %q to be counted once, got %+v", ct.name, ct.reason, stats)
+		}
+	}
+}
+
+// TestTools_countUploadError tests that files rejected for their type are counted by
+// sniffed content type.
+func TestTools_countUploadError(t *testing.T) {
+	testTools := New()
+	testTools.AllowedFileTypes = []string{"image/png"}
+	testTools.Metrics = NewMetrics()
+	dir := t.TempDir()
+
+	uploads := map[string]func(r *http.Request) error{
+		"UploadFiles": func(r *http.Request) error {
+			_, err := testTools.UploadFiles(r, dir)
+			return err
+		},
+		"UploadFiles dry run": func(r *http.Request) error {
+			_, err := testTools.UploadFiles(r.WithContext(WithDryRun(r.Context())), dir)
+			return err
+		},
+		"UploadFile": func(r *http.Request) error {
+			_, err := testTools.UploadFile(r, dir)
+			return err
+		},
+		"UploadFileCtx dry run": func(r *http.Request) error {
+			_, err := testTools.UploadFileCtx(WithDryRun(r.Context()), r, dir)
+			return err
+		},
+	}
+
+	for name, upload := range uploads {
+		err := upload(newMultipartRequest(t, map[string][]byte{"a.txt": []byte("hello")}))
+		if ErrorCode(err) != CodeUnsupportedMediaType {
+			t.Fatalf("%s: expected code %s, got %v", name, CodeUnsupportedMediaType, err)
+		}
+	}
+
+	got := testTools.Metrics.ClientErrors()["unmatched"].UploadTypes
+	if len(got) != 1 || got["text/plain; charset=utf-8"] != len(uploads) {
+		t.Errorf("expected text/plain to be counted %d times, got %v", len(uploads), got)
+	}
+}
+
+// TestMetrics_CountClientError tests that the reasons counted per route and kind are
+// capped, and that ClientErrors returns copies of the counters.
+func TestMetrics_CountClientError(t *testing.T) {
+	m := NewMetrics()
+	for i := range maxClientErrorReasons + 5 {
+		m.CountClientError("POST /items", JSONReadFailure, fmt.Sprintf("reason %d", i))
+	}
+	m.CountClientError("POST /items", JSONReadFailure, "reason 0")
+
+	got := m.ClientErrors()["POST /items"].JSONRead
+	if len(got) != maxClientErrorReasons+1 || got[otherClientErrorReason] != 5 || got["reason 0"] != 2 {
+		t.Errorf("expected %d reasons and 5 others, got %d reasons and %d others", maxClientErrorReasons, len(got), got[otherClientErrorReason])
+	}
+
+	got["reason 0"] = 100
+	if m.ClientErrors()["POST /items"].JSONRead["reason 0"] != 2 {
+		t.Error("expected ClientErrors to return a copy of the counters")
+	}
+}
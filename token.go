@@ -0,0 +1,185 @@
+package gorigumi
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrInvalidToken is returned when a signed token is malformed, was not
+	// signed with the TokenSecret of the Tools struct, or was issued for
+	// another purpose.
+	ErrInvalidToken = WithErrorCode(errors.New("invalid token"), CodeInvalidToken)
+	// ErrTokenExpired is returned when a signed token is valid but expired.
+	ErrTokenExpired = WithErrorCode(errors.New("token expired"), CodeTokenExpired)
+	// ErrTokenUsed is returned when a single-use token was already used.
+	ErrTokenUsed = WithErrorCode(errors.New("token already used"), CodeInvalidToken)
+)
+
+const (
+	// tokenIDLength is the length of the random id of single-use tokens
+	tokenIDLength int = 22
+)
+
+// TokenStore records the single-use tokens, such as password reset tokens,
+// that were used. Implementations backed by a database or a cache let several
+// instances of an application share it.
+type TokenStore interface {
+	// Consume marks the token id as used until expires, the time after
+	// which it is rejected anyway, and reports false if it already was
+	Consume(ctx context.Context, id string, expires time.Time) (bool, error)
+}
+
+// MemoryTokenStore is a TokenStore keeping used tokens in memory, suitable for
+// a single instance. Use NewMemoryTokenStore to create one.
+type MemoryTokenStore struct {
+	clock Clock
+
+	mu   sync.Mutex
+	used map[string]time.Time
+}
+
+// NewMemoryTokenStore returns a new MemoryTokenStore using clock to forget
+// expired tokens. Default to SystemClock if clock is nil.
+func NewMemoryTokenStore(clock Clock) *MemoryTokenStore {
+	if clock == nil {
+		clock = SystemClock
+	}
+	return &MemoryTokenStore{clock: clock, used: make(map[string]time.Time)}
+}
+
+// Consume implements TokenStore.
+func (s *MemoryTokenStore) Consume(ctx context.Context, id string, expires time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	for usedID, usedExpires := range s.used {
+		if now.After(usedExpires) {
+			delete(s.used, usedID)
+		}
+	}
+
+	if _, ok := s.used[id]; ok {
+		return false, nil
+	}
+	s.used[id] = expires
+	return true, nil
+}
+
+// signToken returns a URL safe token carrying subject, valid for ttl, signed
+// with TokenSecret. The signature covers purpose, so a token issued for one
+// flow is rejected by the others, and binding, which is not part of the token:
+// a token only verifies with the same binding, so it can be tied to state that
+// changes once it was used, e.g. a password hash.
+func (t *Tools) signToken(purpose, subject, binding string, ttl time.Duration) (string, error) {
+	if len(t.TokenSecret) == 0 {
+		return "", errors.New("TokenSecret is not set")
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString([]byte(subject)) + "." +
+		strconv.FormatInt(t.clock().Now().Add(ttl).Unix(), 10)
+
+	return payload + "." + t.tokenSignature(purpose, payload, binding), nil
+}
+
+// verifyToken checks a token returned by signToken for the same purpose and
+// binding, and returns its subject and expiry time.
+func (t *Tools) verifyToken(purpose, token, binding string) (string, time.Time, error) {
+	if len(t.TokenSecret) == 0 {
+		return "", time.Time{}, errors.New("TokenSecret is not set")
+	}
+
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 {
+		return "", time.Time{}, ErrInvalidToken
+	}
+	payload, sig := token[:i], token[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(t.tokenSignature(purpose, payload, binding))) {
+		return "", time.Time{}, ErrInvalidToken
+	}
+
+	encoded, expires, ok := strings.Cut(payload, ".")
+	if !ok {
+		return "", time.Time{}, ErrInvalidToken
+	}
+	subject, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", time.Time{}, ErrInvalidToken
+	}
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return "", time.Time{}, ErrInvalidToken
+	}
+	expiry := time.Unix(unix, 0)
+	if t.clock().Now().After(expiry) {
+		return "", time.Time{}, ErrTokenExpired
+	}
+
+	return string(subject), expiry, nil
+}
+
+// signOneTimeToken is like signToken, but the token carries a random id so it
+// can be consumed once with consumeToken.
+func (t *Tools) signOneTimeToken(purpose, subject, binding string, ttl time.Duration) (string, error) {
+	return t.signToken(purpose, t.GenerateRandomString(tokenIDLength)+":"+subject, binding, ttl)
+}
+
+// consumeToken verifies a token returned by signOneTimeToken, marks it as used
+// in TokenStore and returns its subject. Tokens already used are rejected with
+// ErrTokenUsed.
+func (t *Tools) consumeToken(ctx context.Context, purpose, token, binding string) (string, error) {
+	id, subject, expiry, err := t.verifyOneTimeToken(purpose, token, binding)
+	if err != nil {
+		return "", err
+	}
+	if err := t.markTokenUsed(ctx, purpose, id, expiry); err != nil {
+		return "", err
+	}
+	return subject, nil
+}
+
+// verifyOneTimeToken verifies a token returned by signOneTimeToken without
+// consuming it, and returns its id, subject and expiry time.
+func (t *Tools) verifyOneTimeToken(purpose, token, binding string) (string, string, time.Time, error) {
+	payload, expiry, err := t.verifyToken(purpose, token, binding)
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	id, subject, ok := strings.Cut(payload, ":")
+	if !ok {
+		return "", "", time.Time{}, ErrInvalidToken
+	}
+	return id, subject, expiry, nil
+}
+
+// markTokenUsed records the token id of purpose as used in TokenStore, or
+// returns ErrTokenUsed if it already was.
+func (t *Tools) markTokenUsed(ctx context.Context, purpose, id string, expiry time.Time) error {
+	if t.TokenStore == nil {
+		return errors.New("TokenStore is not set")
+	}
+
+	fresh, err := t.TokenStore.Consume(ctx, purpose+":"+id, expiry)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrTokenUsed
+	}
+	return nil
+}
+
+// tokenSignature returns the URL safe HMAC of a token payload.
+func (t *Tools) tokenSignature(purpose, payload, binding string) string {
+	mac := hmac.New(sha256.New, t.TokenSecret)
+	mac.Write([]byte(purpose + "\n" + payload + "\n" + binding))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
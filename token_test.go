@@ -0,0 +1,82 @@
+package gorigumi
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/drunkleen/gorigumi/toolkittest"
+)
+
+// tokenTests is a slice of structs that hold the name of the test, the purpose and binding
+// a token is verified with, the time elapsed since it was signed, a function altering it,
+// and the expected error
+var tokenTests = []struct {
+	name    string
+	purpose string
+	binding string
+	elapsed time.Duration
+	alter   func(string) string
+	err     error
+}{
+	{"valid", "test", "state", time.Minute, nil, nil},
+	{"expired", "test", "state", time.Hour + time.Second, nil, ErrTokenExpired},
+	{"other purpose", "other", "state", time.Minute, nil, ErrInvalidToken},
+	{"other binding", "test", "changed", time.Minute, nil, ErrInvalidToken},
+	{"tampered", "test", "state", time.Minute, func(s string) string { return "Y" + s[1:] }, ErrInvalidToken},
+	{"truncated", "test", "state", time.Minute, func(s string) string { return s[:len(s)-2] }, ErrInvalidToken},
+	{"garbage", "test", "state", time.Minute, func(string) string { return "garbage" }, ErrInvalidToken},
+}
+
+// TestTools_signToken tests that signed tokens only verify for their purpose and binding,
+// unaltered and before they expire.
+func TestTools_signToken(t *testing.T) {
+	for _, tt := range tokenTests {
+		clock := toolkittest.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		testTools := New()
+		testTools.Clock = clock
+		testTools.TokenSecret = []byte("secret")
+
+		token, err := testTools.signToken("test", "user@example.com", "state", time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.alter != nil {
+			token = tt.alter(token)
+		}
+		clock.Advance(tt.elapsed)
+
+		subject, _, err := testTools.verifyToken(tt.purpose, token, tt.binding)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.err, err)
+		}
+		if tt.err == nil && subject != "user@example.com" {
+			t.Errorf("%s: unexpected subject %q", tt.name, subject)
+		}
+	}
+
+	if _, err := New().signToken("test", "subject", "", time.Hour); err == nil {
+		t.Error("expected an error without TokenSecret")
+	}
+}
+
+// TestMemoryTokenStore tests that tokens are consumed once and forgotten once expired.
+func TestMemoryTokenStore(t *testing.T) {
+	clock := toolkittest.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	store := NewMemoryTokenStore(clock)
+	ctx := context.Background()
+
+	if ok, _ := store.Consume(ctx, "a", clock.Now().Add(time.Minute)); !ok {
+		t.Error("expected first use to succeed")
+	}
+	if ok, _ := store.Consume(ctx, "a", clock.Now().Add(time.Minute)); ok {
+		t.Error("expected second use to fail")
+	}
+
+	clock.Advance(2 * time.Minute)
+	store.Consume(ctx, "b", clock.Now().Add(time.Minute))
+	if len(store.used) != 1 {
+		t.Errorf("expected expired tokens to be forgotten, got %v", store.used)
+	}
+}
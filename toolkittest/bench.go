@@ -0,0 +1,126 @@
+package toolkittest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// BenchmarkResult holds the measurements of a single benchmark as printed by
+// go test -bench -benchmem. Results of repeated runs (-count) are averaged.
+type BenchmarkResult struct {
+	Name        string
+	Runs        int
+	NsPerOp     float64
+	BytesPerOp  float64
+	AllocsPerOp float64
+}
+
+// BenchmarkDelta compares the results of one benchmark between two runs.
+// The changes are fractions of the old value, e.g. 0.1 for 10% slower.
+type BenchmarkDelta struct {
+	Name         string
+	Old, New     BenchmarkResult
+	NsChange     float64
+	AllocsChange float64
+	Regressed    bool
+}
+
+// String formats the delta as a single human-readable line.
+func (d BenchmarkDelta) String() string {
+	return fmt.Sprintf("%s: %.0f ns/op -> %.0f ns/op (%+.1f%%), %.0f allocs/op -> %.0f allocs/op (%+.1f%%)",
+		d.Name, d.Old.NsPerOp, d.New.NsPerOp, d.NsChange*100,
+		d.Old.AllocsPerOp, d.New.AllocsPerOp, d.AllocsChange*100)
+}
+
+// ParseBenchmarks reads the output of go test -bench and returns the results keyed
+// by benchmark name, without the GOMAXPROCS suffix. Lines that are not benchmark
+// results are ignored.
+func ParseBenchmarks(r io.Reader) (map[string]BenchmarkResult, error) {
+	results := make(map[string]BenchmarkResult)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[1]); err != nil {
+			continue
+		}
+
+		name := fields[0]
+		if i := strings.LastIndex(name, "-"); i > 0 {
+			if _, err := strconv.Atoi(name[i+1:]); err == nil {
+				name = name[:i]
+			}
+		}
+
+		res := results[name]
+		res.Name = name
+		for i := 2; i+1 < len(fields); i += 2 {
+			value, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("benchmark %s: invalid value %q", name, fields[i])
+			}
+			switch fields[i+1] {
+			case "ns/op":
+				res.NsPerOp = average(res.NsPerOp, value, res.Runs)
+			case "B/op":
+				res.BytesPerOp = average(res.BytesPerOp, value, res.Runs)
+			case "allocs/op":
+				res.AllocsPerOp = average(res.AllocsPerOp, value, res.Runs)
+			}
+		}
+		res.Runs++
+		results[name] = res
+	}
+
+	return results, scanner.Err()
+}
+
+// average adds value to the running average avg of n values.
+func average(avg, value float64, n int) float64 {
+	return (avg*float64(n) + value) / float64(n+1)
+}
+
+// CompareBenchmarks compares the benchmarks present in both old and new, sorted by
+// name. A benchmark regressed if its time or allocations per operation grew by more
+// than threshold, e.g. 0.1 for 10%.
+func CompareBenchmarks(old, new map[string]BenchmarkResult, threshold float64) []BenchmarkDelta {
+	var deltas []BenchmarkDelta
+	for name, o := range old {
+		n, ok := new[name]
+		if !ok {
+			continue
+		}
+
+		d := BenchmarkDelta{
+			Name:         name,
+			Old:          o,
+			New:          n,
+			NsChange:     change(o.NsPerOp, n.NsPerOp),
+			AllocsChange: change(o.AllocsPerOp, n.AllocsPerOp),
+		}
+		d.Regressed = d.NsChange > threshold || d.AllocsChange > threshold
+		deltas = append(deltas, d)
+	}
+
+	sort.Slice(deltas, func(i, j int) bool { return deltas[i].Name < deltas[j].Name })
+	return deltas
+}
+
+// change returns the relative change from old to new. Growing from zero counts
+// as a 100% increase.
+func change(old, new float64) float64 {
+	if old == 0 {
+		if new == 0 {
+			return 0
+		}
+		return 1
+	}
+	return (new - old) / old
+}
@@ -0,0 +1,61 @@
+package toolkittest
+
+import (
+	"strings"
+	"testing"
+)
+
+// benchOld and benchNew are sample outputs of go test -bench -benchmem
+const (
+	benchOld = `goos: linux
+BenchmarkSlug-8        1000000    1000 ns/op    64 B/op    2 allocs/op
+BenchmarkSlug-8        1000000    1200 ns/op    64 B/op    2 allocs/op
+BenchmarkRandom-8       500000    3000 ns/op   128 B/op    4 allocs/op
+BenchmarkGone-8         500000    3000 ns/op
+PASS`
+	benchNew = `BenchmarkSlug-8        1000000    1150 ns/op    64 B/op    2 allocs/op
+BenchmarkRandom-8      1000000     300 ns/op    32 B/op    5 allocs/op
+BenchmarkAdded-8       1000000     300 ns/op
+ok  	github.com/drunkleen/gorigumi	3.2s`
+)
+
+// TestParseBenchmarks tests that results are parsed, named without the GOMAXPROCS
+// suffix, and averaged over repeated runs.
+func TestParseBenchmarks(t *testing.T) {
+	results, err := ParseBenchmarks(strings.NewReader(benchOld))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 3 {
+		t.Fatalf("expected 3 benchmarks, got %d", len(results))
+	}
+	slug := results["BenchmarkSlug"]
+	if slug.Runs != 2 || slug.NsPerOp != 1100 || slug.BytesPerOp != 64 || slug.AllocsPerOp != 2 {
+		t.Errorf("unexpected result %+v", slug)
+	}
+
+	if _, err := ParseBenchmarks(strings.NewReader("BenchmarkX-8 10 abc ns/op")); err == nil {
+		t.Error("expected error for an invalid value")
+	}
+}
+
+// TestCompareBenchmarks tests that only benchmarks present in both runs are compared
+// and that growth in time or allocations beyond the threshold is a regression.
+func TestCompareBenchmarks(t *testing.T) {
+	old, _ := ParseBenchmarks(strings.NewReader(benchOld))
+	new, _ := ParseBenchmarks(strings.NewReader(benchNew))
+
+	deltas := CompareBenchmarks(old, new, 0.1)
+	if len(deltas) != 2 {
+		t.Fatalf("expected 2 deltas, got %d", len(deltas))
+	}
+
+	// sorted by name: BenchmarkRandom, BenchmarkSlug
+	if !deltas[0].Regressed || deltas[0].AllocsChange != 0.25 {
+		t.Errorf("expected allocation regression, got %s", deltas[0])
+	}
+	if deltas[1].Regressed {
+		t.Errorf("expected no regression within threshold, got %s", deltas[1])
+	}
+}
@@ -0,0 +1,139 @@
+// Package toolkittest provides helpers for testing code built on gorigumi,
+// such as a controllable clock. It does not import gorigumi, so the tests of
+// gorigumi itself can use it.
+package toolkittest
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is a manually driven clock implementing gorigumi.Clock. Time only
+// moves when Advance or Set is called, which fires the timers that are due.
+type Clock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*clockTimer
+}
+
+// clockTimer is a pending timer of a Clock.
+type clockTimer struct {
+	at    time.Time
+	ch    chan time.Time
+	fn    func()
+	fired bool
+}
+
+// NewClock returns a new Clock set to now.
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now returns the current time of the clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// After returns a channel receiving the time of the clock once it has been
+// advanced by at least d.
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	c.schedule(d, &clockTimer{ch: ch})
+	return ch
+}
+
+// AfterFunc calls f in its own goroutine once the clock has been advanced by
+// at least d. The returned function cancels the call and reports whether it
+// was still pending.
+func (c *Clock) AfterFunc(d time.Duration, f func()) func() bool {
+	t := &clockTimer{fn: f}
+	c.schedule(d, t)
+
+	return func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		for i, pending := range c.timers {
+			if pending == t {
+				c.timers = append(c.timers[:i], c.timers[i+1:]...)
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Advance moves the clock forward by d and fires the timers that are due.
+func (c *Clock) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+// Set moves the clock to now and fires the timers that are due. The clock
+// never moves backwards.
+func (c *Clock) Set(now time.Time) {
+	c.mu.Lock()
+	if now.After(c.now) {
+		c.now = now
+	}
+
+	var due []*clockTimer
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if !t.at.After(c.now) {
+			due = append(due, t)
+		} else {
+			pending = append(pending, t)
+		}
+	}
+	c.timers = pending
+	now = c.now
+	c.mu.Unlock()
+
+	for _, t := range due {
+		t.fire(now)
+	}
+}
+
+// Pending returns the number of timers waiting for the clock to advance.
+func (c *Clock) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.timers)
+}
+
+// schedule registers t to fire once d elapsed, or fires it immediately if d
+// is not positive.
+func (c *Clock) schedule(d time.Duration, t *clockTimer) {
+	c.mu.Lock()
+	t.at = c.now.Add(d)
+	if d <= 0 {
+		now := c.now
+		c.mu.Unlock()
+		t.fire(now)
+		return
+	}
+
+	c.timers = append(c.timers, t)
+	sort.SliceStable(c.timers, func(i, j int) bool { return c.timers[i].at.Before(c.timers[j].at) })
+	c.mu.Unlock()
+}
+
+// fire delivers the timer.
+func (t *clockTimer) fire(now time.Time) {
+	if t.fired {
+		return
+	}
+	t.fired = true
+
+	if t.ch != nil {
+		t.ch <- now
+	}
+	if t.fn != nil {
+		go t.fn()
+	}
+}
@@ -0,0 +1,55 @@
+package toolkittest
+
+import (
+	"testing"
+	"time"
+)
+
+// TestClock_Advance tests that timers and channels of a Clock fire only once the
+// clock has been advanced past their deadline, and that cancelled calls never run.
+func TestClock_Advance(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewClock(start)
+
+	ch := c.After(time.Minute)
+	ran := make(chan struct{}, 1)
+	c.AfterFunc(2*time.Minute, func() { ran <- struct{}{} })
+	stop := c.AfterFunc(time.Minute, func() { t.Error("expected cancelled call not to run") })
+
+	if !stop() {
+		t.Error("expected pending call to be cancelled")
+	}
+
+	c.Advance(30 * time.Second)
+	select {
+	case <-ch:
+		t.Fatal("expected channel not to fire before its deadline")
+	default:
+	}
+
+	c.Advance(30 * time.Second)
+	select {
+	case now := <-ch:
+		if !now.Equal(start.Add(time.Minute)) {
+			t.Errorf("expected %s, got %s", start.Add(time.Minute), now)
+		}
+	default:
+		t.Fatal("expected channel to fire at its deadline")
+	}
+
+	c.Advance(time.Minute)
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("expected function to run at its deadline")
+	}
+
+	if c.Pending() != 0 {
+		t.Errorf("expected no pending timers, got %d", c.Pending())
+	}
+
+	c.Set(start)
+	if !c.Now().Equal(start.Add(2 * time.Minute)) {
+		t.Error("expected clock not to move backwards")
+	}
+}
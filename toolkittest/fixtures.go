@@ -0,0 +1,69 @@
+package toolkittest
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"strings"
+)
+
+// GenerateImage returns a deterministic w x h image encoded in the given format,
+// "png", "jpeg" (or "jpg") or "gif". The image is a color gradient, so encoders
+// cannot reduce it to a trivial file.
+func GenerateImage(w, h int, format string) ([]byte, error) {
+	if w <= 0 || h <= 0 {
+		return nil, fmt.Errorf("invalid image size %dx%d", w, h)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, color.RGBA{
+				R: uint8(x * 255 / max(w-1, 1)),
+				G: uint8(y * 255 / max(h-1, 1)),
+				B: uint8((x + y) % 256),
+				A: 255,
+			})
+		}
+	}
+
+	var buf bytes.Buffer
+	var err error
+	switch strings.ToLower(format) {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "jpeg", "jpg":
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90})
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	default:
+		return nil, fmt.Errorf("unsupported image format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// GenerateFile returns size bytes made of pattern repeated. An empty pattern
+// produces a deterministic sequence of all byte values.
+func GenerateFile(size int, pattern []byte) []byte {
+	if len(pattern) == 0 {
+		pattern = make([]byte, 256)
+		for i := range pattern {
+			pattern[i] = byte(i)
+		}
+	}
+
+	data := make([]byte, size)
+	for i := 0; i < size; i += len(pattern) {
+		copy(data[i:], pattern)
+	}
+
+	return data
+}
@@ -0,0 +1,75 @@
+package toolkittest
+
+import (
+	"bytes"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+	"testing"
+)
+
+// imageTests is a slice of structs that hold the name of the test, the requested format,
+// the expected sniffed content type and a boolean that indicates if an error is expected
+var imageTests = []struct {
+	name          string
+	format        string
+	contentType   string
+	errorExpected bool
+}{
+	{"png", "png", "image/png", false},
+	{"jpeg", "jpeg", "image/jpeg", false},
+	{"jpg alias", "JPG", "image/jpeg", false},
+	{"gif", "gif", "image/gif", false},
+	{"unsupported", "bmp", "", true},
+}
+
+// TestGenerateImage tests that generated images decode with the requested size and are
+// sniffed with the expected content type.
+func TestGenerateImage(t *testing.T) {
+	for _, it := range imageTests {
+		data, err := GenerateImage(40, 30, it.format)
+		if it.errorExpected {
+			if err == nil {
+				t.Errorf("%s: expected error but got none", it.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", it.name, err)
+			continue
+		}
+
+		if ct := http.DetectContentType(data); ct != it.contentType {
+			t.Errorf("%s: expected content type %s, got %s", it.name, it.contentType, ct)
+		}
+
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil || cfg.Width != 40 || cfg.Height != 30 {
+			t.Errorf("%s: expected a 40x30 image, got %+v, %v", it.name, cfg, err)
+		}
+
+		again, _ := GenerateImage(40, 30, it.format)
+		if !bytes.Equal(data, again) {
+			t.Errorf("%s: expected deterministic output", it.name)
+		}
+	}
+
+	if _, err := GenerateImage(0, 10, "png"); err == nil {
+		t.Error("expected error for an empty image")
+	}
+}
+
+// TestGenerateFile tests the size and content of generated files.
+func TestGenerateFile(t *testing.T) {
+	data := GenerateFile(10, []byte("abc"))
+	if string(data) != "abcabcabca" {
+		t.Errorf("expected repeated pattern, got %q", data)
+	}
+
+	data = GenerateFile(300, nil)
+	if len(data) != 300 || data[255] != 255 || data[256] != 0 {
+		t.Error("expected the default pattern to cycle through all byte values")
+	}
+}
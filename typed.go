@@ -0,0 +1,41 @@
+package gorigumi
+
+import "net/http"
+
+// JSONResponseOf is the envelope of JSON responses whose payload is of type T,
+// the typed counterpart of JSONResponse, e.g. to decode the responses of other
+// services with JSONPushToRemote. It encodes like JSONResponse.
+type JSONResponseOf[T any] struct {
+	Error   bool   `json:"error,omitempty"`
+	Message string `json:"message,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Data    T      `json:"data,omitempty"`
+}
+
+// JSONReadAs reads the JSON request body into a new value of type T, like the
+// JSONRead method of t, and returns it, so no destination variable has to be
+// declared:
+//
+//	user, err := gorigumi.JSONReadAs[User](tools, w, r)
+//
+// It is a function rather than a method of Tools as methods can't have type
+// parameters. On error, the zero value of T is returned.
+func JSONReadAs[T any](t *Tools, w http.ResponseWriter, r *http.Request) (T, error) {
+	var v T
+	if err := t.JSONRead(w, r, &v); err != nil {
+		var zero T
+		return zero, err
+	}
+	return v, nil
+}
+
+// JSONWriteData writes data as the Data of a JSONResponseOf[T], with the
+// specified HTTP status code and the optional headers, like the JSONWrite method
+// of t, or in the format of the Envelope of t if set. It is a function rather than a method of Tools as methods can't have type
+// parameters.
+func JSONWriteData[T any](t *Tools, w http.ResponseWriter, status int, data T, headers ...http.Header) error {
+	if t.Envelope != nil {
+		return t.JSONWrite(w, status, JSONResponse{Data: data}, headers...)
+	}
+	return t.JSONWrite(w, status, JSONResponseOf[T]{Data: data}, headers...)
+}
@@ -0,0 +1,64 @@
+package gorigumi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// typedUser is the payload of the typed JSON tests.
+type typedUser struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+// typedReadTests is a slice of structs that hold the name of the test, the body and
+// a boolean that indicates if an error is expected
+var typedReadTests = []struct {
+	name          string
+	body          string
+	errorExpected bool
+}{
+	{"valid", `{"name":"jane","age":30}`, false},
+	{"unknown field", `{"name":"jane","email":"jane@example.com"}`, true},
+	{"badly formatted", `{"name":`, true},
+}
+
+// TestJSONReadAs tests that JSONReadAs returns the decoded value, and the zero value
+// on error.
+func TestJSONReadAs(t *testing.T) {
+	testTools := New()
+	for _, tt := range typedReadTests {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+		user, err := JSONReadAs[typedUser](testTools, httptest.NewRecorder(), req)
+
+		if tt.errorExpected {
+			if err == nil || user != (typedUser{}) {
+				t.Errorf("%s: expected an error and the zero value, got %+v (%v)", tt.name, user, err)
+			}
+			continue
+		}
+		if err != nil || user != (typedUser{Name: "jane", Age: 30}) {
+			t.Errorf("%s: expected jane, got %+v (%v)", tt.name, user, err)
+		}
+	}
+}
+
+// TestJSONWriteData tests that JSONWriteData writes an envelope decoding into a
+// JSONResponseOf of the same type.
+func TestJSONWriteData(t *testing.T) {
+	rr := httptest.NewRecorder()
+	if err := JSONWriteData(New(), rr, http.StatusOK, []typedUser{{Name: "jane", Age: 30}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var res JSONResponseOf[[]typedUser]
+	if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Error || len(res.Data) != 1 || res.Data[0].Name != "jane" {
+		t.Errorf("unexpected response %+v", res)
+	}
+}
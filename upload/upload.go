@@ -0,0 +1,56 @@
+// Package upload holds the helpers of gorigumi's upload methods that do not
+// depend on the configuration of a Tools value, so they can be imported on
+// their own.
+package upload
+
+import (
+	"strings"
+	"unicode"
+)
+
+// reservedNames are the device names Windows reserves in every directory,
+// whatever the extension, e.g. NUL or nul.txt.
+var reservedNames = []string{
+	"CON", "PRN", "AUX", "NUL",
+	"COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8", "COM9",
+	"LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9",
+}
+
+// SanitizeFileName strips the directory components of a client supplied file
+// name, whatever the path separator, so it cannot escape the upload directory.
+// Control characters and invalid UTF-8 are removed as well. Names reduced to
+// nothing, "." or ".." are replaced by "file".
+//
+// Names are made valid on every platform, so stored files can be moved to
+// Windows: the characters Windows forbids, such as ':' which would name an
+// alternate data stream, are replaced by '_', trailing dots and spaces are
+// removed, and reserved device names such as CON or nul.txt get a '_' prefix.
+func SanitizeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == unicode.ReplacementChar {
+			return -1
+		}
+		if strings.ContainsRune(`<>:"|?*`, r) {
+			return '_'
+		}
+		return r
+	}, strings.ToValidUTF8(name, ""))
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	if name == "" || name == "." || name == ".." {
+		return "file"
+	}
+
+	name = strings.TrimRight(name, ". ")
+	if name == "" {
+		return "file"
+	}
+	base, _, _ := strings.Cut(name, ".")
+	for _, reserved := range reservedNames {
+		if strings.EqualFold(strings.TrimRight(base, " "), reserved) {
+			return "_" + name
+		}
+	}
+	return name
+}
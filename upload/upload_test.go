@@ -0,0 +1,67 @@
+package upload
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+)
+
+// sanitizeTests is a slice of structs that hold the client supplied file name and the
+// expected sanitized file name
+var sanitizeTests = []struct {
+	name     string
+	expected string
+}{
+	{"photo.png", "photo.png"},
+	{"../../etc/passwd", "passwd"},
+	{`..\..\windows\win.ini`, "win.ini"},
+	{"dir/", "file"},
+	{"..", "file"},
+	{"", "file"},
+	{"evil\x00.png", "evil.png"},
+	{"line\r\nbreak.txt", "linebreak.txt"},
+	{"bad\xffutf8.txt", "badutf8.txt"},
+	{"\x1b[31m", "[31m"},
+	{"\x00", "file"},
+	{"report.txt:secret", "report.txt_secret"},
+	{`what?<now>|"*".txt`, "what__now_____.txt"},
+	{"trailing. . ", "trailing"},
+	{"...", "file"},
+	{"CON", "_CON"},
+	{"nul.txt", "_nul.txt"},
+	{"com1.tar.gz", "_com1.tar.gz"},
+	{"LPT9 .log", "_LPT9 .log"},
+	{"console.txt", "console.txt"},
+	{"COM10.txt", "COM10.txt"},
+}
+
+// TestSanitizeFileName tests that directory components are stripped from file names.
+func TestSanitizeFileName(t *testing.T) {
+	for _, st := range sanitizeTests {
+		if got := SanitizeFileName(st.name); got != st.expected {
+			t.Errorf("%q: expected %q, got %q", st.name, st.expected, got)
+		}
+	}
+}
+
+// FuzzSanitizeFileName checks that sanitized file names never contain path separators,
+// control characters or characters forbidden on Windows and never resolve to a directory.
+func FuzzSanitizeFileName(f *testing.F) {
+	for _, st := range sanitizeTests {
+		f.Add(st.name)
+	}
+
+	f.Fuzz(func(t *testing.T, name string) {
+		got := SanitizeFileName(name)
+		if got == "" || got == "." || got == ".." || strings.ContainsAny(got, `/\<>:"|?*`) {
+			t.Fatalf("unsafe file name %q for %q", got, name)
+		}
+		if !utf8.ValidString(got) || strings.IndexFunc(got, unicode.IsControl) >= 0 {
+			t.Fatalf("unsafe file name %q for %q", got, name)
+		}
+		if strings.HasSuffix(got, ".") || strings.HasSuffix(got, " ") {
+			t.Fatalf("file name %q for %q is changed by Windows", got, name)
+		}
+	})
+}
@@ -0,0 +1,156 @@
+package gorigumi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultRateWindow is the default window over which the upload rate is measured
+	// it is inlcuded in the UploadGuardMiddleware method
+	defaultRateWindow time.Duration = 10 * time.Second
+)
+
+// ErrUploadTooSlow is returned by request bodies guarded by UploadGuardMiddleware
+// when the client sends data too slowly or stops sending it.
+var ErrUploadTooSlow = errors.New("upload is too slow")
+
+// UploadGuardOptions configures UploadGuardMiddleware.
+type UploadGuardOptions struct {
+	// MinRate is the minimum average upload rate in bytes per second over
+	// each RateWindow. Zero disables the rate check
+	MinRate int64
+	// RateWindow is the window over which the rate is measured. Default to 10s
+	RateWindow time.Duration
+	// IdleTimeout is the maximum time a single read of the body may wait for
+	// data. It is enforced with a connection read deadline. Zero disables it
+	IdleTimeout time.Duration
+	// PartTimeout is the maximum time spent receiving a single file of a
+	// streamed upload, see StreamUploads. Zero disables it
+	PartTimeout time.Duration
+}
+
+// guardContextKey is the context key of the uploadGuard of a request.
+type guardContextKey struct{}
+
+// UploadGuardMiddleware returns a middleware that protects upload handlers from
+// slow-drip clients holding connections open indefinitely. The request body is
+// read with a connection deadline refreshed on every read, so a client sending
+// nothing for IdleTimeout is cut off, and reads fail with ErrUploadTooSlow when
+// fewer than MinRate bytes per second arrived over a RateWindow, or when a file
+// of a streamed upload takes longer than PartTimeout. The connection is closed
+// after such failures.
+func (t *Tools) UploadGuardMiddleware(next http.Handler, opts UploadGuardOptions) http.Handler {
+	if opts.RateWindow == 0 {
+		opts.RateWindow = defaultRateWindow
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		g := &uploadGuard{
+			ReadCloser: r.Body,
+			opts:       opts,
+			clock:      t.clock(),
+			w:          w,
+			rc:         http.NewResponseController(w),
+		}
+		r.Body = g
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), guardContextKey{}, g)))
+	})
+}
+
+// uploadGuard is the guarded request body of UploadGuardMiddleware.
+type uploadGuard struct {
+	io.ReadCloser
+	opts  UploadGuardOptions
+	clock Clock
+	w     http.ResponseWriter
+	rc    *http.ResponseController
+
+	mu           sync.Mutex
+	windowStart  time.Time
+	windowBytes  int64
+	partDeadline time.Time
+	tripped      bool
+}
+
+// Read reads from the body and enforces the guard limits.
+func (g *uploadGuard) Read(p []byte) (int, error) {
+	g.mu.Lock()
+	if g.tripped {
+		g.mu.Unlock()
+		return 0, ErrUploadTooSlow
+	}
+	// the first window starts with the first read, not with the request
+	if g.windowStart.IsZero() {
+		g.windowStart = g.clock.Now()
+	}
+	partDeadline := g.partDeadline
+	g.mu.Unlock()
+
+	if g.opts.IdleTimeout > 0 {
+		_ = g.rc.SetReadDeadline(time.Now().Add(g.opts.IdleTimeout))
+	}
+
+	n, err := g.ReadCloser.Read(p)
+	if g.opts.IdleTimeout > 0 && errors.Is(err, io.EOF) {
+		// the server reads the connection in the background once the body
+		// is consumed, a stale deadline would cancel the request context
+		_ = g.rc.SetReadDeadline(time.Time{})
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.clock.Now()
+	g.windowBytes += int64(n)
+
+	switch {
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		g.trip()
+	case !partDeadline.IsZero() && now.After(partDeadline):
+		g.trip()
+	case g.opts.MinRate > 0 && now.Sub(g.windowStart) >= g.opts.RateWindow:
+		elapsed := now.Sub(g.windowStart).Seconds()
+		if float64(g.windowBytes) < float64(g.opts.MinRate)*elapsed {
+			g.trip()
+		}
+		g.windowStart, g.windowBytes = now, 0
+	}
+
+	if g.tripped {
+		return n, ErrUploadTooSlow
+	}
+	return n, err
+}
+
+// trip marks the guard as failed and asks for the connection to be closed.
+// It must be called with g.mu held.
+func (g *uploadGuard) trip() {
+	g.tripped = true
+	g.w.Header().Set("Connection", "close")
+}
+
+// setPartDeadline sets the deadline of the file part being received, a zero
+// deadline ends the part.
+func (g *uploadGuard) setPartDeadline(deadline time.Time) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.partDeadline = deadline
+}
+
+// guardPart starts the PartTimeout of the uploadGuard of ctx, if any, and returns
+// the function ending it once the part was received.
+func guardPart(ctx context.Context) (end func()) {
+	g, ok := ctx.Value(guardContextKey{}).(*uploadGuard)
+	if !ok || g.opts.PartTimeout == 0 {
+		return func() {}
+	}
+
+	g.setPartDeadline(g.clock.Now().Add(g.opts.PartTimeout))
+	return func() { g.setPartDeadline(time.Time{}) }
+}
@@ -0,0 +1,126 @@
+package gorigumi
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/drunkleen/gorigumi/toolkittest"
+)
+
+// dripReader returns its data a few bytes at a time and advances clock on every read,
+// like a client sending at a given rate.
+type dripReader struct {
+	data  []byte
+	chunk int
+	delay time.Duration
+	clock *toolkittest.Clock
+}
+
+func (d *dripReader) Read(p []byte) (int, error) {
+	if len(d.data) == 0 {
+		return 0, io.EOF
+	}
+	d.clock.Advance(d.delay)
+	n := copy(p[:min(len(p), d.chunk)], d.data)
+	d.data = d.data[n:]
+	return n, nil
+}
+
+// uploadGuardTests is a slice of structs that hold the name of the test, the guard options,
+// the number of bytes per read and the time each read takes, a boolean that indicates if
+// uploads are streamed, and a boolean that indicates if ErrUploadTooSlow is expected
+var uploadGuardTests = []struct {
+	name        string
+	opts        UploadGuardOptions
+	chunk       int
+	delay       time.Duration
+	stream      bool
+	errExpected bool
+}{
+	{"fast enough", UploadGuardOptions{MinRate: 1000, RateWindow: 5 * time.Second}, 512, 10 * time.Millisecond, false, false},
+	{"slow drip", UploadGuardOptions{MinRate: 1000, RateWindow: 5 * time.Second}, 10, time.Second, false, true},
+	{"slow drip streamed", UploadGuardOptions{MinRate: 1000, RateWindow: 5 * time.Second}, 10, time.Second, true, true},
+	{"part within timeout", UploadGuardOptions{PartTimeout: time.Minute}, 512, 10 * time.Millisecond, true, false},
+	{"part timeout", UploadGuardOptions{PartTimeout: 2 * time.Second}, 512, time.Second, true, true},
+}
+
+// TestTools_UploadGuardMiddleware tests the rate and part timeout checks with a
+// controllable clock.
+func TestTools_UploadGuardMiddleware(t *testing.T) {
+	data := toolkittest.GenerateFile(8*1024, []byte("some text "))
+
+	for _, gt := range uploadGuardTests {
+		clock := toolkittest.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		testTools := New()
+		testTools.Clock = clock
+		testTools.AllowedFileTypes = []string{"*"}
+		testTools.StreamUploads = gt.stream
+
+		var uploadErr error
+		handler := testTools.UploadGuardMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, uploadErr = testTools.UploadFiles(r, t.TempDir())
+		}), gt.opts)
+
+		body, contentType := newMultipartBody(t, map[string][]byte{"a.txt": data})
+		req := httptest.NewRequest("POST", "/", &dripReader{data: body, chunk: gt.chunk, delay: gt.delay, clock: clock})
+		req.Header.Set("Content-Type", contentType)
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if gt.errExpected != errors.Is(uploadErr, ErrUploadTooSlow) {
+			t.Errorf("%s: expected ErrUploadTooSlow %t, got %v", gt.name, gt.errExpected, uploadErr)
+		}
+		if gt.errExpected && rr.Header().Get("Connection") != "close" {
+			t.Errorf("%s: expected the connection to be closed", gt.name)
+		}
+	}
+}
+
+// TestTools_UploadGuardMiddlewareIdle tests over a real connection that a client that
+// stops sending its body is cut off after the idle timeout.
+func TestTools_UploadGuardMiddlewareIdle(t *testing.T) {
+	testTools := New()
+	testTools.AllowedFileTypes = []string{"*"}
+
+	uploadErr := make(chan error, 1)
+	handler := testTools.UploadGuardMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := testTools.UploadFiles(r, t.TempDir())
+		uploadErr <- err
+		testTools.JSONError(w, err, http.StatusRequestTimeout)
+	}), UploadGuardOptions{IdleTimeout: 100 * time.Millisecond})
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// declare a large body and send only its beginning
+	fmt.Fprintf(conn, "POST / HTTP/1.1\r\nHost: x\r\nContent-Type: multipart/form-data; boundary=x\r\nContent-Length: 100000\r\n\r\n--x\r\n")
+
+	select {
+	case err := <-uploadErr:
+		if !errors.Is(err, ErrUploadTooSlow) {
+			t.Errorf("expected ErrUploadTooSlow, got %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("expected the stalled upload to be cut off")
+	}
+
+	status, _ := bufio.NewReader(conn).ReadString('\n')
+	if status != "HTTP/1.1 408 Request Timeout\r\n" {
+		t.Errorf("unexpected response %q", status)
+	}
+}
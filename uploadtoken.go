@@ -0,0 +1,102 @@
+package gorigumi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// uploadTokenPurpose is the purpose of upload tokens
+const uploadTokenPurpose string = "upload"
+
+// UploadConstraints restricts the uploads accepted with an upload token. Zero
+// values fall back to the configuration of the Tools struct.
+type UploadConstraints struct {
+	// MaxFileSize is the maximum size in bytes of each file
+	MaxFileSize int `json:"max_file_size,omitempty"`
+	// MaxFiles is the maximum number of files of the upload
+	MaxFiles int `json:"max_files,omitempty"`
+	// AllowedFileTypes is the list of allowed file types
+	AllowedFileTypes []string `json:"allowed_file_types,omitempty"`
+	// SingleUse is a boolean that indicates if the token is only accepted
+	// once. TokenStore must be set to use it
+	SingleUse bool `json:"single_use,omitempty"`
+}
+
+// uploadClaims is the subject of an upload token.
+type uploadClaims struct {
+	Dir         string            `json:"dir"`
+	Constraints UploadConstraints `json:"constraints"`
+}
+
+// GenerateUploadToken returns a signed token allowing files to be uploaded to
+// dir through an UploadTokenHandler until expiry, within constraints, e.g. to
+// let browsers upload directly without exposing an open endpoint. The directory
+// and the constraints are carried by the token, so clients cannot change them.
+// TokenSecret must be set.
+func (t *Tools) GenerateUploadToken(dir string, expiry time.Time, constraints UploadConstraints) (string, error) {
+	claims, err := json.Marshal(uploadClaims{Dir: dir, Constraints: constraints})
+	if err != nil {
+		return "", err
+	}
+	return t.signOneTimeToken(uploadTokenPurpose, string(claims), "", expiry.Sub(t.clock().Now()))
+}
+
+// UploadTokenHandler returns a handler only accepting uploads presenting a
+// token returned by GenerateUploadToken, in the Upload-Token header or the
+// token query parameter. The files are uploaded like UploadFiles to the
+// directory of the token, within its constraints, and passed to onUpload,
+// which writes the response. It responds 401 for missing, invalid or used
+// tokens, 410 for expired ones, and 400 for rejected uploads.
+func (t *Tools) UploadTokenHandler(onUpload func(w http.ResponseWriter, r *http.Request, files []*UploadedFile)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("Upload-Token")
+		if token == "" {
+			token = r.URL.Query().Get("token")
+		}
+
+		id, subject, expiry, err := t.verifyOneTimeToken(uploadTokenPurpose, token, "")
+		var claims uploadClaims
+		if err == nil && json.Unmarshal([]byte(subject), &claims) != nil {
+			err = ErrInvalidToken
+		}
+		if err == nil && claims.Constraints.SingleUse {
+			err = t.markTokenUsed(r.Context(), uploadTokenPurpose, id, expiry)
+		}
+		switch {
+		case errors.Is(err, ErrTokenExpired):
+			_ = t.JSONError(w, err, http.StatusGone)
+			return
+		case errors.Is(err, ErrInvalidToken), errors.Is(err, ErrTokenUsed):
+			_ = t.JSONError(w, err, http.StatusUnauthorized)
+			return
+		case err != nil:
+			_ = t.JSONError(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		files, err := t.withUploadConstraints(claims.Constraints).UploadFiles(r, claims.Dir)
+		if err != nil {
+			_ = t.JSONError(w, err, http.StatusBadRequest)
+			return
+		}
+
+		onUpload(w, r, files)
+	})
+}
+
+// withUploadConstraints returns a copy of the Tools struct applying c.
+func (t *Tools) withUploadConstraints(c UploadConstraints) *Tools {
+	constrained := *t
+	if c.MaxFileSize > 0 {
+		constrained.MaxFileSizePerFile = c.MaxFileSize
+	}
+	if c.MaxFiles > 0 {
+		constrained.MaxFilesPerRequest = c.MaxFiles
+	}
+	if len(c.AllowedFileTypes) > 0 {
+		constrained.AllowedFileTypes = c.AllowedFileTypes
+	}
+	return &constrained
+}
@@ -0,0 +1,81 @@
+package gorigumi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/drunkleen/gorigumi/toolkittest"
+)
+
+// TestTools_UploadTokenHandler tests that uploads are only accepted with a valid token,
+// within its constraints, to the directory of the token.
+func TestTools_UploadTokenHandler(t *testing.T) {
+	clock := toolkittest.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	testTools := newTokenTools(clock)
+	testTools.AllowedFileTypes = []string{"*"}
+	uploadDir := t.TempDir()
+
+	handler := testTools.UploadTokenHandler(func(w http.ResponseWriter, r *http.Request, files []*UploadedFile) {
+		testTools.JSONWrite(w, http.StatusCreated, files)
+	})
+
+	send := func(token string, files map[string][]byte) int {
+		req := newMultipartRequest(t, files)
+		req.Header.Set("Upload-Token", token)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	text := map[string][]byte{"a.txt": []byte("some text")}
+
+	token, err := testTools.GenerateUploadToken(uploadDir, clock.Now().Add(time.Hour), UploadConstraints{
+		MaxFileSize:      100,
+		MaxFiles:         1,
+		AllowedFileTypes: []string{"text/plain; charset=utf-8"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code := send("", text); code != http.StatusUnauthorized {
+		t.Errorf("expected upload without token to be rejected, got %d", code)
+	}
+	if code := send(token+"x", text); code != http.StatusUnauthorized {
+		t.Errorf("expected tampered token to be rejected, got %d", code)
+	}
+	if code := send(token, map[string][]byte{"a.txt": []byte(strings.Repeat("x", 101))}); code != http.StatusBadRequest {
+		t.Errorf("expected oversized file to be rejected, got %d", code)
+	}
+	if code := send(token, map[string][]byte{"a.txt": []byte("one"), "b.txt": []byte("two")}); code != http.StatusBadRequest {
+		t.Errorf("expected too many files to be rejected, got %d", code)
+	}
+	if code := send(token, text); code != http.StatusCreated {
+		t.Errorf("expected status 201, got %d", code)
+	}
+	if code := send(token, text); code != http.StatusCreated {
+		t.Errorf("expected reusable token to be accepted again, got %d", code)
+	}
+	if entries, _ := os.ReadDir(uploadDir); len(entries) != 2 {
+		t.Errorf("expected 2 files in the directory of the token, got %d", len(entries))
+	}
+	if testTools.MaxFilesPerRequest != 0 || len(testTools.AllowedFileTypes) != 1 {
+		t.Error("expected the constraints not to change the Tools struct")
+	}
+
+	once, _ := testTools.GenerateUploadToken(uploadDir, clock.Now().Add(time.Hour), UploadConstraints{SingleUse: true})
+	if code := send(once, text); code != http.StatusCreated {
+		t.Errorf("expected status 201, got %d", code)
+	}
+	if code := send(once, text); code != http.StatusUnauthorized {
+		t.Errorf("expected single-use token to be rejected once used, got %d", code)
+	}
+
+	clock.Advance(2 * time.Hour)
+	if code := send(token, text); code != http.StatusGone {
+		t.Errorf("expected expired token to be rejected, got %d", code)
+	}
+}
@@ -0,0 +1,182 @@
+// Package urlx normalizes URLs and validates redirect targets. It is the
+// implementation of gorigumi's NormalizeURL and ValidateRedirectURL and can be
+// imported on its own.
+package urlx
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+)
+
+// DefaultTrackingParams are the query parameters removed by Normalize when no
+// other list is configured. Entries ending with '*' match any parameter with
+// that prefix.
+var DefaultTrackingParams = []string{
+	"utm_*", "fbclid", "gclid", "dclid", "msclkid", "mc_cid", "mc_eid", "igshid", "_hsenc", "_hsmi",
+}
+
+// Options configures Normalize.
+type Options struct {
+	// DefaultScheme is the scheme of URLs written without one, such as
+	// "example.com/page". Default to "https"
+	DefaultScheme string
+	// StripParams is the list of query parameters removed from URLs, see
+	// DefaultTrackingParams. Default to DefaultTrackingParams
+	StripParams []string
+	// KeepParams is a boolean that indicates if no query parameter is
+	// removed, regardless of StripParams
+	KeepParams bool
+	// KeepFragment is a boolean that indicates if the fragment is kept
+	KeepFragment bool
+}
+
+// Normalize returns raw in a canonical form, so equivalent URLs compare equal:
+//   - a missing scheme is set to DefaultScheme, schemes and hosts are lowercased
+//   - internationalized hosts are converted to punycode and a trailing dot is removed
+//   - default ports are removed
+//   - dot segments are resolved and an empty path becomes "/"
+//   - tracking query parameters are removed and the others sorted
+//   - the fragment is removed
+//
+// Only http and https URLs are accepted.
+func Normalize(raw string, opts Options) (string, error) {
+	if opts.DefaultScheme == "" {
+		opts.DefaultScheme = "https"
+	}
+	if opts.StripParams == nil {
+		opts.StripParams = DefaultTrackingParams
+	}
+
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		raw = opts.DefaultScheme + "://" + strings.TrimPrefix(raw, "//")
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+
+	host, port := u.Hostname(), u.Port()
+	if host == "" {
+		return "", errors.New("URL has no host")
+	}
+	host, err = HostToASCII(strings.TrimSuffix(host, "."))
+	if err != nil {
+		return "", err
+	}
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	switch {
+	case port != "":
+		u.Host = net.JoinHostPort(host, port)
+	case strings.Contains(host, ":"):
+		u.Host = "[" + host + "]"
+	default:
+		u.Host = host
+	}
+
+	if u.Path == "" {
+		u.Path = "/"
+	} else {
+		cleaned := path.Clean(u.Path)
+		if strings.HasSuffix(u.Path, "/") && cleaned != "/" {
+			cleaned += "/"
+		}
+		u.Path = cleaned
+	}
+	u.RawPath = ""
+
+	query := u.Query()
+	if !opts.KeepParams {
+		for name := range query {
+			if matchParam(opts.StripParams, name) {
+				query.Del(name)
+			}
+		}
+	}
+	// Encode sorts the parameters by name
+	u.RawQuery = query.Encode()
+
+	if !opts.KeepFragment {
+		u.Fragment, u.RawFragment = "", ""
+	}
+
+	return u.String(), nil
+}
+
+// matchParam reports whether name is in params, where entries ending with
+// '*' are prefixes.
+func matchParam(params []string, name string) bool {
+	name = strings.ToLower(name)
+	return slices.ContainsFunc(params, func(p string) bool {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			return strings.HasPrefix(name, strings.ToLower(prefix))
+		}
+		return strings.EqualFold(p, name)
+	})
+}
+
+// ValidateRedirect reports an error unless target is safe to redirect to after
+// e.g. a login: either a path on the same site, such as "/account", or an
+// http(s) URL whose host is in allowlist. Entries of allowlist starting with
+// "*." match any subdomain. Protocol-relative URLs ("//evil.example"),
+// backslashes, control characters and other schemes are rejected, as browsers
+// may resolve them to other sites.
+func ValidateRedirect(target string, allowlist []string) error {
+	if target == "" {
+		return errors.New("redirect target is empty")
+	}
+	if strings.Contains(target, "\\") || strings.IndexFunc(target, func(r rune) bool { return r < 0x20 || r == 0x7f }) >= 0 {
+		return errors.New("redirect target contains forbidden characters")
+	}
+
+	if strings.HasPrefix(target, "/") {
+		if strings.HasPrefix(target, "//") {
+			return errors.New("protocol-relative redirect targets are not allowed")
+		}
+		if _, err := url.Parse(target); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("redirect scheme %q is not allowed", u.Scheme)
+	}
+	if u.User != nil {
+		return errors.New("redirect targets with credentials are not allowed")
+	}
+
+	host, err := HostToASCII(strings.TrimSuffix(u.Hostname(), "."))
+	if err != nil {
+		return err
+	}
+	for _, allowed := range allowlist {
+		allowed = strings.ToLower(allowed)
+		if suffix, ok := strings.CutPrefix(allowed, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return nil
+			}
+			continue
+		}
+		if host == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("redirect host %q is not allowed", host)
+}
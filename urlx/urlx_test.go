@@ -0,0 +1,102 @@
+package urlx
+
+import "testing"
+
+// hostTests is a slice of structs that hold a host and its ASCII form
+var hostTests = []struct {
+	host     string
+	expected string
+}{
+	{"Example.COM", "example.com"},
+	{"bücher.example", "xn--bcher-kva.example"},
+	{"münchen.de", "xn--mnchen-3ya.de"},
+	{"例え.テスト", "xn--r8jz45g.xn--zckzah"},
+}
+
+// TestHostToASCII tests the punycode conversion of hosts.
+func TestHostToASCII(t *testing.T) {
+	for _, ht := range hostTests {
+		got, err := HostToASCII(ht.host)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", ht.host, err)
+		}
+		if got != ht.expected {
+			t.Errorf("%q: expected %q, got %q", ht.host, ht.expected, got)
+		}
+	}
+}
+
+// normalizeTests is a slice of structs that hold the name of the test, the options, the
+// URL, the expected normalized URL and a boolean that indicates if an error is expected
+var normalizeTests = []struct {
+	name          string
+	opts          Options
+	raw           string
+	expected      string
+	errorExpected bool
+}{
+	{"already normal", Options{}, "https://example.com/a", "https://example.com/a", false},
+	{"default scheme", Options{}, "example.com/a", "https://example.com/a", false},
+	{"custom default scheme", Options{DefaultScheme: "http"}, "example.com", "http://example.com/", false},
+	{"lowercase scheme and host", Options{}, "HTTPS://EXAMPLE.com/Path", "https://example.com/Path", false},
+	{"default port", Options{}, "http://example.com:80/", "http://example.com/", false},
+	{"other port", Options{}, "https://example.com:8443", "https://example.com:8443/", false},
+	{"trailing dot", Options{}, "https://example.com./", "https://example.com/", false},
+	{"punycode", Options{}, "https://bücher.example/", "https://xn--bcher-kva.example/", false},
+	{"ipv6", Options{}, "http://[::1]:80/", "http://[::1]/", false},
+	{"dot segments", Options{}, "https://example.com/a/./b/../c/", "https://example.com/a/c/", false},
+	{"sorted query", Options{}, "https://example.com/?b=2&a=1", "https://example.com/?a=1&b=2", false},
+	{"trackers stripped", Options{}, "https://example.com/?utm_source=x&id=3&fbclid=y#top", "https://example.com/?id=3", false},
+	{"custom strip list", Options{StripParams: []string{"ref"}}, "https://example.com/?ref=a&utm_source=x", "https://example.com/?utm_source=x", false},
+	{"keep params and fragment", Options{KeepParams: true, KeepFragment: true}, "https://example.com/?utm_source=x#top", "https://example.com/?utm_source=x#top", false},
+	{"unsupported scheme", Options{}, "ftp://example.com/", "", true},
+	{"no host", Options{}, "https:///path", "", true},
+}
+
+// TestNormalize tests the normalization of URLs.
+func TestNormalize(t *testing.T) {
+	for _, nt := range normalizeTests {
+		got, err := Normalize(nt.raw, nt.opts)
+		if nt.errorExpected != (err != nil) {
+			t.Errorf("%s: expected error %t, got %v", nt.name, nt.errorExpected, err)
+		}
+		if got != nt.expected {
+			t.Errorf("%s: expected %q, got %q", nt.name, nt.expected, got)
+		}
+	}
+}
+
+// redirectTests is a slice of structs that hold the redirect target and a boolean that
+// indicates if it is allowed
+var redirectTests = []struct {
+	target  string
+	allowed bool
+}{
+	{"/account", true},
+	{"/search?q=a#b", true},
+	{"https://example.com/home", true},
+	{"https://app.example.org/", true},
+	{"https://EXAMPLE.com./", true},
+	{"", false},
+	{"account", false},
+	{"//evil.com", false},
+	{"/\\evil.com", false},
+	{"https:\\\\evil.com", false},
+	{"/a\nb", false},
+	{"javascript:alert(1)", false},
+	{"https://evil.com/", false},
+	{"https://example.org/", false},
+	{"https://example.com.evil.com/", false},
+	{"https://example.com@evil.com/", false},
+}
+
+// TestValidateRedirect tests the validation of redirect targets.
+func TestValidateRedirect(t *testing.T) {
+	allowlist := []string{"example.com", "*.example.org"}
+	for _, rt := range redirectTests {
+		err := ValidateRedirect(rt.target, allowlist)
+		if rt.allowed != (err == nil) {
+			t.Errorf("%q: expected allowed %t, got %v", rt.target, rt.allowed, err)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package gorigumi
+
+import (
+	"errors"
+	"strings"
+)
+
+// Validatable is implemented by the values JSONRead decodes into that validate
+// themselves. JSONRead calls Validate once the body is decoded.
+type Validatable interface {
+	Validate() error
+}
+
+// Validator validates the values decoded by JSONRead, e.g. an adapter of a
+// validation library driven by struct tags.
+type Validator interface {
+	Validate(v any) error
+}
+
+// ValidatorFunc is a function implementing Validator.
+type ValidatorFunc func(v any) error
+
+// Validate calls f(v).
+func (f ValidatorFunc) Validate(v any) error {
+	return f(v)
+}
+
+// FieldError is the validation error of a field of a request body. It can be
+// returned by Validate methods, alone or joined with errors.Join.
+type FieldError struct {
+	// Field is the name of the invalid field, as written in the request,
+	// e.g. "email" or "items[2].quantity". Empty for errors of the whole body
+	Field string `json:"field,omitempty"`
+	// Message describes the problem, e.g. "is required"
+	Message string `json:"message"`
+}
+
+// Error returns the field and the message of the error.
+func (e FieldError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+// ValidationErrors is the list of field errors returned by JSONRead when the
+// decoded value is invalid. Its error code is CodeValidationFailed, and JSONError
+// writes the list as the Data of the response:
+//
+//	{"error":true,"message":"validation failed: email: is required","code":"validation_failed",
+//	 "data":[{"field":"email","message":"is required"}]}
+type ValidationErrors []FieldError
+
+// Error returns the messages of the field errors.
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, fe := range e {
+		messages[i] = fe.Error()
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+// ErrorCode returns CodeValidationFailed.
+func (e ValidationErrors) ErrorCode() string {
+	return CodeValidationFailed
+}
+
+// ErrorData returns the field errors, written by JSONError as the Data of the
+// response.
+func (e ValidationErrors) ErrorData() any {
+	return []FieldError(e)
+}
+
+// validate validates the value v decoded by JSONRead with its Validate
+// method, if any, then with its validate tags if ValidateTags is set, then
+// with the Validator of the Tools struct, if set.
+func (t *Tools) validate(v any) error {
+	if v, ok := v.(Validatable); ok {
+		if err := v.Validate(); err != nil {
+			return validationErrors(err)
+		}
+	}
+	if t.ValidateTags {
+		if errs, ok := t.Validate(v); !ok {
+			return errs
+		}
+	}
+	if t.Validator != nil {
+		if err := t.Validator.Validate(v); err != nil {
+			return validationErrors(err)
+		}
+	}
+	return nil
+}
+
+// validationErrors returns the field errors of the validation error err. Joined
+// errors give a field error each, and errors that are not field errors give a
+// field error without a field.
+func validationErrors(err error) ValidationErrors {
+	var list ValidationErrors
+	if errors.As(err, &list) {
+		return list
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			list = append(list, validationErrors(e)...)
+		}
+		return list
+	}
+	var fe FieldError
+	if errors.As(err, &fe) {
+		return ValidationErrors{fe}
+	}
+	return ValidationErrors{{Message: err.Error()}}
+}
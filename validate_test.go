@@ -0,0 +1,96 @@
+package gorigumi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// signup is a request body validating itself.
+type signup struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// Validate implements Validatable.
+func (s signup) Validate() error {
+	var errs []error
+	if !strings.Contains(s.Email, "@") {
+		errs = append(errs, FieldError{Field: "email", Message: "is invalid"})
+	}
+	if len(s.Password) < 8 {
+		errs = append(errs, FieldError{Field: "password", Message: "is too short"})
+	}
+	return errors.Join(errs...)
+}
+
+// validateTests is a slice of structs that hold the name of the test, the body, the
+// validator of the Tools struct and the expected invalid fields
+var validateTests = []struct {
+	name      string
+	body      string
+	validator Validator
+	fields    []string
+}{
+	{"valid", `{"email":"a@example.com","password":"correct horse"}`, nil, nil},
+	{"invalid fields", `{"email":"a","password":"short"}`, nil, []string{"email", "password"}},
+	{"validator", `{"email":"a@example.com","password":"correct horse"}`, ValidatorFunc(func(v any) error {
+		return errors.New("signups are closed")
+	}), []string{""}},
+	{"validator after Validate", `{"email":"a","password":"correct horse"}`, ValidatorFunc(func(v any) error {
+		return errors.New("not called")
+	}), []string{"email"}},
+}
+
+// TestTools_JSONRead_validate tests that JSONRead validates decoded values and returns
+// the invalid fields.
+func TestTools_JSONRead_validate(t *testing.T) {
+	for _, vt := range validateTests {
+		testTools := New()
+		testTools.Validator = vt.validator
+
+		var s signup
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(vt.body))
+		err := testTools.JSONRead(httptest.NewRecorder(), req, &s)
+
+		if vt.fields == nil {
+			if err != nil {
+				t.Errorf("%s: %v", vt.name, err)
+			}
+			continue
+		}
+		var list ValidationErrors
+		if !errors.As(err, &list) || ErrorCode(err) != CodeValidationFailed {
+			t.Errorf("%s: expected validation errors, got %v", vt.name, err)
+			continue
+		}
+		var fields []string
+		for _, fe := range list {
+			fields = append(fields, fe.Field)
+		}
+		if strings.Join(fields, ",") != strings.Join(vt.fields, ",") {
+			t.Errorf("%s: expected fields %v, got %v", vt.name, vt.fields, fields)
+		}
+	}
+}
+
+// TestTools_JSONError_validation tests that JSONError writes the list of field errors.
+func TestTools_JSONError_validation(t *testing.T) {
+	rr := httptest.NewRecorder()
+	err := ValidationErrors{{Field: "email", Message: "is required"}}
+	if werr := New().JSONError(rr, err, http.StatusUnprocessableEntity); werr != nil {
+		t.Fatal(werr)
+	}
+
+	var res JSONResponseOf[[]FieldError]
+	if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Code != CodeValidationFailed || res.Message != "validation failed: email: is required" ||
+		len(res.Data) != 1 || res.Data[0].Field != "email" {
+		t.Errorf("unexpected response %+v", res)
+	}
+}
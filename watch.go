@@ -0,0 +1,233 @@
+package gorigumi
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultWatchInterval is the default delay between two scans of a directory
+	// it is inlcuded in the WatchDir method
+	defaultWatchInterval time.Duration = time.Second
+
+	// defaultWatchDebounce is the default quiet period before a change is reported
+	// it is inlcuded in the WatchDir method
+	defaultWatchDebounce time.Duration = time.Second
+)
+
+// FileOp is the kind of change of an Event.
+type FileOp int
+
+const (
+	// FileCreated reports a new file or directory.
+	FileCreated FileOp = iota + 1
+	// FileModified reports a change of the size, modification time or mode of
+	// a file.
+	FileModified
+	// FileRemoved reports a removed file or directory.
+	FileRemoved
+)
+
+// String returns the name of the operation.
+func (op FileOp) String() string {
+	switch op {
+	case FileCreated:
+		return "created"
+	case FileModified:
+		return "modified"
+	case FileRemoved:
+		return "removed"
+	}
+	return "unknown"
+}
+
+// Event is a change of a file reported by WatchDir.
+type Event struct {
+	// Path is the path of the file, relative to the watched directory and
+	// slash-separated
+	Path string
+	// Op is the kind of change
+	Op FileOp
+	// Dir is a boolean that indicates if the file is a directory
+	Dir bool
+}
+
+// WatchOptions configures WatchDir.
+type WatchOptions struct {
+	// Interval is the delay between two scans of the directory.
+	// Default to 1s
+	Interval time.Duration
+	// Debounce is the quiet period after the last change of a file before it
+	// is reported, so a file still being written is reported once. Changes
+	// within the period are merged, e.g. a file created then modified is
+	// reported as created, and a file created then removed is not reported.
+	// Default to 1s
+	Debounce time.Duration
+	// Recursive is a boolean that indicates if subdirectories are watched
+	Recursive bool
+	// ShowHidden is a boolean that indicates if files whose name starts with
+	// a dot are watched
+	ShowHidden bool
+}
+
+// WatchDir calls fn for every file created, modified or removed in dir, e.g. to
+// process files dropped by other processes, until ctx is done. The standard
+// library has no portable change notifications, so dir is scanned every
+// Interval, which suits directories of up to some thousands of files. Changes are
+// reported once the file did not change for Debounce, in path order, and fn is
+// called from the goroutine of WatchDir, one event at a time. WatchDir returns
+// the error of the first scan, and nil once ctx is done.
+func (t *Tools) WatchDir(ctx context.Context, dir string, fn func(Event), opts ...WatchOptions) error {
+	var options WatchOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	if options.Interval <= 0 {
+		options.Interval = defaultWatchInterval
+	}
+	if options.Debounce <= 0 {
+		options.Debounce = defaultWatchDebounce
+	}
+
+	clock := t.clock()
+	files, err := scanWatchedDir(dir, options)
+	if err != nil {
+		return err
+	}
+
+	pending := make(map[string]*pendingEvent)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-clock.After(options.Interval):
+		}
+
+		now := clock.Now()
+		// a failed scan, e.g. while the directory is being replaced, is
+		// retried at the next interval rather than reported as removals
+		if current, err := scanWatchedDir(dir, options); err == nil {
+			for _, e := range diffWatchedFiles(files, current) {
+				p, ok := pending[e.Path]
+				if !ok {
+					pending[e.Path] = &pendingEvent{Event: e, changed: now}
+					continue
+				}
+				p.merge(e)
+				p.changed = now
+			}
+			files = current
+		}
+
+		var due []Event
+		for path, p := range pending {
+			if now.Sub(p.changed) < options.Debounce {
+				continue
+			}
+			delete(pending, path)
+			if p.Op != 0 {
+				due = append(due, p.Event)
+			}
+		}
+		sort.Slice(due, func(i, j int) bool { return due[i].Path < due[j].Path })
+		for _, e := range due {
+			if ctx.Err() != nil {
+				return nil
+			}
+			fn(e)
+		}
+	}
+}
+
+// watchedFile is the state of a file compared between two scans.
+type watchedFile struct {
+	size    int64
+	modTime time.Time
+	mode    fs.FileMode
+}
+
+// pendingEvent is a change waiting for the quiet period to elapse.
+type pendingEvent struct {
+	Event
+	changed time.Time
+}
+
+// merge combines the pending change with the following change e. An Op of 0
+// means the changes cancel out.
+func (p *pendingEvent) merge(e Event) {
+	p.Dir = e.Dir
+	switch {
+	case p.Op == FileCreated && e.Op == FileRemoved:
+		p.Op = 0
+	case p.Op == 0 && e.Op == FileCreated:
+		p.Op = FileCreated
+	case p.Op == FileRemoved && e.Op == FileCreated:
+		p.Op = FileModified
+	case p.Op != FileCreated:
+		p.Op = e.Op
+	}
+}
+
+// scanWatchedDir returns the state of the files of dir by slash-separated
+// relative path.
+func scanWatchedDir(dir string, options WatchOptions) (map[string]watchedFile, error) {
+	files := make(map[string]watchedFile)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			// the file was removed during the scan
+			return nil
+		}
+		if path == dir {
+			return nil
+		}
+		if !options.ShowHidden && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = watchedFile{size: info.Size(), modTime: info.ModTime(), mode: info.Mode()}
+
+		if d.IsDir() && !options.Recursive {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return files, err
+}
+
+// diffWatchedFiles returns the changes from the scan before to the scan after.
+func diffWatchedFiles(before, after map[string]watchedFile) []Event {
+	var events []Event
+	for path, a := range after {
+		b, ok := before[path]
+		switch {
+		case !ok:
+			events = append(events, Event{Path: path, Op: FileCreated, Dir: a.mode.IsDir()})
+		case !a.mode.IsDir() && (a.size != b.size || !a.modTime.Equal(b.modTime) || a.mode != b.mode):
+			events = append(events, Event{Path: path, Op: FileModified})
+		}
+	}
+	for path, b := range before {
+		if _, ok := after[path]; !ok {
+			events = append(events, Event{Path: path, Op: FileRemoved, Dir: b.mode.IsDir()})
+		}
+	}
+	return events
+}
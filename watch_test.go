@@ -0,0 +1,159 @@
+package gorigumi
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/drunkleen/gorigumi/toolkittest"
+)
+
+// watchTests is a slice of structs that hold the name of the test, the change made to
+// the watched directory and the expected events, once debounced
+var watchTests = []struct {
+	name     string
+	change   func(dir string) error
+	expected []Event
+}{
+	{"create", func(dir string) error {
+		return os.WriteFile(filepath.Join(dir, "new.txt"), []byte("a"), 0644)
+	}, []Event{{Path: "new.txt", Op: FileCreated}}},
+	{"modify", func(dir string) error {
+		return os.WriteFile(filepath.Join(dir, "existing.txt"), []byte("changed"), 0644)
+	}, []Event{{Path: "existing.txt", Op: FileModified}}},
+	{"remove", func(dir string) error {
+		return os.Remove(filepath.Join(dir, "existing.txt"))
+	}, []Event{{Path: "existing.txt", Op: FileRemoved}}},
+	{"recursive and hidden", func(dir string) error {
+		if err := os.WriteFile(filepath.Join(dir, ".hidden"), nil, 0644); err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dir, "sub", "deep.txt"), nil, 0644)
+	}, []Event{{Path: "sub/deep.txt", Op: FileCreated}}},
+}
+
+// watchRecorder collects the events of WatchDir.
+type watchRecorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+// record appends e to the events.
+func (w *watchRecorder) record(e Event) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.events = append(w.events, e)
+}
+
+// get returns the recorded events.
+func (w *watchRecorder) get() []Event {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return slices.Clone(w.events)
+}
+
+// tickWatch waits for WatchDir to wait on clock, then advances it by one interval.
+func tickWatch(clock *toolkittest.Clock) {
+	for clock.Pending() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(time.Second)
+}
+
+// startWatch starts WatchDir on a directory holding existing.txt and sub/, and
+// returns the directory, the clock and the recorder of the events.
+func startWatch(tb testing.TB) (string, *toolkittest.Clock, *watchRecorder) {
+	tb.Helper()
+	dir := tb.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "existing.txt"), []byte("a"), 0644); err != nil {
+		tb.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		tb.Fatal(err)
+	}
+
+	clock := toolkittest.NewClock(time.Now())
+	testTools := New()
+	testTools.Clock = clock
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	rec := &watchRecorder{}
+	go func() {
+		done <- testTools.WatchDir(ctx, dir, rec.record, WatchOptions{Recursive: true})
+	}()
+	tb.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			tb.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	// the first scan is done once WatchDir waits on the clock
+	for clock.Pending() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	return dir, clock, rec
+}
+
+// TestTools_WatchDir tests the events reported for changes of the watched directory.
+func TestTools_WatchDir(t *testing.T) {
+	for _, wt := range watchTests {
+		dir, clock, rec := startWatch(t)
+
+		if err := wt.change(dir); err != nil {
+			t.Fatal(err)
+		}
+		tickWatch(clock)
+		if events := rec.get(); len(events) != 0 {
+			t.Errorf("%s: expected no event before the quiet period, got %v", wt.name, events)
+		}
+		tickWatch(clock)
+		tickWatch(clock)
+
+		if events := rec.get(); !slices.Equal(events, wt.expected) {
+			t.Errorf("%s: expected events %v, got %v", wt.name, wt.expected, events)
+		}
+	}
+}
+
+// TestTools_WatchDir_debounce tests that changes within the quiet period are merged.
+func TestTools_WatchDir_debounce(t *testing.T) {
+	dir, clock, rec := startWatch(t)
+	name := filepath.Join(dir, "upload.part")
+
+	if err := os.WriteFile(name, []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tickWatch(clock)
+	if err := os.WriteFile(name, []byte("ab"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "temp"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	tickWatch(clock)
+	if err := os.Remove(filepath.Join(dir, "temp")); err != nil {
+		t.Fatal(err)
+	}
+	tickWatch(clock)
+	tickWatch(clock)
+	tickWatch(clock)
+
+	expected := []Event{{Path: "upload.part", Op: FileCreated}}
+	if events := rec.get(); !slices.Equal(events, expected) {
+		t.Errorf("expected events %v, got %v", expected, events)
+	}
+}
+
+// TestTools_WatchDir_missing tests that watching a missing directory fails.
+func TestTools_WatchDir_missing(t *testing.T) {
+	err := New().WatchDir(context.Background(), filepath.Join(t.TempDir(), "missing"), func(Event) {})
+	if err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+}
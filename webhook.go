@@ -0,0 +1,406 @@
+package gorigumi
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultWebhookSignatureHeader is the default header of webhook signatures
+	// it is inlcuded in the Deliver and VerifyWebhookSignature methods
+	defaultWebhookSignatureHeader string = "X-Signature"
+
+	// webhookIDLength is the length of the IDs of webhook deliveries
+	webhookIDLength int = 32
+
+	// webhookFileExt is the extension of the failed deliveries stored in FailedDir
+	webhookFileExt string = ".json"
+)
+
+// WebhookDelivery is an event delivered by a WebhookSender. Deliveries that fail
+// are stored as JSON in the FailedDir of the sender, to be replayed later.
+type WebhookDelivery struct {
+	// ID identifies the delivery, and is sent in the X-Webhook-ID header so
+	// receivers can ignore the deliveries they already processed
+	ID string `json:"id"`
+	// URL is the endpoint the event is delivered to
+	URL string `json:"url"`
+	// Event is the type of the event, sent in the X-Webhook-Event header
+	Event string `json:"event"`
+	// Payload is the JSON body of the request
+	Payload json.RawMessage `json:"payload"`
+	// CreatedAt is when the event was first sent
+	CreatedAt time.Time `json:"created_at"`
+	// Error is the error of the last failed delivery
+	Error string `json:"error,omitempty"`
+	// FailedAt is when the last delivery failed
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// WebhookSender delivers events to webhook endpoints, like JSONPushToRemote with
+// signed requests and retries. Every request carries the ID of the delivery in
+// the X-Webhook-ID header, the type of the event in X-Webhook-Event, the Unix
+// time of the request in X-Webhook-Timestamp, and an HMAC-SHA256 signature of
+// the three with the body in SignatureHeader, as computed by SignWebhook. Use
+// NewWebhookSender to create one.
+type WebhookSender struct {
+	// Secret is the key of the signatures, shared with the receivers
+	Secret []byte
+	// SignatureHeader is the header of the signature. Default to X-Signature
+	SignatureHeader string
+	// Retry is the policy of the attempts of a delivery. Network errors, and
+	// 5xx and 429 responses are retried. Default to 3 attempts
+	Retry RetryPolicy
+	// FailedDir is the directory failed deliveries are stored in, to be
+	// replayed with Replay. Default to not storing them
+	FailedDir string
+	// Client is used to make the requests. Default to a new http.Client
+	Client *http.Client
+
+	tools *Tools
+}
+
+// NewWebhookSender returns a WebhookSender signing its requests with secret, with
+// the RemoteTimeout, Clock and Logger of the Tools struct.
+func (t *Tools) NewWebhookSender(secret []byte) *WebhookSender {
+	return &WebhookSender{Secret: secret, tools: t}
+}
+
+// SignWebhook returns the signature of a webhook request with the given delivery
+// ID, Unix timestamp and body, as sent by WebhookSender: "sha256=" followed by
+// the hex HMAC-SHA256 of "id.timestamp.body" with secret. Receivers compare it
+// to the signature header with hmac.Equal, and reject old timestamps.
+func SignWebhook(secret []byte, id string, timestamp int64, body []byte) string {
+	return "sha256=" + webhookHMAC(secret, []byte(id+"."+strconv.FormatInt(timestamp, 10)+"."), body)
+}
+
+// Send delivers the event of type event with payload as JSON body to url, with
+// a new delivery ID. If the delivery fails and FailedDir is set, it is stored
+// there. It returns the delivery, along with the error of the last attempt.
+func (s *WebhookSender) Send(ctx context.Context, url, event string, payload any) (WebhookDelivery, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return WebhookDelivery{}, err
+	}
+
+	t := s.toolsOrNew()
+	d := WebhookDelivery{
+		ID:        t.GenerateRandomString(webhookIDLength),
+		URL:       url,
+		Event:     event,
+		Payload:   body,
+		CreatedAt: t.clock().Now().UTC(),
+	}
+	if err := s.Deliver(ctx, d); err != nil {
+		return d, s.storeFailed(d, err)
+	}
+	return d, nil
+}
+
+// Deliver sends the delivery d, signed with the current time, retrying it
+// according to Retry. Responses with a status other than 2xx return an error.
+// Failed deliveries are not stored, see Send. If ctx is in dry-run mode, the
+// delivery is logged instead of sent.
+func (s *WebhookSender) Deliver(ctx context.Context, d WebhookDelivery) error {
+	if len(s.Secret) == 0 {
+		return errors.New("webhook sender has no secret")
+	}
+	t := s.toolsOrNew()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return err
+	}
+	timestamp := t.clock().Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-ID", d.ID)
+	req.Header.Set("X-Webhook-Event", d.Event)
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set(s.signatureHeader(), SignWebhook(s.Secret, d.ID, timestamp, d.Payload))
+
+	if IsDryRun(ctx) {
+		t.logger().InfoContext(ctx, "dry run: webhook not sent", "url", d.URL, "event", d.Event, "id", d.ID)
+		return nil
+	}
+
+	httpClient := s.Client
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	_, status, err := t.pushWithRetry(ctx, httpClient, req, s.Retry, nil)
+	if err != nil {
+		return err
+	}
+	if status < 200 || status > 299 {
+		return fmt.Errorf("webhook endpoint responded with status %d", status)
+	}
+	return nil
+}
+
+// FailedDeliveries returns the deliveries stored in FailedDir, oldest first.
+func (s *WebhookSender) FailedDeliveries() ([]WebhookDelivery, error) {
+	if s.FailedDir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(s.FailedDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var deliveries []WebhookDelivery
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), webhookFileExt) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.FailedDir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var d WebhookDelivery
+		if err := json.Unmarshal(data, &d); err != nil {
+			return nil, fmt.Errorf("failed delivery %s: %w", e.Name(), err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	slices.SortFunc(deliveries, func(a, b WebhookDelivery) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return deliveries, nil
+}
+
+// Replay delivers again the deliveries stored in FailedDir, oldest first, with
+// their original ID. Delivered ones are removed from FailedDir, and the others
+// are updated with their new error. It returns the number of deliveries made,
+// along with the errors of the others.
+func (s *WebhookSender) Replay(ctx context.Context) (int, error) {
+	deliveries, err := s.FailedDeliveries()
+	if err != nil {
+		return 0, err
+	}
+
+	var delivered int
+	var errs []error
+	for _, d := range deliveries {
+		if ctx.Err() != nil {
+			errs = append(errs, ctx.Err())
+			break
+		}
+		if err := s.Deliver(ctx, d); err != nil {
+			errs = append(errs, fmt.Errorf("delivery %s: %w", d.ID, s.storeFailed(d, err)))
+			continue
+		}
+		if err := os.Remove(s.failedPath(d.ID)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+		}
+		delivered++
+	}
+	return delivered, errors.Join(errs...)
+}
+
+// storeFailed stores the delivery d that failed with err in FailedDir if set,
+// and returns err, joined with the error of storing it if any.
+func (s *WebhookSender) storeFailed(d WebhookDelivery, err error) error {
+	if s.FailedDir == "" {
+		return err
+	}
+	t := s.toolsOrNew()
+	d.Error, d.FailedAt = err.Error(), t.clock().Now().UTC()
+
+	data, merr := json.Marshal(d)
+	if merr == nil {
+		merr = os.MkdirAll(s.FailedDir, 0700)
+	}
+	if merr == nil {
+		merr = writeFileReplacing(s.failedPath(d.ID), data)
+	}
+	if merr != nil {
+		t.logger().Error("storing failed webhook delivery", "id", d.ID, "url", d.URL, "error", merr)
+		return errors.Join(err, fmt.Errorf("storing failed delivery: %w", merr))
+	}
+	return err
+}
+
+// failedPath returns the path of the failed delivery with the given ID.
+func (s *WebhookSender) failedPath(id string) string {
+	return filepath.Join(s.FailedDir, filepath.Base(id)+webhookFileExt)
+}
+
+// signatureHeader returns the SignatureHeader of the sender, or X-Signature if
+// not set.
+func (s *WebhookSender) signatureHeader() string {
+	if s.SignatureHeader == "" {
+		return defaultWebhookSignatureHeader
+	}
+	return s.SignatureHeader
+}
+
+// toolsOrNew returns the Tools struct of the sender, or a new one for senders
+// not created with NewWebhookSender.
+func (s *WebhookSender) toolsOrNew() *Tools {
+	if s.tools == nil {
+		return New()
+	}
+	return s.tools
+}
+
+// WebhookScheme is the way a webhook provider signs its requests, verified by
+// VerifyWebhookSignature.
+type WebhookScheme int
+
+const (
+	// WebhookSchemeSender verifies the requests of a WebhookSender, whose
+	// signature header defaults to X-Signature
+	WebhookSchemeSender WebhookScheme = iota
+	// WebhookSchemeGitHub verifies "sha256=<hex>" signatures of the body, as
+	// sent by GitHub in the X-Hub-Signature-256 header
+	WebhookSchemeGitHub
+	// WebhookSchemeStripe verifies "t=<timestamp>,v1=<hex>" signatures of the
+	// timestamp and the body, as sent by Stripe in the Stripe-Signature header
+	WebhookSchemeStripe
+)
+
+// WebhookVerifyOptions configures VerifyWebhookSignature.
+type WebhookVerifyOptions struct {
+	// NonceStore, if set, records the verified webhooks until their
+	// timestamp is too old to be accepted, so each one is only accepted
+	// once: by delivery ID for the WebhookSchemeSender scheme, and by
+	// timestamp and signature for the others. Default to accepting replays
+	NonceStore TokenStore
+}
+
+// VerifyWebhookSignature checks that the inbound webhook r is signed with secret
+// using HMAC-SHA256 following scheme, before its body is read with JSONRead.
+// header is the header of the signature, and defaults to the one of the scheme
+// if empty. Signatures are compared in constant time. Schemes with a timestamp
+// reject signatures older or newer than 5 minutes with ErrSignatureExpired, and
+// other failures return an error wrapping ErrInvalidSignature. With a NonceStore,
+// webhooks already verified return ErrSignatureReplayed. The body of r is
+// replaced with a copy, and must not be larger than 10MB.
+func (t *Tools) VerifyWebhookSignature(r *http.Request, secret []byte, header string, scheme WebhookScheme, opts ...WebhookVerifyOptions) error {
+	var options WebhookVerifyOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	if len(secret) == 0 {
+		return errors.New("webhook secret is not set")
+	}
+	if header == "" {
+		header = scheme.header()
+	}
+	signature := r.Header.Get(header)
+	if signature == "" {
+		return fmt.Errorf("%w: missing header %s", ErrInvalidSignature, header)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, defaultSignedBodySize+1))
+	r.Body.Close()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	if int64(len(body)) > defaultSignedBodySize {
+		return fmt.Errorf("%w: body is too large", ErrInvalidSignature)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	var timestamp string
+	var candidates []string
+	var expected string
+	switch scheme {
+	case WebhookSchemeSender:
+		timestamp = r.Header.Get("X-Webhook-Timestamp")
+		created, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%w: invalid timestamp", ErrInvalidSignature)
+		}
+		candidates = []string{signature}
+		expected = SignWebhook(secret, r.Header.Get("X-Webhook-ID"), created, body)
+	case WebhookSchemeGitHub:
+		candidates = []string{signature}
+		expected = "sha256=" + webhookHMAC(secret, body)
+	case WebhookSchemeStripe:
+		// several v1 signatures are sent while a secret is being rolled
+		for _, part := range strings.Split(signature, ",") {
+			key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+			switch key {
+			case "t":
+				timestamp = value
+			case "v1":
+				candidates = append(candidates, value)
+			}
+		}
+		expected = webhookHMAC(secret, []byte(timestamp+"."), body)
+	default:
+		return fmt.Errorf("unknown webhook scheme %d", scheme)
+	}
+
+	if !slices.ContainsFunc(candidates, func(c string) bool { return hmac.Equal([]byte(c), []byte(expected)) }) {
+		return ErrInvalidSignature
+	}
+
+	// timestamps are only checked once the signature proved them authentic
+	now := t.clock().Now()
+	expires := now.Add(defaultSignatureMaxSkew)
+	if timestamp != "" {
+		created, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%w: invalid timestamp", ErrInvalidSignature)
+		}
+		if skew := now.Sub(time.Unix(created, 0)); skew > defaultSignatureMaxSkew || skew < -defaultSignatureMaxSkew {
+			return ErrSignatureExpired
+		}
+		expires = time.Unix(created, 0).Add(defaultSignatureMaxSkew)
+	}
+
+	if options.NonceStore != nil {
+		nonce := "webhook:" + timestamp + ":" + expected
+		if scheme == WebhookSchemeSender {
+			nonce = "webhook:" + r.Header.Get("X-Webhook-ID")
+		}
+		fresh, err := options.NonceStore.Consume(r.Context(), nonce, expires)
+		if err != nil {
+			return err
+		}
+		if !fresh {
+			return ErrSignatureReplayed
+		}
+	}
+	return nil
+}
+
+// header returns the default signature header of the scheme.
+func (s WebhookScheme) header() string {
+	switch s {
+	case WebhookSchemeGitHub:
+		return "X-Hub-Signature-256"
+	case WebhookSchemeStripe:
+		return "Stripe-Signature"
+	}
+	return defaultWebhookSignatureHeader
+}
+
+// webhookHMAC returns the hex HMAC-SHA256 of the concatenated parts with secret.
+func webhookHMAC(secret []byte, parts ...[]byte) string {
+	mac := hmac.New(sha256.New, secret)
+	for _, p := range parts {
+		mac.Write(p)
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,240 @@
+package gorigumi
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/drunkleen/gorigumi/toolkittest"
+)
+
+// TestWebhookSender tests that deliveries are signed, retried with the same ID, and
+// that failed deliveries are stored and replayed.
+func TestWebhookSender(t *testing.T) {
+	secret := []byte("webhook secret")
+	var calls, failures atomic.Int32
+	var lastID atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get("X-Webhook-Timestamp"), 10, 64)
+		id := r.Header.Get("X-Webhook-ID")
+		if !hmac.Equal([]byte(r.Header.Get("X-Signature")), []byte(SignWebhook(secret, id, timestamp, body))) {
+			t.Errorf("invalid signature %q", r.Header.Get("X-Signature"))
+		}
+		if r.Header.Get("X-Webhook-Event") != "invoice.paid" || string(body) != `{"id":7}` {
+			t.Errorf("unexpected event %q with body %s", r.Header.Get("X-Webhook-Event"), body)
+		}
+		if previous, _ := lastID.Swap(id).(string); previous != "" && previous != id {
+			t.Errorf("expected retries to keep the ID %q, got %q", previous, id)
+		}
+		if failures.Load() > 0 {
+			failures.Add(-1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	sender := New().NewWebhookSender(secret)
+	sender.Retry = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+	sender.FailedDir = t.TempDir()
+	ctx := context.Background()
+
+	failures.Store(2)
+	d, err := sender.Send(ctx, srv.URL, "invoice.paid", map[string]int{"id": 7})
+	if err != nil || calls.Load() != 3 || len(d.ID) != webhookIDLength {
+		t.Errorf("expected the delivery to succeed after 3 calls, got %d calls (%v)", calls.Load(), err)
+	}
+
+	calls.Store(0)
+	lastID.Store("")
+	failures.Store(3)
+	d, err = sender.Send(ctx, srv.URL, "invoice.paid", map[string]int{"id": 7})
+	if err == nil || calls.Load() != 3 {
+		t.Errorf("expected the delivery to fail after 3 calls, got %d calls (%v)", calls.Load(), err)
+	}
+	failed, err := sender.FailedDeliveries()
+	if err != nil || len(failed) != 1 || failed[0].ID != d.ID || failed[0].Error == "" || string(failed[0].Payload) != `{"id":7}` {
+		t.Fatalf("expected the failed delivery to be stored, got %+v (%v)", failed, err)
+	}
+
+	delivered, err := sender.Replay(ctx)
+	if err != nil || delivered != 1 {
+		t.Errorf("expected the delivery to be replayed, got %d (%v)", delivered, err)
+	}
+	if failed, _ := sender.FailedDeliveries(); len(failed) != 0 {
+		t.Errorf("expected the replayed delivery to be removed, got %+v", failed)
+	}
+}
+
+// TestWebhookSender_clientError tests that 4xx responses are neither retried nor
+// successful, and that a secret is required.
+func TestWebhookSender_clientError(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer srv.Close()
+
+	sender := New().NewWebhookSender([]byte("secret"))
+	sender.SignatureHeader = "X-Hub-Signature-256"
+	if _, err := sender.Send(context.Background(), srv.URL, "ping", nil); err == nil || calls.Load() != 1 {
+		t.Errorf("expected a single failed call, got %d calls (%v)", calls.Load(), err)
+	}
+
+	sender.Secret = nil
+	if _, err := sender.Send(context.Background(), srv.URL, "ping", nil); err == nil {
+		t.Error("expected an error without a secret")
+	}
+}
+
+// TestWebhookSender_dryRun tests that deliveries in dry-run mode are neither sent
+// nor stored as failed.
+func TestWebhookSender_dryRun(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	var logs bytes.Buffer
+	testTools := &Tools{Logger: slog.New(slog.NewTextHandler(&logs, nil))}
+	sender := testTools.NewWebhookSender([]byte("webhook secret"))
+	sender.FailedDir = t.TempDir()
+
+	d, err := sender.Send(WithDryRun(context.Background()), srv.URL, "invoice.paid", map[string]int{"amount": 10})
+	if err != nil || d.ID == "" {
+		t.Fatalf("expected the delivery to succeed, got %+v and %v", d, err)
+	}
+	if calls.Load() != 0 {
+		t.Errorf("expected no request, got %d", calls.Load())
+	}
+	if failed, err := sender.FailedDeliveries(); err != nil || len(failed) != 0 {
+		t.Errorf("expected no failed delivery, got %d (%v)", len(failed), err)
+	}
+	if !strings.Contains(logs.String(), "dry run") {
+		t.Errorf("expected the delivery to be logged, got %q", logs.String())
+	}
+}
+
+// verifyWebhookTests is a slice of structs that hold the name of the test, the scheme,
+// the signature header, the headers of the request as a function of the body and its
+// timestamp, and the expected error
+var verifyWebhookTests = []struct {
+	name    string
+	scheme  WebhookScheme
+	header  string
+	headers func(secret, body []byte, now int64) map[string]string
+	err     error
+}{
+	{"sender", WebhookSchemeSender, "", func(secret, body []byte, now int64) map[string]string {
+		return map[string]string{"X-Webhook-ID": "a1", "X-Webhook-Timestamp": strconv.FormatInt(now, 10), "X-Signature": SignWebhook(secret, "a1", now, body)}
+	}, nil},
+	{"sender other id", WebhookSchemeSender, "", func(secret, body []byte, now int64) map[string]string {
+		return map[string]string{"X-Webhook-ID": "b2", "X-Webhook-Timestamp": strconv.FormatInt(now, 10), "X-Signature": SignWebhook(secret, "a1", now, body)}
+	}, ErrInvalidSignature},
+	{"github", WebhookSchemeGitHub, "", func(secret, body []byte, now int64) map[string]string {
+		return map[string]string{"X-Hub-Signature-256": "sha256=" + webhookHMAC(secret, body)}
+	}, nil},
+	{"github custom header", WebhookSchemeGitHub, "X-Gitea-Signature", func(secret, body []byte, now int64) map[string]string {
+		return map[string]string{"X-Gitea-Signature": "sha256=" + webhookHMAC(secret, body)}
+	}, nil},
+	{"github wrong secret", WebhookSchemeGitHub, "", func(secret, body []byte, now int64) map[string]string {
+		return map[string]string{"X-Hub-Signature-256": "sha256=" + webhookHMAC([]byte("other"), body)}
+	}, ErrInvalidSignature},
+	{"stripe rolled secret", WebhookSchemeStripe, "", func(secret, body []byte, now int64) map[string]string {
+		ts := strconv.FormatInt(now, 10)
+		return map[string]string{"Stripe-Signature": "t=" + ts + ",v1=" + webhookHMAC([]byte("old"), []byte(ts+"."), body) + ",v1=" + webhookHMAC(secret, []byte(ts+"."), body)}
+	}, nil},
+	{"stripe expired", WebhookSchemeStripe, "", func(secret, body []byte, now int64) map[string]string {
+		ts := strconv.FormatInt(now-600, 10)
+		return map[string]string{"Stripe-Signature": "t=" + ts + ",v1=" + webhookHMAC(secret, []byte(ts+"."), body)}
+	}, ErrSignatureExpired},
+	{"stripe changed timestamp", WebhookSchemeStripe, "", func(secret, body []byte, now int64) map[string]string {
+		ts := strconv.FormatInt(now-600, 10)
+		return map[string]string{"Stripe-Signature": "t=" + strconv.FormatInt(now, 10) + ",v1=" + webhookHMAC(secret, []byte(ts+"."), body)}
+	}, ErrInvalidSignature},
+	{"missing header", WebhookSchemeStripe, "", func(secret, body []byte, now int64) map[string]string {
+		return nil
+	}, ErrInvalidSignature},
+}
+
+// TestTools_VerifyWebhookSignature tests the verification of inbound webhooks signed
+// with the supported schemes, and that the body can still be read afterwards.
+func TestTools_VerifyWebhookSignature(t *testing.T) {
+	clock := toolkittest.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	testTools := &Tools{Clock: clock}
+	secret := []byte("whsec")
+	body := []byte(`{"type":"invoice.paid"}`)
+
+	for _, vt := range verifyWebhookTests {
+		req := httptest.NewRequest(http.MethodPost, "/webhooks", bytes.NewReader(body))
+		for key, value := range vt.headers(secret, body, clock.Now().Unix()) {
+			req.Header.Set(key, value)
+		}
+
+		err := testTools.VerifyWebhookSignature(req, secret, vt.header, vt.scheme)
+		if !errors.Is(err, vt.err) || (err != nil) != (vt.err != nil) {
+			t.Errorf("%s: expected error %v, got %v", vt.name, vt.err, err)
+			continue
+		}
+		if got, _ := io.ReadAll(req.Body); !bytes.Equal(got, body) {
+			t.Errorf("%s: expected the body to be readable, got %s", vt.name, got)
+		}
+	}
+}
+
+// TestTools_VerifyWebhookSignature_replay tests that webhooks are only accepted once
+// with a NonceStore, until their timestamp expires.
+func TestTools_VerifyWebhookSignature_replay(t *testing.T) {
+	clock := toolkittest.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	testTools := &Tools{Clock: clock}
+	secret := []byte("whsec")
+	body := []byte(`{"type":"invoice.paid"}`)
+	options := WebhookVerifyOptions{NonceStore: NewMemoryTokenStore(clock)}
+
+	for _, vt := range verifyWebhookTests {
+		if vt.err != nil {
+			continue
+		}
+		// every test has its own body, as identical GitHub webhooks are replays
+		body := []byte(`{"type":"` + vt.name + `"}`)
+		headers := vt.headers(secret, body, clock.Now().Unix())
+		for i, expected := range []error{nil, ErrSignatureReplayed} {
+			req := httptest.NewRequest(http.MethodPost, "/webhooks", bytes.NewReader(body))
+			for key, value := range headers {
+				req.Header.Set(key, value)
+			}
+			if err := testTools.VerifyWebhookSignature(req, secret, vt.header, vt.scheme, options); !errors.Is(err, expected) || (err != nil) != (expected != nil) {
+				t.Errorf("%s: expected error %v on delivery %d, got %v", vt.name, expected, i+1, err)
+			}
+		}
+	}
+
+	// an invalid signature doesn't consume the delivery ID
+	now := clock.Now().Unix()
+	for i, signature := range []string{"sha256=forged", SignWebhook(secret, "c3", now, body)} {
+		req := httptest.NewRequest(http.MethodPost, "/webhooks", bytes.NewReader(body))
+		req.Header.Set("X-Webhook-ID", "c3")
+		req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(now, 10))
+		req.Header.Set("X-Signature", signature)
+		err := testTools.VerifyWebhookSignature(req, secret, "", WebhookSchemeSender, options)
+		if (i == 0) != errors.Is(err, ErrInvalidSignature) || (i == 1 && err != nil) {
+			t.Errorf("forged then genuine: unexpected error %v on delivery %d", err, i+1)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package gorigumi
+
+import (
+	"net/http"
+
+	"github.com/drunkleen/gorigumi/xmlx"
+)
+
+// CodeInvalidXML is the error code of malformed XML bodies read by XMLRead.
+const CodeInvalidXML = xmlx.CodeInvalidXML
+
+// XMLResponse is the envelope of the responses written by XMLError, the
+// counterpart of JSONResponse.
+type XMLResponse = xmlx.Response
+
+// XMLRead reads an XML document from the request body and decodes it into data,
+// like JSONRead. The size of the body is limited to MaxXMLSize, 1MB if it is not
+// set, and decoding errors are reported with the same messages and error codes,
+// e.g. "body contains badly-formed XML (at line 3)". Unknown elements and
+// attributes are always ignored, as encoding/xml can't reject them, regardless of
+// AllowUnknownFields. External entities are never resolved.
+func (t *Tools) XMLRead(w http.ResponseWriter, r *http.Request, data any) error {
+	return xmlx.Read(w, r, data, t.MaxXMLSize)
+}
+
+// XMLWrite writes data as an XML response, after the XML declaration, with the
+// specified HTTP status code and the optional headers, like JSONWrite.
+func (t *Tools) XMLWrite(w http.ResponseWriter, status int, data any, headers ...http.Header) error {
+	return xmlx.Write(w, status, data, headers...)
+}
+
+// XMLError writes err as an XMLResponse with the specified HTTP status code, or
+// 500 Internal Server Error if none is provided, like JSONError. The code of the
+// response is the error code of err or else the code of the status.
+func (t *Tools) XMLError(w http.ResponseWriter, err error, status ...int) error {
+	return xmlx.Error(w, err, status...)
+}
@@ -0,0 +1,73 @@
+package gorigumi
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// xmlReadTests is a slice of structs that hold the name of the test, the body, the
+// maximum XML size and the expected error code
+var xmlReadTests = []struct {
+	name    string
+	body    string
+	maxSize int
+	code    string
+}{
+	{"valid", `<feed><title>news</title></feed>`, 0, ""},
+	{"malformed", `<feed><title>news</feed>`, 0, CodeInvalidXML},
+	{"too large", `<feed><title>` + strings.Repeat("x", 100) + `</title></feed>`, 50, CodePayloadTooLarge},
+}
+
+// TestTools_XMLRead tests the XMLRead method with valid, malformed and too large bodies.
+func TestTools_XMLRead(t *testing.T) {
+	for _, xt := range xmlReadTests {
+		testTools := New()
+		testTools.MaxXMLSize = xt.maxSize
+
+		var feed struct {
+			Title string `xml:"title"`
+		}
+		req := httptest.NewRequest("POST", "/", strings.NewReader(xt.body))
+		err := testTools.XMLRead(httptest.NewRecorder(), req, &feed)
+
+		if xt.code == "" && (err != nil || feed.Title != "news") {
+			t.Errorf("%s: expected title news, got %q (%v)", xt.name, feed.Title, err)
+		}
+		if xt.code != "" && ErrorCode(err) != xt.code {
+			t.Errorf("%s: expected error code %q, got %v", xt.name, xt.code, err)
+		}
+	}
+}
+
+// TestTools_XMLWrite tests that XMLWrite and XMLError write XML documents.
+func TestTools_XMLWrite(t *testing.T) {
+	testTools := New()
+
+	type item struct {
+		XMLName xml.Name `xml:"item"`
+		Name    string   `xml:"name,attr"`
+	}
+	rr := httptest.NewRecorder()
+	if err := testTools.XMLWrite(rr, http.StatusOK, item{Name: "a"}, http.Header{"X-Foo": {"bar"}}); err != nil {
+		t.Fatal(err)
+	}
+	if rr.Body.String() != xml.Header+`<item name="a"></item>` || rr.Header().Get("X-Foo") != "bar" {
+		t.Errorf("unexpected response %v %q", rr.Header(), rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	if err := testTools.XMLError(rr, WithErrorCode(errors.New("no such feed"), CodeNotFound), http.StatusNotFound); err != nil {
+		t.Fatal(err)
+	}
+	var res XMLResponse
+	if err := xml.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if !res.Error || res.Message != "no such feed" || res.Code != CodeNotFound || rr.Code != http.StatusNotFound {
+		t.Errorf("unexpected error response %d %+v", rr.Code, res)
+	}
+}
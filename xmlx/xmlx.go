@@ -0,0 +1,155 @@
+// Package xmlx reads and writes XML request and response bodies, with the same
+// size limits, error messages and error codes as the jsonx package. It is the
+// implementation of gorigumi's XML methods and can be imported on its own.
+package xmlx
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/drunkleen/gorigumi/jsonx"
+)
+
+// DefaultMaxBytes is the default maximum size of an XML request body
+const DefaultMaxBytes int = 1024 * 1024 // 1MB
+
+// CodeInvalidXML is the error code of malformed XML bodies, the counterpart
+// of jsonx.CodeInvalidJSON
+const CodeInvalidXML = "invalid_xml"
+
+// Response is the envelope of XML responses, the counterpart of
+// jsonx.Response. Error indicates whether the response is an error, Message
+// holds the error or informational message, Code the machine-readable code of
+// errors and Data the payload. Empty fields are omitted.
+type Response struct {
+	XMLName xml.Name `xml:"response"`
+	Error   bool     `xml:"error,omitempty"`
+	Message string   `xml:"message,omitempty"`
+	Code    string   `xml:"code,omitempty"`
+	Data    any      `xml:"data,omitempty"`
+}
+
+// Read decodes the body of r into v. The body is limited to maxBytes, or to
+// DefaultMaxBytes if maxBytes is zero.
+func Read(w http.ResponseWriter, r *http.Request, v any, maxBytes int) error {
+	if maxBytes == 0 {
+		maxBytes = DefaultMaxBytes
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+
+	return Decode(r.Body, v, maxBytes)
+}
+
+// Decode decodes exactly one XML document from body into v and classifies
+// decoding errors with ClassifyError. The size limit must already be enforced
+// on body, e.g. with http.MaxBytesReader; maxBytes is only used in error
+// messages. Unlike JSON decoding, unknown elements and attributes are always
+// ignored, as encoding/xml can't reject them. encoding/xml does not resolve
+// external entities, so documents can't read local files or other URLs.
+func Decode(body io.Reader, v any, maxBytes int) error {
+	decoder := xml.NewDecoder(body)
+	if err := decoder.Decode(v); err != nil {
+		return ClassifyError(err, maxBytes)
+	}
+
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return ClassifyError(err, maxBytes)
+		}
+		switch tok := tok.(type) {
+		case xml.CharData:
+			if len(bytes.TrimSpace(tok)) == 0 {
+				continue
+			}
+		case xml.Comment, xml.ProcInst:
+			continue
+		}
+		return jsonx.WithCode(errors.New("body must not contain more than one XML document"), CodeInvalidXML)
+	}
+}
+
+// ClassifyError converts an error returned by a xml.Decoder into an error
+// message suitable for the client, with the code CodeInvalidXML, or
+// jsonx.CodePayloadTooLarge when the body was too large. maxBytes is the size
+// limit reported in that case.
+func ClassifyError(err error, maxBytes int) error {
+	var syntaxError *xml.SyntaxError
+	var unmarshalError xml.UnmarshalError
+	var numError *strconv.NumError
+	var maxBytesError *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &maxBytesError), err.Error() == "http: request body too large":
+		return jsonx.WithCode(fmt.Errorf("body must not be larger than %d bytes", maxBytes), jsonx.CodePayloadTooLarge)
+
+	case errors.As(err, &syntaxError):
+		if syntaxError.Msg == "unexpected EOF" {
+			return jsonx.WithCode(errors.New("body contains badly-formed XML"), CodeInvalidXML)
+		}
+		return jsonx.WithCode(fmt.Errorf("body contains badly-formed XML (at line %d)", syntaxError.Line), CodeInvalidXML)
+
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return jsonx.WithCode(errors.New("body contains badly-formed XML"), CodeInvalidXML)
+
+	case errors.As(err, &numError):
+		return jsonx.WithCode(fmt.Errorf("body contains incorrect XML value %q", numError.Num), CodeInvalidXML)
+
+	case errors.As(err, &unmarshalError):
+		return jsonx.WithCode(fmt.Errorf("body contains invalid XML: %s", unmarshalError), CodeInvalidXML)
+
+	case errors.Is(err, io.EOF):
+		return jsonx.WithCode(errors.New("body must not be empty"), CodeInvalidXML)
+
+	default:
+		return err
+	}
+}
+
+// Write marshals data and writes it to w, after the XML declaration, with the
+// given status code and the optional headers.
+func Write(w http.ResponseWriter, status int, data any, headers ...http.Header) error {
+	out, err := xml.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if len(headers) > 0 {
+		for key, value := range headers[0] {
+			w.Header()[key] = value
+		}
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(status)
+	if _, err = io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	if _, err = w.Write(out); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Error writes err as an error Response with the given status code, or
+// 500 Internal Server Error if none is provided. The Code of the response is
+// the code of err, see jsonx.Code, or else the code of the status.
+func Error(w http.ResponseWriter, err error, status ...int) error {
+	statusCode := http.StatusInternalServerError
+	if len(status) > 0 {
+		statusCode = status[0]
+	}
+
+	code := jsonx.Code(err)
+	if code == "" {
+		code = jsonx.StatusCode(statusCode)
+	}
+
+	return Write(w, statusCode, Response{Error: true, Message: err.Error(), Code: code})
+}
@@ -0,0 +1,85 @@
+package xmlx
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/drunkleen/gorigumi/jsonx"
+)
+
+// decodeTests is a slice of structs that hold the name of the test, the body, the
+// expected error message and the expected error code
+var decodeTests = []struct {
+	name         string
+	body         string
+	errorMessage string
+	code         string
+}{
+	{"valid", `<item><foo>bar</foo><count>2</count></item>`, "", ""},
+	{"declaration and comment", xml.Header + `<item><foo>bar</foo></item><!-- end -->` + "\n", "", ""},
+	{"unknown element", `<item><fooo>bar</fooo></item>`, "", ""},
+	{"syntax", "<item>\n<foo>bar</fo></item>", "body contains badly-formed XML (at line 2)", CodeInvalidXML},
+	{"truncated", `<item><foo>`, "body contains badly-formed XML", CodeInvalidXML},
+	{"type", `<item><count>many</count></item>`, `body contains incorrect XML value "many"`, CodeInvalidXML},
+	{"wrong root", `<other></other>`, "body contains invalid XML: expected element type <item> but have <other>", CodeInvalidXML},
+	{"empty", ``, "body must not be empty", CodeInvalidXML},
+	{"two documents", `<item></item><item></item>`, "body must not contain more than one XML document", CodeInvalidXML},
+}
+
+// TestDecode tests the classification of decoding errors.
+func TestDecode(t *testing.T) {
+	for _, dt := range decodeTests {
+		var decoded struct {
+			XMLName xml.Name `xml:"item"`
+			Foo     string   `xml:"foo"`
+			Count   int      `xml:"count"`
+		}
+		err := Decode(strings.NewReader(dt.body), &decoded, DefaultMaxBytes)
+
+		if dt.errorMessage == "" {
+			if err != nil {
+				t.Errorf("%s: %s", dt.name, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != dt.errorMessage {
+			t.Errorf("%s: expected error %q, got %v", dt.name, dt.errorMessage, err)
+			continue
+		}
+		if code := jsonx.Code(err); code != dt.code {
+			t.Errorf("%s: expected code %q, got %q", dt.name, dt.code, code)
+		}
+	}
+}
+
+// TestRead tests that Read enforces the size limit.
+func TestRead(t *testing.T) {
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`<item><foo>`+strings.Repeat("x", 100)+`</foo></item>`))
+	var decoded struct {
+		Foo string `xml:"foo"`
+	}
+	err := Read(httptest.NewRecorder(), req, &decoded, 50)
+	if err == nil || err.Error() != "body must not be larger than 50 bytes" || jsonx.Code(err) != jsonx.CodePayloadTooLarge {
+		t.Errorf("expected size error, got %v", err)
+	}
+}
+
+// TestError tests that Error writes an error Response with the given status.
+func TestError(t *testing.T) {
+	rr := httptest.NewRecorder()
+	if err := Error(rr, errors.New("boom"), http.StatusNotFound); err != nil {
+		t.Fatal(err)
+	}
+
+	if rr.Code != http.StatusNotFound || rr.Header().Get("Content-Type") != "application/xml; charset=utf-8" {
+		t.Errorf("unexpected status %d or content type %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	expected := xml.Header + "<response><error>true</error><message>boom</message><code>not_found</code></response>"
+	if rr.Body.String() != expected {
+		t.Errorf("expected body %q, got %q", expected, rr.Body.String())
+	}
+}
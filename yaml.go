@@ -0,0 +1,27 @@
+package gorigumi
+
+import (
+	"net/http"
+
+	"github.com/drunkleen/gorigumi/yamlx"
+)
+
+// CodeInvalidYAML is the error code of malformed YAML bodies read by YAMLRead.
+const CodeInvalidYAML = yamlx.CodeInvalidYAML
+
+// YAMLRead reads a YAML document from the request body and decodes it into data,
+// like JSONRead, e.g. for configuration-style APIs. Fields are matched by their
+// json tags. The size of the body is limited to MaxYAMLSize, 1MB if it is not set,
+// and unknown fields are rejected unless AllowUnknownFields is set. Decoding errors
+// are reported with the same messages and error codes as JSONRead, e.g. "body
+// contains badly-formed YAML (at line 3): duplicate key". Anchors, aliases and tags
+// are not supported, see the yamlx package.
+func (t *Tools) YAMLRead(w http.ResponseWriter, r *http.Request, data any) error {
+	return yamlx.Read(w, r, data, t.MaxYAMLSize, t.AllowUnknownFields)
+}
+
+// YAMLWrite writes data as a YAML document with the specified HTTP status code and
+// the optional headers, like JSONWrite. Fields are named after their json tags.
+func (t *Tools) YAMLWrite(w http.ResponseWriter, status int, data any, headers ...http.Header) error {
+	return yamlx.Write(w, status, data, headers...)
+}
@@ -0,0 +1,61 @@
+package gorigumi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// yamlReadTests is a slice of structs that hold the name of the test, the body, the
+// maximum YAML size, a boolean that indicates if unknown fields are allowed and the
+// expected error code
+var yamlReadTests = []struct {
+	name               string
+	body               string
+	maxSize            int
+	allowUnknownFields bool
+	code               string
+}{
+	{"valid", "title: news\n", 0, false, ""},
+	{"unknown field", "title: news\nauthor: me\n", 0, false, CodeInvalidYAML},
+	{"allowed unknown field", "title: news\nauthor: me\n", 0, true, ""},
+	{"malformed", "title: [news\n", 0, false, CodeInvalidYAML},
+	{"too large", "title: " + strings.Repeat("x", 100) + "\n", 50, false, CodePayloadTooLarge},
+}
+
+// TestTools_YAMLRead tests the YAMLRead method with valid, malformed and too large bodies.
+func TestTools_YAMLRead(t *testing.T) {
+	for _, yt := range yamlReadTests {
+		testTools := New()
+		testTools.MaxYAMLSize = yt.maxSize
+		testTools.AllowUnknownFields = yt.allowUnknownFields
+
+		var feed struct {
+			Title string `json:"title"`
+		}
+		req := httptest.NewRequest("POST", "/", strings.NewReader(yt.body))
+		err := testTools.YAMLRead(httptest.NewRecorder(), req, &feed)
+
+		if yt.code == "" && (err != nil || feed.Title != "news") {
+			t.Errorf("%s: expected title news, got %q (%v)", yt.name, feed.Title, err)
+		}
+		if yt.code != "" && ErrorCode(err) != yt.code {
+			t.Errorf("%s: expected code %s, got %v", yt.name, yt.code, err)
+		}
+	}
+}
+
+// TestTools_YAMLWrite tests that YAMLWrite writes a block style document.
+func TestTools_YAMLWrite(t *testing.T) {
+	testTools := New()
+
+	rr := httptest.NewRecorder()
+	data := map[string]any{"title": "news", "tags": []string{"a", "b"}}
+	if err := testTools.YAMLWrite(rr, http.StatusCreated, data); err != nil {
+		t.Fatal(err)
+	}
+	if rr.Code != http.StatusCreated || rr.Body.String() != "tags:\n  - a\n  - b\ntitle: news\n" {
+		t.Errorf("unexpected response %d:\n%s", rr.Code, rr.Body.String())
+	}
+}
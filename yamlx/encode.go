@@ -0,0 +1,164 @@
+package yamlx
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// entry is an entry of a mapping.
+type entry struct {
+	key   string
+	value any
+}
+
+// mapping is a mapping whose entries keep the order of the JSON object it was
+// read from.
+type mapping []entry
+
+// readNode reads the next JSON value of decoder as a mapping, a []any or a
+// scalar.
+func readNode(decoder *json.Decoder) (any, error) {
+	tok, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		m := mapping{}
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := readNode(decoder)
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, entry{key: key.(string), value: value})
+		}
+		_, err := decoder.Token()
+		return m, err
+
+	case json.Delim('['):
+		items := []any{}
+		for decoder.More() {
+			item, err := readNode(decoder)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		_, err := decoder.Token()
+		return items, err
+	}
+	return tok, nil
+}
+
+// writeNode writes the document node, indented by indent spaces.
+func writeNode(buf *bytes.Buffer, node any, indent int) {
+	switch node := node.(type) {
+	case mapping:
+		if len(node) > 0 {
+			writeMapping(buf, node, indent, false)
+			return
+		}
+	case []any:
+		if len(node) > 0 {
+			writeSequence(buf, node, indent)
+			return
+		}
+	}
+	buf.WriteString(scalar(node))
+	buf.WriteByte('\n')
+}
+
+// writeMapping writes the entries of m, indented by indent spaces. If inline
+// is true, the first entry follows a sequence item indicator.
+func writeMapping(buf *bytes.Buffer, m mapping, indent int, inline bool) {
+	for i, e := range m {
+		if i > 0 || !inline {
+			buf.WriteString(strings.Repeat(" ", indent))
+		}
+		buf.WriteString(quoteIfNeeded(e.key))
+		buf.WriteByte(':')
+		writeValue(buf, e.value, indent)
+	}
+}
+
+// writeSequence writes the items of s, indented by indent spaces.
+func writeSequence(buf *bytes.Buffer, s []any, indent int) {
+	for _, item := range s {
+		buf.WriteString(strings.Repeat(" ", indent))
+		buf.WriteByte('-')
+		if m, ok := item.(mapping); ok && len(m) > 0 {
+			buf.WriteByte(' ')
+			writeMapping(buf, m, indent+2, true)
+			continue
+		}
+		writeValue(buf, item, indent)
+	}
+}
+
+// writeValue writes the value of a mapping entry or a sequence item, nested
+// collections being indented by indent+2 spaces on the following lines.
+func writeValue(buf *bytes.Buffer, value any, indent int) {
+	switch value := value.(type) {
+	case mapping:
+		if len(value) > 0 {
+			buf.WriteByte('\n')
+			writeMapping(buf, value, indent+2, false)
+			return
+		}
+	case []any:
+		if len(value) > 0 {
+			buf.WriteByte('\n')
+			writeSequence(buf, value, indent+2)
+			return
+		}
+	}
+	buf.WriteByte(' ')
+	buf.WriteString(scalar(value))
+	buf.WriteByte('\n')
+}
+
+// scalar returns the YAML representation of a scalar or an empty collection.
+func scalar(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(value)
+	case json.Number:
+		return value.String()
+	case string:
+		return quoteIfNeeded(value)
+	case mapping:
+		return "{}"
+	case []any:
+		return "[]"
+	}
+	return ""
+}
+
+// quoteIfNeeded returns s as a plain scalar, or as a double-quoted scalar if it
+// would otherwise be read as another value or break the document.
+func quoteIfNeeded(s string) string {
+	if s == "" || strings.ContainsRune("-?:,[]{}#&*!|>'\"%@` ", rune(s[0])) ||
+		strings.HasSuffix(s, " ") || strings.HasSuffix(s, ":") ||
+		strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasPrefix(s, "...") {
+		return strconv.Quote(s)
+	}
+	for _, r := range s {
+		if !unicode.IsPrint(r) {
+			return strconv.Quote(s)
+		}
+	}
+	if value, err := resolve(s); err != nil || value != s {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -0,0 +1,769 @@
+package yamlx
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/drunkleen/gorigumi/jsonx"
+)
+
+// maxDepth is the maximum nesting depth of the collections of a document,
+// block and flow collections combined
+const maxDepth int = 1000
+
+// parser parses the subset of YAML supported by Decode into the values of
+// encoding/json: map[string]any, []any, string, json.Number, bool and nil.
+type parser struct {
+	lines []string
+	pos   int
+	// shifted is the index of the line whose content starts at column
+	// rather than after its indentation, e.g. the nested node of "- key: v"
+	shifted int
+	column  int
+	// depth is the nesting depth of the node being parsed
+	depth int
+}
+
+// errTooDeep is the error of documents nested deeper than maxDepth at a line.
+func errTooDeep(line int) error {
+	return parseError(line, "nesting exceeds the maximum depth of %d", maxDepth)
+}
+
+// parseError is the error of a malformed document at a line, 1-based.
+func parseError(line int, format string, args ...any) error {
+	return jsonx.WithCode(fmt.Errorf("body contains badly-formed YAML (at line %d): %s", line, fmt.Sprintf(format, args...)), CodeInvalidYAML)
+}
+
+// parse parses the single document of data.
+func parse(data []byte) (any, error) {
+	if !utf8.Valid(data) {
+		return nil, jsonx.WithCode(fmt.Errorf("body must be UTF-8 encoded"), CodeInvalidYAML)
+	}
+	text := strings.TrimPrefix(string(data), "\ufeff")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	p := &parser{lines: strings.Split(text, "\n"), shifted: -1}
+	for i, line := range p.lines {
+		if strings.HasPrefix(line, "\t") && strings.TrimSpace(line) != "" {
+			return nil, parseError(i+1, "tabs are not allowed in indentation")
+		}
+	}
+
+	if err := p.skipDocumentStart(); err != nil {
+		return nil, err
+	}
+	if err := p.checkDocumentEnd(); err != nil {
+		return nil, err
+	}
+	i, ok := p.peek()
+	if !ok {
+		return nil, errEmpty
+	}
+	value, err := p.parseNode(p.indent(i))
+	if err != nil {
+		return nil, err
+	}
+	if i, ok := p.peek(); ok {
+		return nil, parseError(i+1, "unexpected content %q", strings.TrimSpace(p.lines[i]))
+	}
+	return value, nil
+}
+
+// skipDocumentStart skips the directives and the document start marker, if
+// any. A document start marker may be followed by the content of the document.
+func (p *parser) skipDocumentStart() error {
+	for {
+		i, ok := p.peek()
+		if !ok {
+			return nil
+		}
+		line := stripComment(p.lines[i])
+		switch {
+		case strings.HasPrefix(line, "%"):
+			p.pos = i + 1
+		case line == "---":
+			p.pos = i + 1
+			return nil
+		case strings.HasPrefix(line, "--- "):
+			p.lines[i] = "    " + strings.TrimSpace(line[4:])
+			return nil
+		default:
+			return nil
+		}
+	}
+}
+
+// checkDocumentEnd drops the lines following the document end marker, if
+// any, and returns errMultipleDocuments if the document is followed by
+// another one.
+func (p *parser) checkDocumentEnd() error {
+	for i := p.pos; i < len(p.lines); i++ {
+		line := stripComment(p.lines[i])
+		switch {
+		case line == "---", strings.HasPrefix(line, "--- "):
+			return errMultipleDocuments
+		case line == "...":
+			for _, rest := range p.lines[i+1:] {
+				if rest := strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+					return errMultipleDocuments
+				}
+			}
+			p.lines = p.lines[:i]
+			return nil
+		}
+	}
+	return nil
+}
+
+// peek returns the index of the next line holding content, skipping blank
+// lines and comments.
+func (p *parser) peek() (int, bool) {
+	for i := p.pos; i < len(p.lines); i++ {
+		content := strings.TrimSpace(p.lines[i])
+		if content != "" && !strings.HasPrefix(content, "#") {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// indentOf returns the number of leading spaces of line.
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// indent returns the column the content of line i starts at: its indentation,
+// or the column of the nested node for the line shifted by parseSequence.
+func (p *parser) indent(i int) int {
+	if i == p.shifted {
+		return p.column
+	}
+	return indentOf(p.lines[i])
+}
+
+// isSequenceItem reports whether content, a line without its indentation,
+// is an item of a block sequence.
+func isSequenceItem(content string) bool {
+	return content == "-" || strings.HasPrefix(content, "- ")
+}
+
+// parseNode parses the block node starting at the next line, indented by
+// indent spaces.
+func (p *parser) parseNode(indent int) (any, error) {
+	i, _ := p.peek()
+	line := p.lines[i]
+	if i == p.shifted {
+		line = line[p.column:]
+	}
+	if strings.HasPrefix(strings.TrimLeft(line, " "), "\t") {
+		return nil, parseError(i+1, "tabs are not allowed in indentation")
+	}
+	content := stripComment(p.lines[i][indent:])
+	if isSequenceItem(content) {
+		return p.parseSequence(indent)
+	}
+	if _, _, ok, err := splitKey(content, i+1); err != nil {
+		return nil, err
+	} else if ok {
+		return p.parseMapping(indent)
+	}
+
+	p.pos = i + 1
+	return p.parseInline(content, i+1)
+}
+
+// enter increases the depth of the parser for a nested collection, or returns
+// an error if it exceeds maxDepth.
+func (p *parser) enter() error {
+	if p.depth >= maxDepth {
+		i, _ := p.peek()
+		return errTooDeep(i + 1)
+	}
+	p.depth++
+	return nil
+}
+
+// parseMapping parses a block mapping whose keys are indented by indent
+// spaces.
+func (p *parser) parseMapping(indent int) (any, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+
+	m := make(map[string]any)
+	for {
+		i, ok := p.peek()
+		if !ok || p.indent(i) < indent {
+			return m, nil
+		}
+		if p.indent(i) > indent {
+			return nil, parseError(i+1, "bad indentation of a mapping entry")
+		}
+		content := stripComment(p.lines[i][indent:])
+		if isSequenceItem(content) {
+			return nil, parseError(i+1, "unexpected sequence item in a mapping")
+		}
+		key, rest, ok, err := splitKey(content, i+1)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, parseError(i+1, "expected a mapping key")
+		}
+		if _, exists := m[key]; exists {
+			return nil, parseError(i+1, "duplicate key %q", key)
+		}
+		p.pos = i + 1
+
+		value, err := p.parseValue(rest, indent, i+1, true)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = value
+	}
+}
+
+// parseSequence parses a block sequence whose items are indented by indent
+// spaces.
+func (p *parser) parseSequence(indent int) (any, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+
+	items := []any{}
+	for {
+		i, ok := p.peek()
+		if !ok || p.indent(i) < indent {
+			return items, nil
+		}
+		line := p.lines[i]
+		if p.indent(i) > indent {
+			return nil, parseError(i+1, "bad indentation of a sequence item")
+		}
+		content := stripComment(line[indent:])
+		if !isSequenceItem(content) {
+			return items, nil
+		}
+
+		rest := strings.TrimPrefix(content, "-")
+		item := strings.TrimLeft(rest, " ")
+		if item != "" && !strings.HasPrefix(item, "|") && !strings.HasPrefix(item, ">") {
+			// a block node starting on the line of the item, e.g. "- key: value",
+			// continues on the following lines at the column it starts at
+			column := indent + 1 + len(rest) - len(item)
+			_, _, isKey, err := splitKey(item, i+1)
+			if err != nil {
+				return nil, err
+			}
+			if isKey || isSequenceItem(item) {
+				p.shifted, p.column = i, column
+				value, err := p.parseNode(column)
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, value)
+				continue
+			}
+		}
+		p.pos = i + 1
+
+		value, err := p.parseValue(item, indent, i+1, false)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, value)
+	}
+}
+
+// parseValue parses the value following a mapping key or a sequence item
+// indicator on the line lineNo, the rest of the line, of a node indented by
+// indent spaces. An empty rest is followed by a nested block node, or is null.
+// The items of a sequence nested in a mapping may be indented like its keys.
+func (p *parser) parseValue(rest string, indent, lineNo int, inMapping bool) (any, error) {
+	switch {
+	case strings.HasPrefix(rest, "|"), strings.HasPrefix(rest, ">"):
+		return p.parseBlockScalar(rest, indent, lineNo)
+	case rest != "":
+		return p.parseInline(rest, lineNo)
+	}
+
+	i, ok := p.peek()
+	if !ok {
+		return nil, nil
+	}
+	next := p.indent(i)
+	switch {
+	case next > indent:
+		return p.parseNode(next)
+	case next == indent && inMapping && isSequenceItem(stripComment(p.lines[i][next:])):
+		return p.parseSequence(next)
+	}
+	return nil, nil
+}
+
+// parseBlockScalar parses a literal (|) or folded (>) block scalar whose
+// header is header, nested in a node indented by indent spaces.
+func (p *parser) parseBlockScalar(header string, indent, lineNo int) (any, error) {
+	header = stripComment(header)
+	folded := header[0] == '>'
+	chomping := header[1:]
+	if chomping != "" && chomping != "-" && chomping != "+" {
+		return nil, parseError(lineNo, "unsupported block scalar header %q", header)
+	}
+
+	var lines []string
+	blockIndent := -1
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if strings.TrimSpace(line) == "" {
+			lines = append(lines, "")
+			p.pos++
+			continue
+		}
+		n := indentOf(line)
+		if n <= indent || (blockIndent >= 0 && n < blockIndent) {
+			break
+		}
+		if blockIndent < 0 {
+			blockIndent = n
+		}
+		lines = append(lines, line[blockIndent:])
+		p.pos++
+	}
+
+	// trailing blank lines are only kept with the + chomping indicator
+	content := len(lines)
+	for content > 0 && lines[content-1] == "" {
+		content--
+	}
+	trailing := len(lines) - content
+	lines = lines[:content]
+
+	var b strings.Builder
+	for i, line := range lines {
+		switch {
+		case !folded:
+			if i > 0 {
+				b.WriteByte('\n')
+			}
+		case line == "":
+			// empty lines of folded scalars are line breaks, the break
+			// preceding them is folded
+			b.WriteByte('\n')
+			continue
+		case i > 0 && lines[i-1] != "":
+			// more indented lines are not folded
+			if strings.HasPrefix(line, " ") || strings.HasPrefix(lines[i-1], " ") {
+				b.WriteByte('\n')
+			} else {
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(line)
+	}
+
+	switch {
+	case content == 0:
+	case chomping == "-":
+	case chomping == "+":
+		b.WriteString(strings.Repeat("\n", trailing+1))
+	default:
+		b.WriteByte('\n')
+	}
+	return b.String(), nil
+}
+
+// parseInline parses a scalar or a flow collection written on the line lineNo.
+func (p *parser) parseInline(s string, lineNo int) (any, error) {
+	s = strings.TrimSpace(stripComment(s))
+	f := &flowParser{s: s, line: lineNo, depth: p.depth}
+	value, err := f.parse(false)
+	if err != nil {
+		return nil, err
+	}
+	f.skipSpaces()
+	if f.i < len(f.s) {
+		return nil, parseError(lineNo, "unexpected content %q", f.s[f.i:])
+	}
+	return value, nil
+}
+
+// splitKey splits content into the key of a mapping entry and the rest of
+// the line, the value. ok is false if content is not a mapping entry.
+func splitKey(content string, lineNo int) (key, rest string, ok bool, err error) {
+	if content == "" {
+		return "", "", false, nil
+	}
+	if err := checkUnsupported(content, lineNo); err != nil {
+		return "", "", false, err
+	}
+	if strings.HasPrefix(content, "? ") || content == "?" {
+		return "", "", false, parseError(lineNo, "complex mapping keys are not supported")
+	}
+
+	if content[0] == '"' || content[0] == '\'' {
+		end, err := quotedEnd(content, lineNo)
+		if err != nil {
+			return "", "", false, err
+		}
+		after := strings.TrimLeft(content[end:], " ")
+		if after != ":" && !strings.HasPrefix(after, ": ") {
+			return "", "", false, nil
+		}
+		key, err := unquote(content[:end], lineNo)
+		if err != nil {
+			return "", "", false, err
+		}
+		return key, strings.TrimSpace(after[1:]), true, nil
+	}
+	if content[0] == '[' || content[0] == '{' {
+		return "", "", false, nil
+	}
+
+	for i := 0; i < len(content); i++ {
+		if content[i] != ':' || (i+1 < len(content) && content[i+1] != ' ') {
+			continue
+		}
+		key := strings.TrimSpace(content[:i])
+		if key == "" {
+			return "", "", false, parseError(lineNo, "empty mapping key")
+		}
+		value, err := resolve(key)
+		if err != nil {
+			return "", "", false, parseError(lineNo, "%s", err)
+		}
+		return scalarString(key, value), strings.TrimSpace(content[i+1:]), true, nil
+	}
+	return "", "", false, nil
+}
+
+// scalarString returns the key of a plain scalar: its text for strings, and
+// the JSON encoding of other values, e.g. "null" or "1".
+func scalarString(text string, value any) string {
+	switch value := value.(type) {
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	case nil:
+		return "null"
+	}
+	return text
+}
+
+// checkUnsupported returns an error if s starts with a YAML feature Decode
+// does not support.
+func checkUnsupported(s string, lineNo int) error {
+	switch s[0] {
+	case '&', '*':
+		return parseError(lineNo, "anchors and aliases are not supported")
+	case '!':
+		return parseError(lineNo, "tags are not supported")
+	case '@', '`':
+		return parseError(lineNo, "plain scalars can't start with %q", s[0])
+	}
+	return nil
+}
+
+// stripComment removes the comment ending line, if any.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '\'' && c == '\'':
+			if i+1 < len(line) && line[i+1] == '\'' {
+				i++
+				continue
+			}
+			quote = 0
+		case quote == '"' && c == '\\':
+			i++
+		case quote == '"' && c == '"':
+			quote = 0
+		case quote != 0:
+		case (c == '"' || c == '\'') && (i == 0 || strings.IndexByte(" [{,:-", line[i-1]) >= 0):
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return strings.TrimRight(line[:i], " \t")
+		}
+	}
+	return strings.TrimRight(line, " \t")
+}
+
+// quotedEnd returns the index following the quoted scalar s starts with.
+func quotedEnd(s string, lineNo int) (int, error) {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case quote == '"' && s[i] == '\\':
+			i++
+		case s[i] == quote && quote == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == quote:
+			return i + 1, nil
+		}
+	}
+	return 0, parseError(lineNo, "unterminated quoted scalar, multi-line quoted scalars are not supported")
+}
+
+// unquote returns the value of the quoted scalar s.
+func unquote(s string, lineNo int) (string, error) {
+	if s[0] == '\'' {
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+
+	var b strings.Builder
+	body := s[1 : len(s)-1]
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		if c != '\\' {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		if i >= len(body) {
+			return "", parseError(lineNo, "invalid escape sequence")
+		}
+		if r, ok := simpleEscapes[body[i]]; ok {
+			b.WriteString(r)
+			continue
+		}
+		size := hexEscapes[body[i]]
+		if size == 0 || i+1+size > len(body) {
+			return "", parseError(lineNo, "invalid escape sequence \\%c", body[i])
+		}
+		code, err := strconv.ParseUint(body[i+1:i+1+size], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			return "", parseError(lineNo, "invalid escape sequence \\%s", body[i:i+1+size])
+		}
+		b.WriteRune(rune(code))
+		i += size
+	}
+	return b.String(), nil
+}
+
+// simpleEscapes are the one-character escape sequences of double-quoted
+// scalars.
+var simpleEscapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n", 'v': "\v",
+	'f': "\f", 'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"", '/': "/", '\\': "\\",
+	'N': "\u0085", '_': " ", 'L': " ", 'P': " ",
+}
+
+// hexEscapes are the escape sequences of double-quoted scalars followed by
+// the hexadecimal code of a character, by the number of their digits.
+var hexEscapes = map[byte]int{'x': 2, 'u': 4, 'U': 8}
+
+// resolve returns the value of the plain scalar s, according to the YAML 1.2
+// core schema.
+func resolve(s string) (any, error) {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	case ".inf", ".Inf", ".INF", "+.inf", "+.Inf", "+.INF", "-.inf", "-.Inf", "-.INF", ".nan", ".NaN", ".NAN":
+		return nil, fmt.Errorf("%s can't be represented in JSON", s)
+	}
+
+	if c := s[0]; c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9') {
+		digits := strings.TrimPrefix(s, "+")
+		switch {
+		case strings.HasPrefix(digits, "0x"), strings.HasPrefix(digits, "0o"):
+			base := 16
+			if digits[1] == 'o' {
+				base = 8
+			}
+			if n, err := strconv.ParseUint(digits[2:], base, 64); err == nil {
+				return json.Number(strconv.FormatUint(n, 10)), nil
+			}
+		default:
+			if n, err := strconv.ParseInt(digits, 10, 64); err == nil {
+				return json.Number(strconv.FormatInt(n, 10)), nil
+			}
+			if f, err := strconv.ParseFloat(digits, 64); err == nil && !math.IsInf(f, 0) && isDecimal(digits) {
+				if json.Valid([]byte(digits)) {
+					// keeps the precision of large integers
+					return json.Number(digits), nil
+				}
+				return json.Number(strconv.FormatFloat(f, 'g', -1, 64)), nil
+			}
+		}
+	}
+	return s, nil
+}
+
+// isDecimal reports whether s is a decimal floating-point number rather than
+// a value strconv.ParseFloat also accepts, such as "Inf" or hexadecimal.
+func isDecimal(s string) bool {
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789.eE+-", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// flowParser parses the flow collections and scalars of a single line.
+type flowParser struct {
+	s    string
+	i    int
+	line int
+	// depth is the nesting depth of the collection being parsed, including
+	// the block collections the line is nested in
+	depth int
+}
+
+// skipSpaces skips the spaces at the position of the parser.
+func (f *flowParser) skipSpaces() {
+	for f.i < len(f.s) && (f.s[f.i] == ' ' || f.s[f.i] == '\t') {
+		f.i++
+	}
+}
+
+// parse parses the value at the position of the parser. inFlow is a boolean
+// that indicates if the value is nested in a flow collection, where plain
+// scalars end at the flow indicators.
+func (f *flowParser) parse(inFlow bool) (any, error) {
+	f.skipSpaces()
+	if f.i >= len(f.s) {
+		if inFlow {
+			return nil, parseError(f.line, "unterminated flow collection, multi-line flow collections are not supported")
+		}
+		return nil, nil
+	}
+	if err := checkUnsupported(f.s[f.i:], f.line); err != nil {
+		return nil, err
+	}
+
+	switch f.s[f.i] {
+	case '[', '{':
+		if f.depth >= maxDepth {
+			return nil, errTooDeep(f.line)
+		}
+		f.depth++
+		defer func() { f.depth-- }()
+		if f.s[f.i] == '[' {
+			return f.parseSequence()
+		}
+		return f.parseMapping()
+	case '"', '\'':
+		end, err := quotedEnd(f.s[f.i:], f.line)
+		if err != nil {
+			return nil, err
+		}
+		value, err := unquote(f.s[f.i:f.i+end], f.line)
+		f.i += end
+		return value, err
+	case '|', '>':
+		return nil, parseError(f.line, "block scalars must end the line of their key")
+	case '%':
+		return nil, parseError(f.line, "plain scalars can't start with %q", f.s[f.i])
+	}
+
+	start := f.i
+	for f.i < len(f.s) {
+		c := f.s[f.i]
+		if inFlow && (c == ',' || c == ']' || c == '}') {
+			break
+		}
+		if inFlow && c == ':' && (f.i+1 == len(f.s) || strings.IndexByte(" ,]}", f.s[f.i+1]) >= 0) {
+			break
+		}
+		if !inFlow && c == ':' && f.i+1 < len(f.s) && f.s[f.i+1] == ' ' {
+			return nil, parseError(f.line, "unexpected mapping value, quote scalars holding \": \"")
+		}
+		f.i++
+	}
+	value, err := resolve(strings.TrimSpace(f.s[start:f.i]))
+	if err != nil {
+		return nil, parseError(f.line, "%s", err)
+	}
+	return value, nil
+}
+
+// parseSequence parses a flow sequence, e.g. [a, b].
+func (f *flowParser) parseSequence() (any, error) {
+	f.i++
+	items := []any{}
+	for {
+		f.skipSpaces()
+		if f.i < len(f.s) && f.s[f.i] == ']' {
+			f.i++
+			return items, nil
+		}
+		item, err := f.parse(true)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		if err := f.separator(']'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseMapping parses a flow mapping, e.g. {a: 1, b: 2}.
+func (f *flowParser) parseMapping() (any, error) {
+	f.i++
+	m := make(map[string]any)
+	for {
+		f.skipSpaces()
+		if f.i < len(f.s) && f.s[f.i] == '}' {
+			f.i++
+			return m, nil
+		}
+		keyStart := f.i
+		key, err := f.parse(true)
+		if err != nil {
+			return nil, err
+		}
+		var name string
+		switch key := key.(type) {
+		case map[string]any, []any:
+			return nil, parseError(f.line, "complex mapping keys are not supported")
+		default:
+			name = scalarString(strings.TrimSpace(f.s[keyStart:f.i]), key)
+		}
+		if _, exists := m[name]; exists {
+			return nil, parseError(f.line, "duplicate key %q", name)
+		}
+
+		f.skipSpaces()
+		var value any
+		if f.i < len(f.s) && f.s[f.i] == ':' {
+			f.i++
+			if value, err = f.parse(true); err != nil {
+				return nil, err
+			}
+		}
+		m[name] = value
+		if err := f.separator('}'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// separator consumes the comma following an entry of a flow collection, or
+// stops at its closing indicator.
+func (f *flowParser) separator(closing byte) error {
+	f.skipSpaces()
+	switch {
+	case f.i >= len(f.s):
+		return parseError(f.line, "unterminated flow collection, multi-line flow collections are not supported")
+	case f.s[f.i] == ',':
+		f.i++
+		return nil
+	case f.s[f.i] == closing:
+		return nil
+	}
+	return parseError(f.line, "expected ',' or %q in flow collection", closing)
+}
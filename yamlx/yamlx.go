@@ -0,0 +1,154 @@
+// Package yamlx reads and writes YAML request and response bodies, with the same
+// size limits, error messages and error codes as the jsonx package. It is the
+// implementation of gorigumi's YAML methods and can be imported on its own.
+//
+// YAML documents are converted to and from JSON, so values are mapped to Go
+// types by encoding/json, with their json tags. As the standard library has no
+// YAML support, the package implements the subset of YAML 1.2 used by
+// configuration documents: block and flow mappings and sequences, plain, quoted
+// and block scalars, and comments. Anchors, aliases, tags, complex keys,
+// multi-line flow collections and quoted scalars, and streams of several
+// documents are rejected with an error.
+package yamlx
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/drunkleen/gorigumi/jsonx"
+)
+
+// DefaultMaxBytes is the default maximum size of a YAML request body
+const DefaultMaxBytes int = 1024 * 1024 // 1MB
+
+// CodeInvalidYAML is the error code of malformed YAML bodies, the counterpart
+// of jsonx.CodeInvalidJSON
+const CodeInvalidYAML = "invalid_yaml"
+
+var (
+	// errEmpty is the error of bodies without a document.
+	errEmpty = jsonx.WithCode(errors.New("body must not be empty"), CodeInvalidYAML)
+	// errMultipleDocuments is the error of bodies holding several documents.
+	errMultipleDocuments = jsonx.WithCode(errors.New("body must not contain more than one YAML document"), CodeInvalidYAML)
+)
+
+// Read decodes the body of r into v. The body is limited to maxBytes, or to
+// DefaultMaxBytes if maxBytes is zero, and unknown fields are rejected unless
+// allowUnknownFields is true.
+func Read(w http.ResponseWriter, r *http.Request, v any, maxBytes int, allowUnknownFields bool) error {
+	if maxBytes == 0 {
+		maxBytes = DefaultMaxBytes
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+
+	return Decode(r.Body, v, maxBytes, allowUnknownFields)
+}
+
+// Decode decodes exactly one YAML document from body into v and classifies
+// decoding errors with ClassifyError. The size limit must already be enforced
+// on body, e.g. with http.MaxBytesReader; maxBytes is only used in error
+// messages.
+func Decode(body io.Reader, v any, maxBytes int, allowUnknownFields bool) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return ClassifyError(err, maxBytes)
+	}
+	value, err := parse(data)
+	if err != nil {
+		return err
+	}
+	out, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(out))
+	if !allowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	if err := decoder.Decode(v); err != nil {
+		return ClassifyError(err, maxBytes)
+	}
+	return nil
+}
+
+// ClassifyError converts an error returned while reading a body or decoding
+// its document into v into an error message suitable for the client, with the
+// code CodeInvalidYAML, or jsonx.CodePayloadTooLarge when the body was too
+// large. maxBytes is the size limit reported in that case.
+func ClassifyError(err error, maxBytes int) error {
+	var syntaxError *json.SyntaxError
+	var unmarshalTypeError *json.UnmarshalTypeError
+	var invalidUnmarshalError *json.InvalidUnmarshalError
+	var maxBytesError *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &maxBytesError), err.Error() == "http: request body too large":
+		return jsonx.WithCode(fmt.Errorf("body must not be larger than %d bytes", maxBytes), jsonx.CodePayloadTooLarge)
+
+	case errors.As(err, &syntaxError):
+		// e.g. "exceeded max depth" of encoding/json
+		return jsonx.WithCode(fmt.Errorf("body contains badly-formed YAML: %s", syntaxError), CodeInvalidYAML)
+
+	case errors.As(err, &unmarshalTypeError):
+		if unmarshalTypeError.Field != "" {
+			return jsonx.WithCode(fmt.Errorf("body contains incorrect YAML type for field %q", unmarshalTypeError.Field), CodeInvalidYAML)
+		}
+		return jsonx.WithCode(fmt.Errorf("body contains an invalid YAML type (%s for %s)", unmarshalTypeError.Value, unmarshalTypeError.Type), CodeInvalidYAML)
+
+	case strings.HasPrefix(err.Error(), "json: unknown field"):
+		fieldName := strings.TrimPrefix(err.Error(), "json: unknown field")
+		return jsonx.WithCode(fmt.Errorf("body contains unknown key %s", fieldName), CodeInvalidYAML)
+
+	case errors.As(err, &invalidUnmarshalError):
+		return fmt.Errorf("error unmarshalling YAML: %s", invalidUnmarshalError)
+
+	default:
+		return err
+	}
+}
+
+// Write marshals data and writes it to w as a YAML document with the given
+// status code and the optional headers. Fields are named after their json
+// tags, like the responses of jsonx.Write.
+func Write(w http.ResponseWriter, status int, data any, headers ...http.Header) error {
+	out, err := Marshal(data)
+	if err != nil {
+		return err
+	}
+	if len(headers) > 0 {
+		for key, value := range headers[0] {
+			w.Header()[key] = value
+		}
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	w.WriteHeader(status)
+	if _, err = w.Write(out); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Marshal returns the YAML document of data, in block style. The fields of
+// structs keep their order.
+func Marshal(data any) ([]byte, error) {
+	out, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(out))
+	decoder.UseNumber()
+	node, err := readNode(decoder)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	writeNode(&buf, node, 0)
+	return buf.Bytes(), nil
+}
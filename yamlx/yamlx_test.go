@@ -0,0 +1,189 @@
+package yamlx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// config is the struct decoded by the tests.
+type config struct {
+	Name     string            `json:"name"`
+	Port     int               `json:"port"`
+	Debug    bool              `json:"debug"`
+	Ratio    float64           `json:"ratio"`
+	Hosts    []string          `json:"hosts"`
+	Labels   map[string]string `json:"labels"`
+	Script   string            `json:"script"`
+	Note     string            `json:"note"`
+	Backends []backend         `json:"backends"`
+	Empty    *string           `json:"empty"`
+}
+
+// backend is a nested struct of config.
+type backend struct {
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+}
+
+// document is a configuration document using the supported YAML features.
+const document = `%YAML 1.2
+---
+# service configuration
+name: "api # 1"
+port: 0x1F90
+debug: true   # comment
+ratio: 0.5
+hosts:
+- a.example.com
+- 'b.example.com'
+labels: {env: prod, "team": core}
+script: |
+  echo one
+  echo two
+note: >-
+  folded
+  text
+
+  paragraph
+backends:
+  - url: http://a:8080/x
+    weight: 2
+  - {url: "http://b", weight: 1}
+empty: ~
+...
+`
+
+// TestDecode tests the decoding of a document into a struct.
+func TestDecode(t *testing.T) {
+	var c config
+	if err := Decode(strings.NewReader(document), &c, DefaultMaxBytes, false); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := config{
+		Name:     "api # 1",
+		Port:     8080,
+		Debug:    true,
+		Ratio:    0.5,
+		Hosts:    []string{"a.example.com", "b.example.com"},
+		Labels:   map[string]string{"env": "prod", "team": "core"},
+		Script:   "echo one\necho two\n",
+		Note:     "folded text\nparagraph",
+		Backends: []backend{{URL: "http://a:8080/x", Weight: 2}, {URL: "http://b", Weight: 1}},
+	}
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("expected %+v, got %+v", expected, c)
+	}
+}
+
+// decodeTests is a slice of structs that hold the name of the test, the body, a boolean
+// that indicates if unknown fields are allowed and the expected error message prefix
+var decodeTests = []struct {
+	name               string
+	body               string
+	allowUnknownFields bool
+	errorPrefix        string
+}{
+	{"valid", "name: foo\n", false, ""},
+	{"unknown field", "nam: foo\n", false, "body contains unknown key"},
+	{"allowed unknown field", "nam: foo\n", true, ""},
+	{"type", "port: eighty\n", false, `body contains incorrect YAML type for field "port"`},
+	{"empty", "# nothing\n", false, "body must not be empty"},
+	{"two documents", "name: a\n---\nname: b\n", false, "body must not contain more than one YAML document"},
+	{"duplicate key", "name: a\nname: b\n", false, "body contains badly-formed YAML (at line 2): duplicate key"},
+	{"bad indentation", "name: a\n  port: 1\n", false, "body contains badly-formed YAML (at line 2)"},
+	{"anchor", "name: &a foo\n", false, "body contains badly-formed YAML (at line 1): anchors"},
+	{"tab", "labels:\n\tenv: prod\n", false, "body contains badly-formed YAML (at line 2): tabs"},
+	{"unterminated flow", "hosts: [a, b\n", false, "body contains badly-formed YAML (at line 1): unterminated"},
+	{"deep block", "hosts:\n" + strings.Repeat("- ", 1000) + "x\n", false, "body contains badly-formed YAML (at line 2): nesting exceeds"},
+	{"deep flow", "hosts: " + strings.Repeat("[", 1000) + strings.Repeat("]", 1000) + "\n", false, "body contains badly-formed YAML (at line 1): nesting exceeds"},
+	{"deep mixed", "hosts:\n" + strings.Repeat("- ", 500) + strings.Repeat("[", 500) + strings.Repeat("]", 500) + "\n", false, "body contains badly-formed YAML (at line 2): nesting exceeds"},
+}
+
+// TestDecode_errors tests the classification of decoding errors.
+func TestDecode_errors(t *testing.T) {
+	for _, dt := range decodeTests {
+		var c config
+		err := Decode(strings.NewReader(dt.body), &c, DefaultMaxBytes, dt.allowUnknownFields)
+
+		if dt.errorPrefix == "" {
+			if err != nil {
+				t.Errorf("%s: %s", dt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.HasPrefix(err.Error(), dt.errorPrefix) {
+			t.Errorf("%s: expected error %q, got %v", dt.name, dt.errorPrefix, err)
+		}
+	}
+}
+
+// TestRead tests that bodies larger than the limit are rejected.
+func TestRead(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("name: "+strings.Repeat("a", 100)))
+	var c config
+	err := Read(httptest.NewRecorder(), req, &c, 10, false)
+	if err == nil || err.Error() != "body must not be larger than 10 bytes" {
+		t.Errorf("expected a size error, got %v", err)
+	}
+}
+
+// TestWrite tests that written documents decode into the written value.
+func TestWrite(t *testing.T) {
+	c := config{
+		Name:     "- tricky: value #1",
+		Port:     80,
+		Hosts:    []string{"true", "1.5", ""},
+		Labels:   map[string]string{"env": "prod"},
+		Script:   "line 1\nline 2\n",
+		Backends: []backend{{URL: "http://a", Weight: 1}},
+	}
+	rr := httptest.NewRecorder()
+	if err := Write(rr, http.StatusOK, c); err != nil {
+		t.Fatal(err)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/yaml" {
+		t.Errorf("expected content type application/yaml, got %s", ct)
+	}
+	if !strings.Contains(rr.Body.String(), "backends:\n  - url: http://a\n    weight: 1\n") {
+		t.Errorf("expected block style backends, got\n%s", rr.Body.String())
+	}
+
+	var decoded config
+	if err := Decode(rr.Body, &decoded, DefaultMaxBytes, false); err != nil {
+		t.Fatalf("failed to decode written document: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, c) {
+		t.Errorf("expected %+v, got %+v", c, decoded)
+	}
+}
+
+// TestDecode_nested tests that nested sequences on a single line are parsed in
+// linear time.
+func TestDecode_nested(t *testing.T) {
+	var body strings.Builder
+	for range 100 {
+		body.WriteString(strings.Repeat("- ", 900) + "x\n")
+	}
+
+	start := time.Now()
+	var v any
+	if err := Decode(strings.NewReader(body.String()), &v, DefaultMaxBytes, false); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected nested sequences to be parsed quickly, took %s", elapsed)
+	}
+
+	item := v.([]any)[99]
+	for range 899 {
+		item = item.([]any)[0]
+	}
+	if item != "x" {
+		t.Errorf("expected the innermost item to be x, got %v", item)
+	}
+}